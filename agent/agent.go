@@ -0,0 +1,271 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// Component is a long running part of a home agent such as a queue processor,
+// device bridge or state refresher. Run should block until ctx is done or the
+// component fails.
+type Component interface {
+	Run(ctx context.Context) error
+}
+
+// ComponentFunc implements Component as a func
+type ComponentFunc func(ctx context.Context) error
+
+// Run calls the ComponentFunc
+func (c ComponentFunc) Run(ctx context.Context) error {
+	return c(ctx)
+}
+
+// Loop returns a Component that calls fn repeatedly until ctx is done. It suits
+// processors like sqsrelay.QueueProcessor that handle one batch per call.
+func Loop(fn func(ctx context.Context) error) Component {
+	return ComponentFunc(func(ctx context.Context) error {
+		for ctx.Err() == nil {
+			if err := fn(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Every returns a Component that calls fn once per interval until ctx is done. It
+// suits refreshers that poll devices for state. Errors are returned so the agent
+// can log them and restart the component.
+func Every(interval time.Duration, fn func(ctx context.Context) error) Component {
+	return ComponentFunc(func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := fn(ctx); err != nil {
+				return err
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+}
+
+// Agent runs a set of components until it's stopped. Components that fail are
+// restarted after a backoff delay that doubles on each consecutive failure.
+type Agent struct {
+	// MinBackoff is the delay before restarting a failed component. Defaults to 1s.
+	MinBackoff time.Duration
+	// MaxBackoff caps the restart delay. Defaults to 1m.
+	MaxBackoff time.Duration
+	// ShutdownTimeout is how long Run waits for components to return once stopped.
+	// Zero means wait indefinitely.
+	ShutdownTimeout time.Duration
+	// Logger defaults to alexa.StdLogger
+	Logger alexa.Logger
+	// HealthAddr is optionally the address, e.g. ":8080", to serve the agent's health
+	// and readiness from while it runs. See HealthHandler.
+	HealthAddr string
+
+	mu         sync.Mutex
+	components []*component
+	checks     []namedCheck
+	heartbeats []namedHeartbeat
+	running    bool
+	stopping   bool
+}
+
+type component struct {
+	name      string
+	component Component
+
+	mu       sync.Mutex
+	state    string
+	restarts int
+	lastErr  error
+	failedAt time.Time
+}
+
+func (c *component) setState(state string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = state
+}
+
+func (c *component) failed(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = StateBackoff
+	c.restarts++
+	c.lastErr = err
+	c.failedAt = time.Now()
+}
+
+// Add registers a component under name. Components must be added before Run is called.
+func (a *Agent) Add(name string, c Component) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.running {
+		panic(fmt.Sprintf("agent: Add(%q) called after Run", name))
+	}
+	a.components = append(a.components, &component{name: name, component: c, state: StateStarting})
+}
+
+// Run starts all components and blocks until ctx is done and the components have
+// returned. An error is returned if the components don't stop within ShutdownTimeout.
+func (a *Agent) Run(ctx context.Context) error {
+	a.mu.Lock()
+	if a.running {
+		a.mu.Unlock()
+		return errors.New("agent: already running")
+	}
+	a.running = true
+	components := a.components
+	a.mu.Unlock()
+
+	if len(components) == 0 {
+		return errors.New("agent: no components added")
+	}
+
+	var health *http.Server
+	if a.HealthAddr != "" {
+		var err error
+		if health, err = a.serveHealth(ctx); err != nil {
+			return err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range components {
+		wg.Add(1)
+		go func(c *component) {
+			defer wg.Done()
+			a.supervise(ctx, c)
+		}(c)
+	}
+
+	<-ctx.Done()
+	a.logger().Log(ctx, "agent stopping", "components", len(components))
+	a.mu.Lock()
+	a.stopping = true
+	a.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	if a.ShutdownTimeout <= 0 {
+		<-done
+	} else {
+		select {
+		case <-done:
+		case <-time.After(a.ShutdownTimeout):
+			err = fmt.Errorf("agent: components did not stop within %v", a.ShutdownTimeout)
+		}
+	}
+
+	if health != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if shutdownErr := health.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+			err = fmt.Errorf("agent: failed to stop health server: %v", shutdownErr)
+		}
+	}
+
+	return err
+}
+
+// RunUntilSignal calls Run with a context that's cancelled on SIGINT or SIGTERM.
+func (a *Agent) RunUntilSignal(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return a.Run(ctx)
+}
+
+// supervise runs c until ctx is done, restarting it with backoff when it returns early
+func (a *Agent) supervise(ctx context.Context, c *component) {
+	defer c.setState(StateStopped)
+
+	backoff := a.minBackoff()
+	for {
+		started := time.Now()
+		c.setState(StateRunning)
+		err := a.runComponent(ctx, c)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// a component that ran for a while before failing isn't failing consecutively
+		if time.Since(started) > a.maxBackoff() {
+			backoff = a.minBackoff()
+		}
+
+		if err == nil {
+			err = errors.New("returned before agent stopped")
+		}
+		c.failed(err)
+		a.logger().Log(ctx, "agent component failed",
+			"component", c.name, "error", err, "retry", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		backoff *= 2
+		if max := a.maxBackoff(); backoff > max {
+			backoff = max
+		}
+	}
+}
+
+// runComponent runs c, converting a panic into an error so one misbehaving
+// component doesn't take down the agent
+func (a *Agent) runComponent(ctx context.Context, c *component) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return c.component.Run(ctx)
+}
+
+func (a *Agent) minBackoff() time.Duration {
+	if a.MinBackoff <= 0 {
+		return time.Second
+	}
+	return a.MinBackoff
+}
+
+func (a *Agent) maxBackoff() time.Duration {
+	if a.MaxBackoff <= 0 {
+		return time.Minute
+	}
+	return a.MaxBackoff
+}
+
+func (a *Agent) logger() alexa.Logger {
+	if a.Logger == nil {
+		return alexa.StdLogger{}
+	}
+	return a.Logger
+}
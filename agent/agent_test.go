@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func TestAgentRestartsFailedComponents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int32
+	a := &Agent{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+		Logger:     alexa.NopLogger{},
+	}
+	a.Add("flaky", ComponentFunc(func(ctx context.Context) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("boom")
+		}
+		if atomic.LoadInt32(&calls) == 3 {
+			panic("kaboom")
+		}
+		cancel()
+		<-ctx.Done()
+		return nil
+	}))
+
+	if err := a.Run(ctx); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 4 {
+		t.Errorf("expected 4 calls, got %d", got)
+	}
+}
+
+func TestAgentShutdownTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	a := &Agent{ShutdownTimeout: 10 * time.Millisecond, Logger: alexa.NopLogger{}}
+	a.Add("stuck", ComponentFunc(func(ctx context.Context) error {
+		<-stuck
+		return nil
+	}))
+
+	if err := a.Run(ctx); err == nil {
+		t.Error("expected shutdown timeout error")
+	}
+}
+
+func TestAgentHealthHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var heartbeat Heartbeat
+	started := make(chan struct{})
+	a := &Agent{Logger: alexa.NopLogger{}}
+	a.Add("ok", ComponentFunc(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return nil
+	}))
+	var checks int32
+	check := heartbeat.Check(time.Minute)
+	a.AddCheck("heartbeat", func(ctx context.Context) error {
+		atomic.AddInt32(&checks, 1)
+		return check(ctx)
+	})
+	a.AddHeartbeat("heartbeat", &heartbeat)
+
+	done := make(chan error)
+	go func() { done <- a.Run(ctx) }()
+	<-started
+
+	handler := a.HealthHandler()
+	get := func(path string) (int, Status) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var status Status
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("failed to decode status: %v", err)
+		}
+		return rec.Code, status
+	}
+
+	if code, status := get("/healthz"); code != http.StatusOK || !status.Live {
+		t.Errorf("expected live, got %d: %+v", code, status)
+	}
+	// liveness doesn't depend on the checks
+	if n := atomic.LoadInt32(&checks); n != 0 {
+		t.Errorf("expected /healthz not to run checks, ran %d", n)
+	}
+	if code, status := get("/readyz"); code != http.StatusServiceUnavailable || status.Checks[0].OK {
+		t.Errorf("expected not ready before heartbeat, got %d: %+v", code, status)
+	}
+
+	heartbeat.Beat()
+	code, status := get("/readyz")
+	if code != http.StatusOK {
+		t.Errorf("expected ready, got %d: %+v", code, status)
+	}
+	if status.Components[0].State != StateRunning || status.Heartbeats[0].Last == nil {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if code, _ := get("/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected not live after stopping, got %d", code)
+	}
+}
@@ -0,0 +1,276 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+// Component states
+const (
+	StateStarting = "starting"
+	StateRunning  = "running"
+	StateBackoff  = "backoff"
+	StateStopped  = "stopped"
+)
+
+// checkTimeout bounds how long a single readiness check may take
+const checkTimeout = 5 * time.Second
+
+// Check reports whether some dependency of the agent is healthy. A nil error means healthy.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// AddCheck registers a check that must pass for the agent to be ready. Checks must
+// be added before Run is called.
+func (a *Agent) AddCheck(name string, check Check) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.running {
+		panic(fmt.Sprintf("agent: AddCheck(%q) called after Run", name))
+	}
+	a.checks = append(a.checks, namedCheck{name: name, check: check})
+}
+
+type namedHeartbeat struct {
+	name      string
+	heartbeat *Heartbeat
+}
+
+// AddHeartbeat includes when heartbeat last beat in the agent's Status without
+// affecting readiness, e.g. to report the last successful event send. Use
+// Heartbeat.Check to make readiness depend on it.
+func (a *Agent) AddHeartbeat(name string, heartbeat *Heartbeat) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.running {
+		panic(fmt.Sprintf("agent: AddHeartbeat(%q) called after Run", name))
+	}
+	a.heartbeats = append(a.heartbeats, namedHeartbeat{name: name, heartbeat: heartbeat})
+}
+
+// Status is a snapshot of the health of an agent
+type Status struct {
+	// Live is false once the agent has started stopping
+	Live bool `json:"live"`
+	// Ready is true when every component is running and every check passes
+	Ready      bool              `json:"ready"`
+	Components []ComponentStatus `json:"components"`
+	Checks     []CheckStatus     `json:"checks,omitempty"`
+	Heartbeats []HeartbeatStatus `json:"heartbeats,omitempty"`
+}
+
+// ComponentStatus describes the state of a single component
+type ComponentStatus struct {
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	Restarts  int        `json:"restarts"`
+	LastError string     `json:"lastError,omitempty"`
+	FailedAt  *time.Time `json:"failedAt,omitempty"`
+}
+
+// CheckStatus is the outcome of a single check
+type CheckStatus struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// HeartbeatStatus reports when a heartbeat last beat
+type HeartbeatStatus struct {
+	Name string     `json:"name"`
+	Last *time.Time `json:"last,omitempty"`
+}
+
+// Status runs the registered checks and reports the state of the agent's components.
+func (a *Agent) Status(ctx context.Context) Status {
+	a.mu.Lock()
+	components := a.components
+	checks := a.checks
+	heartbeats := a.heartbeats
+	status := Status{Live: a.live()}
+	a.mu.Unlock()
+
+	status.Ready = status.Live
+	for _, c := range components {
+		c.mu.Lock()
+		compStatus := ComponentStatus{
+			Name:     c.name,
+			State:    c.state,
+			Restarts: c.restarts,
+		}
+		if c.lastErr != nil {
+			failedAt := c.failedAt
+			compStatus.LastError = c.lastErr.Error()
+			compStatus.FailedAt = &failedAt
+		}
+		c.mu.Unlock()
+
+		if compStatus.State != StateRunning {
+			status.Ready = false
+		}
+		status.Components = append(status.Components, compStatus)
+	}
+
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := c.check(checkCtx)
+		cancel()
+
+		checkStatus := CheckStatus{Name: c.name, OK: err == nil}
+		if err != nil {
+			checkStatus.Error = err.Error()
+			status.Ready = false
+		}
+		status.Checks = append(status.Checks, checkStatus)
+	}
+
+	for _, h := range heartbeats {
+		heartbeatStatus := HeartbeatStatus{Name: h.name}
+		if last := h.heartbeat.Last(); !last.IsZero() {
+			heartbeatStatus.Last = &last
+		}
+		status.Heartbeats = append(status.Heartbeats, heartbeatStatus)
+	}
+
+	return status
+}
+
+// liveness is the body of /healthz
+type liveness struct {
+	Live bool `json:"live"`
+}
+
+// HealthHandler serves the agent's health for process supervisors such as systemd
+// or Kubernetes. /healthz responds 200 while the agent is running. It doesn't run the
+// checks so a failing dependency doesn't get the process restarted. /readyz responds
+// 200 when the agent is ready and includes the Status as json. Both respond 503
+// otherwise.
+func (a *Agent) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		live := a.live()
+		a.mu.Unlock()
+		writeStatus(w, liveness{Live: live}, live)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		status := a.Status(r.Context())
+		writeStatus(w, status, status.Ready)
+	})
+	return mux
+}
+
+func writeStatus(w http.ResponseWriter, status interface{}, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// live reports whether the agent is running and not stopping. a.mu must be held.
+func (a *Agent) live() bool {
+	return a.running && !a.stopping
+}
+
+// serveHealth starts serving HealthHandler on HealthAddr
+func (a *Agent) serveHealth(ctx context.Context) (*http.Server, error) {
+	l, err := net.Listen("tcp", a.HealthAddr)
+	if err != nil {
+		return nil, fmt.Errorf("agent: failed to listen on %s: %v", a.HealthAddr, err)
+	}
+
+	server := &http.Server{
+		Handler:           a.HealthHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+			a.logger().Log(ctx, "agent health server failed", "error", err)
+		}
+	}()
+
+	return server, nil
+}
+
+// Heartbeat records when some activity last succeeded, such as receiving from a
+// queue or sending an event. It's safe for concurrent use.
+type Heartbeat struct {
+	last int64
+}
+
+// Beat records that the activity succeeded now
+func (h *Heartbeat) Beat() {
+	atomic.StoreInt64(&h.last, time.Now().UnixNano())
+}
+
+// Last returns when Beat was last called or the zero time if it never was
+func (h *Heartbeat) Last() time.Time {
+	last := atomic.LoadInt64(&h.last)
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+// Check returns a Check that fails if Beat hasn't been called within maxAge.
+func (h *Heartbeat) Check(maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		last := h.Last()
+		if last.IsZero() {
+			return errors.New("no activity yet")
+		}
+		if age := time.Since(last); age > maxAge {
+			return fmt.Errorf("no activity for %v", age.Round(time.Second))
+		}
+		return nil
+	}
+}
+
+// SendHeartbeat wraps sender so heartbeat beats after each successful send.
+func SendHeartbeat(sender deferred.EventSender, heartbeat *Heartbeat) deferred.EventSender {
+	return deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+		if err := sender.Send(ctx, resp); err != nil {
+			return err
+		}
+		heartbeat.Beat()
+		return nil
+	})
+}
+
+// TokenCheck returns a Check that fails if the user's stored token is missing, can't
+// be refreshed or expired more than maxStale ago. Tokens are refreshed as events are
+// sent so a long expired token suggests refreshes are failing.
+func TokenCheck(store alexa.TokenReader, userID string, maxStale time.Duration) Check {
+	return func(ctx context.Context) error {
+		token, err := store.Read(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to read token: %v", err)
+		}
+		if token == nil {
+			return errors.New("no token stored")
+		}
+		if token.RefreshToken == "" {
+			return errors.New("token has no refresh token")
+		}
+		if !token.Expiry.IsZero() && time.Since(token.Expiry) > maxStale {
+			return fmt.Errorf("token expired at %s", token.Expiry.Format(time.RFC3339))
+		}
+		return nil
+	}
+}
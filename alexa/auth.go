@@ -3,9 +3,11 @@ package alexa
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"golang.org/x/oauth2"
 )
@@ -26,24 +28,55 @@ type TokenReader interface {
 	Read(ctx context.Context, id string) (*oauth2.Token, error)
 }
 
-// UserIDReader uses the bearerToken from the skill request to look up the user's id
+// TokenDeleter removes a user's stored oauth tokens
+type TokenDeleter interface {
+	Delete(ctx context.Context, id string) error
+}
+
+// TokenLister lists the ids of the users with stored oauth tokens
+type TokenLister interface {
+	List(ctx context.Context) ([]string, error)
+}
+
+// UserIDReader uses the bearerToken from the skill request to look up the user's id.
+// Errors for a rejected token should wrap ErrInvalidToken.
 type UserIDReader interface {
 	Read(ctx context.Context, bearerToken string) (string, error)
 }
 
+// ErrInvalidToken is wrapped by UserIDReader errors when the bearer token itself was
+// rejected, e.g. because it expired, as opposed to the lookup failing
+var ErrInvalidToken = errors.New("invalid token")
+
+// invalidToken creates an error wrapping ErrInvalidToken
+func invalidToken(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidToken, fmt.Sprintf(format, args...))
+}
+
 // HTTPDoer performs a HTTP request (HTTPClient implements this)
 type HTTPDoer interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// DefaultProfileURL is the Login with Amazon user profile api
+const DefaultProfileURL = "https://api.amazon.com/user/profile"
+
 // ProfileUserIDReader retrieves the user's Amazon account user id.
 // It also has access to the user's name and email but it is not returned.
 type ProfileUserIDReader struct {
+	// HTTPDoer defaults to DefaultHTTPClient
 	HTTPDoer HTTPDoer
+	// ProfileURL overrides DefaultProfileURL if set
+	ProfileURL string
 }
 
 func (p *ProfileUserIDReader) Read(ctx context.Context, bearerToken string) (string, error) {
-	profileReq, err := http.NewRequest(http.MethodGet, "https://api.amazon.com/user/profile", nil)
+	profileURL := p.ProfileURL
+	if profileURL == "" {
+		profileURL = DefaultProfileURL
+	}
+
+	profileReq, err := http.NewRequest(http.MethodGet, profileURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to build profile request: %v", err)
 	}
@@ -52,7 +85,7 @@ func (p *ProfileUserIDReader) Read(ctx context.Context, bearerToken string) (str
 	profileReq.Header.Set("Content-Type", "application/json")
 	profileReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", bearerToken))
 
-	profileResp, err := p.HTTPDoer.Do(profileReq)
+	profileResp, err := HTTPDoerOrDefault(p.HTTPDoer).Do(profileReq)
 	if err != nil {
 		return "", fmt.Errorf("failed to perform profile request: %v", err)
 	}
@@ -63,6 +96,9 @@ func (p *ProfileUserIDReader) Read(ctx context.Context, bearerToken string) (str
 		return "", fmt.Errorf("failed to read profile body: %v", err)
 	}
 
+	if profileResp.StatusCode == http.StatusBadRequest || profileResp.StatusCode == http.StatusUnauthorized {
+		return "", invalidToken("profile response status code: %s", profileResp.Status)
+	}
 	if profileResp.StatusCode != http.StatusOK && profileResp.StatusCode != http.StatusAccepted {
 		return "", fmt.Errorf("profile response unexpected status code: %s", profileResp.Status)
 	}
@@ -79,3 +115,40 @@ func (p *ProfileUserIDReader) Read(ctx context.Context, bearerToken string) (str
 
 	return profileData.UserID, nil
 }
+
+// CachingUserIDReader caches the user ids resolved by another UserIDReader. Concurrent
+// lookups of the same token share a single call to the underlying reader.
+// Failed lookups are not cached.
+type CachingUserIDReader struct {
+	reader UserIDReader
+	cache  *ttlCache
+	group  flightGroup
+}
+
+// NewCachingUserIDReader creates a CachingUserIDReader that caches ids for ttl.
+func NewCachingUserIDReader(reader UserIDReader, ttl time.Duration) *CachingUserIDReader {
+	return &CachingUserIDReader{
+		reader: reader,
+		cache:  newTTLCache(ttl),
+	}
+}
+
+func (c *CachingUserIDReader) Read(ctx context.Context, bearerToken string) (string, error) {
+	if userID, ok := c.cache.get(bearerToken); ok {
+		return userID, nil
+	}
+
+	return c.group.do(ctx, bearerToken, func(ctx context.Context) (string, error) {
+		userID, err := c.reader.Read(ctx, bearerToken)
+		if err != nil {
+			return "", err
+		}
+		c.cache.set(bearerToken, userID)
+		return userID, nil
+	})
+}
+
+// Forget removes any cached user id for bearerToken
+func (c *CachingUserIDReader) Forget(bearerToken string) {
+	c.cache.delete(bearerToken)
+}
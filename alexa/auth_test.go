@@ -0,0 +1,152 @@
+package alexa
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestCachingUserIDReader(t *testing.T) {
+	var calls int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	reader := NewCachingUserIDReader(userIDReaderFunc(func(ctx context.Context, token string) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		if token == "bad" {
+			return "", errors.New("invalid token")
+		}
+		return "user-" + token, nil
+	}), time.Minute)
+
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			userID, err := reader.Read(ctx, "abc")
+			if err != nil || userID != "user-abc" {
+				t.Errorf("unexpected result: %s %v", userID, err)
+			}
+		}()
+	}
+	// callers arriving after the lookup completes are served from the cache so there's
+	// a single lookup however the goroutines are scheduled
+	<-started
+	close(release)
+	wg.Wait()
+
+	if _, err := reader.Read(ctx, "abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 lookup but got %d", calls)
+	}
+
+	if _, err := reader.Read(ctx, "bad"); err == nil {
+		t.Fatalf("expected error for bad token")
+	}
+	if _, err := reader.Read(ctx, "bad"); err == nil {
+		t.Fatalf("expected error for bad token")
+	}
+	if calls != 3 {
+		t.Fatalf("expected failed lookups to be retried but got %d calls", calls)
+	}
+
+	now := time.Now()
+	reader.cache.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if _, err := reader.Read(ctx, "abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 4 {
+		t.Fatalf("expected expired entry to be refreshed but got %d calls", calls)
+	}
+}
+
+func TestFlightGroup(t *testing.T) {
+	var group flightGroup
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	// the first caller giving up doesn't cancel the shared call
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error)
+	callErr := make(chan error, 1)
+	go func() {
+		_, err := group.do(leaderCtx, "key", func(ctx context.Context) (string, error) {
+			close(started)
+			<-release
+			callErr <- ctx.Err()
+			return "value", nil
+		})
+		leaderErr <- err
+	}()
+	<-started
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the leader to stop waiting, got %v", err)
+	}
+	close(release)
+	if err := <-callErr; err != nil {
+		t.Errorf("expected the call to continue after the leader gave up, got %v", err)
+	}
+
+	// a panic is returned to every caller instead of leaving them waiting
+	_, err := group.do(context.Background(), "panic", func(ctx context.Context) (string, error) {
+		panic("boom")
+	})
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Errorf("expected a PanicError, got %v", err)
+	}
+}
+
+func TestTTLCacheExpiry(t *testing.T) {
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	cache := newTTLCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.set("a", "1")
+	now = now.Add(30 * time.Second)
+	cache.set("b", "2")
+	cache.set("a", "3")
+	now = now.Add(45 * time.Second)
+	cache.set("c", "4")
+
+	// a was set again so only its first expiry has passed
+	if value, ok := cache.get("a"); !ok || value != "3" {
+		t.Errorf("expected a to be refreshed, got %s %t", value, ok)
+	}
+	if len(cache.entries) != 3 || len(cache.expiry) != 3 {
+		t.Errorf("expected only the expired entry to be dropped: %v %v", cache.entries, cache.expiry)
+	}
+
+	now = now.Add(time.Minute)
+	cache.set("d", "5")
+	if len(cache.entries) != 1 || len(cache.expiry) != 1 {
+		t.Errorf("expected expired entries to be dropped: %v %v", cache.entries, cache.expiry)
+	}
+}
+
+type userIDReaderFunc func(ctx context.Context, token string) (string, error)
+
+func (u userIDReaderFunc) Read(ctx context.Context, token string) (string, error) {
+	return u(ctx, token)
+}
+
+type tokenWriterFunc func(ctx context.Context, id string, token *oauth2.Token) error
+
+func (t tokenWriterFunc) Write(ctx context.Context, id string, token *oauth2.Token) error {
+	return t(ctx, id, token)
+}
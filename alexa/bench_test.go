@@ -0,0 +1,201 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"testing"
+)
+
+// Baselines for these benchmarks are recorded in the README. Re-run them when changing
+// routing, response building or validation and update the README if they shift.
+
+func benchmarkEndpoints(n int) []DiscoverEndpoint {
+	endpoints := make([]DiscoverEndpoint, 0, n)
+	for i := 0; i < n; i++ {
+		endpoints = append(endpoints, DiscoverEndpoint{
+			EndpointID:        "switch-" + strconv.Itoa(i),
+			ManufacturerName:  "Acme",
+			FriendlyName:      "Switch " + strconv.Itoa(i),
+			Description:       "Smart switch",
+			DisplayCategories: []string{DisplayCategorySwitch},
+			Capabilities: []DiscoverCapability{
+				{Type: "AlexaInterface", Interface: "Alexa", Version: "3"},
+				{
+					Type:      "AlexaInterface",
+					Interface: InterfacePowerController,
+					Version:   "3",
+					Properties: &DiscoverProperties{
+						Supported:   []DiscoverProperty{{Name: "powerState"}},
+						Retrievable: true,
+					},
+				},
+			},
+		})
+	}
+	return endpoints
+}
+
+// benchmarkMux routes power and percentage directives to 100 switch endpoints
+func benchmarkMux() (Handler, *Request) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+
+	endpointMux := NewEndpointMux()
+	for i := 0; i < 100; i++ {
+		endpointMux.HandleFunc("switch-"+strconv.Itoa(i), func(ctx context.Context, req *Request) (*Response, error) {
+			return rb.BasicResponse(req), nil
+		})
+	}
+	namespaceMux := NewNamespaceMux()
+	namespaceMux.Handle(NamespacePowerController, endpointMux)
+	namespaceMux.Handle(NamespacePercentageController, endpointMux)
+
+	req := &Request{
+		Directive: RequestDirective{
+			Header:   Header{Namespace: NamespacePowerController, Name: "TurnOn"},
+			Endpoint: RequestEndpoint{EndpointID: "switch-42"},
+		},
+	}
+	return namespaceMux, req
+}
+
+func BenchmarkMux(b *testing.B) {
+	mux, req := benchmarkMux()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mux.HandleRequest(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestAllocationBaselines fails when a hot path allocates more than its README baseline so
+// regressions are caught by go test rather than only by re-running the benchmarks
+func TestAllocationBaselines(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations aren't representative with the race detector on")
+	}
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	var req Request
+	if err := json.Unmarshal([]byte(sampleRequest), &req); err != nil {
+		t.Fatal(err)
+	}
+	properties := testProperties()
+	scope := Scope{Type: "BearerToken", Token: "token"}
+	mux, muxReq := benchmarkMux()
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		run       func() error
+		maxAllocs float64
+	}{
+		"Mux": {
+			run: func() error {
+				_, err := mux.HandleRequest(ctx, muxReq)
+				return err
+			},
+			maxAllocs: 3,
+		},
+		"ResponseBuilder/BasicResponse": {
+			run: func() error {
+				_, err := MarshalResponse(rb.BasicResponse(&req, properties...))
+				return err
+			},
+			maxAllocs: 6,
+		},
+		"ChangeReport/WithChangeReport": {
+			run: func() error {
+				return rb.WithChangeReport(scope, "fan-1", CausePhysicalInteraction, properties[:1], properties[1:],
+					func(resp *Response) error {
+						return EncodeResponse(ioutil.Discard, resp)
+					})
+			},
+			maxAllocs: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var err error
+			allocs := testing.AllocsPerRun(100, func() {
+				if runErr := test.run(); runErr != nil {
+					err = runErr
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allocs > test.maxAllocs {
+				t.Errorf("%v allocs/op exceeds the baseline of %v", allocs, test.maxAllocs)
+			}
+		})
+	}
+}
+
+func BenchmarkResponseBuilder(b *testing.B) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	var req Request
+	if err := json.Unmarshal([]byte(sampleRequest), &req); err != nil {
+		b.Fatal(err)
+	}
+	properties := testProperties()
+
+	b.Run("BasicResponse", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := MarshalResponse(rb.BasicResponse(&req, properties...)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	for _, n := range []int{10, 300} {
+		endpoints := benchmarkEndpoints(n)
+		b.Run("DiscoverResponse/"+strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				resp, err := rb.DiscoverResponse(endpoints...)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := MarshalResponse(resp); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkValidateResponse(b *testing.B) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	var req Request
+	if err := json.Unmarshal([]byte(sampleRequest), &req); err != nil {
+		b.Fatal(err)
+	}
+
+	discover, err := rb.DiscoverResponse(benchmarkEndpoints(10)...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for name, resp := range map[string]*Response{
+		"StateReport":      rb.StateReportResponse(&req, testProperties()[:1]...),
+		"DiscoverResponse": discover,
+	} {
+		respJSON, err := MarshalResponse(resp)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := ValidateResponse(respJSON); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package alexa
+
+import "context"
+
+// PropertyBrightness is the Alexa.BrightnessController property holding the brightness
+// percentage of an endpoint
+const PropertyBrightness = "brightness"
+
+type SetBrightnessPayload struct {
+	Brightness int `json:"brightness"`
+}
+
+// Validate checks that the brightness is within 0 to 100
+func (p SetBrightnessPayload) Validate() error {
+	return validateRange("brightness", float64(p.Brightness), 0, 100)
+}
+
+type AdjustBrightnessPayload struct {
+	BrightnessDelta int `json:"brightnessDelta"`
+}
+
+// Validate checks that the delta is within -100 to 100
+func (p AdjustBrightnessPayload) Validate() error {
+	return validateRange("brightnessDelta", float64(p.BrightnessDelta), -100, 100)
+}
+
+// BrightnessControllerHandler routes handling of set & adjust brightness directives
+func BrightnessControllerHandler(setBrightness, adjustBrightness Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "SetBrightness":
+			return setBrightness.HandleRequest(ctx, req)
+		case "AdjustBrightness":
+			return adjustBrightness.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("BrightnessControllerHandler", req)
+		}
+	}
+}
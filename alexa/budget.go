@@ -0,0 +1,129 @@
+package alexa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBudgetExceeded indicates too little time remains before a deadline for a stage
+var ErrBudgetExceeded = errors.New("deadline budget exceeded")
+
+// Stage is a step in handling a request that a Budget allots time to
+type Stage int
+
+// Stage enums
+const (
+	// StageRelay publishes a request for handling elsewhere, see Relayer
+	StageRelay Stage = iota
+	// StageHandle handles a request with the device
+	StageHandle
+	// StageSend returns or sends the response event
+	StageSend
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageRelay:
+		return "relay"
+	case StageHandle:
+		return "handle"
+	case StageSend:
+		return "send"
+	default:
+		return fmt.Sprintf("Stage(%d)", int(s))
+	}
+}
+
+// Budget splits the time remaining before a request's deadline between relaying or
+// handling the request and sending its response. Relaying and handling may use the
+// remaining time except for the minimum reserved for sending.
+type Budget struct {
+	// Relay is the minimum time needed to relay a request
+	Relay time.Duration
+	// Handle is the minimum time needed to handle a request
+	Handle time.Duration
+	// Send is the minimum time needed to send a response and is reserved by the other stages
+	Send time.Duration
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+}
+
+// Fits reports if the minimum time of stage and the stages after it remains before ctx's
+// deadline. It's always true without a deadline.
+func (b *Budget) Fits(ctx context.Context, stage Stage) bool {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+	return deadline.Sub(b.now()) >= b.minimum(stage)+b.reserve(stage)
+}
+
+// Context returns a context for stage with a deadline that leaves the time reserved
+// for later stages. ErrBudgetExceeded is returned if the stage's minimum doesn't fit.
+// Without a deadline on ctx the context is unbounded.
+func (b *Budget) Context(ctx context.Context, stage Stage) (context.Context, context.CancelFunc, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ctx, func() {}, nil
+	}
+	if !b.Fits(ctx, stage) {
+		return nil, nil, fmt.Errorf("%w: %s needs %v of %v remaining", ErrBudgetExceeded,
+			stage, b.minimum(stage)+b.reserve(stage), deadline.Sub(b.now()))
+	}
+	stageCtx, cancel := context.WithDeadline(ctx, deadline.Add(-b.reserve(stage)))
+	return stageCtx, cancel, nil
+}
+
+func (b *Budget) minimum(stage Stage) time.Duration {
+	switch stage {
+	case StageRelay:
+		return b.Relay
+	case StageHandle:
+		return b.Handle
+	default:
+		return b.Send
+	}
+}
+
+// reserve returns the time stage must leave for the stages after it
+func (b *Budget) reserve(stage Stage) time.Duration {
+	if stage == StageSend {
+		return 0
+	}
+	return b.Send
+}
+
+func (b *Budget) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+	return time.Now()
+}
+
+// BudgetHandler handles a request synchronously with handler while the budget allows
+// for it. Otherwise the request is relayed and a DeferredResponse is returned early
+// so the response is sent to the smart home api once handled elsewhere.
+func BudgetHandler(budget *Budget, relayer Relayer, builder *ResponseBuilder, handler Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		if budget.Fits(ctx, StageHandle) {
+			handleCtx, cancel, err := budget.Context(ctx, StageHandle)
+			if err != nil {
+				return nil, err
+			}
+			defer cancel()
+			return handler.HandleRequest(handleCtx, req)
+		}
+
+		relayCtx, cancel, err := budget.Context(ctx, StageRelay)
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+		if err := relayer.Relay(relayCtx, req); err != nil {
+			return nil, fmt.Errorf("failed to relay: %v", err)
+		}
+		return builder.DeferredResponse(req), nil
+	}
+}
@@ -0,0 +1,142 @@
+package alexa
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ttlCache is a concurrency safe string cache with expiring entries
+type ttlCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]ttlCacheEntry
+	// expiry holds keys in the order they were set. With a fixed ttl this is also the
+	// order they expire in so expired entries are dropped from the front.
+	expiry []ttlCacheKey
+}
+
+type ttlCacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+type ttlCacheKey struct {
+	key     string
+	expires time.Time
+}
+
+func newTTLCache(ttl time.Duration) *ttlCache {
+	return &ttlCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]ttlCacheEntry),
+	}
+}
+
+func (c *ttlCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return entry.value, true
+}
+
+func (c *ttlCache) set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.expire(now)
+	expires := now.Add(c.ttl)
+	c.entries[key] = ttlCacheEntry{value, expires}
+	c.expiry = append(c.expiry, ttlCacheKey{key, expires})
+}
+
+// expire drops the entries that expired before now so abandoned tokens don't accumulate.
+// Only expired entries are visited.
+func (c *ttlCache) expire(now time.Time) {
+	n := 0
+	for ; n < len(c.expiry) && !now.Before(c.expiry[n].expires); n++ {
+		expired := c.expiry[n]
+		// the key may have been set again since
+		if entry, ok := c.entries[expired.key]; ok && entry.expires.Equal(expired.expires) {
+			delete(c.entries, expired.key)
+		}
+	}
+	if n > 0 {
+		c.expiry = append(c.expiry[:0], c.expiry[n:]...)
+	}
+}
+
+func (c *ttlCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+// flightTimeout bounds a call shared by a flightGroup as it isn't cancelled with
+// the callers' contexts
+const flightTimeout = time.Minute
+
+// flightGroup collapses concurrent calls for the same key into a single call
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done  chan struct{}
+	value string
+	err   error
+}
+
+// do calls fn once for concurrent callers with the same key. fn runs in the background
+// with the values of the first caller's ctx but not its cancellation so one caller
+// giving up doesn't fail the others. Each caller stops waiting when its own ctx is done.
+// A panic in fn is returned to every caller as a PanicError.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (string, error)) (string, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go g.call(ctx, key, call, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (g *flightGroup) call(ctx context.Context, key string, call *flightCall, fn func(ctx context.Context) (string, error)) {
+	defer func() {
+		if panicErr := RecoverPanic(recover()); panicErr != nil {
+			call.value, call.err = "", panicErr
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	callCtx, cancel := context.WithTimeout(Detach(ctx), flightTimeout)
+	defer cancel()
+	call.value, call.err = fn(callCtx)
+}
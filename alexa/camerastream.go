@@ -0,0 +1,254 @@
+package alexa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Camera stream protocol enums
+const (
+	ProtocolRTSP = "RTSP"
+	ProtocolHLS  = "HLS"
+)
+
+// Camera stream authorization type enums
+const (
+	AuthorizationTypeBasic  = "BASIC"
+	AuthorizationTypeDigest = "DIGEST"
+	AuthorizationTypeNone   = "NONE"
+)
+
+// Video codec enums
+const (
+	VideoCodecH264  = "H264"
+	VideoCodecMPEG2 = "MPEG2"
+	VideoCodecMJPEG = "MJPEG"
+	VideoCodecJPG   = "JPG"
+)
+
+// Audio codec enums
+const (
+	AudioCodecG711 = "G711"
+	AudioCodecAAC  = "AAC"
+	AudioCodecNone = "NONE"
+)
+
+var (
+	cameraProtocols          = []string{ProtocolRTSP, ProtocolHLS}
+	cameraAuthorizationTypes = []string{AuthorizationTypeBasic, AuthorizationTypeDigest, AuthorizationTypeNone}
+	cameraVideoCodecs        = []string{VideoCodecH264, VideoCodecMPEG2, VideoCodecMJPEG, VideoCodecJPG}
+	cameraAudioCodecs        = []string{AudioCodecG711, AudioCodecAAC, AudioCodecNone}
+)
+
+type Resolution struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// CameraStreamConfiguration describes a combination of stream options a camera supports
+type CameraStreamConfiguration struct {
+	Protocols          []string     `json:"protocols"`
+	Resolutions        []Resolution `json:"resolutions"`
+	AuthorizationTypes []string     `json:"authorizationTypes"`
+	VideoCodecs        []string     `json:"videoCodecs"`
+	AudioCodecs        []string     `json:"audioCodecs"`
+}
+
+// Validate checks that each option list is set, free of duplicates and only holds known values
+func (c CameraStreamConfiguration) Validate() error {
+	for _, option := range []struct {
+		name    string
+		values  []string
+		allowed []string
+	}{
+		{"protocols", c.Protocols, cameraProtocols},
+		{"authorizationTypes", c.AuthorizationTypes, cameraAuthorizationTypes},
+		{"videoCodecs", c.VideoCodecs, cameraVideoCodecs},
+		{"audioCodecs", c.AudioCodecs, cameraAudioCodecs},
+	} {
+		if len(option.values) == 0 {
+			return fmt.Errorf("%s must be set", option.name)
+		}
+		seen := make(map[string]bool, len(option.values))
+		for _, value := range option.values {
+			if !contains(option.allowed, value) {
+				return fmt.Errorf("%s has unknown value %q", option.name, value)
+			}
+			if seen[value] {
+				return fmt.Errorf("%s has duplicate value %q", option.name, value)
+			}
+			seen[value] = true
+		}
+	}
+
+	if len(c.Resolutions) == 0 {
+		return errors.New("resolutions must be set")
+	}
+	seen := make(map[Resolution]bool, len(c.Resolutions))
+	for _, resolution := range c.Resolutions {
+		if resolution.Width <= 0 || resolution.Height <= 0 {
+			return fmt.Errorf("resolution %dx%d must be positive", resolution.Width, resolution.Height)
+		}
+		if seen[resolution] {
+			return fmt.Errorf("resolutions has duplicate value %dx%d", resolution.Width, resolution.Height)
+		}
+		seen[resolution] = true
+	}
+
+	return nil
+}
+
+// Supports reports if the requested stream can be provided with this configuration
+func (c CameraStreamConfiguration) Supports(requested CameraStreamRequest) bool {
+	if !contains(c.Protocols, requested.Protocol) || !contains(c.AuthorizationTypes, requested.AuthorizationType) ||
+		!contains(c.VideoCodecs, requested.VideoCodec) || !contains(c.AudioCodecs, requested.AudioCodec) {
+		return false
+	}
+	for _, resolution := range c.Resolutions {
+		if resolution == requested.Resolution {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCameraStreamCapability creates the discovery capability of a camera after validating
+// its stream configurations
+func NewCameraStreamCapability(configs ...CameraStreamConfiguration) (DiscoverCapability, error) {
+	if len(configs) == 0 {
+		return DiscoverCapability{}, errors.New("camera stream capability requires a configuration")
+	}
+	for i, config := range configs {
+		if err := config.Validate(); err != nil {
+			return DiscoverCapability{}, fmt.Errorf("invalid camera stream configuration %d: %v", i, err)
+		}
+	}
+	return DiscoverCapability{
+		Type:                       "AlexaInterface",
+		Interface:                  InterfaceCameraStreamController,
+		Version:                    "3",
+		CameraStreamConfigurations: configs,
+	}, nil
+}
+
+// CameraStreamRequest is a stream requested by an InitializeCameraStreams directive
+type CameraStreamRequest struct {
+	Protocol          string     `json:"protocol"`
+	Resolution        Resolution `json:"resolution"`
+	AuthorizationType string     `json:"authorizationType"`
+	VideoCodec        string     `json:"videoCodec"`
+	AudioCodec        string     `json:"audioCodec"`
+}
+
+// InitializeCameraStreamsPayload is the payload of an InitializeCameraStreams directive
+type InitializeCameraStreamsPayload struct {
+	CameraStreams []CameraStreamRequest `json:"cameraStreams"`
+}
+
+// CameraStream is a stream provided in response to an InitializeCameraStreams directive
+type CameraStream struct {
+	URI                string     `json:"uri"`
+	ExpirationTime     *time.Time `json:"expirationTime,omitempty"`
+	IdleTimeoutSeconds int        `json:"idleTimeoutSeconds,omitempty"`
+	Protocol           string     `json:"protocol"`
+	Resolution         Resolution `json:"resolution"`
+	AuthorizationType  string     `json:"authorizationType"`
+	VideoCodec         string     `json:"videoCodec"`
+	AudioCodec         string     `json:"audioCodec"`
+}
+
+// NewCameraStream creates a stream at uri matching the requested stream. A non-zero
+// expiration sets when the uri stops being valid.
+func NewCameraStream(uri string, requested CameraStreamRequest, expiration time.Time) CameraStream {
+	stream := CameraStream{
+		URI:               uri,
+		Protocol:          requested.Protocol,
+		Resolution:        requested.Resolution,
+		AuthorizationType: requested.AuthorizationType,
+		VideoCodec:        requested.VideoCodec,
+		AudioCodec:        requested.AudioCodec,
+	}
+	if !expiration.IsZero() {
+		expiration = expiration.UTC()
+		stream.ExpirationTime = &expiration
+	}
+	return stream
+}
+
+// Validate checks that the uri suits the protocol, RTSP streams require an rtsps or rtsp
+// uri and HLS streams an https uri, and that the stream options are known values.
+func (s CameraStream) Validate() error {
+	uri, err := url.Parse(s.URI)
+	if err != nil {
+		return fmt.Errorf("invalid stream uri: %v", err)
+	}
+	switch s.Protocol {
+	case ProtocolRTSP:
+		if uri.Scheme != "rtsp" && uri.Scheme != "rtsps" {
+			return fmt.Errorf("RTSP stream uri must be rtsp or rtsps, got %q", uri.Scheme)
+		}
+	case ProtocolHLS:
+		if uri.Scheme != "https" {
+			return fmt.Errorf("HLS stream uri must be https, got %q", uri.Scheme)
+		}
+	default:
+		return fmt.Errorf("unknown protocol %q", s.Protocol)
+	}
+
+	switch {
+	case !contains(cameraAuthorizationTypes, s.AuthorizationType):
+		return fmt.Errorf("unknown authorizationType %q", s.AuthorizationType)
+	case !contains(cameraVideoCodecs, s.VideoCodec):
+		return fmt.Errorf("unknown videoCodec %q", s.VideoCodec)
+	case !contains(cameraAudioCodecs, s.AudioCodec):
+		return fmt.Errorf("unknown audioCodec %q", s.AudioCodec)
+	case s.IdleTimeoutSeconds < 0:
+		return errors.New("idleTimeoutSeconds must not be negative")
+	}
+	return nil
+}
+
+// CameraStreamsPayload answers an InitializeCameraStreams directive. ImageURI optionally
+// links a still image shown while the stream loads.
+type CameraStreamsPayload struct {
+	CameraStreams []CameraStream `json:"cameraStreams"`
+	ImageURI      string         `json:"imageUri,omitempty"`
+}
+
+// CameraStreamControllerHandler routes initialize camera streams requests
+func CameraStreamControllerHandler(initializeStreams Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "InitializeCameraStreams":
+			return initializeStreams.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("CameraStreamControllerHandler", req)
+		}
+	}
+}
+
+// CameraStreamsResponse answers an InitializeCameraStreams directive with the streams
+// after validating them
+func (r *ResponseBuilder) CameraStreamsResponse(req *Request, payload CameraStreamsPayload) (*Response, error) {
+	if len(payload.CameraStreams) == 0 {
+		return nil, errors.New("camera streams response requires a stream")
+	}
+	for i, stream := range payload.CameraStreams {
+		if err := stream.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid camera stream %d: %v", i, err)
+		}
+	}
+	return TypedResponse(r, req, NamespaceCameraStreamController, "Response", payload)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package alexa
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Catalog holds the translations of the text assets of a skill, such as the friendly
+// names of capability instances, mode labels and preset names, keyed by locale and then
+// message key. It lets multi-locale skills describe resources once by key rather than
+// hardcoding English text, see Localize.
+type Catalog struct {
+	messages map[string]map[string]string
+}
+
+// ParseCatalog decodes a json object mapping each locale to its messages, e.g.
+// {"en-US": {"fan.speed": "Fan Speed"}, "de-DE": {"fan.speed": "Lüftergeschwindigkeit"}}
+func ParseCatalog(data []byte) (*Catalog, error) {
+	var messages map[string]map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %v", err)
+	}
+
+	c := &Catalog{}
+	for locale, localeMessages := range messages {
+		if locale == "" {
+			return nil, fmt.Errorf("invalid catalog: messages without locale")
+		}
+		c.Add(locale, localeMessages)
+	}
+	return c, nil
+}
+
+// Add adds the messages of the locale, replacing existing messages with the same key
+func (c *Catalog) Add(locale string, messages map[string]string) {
+	if c.messages == nil {
+		c.messages = make(map[string]map[string]string)
+	}
+	localeMessages, ok := c.messages[locale]
+	if !ok {
+		localeMessages = make(map[string]string, len(messages))
+		c.messages[locale] = localeMessages
+	}
+	for key, text := range messages {
+		localeMessages[key] = text
+	}
+}
+
+// Locales returns the locales in the catalog in sorted order
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.messages))
+	for locale := range c.messages {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Text returns the text of the message key in the locale
+func (c *Catalog) Text(locale, key string) (string, bool) {
+	text, ok := c.messages[locale][key]
+	return text, ok
+}
+
+// FriendlyNames returns a text friendly name for each locale translating key, ordered by locale
+func (c *Catalog) FriendlyNames(key string) ([]FriendlyName, error) {
+	var names []FriendlyName
+	for _, locale := range c.Locales() {
+		if text, ok := c.Text(locale, key); ok {
+			names = append(names, FriendlyName{
+				Type:  FriendlyNameText,
+				Value: FriendlyNameValue{Text: text, Locale: locale},
+			})
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no translations for %q", key)
+	}
+	return names, nil
+}
+
+// Resources returns capability resources with the friendly names translating each key
+func (c *Catalog) Resources(keys ...string) (*CapabilityResources, error) {
+	resources := &CapabilityResources{}
+	for _, key := range keys {
+		names, err := c.FriendlyNames(key)
+		if err != nil {
+			return nil, err
+		}
+		resources.FriendlyNames = append(resources.FriendlyNames, names...)
+	}
+	return resources, nil
+}
+
+// Localize returns a copy of endpoint where each text friendly name without a locale is
+// treated as a message key and replaced with its translations. This includes the labels
+// of ModeController modes. Asset and localized text friendly names are kept as is.
+func (c *Catalog) Localize(endpoint DiscoverEndpoint) (DiscoverEndpoint, error) {
+	capabilities := make([]DiscoverCapability, len(endpoint.Capabilities))
+	for i, capability := range endpoint.Capabilities {
+		resources, err := c.localizeResources(capability.CapabilityResources)
+		if err != nil {
+			return DiscoverEndpoint{}, fmt.Errorf("failed to localize %s: %v", capability.Interface, err)
+		}
+		capability.CapabilityResources = resources
+
+		if capability.Interface == InterfaceModeController && len(capability.Configuration) > 0 {
+			config, err := c.localizeModes(capability.Configuration)
+			if err != nil {
+				return DiscoverEndpoint{}, fmt.Errorf("failed to localize %s modes: %v", capability.Instance, err)
+			}
+			capability.Configuration = config
+		}
+		capabilities[i] = capability
+	}
+	endpoint.Capabilities = capabilities
+	return endpoint, nil
+}
+
+// localizeModes localizes the mode resources of a ModeControllerConfiguration
+func (c *Catalog) localizeModes(configJSON json.RawMessage) (json.RawMessage, error) {
+	var config ModeControllerConfiguration
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	modes := make([]SupportedMode, len(config.SupportedModes))
+	for i, mode := range config.SupportedModes {
+		names, err := c.localizeNames(mode.ModeResources.FriendlyNames)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", mode.Value, err)
+		}
+		mode.ModeResources.FriendlyNames = names
+		modes[i] = mode
+	}
+	config.SupportedModes = modes
+
+	return json.Marshal(config)
+}
+
+func (c *Catalog) localizeResources(resources *CapabilityResources) (*CapabilityResources, error) {
+	if resources == nil {
+		return nil, nil
+	}
+	names, err := c.localizeNames(resources.FriendlyNames)
+	if err != nil {
+		return nil, err
+	}
+	return &CapabilityResources{FriendlyNames: names}, nil
+}
+
+func (c *Catalog) localizeNames(names []FriendlyName) ([]FriendlyName, error) {
+	localized := make([]FriendlyName, 0, len(names))
+	for _, name := range names {
+		if name.Type != FriendlyNameText || name.Value.Locale != "" {
+			localized = append(localized, name)
+			continue
+		}
+		translations, err := c.FriendlyNames(name.Value.Text)
+		if err != nil {
+			return nil, err
+		}
+		localized = append(localized, translations...)
+	}
+	return localized, nil
+}
@@ -0,0 +1,108 @@
+package alexa
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCatalogLocalize(t *testing.T) {
+	catalog, err := ParseCatalog([]byte(`{
+		"en-US": {"fan.speed": "Fan Speed"},
+		"de-DE": {"fan.speed": "Lüftergeschwindigkeit"}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to parse catalog: %v", err)
+	}
+
+	endpoint := DiscoverEndpoint{
+		EndpointID: "fan-1",
+		Capabilities: []DiscoverCapability{
+			{Interface: InterfacePowerController},
+			{
+				Interface: "Alexa.RangeController",
+				Instance:  "Fan.Speed",
+				CapabilityResources: &CapabilityResources{FriendlyNames: []FriendlyName{
+					{Type: FriendlyNameAsset, Value: FriendlyNameValue{AssetID: "Alexa.Setting.FanSpeed"}},
+					{Type: FriendlyNameText, Value: FriendlyNameValue{Text: "fan.speed"}},
+				}},
+			},
+		},
+	}
+
+	localized, err := catalog.Localize(endpoint)
+	if err != nil {
+		t.Fatalf("failed to localize: %v", err)
+	}
+
+	expected := []FriendlyName{
+		{Type: FriendlyNameAsset, Value: FriendlyNameValue{AssetID: "Alexa.Setting.FanSpeed"}},
+		{Type: FriendlyNameText, Value: FriendlyNameValue{Text: "Lüftergeschwindigkeit", Locale: "de-DE"}},
+		{Type: FriendlyNameText, Value: FriendlyNameValue{Text: "Fan Speed", Locale: "en-US"}},
+	}
+	if got := localized.Capabilities[1].CapabilityResources.FriendlyNames; !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+	if endpoint.Capabilities[1].CapabilityResources.FriendlyNames[1].Value.Locale != "" {
+		t.Error("expected original endpoint to be unchanged")
+	}
+
+	endpoint.Capabilities[1].CapabilityResources.FriendlyNames[1].Value.Text = "missing"
+	if _, err := catalog.Localize(endpoint); err == nil {
+		t.Error("expected error for missing translation")
+	}
+}
+
+func TestCatalogLocalizeModes(t *testing.T) {
+	catalog, err := ParseCatalog([]byte(`{
+		"en-US": {"wash.cycle": "Wash Cycle", "wash.delicates": "Delicates"},
+		"de-DE": {"wash.cycle": "Waschgang", "wash.delicates": "Feinwäsche"}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to parse catalog: %v", err)
+	}
+
+	capability, err := NewModeControllerCapability("Wash.Cycle",
+		CapabilityResources{FriendlyNames: []FriendlyName{{Type: FriendlyNameText, Value: FriendlyNameValue{Text: "wash.cycle"}}}},
+		ModeControllerConfiguration{SupportedModes: []SupportedMode{
+			{
+				Value: "Wash.Cycle.Delicates",
+				ModeResources: CapabilityResources{FriendlyNames: []FriendlyName{
+					{Type: FriendlyNameAsset, Value: FriendlyNameValue{AssetID: "Alexa.Value.Delicate"}},
+					{Type: FriendlyNameText, Value: FriendlyNameValue{Text: "wash.delicates"}},
+				}},
+			},
+		}},
+		nil)
+	if err != nil {
+		t.Fatalf("failed to create capability: %v", err)
+	}
+	endpoint := DiscoverEndpoint{EndpointID: "washer-1", Capabilities: []DiscoverCapability{capability}}
+
+	localized, err := catalog.Localize(endpoint)
+	if err != nil {
+		t.Fatalf("failed to localize: %v", err)
+	}
+
+	var config ModeControllerConfiguration
+	if err := json.Unmarshal(localized.Capabilities[0].Configuration, &config); err != nil {
+		t.Fatalf("failed to unmarshal configuration: %v", err)
+	}
+	expected := []FriendlyName{
+		{Type: FriendlyNameAsset, Value: FriendlyNameValue{AssetID: "Alexa.Value.Delicate"}},
+		{Type: FriendlyNameText, Value: FriendlyNameValue{Text: "Feinwäsche", Locale: "de-DE"}},
+		{Type: FriendlyNameText, Value: FriendlyNameValue{Text: "Delicates", Locale: "en-US"}},
+	}
+	if got := config.SupportedModes[0].ModeResources.FriendlyNames; !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+	if len(localized.Capabilities[0].CapabilityResources.FriendlyNames) != 2 {
+		t.Errorf("expected the instance name to be localized: %v", localized.Capabilities[0].CapabilityResources)
+	}
+
+	catalog = &Catalog{}
+	catalog.Add("en-US", map[string]string{"wash.cycle": "Wash Cycle"})
+	if _, err := catalog.Localize(endpoint); err == nil {
+		t.Error("expected error for missing mode translation")
+	}
+}
@@ -0,0 +1,63 @@
+package alexa
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// PropertyChannel is the Alexa.ChannelController property holding the Channel an endpoint is tuned to
+const PropertyChannel = "channel"
+
+// Channel identifies a channel by any of its number, call signs or uri. It's used by
+// ChangeChannel directives and as the value of the channel property.
+type Channel struct {
+	Number            string `json:"number,omitempty"`
+	CallSign          string `json:"callSign,omitempty"`
+	AffiliateCallSign string `json:"affiliateCallSign,omitempty"`
+	URI               string `json:"uri,omitempty"`
+}
+
+// ChannelMetadata describes the channel the user asked for when Alexa couldn't
+// identify it by number or call sign
+type ChannelMetadata struct {
+	Name  string `json:"name,omitempty"`
+	Image string `json:"image,omitempty"`
+}
+
+type ChangeChannelPayload struct {
+	Channel         Channel         `json:"channel"`
+	ChannelMetadata ChannelMetadata `json:"channelMetadata"`
+}
+
+// Validate checks that the channel is identified
+func (p ChangeChannelPayload) Validate() error {
+	if p.Channel == (Channel{}) && p.ChannelMetadata.Name == "" {
+		return errors.New("channel or channelMetadata name must be set")
+	}
+	return nil
+}
+
+// SkipChannelsPayload moves ChannelCount channels up, or down if negative
+type SkipChannelsPayload struct {
+	ChannelCount int `json:"channelCount"`
+}
+
+// ChannelControllerHandler routes handling of change & skip channel directives
+func ChannelControllerHandler(changeChannel, skipChannels Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "ChangeChannel":
+			return changeChannel.HandleRequest(ctx, req)
+		case "SkipChannels":
+			return skipChannels.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("ChannelControllerHandler", req)
+		}
+	}
+}
+
+// ChannelProperty creates a channel property
+func ChannelProperty(channel Channel, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceChannelController, PropertyChannel, channel, timeOfSample)
+}
@@ -0,0 +1,55 @@
+package alexa
+
+import (
+	"context"
+	"fmt"
+)
+
+// PropertyColor is the Alexa.ColorController property holding the ColorValue of an endpoint
+const PropertyColor = "color"
+
+// ColorValue is a color in hue, saturation and brightness. It's used by SetColor
+// directives and as the value of the color property.
+type ColorValue struct {
+	// Hue in degrees from 0 to 360
+	Hue float64 `json:"hue"`
+	// Saturation from 0 to 1
+	Saturation float64 `json:"saturation"`
+	// Brightness from 0 to 1
+	Brightness float64 `json:"brightness"`
+}
+
+// Validate checks that each component of the color is within its range
+func (c ColorValue) Validate() error {
+	if err := validateRange("hue", c.Hue, 0, 360); err != nil {
+		return err
+	}
+	if err := validateRange("saturation", c.Saturation, 0, 1); err != nil {
+		return err
+	}
+	return validateRange("brightness", c.Brightness, 0, 1)
+}
+
+type SetColorPayload struct {
+	Color ColorValue `json:"color"`
+}
+
+// Validate checks the requested color
+func (p SetColorPayload) Validate() error {
+	if err := p.Color.Validate(); err != nil {
+		return fmt.Errorf("invalid color: %w", err)
+	}
+	return nil
+}
+
+// ColorControllerHandler routes handling of set color directives
+func ColorControllerHandler(setColor Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "SetColor":
+			return setColor.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("ColorControllerHandler", req)
+		}
+	}
+}
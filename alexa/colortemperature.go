@@ -0,0 +1,77 @@
+package alexa
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// PropertyColorTemperatureInKelvin is the Alexa.ColorTemperatureController property
+// holding the color temperature of an endpoint
+const PropertyColorTemperatureInKelvin = "colorTemperatureInKelvin"
+
+// Color temperatures Alexa uses for named white shades
+const (
+	ColorTemperatureWarmWhite     = 2200
+	ColorTemperatureSoftWhite     = 2700
+	ColorTemperatureWhite         = 4000
+	ColorTemperatureDaylightWhite = 5500
+	ColorTemperatureCoolWhite     = 7000
+)
+
+var colorTemperatureSteps = []int{
+	ColorTemperatureWarmWhite,
+	ColorTemperatureSoftWhite,
+	ColorTemperatureWhite,
+	ColorTemperatureDaylightWhite,
+	ColorTemperatureCoolWhite,
+}
+
+type SetColorTemperaturePayload struct {
+	ColorTemperatureInKelvin int `json:"colorTemperatureInKelvin"`
+}
+
+// Validate checks that the color temperature is within 1000 to 10000 kelvin
+func (p SetColorTemperaturePayload) Validate() error {
+	return validateRange("colorTemperatureInKelvin", float64(p.ColorTemperatureInKelvin), 1000, 10000)
+}
+
+// ColorTemperatureControllerHandler routes handling of set, increase & decrease color
+// temperature directives. Increase and decrease have no payload, see ColorTemperatureStep.
+func ColorTemperatureControllerHandler(setColorTemp, increaseColorTemp, decreaseColorTemp Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "SetColorTemperature":
+			return setColorTemp.HandleRequest(ctx, req)
+		case "IncreaseColorTemperature":
+			return increaseColorTemp.HandleRequest(ctx, req)
+		case "DecreaseColorTemperature":
+			return decreaseColorTemp.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("ColorTemperatureControllerHandler", req)
+		}
+	}
+}
+
+// ColorTemperatureStep returns the next named white shade cooler than kelvin when
+// increasing or warmer when decreasing. kelvin is returned unchanged if there is no
+// cooler or warmer shade.
+func ColorTemperatureStep(kelvin int, increase bool) int {
+	if increase {
+		i := sort.SearchInts(colorTemperatureSteps, kelvin+1)
+		if i == len(colorTemperatureSteps) {
+			return kelvin
+		}
+		return colorTemperatureSteps[i]
+	}
+	i := sort.SearchInts(colorTemperatureSteps, kelvin)
+	if i == 0 {
+		return kelvin
+	}
+	return colorTemperatureSteps[i-1]
+}
+
+// ColorTemperatureProperty creates a colorTemperatureInKelvin property
+func ColorTemperatureProperty(kelvin int, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceColorTemperatureController, PropertyColorTemperatureInKelvin, kelvin, timeOfSample)
+}
@@ -0,0 +1,74 @@
+package alexa
+
+import "encoding/json"
+
+// AdditionalAttributes describe the physical device of an endpoint
+type AdditionalAttributes struct {
+	Manufacturer     string `json:"manufacturer,omitempty"`
+	Model            string `json:"model,omitempty"`
+	SerialNumber     string `json:"serialNumber,omitempty"`
+	FirmwareVersion  string `json:"firmwareVersion,omitempty"`
+	SoftwareVersion  string `json:"softwareVersion,omitempty"`
+	CustomIdentifier string `json:"customIdentifier,omitempty"`
+}
+
+// Connection type enums
+const (
+	ConnectionTypeMatter  = "MATTER"
+	ConnectionTypeTCPIP   = "TCP_IP"
+	ConnectionTypeZigbee  = "ZIGBEE"
+	ConnectionTypeZWave   = "ZWAVE"
+	ConnectionTypeUnknown = "UNKNOWN"
+)
+
+// Connection describes how the device of an endpoint connects. The fields used depend
+// on the Type: MacAddress for TCP_IP and ZIGBEE, HomeID and NodeID for ZWAVE, the Matter
+// fields for MATTER and Value for UNKNOWN.
+type Connection struct {
+	Type       string `json:"type"`
+	MacAddress string `json:"macAddress,omitempty"`
+	HomeID     string `json:"homeId,omitempty"`
+	NodeID     string `json:"nodeId,omitempty"`
+	Value      string `json:"value,omitempty"`
+
+	MatterDiscriminator string `json:"matterDiscriminator,omitempty"`
+	MatterVendorID      string `json:"matterVendorId,omitempty"`
+	MatterProductID     string `json:"matterProductId,omitempty"`
+}
+
+// NewMatterConnection creates the connection of a Matter device identified by its
+// discriminator, vendor id and product id
+func NewMatterConnection(discriminator, vendorID, productID string) Connection {
+	return Connection{
+		Type:                ConnectionTypeMatter,
+		MatterDiscriminator: discriminator,
+		MatterVendorID:      vendorID,
+		MatterProductID:     productID,
+	}
+}
+
+// Commissioning protocol enums
+const (
+	CommissioningProtocolMatter = "MATTER"
+)
+
+// CommissionableConfiguration is the discovery configuration of Alexa.Commissionable
+type CommissionableConfiguration struct {
+	// SupportedProtocols lists the CommissioningProtocol enums the device can be commissioned with
+	SupportedProtocols []string `json:"supportedProtocols"`
+}
+
+// NewCommissionableCapability creates the discovery capability of an endpoint that can be
+// commissioned with the protocols, e.g. a Matter device bridged by the skill
+func NewCommissionableCapability(protocols ...string) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(CommissionableConfiguration{SupportedProtocols: protocols})
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:          "AlexaInterface",
+		Interface:     InterfaceCommissionable,
+		Version:       "1.0",
+		Configuration: configJSON,
+	}, nil
+}
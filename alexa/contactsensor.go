@@ -0,0 +1,44 @@
+package alexa
+
+import "time"
+
+// PropertyDetectionState is the property of Alexa.ContactSensor and Alexa.MotionSensor
+// holding a DetectionState
+const PropertyDetectionState = "detectionState"
+
+// DetectionState enums. A contact sensor is DETECTED when open, i.e. the contact is broken.
+const (
+	DetectionStateDetected    = "DETECTED"
+	DetectionStateNotDetected = "NOT_DETECTED"
+)
+
+// NewContactSensorCapability creates the discovery capability of a door or window sensor
+func NewContactSensorCapability() DiscoverCapability {
+	return detectionSensorCapability(InterfaceContactSensor)
+}
+
+// ContactSensorProperty creates a detectionState property that's DETECTED if open
+func ContactSensorProperty(open bool, timeOfSample time.Time) (ContextProperty, error) {
+	return detectionStateProperty(NamespaceContactSensor, open, timeOfSample)
+}
+
+func detectionSensorCapability(iface string) DiscoverCapability {
+	return DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: iface,
+		Version:   "3",
+		Properties: &DiscoverProperties{
+			Supported:           []DiscoverProperty{{Name: PropertyDetectionState}},
+			ProactivelyReported: true,
+			Retrievable:         true,
+		},
+	}
+}
+
+func detectionStateProperty(namespace string, detected bool, timeOfSample time.Time) (ContextProperty, error) {
+	state := DetectionStateNotDetected
+	if detected {
+		state = DetectionStateDetected
+	}
+	return NewProperty(namespace, PropertyDetectionState, state, timeOfSample)
+}
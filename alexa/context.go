@@ -0,0 +1,50 @@
+package alexa
+
+import (
+	"context"
+	"time"
+)
+
+type contextKey int
+
+const (
+	userIDContextKey contextKey = iota
+	partitionContextKey
+)
+
+// WithUserID returns a copy of ctx carrying the resolved user id of the request
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDContextKey, userID)
+}
+
+// UserIDFromContext returns the user id placed in ctx by WithUserID
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDContextKey).(string)
+	return userID, ok
+}
+
+// WithPartition returns a copy of ctx carrying the partition the request is scoped to
+func WithPartition(ctx context.Context, partition PartitionScope) context.Context {
+	return context.WithValue(ctx, partitionContextKey, partition)
+}
+
+// PartitionFromContext returns the partition placed in ctx by WithPartition
+func PartitionFromContext(ctx context.Context) (PartitionScope, bool) {
+	partition, ok := ctx.Value(partitionContextKey).(PartitionScope)
+	return partition, ok
+}
+
+// Detach returns a context with the values of ctx but without its deadline or
+// cancellation, e.g. for work that must finish after the request returns. It matches
+// context.WithoutCancel, which needs a newer go than this module requires.
+func Detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
@@ -0,0 +1,89 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// ClientCredentials are the skill's Login with Amazon client id and secret used to
+// exchange and refresh user tokens
+type ClientCredentials struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+}
+
+// Credentials returns c so ClientCredentials can be used as a static CredentialsProvider
+func (c ClientCredentials) Credentials(ctx context.Context) (ClientCredentials, error) {
+	return c, nil
+}
+
+// CredentialsProvider supplies client credentials. Providers are consulted each time
+// credentials are needed so a rotated secret is picked up without a restart.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (ClientCredentials, error)
+}
+
+// EnvCredentials reads client credentials from environment variables
+type EnvCredentials struct {
+	ClientIDVar     string
+	ClientSecretVar string
+}
+
+// Credentials reads the configured environment variables
+func (e *EnvCredentials) Credentials(ctx context.Context) (ClientCredentials, error) {
+	creds := ClientCredentials{
+		ClientID:     os.Getenv(e.ClientIDVar),
+		ClientSecret: os.Getenv(e.ClientSecretVar),
+	}
+	if creds.ClientID == "" || creds.ClientSecret == "" {
+		return ClientCredentials{}, fmt.Errorf("%s and %s must be set", e.ClientIDVar, e.ClientSecretVar)
+	}
+	return creds, nil
+}
+
+// FileCredentials reads client credentials from a json file containing clientId and
+// clientSecret fields. The file is re-read when its modification time changes.
+type FileCredentials struct {
+	Path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	creds   ClientCredentials
+}
+
+// Credentials returns the credentials in the file
+func (f *FileCredentials) Credentials(ctx context.Context) (ClientCredentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return ClientCredentials{}, fmt.Errorf("failed to stat credentials file: %v", err)
+	}
+	if info.ModTime().Equal(f.modTime) {
+		return f.creds, nil
+	}
+
+	content, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return ClientCredentials{}, fmt.Errorf("failed to read credentials file: %v", err)
+	}
+
+	var creds ClientCredentials
+	if err := json.Unmarshal(content, &creds); err != nil {
+		return ClientCredentials{}, fmt.Errorf("failed to unmarshal credentials file: %v", err)
+	}
+	if creds.ClientID == "" || creds.ClientSecret == "" {
+		return ClientCredentials{}, errors.New("credentials file missing clientId or clientSecret")
+	}
+
+	f.creds = creds
+	f.modTime = info.ModTime()
+	return creds, nil
+}
@@ -0,0 +1,125 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// DataController access enums
+const (
+	DataAccessByIdentifier     = "BY_IDENTIFIER"
+	DataAccessByTimestampRange = "BY_TIMESTAMP_RANGE"
+)
+
+// DataController event name enums
+const (
+	EventDataReport         = "DataReport"
+	EventDeleteDataResponse = "DeleteDataResponse"
+)
+
+// DataControllerConfiguration is the discovery configuration of an Alexa.DataController
+// instance. It describes the data an endpoint collects for another of its capabilities
+// so users can review and delete it.
+type DataControllerConfiguration struct {
+	// TargetCapability is the interface the data is collected by, e.g. Alexa.RTCSessionController
+	TargetCapability    string              `json:"targetCapability"`
+	DataRetrievalSchema DataRetrievalSchema `json:"dataRetrievalSchema"`
+	// SupportedAccess lists the DataAccess enums the endpoint supports
+	SupportedAccess []string `json:"supportedAccess"`
+}
+
+type DataRetrievalSchema struct {
+	Type   string `json:"type"`
+	Schema string `json:"schema"`
+}
+
+// NewDataControllerCapability creates the discovery capability of a DataController instance
+func NewDataControllerCapability(instance string, config DataControllerConfiguration) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:          "AlexaInterface",
+		Interface:     InterfaceDataController,
+		Instance:      instance,
+		Version:       "1.0",
+		Configuration: configJSON,
+	}, nil
+}
+
+// TimeWindow is an inclusive range of time
+type TimeWindow struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type DataPaginationContext struct {
+	Token           string `json:"token,omitempty"`
+	MaximumPageSize int    `json:"maximumPageSize,omitempty"`
+}
+
+// ListDataPayload is the payload of a ListData directive. TimeWindow is set when listing
+// by timestamp range.
+type ListDataPayload struct {
+	TimeWindow        *TimeWindow            `json:"timeWindow,omitempty"`
+	PaginationContext *DataPaginationContext `json:"paginationContext,omitempty"`
+}
+
+// DataReportPayload answers a ListData directive. Each record is encoded as described by
+// the instance's DataRetrievalSchema. PaginationContext holds the token of the next page.
+type DataReportPayload struct {
+	DataRecords       []json.RawMessage      `json:"dataRecords"`
+	PaginationContext *DataPaginationContext `json:"paginationContext,omitempty"`
+}
+
+// DeleteDataPayload is the payload of a DeleteData directive. Records are identified by
+// DataIDs or TimeWindow depending on the access supported by the instance.
+type DeleteDataPayload struct {
+	DataIDs    []string    `json:"dataIds,omitempty"`
+	TimeWindow *TimeWindow `json:"timeWindow,omitempty"`
+}
+
+// DeleteDataResponsePayload answers a DeleteData directive
+type DeleteDataResponsePayload struct {
+	SuccessCount int `json:"successCount"`
+	FailureCount int `json:"failureCount"`
+}
+
+// DataControllerHandler routes list & delete data requests
+func DataControllerHandler(listData, deleteData Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "ListData":
+			return listData.HandleRequest(ctx, req)
+		case "DeleteData":
+			return deleteData.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("DataControllerHandler", req)
+		}
+	}
+}
+
+// DataReportResponse answers a ListData directive
+func (r *ResponseBuilder) DataReportResponse(req *Request, payload DataReportPayload) (*Response, error) {
+	if payload.DataRecords == nil {
+		payload.DataRecords = []json.RawMessage{}
+	}
+	return r.instanceResponse(req, NamespaceDataController, EventDataReport, payload)
+}
+
+// DeleteDataResponse answers a DeleteData directive
+func (r *ResponseBuilder) DeleteDataResponse(req *Request, payload DeleteDataResponsePayload) (*Response, error) {
+	return r.instanceResponse(req, NamespaceDataController, EventDeleteDataResponse, payload)
+}
+
+// instanceResponse builds a TypedResponse addressed to the capability instance of req
+func (r *ResponseBuilder) instanceResponse(req *Request, namespace, name string, payload interface{}) (*Response, error) {
+	resp, err := TypedResponse(r, req, namespace, name, payload)
+	if err != nil {
+		return nil, err
+	}
+	resp.Event.Header.Instance = req.Directive.Header.Instance
+	return resp, nil
+}
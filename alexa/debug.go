@@ -2,10 +2,11 @@ package alexa
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 
 	"github.com/mctofu/alexa-smart-home/schema"
 	"github.com/xeipuuv/gojsonschema"
@@ -21,7 +22,7 @@ func DebugHandler(handler Handler) Handler {
 // RequestDebugHandler wraps handler and logs the contents of the request for debugging.
 func RequestDebugHandler(handler Handler) HandlerFunc {
 	return func(ctx context.Context, req *Request) (*Response, error) {
-		reqJSON, err := json.Marshal(req)
+		reqJSON, err := req.JSON()
 		if err != nil {
 			log.Printf("RequestDebugHandler: Failed to marshal request: %v", err)
 		} else {
@@ -43,38 +44,90 @@ func ResponseDebugHandler(handler Handler) HandlerFunc {
 			return resp, err
 		}
 
-		respJSON, jsonErr := json.Marshal(resp)
-		if jsonErr != nil {
-			log.Printf("Failed to marshal debug response: %v\n", jsonErr)
-		}
-		log.Printf("Debug response:\n%s\n", respJSON)
-
-		if schemaErr := validateSchema(string(respJSON)); schemaErr != nil {
-			log.Printf("Failed to validate schema: %v\n", schemaErr)
-		} else {
-			log.Printf("Schema validated!\n")
-		}
+		DebugResponse(resp)
 
 		return resp, err
 	}
 }
 
-func validateSchema(resp string) error {
-	schemaLoader := gojsonschema.NewStringLoader(schema.AlexaSmartHome)
-	result, err := gojsonschema.Validate(schemaLoader, gojsonschema.NewStringLoader(resp))
+// DebugResponse marshals resp once, logs it and validates it against the smart home
+// schema. The marshaled json is returned so callers can send it without encoding the
+// response again. It is nil if the response couldn't be marshaled.
+func DebugResponse(resp *Response) []byte {
+	respJSON, err := MarshalResponse(resp)
+	if err != nil {
+		log.Printf("Failed to marshal debug response: %v\n", err)
+		return nil
+	}
+	log.Printf("Debug response:\n%s\n", respJSON)
+
+	if schemaErr := validateSchema(respJSON); schemaErr != nil {
+		log.Printf("Failed to validate schema: %v\n", schemaErr)
+	} else {
+		log.Printf("Schema validated!\n")
+	}
+
+	return respJSON
+}
+
+func validateSchema(respJSON []byte) error {
+	err := ValidateResponse(respJSON)
+	var schemaErr *SchemaError
+	if errors.As(err, &schemaErr) {
+		log.Printf("Response is not valid:\n")
+		for _, problem := range schemaErr.Problems {
+			log.Printf("- %s\n", problem)
+		}
+		return errors.New("Response is not valid")
+	}
+	return err
+}
+
+// SchemaError lists the ways a response fails to conform to the smart home schema
+type SchemaError struct {
+	Problems []string
+}
+
+func (s *SchemaError) Error() string {
+	return "- " + strings.Join(s.Problems, "\n- ")
+}
+
+// ValidateResponse validates the json of a response or event against the smart home schema.
+// A *SchemaError is returned if the response is not valid.
+func ValidateResponse(respJSON []byte) error {
+	compiled, err := compiledSchema()
+	if err != nil {
+		return fmt.Errorf("Failed to load schema: %v", err)
+	}
+	result, err := compiled.Validate(gojsonschema.NewBytesLoader(respJSON))
 	if err != nil {
 		return fmt.Errorf("Failed to validate schema: %v", err)
 	}
 	if !result.Valid() {
-		log.Printf("Response is not valid:\n")
+		schemaErr := &SchemaError{}
 		for _, desc := range result.Errors() {
-			log.Printf("- %s\n", desc)
+			schemaErr.Problems = append(schemaErr.Problems, desc.String())
 		}
-		return errors.New("Response is not valid")
+		return schemaErr
 	}
 	return nil
 }
 
+var (
+	schemaOnce     sync.Once
+	schemaCompiled *gojsonschema.Schema
+	schemaErr      error
+)
+
+// compiledSchema parses the smart home schema on first use. Parsing dominates the cost
+// of validation so it's only done once.
+func compiledSchema() (*gojsonschema.Schema, error) {
+	schemaOnce.Do(func() {
+		schemaCompiled, schemaErr = gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema.AlexaSmartHome))
+	})
+	return schemaCompiled, schemaErr
+}
+
 // DebugTokenStore logs reads/writes to tokens
 type DebugTokenStore struct {
 	TokenStore TokenReaderWriter
@@ -89,3 +142,12 @@ func (d *DebugTokenStore) Read(ctx context.Context, id string) (*oauth2.Token, e
 	log.Printf("Reading token for %s\n", id)
 	return d.TokenStore.Read(ctx, id)
 }
+
+func (d *DebugTokenStore) Delete(ctx context.Context, id string) error {
+	log.Printf("Deleting token for %s\n", id)
+	deleter, ok := d.TokenStore.(TokenDeleter)
+	if !ok {
+		return fmt.Errorf("token store %T does not support delete", d.TokenStore)
+	}
+	return deleter.Delete(ctx, id)
+}
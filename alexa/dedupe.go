@@ -0,0 +1,127 @@
+package alexa
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MessageIDStore records the message ids of directives that have been handled
+type MessageIDStore interface {
+	// MarkSeen records messageID and reports whether it was already recorded.
+	MarkSeen(ctx context.Context, messageID string) (bool, error)
+	// Release removes messageID so the directive can be handled again.
+	Release(ctx context.Context, messageID string) error
+}
+
+// DefaultMessageIDTTL is how long message ids are remembered when a store's TTL isn't
+// set. It comfortably covers Alexa retries and queue redelivery.
+const DefaultMessageIDTTL = time.Hour
+
+// MemoryMessageIDStore is a MessageIDStore that remembers message ids in memory for TTL.
+// It's only suitable when a single process handles all directives.
+type MemoryMessageIDStore struct {
+	// TTL defaults to DefaultMessageIDTTL
+	TTL time.Duration
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// expiry holds message ids in the order they were marked. With a fixed ttl this is
+	// also the order they expire in so expired ids are dropped from the front.
+	expiry []seenMessageID
+}
+
+type seenMessageID struct {
+	messageID string
+	expires   time.Time
+}
+
+// MarkSeen records messageID and reports whether it was seen within TTL.
+func (m *MemoryMessageIDStore) MarkSeen(ctx context.Context, messageID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.expire(now)
+	if m.seen == nil {
+		m.seen = make(map[string]time.Time)
+	}
+
+	if _, ok := m.seen[messageID]; ok {
+		return true, nil
+	}
+	expires := now.Add(m.ttl())
+	m.seen[messageID] = expires
+	m.expiry = append(m.expiry, seenMessageID{messageID, expires})
+	return false, nil
+}
+
+// Release forgets messageID
+func (m *MemoryMessageIDStore) Release(ctx context.Context, messageID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.seen, messageID)
+	return nil
+}
+
+// expire forgets the message ids that expired before now. Only expired ids are visited.
+func (m *MemoryMessageIDStore) expire(now time.Time) {
+	n := 0
+	for ; n < len(m.expiry) && !now.Before(m.expiry[n].expires); n++ {
+		expired := m.expiry[n]
+		// the id may have been released and marked again since
+		if expires, ok := m.seen[expired.messageID]; ok && expires.Equal(expired.expires) {
+			delete(m.seen, expired.messageID)
+		}
+	}
+	if n > 0 {
+		m.expiry = append(m.expiry[:0], m.expiry[n:]...)
+	}
+}
+
+func (m *MemoryMessageIDStore) ttl() time.Duration {
+	if m.TTL <= 0 {
+		return DefaultMessageIDTTL
+	}
+	return m.TTL
+}
+
+func (m *MemoryMessageIDStore) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+// DedupeHandler wraps handler so directives with a previously seen message id are not
+// handled twice. This protects non-idempotent device actions from SQS redelivery and
+// Alexa retries. If duplicate is nil a duplicate directive returns a nil response,
+// otherwise it is passed to duplicate. A message id is released if handler fails so
+// the directive can be retried.
+func DedupeHandler(store MessageIDStore, handler, duplicate Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		messageID := req.Directive.Header.MessageID
+		seen, err := store.MarkSeen(ctx, messageID)
+		if err != nil {
+			return nil, fmt.Errorf("DedupeHandler: failed to record message id: %v", err)
+		}
+		if seen {
+			if duplicate == nil {
+				return nil, nil
+			}
+			return duplicate.HandleRequest(ctx, req)
+		}
+
+		resp, err := handler.HandleRequest(ctx, req)
+		if err != nil {
+			if releaseErr := store.Release(ctx, messageID); releaseErr != nil {
+				return resp, fmt.Errorf("%v (failed to release message id: %v)", err, releaseErr)
+			}
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,48 @@
+package alexa
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Measurement enums
+const (
+	EventMeasurementsReport = "MeasurementsReport"
+
+	MeasurementTypeElectricEnergy = "ELECTRIC_ENERGY"
+	MeasurementUnitKilowattHours  = "KILOWATT_HOURS"
+)
+
+// Measurement is the amount of a resource an endpoint used between StartTime and EndTime
+type Measurement struct {
+	Type      string    `json:"type"`
+	Unit      string    `json:"unit"`
+	Value     float64   `json:"value"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+}
+
+type MeasurementsReportPayload struct {
+	Measurements []Measurement `json:"measurements"`
+}
+
+// MeasurementsReport creates a proactive event reporting the usage of an endpoint in
+// namespace, e.g. Alexa.DeviceUsage.Meter. scope must identify the user, see
+// deferred.HTTPEventSender.
+func (r *ResponseBuilder) MeasurementsReport(scope Scope, namespace, endpointID string,
+	measurements ...Measurement) (*Response, error) {
+	if len(measurements) == 0 {
+		return nil, errors.New("measurements report requires a measurement")
+	}
+	for i, m := range measurements {
+		if m.EndTime.Before(m.StartTime) {
+			return nil, fmt.Errorf("measurement %d ends before it starts", i)
+		}
+		measurements[i].StartTime = m.StartTime.UTC()
+		measurements[i].EndTime = m.EndTime.UTC()
+	}
+
+	return ProactiveEvent(r, scope, namespace, EventMeasurementsReport, endpointID,
+		MeasurementsReportPayload{Measurements: measurements})
+}
@@ -0,0 +1,35 @@
+package alexa
+
+import "time"
+
+// EventDoorbellPress is the proactive Alexa.DoorbellEventSource event
+const EventDoorbellPress = "DoorbellPress"
+
+// DoorbellPressPayload is the payload of a DoorbellPress event
+type DoorbellPressPayload struct {
+	Cause     Cause     `json:"cause"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewDoorbellEventSourceCapability creates the discovery capability of a doorbell. The
+// interface has no directives, presses are only sent as DoorbellPress events.
+func NewDoorbellEventSourceCapability() DiscoverCapability {
+	proactivelyReported := true
+	return DiscoverCapability{
+		Type:                "AlexaInterface",
+		Interface:           InterfaceDoorbellEventSource,
+		Version:             "3",
+		ProactivelyReported: &proactivelyReported,
+	}
+}
+
+// DoorbellPressEvent creates a proactive event announcing that the doorbell was pressed
+// at timestamp, usually due to CausePhysicalInteraction. scope must identify the user,
+// see deferred.HTTPEventSender.
+func (r *ResponseBuilder) DoorbellPressEvent(scope Scope, endpointID, cause string, timestamp time.Time) (*Response, error) {
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return ProactiveEvent(r, scope, NamespaceDoorbellEventSource, EventDoorbellPress, endpointID,
+		DoorbellPressPayload{Cause: Cause{Type: cause}, Timestamp: timestamp.UTC()})
+}
@@ -0,0 +1,271 @@
+package alexa
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MarshalResponse encodes resp to json in a single pass. The output is identical to
+// json.Marshal but avoids reflection and reuses buffers between calls which matters
+// when sending a high volume of events.
+func MarshalResponse(resp *Response) ([]byte, error) {
+	e := newEncoder()
+	defer e.release()
+
+	if err := e.response(resp); err != nil {
+		return nil, err
+	}
+
+	return e.bytes(), nil
+}
+
+// EncodeResponse writes the json encoding of resp to w. See MarshalResponse.
+func EncodeResponse(w io.Writer, resp *Response) error {
+	e := newEncoder()
+	defer e.release()
+
+	if err := e.response(resp); err != nil {
+		return err
+	}
+
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
+
+// streamFlushSize is the amount of json buffered before it's written out when streaming
+const streamFlushSize = 32 << 10
+
+var encoderPool = sync.Pool{
+	New: func() interface{} { return &encoder{} },
+}
+
+// encoder writes the json for responses into a reusable buffer
+type encoder struct {
+	buf     bytes.Buffer
+	scratch [64]byte
+	keys    []string
+}
+
+func newEncoder() *encoder {
+	return encoderPool.Get().(*encoder)
+}
+
+func (e *encoder) release() {
+	// don't hold on to unusually large buffers
+	if e.buf.Cap() > 64<<10 {
+		return
+	}
+	e.buf.Reset()
+	e.keys = e.keys[:0]
+	encoderPool.Put(e)
+}
+
+// bytes returns a copy of the encoded json that's safe to use after release
+func (e *encoder) bytes() []byte {
+	return append([]byte(nil), e.buf.Bytes()...)
+}
+
+func (e *encoder) response(resp *Response) error {
+	e.buf.WriteByte('{')
+	if resp.Context != nil {
+		e.buf.WriteString(`"context":{`)
+		if len(resp.Context.Properties) > 0 {
+			e.buf.WriteString(`"properties":`)
+			if err := e.properties(resp.Context.Properties); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteString(`},`)
+	}
+
+	e.buf.WriteString(`"event":{"header":`)
+	e.header(&resp.Event.Header)
+	if resp.Event.Endpoint != nil {
+		e.buf.WriteString(`,"endpoint":`)
+		e.endpoint(resp.Event.Endpoint)
+	}
+	e.buf.WriteString(`,"payload":`)
+	if err := e.raw(resp.Event.Payload); err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+	e.buf.WriteString(`}}`)
+
+	return nil
+}
+
+func (e *encoder) header(header *Header) {
+	e.buf.WriteString(`{"namespace":`)
+	e.string(header.Namespace)
+	e.buf.WriteString(`,"name":`)
+	e.string(header.Name)
+	if header.Instance != "" {
+		e.buf.WriteString(`,"instance":`)
+		e.string(header.Instance)
+	}
+	e.buf.WriteString(`,"messageId":`)
+	e.string(header.MessageID)
+	if header.CorrelationToken != "" {
+		e.buf.WriteString(`,"correlationToken":`)
+		e.string(header.CorrelationToken)
+	}
+	e.buf.WriteString(`,"payloadVersion":`)
+	e.string(header.PayloadVersion)
+	e.buf.WriteByte('}')
+}
+
+func (e *encoder) endpoint(endpoint *ResponseEndpoint) {
+	e.buf.WriteByte('{')
+	if endpoint.EndpointID != "" {
+		e.buf.WriteString(`"endpointId":`)
+		e.string(endpoint.EndpointID)
+		e.buf.WriteByte(',')
+	}
+	if len(endpoint.Cookie) > 0 {
+		e.buf.WriteString(`"cookie":`)
+		e.cookie(endpoint.Cookie)
+		e.buf.WriteByte(',')
+	}
+	e.buf.WriteString(`"scope":{"type":`)
+	e.string(endpoint.Scope.Type)
+	e.buf.WriteString(`,"token":`)
+	e.string(endpoint.Scope.Token)
+	if endpoint.Scope.Partition != "" {
+		e.buf.WriteString(`,"partition":`)
+		e.string(endpoint.Scope.Partition)
+	}
+	if endpoint.Scope.UserID != "" {
+		e.buf.WriteString(`,"userId":`)
+		e.string(endpoint.Scope.UserID)
+	}
+	e.buf.WriteString(`}}`)
+}
+
+// cookie writes the map with sorted keys to match encoding/json
+func (e *encoder) cookie(cookie map[string]string) {
+	keys := e.keys[:0]
+	for k := range cookie {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	e.keys = keys
+
+	e.buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.string(k)
+		e.buf.WriteByte(':')
+		e.string(cookie[k])
+	}
+	e.buf.WriteByte('}')
+}
+
+// changeReportPayload writes the payload of a ChangeReport
+func (e *encoder) changeReportPayload(cause string, changed []ContextProperty) error {
+	e.buf.WriteString(`{"change":{"cause":{"type":`)
+	e.string(cause)
+	e.buf.WriteString(`},"properties":`)
+	if err := e.properties(changed); err != nil {
+		return err
+	}
+	e.buf.WriteString(`}}`)
+	return nil
+}
+
+func (e *encoder) properties(properties []ContextProperty) error {
+	if properties == nil {
+		e.buf.WriteString("null")
+		return nil
+	}
+
+	e.buf.WriteByte('[')
+	for i := range properties {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		if err := e.property(&properties[i]); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte(']')
+
+	return nil
+}
+
+func (e *encoder) property(property *ContextProperty) error {
+	e.buf.WriteString(`{"namespace":`)
+	e.string(property.Namespace)
+	if property.Instance != "" {
+		e.buf.WriteString(`,"instance":`)
+		e.string(property.Instance)
+	}
+	e.buf.WriteString(`,"name":`)
+	e.string(property.Name)
+	e.buf.WriteString(`,"value":`)
+	if err := e.raw(property.Value); err != nil {
+		return fmt.Errorf("failed to encode %s.%s value: %v", property.Namespace, property.Name, err)
+	}
+	e.buf.WriteString(`,"timeOfSample":`)
+	if err := e.time(property.TimeOfSample); err != nil {
+		return err
+	}
+	e.buf.WriteString(`,"uncertaintyInMilliseconds":`)
+	e.buf.Write(strconv.AppendInt(e.scratch[:0], int64(property.UncertaintyInMilliseconds), 10))
+	e.buf.WriteByte('}')
+
+	return nil
+}
+
+// raw writes already encoded json, compacting and escaping it as encoding/json does
+func (e *encoder) raw(data json.RawMessage) error {
+	if data == nil {
+		e.buf.WriteString("null")
+		return nil
+	}
+
+	start := e.buf.Len()
+	if err := json.Compact(&e.buf, data); err != nil {
+		return err
+	}
+	if compacted := e.buf.Bytes()[start:]; bytes.ContainsAny(compacted, "<>&\u2028\u2029") {
+		escaped := append([]byte(nil), compacted...)
+		e.buf.Truncate(start)
+		json.HTMLEscape(&e.buf, escaped)
+	}
+
+	return nil
+}
+
+func (e *encoder) time(t time.Time) error {
+	if y := t.Year(); y < 0 || y >= 10000 {
+		return errors.New("timeOfSample year outside of range [0,9999]")
+	}
+	b := append(e.scratch[:0], '"')
+	b = t.AppendFormat(b, time.RFC3339Nano)
+	b = append(b, '"')
+	e.buf.Write(b)
+	return nil
+}
+
+// string writes s as a json string. Strings that need escaping are rare in
+// responses so they're handed off to encoding/json to guarantee identical output.
+func (e *encoder) string(s string) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			quoted, _ := json.Marshal(s)
+			e.buf.Write(quoted)
+			return
+		}
+	}
+	e.buf.WriteByte('"')
+	e.buf.WriteString(s)
+	e.buf.WriteByte('"')
+}
@@ -0,0 +1,269 @@
+package alexa
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testProperties() []ContextProperty {
+	sampled := time.Date(2021, 2, 3, 4, 5, 6, 789000000, time.UTC)
+	return []ContextProperty{
+		{
+			Namespace:                 NamespaceTemperatureSensor,
+			Name:                      "temperature",
+			Value:                     json.RawMessage(`{ "value": 72.5, "scale": "FAHRENHEIT" }`),
+			TimeOfSample:              sampled,
+			UncertaintyInMilliseconds: 500,
+		},
+		{
+			Namespace:    NamespacePowerController,
+			Instance:     "Fan.Speed",
+			Name:         "powerState",
+			Value:        json.RawMessage(`"ON"`),
+			TimeOfSample: sampled.In(time.FixedZone("PST", -8*60*60)),
+		},
+		{
+			Namespace:    NamespaceEndpointHealth,
+			Name:         "connectivity",
+			Value:        json.RawMessage(`{"value":"<OK &  >"}`),
+			TimeOfSample: sampled,
+		},
+	}
+}
+
+func TestMarshalResponse(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	req := &Request{
+		Directive: RequestDirective{
+			Header: Header{
+				Namespace:        NamespaceAlexa,
+				Name:             "ReportState",
+				CorrelationToken: "corr\"<token>",
+			},
+			Endpoint: RequestEndpoint{
+				Scope:      Scope{Type: "BearerToken", Token: "token"},
+				EndpointID: "temp-sensor-1",
+			},
+		},
+	}
+
+	changeReport, err := rb.ChangeReport(Scope{Type: "BearerToken", Token: "token"},
+		"fan-1", CausePhysicalInteraction, testProperties()[:1], testProperties()[1:]...)
+	if err != nil {
+		t.Fatalf("failed to build change report: %v", err)
+	}
+
+	discover, err := rb.DiscoverResponse(DiscoverEndpoint{EndpointID: "fan-1", FriendlyName: "Fan"})
+	if err != nil {
+		t.Fatalf("failed to build discover response: %v", err)
+	}
+
+	cookie := rb.BasicResponse(req)
+	cookie.Event.Endpoint.Cookie = map[string]string{"z": "1", "a": "2", "m": "ünïcode"}
+
+	full := &Response{
+		Context: &ResponseContext{Properties: testProperties()},
+		Event: Event{
+			Header: Header{
+				Namespace:        NamespaceToggleController,
+				Name:             "Response",
+				Instance:         "Fan.Speed",
+				MessageID:        "msg-1",
+				CorrelationToken: "corr-1",
+				PayloadVersion:   "3",
+			},
+			Endpoint: &ResponseEndpoint{
+				EndpointID: "fan-1",
+				Cookie:     map[string]string{"key": "value"},
+				Scope: Scope{
+					Type:      "BearerTokenWithPartition",
+					Token:     "token",
+					Partition: "room-1",
+					UserID:    "user-1",
+				},
+			},
+			Payload: json.RawMessage(`{"value": 1}`),
+		},
+	}
+
+	tests := map[string]*Response{
+		"full":         full,
+		"changeReport": changeReport,
+		"discover":     discover,
+		"stateReport":  rb.StateReportResponse(req, testProperties()...),
+		"emptyContext": rb.BasicResponse(req),
+		"cookie":       cookie,
+		"nilPayload":   {Event: Event{Header: Header{Name: "Response"}}},
+	}
+
+	for name, resp := range tests {
+		t.Run(name, func(t *testing.T) {
+			expected, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatalf("failed to json.Marshal: %v", err)
+			}
+
+			actual, err := MarshalResponse(resp)
+			if err != nil {
+				t.Fatalf("failed to MarshalResponse: %v", err)
+			}
+			if !bytes.Equal(expected, actual) {
+				t.Errorf("MarshalResponse output differs from json.Marshal\nexpected: %s\nactual:   %s", expected, actual)
+			}
+		})
+	}
+}
+
+func TestWithChangeReport(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	scope := Scope{Type: "BearerToken", Token: "token"}
+	properties := testProperties()
+
+	expectedResp, err := rb.ChangeReport(scope, "fan-1", CausePhysicalInteraction, properties[:1], properties[1:]...)
+	if err != nil {
+		t.Fatalf("failed to build change report: %v", err)
+	}
+	expected, err := MarshalResponse(expectedResp)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	// run twice so the second report reuses the pooled response
+	for i := 0; i < 2; i++ {
+		err := rb.WithChangeReport(scope, "fan-1", CausePhysicalInteraction, properties[:1], properties[1:],
+			func(resp *Response) error {
+				actual, err := MarshalResponse(resp)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(expected, actual) {
+					t.Errorf("pooled change report differs\nexpected: %s\nactual:   %s", expected, actual)
+				}
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("failed to build pooled change report: %v", err)
+		}
+	}
+}
+
+func TestMarshalResponseInvalidPayload(t *testing.T) {
+	resp := &Response{Event: Event{Payload: json.RawMessage(`{"broken"`)}}
+	if _, err := MarshalResponse(resp); err == nil {
+		t.Error("expected error for invalid payload")
+	}
+}
+
+// BenchmarkChangeReport builds and encodes change reports as an agent relaying a
+// high volume of device updates would.
+func BenchmarkChangeReport(b *testing.B) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	scope := Scope{Type: "BearerToken", Token: "token"}
+	properties := testProperties()
+
+	b.Run("json.Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				payload, err := json.Marshal(ChangeReportPayload{
+					Change: Change{
+						Cause:      Cause{Type: CausePhysicalInteraction},
+						Properties: properties[:1],
+					},
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				resp := &Response{
+					Context: &ResponseContext{Properties: properties[1:]},
+					Event: Event{
+						Header:   Header{Namespace: NamespaceAlexa, Name: "ChangeReport", PayloadVersion: "3", MessageID: rb.MessageID()},
+						Endpoint: &ResponseEndpoint{EndpointID: "fan-1", Scope: scope},
+						Payload:  payload,
+					},
+				}
+				if _, err := json.Marshal(resp); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+
+	b.Run("WithChangeReport", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				err := rb.WithChangeReport(scope, "fan-1", CausePhysicalInteraction, properties[:1], properties[1:],
+					func(resp *Response) error {
+						return EncodeResponse(ioutil.Discard, resp)
+					})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+
+	b.Run("MarshalResponse", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				resp, err := rb.ChangeReport(scope, "fan-1", CausePhysicalInteraction, properties[:1], properties[1:]...)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := MarshalResponse(resp); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
+
+func TestEncodeDiscoverResponse(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+
+	var endpoints []DiscoverEndpoint
+	for i := 0; i < 500; i++ {
+		endpoints = append(endpoints, DiscoverEndpoint{
+			EndpointID:        "switch-" + strconv.Itoa(i),
+			ManufacturerName:  "Acme & Sons",
+			FriendlyName:      "Switch " + strconv.Itoa(i),
+			Description:       "A <switch>",
+			DisplayCategories: []string{DisplayCategorySwitch},
+			Cookie:            map[string]string{"b": "2", "a": "1"},
+			Capabilities: []DiscoverCapability{
+				{Type: "AlexaInterface", Interface: InterfacePowerController, Version: "3"},
+			},
+		})
+	}
+
+	for name, endpoints := range map[string][]DiscoverEndpoint{
+		"none":  nil,
+		"empty": {},
+		"many":  endpoints,
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := rb.DiscoverResponse(endpoints...)
+			if err != nil {
+				t.Fatalf("failed to build discover response: %v", err)
+			}
+			expected, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+
+			var actual bytes.Buffer
+			if err := rb.EncodeDiscoverResponse(&actual, endpoints...); err != nil {
+				t.Fatalf("failed to encode: %v", err)
+			}
+			if !bytes.Equal(expected, actual.Bytes()) {
+				t.Errorf("EncodeDiscoverResponse output differs from json.Marshal\nexpected: %.300s\nactual:   %.300s", expected, actual.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package alexa
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PropertyConnectivity is the Alexa.EndpointHealth property holding a ConnectivityValue
+const PropertyConnectivity = "connectivity"
+
+// EndpointHealthProperty creates a connectivity property. reason optionally explains why
+// an UNREACHABLE endpoint can't be reached and is ignored when connectivity is OK.
+func EndpointHealthProperty(connectivity, reason string, timeOfSample time.Time) (ContextProperty, error) {
+	value := ConnectivityValue{Value: connectivity}
+	if connectivity != ConnectivityOK {
+		value.Reason = reason
+	}
+	return NewProperty(NamespaceEndpointHealth, PropertyConnectivity, value, timeOfSample)
+}
+
+// EndpointConnectivity returns the connectivity of an endpoint, one of the Connectivity enums
+type EndpointConnectivity func(ctx context.Context, endpointID string) (string, error)
+
+// EndpointHealthHandler wraps handler and adds a connectivity property from connectivity,
+// e.g. state.ConnectivityMonitor's EndpointConnectivity, to the context of responses that
+// don't include one. Error and deferred responses are left unchanged. The response fails if
+// connectivity does.
+func EndpointHealthHandler(connectivity EndpointConnectivity, handler Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		resp, err := handler.HandleRequest(ctx, req)
+		if err != nil || resp == nil || resp.Event.Endpoint == nil || resp.Event.Endpoint.EndpointID == "" {
+			return resp, err
+		}
+		switch resp.Event.Header.Name {
+		case "ErrorResponse", "DeferredResponse":
+			return resp, nil
+		}
+
+		var properties []ContextProperty
+		if resp.Context != nil {
+			properties = resp.Context.Properties
+		}
+		for _, prop := range properties {
+			if prop.Namespace == NamespaceEndpointHealth && prop.Name == PropertyConnectivity {
+				return resp, nil
+			}
+		}
+
+		endpointID := resp.Event.Endpoint.EndpointID
+		value, err := connectivity(ctx, endpointID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get connectivity of %s: %v", endpointID, err)
+		}
+		prop, err := EndpointHealthProperty(value, "", time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+
+		// copy so a response shared with other requests isn't modified
+		withConnectivity := *resp
+		withConnectivity.Context = &ResponseContext{
+			Properties: append(properties[:len(properties):len(properties)], prop),
+		}
+		return &withConnectivity, nil
+	}
+}
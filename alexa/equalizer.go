@@ -0,0 +1,197 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Alexa.EqualizerController properties
+const (
+	PropertyBands = "bands"
+	// PropertyEqualizerMode is the mode property, distinct from Alexa.ModeController's
+	PropertyEqualizerMode = "mode"
+)
+
+// EqualizerBand enums
+const (
+	EqualizerBandBass     = "BASS"
+	EqualizerBandMidrange = "MIDRANGE"
+	EqualizerBandTreble   = "TREBLE"
+)
+
+// EqualizerMode enums
+const (
+	EqualizerModeMovie = "MOVIE"
+	EqualizerModeMusic = "MUSIC"
+	EqualizerModeNight = "NIGHT"
+	EqualizerModeSport = "SPORT"
+	EqualizerModeTV    = "TV"
+)
+
+// LevelDirection enums
+const (
+	LevelDirectionDown = "DOWN"
+	LevelDirectionUp   = "UP"
+)
+
+// EqualizerConfiguration is the discovery configuration of an equalizer. Either of Bands
+// or Modes may be nil if the endpoint doesn't support them.
+type EqualizerConfiguration struct {
+	Bands *EqualizerBandsConfiguration `json:"bands,omitempty"`
+	Modes *EqualizerModesConfiguration `json:"modes,omitempty"`
+}
+
+type EqualizerBandsConfiguration struct {
+	Supported []EqualizerName `json:"supported"`
+	Range     EqualizerRange  `json:"range"`
+}
+
+type EqualizerModesConfiguration struct {
+	Supported []EqualizerName `json:"supported"`
+}
+
+type EqualizerName struct {
+	Name string `json:"name"`
+}
+
+// EqualizerRange is the inclusive range of band levels
+type EqualizerRange struct {
+	Minimum int `json:"minimum"`
+	Maximum int `json:"maximum"`
+}
+
+// Clamp limits level to the range
+func (r EqualizerRange) Clamp(level int) int {
+	if level < r.Minimum {
+		return r.Minimum
+	}
+	if level > r.Maximum {
+		return r.Maximum
+	}
+	return level
+}
+
+// NewEqualizerBandsConfiguration creates the configuration of bands adjustable within min & max
+func NewEqualizerBandsConfiguration(min, max int, bands ...string) *EqualizerBandsConfiguration {
+	config := &EqualizerBandsConfiguration{Range: EqualizerRange{Minimum: min, Maximum: max}}
+	for _, band := range bands {
+		config.Supported = append(config.Supported, EqualizerName{Name: band})
+	}
+	return config
+}
+
+// NewEqualizerModesConfiguration creates the configuration of the supported modes
+func NewEqualizerModesConfiguration(modes ...string) *EqualizerModesConfiguration {
+	config := &EqualizerModesConfiguration{}
+	for _, mode := range modes {
+		config.Supported = append(config.Supported, EqualizerName{Name: mode})
+	}
+	return config
+}
+
+// EqualizerBand is the level of a band. It's both the bands property value and the bands
+// of a SetBands directive.
+type EqualizerBand struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+type SetBandsPayload struct {
+	Bands []EqualizerBand `json:"bands"`
+}
+
+// EqualizerBandAdjustment changes a band's level by LevelDelta in LevelDirection
+type EqualizerBandAdjustment struct {
+	Name           string `json:"name"`
+	LevelDelta     int    `json:"levelDelta"`
+	LevelDirection string `json:"levelDirection"`
+}
+
+// Delta returns the signed level change
+func (a EqualizerBandAdjustment) Delta() int {
+	if a.LevelDirection == LevelDirectionDown {
+		return -a.LevelDelta
+	}
+	return a.LevelDelta
+}
+
+type AdjustBandsPayload struct {
+	Bands []EqualizerBandAdjustment `json:"bands"`
+}
+
+// Validate checks that the level directions are UP or DOWN
+func (p AdjustBandsPayload) Validate() error {
+	for _, band := range p.Bands {
+		if band.LevelDirection != LevelDirectionUp && band.LevelDirection != LevelDirectionDown {
+			return fmt.Errorf("unknown level direction %q for %s", band.LevelDirection, band.Name)
+		}
+	}
+	return nil
+}
+
+// ResetBandsPayload lists the bands to reset to their default level
+type ResetBandsPayload struct {
+	Bands []EqualizerName `json:"bands"`
+}
+
+type SetEqualizerModePayload struct {
+	Mode string `json:"mode"`
+}
+
+// EqualizerControllerHandler routes handling of band & mode directives. setMode may be nil
+// for endpoints without modes.
+func EqualizerControllerHandler(setBands, adjustBands, resetBands, setMode Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "SetBands":
+			return setBands.HandleRequest(ctx, req)
+		case "AdjustBands":
+			return adjustBands.HandleRequest(ctx, req)
+		case "ResetBands":
+			return resetBands.HandleRequest(ctx, req)
+		case "SetMode":
+			if setMode != nil {
+				return setMode.HandleRequest(ctx, req)
+			}
+		}
+		return nil, UnexpectedDirective("EqualizerControllerHandler", req)
+	}
+}
+
+// BandsProperty creates a bands property
+func BandsProperty(bands []EqualizerBand, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceEqualizerController, PropertyBands, bands, timeOfSample)
+}
+
+// EqualizerModeProperty creates an equalizer mode property
+func EqualizerModeProperty(mode string, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceEqualizerController, PropertyEqualizerMode, mode, timeOfSample)
+}
+
+// NewEqualizerControllerCapability creates the discovery capability of an equalizer
+func NewEqualizerControllerCapability(config EqualizerConfiguration) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	var supported []DiscoverProperty
+	if config.Bands != nil {
+		supported = append(supported, DiscoverProperty{Name: PropertyBands})
+	}
+	if config.Modes != nil {
+		supported = append(supported, DiscoverProperty{Name: PropertyEqualizerMode})
+	}
+	return DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: InterfaceEqualizerController,
+		Version:   "3",
+		Properties: &DiscoverProperties{
+			Supported:           supported,
+			ProactivelyReported: true,
+			Retrievable:         true,
+		},
+		Configurations: configJSON,
+	}, nil
+}
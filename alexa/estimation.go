@@ -0,0 +1,73 @@
+package alexa
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PowerProfileType enums. They name the properties Alexa estimates usage from.
+const (
+	PowerProfileTypeBrightness                 = "BRIGHTNESS"
+	PowerProfileTypeBrightnessColor            = "BRIGHTNESS_COLOR"
+	PowerProfileTypeBrightnessColorTemperature = "BRIGHTNESS_COLOR_TEMPERATURE"
+)
+
+// WattageUnitsWatts is the only supported Wattage unit
+const WattageUnitsWatts = "WATTS"
+
+// EstimationConfiguration is the discovery configuration of an endpoint whose energy use
+// Alexa estimates from its power profile and reported state
+type EstimationConfiguration struct {
+	PowerProfile PowerProfileConfiguration `json:"powerProfile"`
+}
+
+// Validate checks the power profile type and that the maximum draw is in watts and at least
+// the standby draw
+func (c EstimationConfiguration) Validate() error {
+	profile := c.PowerProfile
+	switch profile.Type {
+	case PowerProfileTypeBrightness, PowerProfileTypeBrightnessColor, PowerProfileTypeBrightnessColorTemperature:
+	default:
+		return fmt.Errorf("unknown power profile type %q", profile.Type)
+	}
+	if profile.StandbyWattage.Units != WattageUnitsWatts || profile.MaximumWattage.Units != WattageUnitsWatts {
+		return fmt.Errorf("wattage units must be %s", WattageUnitsWatts)
+	}
+	return validateRange("standbyWattage", profile.StandbyWattage.Value, 0, profile.MaximumWattage.Value)
+}
+
+// PowerProfileConfiguration is the draw of a light while off and on at full brightness
+type PowerProfileConfiguration struct {
+	Type           string  `json:"type"`
+	StandbyWattage Wattage `json:"standbyWattage"`
+	MaximumWattage Wattage `json:"maximumWattage"`
+}
+
+type Wattage struct {
+	Value float64 `json:"value"`
+	Units string  `json:"units"`
+}
+
+// Watts creates a Wattage of value watts
+func Watts(value float64) Wattage {
+	return Wattage{Value: value, Units: WattageUnitsWatts}
+}
+
+// NewEstimationCapability creates the discovery capability of an endpoint opting into
+// Alexa's energy estimates. The endpoint must also report the properties named by the
+// power profile type, e.g. brightness.
+func NewEstimationCapability(config EstimationConfiguration) (DiscoverCapability, error) {
+	if err := config.Validate(); err != nil {
+		return DiscoverCapability{}, fmt.Errorf("invalid estimation configuration: %v", err)
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:          "AlexaInterface",
+		Interface:     InterfaceDeviceUsageEstimation,
+		Version:       "1.0",
+		Configuration: configJSON,
+	}, nil
+}
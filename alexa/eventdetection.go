@@ -0,0 +1,121 @@
+package alexa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PropertyHumanPresenceDetectionState is the Alexa.EventDetectionSensor property reporting
+// a person detected by a camera
+const PropertyHumanPresenceDetectionState = "humanPresenceDetectionState"
+
+// DetectionMethod enums
+const (
+	DetectionMethodAudio = "AUDIO"
+	DetectionMethodVideo = "VIDEO"
+)
+
+// FeatureAvailability enums
+const (
+	FeatureAvailabilityDisabled    = "DISABLED"
+	FeatureAvailabilityEnabled     = "ENABLED"
+	FeatureAvailabilityUnavailable = "UNAVAILABLE"
+)
+
+// MediaTypeMediaMetadata identifies media described by Alexa.MediaMetadata
+const MediaTypeMediaMetadata = "ALEXA.MEDIAMETADATA"
+
+// EventDetectionConfiguration is the discovery configuration of an event detection sensor
+type EventDetectionConfiguration struct {
+	// DetectionMethods lists the DetectionMethod enums the sensor uses
+	DetectionMethods []string       `json:"detectionMethods"`
+	DetectionModes   DetectionModes `json:"detectionModes"`
+}
+
+type DetectionModes struct {
+	HumanPresence DetectionMode `json:"humanPresence"`
+}
+
+// Validate checks the detection methods and availability are known enums
+func (c EventDetectionConfiguration) Validate() error {
+	if len(c.DetectionMethods) == 0 {
+		return errors.New("detectionMethods must be set")
+	}
+	for _, method := range c.DetectionMethods {
+		if method != DetectionMethodAudio && method != DetectionMethodVideo {
+			return fmt.Errorf("unknown detection method %q", method)
+		}
+	}
+	switch c.DetectionModes.HumanPresence.FeatureAvailability {
+	case FeatureAvailabilityDisabled, FeatureAvailabilityEnabled, FeatureAvailabilityUnavailable:
+		return nil
+	default:
+		return fmt.Errorf("unknown feature availability %q", c.DetectionModes.HumanPresence.FeatureAvailability)
+	}
+}
+
+// DetectionMode describes if a detection is available. SupportsNotDetected is set if the
+// sensor also reports when nobody is detected.
+type DetectionMode struct {
+	FeatureAvailability string `json:"featureAvailability"`
+	SupportsNotDetected bool   `json:"supportsNotDetected"`
+}
+
+// HumanPresenceValue is the value of a humanPresenceDetectionState property. Media is the
+// recording of the detection, if any.
+type HumanPresenceValue struct {
+	Value            string          `json:"value"`
+	DetectionMethods []string        `json:"detectionMethods,omitempty"`
+	Media            *DetectionMedia `json:"media,omitempty"`
+}
+
+// DetectionMedia identifies a recording, e.g. a MediaMetadata media id
+type DetectionMedia struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// NewEventDetectionSensorCapability creates the discovery capability of a camera detecting
+// people. The detection state is only proactively reported.
+func NewEventDetectionSensorCapability(config EventDetectionConfiguration) (DiscoverCapability, error) {
+	if err := config.Validate(); err != nil {
+		return DiscoverCapability{}, fmt.Errorf("invalid event detection configuration: %v", err)
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: InterfaceEventDetectionSensor,
+		Version:   "3",
+		Properties: &DiscoverProperties{
+			Supported:           []DiscoverProperty{{Name: PropertyHumanPresenceDetectionState}},
+			ProactivelyReported: true,
+		},
+		Configuration: configJSON,
+	}, nil
+}
+
+// HumanPresenceProperty creates a humanPresenceDetectionState property
+func HumanPresenceProperty(value HumanPresenceValue, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceEventDetectionSensor, PropertyHumanPresenceDetectionState, value, timeOfSample)
+}
+
+// HumanPresenceReport creates a proactive ChangeReport that a person was detected at
+// detectedAt by methods. mediaID optionally identifies the recording. scope must identify
+// the user, see deferred.HTTPEventSender.
+func (r *ResponseBuilder) HumanPresenceReport(scope Scope, endpointID string, detectedAt time.Time,
+	mediaID string, methods ...string) (*Response, error) {
+	value := HumanPresenceValue{Value: DetectionStateDetected, DetectionMethods: methods}
+	if mediaID != "" {
+		value.Media = &DetectionMedia{Type: MediaTypeMediaMetadata, ID: mediaID}
+	}
+	prop, err := HumanPresenceProperty(value, detectedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r.ChangeReport(scope, endpointID, CausePhysicalInteraction, []ContextProperty{prop})
+}
@@ -0,0 +1,108 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// FileTokenStore stores each user's oauth tokens as a json file in Dir. It's intended
+// for agents running on a single host and local development.
+type FileTokenStore struct {
+	Dir string
+}
+
+const tokenFileExt = ".json"
+
+func (f *FileTokenStore) Write(ctx context.Context, id string, token *oauth2.Token) error {
+	content, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %v", err)
+	}
+
+	if err := os.MkdirAll(f.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create token dir: %v", err)
+	}
+
+	// write to a temp file first so a concurrent Read never sees a partial token
+	tmp, err := ioutil.TempFile(f.Dir, ".token-")
+	if err != nil {
+		return fmt.Errorf("failed to create token file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write token file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write token file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path(id)); err != nil {
+		return fmt.Errorf("failed to write token file: %v", err)
+	}
+
+	return nil
+}
+
+func (f *FileTokenStore) Read(ctx context.Context, id string) (*oauth2.Token, error) {
+	content, err := ioutil.ReadFile(f.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read token file: %v", err)
+	}
+
+	var token oauth2.Token
+	if err := json.Unmarshal(content, &token); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token: %v", err)
+	}
+
+	return &token, nil
+}
+
+func (f *FileTokenStore) Delete(ctx context.Context, id string) error {
+	if err := os.Remove(f.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete token file: %v", err)
+	}
+	return nil
+}
+
+// List returns the ids of all users with stored tokens
+func (f *FileTokenStore) List(ctx context.Context) ([]string, error) {
+	entries, err := ioutil.ReadDir(f.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read token dir: %v", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, tokenFileExt) {
+			continue
+		}
+		id, err := url.PathUnescape(strings.TrimSuffix(name, tokenFileExt))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// path escapes id so it can't refer to a file outside of Dir
+func (f *FileTokenStore) path(id string) string {
+	return filepath.Join(f.Dir, url.PathEscape(id)+tokenFileExt)
+}
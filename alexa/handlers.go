@@ -2,8 +2,10 @@ package alexa
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/amazon"
@@ -43,64 +45,191 @@ func StaticDiscoveryHandler(builder *ResponseBuilder, endpoints ...DiscoverEndpo
 // to post events to the smart home api
 func AuthorizationHandler(clientID, clientSecret string,
 	userIDReader UserIDReader, tokenWriter TokenWriter, respBuilder *ResponseBuilder) HandlerFunc {
-	return func(ctx context.Context, req *Request) (*Response, error) {
-		var payload AcceptGrantPayload
-		if err := json.Unmarshal(req.Directive.Payload, &payload); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal payload: %v", err)
-		}
+	grantHandler := &AcceptGrantHandler{
+		Credentials:  ClientCredentials{clientID, clientSecret},
+		UserIDReader: userIDReader,
+		TokenWriter:  tokenWriter,
+		RespBuilder:  respBuilder,
+	}
+	return grantHandler.HandleRequest
+}
 
-		config := oauth2.Config{
-			ClientID:     clientID,
-			ClientSecret: clientSecret,
-			Endpoint:     amazon.Endpoint,
-		}
+// OAuthEndpointOrDefault returns endpoint or the Login with Amazon endpoint if endpoint is unset
+func OAuthEndpointOrDefault(endpoint oauth2.Endpoint) oauth2.Endpoint {
+	if endpoint.TokenURL == "" {
+		return amazon.Endpoint
+	}
+	return endpoint
+}
 
-		token, err := config.Exchange(ctx, payload.Grant.Code)
-		if err != nil {
-			resp, err := respBuilder.BasicErrorResponse(req,
-				"ACCEPT_GRANT_FAILED",
-				fmt.Sprintf("failed to exchange token: %v", err))
-			if err != nil {
-				return nil, fmt.Errorf("failed to create error response: %v", err)
-			}
-			return resp, nil
-		}
+// GrantInfo describes the outcome of an AcceptGrant request
+type GrantInfo struct {
+	// UserID is empty if the user could not be identified
+	UserID          string
+	TokenType       string
+	Expiry          time.Time
+	HasRefreshToken bool
+}
 
-		userID, err := userIDReader.Read(ctx, payload.Grantee.Token)
-		if err != nil {
-			resp, err := respBuilder.BasicErrorResponse(req,
-				"ACCEPT_GRANT_FAILED",
-				fmt.Sprintf("failed to lookup userid: %v", err))
-			if err != nil {
-				return nil, fmt.Errorf("failed to create error response: %v", err)
-			}
-			return resp, nil
+// AcceptGrantHandler handles an Authorization AcceptGrant request and fetches credentials required
+// to post events to the smart home api. The optional hooks allow applications to provision
+// per-user resources or alert when account linking fails.
+type AcceptGrantHandler struct {
+	Credentials CredentialsProvider
+	// Endpoint is the oauth endpoint the grant code is exchanged with.
+	// Defaults to Login with Amazon.
+	Endpoint     oauth2.Endpoint
+	UserIDReader UserIDReader
+	TokenWriter  TokenWriter
+	RespBuilder  *ResponseBuilder
+	// HTTPClient performs the token exchange. Defaults to DefaultHTTPClient.
+	HTTPClient *http.Client
+	// Retries is the number of additional exchange attempts made after a network
+	// failure or 5xx response from the oauth endpoint.
+	Retries int
+	// RetryDelay is the wait before the first retry. It doubles on each subsequent retry.
+	RetryDelay time.Duration
+	// OnGrantAccepted is called after the user's token has been stored
+	OnGrantAccepted func(ctx context.Context, info GrantInfo)
+	// OnGrantFailed is called before an ACCEPT_GRANT_FAILED error is returned
+	OnGrantFailed func(ctx context.Context, info GrantInfo, err error)
+}
+
+// HandleRequest exchanges the grant code for tokens and stores them for the user
+func (a *AcceptGrantHandler) HandleRequest(ctx context.Context, req *Request) (*Response, error) {
+	var payload AcceptGrantPayload
+	if err := req.DecodePayload(&payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payload: %v", err)
+	}
+
+	var info GrantInfo
+
+	creds, err := a.Credentials.Credentials(ctx)
+	if err != nil {
+		return a.failGrant(ctx, req, info, fmt.Errorf("failed to load client credentials: %v", err))
+	}
+
+	config := oauth2.Config{
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		Endpoint:     OAuthEndpointOrDefault(a.Endpoint),
+	}
+
+	token, err := a.exchange(ctx, &config, payload.Grant.Code)
+	if err != nil {
+		return a.failGrant(ctx, req, info, fmt.Errorf("failed to exchange token: %v", err))
+	}
+	info.TokenType = token.Type()
+	info.Expiry = token.Expiry
+	info.HasRefreshToken = token.RefreshToken != ""
+
+	userID, err := a.UserIDReader.Read(ctx, payload.Grantee.Token)
+	if err != nil {
+		return a.failGrant(ctx, req, info, fmt.Errorf("failed to lookup userid: %v", err))
+	}
+	info.UserID = userID
+
+	if err := a.TokenWriter.Write(ctx, userID, token); err != nil {
+		return a.failGrant(ctx, req, info, fmt.Errorf("failed to store token: %v", err))
+	}
+
+	if a.OnGrantAccepted != nil {
+		a.OnGrantAccepted(ctx, info)
+	}
+
+	return a.RespBuilder.AcceptGrantResponse(), nil
+}
+
+// exchange performs the token exchange, retrying transient failures
+func (a *AcceptGrantHandler) exchange(ctx context.Context, config *oauth2.Config, code string) (*oauth2.Token, error) {
+	delay := a.RetryDelay
+	for attempt := 0; ; attempt++ {
+		token, err := config.Exchange(WithOAuthHTTPClient(ctx, a.HTTPClient), code)
+		if err == nil || attempt >= a.Retries || !isTransientExchangeError(ctx, err) {
+			return token, err
 		}
 
-		if err := tokenWriter.Write(ctx, userID, token); err != nil {
-			resp, err := respBuilder.BasicErrorResponse(req,
-				"ACCEPT_GRANT_FAILED",
-				fmt.Sprintf("failed to store token: %v", err))
-			if err != nil {
-				return nil, fmt.Errorf("failed to create error response: %v", err)
-			}
-			return resp, nil
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return nil, err
 		}
+	}
+}
+
+// isTransientExchangeError reports if a failed exchange may succeed if retried
+func isTransientExchangeError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return retrieveErr.Response != nil && retrieveErr.Response.StatusCode >= http.StatusInternalServerError
+	}
+	// no response was received from the oauth endpoint
+	return true
+}
+
+func (a *AcceptGrantHandler) failGrant(ctx context.Context, req *Request, info GrantInfo, grantErr error) (*Response, error) {
+	if a.OnGrantFailed != nil {
+		a.OnGrantFailed(ctx, info, grantErr)
+	}
 
-		return respBuilder.AcceptGrantResponse(), nil
+	resp, err := a.RespBuilder.BasicErrorResponse(req, ErrorTypeAcceptGrantFailed, grantErr.Error())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create error response: %v", err)
+	}
+	return resp, nil
+}
+
+// UnexpectedDirectiveError is returned by routing handlers for directives they don't
+// handle. Wrap the handler chain with InvalidDirectiveHandler to respond with an
+// INVALID_DIRECTIVE error rather than failing the request.
+type UnexpectedDirectiveError struct {
+	// Router is the handler that rejected the directive
+	Router    string
+	Namespace string
+	Name      string
+}
+
+func (u *UnexpectedDirectiveError) Error() string {
+	return fmt.Sprintf("%s: unexpected name: %s", u.Router, u.Name)
+}
+
+// ValueOutOfRangeError is returned for directive values outside of the range an endpoint
+// supports. Wrap the handler chain with ValueOutOfRangeHandler to respond with a
+// VALUE_OUT_OF_RANGE error rather than failing the request.
+type ValueOutOfRangeError struct {
+	Field string
+	Value float64
+	Min   float64
+	Max   float64
+}
+
+func (v *ValueOutOfRangeError) Error() string {
+	return fmt.Sprintf("%s %g is outside of %g to %g", v.Field, v.Value, v.Min, v.Max)
+}
+
+// UnexpectedDirective creates an UnexpectedDirectiveError for req rejected by router
+func UnexpectedDirective(router string, req *Request) error {
+	return &UnexpectedDirectiveError{
+		Router:    router,
+		Namespace: req.Directive.Header.Namespace,
+		Name:      req.Directive.Header.Name,
 	}
 }
 
 // PercentageControllerHandler routes handling of set & adjust directives
 func PercentageControllerHandler(setPct, adjustPct Handler) HandlerFunc {
 	return func(ctx context.Context, req *Request) (*Response, error) {
-		switch req.Directive.Header.Name {
+		switch req.DirectiveName() {
 		case "SetPercentage":
 			return setPct.HandleRequest(ctx, req)
 		case "AdjustPercentage":
 			return adjustPct.HandleRequest(ctx, req)
 		default:
-			return nil, fmt.Errorf("PercentageControllerHandler: unexpected name: %s", req.Directive.Header.Name)
+			return nil, UnexpectedDirective("PercentageControllerHandler", req)
 		}
 	}
 }
@@ -108,13 +237,13 @@ func PercentageControllerHandler(setPct, adjustPct Handler) HandlerFunc {
 // PowerControllerHandler routes turn on & off requests
 func PowerControllerHandler(turnOn, turnOff Handler) HandlerFunc {
 	return func(ctx context.Context, req *Request) (*Response, error) {
-		switch req.Directive.Header.Name {
+		switch req.DirectiveName() {
 		case "TurnOn":
 			return turnOn.HandleRequest(ctx, req)
 		case "TurnOff":
 			return turnOff.HandleRequest(ctx, req)
 		default:
-			return nil, fmt.Errorf("PowerControllerHandler: unexpected name: %s", req.Directive.Header.Name)
+			return nil, UnexpectedDirective("PowerControllerHandler", req)
 		}
 	}
 }
@@ -122,13 +251,24 @@ func PowerControllerHandler(turnOn, turnOff Handler) HandlerFunc {
 // SceneControllerHandler routes activate & deactivate requests
 func SceneControllerHandler(activate, deactivate Handler) HandlerFunc {
 	return func(ctx context.Context, req *Request) (*Response, error) {
-		switch req.Directive.Header.Name {
+		switch req.DirectiveName() {
 		case "Activate":
 			return activate.HandleRequest(ctx, req)
 		case "Deactivate":
 			return deactivate.HandleRequest(ctx, req)
 		default:
-			return nil, fmt.Errorf("SceneControllerHandler: unexpected name: %s", req.Directive.Header.Name)
+			return nil, UnexpectedDirective("SceneControllerHandler", req)
+		}
+	}
+}
+
+// InstanceHandler routes directives of multi-instance interfaces such as
+// Alexa.ToggleController to the handler of the targeted instance
+func InstanceHandler(handlers map[string]Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		if handler, ok := handlers[req.Instance()]; ok && handler != nil {
+			return handler.HandleRequest(ctx, req)
 		}
+		return nil, UnexpectedDirective("InstanceHandler", req)
 	}
 }
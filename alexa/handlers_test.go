@@ -3,9 +3,15 @@ package alexa
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"golang.org/x/oauth2"
 )
 
 const sampleRequest = `{
@@ -120,3 +126,1464 @@ func (t *mockTempReader) GetTemperature(ctx context.Context, req *Request) (*Res
 			UncertaintyInMilliseconds: 60000,
 		}), nil
 }
+
+func TestInvalidDirectiveHandler(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	ok := HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+		return rb.BasicResponse(req), nil
+	})
+
+	endpointMux := NewEndpointMux()
+	endpointMux.Handle("switch-1", PowerControllerHandler(ok, ok))
+	handler := InvalidDirectiveHandler(rb, endpointMux)
+
+	req := &Request{
+		Directive: RequestDirective{
+			Header:   Header{Namespace: NamespacePowerController, Name: "Toggle"},
+			Endpoint: RequestEndpoint{EndpointID: "switch-1"},
+		},
+	}
+
+	if _, err := endpointMux.HandleRequest(context.Background(), req); err == nil {
+		t.Fatal("expected error from unwrapped mux")
+	}
+
+	resp, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+	if resp.Event.Header.Name != "ErrorResponse" {
+		t.Fatalf("expected ErrorResponse, got %s", resp.Event.Header.Name)
+	}
+	var payload struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+	if payload.Type != ErrorTypeInvalidDirective {
+		t.Errorf("expected %s, got %s", ErrorTypeInvalidDirective, payload.Type)
+	}
+}
+
+func TestNamespaceMuxReportState(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	var handled string
+	handler := func(name string) HandlerFunc {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			handled = name
+			return rb.BasicResponse(req), nil
+		}
+	}
+
+	mux := NewNamespaceMux()
+	mux.Handle(NamespaceAlexa, handler("alexa"))
+	mux.HandleReportState(handler("reportState"))
+
+	for name, expected := range map[string]string{
+		DirectiveReportState: "reportState",
+		"Other":              "alexa",
+	} {
+		req := &Request{Directive: RequestDirective{Header: Header{Namespace: NamespaceAlexa, Name: name}}}
+		if _, err := mux.HandleRequest(context.Background(), req); err != nil {
+			t.Fatalf("failed to handle %s: %v", name, err)
+		}
+		if handled != expected {
+			t.Errorf("expected %s to be handled by %s, got %s", name, expected, handled)
+		}
+	}
+}
+
+func TestPartitionMux(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	var got PartitionScope
+	kitchen := HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+		got, _ = PartitionFromContext(ctx)
+		return rb.BasicResponse(req), nil
+	})
+	unpartitioned := HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+		if _, ok := PartitionFromContext(ctx); ok {
+			t.Error("expected no partition in context")
+		}
+		return rb.BasicResponse(req), nil
+	})
+
+	mux := NewPartitionMux()
+	mux.Handle("kitchen", kitchen)
+	mux.Handle("", unpartitioned)
+
+	req := &Request{
+		Directive: RequestDirective{
+			Header: Header{Namespace: NamespacePowerController, Name: "TurnOn"},
+			Endpoint: RequestEndpoint{
+				EndpointID: "light-1",
+				Scope:      PartitionScope{Token: "token", Partition: "kitchen", UserID: "user-1"}.Scope(),
+			},
+		},
+	}
+	if _, err := mux.HandleRequest(context.Background(), req); err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+	if got.Partition != "kitchen" || got.UserID != "user-1" {
+		t.Errorf("unexpected partition in context: %+v", got)
+	}
+
+	req.Directive.Endpoint.Scope = Scope{Type: ScopeTypeBearerToken, Token: "token"}
+	if _, err := mux.HandleRequest(context.Background(), req); err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+
+	req.Directive.Endpoint.Scope = PartitionScope{Token: "token", Partition: "garage"}.Scope()
+	if _, err := mux.HandleRequest(context.Background(), req); err == nil {
+		t.Error("expected error for unregistered partition")
+	}
+}
+
+type relayFunc func(ctx context.Context, req *Request) error
+
+func (r relayFunc) Relay(ctx context.Context, req *Request) error {
+	return r(ctx, req)
+}
+
+func TestBudgetHandler(t *testing.T) {
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	budget := &Budget{Relay: time.Second, Handle: 3 * time.Second, Send: time.Second, Now: func() time.Time { return now }}
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+
+	var relayed int
+	relayer := relayFunc(func(ctx context.Context, req *Request) error {
+		relayed++
+		return nil
+	})
+	var handleDeadline time.Time
+	handler := HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+		handleDeadline, _ = ctx.Deadline()
+		return rb.BasicResponse(req), nil
+	})
+	budgetHandler := BudgetHandler(budget, relayer, rb, handler)
+	req := &Request{Directive: RequestDirective{Header: Header{Namespace: NamespacePowerController, Name: "TurnOn"}}}
+
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(5*time.Second))
+	defer cancel()
+	resp, err := budgetHandler(ctx, req)
+	if err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+	if resp.Event.Header.Name != "Response" || relayed != 0 {
+		t.Errorf("expected synchronous response, got %s", resp.Event.Header.Name)
+	}
+	if expected := now.Add(4 * time.Second); !handleDeadline.Equal(expected) {
+		t.Errorf("expected handle deadline %v, got %v", expected, handleDeadline)
+	}
+
+	ctx, cancel = context.WithDeadline(context.Background(), now.Add(3*time.Second))
+	defer cancel()
+	resp, err = budgetHandler(ctx, req)
+	if err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+	if resp.Event.Header.Name != "DeferredResponse" || relayed != 1 {
+		t.Errorf("expected deferred response, got %s", resp.Event.Header.Name)
+	}
+
+	ctx, cancel = context.WithDeadline(context.Background(), now.Add(time.Second))
+	defer cancel()
+	if _, err := budgetHandler(ctx, req); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected budget exceeded, got %v", err)
+	}
+}
+
+// handledBy returns a handler for directives with payload P that responds with an event
+// named name carrying the decoded payload, so cases can check which handler a directive
+// reached and what it decoded
+func handledBy[P any](name string) HandlerFunc {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	return Typed(func(ctx context.Context, req *Request, payload P) (*Response, error) {
+		return TypedResponse(rb, req, req.Directive.Header.Namespace, name, payload)
+	})
+}
+
+func TestControllerHandlers(t *testing.T) {
+	tests := map[string]struct {
+		handler HandlerFunc
+		header  Header
+		payload string
+		// handled is the name of the handler the directive reaches, empty if it's rejected
+		handled string
+		// decoded optionally checks the payload decoded by the handler
+		decoded    string
+		outOfRange bool
+	}{
+		"brightness set": {
+			handler: BrightnessControllerHandler(handledBy[SetBrightnessPayload]("set"), handledBy[AdjustBrightnessPayload]("adjust")),
+			header:  Header{Namespace: NamespaceBrightnessController, Name: "SetBrightness"},
+			payload: `{"brightness": 40}`,
+			handled: "set",
+			decoded: `{"brightness":40}`,
+		},
+		"brightness adjust": {
+			handler: BrightnessControllerHandler(handledBy[SetBrightnessPayload]("set"), handledBy[AdjustBrightnessPayload]("adjust")),
+			header:  Header{Namespace: NamespaceBrightnessController, Name: "AdjustBrightness"},
+			payload: `{"brightnessDelta": -15}`,
+			handled: "adjust",
+			decoded: `{"brightnessDelta":-15}`,
+		},
+		"brightness out of range": {
+			handler:    BrightnessControllerHandler(handledBy[SetBrightnessPayload]("set"), handledBy[AdjustBrightnessPayload]("adjust")),
+			header:     Header{Namespace: NamespaceBrightnessController, Name: "SetBrightness"},
+			payload:    `{"brightness": 140}`,
+			outOfRange: true,
+		},
+		"toggle instance": {
+			handler: InstanceHandler(map[string]Handler{
+				"Fan.Oscillate": ToggleControllerHandler(handledBy[struct{}]("oscillate on"), handledBy[struct{}]("oscillate off")),
+				"Fan.Light":     ToggleControllerHandler(handledBy[struct{}]("light on"), handledBy[struct{}]("light off")),
+			}),
+			header:  Header{Namespace: NamespaceToggleController, Name: "TurnOn", Instance: "Fan.Oscillate"},
+			handled: "oscillate on",
+		},
+		"toggle unknown instance": {
+			handler: InstanceHandler(map[string]Handler{
+				"Fan.Oscillate": ToggleControllerHandler(handledBy[struct{}]("oscillate on"), handledBy[struct{}]("oscillate off")),
+			}),
+			header: Header{Namespace: NamespaceToggleController, Name: "TurnOn", Instance: "Fan.Missing"},
+		},
+		"security panel arm": {
+			handler: SecurityPanelControllerHandler(handledBy[ArmPayload]("arm"), handledBy[DisarmPayload]("disarm")),
+			header:  Header{Namespace: NamespaceSecurityPanelController, Name: "Arm"},
+			payload: `{"armState":"ARMED_AWAY"}`,
+			handled: "arm",
+		},
+		"rtc session offer": {
+			handler: RTCSessionControllerHandler(handledBy[InitiateSessionWithOfferPayload]("initiate"),
+				handledBy[SessionPayload]("connected"), handledBy[SessionPayload]("disconnected")),
+			header:  Header{Namespace: NamespaceRTCSessionController, Name: "InitiateSessionWithOffer"},
+			payload: `{"sessionId":"session-1","offer":{"format":"SDP","value":"v=0"}}`,
+			handled: "initiate",
+			decoded: `{"sessionId":"session-1","offer":{"format":"SDP","value":"v=0"}}`,
+		},
+		"rtc session empty offer": {
+			handler: RTCSessionControllerHandler(handledBy[InitiateSessionWithOfferPayload]("initiate"),
+				handledBy[SessionPayload]("connected"), handledBy[SessionPayload]("disconnected")),
+			header:  Header{Namespace: NamespaceRTCSessionController, Name: "InitiateSessionWithOffer"},
+			payload: `{"sessionId":"session-1","offer":{"format":"SDP","value":""}}`,
+		},
+		"rtc session disconnected": {
+			handler: RTCSessionControllerHandler(handledBy[InitiateSessionWithOfferPayload]("initiate"),
+				handledBy[SessionPayload]("connected"), handledBy[SessionPayload]("disconnected")),
+			header:  Header{Namespace: NamespaceRTCSessionController, Name: "SessionDisconnected"},
+			payload: `{"sessionId":"session-1"}`,
+			handled: "disconnected",
+		},
+		"network access blocked": {
+			handler: AccessControllerHandler(handledBy[SetNetworkAccessPayload]("enable"), handledBy[SetNetworkAccessPayload]("disable")),
+			header:  Header{Namespace: NamespaceNetworkingAccessController, Name: "SetNetworkAccess"},
+			payload: `{"networkAccess":"BLOCKED","schedule":{"start":"2021-02-01T12:00:00Z","duration":"PT1H"}}`,
+			handled: "disable",
+			decoded: `{"networkAccess":"BLOCKED","schedule":{"start":"2021-02-01T12:00:00Z","duration":"PT1H"}}`,
+		},
+		"network access allowed": {
+			handler: AccessControllerHandler(handledBy[SetNetworkAccessPayload]("enable"), handledBy[SetNetworkAccessPayload]("disable")),
+			header:  Header{Namespace: NamespaceNetworkingAccessController, Name: "SetNetworkAccess"},
+			payload: `{"networkAccess":"ALLOWED"}`,
+			handled: "enable",
+		},
+		"network access schedule without start": {
+			handler: AccessControllerHandler(handledBy[SetNetworkAccessPayload]("enable"), handledBy[SetNetworkAccessPayload]("disable")),
+			header:  Header{Namespace: NamespaceNetworkingAccessController, Name: "SetNetworkAccess"},
+			payload: `{"networkAccess":"ALLOWED","schedule":{"duration":"PT1H"}}`,
+		},
+		"remote video search and play": {
+			handler: RemoteVideoPlayerHandler(handledBy[SearchAndPlayPayload]("play"), handledBy[SearchAndPlayPayload]("display")),
+			header:  Header{Namespace: NamespaceRemoteVideoPlayer, Name: "SearchAndPlay"},
+			payload: `{"entities":[{"type":"Actor","value":"Alan Rickman","externalIds":{"gracenote":"P000000099001"}},` +
+				`{"type":"Video","value":"Die Hard"}]}`,
+			handled: "play",
+		},
+		"remote video search without entities": {
+			handler: RemoteVideoPlayerHandler(handledBy[SearchAndPlayPayload]("play"), handledBy[SearchAndPlayPayload]("display")),
+			header:  Header{Namespace: NamespaceRemoteVideoPlayer, Name: "SearchAndDisplayResults"},
+			payload: `{"entities":[]}`,
+		},
+		"thermostat target": {
+			handler: ThermostatControllerHandler(handledBy[SetTargetTemperaturePayload]("target"),
+				handledBy[AdjustTargetTemperaturePayload]("adjust"), handledBy[SetThermostatModePayload]("mode"), nil),
+			header:  Header{Namespace: NamespaceThermostatController, Name: "SetTargetTemperature"},
+			payload: `{"targetSetpoint":{"value":21,"scale":"CELSIUS"}}`,
+			handled: "target",
+		},
+		"thermostat dual setpoints": {
+			handler: ThermostatControllerHandler(handledBy[SetTargetTemperaturePayload]("target"),
+				handledBy[AdjustTargetTemperaturePayload]("adjust"), handledBy[SetThermostatModePayload]("mode"), nil),
+			header:  Header{Namespace: NamespaceThermostatController, Name: "SetTargetTemperature"},
+			payload: `{"lowerSetpoint":{"value":68,"scale":"FAHRENHEIT"},"upperSetpoint":{"value":22,"scale":"CELSIUS"}}`,
+			handled: "target",
+		},
+		"thermostat setpoints crossed": {
+			handler: ThermostatControllerHandler(handledBy[SetTargetTemperaturePayload]("target"),
+				handledBy[AdjustTargetTemperaturePayload]("adjust"), handledBy[SetThermostatModePayload]("mode"), nil),
+			header:  Header{Namespace: NamespaceThermostatController, Name: "SetTargetTemperature"},
+			payload: `{"lowerSetpoint":{"value":74,"scale":"FAHRENHEIT"},"upperSetpoint":{"value":22,"scale":"CELSIUS"}}`,
+		},
+		"thermostat upper setpoint only": {
+			handler: ThermostatControllerHandler(handledBy[SetTargetTemperaturePayload]("target"),
+				handledBy[AdjustTargetTemperaturePayload]("adjust"), handledBy[SetThermostatModePayload]("mode"), nil),
+			header:  Header{Namespace: NamespaceThermostatController, Name: "SetTargetTemperature"},
+			payload: `{"upperSetpoint":{"value":22,"scale":"CELSIUS"}}`,
+		},
+		"thermostat no setpoint": {
+			handler: ThermostatControllerHandler(handledBy[SetTargetTemperaturePayload]("target"),
+				handledBy[AdjustTargetTemperaturePayload]("adjust"), handledBy[SetThermostatModePayload]("mode"), nil),
+			header: Header{Namespace: NamespaceThermostatController, Name: "SetTargetTemperature"},
+		},
+		"thermostat scheduled target": {
+			handler: ThermostatControllerHandler(handledBy[SetTargetTemperaturePayload]("target"),
+				handledBy[AdjustTargetTemperaturePayload]("adjust"), handledBy[SetThermostatModePayload]("mode"), nil),
+			header: Header{Namespace: NamespaceThermostatController, Name: "SetTargetTemperature"},
+			payload: `{"targetSetpoint":{"value":21,"scale":"CELSIUS"},` +
+				`"schedule":{"start":"2021-02-01T20:00:00Z","duration":"PT1H30M"}}`,
+			handled: "target",
+		},
+		"thermostat custom mode without name": {
+			handler: ThermostatControllerHandler(handledBy[SetTargetTemperaturePayload]("target"),
+				handledBy[AdjustTargetTemperaturePayload]("adjust"), handledBy[SetThermostatModePayload]("mode"), nil),
+			header:  Header{Namespace: NamespaceThermostatController, Name: "SetThermostatMode"},
+			payload: `{"thermostatMode":{"value":"CUSTOM"}}`,
+		},
+		"thermostat resume schedule unsupported": {
+			handler: ThermostatControllerHandler(handledBy[SetTargetTemperaturePayload]("target"),
+				handledBy[AdjustTargetTemperaturePayload]("adjust"), handledBy[SetThermostatModePayload]("mode"), nil),
+			header: Header{Namespace: NamespaceThermostatController, Name: "ResumeSchedule"},
+		},
+		"data list": {
+			handler: DataControllerHandler(handledBy[ListDataPayload]("list"), handledBy[DeleteDataPayload]("delete")),
+			header:  Header{Namespace: NamespaceDataController, Name: "ListData", Instance: "Camera.Clips"},
+			payload: `{"paginationContext":{"maximumPageSize":2}}`,
+			handled: "list",
+		},
+		"data delete": {
+			handler: DataControllerHandler(handledBy[ListDataPayload]("list"), handledBy[DeleteDataPayload]("delete")),
+			header:  Header{Namespace: NamespaceDataController, Name: "DeleteData", Instance: "Camera.Clips"},
+			payload: `{"dataIds":["clip-1","clip-2"]}`,
+			handled: "delete",
+			decoded: `{"dataIds":["clip-1","clip-2"]}`,
+		},
+		"color set": {
+			handler: ColorControllerHandler(handledBy[SetColorPayload]("set")),
+			header:  Header{Namespace: NamespaceColorController, Name: "SetColor"},
+			payload: `{"color":{"hue":350.5,"saturation":0.7138,"brightness":0.6524}}`,
+			handled: "set",
+			decoded: `{"color":{"hue":350.5,"saturation":0.7138,"brightness":0.6524}}`,
+		},
+		"color hue out of range": {
+			handler:    ColorControllerHandler(handledBy[SetColorPayload]("set")),
+			header:     Header{Namespace: NamespaceColorController, Name: "SetColor"},
+			payload:    `{"color":{"hue":400,"saturation":0.5,"brightness":0.5}}`,
+			outOfRange: true,
+		},
+		"speaker adjust volume": {
+			handler: SpeakerHandler(handledBy[SetVolumePayload]("set volume"), handledBy[AdjustVolumePayload]("adjust volume"),
+				handledBy[SetMutePayload]("mute")),
+			header:  Header{Namespace: NamespaceSpeaker, Name: "AdjustVolume"},
+			payload: `{"volume":-20,"volumeDefault":false}`,
+			handled: "adjust volume",
+			decoded: `{"volume":-20,"volumeDefault":false}`,
+		},
+		"speaker volume out of range": {
+			handler: SpeakerHandler(handledBy[SetVolumePayload]("set volume"), handledBy[AdjustVolumePayload]("adjust volume"),
+				handledBy[SetMutePayload]("mute")),
+			header:     Header{Namespace: NamespaceSpeaker, Name: "SetVolume"},
+			payload:    `{"volume":120}`,
+			outOfRange: true,
+		},
+		"channel change": {
+			handler: ChannelControllerHandler(handledBy[ChangeChannelPayload]("change"), handledBy[SkipChannelsPayload]("skip")),
+			header:  Header{Namespace: NamespaceChannelController, Name: "ChangeChannel"},
+			payload: `{"channel":{"number":"1234","callSign":"KSTATION1"},"channelMetadata":{"name":"Alternate Channel Name"}}`,
+			handled: "change",
+		},
+		"channel without identity": {
+			handler: ChannelControllerHandler(handledBy[ChangeChannelPayload]("change"), handledBy[SkipChannelsPayload]("skip")),
+			header:  Header{Namespace: NamespaceChannelController, Name: "ChangeChannel"},
+			payload: `{"channel":{},"channelMetadata":{}}`,
+		},
+		"playback play": {
+			handler: PlaybackControllerHandler(map[string]Handler{
+				PlaybackOperationPlay:  handledBy[struct{}]("play"),
+				PlaybackOperationPause: handledBy[struct{}]("pause"),
+			}),
+			header:  Header{Namespace: NamespacePlaybackController, Name: "Play"},
+			handled: "play",
+		},
+		"playback unsupported operation": {
+			handler: PlaybackControllerHandler(map[string]Handler{
+				PlaybackOperationPlay:  handledBy[struct{}]("play"),
+				PlaybackOperationPause: handledBy[struct{}]("pause"),
+			}),
+			header: Header{Namespace: NamespacePlaybackController, Name: "Rewind"},
+		},
+		"playback operation without handler": {
+			handler: PlaybackControllerHandler(map[string]Handler{
+				PlaybackOperationPlay:  handledBy[struct{}]("play"),
+				PlaybackOperationPause: nil,
+			}),
+			header: Header{Namespace: NamespacePlaybackController, Name: "Pause"},
+		},
+		"equalizer adjust bands": {
+			handler: EqualizerControllerHandler(handledBy[SetBandsPayload]("set bands"), handledBy[AdjustBandsPayload]("adjust bands"),
+				handledBy[ResetBandsPayload]("reset bands"), nil),
+			header:  Header{Namespace: NamespaceEqualizerController, Name: "AdjustBands"},
+			payload: `{"bands":[{"name":"BASS","levelDelta":3,"levelDirection":"DOWN"}]}`,
+			handled: "adjust bands",
+		},
+		"equalizer unknown level direction": {
+			handler: EqualizerControllerHandler(handledBy[SetBandsPayload]("set bands"), handledBy[AdjustBandsPayload]("adjust bands"),
+				handledBy[ResetBandsPayload]("reset bands"), nil),
+			header:  Header{Namespace: NamespaceEqualizerController, Name: "AdjustBands"},
+			payload: `{"bands":[{"name":"BASS","levelDelta":3,"levelDirection":"SIDEWAYS"}]}`,
+		},
+		"equalizer mode unsupported": {
+			handler: EqualizerControllerHandler(handledBy[SetBandsPayload]("set bands"), handledBy[AdjustBandsPayload]("adjust bands"),
+				handledBy[ResetBandsPayload]("reset bands"), nil),
+			header:  Header{Namespace: NamespaceEqualizerController, Name: "SetMode"},
+			payload: `{"mode":"MOVIE"}`,
+		},
+		"time hold": {
+			handler: TimeHoldControllerHandler(handledBy[struct{}]("hold"), nil),
+			header:  Header{Namespace: NamespaceTimeHoldController, Name: "Hold"},
+			handled: "hold",
+		},
+		"time hold remote resume unsupported": {
+			handler: TimeHoldControllerHandler(handledBy[struct{}]("hold"), nil),
+			header:  Header{Namespace: NamespaceTimeHoldController, Name: "Resume"},
+		},
+		"keypad keystroke": {
+			handler: KeypadControllerHandler(handledBy[SendKeystrokePayload]("keystroke")),
+			header:  Header{Namespace: NamespaceKeypadController, Name: "SendKeystroke"},
+			payload: `{"keystroke":"PAGE_UP"}`,
+			handled: "keystroke",
+		},
+		"keypad unknown keystroke": {
+			handler: KeypadControllerHandler(handledBy[SendKeystrokePayload]("keystroke")),
+			header:  Header{Namespace: NamespaceKeypadController, Name: "SendKeystroke"},
+			payload: `{"keystroke":"ENTER"}`,
+		},
+		"media metadata get": {
+			handler: MediaMetadataHandler(handledBy[GetMediaMetadataPayload]("get")),
+			header:  Header{Namespace: NamespaceMediaMetadata, Name: "GetMediaMetadata"},
+			payload: `{"filters":{"mediaIds":["clip-1"]}}`,
+			handled: "get",
+			decoded: `{"filters":{"mediaIds":["clip-1"]}}`,
+		},
+		"media metadata without media ids": {
+			handler: MediaMetadataHandler(handledBy[GetMediaMetadataPayload]("get")),
+			header:  Header{Namespace: NamespaceMediaMetadata, Name: "GetMediaMetadata"},
+			payload: `{"filters":{"mediaIds":[]}}`,
+		},
+		"video recorder search and record": {
+			handler: VideoRecorderHandler(handledBy[SearchAndRecordPayload]("record"), nil, nil),
+			header:  Header{Namespace: NamespaceVideoRecorder, Name: "SearchAndRecord"},
+			payload: `{"entities":[{"type":"Video","value":"Breaking Bad"}],"quantifier":{"name":"NEW"}}`,
+			handled: "record",
+		},
+		"video recorder search without entities": {
+			handler: VideoRecorderHandler(handledBy[SearchAndRecordPayload]("record"), nil, nil),
+			header:  Header{Namespace: NamespaceVideoRecorder, Name: "SearchAndRecord"},
+			payload: `{"entities":[]}`,
+		},
+		"video recorder cancel unsupported": {
+			handler: VideoRecorderHandler(handledBy[SearchAndRecordPayload]("record"), nil, nil),
+			header:  Header{Namespace: NamespaceVideoRecorder, Name: "CancelRecording"},
+		},
+		"record start": {
+			handler: RecordControllerHandler(handledBy[struct{}]("start"), handledBy[struct{}]("stop")),
+			header:  Header{Namespace: NamespaceRecordController, Name: "StartRecording"},
+			handled: "start",
+		},
+		"record unknown directive": {
+			handler: RecordControllerHandler(handledBy[struct{}]("start"), handledBy[struct{}]("stop")),
+			header:  Header{Namespace: NamespaceRecordController, Name: "PauseRecording"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			payload := test.payload
+			if payload == "" {
+				payload = "{}"
+			}
+			req := &Request{Directive: RequestDirective{
+				Header:   test.header,
+				Endpoint: RequestEndpoint{EndpointID: "endpoint-1"},
+				Payload:  json.RawMessage(payload),
+			}}
+
+			resp, err := test.handler(context.Background(), req)
+			if test.outOfRange {
+				var rangeErr *ValueOutOfRangeError
+				if !errors.As(err, &rangeErr) {
+					t.Fatalf("expected out of range error, got %v", err)
+				}
+				return
+			}
+			if test.handled == "" {
+				if err == nil {
+					t.Fatalf("expected the directive to be rejected, got %s", resp.Event.Header.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to handle request: %v", err)
+			}
+			if resp.Event.Header.Name != test.handled {
+				t.Errorf("expected %s to handle the directive, got %s", test.handled, resp.Event.Header.Name)
+			}
+			if test.decoded != "" && string(resp.Event.Payload) != test.decoded {
+				t.Errorf("expected decoded payload:\n%s\ngot:\n%s", test.decoded, resp.Event.Payload)
+			}
+		})
+	}
+}
+
+func TestPlaybackOperations(t *testing.T) {
+	handlers := map[string]Handler{
+		PlaybackOperationStop:  handledBy[struct{}]("stop"),
+		PlaybackOperationPlay:  handledBy[struct{}]("play"),
+		PlaybackOperationPause: nil,
+	}
+	if operations := fmt.Sprint(PlaybackOperations(handlers)); operations != "[Play Stop]" {
+		t.Errorf("unexpected operations %s", operations)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for unknown operation")
+		}
+	}()
+	handlers["Shuffle"] = handledBy[struct{}]("shuffle")
+	PlaybackControllerHandler(handlers)
+}
+
+func TestControllerResponses(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	sampled := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	req := func(namespace, name string) *Request {
+		return &Request{Directive: RequestDirective{
+			Header:   Header{Namespace: namespace, Name: name, CorrelationToken: "corr"},
+			Endpoint: RequestEndpoint{EndpointID: "endpoint-1"},
+		}}
+	}
+	stream := CameraStreamRequest{
+		Protocol:          ProtocolHLS,
+		Resolution:        Resolution{1280, 720},
+		AuthorizationType: AuthorizationTypeBasic,
+		VideoCodec:        VideoCodecH264,
+		AudioCodec:        AudioCodecAAC,
+	}
+
+	tests := map[string]struct {
+		build     func() (*Response, error)
+		namespace string
+		name      string
+		// payload is the expected payload, empty if building the response fails
+		payload string
+	}{
+		"arm": {
+			build: func() (*Response, error) {
+				armState, err := ArmStateProperty(ArmStateArmedAway, sampled)
+				if err != nil {
+					return nil, err
+				}
+				return rb.ArmResponse(req(NamespaceSecurityPanelController, "Arm"), 90*time.Second, armState)
+			},
+			namespace: NamespaceSecurityPanelController,
+			name:      "Arm.Response",
+			payload:   `{"exitDelayInSeconds":90}`,
+		},
+		"arm exit delay too long": {
+			build: func() (*Response, error) {
+				return rb.ArmResponse(req(NamespaceSecurityPanelController, "Arm"), 5*time.Minute)
+			},
+		},
+		"bypass needed": {
+			build: func() (*Response, error) {
+				return rb.BypassNeededErrorResponse(req(NamespaceSecurityPanelController, "Arm"), "window open", "window-1")
+			},
+			namespace: NamespaceSecurityPanelController,
+			name:      "ErrorResponse",
+			payload:   `{"type":"BYPASS_NEEDED","message":"window open","endpoints":[{"endpointId":"window-1"}]}`,
+		},
+		"schedule request failed": {
+			build: func() (*Response, error) {
+				return rb.ScheduleRequestFailedErrorResponse(req(NamespaceThermostatController, "SetTargetTemperature"),
+					"hold rejected")
+			},
+			namespace: NamespaceThermostatController,
+			name:      "ErrorResponse",
+			payload:   `{"type":"SCHEDULE_REQUEST_FAILED","message":"hold rejected"}`,
+		},
+		"rtc answer": {
+			build: func() (*Response, error) {
+				return rb.AnswerGeneratedForSessionResponse(req(NamespaceRTCSessionController, "InitiateSessionWithOffer"), "v=0")
+			},
+			namespace: NamespaceRTCSessionController,
+			name:      EventAnswerGeneratedForSession,
+			payload:   `{"answer":{"format":"SDP","value":"v=0"}}`,
+		},
+		"rtc disconnected": {
+			build: func() (*Response, error) {
+				return rb.SessionDisconnectedResponse(req(NamespaceRTCSessionController, "SessionDisconnected"), "session-1")
+			},
+			namespace: NamespaceRTCSessionController,
+			name:      EventSessionDisconnected,
+			payload:   `{"sessionId":"session-1"}`,
+		},
+		"camera streams": {
+			build: func() (*Response, error) {
+				return rb.CameraStreamsResponse(req(NamespaceCameraStreamController, "InitializeCameraStreams"), CameraStreamsPayload{
+					CameraStreams: []CameraStream{NewCameraStream("https://camera/stream.m3u8", stream, sampled)},
+				})
+			},
+			namespace: NamespaceCameraStreamController,
+			name:      "Response",
+			payload: `{"cameraStreams":[{"uri":"https://camera/stream.m3u8","expirationTime":"2021-02-01T12:00:00Z",` +
+				`"protocol":"HLS","resolution":{"width":1280,"height":720},"authorizationType":"BASIC",` +
+				`"videoCodec":"H264","audioCodec":"AAC"}]}`,
+		},
+		"camera streams uri mismatch": {
+			build: func() (*Response, error) {
+				return rb.CameraStreamsResponse(req(NamespaceCameraStreamController, "InitializeCameraStreams"), CameraStreamsPayload{
+					CameraStreams: []CameraStream{NewCameraStream("rtsp://camera/stream", stream, sampled)},
+				})
+			},
+		},
+		"data report": {
+			build: func() (*Response, error) {
+				r := req(NamespaceDataController, "ListData")
+				r.Directive.Header.Instance = "Camera.Clips"
+				return rb.DataReportResponse(r, DataReportPayload{
+					DataRecords:       []json.RawMessage{json.RawMessage(`{"id":"clip-1"}`)},
+					PaginationContext: &DataPaginationContext{Token: "next"},
+				})
+			},
+			namespace: NamespaceDataController,
+			name:      "DataReport",
+			payload:   `{"dataRecords":[{"id":"clip-1"}],"paginationContext":{"token":"next"}}`,
+		},
+		"delete data": {
+			build: func() (*Response, error) {
+				return rb.DeleteDataResponse(req(NamespaceDataController, "DeleteData"), DeleteDataResponsePayload{SuccessCount: 2})
+			},
+			namespace: NamespaceDataController,
+			name:      "DeleteDataResponse",
+			payload:   `{"successCount":2,"failureCount":0}`,
+		},
+		"media metadata": {
+			build: func() (*Response, error) {
+				return rb.GetMediaMetadataResponse(req(NamespaceMediaMetadata, "GetMediaMetadata"), GetMediaMetadataResponsePayload{
+					Errors: []MediaError{{MediaID: "clip-1", Status: MediaErrorStatusNotFound}},
+				})
+			},
+			namespace: NamespaceMediaMetadata,
+			name:      EventGetMediaMetadataResponse,
+			payload:   `{"media":[],"errors":[{"mediaId":"clip-1","status":"MEDIA_NOT_FOUND"}]}`,
+		},
+		"media metadata with scan directions": {
+			build: func() (*Response, error) {
+				return rb.GetMediaMetadataResponse(req(NamespaceMediaMetadata, "GetMediaMetadata"), GetMediaMetadataResponsePayload{
+					Media: []Media{{ID: "clip-1", Cause: MediaCausePersonDetected, Recording: Recording{
+						StartTime:    sampled,
+						EndTime:      sampled.Add(time.Minute),
+						URI:          MediaURI{Value: "https://camera/clip-1.mp4", ExpireTime: sampled.Add(time.Hour)},
+						ThumbnailURI: &MediaURI{Value: "https://camera/clip-1.jpg", ExpireTime: sampled.Add(time.Hour)},
+					}}},
+					ScanDirections: []string{ScanDirectionBackward},
+				})
+			},
+			namespace: NamespaceMediaMetadata,
+			name:      EventGetMediaMetadataResponse,
+			payload: `{"media":[{"id":"clip-1","cause":"PERSON_DETECTED","recording":{"startTime":"2021-02-01T12:00:00Z",` +
+				`"endTime":"2021-02-01T12:01:00Z","uri":{"value":"https://camera/clip-1.mp4","expireTime":"2021-02-01T13:00:00Z"},` +
+				`"thumbnailUri":{"value":"https://camera/clip-1.jpg","expireTime":"2021-02-01T13:00:00Z"}}}],` +
+				`"scanDirections":["BACKWARD"]}`,
+		},
+		"media metadata unknown scan direction": {
+			build: func() (*Response, error) {
+				return rb.GetMediaMetadataResponse(req(NamespaceMediaMetadata, "GetMediaMetadata"), GetMediaMetadataResponsePayload{
+					ScanDirections: []string{"SIDEWAYS"},
+				})
+			},
+		},
+		"search and record": {
+			build: func() (*Response, error) {
+				storage := 40
+				return rb.SearchAndRecordResponse(req(NamespaceVideoRecorder, "SearchAndRecord"), SearchAndRecordResponsePayload{
+					RecordingStatus: RecordingStatusScheduled,
+					StorageLevel:    &storage,
+				})
+			},
+			namespace: NamespaceVideoRecorder,
+			name:      EventSearchAndRecordResponse,
+			payload:   `{"recordingStatus":"SCHEDULED","storageLevel":40}`,
+		},
+		"search and record storage out of range": {
+			build: func() (*Response, error) {
+				storage := 120
+				return rb.SearchAndRecordResponse(req(NamespaceVideoRecorder, "SearchAndRecord"), SearchAndRecordResponsePayload{
+					RecordingStatus: RecordingStatusScheduled,
+					StorageLevel:    &storage,
+				})
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := test.build()
+			if test.payload == "" {
+				if err == nil {
+					t.Fatalf("expected error, got %s", resp.Event.Payload)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to build response: %v", err)
+			}
+			header := resp.Event.Header
+			if header.Namespace != test.namespace || header.Name != test.name || header.CorrelationToken != "corr" {
+				t.Errorf("unexpected header %+v", header)
+			}
+			if string(resp.Event.Payload) != test.payload {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.payload, resp.Event.Payload)
+			}
+		})
+	}
+}
+
+func TestProactiveEvents(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	scope := Scope{Type: "BearerToken", Token: "token"}
+	start := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		event     func() (*Response, error)
+		namespace string
+		name      string
+		instance  string
+		// payload is the expected payload, empty if creating the event fails
+		payload string
+	}{
+		"measurements report": {
+			event: func() (*Response, error) {
+				return rb.MeasurementsReport(scope, NamespaceDeviceUsageMeter, "endpoint-1", Measurement{
+					Type:      MeasurementTypeElectricEnergy,
+					Unit:      MeasurementUnitKilowattHours,
+					Value:     0.5,
+					StartTime: start,
+					EndTime:   start.Add(time.Hour),
+				})
+			},
+			namespace: NamespaceDeviceUsageMeter,
+			name:      EventMeasurementsReport,
+			payload: `{"measurements":[{"type":"ELECTRIC_ENERGY","unit":"KILOWATT_HOURS","value":0.5,` +
+				`"startTime":"2021-02-01T12:00:00Z","endTime":"2021-02-01T13:00:00Z"}]}`,
+		},
+		"inventory consumed": {
+			event: func() (*Response, error) {
+				return rb.InventoryConsumedEvent(scope, "endpoint-1", "Printer.Ink",
+					InventoryMeasurement{Type: InventoryMeasurementPercentage, Value: 40}, start)
+			},
+			namespace: NamespaceInventoryUsageSensor,
+			name:      EventInventoryConsumed,
+			instance:  "Printer.Ink",
+			payload:   `{"usage":{"@type":"Percentage","value":40},"timeOfSample":"2021-02-01T12:00:00Z"}`,
+		},
+		"media created": {
+			event: func() (*Response, error) {
+				return rb.MediaCreatedOrUpdatedEvent(scope, "endpoint-1", Media{ID: "clip-1", Cause: MediaCauseMotionDetected,
+					Recording: Recording{StartTime: start, EndTime: start.Add(time.Minute),
+						URI: MediaURI{Value: "https://example.com/clip-1", ExpireTime: start.Add(time.Hour)}}})
+			},
+			namespace: NamespaceMediaMetadata,
+			name:      EventMediaCreatedOrUpdated,
+			payload: `{"media":{"id":"clip-1","cause":"MOTION_DETECTED","recording":` +
+				`{"startTime":"2021-02-01T12:00:00Z","endTime":"2021-02-01T12:01:00Z",` +
+				`"uri":{"value":"https://example.com/clip-1","expireTime":"2021-02-01T13:00:00Z"}}}}`,
+		},
+		"doorbell press": {
+			event: func() (*Response, error) {
+				return rb.DoorbellPressEvent(scope, "endpoint-1", CausePhysicalInteraction,
+					start.In(time.FixedZone("PST", -8*3600)))
+			},
+			namespace: NamespaceDoorbellEventSource,
+			name:      EventDoorbellPress,
+			payload:   `{"cause":{"type":"PHYSICAL_INTERACTION"},"timestamp":"2021-02-01T12:00:00Z"}`,
+		},
+		"button press": {
+			event: func() (*Response, error) {
+				return rb.ButtonPressEvent(scope, "endpoint-1", PressTypeDoublePress, start)
+			},
+			namespace: NamespaceSimpleEventSource,
+			name:      EventSimpleEvent,
+			payload: `{"event":{"eventName":"buttonPress","pressType":"DOUBLE_PRESS"},` +
+				`"cause":{"type":"PHYSICAL_INTERACTION"},"timestamp":"2021-02-01T12:00:00Z"}`,
+		},
+		"simple event without a name": {
+			event: func() (*Response, error) {
+				return rb.SimpleEvent(scope, "endpoint-1", SimpleEvent{}, start)
+			},
+		},
+		"inventory consumed without unit": {
+			event: func() (*Response, error) {
+				return rb.InventoryConsumedEvent(scope, "endpoint-1", "Printer.Ink",
+					InventoryMeasurement{Type: InventoryMeasurementVolume, Value: 40}, start)
+			},
+		},
+		"meter measurements report": {
+			event: func() (*Response, error) {
+				return rb.MeterMeasurementsReport(scope, "endpoint-1", Measurement{
+					Type:      MeasurementTypeElectricEnergy,
+					Unit:      MeasurementUnitKilowattHours,
+					Value:     1.25,
+					StartTime: start,
+					EndTime:   start.Add(15 * time.Minute),
+				})
+			},
+			namespace: NamespaceDeviceUsageMeter,
+			name:      EventMeasurementsReport,
+			payload: `{"measurements":[{"type":"ELECTRIC_ENERGY","unit":"KILOWATT_HOURS","value":1.25,` +
+				`"startTime":"2021-02-01T12:00:00Z","endTime":"2021-02-01T12:15:00Z"}]}`,
+		},
+		"meter measurement ending before it starts": {
+			event: func() (*Response, error) {
+				return rb.MeterMeasurementsReport(scope, "endpoint-1", Measurement{
+					Type:      MeasurementTypeElectricEnergy,
+					Unit:      MeasurementUnitKilowattHours,
+					Value:     1.25,
+					StartTime: start,
+					EndTime:   start.Add(-time.Minute),
+				})
+			},
+		},
+		"human presence report": {
+			event: func() (*Response, error) {
+				return rb.HumanPresenceReport(scope, "endpoint-1", start, "clip-1", DetectionMethodVideo)
+			},
+			namespace: NamespaceAlexa,
+			name:      "ChangeReport",
+			payload: `{"change":{"cause":{"type":"PHYSICAL_INTERACTION"},"properties":[{"namespace":"Alexa.EventDetectionSensor",` +
+				`"name":"humanPresenceDetectionState","value":{"value":"DETECTED","detectionMethods":["VIDEO"],` +
+				`"media":{"type":"ALEXA.MEDIAMETADATA","id":"clip-1"}},"timeOfSample":"2021-02-01T12:00:00Z",` +
+				`"uncertaintyInMilliseconds":0}]}}`,
+		},
+		"contact change report": {
+			event: func() (*Response, error) {
+				contact, err := ContactSensorProperty(true, start)
+				if err != nil {
+					return nil, err
+				}
+				return rb.ChangeReport(scope, "endpoint-1", CausePhysicalInteraction, []ContextProperty{contact})
+			},
+			namespace: NamespaceAlexa,
+			name:      "ChangeReport",
+			payload: `{"change":{"cause":{"type":"PHYSICAL_INTERACTION"},"properties":[{"namespace":"Alexa.ContactSensor",` +
+				`"name":"detectionState","value":"DETECTED","timeOfSample":"2021-02-01T12:00:00Z","uncertaintyInMilliseconds":0}]}}`,
+		},
+		"change report without properties": {
+			event: func() (*Response, error) {
+				return rb.ChangeReport(scope, "endpoint-1", CausePhysicalInteraction, nil)
+			},
+		},
+		"motion change report": {
+			event: func() (*Response, error) {
+				motion, err := MotionSensorProperty(true, start)
+				if err != nil {
+					return nil, err
+				}
+				return rb.ChangeReport(scope, "endpoint-1", CausePhysicalInteraction, []ContextProperty{motion})
+			},
+			namespace: NamespaceAlexa,
+			name:      "ChangeReport",
+			payload: `{"change":{"cause":{"type":"PHYSICAL_INTERACTION"},"properties":[{"namespace":"Alexa.MotionSensor",` +
+				`"name":"detectionState","value":"DETECTED","timeOfSample":"2021-02-01T12:00:00Z","uncertaintyInMilliseconds":0}]}}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			event, err := test.event()
+			if test.payload == "" {
+				if err == nil {
+					t.Fatalf("expected error, got %s", event.Event.Payload)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to create event: %v", err)
+			}
+			header := event.Event.Header
+			if header.Namespace != test.namespace || header.Name != test.name || header.Instance != test.instance ||
+				header.MessageID != "msg-1" || header.CorrelationToken != "" {
+				t.Errorf("unexpected header %+v", header)
+			}
+			if event.Event.Endpoint.EndpointID != "endpoint-1" || event.Event.Endpoint.Scope != scope {
+				t.Errorf("unexpected endpoint %+v", event.Event.Endpoint)
+			}
+			if string(event.Event.Payload) != test.payload {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.payload, event.Event.Payload)
+			}
+		})
+	}
+}
+
+func TestControllerProperties(t *testing.T) {
+	sampled := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		property  func() (ContextProperty, error)
+		namespace string
+		name      string
+		// value is the expected value, empty if creating the property fails
+		value string
+	}{
+		"playback state": {
+			property:  func() (ContextProperty, error) { return PlaybackStateProperty(PlaybackStatePlaying, sampled) },
+			namespace: NamespacePlaybackStateReporter,
+			name:      PropertyPlaybackState,
+			value:     `{"state":"PLAYING"}`,
+		},
+		"unknown playback state": {
+			property: func() (ContextProperty, error) { return PlaybackStateProperty("BUFFERING", sampled) },
+		},
+		"contact open": {
+			property:  func() (ContextProperty, error) { return ContactSensorProperty(true, sampled) },
+			namespace: NamespaceContactSensor,
+			name:      PropertyDetectionState,
+			value:     `"DETECTED"`,
+		},
+		"contact closed": {
+			property:  func() (ContextProperty, error) { return ContactSensorProperty(false, sampled) },
+			namespace: NamespaceContactSensor,
+			name:      PropertyDetectionState,
+			value:     `"NOT_DETECTED"`,
+		},
+		"inventory level": {
+			property: func() (ContextProperty, error) {
+				return LevelProperty("Water.Tank", InventoryMeasurement{Type: InventoryMeasurementVolume, Value: 1.5,
+					Unit: UnitLiter}, sampled)
+			},
+			namespace: NamespaceInventoryLevelSensor,
+			name:      PropertyLevel,
+			value:     `{"@type":"Volume","value":1.5,"unit":"LITER"}`,
+		},
+		"inventory level out of range": {
+			property: func() (ContextProperty, error) {
+				return LevelProperty("Printer.Ink", InventoryMeasurement{Type: InventoryMeasurementPercentage, Value: 140}, sampled)
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			prop, err := test.property()
+			if test.value == "" {
+				if err == nil {
+					t.Fatalf("expected error, got %s", prop.Value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to create property: %v", err)
+			}
+			if prop.Namespace != test.namespace || prop.Name != test.name || string(prop.Value) != test.value {
+				t.Errorf("unexpected property %s.%s %s", prop.Namespace, prop.Name, prop.Value)
+			}
+		})
+	}
+}
+
+func TestControllerCapabilities(t *testing.T) {
+	detection := EventDetectionConfiguration{
+		DetectionMethods: []string{DetectionMethodVideo},
+		DetectionModes: DetectionModes{HumanPresence: DetectionMode{
+			FeatureAvailability: FeatureAvailabilityEnabled,
+			SupportsNotDetected: false,
+		}},
+	}
+	estimation := EstimationConfiguration{PowerProfile: PowerProfileConfiguration{
+		Type:           PowerProfileTypeBrightness,
+		StandbyWattage: Watts(0.5),
+		MaximumWattage: Watts(9),
+	}}
+
+	tests := map[string]struct {
+		capability func() (DiscoverCapability, error)
+		iface      string
+		// configuration is the expected configuration, empty if creating the capability fails
+		configuration string
+	}{
+		"event detection": {
+			capability: func() (DiscoverCapability, error) { return NewEventDetectionSensorCapability(detection) },
+			iface:      InterfaceEventDetectionSensor,
+			configuration: `{"detectionMethods":["VIDEO"],"detectionModes":{"humanPresence":` +
+				`{"featureAvailability":"ENABLED","supportsNotDetected":false}}}`,
+		},
+		"event detection unknown method": {
+			capability: func() (DiscoverCapability, error) {
+				config := detection
+				config.DetectionMethods = []string{"THERMAL"}
+				return NewEventDetectionSensorCapability(config)
+			},
+		},
+		"estimation": {
+			capability: func() (DiscoverCapability, error) { return NewEstimationCapability(estimation) },
+			iface:      InterfaceDeviceUsageEstimation,
+			configuration: `{"powerProfile":{"type":"BRIGHTNESS","standbyWattage":{"value":0.5,"units":"WATTS"},` +
+				`"maximumWattage":{"value":9,"units":"WATTS"}}}`,
+		},
+		"estimation standby above maximum": {
+			capability: func() (DiscoverCapability, error) {
+				config := estimation
+				config.PowerProfile.StandbyWattage = Watts(12)
+				return NewEstimationCapability(config)
+			},
+		},
+		"estimation unknown profile": {
+			capability: func() (DiscoverCapability, error) {
+				config := estimation
+				config.PowerProfile.Type = "TEMPERATURE"
+				return NewEstimationCapability(config)
+			},
+		},
+		"meter": {
+			capability: func() (DiscoverCapability, error) {
+				return NewMeterCapability(ElectricityMeterConfiguration(15 * time.Minute))
+			},
+			iface: InterfaceDeviceUsageMeter,
+			configuration: `{"energySources":{"electricity":{"measuringMethod":"DIRECT","unit":"KILOWATT_HOURS",` +
+				`"defaultMeasurementResolution":"PT15M"}}}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			capability, err := test.capability()
+			if test.configuration == "" {
+				if err == nil {
+					t.Fatalf("expected error, got %s", capability.Configuration)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to create capability: %v", err)
+			}
+			if capability.Interface != test.iface || string(capability.Configuration) != test.configuration {
+				t.Errorf("unexpected capability %s %s", capability.Interface, capability.Configuration)
+			}
+		})
+	}
+}
+
+func TestSemanticsJSON(t *testing.T) {
+	open, err := ActionsToDirective("TurnOn", struct{}{}, ActionOpen)
+	if err != nil {
+		t.Fatalf("failed to create action mapping: %v", err)
+	}
+	opened, err := StatesToValue(ToggleStateOn, StateOpen)
+	if err != nil {
+		t.Fatalf("failed to create state mapping: %v", err)
+	}
+	semantics := Semantics{
+		ActionMappings: []ActionMapping{open},
+		StateMappings:  []StateMapping{opened},
+	}
+
+	data, err := json.Marshal(semantics)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	expected := `{"actionMappings":[{"@type":"ActionsToDirective","actions":["Alexa.Actions.Open"],` +
+		`"directive":{"name":"TurnOn","payload":{}}}],` +
+		`"stateMappings":[{"@type":"StatesToValue","states":["Alexa.States.Open"],"value":"ON"}]}`
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+}
+
+func TestColorTemperatureStep(t *testing.T) {
+	for _, tc := range []struct {
+		kelvin   int
+		increase bool
+		expected int
+	}{
+		{2200, true, 2700},
+		{3000, true, 4000},
+		{7000, true, 7000},
+		{9000, true, 9000},
+		{1500, true, 2200},
+		{4000, false, 2700},
+		{3000, false, 2700},
+		{2200, false, 2200},
+		{1500, false, 1500},
+		{9000, false, 7000},
+	} {
+		if got := ColorTemperatureStep(tc.kelvin, tc.increase); got != tc.expected {
+			t.Errorf("step from %d (increase=%v): expected %d, got %d", tc.kelvin, tc.increase, tc.expected, got)
+		}
+	}
+
+	prop, err := ColorTemperatureProperty(4000, time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create property: %v", err)
+	}
+	if prop.Namespace != NamespaceColorTemperatureController || string(prop.Value) != "4000" {
+		t.Errorf("unexpected property: %+v", prop)
+	}
+}
+
+func TestModeControllerConfigurationAdjust(t *testing.T) {
+	config := ModeControllerConfiguration{
+		Ordered: true,
+		SupportedModes: []SupportedMode{
+			{Value: "Speed.Low"}, {Value: "Speed.Medium"}, {Value: "Speed.High"},
+		},
+	}
+
+	for _, test := range []struct {
+		current  string
+		delta    int
+		expected string
+	}{
+		{"Speed.Low", 1, "Speed.Medium"},
+		{"Speed.Medium", -1, "Speed.Low"},
+		{"Speed.Medium", 5, "Speed.High"},
+		{"Speed.Low", -2, "Speed.Low"},
+	} {
+		got, err := config.Adjust(test.current, test.delta)
+		if err != nil {
+			t.Fatalf("failed to adjust %s: %v", test.current, err)
+		}
+		if got != test.expected {
+			t.Errorf("%s%+d: expected %s, got %s", test.current, test.delta, test.expected, got)
+		}
+	}
+
+	if _, err := config.Adjust("Speed.Turbo", 1); err == nil {
+		t.Error("expected error for unsupported mode")
+	}
+	config.Ordered = false
+	if _, err := config.Adjust("Speed.Low", 1); err == nil {
+		t.Error("expected error for unordered modes")
+	}
+}
+
+func TestTemperatureValue(t *testing.T) {
+	celsius := TemperatureValue{Value: 68, Scale: TemperatureScaleFahrenheit}.In(TemperatureScaleCelsius)
+	if celsius.Value != 20 || celsius.Scale != TemperatureScaleCelsius {
+		t.Errorf("expected 20 CELSIUS, got %+v", celsius)
+	}
+	delta := TemperatureValue{Value: 2, Scale: TemperatureScaleCelsius}.DeltaIn(TemperatureScaleFahrenheit)
+	if delta.Value != 3.6 {
+		t.Errorf("expected delta of 3.6, got %v", delta.Value)
+	}
+}
+
+func TestThermostatSchedule(t *testing.T) {
+	var payload SetTargetTemperaturePayload
+	if err := json.Unmarshal([]byte(`{"targetSetpoint":{"value":21,"scale":"CELSIUS"},
+		"schedule":{"start":"2021-02-01T20:00:00Z","duration":"PT1H30M"}}`), &payload); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if err := payload.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start, end, err := payload.Schedule.Window()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if end.Sub(start) != 90*time.Minute {
+		t.Errorf("expected 90m window, got %v", end.Sub(start))
+	}
+
+	if schedule := NewThermostatSchedule(start, 26*time.Hour+30*time.Second); schedule.Duration != "PT26H30S" {
+		t.Errorf("unexpected duration %s", schedule.Duration)
+	}
+	for _, invalid := range []string{"", "P", "PT", "1H", "PT1X"} {
+		if _, err := parseISODuration(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestEndpointHealthHandler(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	connectivity := EndpointConnectivity(func(ctx context.Context, endpointID string) (string, error) {
+		if endpointID == "broken" {
+			return "", errors.New("lookup failed")
+		}
+		return ConnectivityUnreachable, nil
+	})
+	power, err := NewProperty(NamespacePowerController, "powerState", "ON", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create property: %v", err)
+	}
+	reachable, err := EndpointHealthProperty(ConnectivityOK, "ignored", time.Time{})
+	if err != nil {
+		t.Fatalf("failed to create property: %v", err)
+	}
+
+	tests := map[string]struct {
+		endpointID string
+		respond    func(req *Request) (*Response, error)
+		// properties are the expected property values, nil if the handler fails
+		properties []string
+	}{
+		"state report": {
+			endpointID: "light-1",
+			respond: func(req *Request) (*Response, error) {
+				return rb.StateReportResponse(req, power), nil
+			},
+			properties: []string{`"ON"`, `{"value":"UNREACHABLE"}`},
+		},
+		"existing connectivity kept": {
+			endpointID: "light-1",
+			respond: func(req *Request) (*Response, error) {
+				return rb.BasicResponse(req, power, reachable), nil
+			},
+			properties: []string{`"ON"`, `{"value":"OK"}`},
+		},
+		"typed response": {
+			endpointID: "light-1",
+			respond: func(req *Request) (*Response, error) {
+				return TypedResponse(rb, req, NamespaceRTCSessionController, EventSessionConnected, SessionPayload{"session-1"})
+			},
+			properties: []string{`{"value":"UNREACHABLE"}`},
+		},
+		"error response unchanged": {
+			endpointID: "light-1",
+			respond: func(req *Request) (*Response, error) {
+				return rb.BasicErrorResponse(req, ErrorTypeEndpointUnreachable, "offline")
+			},
+			properties: []string{},
+		},
+		"connectivity lookup fails": {
+			endpointID: "broken",
+			respond: func(req *Request) (*Response, error) {
+				return rb.StateReportResponse(req, power), nil
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := &Request{Directive: RequestDirective{
+				Header:   Header{Namespace: NamespaceAlexa, Name: "ReportState"},
+				Endpoint: RequestEndpoint{EndpointID: test.endpointID},
+			}}
+			var original *Response
+			var originalProperties int
+			handler := EndpointHealthHandler(connectivity, HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+				resp, err := test.respond(req)
+				if resp != nil && resp.Context != nil {
+					originalProperties = len(resp.Context.Properties)
+				}
+				original = resp
+				return resp, err
+			}))
+
+			resp, err := handler(context.Background(), req)
+			if test.properties == nil {
+				if err == nil {
+					t.Fatal("expected the connectivity error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			values := []string{}
+			if resp.Context != nil {
+				for _, prop := range resp.Context.Properties {
+					values = append(values, string(prop.Value))
+				}
+			}
+			if fmt.Sprint(values) != fmt.Sprint(test.properties) {
+				t.Errorf("expected properties %v, got %v", test.properties, values)
+			}
+			if original.Context != nil && len(original.Context.Properties) != originalProperties {
+				t.Error("expected the original response to be unchanged")
+			}
+		})
+	}
+}
+
+func TestCameraStreamConfiguration(t *testing.T) {
+	config := CameraStreamConfiguration{
+		Protocols:          []string{ProtocolRTSP, ProtocolHLS},
+		Resolutions:        []Resolution{{1920, 1080}, {1280, 720}},
+		AuthorizationTypes: []string{AuthorizationTypeBasic},
+		VideoCodecs:        []string{VideoCodecH264},
+		AudioCodecs:        []string{AudioCodecAAC},
+	}
+	if _, err := NewCameraStreamCapability(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	invalid := config
+	invalid.VideoCodecs = []string{"VP9"}
+	if _, err := NewCameraStreamCapability(invalid); err == nil {
+		t.Error("expected error for unknown video codec")
+	}
+
+	var payload InitializeCameraStreamsPayload
+	if err := json.Unmarshal([]byte(`{"cameraStreams":[{"protocol":"HLS","resolution":{"width":1280,"height":720},
+		"authorizationType":"BASIC","videoCodec":"H264","audioCodec":"AAC"}]}`), &payload); err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+	requested := payload.CameraStreams[0]
+	if !config.Supports(requested) {
+		t.Fatalf("expected %+v to be supported", requested)
+	}
+}
+
+func TestAcceptGrantHandler(t *testing.T) {
+	tests := map[string]struct {
+		statuses []int
+		retries  int
+		userErr  error
+		writeErr error
+		attempts int
+		accepted bool
+		// exchanged is set if the token metadata is known
+		exchanged bool
+		userID    string
+	}{
+		"accepted": {
+			statuses:  []int{http.StatusOK},
+			attempts:  1,
+			exchanged: true,
+			accepted:  true,
+			userID:    "user-1",
+		},
+		"transient failures retried": {
+			statuses:  []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK},
+			retries:   2,
+			attempts:  3,
+			exchanged: true,
+			accepted:  true,
+			userID:    "user-1",
+		},
+		"retries exhausted": {
+			statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK},
+			retries:  1,
+			attempts: 2,
+		},
+		"rejected code not retried": {
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			retries:  2,
+			attempts: 1,
+		},
+		"user lookup failed": {
+			statuses:  []int{http.StatusOK},
+			userErr:   errors.New("profile unavailable"),
+			attempts:  1,
+			exchanged: true,
+		},
+		"token write failed": {
+			statuses:  []int{http.StatusOK},
+			writeErr:  errors.New("store unavailable"),
+			attempts:  1,
+			exchanged: true,
+			userID:    "user-1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var attempts int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil || r.Form.Get("code") != "grant-code" || r.Form.Get("client_id") != "client" {
+					t.Errorf("unexpected exchange request: %v %v", r.Form, err)
+				}
+				status := test.statuses[attempts]
+				attempts++
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					fmt.Fprint(w, `{"access_token":"access","refresh_token":"refresh","token_type":"bearer","expires_in":3600}`)
+					return
+				}
+				fmt.Fprint(w, `{"error":"failed"}`)
+			}))
+			defer server.Close()
+
+			var written *oauth2.Token
+			var accepted, failed []GrantInfo
+			handler := &AcceptGrantHandler{
+				Credentials: ClientCredentials{"client", "secret"},
+				Endpoint:    oauth2.Endpoint{TokenURL: server.URL, AuthStyle: oauth2.AuthStyleInParams},
+				UserIDReader: userIDReaderFunc(func(ctx context.Context, token string) (string, error) {
+					if token != "grantee-token" {
+						t.Errorf("unexpected grantee token: %s", token)
+					}
+					return "user-1", test.userErr
+				}),
+				TokenWriter: tokenWriterFunc(func(ctx context.Context, id string, token *oauth2.Token) error {
+					written = token
+					return test.writeErr
+				}),
+				RespBuilder: &ResponseBuilder{MessageID: func() string { return "msg-1" }},
+				Retries:     test.retries,
+				RetryDelay:  time.Millisecond,
+				OnGrantAccepted: func(ctx context.Context, info GrantInfo) {
+					accepted = append(accepted, info)
+				},
+				OnGrantFailed: func(ctx context.Context, info GrantInfo, err error) {
+					if err == nil {
+						t.Error("expected failure cause")
+					}
+					failed = append(failed, info)
+				},
+			}
+
+			req := &Request{Directive: RequestDirective{
+				Header:  Header{Namespace: NamespaceAuthorization, Name: "AcceptGrant"},
+				Payload: json.RawMessage(`{"grant":{"type":"OAuth2.AuthorizationCode","code":"grant-code"},"grantee":{"type":"BearerToken","token":"grantee-token"}}`),
+			}}
+			resp, err := handler.HandleRequest(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if attempts != test.attempts {
+				t.Errorf("expected %d exchange attempts, got %d", test.attempts, attempts)
+			}
+
+			hooked := failed
+			expectedName := "ErrorResponse"
+			if test.accepted {
+				hooked = accepted
+				expectedName = "AcceptGrant.Response"
+			}
+			if resp.Event.Header.Name != expectedName {
+				t.Fatalf("expected %s, got %s: %s", expectedName, resp.Event.Header.Name, resp.Event.Payload)
+			}
+			if !test.accepted && !strings.Contains(string(resp.Event.Payload), ErrorTypeAcceptGrantFailed) {
+				t.Errorf("expected %s in %s", ErrorTypeAcceptGrantFailed, resp.Event.Payload)
+			}
+			if len(accepted)+len(failed) != 1 || len(hooked) != 1 {
+				t.Fatalf("expected one hook call, got accepted %v failed %v", accepted, failed)
+			}
+
+			info := hooked[0]
+			if info.UserID != test.userID {
+				t.Errorf("expected user id %q, got %q", test.userID, info.UserID)
+			}
+			if test.exchanged != (info.TokenType == "Bearer" && info.HasRefreshToken && !info.Expiry.IsZero()) {
+				t.Errorf("unexpected token metadata: %+v", info)
+			}
+			if test.accepted && (written == nil || written.AccessToken != "access") {
+				t.Errorf("expected token to be stored, got %+v", written)
+			}
+		})
+	}
+}
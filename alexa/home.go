@@ -21,18 +21,23 @@ func (h HandlerFunc) HandleRequest(ctx context.Context, req *Request) (*Response
 // NamespaceMux performs routing of skill requests to handlers based on the namespace value
 // in the request.
 type NamespaceMux struct {
-	handlerMap map[string]Handler
+	handlerMap  map[string]Handler
+	reportState Handler
 }
 
 // NewNamespaceMux creates a NamespaceMux
 func NewNamespaceMux() *NamespaceMux {
-	return &NamespaceMux{make(map[string]Handler)}
+	return &NamespaceMux{handlerMap: make(map[string]Handler)}
 }
 
 // HandleRequest delegates the request to the handler registered for the request's namespace.
 // An error is returned if the namespace is unregistered.
 func (n *NamespaceMux) HandleRequest(ctx context.Context, req *Request) (*Response, error) {
-	handler := n.handlerMap[req.Directive.Header.Namespace]
+	if n.reportState != nil && IsReportState(req) {
+		return n.reportState.HandleRequest(ctx, req)
+	}
+
+	handler := n.handlerMap[req.Namespace()]
 	if handler == nil {
 		return nil, fmt.Errorf("NamespaceMux: unhandled namespace: %s", req.Directive.Header.Namespace)
 	}
@@ -49,6 +54,18 @@ func (n *NamespaceMux) HandleFunc(namespace string, handler HandlerFunc) {
 	n.Handle(namespace, handler)
 }
 
+// HandleReportState registers a Handler for ReportState directives. It takes precedence
+// over any handler registered for the Alexa namespace which continues to receive the
+// namespace's other directives.
+func (n *NamespaceMux) HandleReportState(handler Handler) {
+	n.reportState = handler
+}
+
+// IsReportState reports whether req is a ReportState directive
+func IsReportState(req *Request) bool {
+	return req.Namespace() == NamespaceAlexa && req.DirectiveName() == DirectiveReportState
+}
+
 // EndpointMux routes a request based on the requested endpoint
 type EndpointMux struct {
 	handlerMap map[string]Handler
@@ -62,13 +79,13 @@ func NewEndpointMux() *EndpointMux {
 // HandleRequest delegates the request to the handler registered for the request's endpoint.
 // An error is returned if the endpoint is unregistered.
 func (e *EndpointMux) HandleRequest(ctx context.Context, req *Request) (*Response, error) {
-	handler := e.handlerMap[req.Directive.Endpoint.EndpointID]
+	handler := e.handlerMap[req.EndpointID()]
 	if handler == nil {
 		return nil, fmt.Errorf("EndpointMux: unhandled endpoint: %s", req.Directive.Endpoint.EndpointID)
 	}
 	resp, err := handler.HandleRequest(ctx, req)
 	if err != nil {
-		return resp, fmt.Errorf("EndpointMux: failed to handle %s: %v", req.Directive.Endpoint.EndpointID, err)
+		return resp, fmt.Errorf("EndpointMux: failed to handle %s: %w", req.Directive.Endpoint.EndpointID, err)
 	}
 
 	return resp, nil
@@ -83,3 +100,52 @@ func (e *EndpointMux) Handle(endpoint string, handler Handler) {
 func (e *EndpointMux) HandleFunc(endpoint string, handler HandlerFunc) {
 	e.Handle(endpoint, handler)
 }
+
+// PartitionMux routes a request based on the partition of its BearerTokenWithPartition
+// scope, e.g. a room or home in a multi-home deployment, so each partition can resolve
+// devices separately. Requests without a partition are routed to the handler registered
+// for the empty partition. The partition is available to handlers via PartitionFromContext.
+type PartitionMux struct {
+	handlerMap map[string]Handler
+}
+
+// NewPartitionMux creates a PartitionMux
+func NewPartitionMux() *PartitionMux {
+	return &PartitionMux{make(map[string]Handler)}
+}
+
+// HandleRequest delegates the request to the handler registered for the request's partition.
+// An error is returned if the partition is unregistered.
+func (p *PartitionMux) HandleRequest(ctx context.Context, req *Request) (*Response, error) {
+	partition, ok := req.Partition()
+	if ok {
+		ctx = WithPartition(ctx, partition)
+	}
+
+	handler := p.handlerMap[partition.Partition]
+	if handler == nil {
+		return nil, fmt.Errorf("PartitionMux: unhandled partition: %q", partition.Partition)
+	}
+	return handler.HandleRequest(ctx, req)
+}
+
+// Handle registers a Handler for the partition
+func (p *PartitionMux) Handle(partition string, handler Handler) {
+	p.handlerMap[partition] = handler
+}
+
+// HandleFunc registers a HandlerFunc for the partition
+func (p *PartitionMux) HandleFunc(partition string, handler HandlerFunc) {
+	p.Handle(partition, handler)
+}
+
+// PartitionHandler places the partition of requests with a BearerTokenWithPartition scope
+// in the context before passing them on to handler, see PartitionFromContext.
+func PartitionHandler(handler Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		if partition, ok := req.Partition(); ok {
+			ctx = WithPartition(ctx, partition)
+		}
+		return handler.HandleRequest(ctx, req)
+	}
+}
@@ -0,0 +1,59 @@
+package alexa
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultHTTPClient is shared by the auth and event code when no HTTPDoer or HTTPClient
+// is configured. Reusing it keeps connections to the Amazon apis alive between requests
+// so frequent events don't pay for a TLS handshake each time.
+var DefaultHTTPClient = NewHTTPClient(30 * time.Second)
+
+// NewHTTPClient creates a client with connection pooling and keep-alives tuned for
+// repeated requests to a small number of hosts. Create one and share it rather than
+// creating a client per request.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   10,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
+
+// HTTPDoerOrDefault returns doer or DefaultHTTPClient if doer is nil
+func HTTPDoerOrDefault(doer HTTPDoer) HTTPDoer {
+	if doer == nil {
+		return DefaultHTTPClient
+	}
+	return doer
+}
+
+// HTTPClientOrDefault returns client or DefaultHTTPClient if client is nil
+func HTTPClientOrDefault(client *http.Client) *http.Client {
+	if client == nil {
+		return DefaultHTTPClient
+	}
+	return client
+}
+
+// WithOAuthHTTPClient returns a context that directs the oauth2 package to use client for
+// token exchanges and refreshes instead of http.DefaultClient.
+func WithOAuthHTTPClient(ctx context.Context, client *http.Client) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, HTTPClientOrDefault(client))
+}
@@ -0,0 +1,130 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ResponseStore records the response produced for a directive so it can be replayed
+type ResponseStore interface {
+	// Get returns the response recorded for messageID or nil if there is none
+	Get(ctx context.Context, messageID string) (*Response, error)
+	// Put records the response for messageID unless one is already recorded
+	Put(ctx context.Context, messageID string, resp *Response) error
+}
+
+// IdempotentHandler wraps handler so the first response for a directive is recorded and
+// returned again for any retry with the same message id, rather than handling the
+// directive twice. Failed requests are not recorded. Duplicates arriving while the first
+// is still being handled are not detected; combine with DedupeHandler if that matters.
+func IdempotentHandler(store ResponseStore, handler Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		messageID := req.Directive.Header.MessageID
+		recorded, err := store.Get(ctx, messageID)
+		if err != nil {
+			return nil, fmt.Errorf("IdempotentHandler: failed to get response: %v", err)
+		}
+		if recorded != nil {
+			return recorded, nil
+		}
+
+		resp, err := handler.HandleRequest(ctx, req)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+
+		if err := store.Put(ctx, messageID, resp); err != nil {
+			return nil, fmt.Errorf("IdempotentHandler: failed to record response: %v", err)
+		}
+		return resp, nil
+	}
+}
+
+// MemoryResponseStore is a ResponseStore that keeps responses in memory for TTL.
+// It's only suitable when a single process handles all directives. Responses are stored
+// as json so callers can't modify a recorded response through the one they put or get.
+type MemoryResponseStore struct {
+	// TTL defaults to DefaultMessageIDTTL
+	TTL time.Duration
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+
+	mu        sync.Mutex
+	responses map[string]recordedResponse
+	// expiry holds message ids in the order they were recorded. With a fixed ttl this is
+	// also the order they expire in so expired responses are dropped from the front.
+	expiry []seenMessageID
+}
+
+type recordedResponse struct {
+	resp    []byte
+	expires time.Time
+}
+
+func (m *MemoryResponseStore) Get(ctx context.Context, messageID string) (*Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recorded, ok := m.responses[messageID]
+	if !ok || !m.now().Before(recorded.expires) {
+		return nil, nil
+	}
+
+	var resp Response
+	if err := json.Unmarshal(recorded.resp, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	return &resp, nil
+}
+
+func (m *MemoryResponseStore) Put(ctx context.Context, messageID string, resp *Response) error {
+	respJSON, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.expire(now)
+	if m.responses == nil {
+		m.responses = make(map[string]recordedResponse)
+	}
+
+	if _, ok := m.responses[messageID]; ok {
+		return nil
+	}
+	expires := now.Add(m.ttl())
+	m.responses[messageID] = recordedResponse{respJSON, expires}
+	m.expiry = append(m.expiry, seenMessageID{messageID, expires})
+	return nil
+}
+
+// expire drops the responses that expired before now. Only expired ones are visited.
+func (m *MemoryResponseStore) expire(now time.Time) {
+	n := 0
+	for ; n < len(m.expiry) && !now.Before(m.expiry[n].expires); n++ {
+		delete(m.responses, m.expiry[n].messageID)
+	}
+	if n > 0 {
+		m.expiry = append(m.expiry[:0], m.expiry[n:]...)
+	}
+}
+
+func (m *MemoryResponseStore) ttl() time.Duration {
+	if m.TTL <= 0 {
+		return DefaultMessageIDTTL
+	}
+	return m.TTL
+}
+
+func (m *MemoryResponseStore) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
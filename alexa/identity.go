@@ -0,0 +1,313 @@
+package alexa
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCUserIDReader retrieves the user's id from an OpenID Connect userinfo endpoint.
+// This can be used for skills that link accounts with an identity provider
+// other than Login with Amazon.
+type OIDCUserIDReader struct {
+	// HTTPDoer defaults to DefaultHTTPClient
+	HTTPDoer HTTPDoer
+	// UserInfoURL is the provider's userinfo endpoint
+	UserInfoURL string
+	// Claim is the userinfo field holding the user id. Defaults to "sub".
+	Claim string
+}
+
+func (o *OIDCUserIDReader) Read(ctx context.Context, bearerToken string) (string, error) {
+	userInfoReq, err := http.NewRequest(http.MethodGet, o.UserInfoURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build userinfo request: %v", err)
+	}
+
+	userInfoReq = userInfoReq.WithContext(ctx)
+	userInfoReq.Header.Set("Accept", "application/json")
+	userInfoReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", bearerToken))
+
+	userInfoResp, err := HTTPDoerOrDefault(o.HTTPDoer).Do(userInfoReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to perform userinfo request: %v", err)
+	}
+	defer userInfoResp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(userInfoResp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read userinfo body: %v", err)
+	}
+
+	if userInfoResp.StatusCode == http.StatusUnauthorized {
+		return "", invalidToken("userinfo response status code: %s", userInfoResp.Status)
+	}
+	if userInfoResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("userinfo response unexpected status code: %s", userInfoResp.Status)
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(respBody, &claims); err != nil {
+		return "", fmt.Errorf("failed to unmarshal userinfo data: %v", err)
+	}
+
+	return claimString(claims, o.Claim)
+}
+
+// NewCognitoUserIDReader creates a UserIDReader for tokens issued by an Amazon Cognito
+// user pool. domain is the user pool's domain, e.g. myskill.auth.us-east-1.amazoncognito.com
+func NewCognitoUserIDReader(domain string, httpDoer HTTPDoer) *OIDCUserIDReader {
+	return &OIDCUserIDReader{
+		HTTPDoer:    httpDoer,
+		UserInfoURL: fmt.Sprintf("https://%s/oauth2/userInfo", domain),
+	}
+}
+
+// JWTUserIDReader extracts the user's id from the claims of a JWT access token without
+// calling out to the identity provider for every request. Tokens must be RS256 signed by
+// a key published at JWKSURL.
+type JWTUserIDReader struct {
+	// HTTPDoer defaults to DefaultHTTPClient
+	HTTPDoer HTTPDoer
+	// JWKSURL is the location of the provider's signing keys
+	JWKSURL string
+	// Issuer is compared against the iss claim if set
+	Issuer string
+	// Audience is compared against the aud or client_id claim if set
+	Audience string
+	// Claim is the token claim holding the user id. Defaults to "sub".
+	Claim string
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+	// RefreshInterval is the minimum time between fetches of the key set when a token
+	// is signed by an unknown key. Defaults to a minute.
+	RefreshInterval time.Duration
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// defaultJWKSRefreshInterval limits how often tokens with unknown keys refetch the key set
+const defaultJWKSRefreshInterval = time.Minute
+
+func (j *JWTUserIDReader) Read(ctx context.Context, bearerToken string) (string, error) {
+	parts := strings.Split(bearerToken, ".")
+	if len(parts) != 3 {
+		return "", invalidToken("malformed jwt")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return "", invalidToken("failed to decode jwt header: %v", err)
+	}
+	if header.Alg != "RS256" {
+		return "", invalidToken("unsupported jwt alg: %s", header.Alg)
+	}
+
+	key, err := j.key(ctx, header.Kid)
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", invalidToken("failed to decode jwt signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return "", invalidToken("invalid jwt signature: %v", err)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return "", invalidToken("failed to decode jwt claims: %v", err)
+	}
+
+	if err := j.validateClaims(claims); err != nil {
+		return "", err
+	}
+
+	userID, err := claimString(claims, j.Claim)
+	if err != nil {
+		return "", invalidToken("%v", err)
+	}
+	return userID, nil
+}
+
+func (j *JWTUserIDReader) now() time.Time {
+	if j.Now != nil {
+		return j.Now()
+	}
+	return time.Now()
+}
+
+func (j *JWTUserIDReader) validateClaims(claims map[string]interface{}) error {
+	now := j.now().Unix()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return invalidToken("jwt missing exp claim")
+	}
+	if now >= int64(exp) {
+		return invalidToken("jwt expired")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now < int64(nbf) {
+		return invalidToken("jwt not yet valid")
+	}
+
+	if j.Issuer != "" && claims["iss"] != j.Issuer {
+		return invalidToken("unexpected jwt issuer: %v", claims["iss"])
+	}
+
+	if j.Audience != "" && !hasAudience(claims, j.Audience) {
+		return invalidToken("jwt not issued for audience")
+	}
+
+	return nil
+}
+
+func hasAudience(claims map[string]interface{}, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		if aud == audience {
+			return true
+		}
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	// Cognito access tokens carry the app client in client_id instead of aud
+	return claims["client_id"] == audience
+}
+
+// key returns the signing key for kid, refreshing the key set if it's unknown. Refreshes
+// are limited to one per RefreshInterval so tokens with made up key ids can't be used to
+// flood the provider. The key set is fetched without holding the lock so reads of known
+// keys aren't blocked.
+func (j *JWTUserIDReader) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	if key, ok := j.keys[kid]; ok {
+		j.mu.Unlock()
+		return key, nil
+	}
+
+	refreshInterval := j.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = defaultJWKSRefreshInterval
+	}
+	now := j.now()
+	if !j.fetched.IsZero() && now.Sub(j.fetched) < refreshInterval {
+		j.mu.Unlock()
+		return nil, invalidToken("unknown jwt signing key: %s", kid)
+	}
+	j.fetched = now
+	j.mu.Unlock()
+
+	keys, err := j.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.mu.Unlock()
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, invalidToken("unknown jwt signing key: %s", kid)
+	}
+	return key, nil
+}
+
+func (j *JWTUserIDReader) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	jwksReq, err := http.NewRequest(http.MethodGet, j.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build jwks request: %v", err)
+	}
+	jwksReq = jwksReq.WithContext(ctx)
+
+	jwksResp, err := HTTPDoerOrDefault(j.HTTPDoer).Do(jwksReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform jwks request: %v", err)
+	}
+	defer jwksResp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(jwksResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks body: %v", err)
+	}
+
+	if jwksResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks response unexpected status code: %s", jwksResp.Status)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(respBody, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal jwks: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for key %s: %v", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for key %s: %v", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func claimString(claims map[string]interface{}, claim string) (string, error) {
+	if claim == "" {
+		claim = "sub"
+	}
+	userID, ok := claims[claim].(string)
+	if !ok || userID == "" {
+		return "", fmt.Errorf("missing %s claim", claim)
+	}
+	return userID, nil
+}
@@ -0,0 +1,176 @@
+package alexa
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type httpDoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f httpDoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func signJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	if err != nil {
+		t.Fatalf("failed to marshal header: %v", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal claims: %v", err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTUserIDReader(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "key-1",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal jwks: %v", err)
+	}
+
+	var fetches int
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	reader := &JWTUserIDReader{
+		HTTPDoer: httpDoerFunc(func(req *http.Request) (*http.Response, error) {
+			fetches++
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(string(jwks))),
+			}, nil
+		}),
+		JWKSURL:  "https://example.com/.well-known/jwks.json",
+		Issuer:   "https://example.com",
+		Audience: "skill",
+		Now:      func() time.Time { return now },
+	}
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "user-1",
+			"iss": "https://example.com",
+			"aud": "skill",
+			"exp": now.Add(time.Hour).Unix(),
+			"nbf": now.Add(-time.Minute).Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := map[string]struct {
+		token string
+		err   string
+	}{
+		"valid":           {token: signJWT(t, key, "key-1", claims(nil))},
+		"client id":       {token: signJWT(t, key, "key-1", claims(map[string]interface{}{"aud": nil, "client_id": "skill"}))},
+		"audience list":   {token: signJWT(t, key, "key-1", claims(map[string]interface{}{"aud": []string{"other", "skill"}}))},
+		"bad signature":   {token: signJWT(t, otherKey, "key-1", claims(nil)), err: "invalid jwt signature"},
+		"expired":         {token: signJWT(t, key, "key-1", claims(map[string]interface{}{"exp": now.Unix()})), err: "jwt expired"},
+		"missing exp":     {token: signJWT(t, key, "key-1", claims(map[string]interface{}{"exp": nil})), err: "missing exp"},
+		"not yet valid":   {token: signJWT(t, key, "key-1", claims(map[string]interface{}{"nbf": now.Add(time.Minute).Unix()})), err: "not yet valid"},
+		"wrong issuer":    {token: signJWT(t, key, "key-1", claims(map[string]interface{}{"iss": "https://evil.com"})), err: "unexpected jwt issuer"},
+		"wrong audience":  {token: signJWT(t, key, "key-1", claims(map[string]interface{}{"aud": "other"})), err: "audience"},
+		"missing subject": {token: signJWT(t, key, "key-1", claims(map[string]interface{}{"sub": nil})), err: "missing sub"},
+		"malformed":       {token: "not-a-jwt", err: "malformed jwt"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			userID, err := reader.Read(context.Background(), test.token)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) || !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("expected invalid token error containing %q, got %s %v", test.err, userID, err)
+				}
+				return
+			}
+			if err != nil || userID != "user-1" {
+				t.Errorf("unexpected result: %s %v", userID, err)
+			}
+		})
+	}
+
+	if fetches != 1 {
+		t.Errorf("expected the key set to be fetched once, got %d", fetches)
+	}
+}
+
+func TestJWTUserIDReaderUnknownKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var fetches int
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	reader := &JWTUserIDReader{
+		HTTPDoer: httpDoerFunc(func(req *http.Request) (*http.Response, error) {
+			fetches++
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"keys":[]}`)),
+			}, nil
+		}),
+		Now: func() time.Time { return now },
+	}
+
+	token := signJWT(t, key, "unknown", map[string]interface{}{"sub": "user-1", "exp": now.Add(time.Hour).Unix()})
+	for i := 0; i < 3; i++ {
+		if _, err := reader.Read(context.Background(), token); err == nil || !strings.Contains(err.Error(), "unknown jwt signing key") {
+			t.Fatalf("expected unknown key error, got %v", err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected repeated unknown keys to be rate limited, got %d fetches", fetches)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := reader.Read(context.Background(), token); err == nil {
+		t.Fatal("expected unknown key error")
+	}
+	if fetches != 2 {
+		t.Errorf("expected a refetch after the refresh interval, got %d fetches", fetches)
+	}
+}
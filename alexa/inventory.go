@@ -0,0 +1,160 @@
+package alexa
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PropertyLevel is the Alexa.InventoryLevelSensor property holding an InventoryMeasurement
+const PropertyLevel = "level"
+
+// EventInventoryConsumed is the proactive Alexa.InventoryUsageSensor event
+const EventInventoryConsumed = "InventoryConsumed"
+
+// InventoryMeasurement type enums
+const (
+	InventoryMeasurementCount      = "Count"
+	InventoryMeasurementPercentage = "Percentage"
+	InventoryMeasurementVolume     = "Volume"
+	InventoryMeasurementWeight     = "Weight"
+)
+
+// Inventory unit enums
+const (
+	UnitFluidOunces = "FLUID_OUNCE"
+	UnitGram        = "GRAM"
+	UnitKilogram    = "KILOGRAM"
+	UnitLiter       = "LITER"
+	UnitMilliliter  = "MILLILITER"
+	UnitOunce       = "OUNCE"
+	UnitPound       = "POUND"
+)
+
+// ReplenishmentDashReplenishmentID identifies the Dash Replenishment product to reorder
+const ReplenishmentDashReplenishmentID = "DashReplenishmentId"
+
+// InventoryMeasurement is an amount of a consumable. Unit is only set for Volume and
+// Weight measurements.
+type InventoryMeasurement struct {
+	Type  string  `json:"@type"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+// Validate checks the measurement has a unit if it's a Volume or Weight and a percentage
+// is within 0 to 100
+func (m InventoryMeasurement) Validate() error {
+	switch m.Type {
+	case InventoryMeasurementVolume, InventoryMeasurementWeight:
+		if m.Unit == "" {
+			return fmt.Errorf("%s measurement requires a unit", m.Type)
+		}
+	case InventoryMeasurementPercentage:
+		return validateRange("value", m.Value, 0, 100)
+	case InventoryMeasurementCount:
+	default:
+		return fmt.Errorf("unknown measurement type %q", m.Type)
+	}
+	return nil
+}
+
+// InventoryConfiguration is the discovery configuration of an inventory level or usage
+// sensor instance. Measurement is the Type and, for volume and weight, Unit of the
+// measurements reported.
+type InventoryConfiguration struct {
+	Measurement   InventoryMeasurementType `json:"measurement"`
+	Replenishment *Replenishment           `json:"replenishment,omitempty"`
+}
+
+type InventoryMeasurementType struct {
+	Type string `json:"@type"`
+	Unit string `json:"unit,omitempty"`
+}
+
+type Replenishment struct {
+	Type  string `json:"@type"`
+	Value string `json:"value"`
+}
+
+// DashReplenishment creates the replenishment of the Dash Replenishment product id
+func DashReplenishment(id string) *Replenishment {
+	return &Replenishment{Type: ReplenishmentDashReplenishmentID, Value: id}
+}
+
+// NewInventoryLevelSensorCapability creates the discovery capability of a level sensor
+// instance named by resources
+func NewInventoryLevelSensorCapability(instance string, resources CapabilityResources,
+	config InventoryConfiguration) (DiscoverCapability, error) {
+	capability, err := inventoryCapability(InterfaceInventoryLevelSensor, instance, resources, config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	capability.Properties = &DiscoverProperties{
+		Supported:           []DiscoverProperty{{Name: PropertyLevel}},
+		ProactivelyReported: true,
+		Retrievable:         true,
+	}
+	return capability, nil
+}
+
+// NewInventoryUsageSensorCapability creates the discovery capability of a usage sensor
+// instance named by resources. Usage is only reported by InventoryConsumed events.
+func NewInventoryUsageSensorCapability(instance string, resources CapabilityResources,
+	config InventoryConfiguration) (DiscoverCapability, error) {
+	return inventoryCapability(InterfaceInventoryUsageSensor, instance, resources, config)
+}
+
+func inventoryCapability(iface, instance string, resources CapabilityResources,
+	config InventoryConfiguration) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:                "AlexaInterface",
+		Interface:           iface,
+		Instance:            instance,
+		Version:             "3",
+		CapabilityResources: &resources,
+		Configuration:       configJSON,
+	}, nil
+}
+
+// LevelProperty creates a level property of instance
+func LevelProperty(instance string, level InventoryMeasurement, timeOfSample time.Time) (ContextProperty, error) {
+	if err := level.Validate(); err != nil {
+		return ContextProperty{}, err
+	}
+	prop, err := NewProperty(NamespaceInventoryLevelSensor, PropertyLevel, level, timeOfSample)
+	if err != nil {
+		return ContextProperty{}, err
+	}
+	prop.Instance = instance
+	return prop, nil
+}
+
+// InventoryConsumedPayload is the payload of an InventoryConsumed event
+type InventoryConsumedPayload struct {
+	Usage        InventoryMeasurement `json:"usage"`
+	TimeOfSample time.Time            `json:"timeOfSample"`
+}
+
+// InventoryConsumedEvent creates a proactive event reporting usage of the consumable of
+// instance. scope must identify the user, see deferred.HTTPEventSender.
+func (r *ResponseBuilder) InventoryConsumedEvent(scope Scope, endpointID, instance string,
+	usage InventoryMeasurement, timeOfSample time.Time) (*Response, error) {
+	if err := usage.Validate(); err != nil {
+		return nil, err
+	}
+	if timeOfSample.IsZero() {
+		timeOfSample = time.Now()
+	}
+	resp, err := ProactiveEvent(r, scope, NamespaceInventoryUsageSensor, EventInventoryConsumed, endpointID,
+		InventoryConsumedPayload{Usage: usage, TimeOfSample: timeOfSample.UTC()})
+	if err != nil {
+		return nil, err
+	}
+	resp.Event.Header.Instance = instance
+	return resp, nil
+}
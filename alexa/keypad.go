@@ -0,0 +1,64 @@
+package alexa
+
+import (
+	"context"
+	"fmt"
+)
+
+// Key enums
+const (
+	KeyDown      = "DOWN"
+	KeyInfo      = "INFO"
+	KeyLeft      = "LEFT"
+	KeyMore      = "MORE"
+	KeyPageDown  = "PAGE_DOWN"
+	KeyPageLeft  = "PAGE_LEFT"
+	KeyPageRight = "PAGE_RIGHT"
+	KeyPageUp    = "PAGE_UP"
+	KeyRight     = "RIGHT"
+	KeySelect    = "SELECT"
+	KeyUp        = "UP"
+)
+
+var keys = []string{KeyDown, KeyInfo, KeyLeft, KeyMore, KeyPageDown, KeyPageLeft,
+	KeyPageRight, KeyPageUp, KeyRight, KeySelect, KeyUp}
+
+// IsKey reports if key is a Key enum
+func IsKey(key string) bool {
+	return contains(keys, key)
+}
+
+type SendKeystrokePayload struct {
+	Keystroke string `json:"keystroke"`
+}
+
+// Validate checks that the keystroke is a Key enum
+func (p SendKeystrokePayload) Validate() error {
+	if !IsKey(p.Keystroke) {
+		return fmt.Errorf("unknown keystroke %q", p.Keystroke)
+	}
+	return nil
+}
+
+// KeypadControllerHandler routes handling of keystroke directives
+func KeypadControllerHandler(sendKeystroke Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "SendKeystroke":
+			return sendKeystroke.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("KeypadControllerHandler", req)
+		}
+	}
+}
+
+// NewKeypadControllerCapability creates the discovery capability of an endpoint that
+// supports the keys
+func NewKeypadControllerCapability(keys ...string) DiscoverCapability {
+	return DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: InterfaceKeypadController,
+		Version:   "3",
+		Keys:      keys,
+	}
+}
@@ -0,0 +1,37 @@
+package alexa
+
+import (
+	"context"
+	"time"
+)
+
+// PropertyLockState is the Alexa.LockController property holding the LockState of an endpoint
+const PropertyLockState = "lockState"
+
+// LockState enums
+const (
+	LockStateJammed   = "JAMMED"
+	LockStateLocked   = "LOCKED"
+	LockStateUnlocked = "UNLOCKED"
+)
+
+// LockControllerHandler routes handling of lock & unlock directives. Locks usually
+// take longer to move than Alexa waits for a response so unlock is typically wrapped
+// with deferred.Async to answer with a DeferredResponse.
+func LockControllerHandler(lock, unlock Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "Lock":
+			return lock.HandleRequest(ctx, req)
+		case "Unlock":
+			return unlock.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("LockControllerHandler", req)
+		}
+	}
+}
+
+// LockStateProperty creates a lockState property
+func LockStateProperty(lockState string, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceLockController, PropertyLockState, lockState, timeOfSample)
+}
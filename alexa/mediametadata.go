@@ -0,0 +1,144 @@
+package alexa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MediaMetadata event name enums
+const (
+	EventGetMediaMetadataResponse = "GetMediaMetadata.Response"
+	EventMediaCreatedOrUpdated    = "MediaCreatedOrUpdated"
+)
+
+// MediaCause enums
+const (
+	MediaCauseAudioDetected   = "AUDIO_DETECTED"
+	MediaCauseMotionDetected  = "MOTION_DETECTED"
+	MediaCausePersonDetected  = "PERSON_DETECTED"
+	MediaCauseUserInteraction = "USER_INTERACTION"
+)
+
+// MediaErrorStatus enums
+const (
+	MediaErrorStatusInternalError = "INTERNAL_ERROR"
+	MediaErrorStatusNotFound      = "MEDIA_NOT_FOUND"
+)
+
+// ScanDirection enums. They're the directions media can be browsed in from the media
+// returned, older or newer recordings.
+const (
+	ScanDirectionBackward = "BACKWARD"
+	ScanDirectionForward  = "FORWARD"
+)
+
+// GetMediaMetadataPayload requests the metadata of the media in Filters
+type GetMediaMetadataPayload struct {
+	Filters MediaFilters `json:"filters"`
+}
+
+type MediaFilters struct {
+	MediaIDs []string `json:"mediaIds"`
+}
+
+// Validate checks that media was requested
+func (p GetMediaMetadataPayload) Validate() error {
+	if len(p.Filters.MediaIDs) == 0 {
+		return errors.New("missing filters mediaIds")
+	}
+	return nil
+}
+
+// GetMediaMetadataResponsePayload answers a GetMediaMetadata directive with the Media found
+// and Errors for the rest. ScanDirections lists the ScanDirection enums there's more media in.
+type GetMediaMetadataResponsePayload struct {
+	Media          []Media      `json:"media"`
+	Errors         []MediaError `json:"errors,omitempty"`
+	ScanDirections []string     `json:"scanDirections,omitempty"`
+}
+
+// Validate checks that the scan directions are ScanDirection enums
+func (p GetMediaMetadataResponsePayload) Validate() error {
+	for _, direction := range p.ScanDirections {
+		if direction != ScanDirectionBackward && direction != ScanDirectionForward {
+			return fmt.Errorf("unknown scan direction %q", direction)
+		}
+	}
+	return nil
+}
+
+// Media is a recording, e.g. a camera clip, identified by ID
+type Media struct {
+	ID string `json:"id"`
+	// Cause is one of the MediaCause enums
+	Cause     string    `json:"cause"`
+	Recording Recording `json:"recording"`
+}
+
+type Recording struct {
+	Name         string    `json:"name,omitempty"`
+	StartTime    time.Time `json:"startTime"`
+	EndTime      time.Time `json:"endTime"`
+	VideoCodec   string    `json:"videoCodec,omitempty"`
+	AudioCodec   string    `json:"audioCodec,omitempty"`
+	URI          MediaURI  `json:"uri"`
+	ThumbnailURI *MediaURI `json:"thumbnailUri,omitempty"`
+}
+
+// MediaURI is a presigned url to media that's valid until ExpireTime
+type MediaURI struct {
+	Value      string    `json:"value"`
+	ExpireTime time.Time `json:"expireTime"`
+}
+
+// MediaError reports why media couldn't be returned, one of the MediaErrorStatus enums
+type MediaError struct {
+	MediaID string `json:"mediaId"`
+	Status  string `json:"status"`
+}
+
+// MediaMetadataHandler routes handling of media metadata directives
+func MediaMetadataHandler(getMediaMetadata Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "GetMediaMetadata":
+			return getMediaMetadata.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("MediaMetadataHandler", req)
+		}
+	}
+}
+
+// GetMediaMetadataResponse answers a GetMediaMetadata directive
+func (r *ResponseBuilder) GetMediaMetadataResponse(req *Request, payload GetMediaMetadataResponsePayload) (*Response, error) {
+	if err := payload.Validate(); err != nil {
+		return nil, err
+	}
+	if payload.Media == nil {
+		payload.Media = []Media{}
+	}
+	return TypedResponse(r, req, NamespaceMediaMetadata, EventGetMediaMetadataResponse, payload)
+}
+
+// MediaCreatedOrUpdatedEvent creates a proactive event announcing new or updated media,
+// e.g. a clip recorded on motion. scope must identify the user, see deferred.HTTPEventSender.
+func (r *ResponseBuilder) MediaCreatedOrUpdatedEvent(scope Scope, endpointID string, media Media) (*Response, error) {
+	return ProactiveEvent(r, scope, NamespaceMediaMetadata, EventMediaCreatedOrUpdated, endpointID,
+		struct {
+			Media Media `json:"media"`
+		}{media})
+}
+
+// NewMediaMetadataCapability creates the discovery capability of an endpoint with
+// recordings such as camera clips
+func NewMediaMetadataCapability() DiscoverCapability {
+	proactivelyReported := true
+	return DiscoverCapability{
+		Type:                "AlexaInterface",
+		Interface:           InterfaceMediaMetadata,
+		Version:             "3",
+		ProactivelyReported: &proactivelyReported,
+	}
+}
@@ -0,0 +1,64 @@
+package alexa
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MeasuringMethod enums
+const (
+	// MeasuringMethodDirect measurements come from a meter in the device
+	MeasuringMethodDirect = "DIRECT"
+	// MeasuringMethodIndirect measurements are derived, e.g. from the current and voltage
+	MeasuringMethodIndirect = "INDIRECT"
+)
+
+// MeterConfiguration is the discovery configuration of an Alexa.DeviceUsage.Meter endpoint,
+// e.g. an energy monitoring plug
+type MeterConfiguration struct {
+	EnergySources EnergySources `json:"energySources"`
+}
+
+// EnergySources describes the metered resources. Only electricity is supported.
+type EnergySources struct {
+	Electricity *EnergySource `json:"electricity,omitempty"`
+}
+
+// EnergySource describes how a resource is metered. DefaultMeasurementResolution is an
+// ISO 8601 duration, the period each reported measurement covers.
+type EnergySource struct {
+	MeasuringMethod              string `json:"measuringMethod"`
+	Unit                         string `json:"unit"`
+	DefaultMeasurementResolution string `json:"defaultMeasurementResolution"`
+}
+
+// ElectricityMeterConfiguration creates the configuration of an endpoint directly metering
+// electricity in kilowatt hours and reporting a measurement each resolution
+func ElectricityMeterConfiguration(resolution time.Duration) MeterConfiguration {
+	return MeterConfiguration{EnergySources: EnergySources{Electricity: &EnergySource{
+		MeasuringMethod:              MeasuringMethodDirect,
+		Unit:                         MeasurementUnitKilowattHours,
+		DefaultMeasurementResolution: formatISODuration(resolution),
+	}}}
+}
+
+// NewMeterCapability creates the discovery capability of an endpoint metering usage
+func NewMeterCapability(config MeterConfiguration) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:          "AlexaInterface",
+		Interface:     InterfaceDeviceUsageMeter,
+		Version:       "1.0",
+		Configuration: configJSON,
+	}, nil
+}
+
+// MeterMeasurementsReport creates a proactive event reporting metered usage, see
+// MeasurementsReport
+func (r *ResponseBuilder) MeterMeasurementsReport(scope Scope, endpointID string,
+	measurements ...Measurement) (*Response, error) {
+	return r.MeasurementsReport(scope, NamespaceDeviceUsageMeter, endpointID, measurements...)
+}
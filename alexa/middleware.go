@@ -0,0 +1,152 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TokenValidationHandler verifies the bearer token of a request by resolving it to a user id
+// before passing the request on. Requests with an invalid token, i.e. the UserIDReader
+// returned an error wrapping ErrInvalidToken, receive an INVALID_AUTHORIZATION_CREDENTIAL
+// error response. Other lookup failures receive an INTERNAL_ERROR so Alexa doesn't treat
+// an outage of the identity provider as the user's credentials being revoked.
+// The resolved user id is available to downstream handlers via UserIDFromContext.
+type TokenValidationHandler struct {
+	userIDReader UserIDReader
+	respBuilder  *ResponseBuilder
+	handler      Handler
+}
+
+// NewTokenValidationHandler creates a TokenValidationHandler that caches resolved user ids
+// for ttl.
+func NewTokenValidationHandler(userIDReader UserIDReader, respBuilder *ResponseBuilder,
+	ttl time.Duration, handler Handler) *TokenValidationHandler {
+	return &TokenValidationHandler{
+		userIDReader: NewCachingUserIDReader(userIDReader, ttl),
+		respBuilder:  respBuilder,
+		handler:      handler,
+	}
+}
+
+// HandleRequest validates the request's token and delegates to the wrapped handler.
+func (t *TokenValidationHandler) HandleRequest(ctx context.Context, req *Request) (*Response, error) {
+	token := req.BearerToken()
+	if token == "" {
+		return t.respBuilder.BasicErrorResponse(req, ErrorTypeInvalidAuthorizationCredential,
+			"missing bearer token")
+	}
+
+	userID, err := t.userIDReader.Read(ctx, token)
+	if err != nil {
+		errorType := ErrorTypeInternalError
+		if errors.Is(err, ErrInvalidToken) {
+			errorType = ErrorTypeInvalidAuthorizationCredential
+		}
+		return t.respBuilder.BasicErrorResponse(req, errorType, fmt.Sprintf("failed to resolve user: %v", err))
+	}
+
+	return t.handler.HandleRequest(WithUserID(ctx, userID), req)
+}
+
+// EndpointOwnership reports whether a user is permitted to control an endpoint
+type EndpointOwnership interface {
+	OwnsEndpoint(ctx context.Context, userID, endpointID string) (bool, error)
+}
+
+// EndpointOwnershipFunc implements EndpointOwnership as a func
+type EndpointOwnershipFunc func(ctx context.Context, userID, endpointID string) (bool, error)
+
+// OwnsEndpoint calls the EndpointOwnershipFunc
+func (e EndpointOwnershipFunc) OwnsEndpoint(ctx context.Context, userID, endpointID string) (bool, error) {
+	return e(ctx, userID, endpointID)
+}
+
+// EndpointOwnershipHandler wraps handler so a request is only handled if the user owns the
+// targeted endpoint. Requests for endpoints the user doesn't own receive a NO_SUCH_ENDPOINT
+// error response. The user id must be in the context, see TokenValidationHandler.
+// Requests without an endpoint are passed through.
+func EndpointOwnershipHandler(ownership EndpointOwnership, respBuilder *ResponseBuilder, handler Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		endpointID := req.Directive.Endpoint.EndpointID
+		if endpointID == "" {
+			return handler.HandleRequest(ctx, req)
+		}
+
+		userID, ok := UserIDFromContext(ctx)
+		if !ok {
+			return nil, fmt.Errorf("EndpointOwnershipHandler: no user id in context")
+		}
+
+		owned, err := ownership.OwnsEndpoint(ctx, userID, endpointID)
+		if err != nil {
+			return nil, fmt.Errorf("EndpointOwnershipHandler: failed to check ownership of %s: %v", endpointID, err)
+		}
+		if !owned {
+			return respBuilder.BasicErrorResponse(req, ErrorTypeNoSuchEndpoint,
+				fmt.Sprintf("unknown endpoint: %s", endpointID))
+		}
+
+		return handler.HandleRequest(ctx, req)
+	}
+}
+
+// StaticEndpointOwnership maps user ids to the ids of the endpoints they own
+type StaticEndpointOwnership map[string][]string
+
+// OwnsEndpoint reports if endpointID is listed for userID
+func (s StaticEndpointOwnership) OwnsEndpoint(ctx context.Context, userID, endpointID string) (bool, error) {
+	for _, id := range s[userID] {
+		if id == endpointID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// InvalidDirectiveHandler wraps handler and converts an UnexpectedDirectiveError returned
+// anywhere in the handler chain into a spec compliant INVALID_DIRECTIVE error response.
+// Errors must be wrapped with %w to be detected.
+func InvalidDirectiveHandler(respBuilder *ResponseBuilder, handler Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		resp, err := handler.HandleRequest(ctx, req)
+
+		var unexpected *UnexpectedDirectiveError
+		if errors.As(err, &unexpected) {
+			return respBuilder.BasicErrorResponse(req, ErrorTypeInvalidDirective,
+				fmt.Sprintf("%s.%s is not supported", unexpected.Namespace, unexpected.Name))
+		}
+
+		return resp, err
+	}
+}
+
+// ValueOutOfRangeHandler wraps handler and converts a ValueOutOfRangeError returned anywhere
+// in the handler chain into a VALUE_OUT_OF_RANGE error response including the valid range.
+// Errors must be wrapped with %w to be detected.
+func ValueOutOfRangeHandler(respBuilder *ResponseBuilder, handler Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		resp, err := handler.HandleRequest(ctx, req)
+
+		var outOfRange *ValueOutOfRangeError
+		if errors.As(err, &outOfRange) {
+			payload := ValueOutOfRangeErrorPayload{
+				Type:    ErrorTypeValueOutOfRange,
+				Message: outOfRange.Error(),
+				ValidRange: ValidRange{
+					MinimumValue: outOfRange.Min,
+					MaximumValue: outOfRange.Max,
+				},
+			}
+			payloadJSON, err := json.Marshal(payload)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal payload: %v", err)
+			}
+			return respBuilder.CustomErrorResponse(req, payloadJSON), nil
+		}
+
+		return resp, err
+	}
+}
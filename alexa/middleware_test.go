@@ -0,0 +1,445 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenValidationHandler(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	reader := userIDReaderFunc(func(ctx context.Context, token string) (string, error) {
+		switch token {
+		case "expired":
+			return "", fmt.Errorf("lookup failed: %w", ErrInvalidToken)
+		case "outage":
+			return "", errors.New("profile api unavailable")
+		default:
+			return "user-" + token, nil
+		}
+	})
+	handler := NewTokenValidationHandler(reader, rb, time.Minute,
+		HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			userID, _ := UserIDFromContext(ctx)
+			return rb.BasicResponse(req, ContextProperty{Name: userID}), nil
+		}))
+
+	tests := map[string]struct {
+		token     string
+		errorType string
+	}{
+		"valid":   {token: "abc"},
+		"missing": {errorType: ErrorTypeInvalidAuthorizationCredential},
+		"invalid": {token: "expired", errorType: ErrorTypeInvalidAuthorizationCredential},
+		"outage":  {token: "outage", errorType: ErrorTypeInternalError},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := &Request{Directive: RequestDirective{
+				Header:   Header{Namespace: NamespacePowerController, Name: "TurnOn"},
+				Endpoint: RequestEndpoint{Scope: Scope{Type: "BearerToken", Token: test.token}},
+				Payload:  EmptyPayload,
+			}}
+			resp, err := handler.HandleRequest(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if test.errorType == "" {
+				if resp.Event.Header.Name != "Response" || resp.Context.Properties[0].Name != "user-abc" {
+					t.Errorf("expected the user id to be passed on: %+v", resp)
+				}
+				return
+			}
+
+			var payload struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil {
+				t.Fatalf("failed to unmarshal payload: %v", err)
+			}
+			if resp.Event.Header.Name != "ErrorResponse" || payload.Type != test.errorType {
+				t.Errorf("expected %s, got %s %s", test.errorType, resp.Event.Header.Name, payload.Type)
+			}
+		})
+	}
+}
+
+func TestProfileUserIDReaderInvalidToken(t *testing.T) {
+	for status, invalid := range map[int]bool{
+		http.StatusBadRequest:          true,
+		http.StatusUnauthorized:        true,
+		http.StatusInternalServerError: false,
+	} {
+		reader := &ProfileUserIDReader{HTTPDoer: httpDoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: status,
+				Status:     http.StatusText(status),
+				Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+			}, nil
+		})}
+
+		_, err := reader.Read(context.Background(), "token")
+		if err == nil || errors.Is(err, ErrInvalidToken) != invalid {
+			t.Errorf("%d: expected invalid token %t, got %v", status, invalid, err)
+		}
+	}
+}
+
+func TestProfileUserIDReaderURL(t *testing.T) {
+	tests := map[string]struct {
+		profileURL string
+		expected   string
+	}{
+		"default":  {expected: DefaultProfileURL},
+		"override": {profileURL: "https://api.amazon.co.uk/user/profile", expected: "https://api.amazon.co.uk/user/profile"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			reader := &ProfileUserIDReader{
+				ProfileURL: test.profileURL,
+				HTTPDoer: httpDoerFunc(func(req *http.Request) (*http.Response, error) {
+					if req.URL.String() != test.expected {
+						t.Errorf("expected request to %s, got %s", test.expected, req.URL)
+					}
+					if req.Header.Get("Authorization") != "Bearer token" {
+						t.Errorf("unexpected authorization: %s", req.Header.Get("Authorization"))
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(strings.NewReader(`{"user_id":"amzn1.account.1"}`)),
+					}, nil
+				}),
+			}
+
+			userID, err := reader.Read(context.Background(), "token")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if userID != "amzn1.account.1" {
+				t.Errorf("unexpected user id: %s", userID)
+			}
+		})
+	}
+}
+
+func TestEndpointOwnershipHandler(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	ownership := StaticEndpointOwnership{"user-1": {"lamp", "fan"}}
+	handler := EndpointOwnershipHandler(
+		EndpointOwnershipFunc(func(ctx context.Context, userID, endpointID string) (bool, error) {
+			if endpointID == "broken" {
+				return false, errors.New("registry unavailable")
+			}
+			return ownership.OwnsEndpoint(ctx, userID, endpointID)
+		}),
+		rb,
+		HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			return rb.BasicResponse(req), nil
+		}))
+
+	tests := map[string]struct {
+		userID     string
+		endpointID string
+		name       string
+		err        bool
+	}{
+		"owned":               {userID: "user-1", endpointID: "fan", name: "Response"},
+		"not owned":           {userID: "user-2", endpointID: "fan", name: "ErrorResponse"},
+		"unknown endpoint":    {userID: "user-1", endpointID: "heater", name: "ErrorResponse"},
+		"no endpoint":         {userID: "user-2", name: "Response"},
+		"no user id":          {endpointID: "fan", err: true},
+		"ownership unchecked": {userID: "user-1", endpointID: "broken", err: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.userID != "" {
+				ctx = WithUserID(ctx, test.userID)
+			}
+			req := &Request{Directive: RequestDirective{
+				Header:   Header{Namespace: NamespacePowerController, Name: "TurnOn"},
+				Endpoint: RequestEndpoint{EndpointID: test.endpointID},
+				Payload:  EmptyPayload,
+			}}
+
+			resp, err := handler(ctx, req)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Event.Header.Name != test.name {
+				t.Fatalf("expected %s, got %s", test.name, resp.Event.Header.Name)
+			}
+			if test.name == "ErrorResponse" && !strings.Contains(string(resp.Event.Payload), ErrorTypeNoSuchEndpoint) {
+				t.Errorf("expected %s in %s", ErrorTypeNoSuchEndpoint, resp.Event.Payload)
+			}
+		})
+	}
+}
+
+func TestErrorReportingHandler(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	handlerErr := errors.New("device offline")
+
+	tests := map[string]struct {
+		handler HandlerFunc
+		err     error
+		panics  bool
+	}{
+		"success": {
+			handler: func(ctx context.Context, req *Request) (*Response, error) {
+				return rb.BasicResponse(req), nil
+			},
+		},
+		"error": {
+			handler: func(ctx context.Context, req *Request) (*Response, error) {
+				return nil, handlerErr
+			},
+			err: handlerErr,
+		},
+		"panic": {
+			handler: func(ctx context.Context, req *Request) (*Response, error) {
+				panic("boom")
+			},
+			panics: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := &Request{Directive: RequestDirective{Header: Header{Namespace: NamespacePowerController, Name: "TurnOn"}}}
+			var reported []error
+			reporter := ErrorReporterFunc(func(ctx context.Context, r *Request, err error) {
+				if r != req {
+					t.Errorf("expected the failed request to be reported")
+				}
+				reported = append(reported, err)
+			})
+
+			resp, err := ErrorReportingHandler(reporter, test.handler)(context.Background(), req)
+
+			if test.panics {
+				var panicErr *PanicError
+				if !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+					t.Fatalf("expected PanicError, got %v", err)
+				}
+				test.err = err
+			}
+			if err != test.err {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if test.err == nil {
+				if resp == nil || len(reported) != 0 {
+					t.Errorf("expected response without reports, got %v %v", resp, reported)
+				}
+				return
+			}
+			if resp != nil {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+			if len(reported) != 1 || reported[0] != err {
+				t.Errorf("expected the error to be reported once, got %v", reported)
+			}
+		})
+	}
+}
+
+func TestMemoryMessageIDStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := &MemoryMessageIDStore{Now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	markSeen := func(messageID string, expected bool) {
+		t.Helper()
+		seen, err := store.MarkSeen(ctx, messageID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if seen != expected {
+			t.Errorf("%s: expected seen %t but got %t", messageID, expected, seen)
+		}
+	}
+
+	markSeen("msg-1", false)
+	markSeen("msg-1", true)
+
+	// the zero TTL defaults rather than disabling dedupe
+	now = now.Add(DefaultMessageIDTTL - time.Second)
+	markSeen("msg-1", true)
+	markSeen("msg-2", false)
+
+	now = now.Add(time.Second)
+	markSeen("msg-1", false)
+	markSeen("msg-2", true)
+
+	if err := store.Release(ctx, "msg-2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	markSeen("msg-2", false)
+
+	// the stale expiry of the released id doesn't drop its new entry
+	now = now.Add(DefaultMessageIDTTL - time.Second)
+	markSeen("msg-3", false)
+	markSeen("msg-2", true)
+
+	now = now.Add(DefaultMessageIDTTL)
+	markSeen("msg-4", false)
+	if len(store.seen) != 1 || len(store.expiry) != 1 {
+		t.Errorf("expected expired ids to be dropped: %v %v", store.seen, store.expiry)
+	}
+}
+
+func TestDedupeHandler(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	calls := 0
+	fail := true
+	handler := DedupeHandler(&MemoryMessageIDStore{},
+		HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			calls++
+			if fail {
+				return nil, errors.New("device offline")
+			}
+			return rb.BasicResponse(req), nil
+		}),
+		HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			return rb.BasicErrorResponse(req, ErrorTypeInternalError, "duplicate")
+		}))
+
+	req := &Request{Directive: RequestDirective{
+		Header:  Header{Namespace: NamespacePowerController, Name: "TurnOn", MessageID: "directive-1"},
+		Payload: EmptyPayload,
+	}}
+
+	if _, err := handler.HandleRequest(context.Background(), req); err == nil {
+		t.Fatal("expected the handler error")
+	}
+
+	// failures release the message id so a retry is handled
+	fail = false
+	resp, err := handler.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Event.Header.Name != "Response" {
+		t.Errorf("expected a response but got %s", resp.Event.Header.Name)
+	}
+
+	resp, err = handler.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Event.Header.Name != "ErrorResponse" {
+		t.Errorf("expected the duplicate handler response but got %s", resp.Event.Header.Name)
+	}
+	if calls != 2 {
+		t.Errorf("expected the handler to be called twice but got %d", calls)
+	}
+}
+
+func TestMemoryResponseStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := &MemoryResponseStore{Now: func() time.Time { return now }}
+	ctx := context.Background()
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	req := &Request{Directive: RequestDirective{
+		Header:  Header{Namespace: NamespacePowerController, Name: "TurnOn", MessageID: "directive-1"},
+		Payload: EmptyPayload,
+	}}
+
+	resp := rb.BasicResponse(req, ContextProperty{
+		Namespace: NamespacePowerController,
+		Name:      "powerState",
+		Value:     json.RawMessage(`"ON"`),
+	})
+	if err := store.Put(ctx, "directive-1", resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// changes to the response after it's recorded aren't replayed
+	resp.Context.Properties[0].Value = json.RawMessage(`"OFF"`)
+
+	recorded, err := store.Get(ctx, "directive-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(recorded.Context.Properties[0].Value) != `"ON"` {
+		t.Errorf("expected the recorded value but got %s", recorded.Context.Properties[0].Value)
+	}
+
+	// changes to a replayed response don't affect later replays
+	recorded.Context.Properties[0].Value = json.RawMessage(`"OFF"`)
+	recorded.Event.Header.MessageID = "changed"
+	recorded, err = store.Get(ctx, "directive-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(recorded.Context.Properties[0].Value) != `"ON"` || recorded.Event.Header.MessageID != "msg-1" {
+		t.Errorf("expected the recorded response but got %+v", recorded)
+	}
+
+	// the zero TTL defaults rather than expiring immediately
+	now = now.Add(DefaultMessageIDTTL)
+	recorded, err = store.Get(ctx, "directive-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if recorded != nil {
+		t.Errorf("expected the response to expire but got %+v", recorded)
+	}
+
+	if err := store.Put(ctx, "directive-2", resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.responses) != 1 || len(store.expiry) != 1 {
+		t.Errorf("expected expired responses to be dropped: %v %v", store.responses, store.expiry)
+	}
+}
+
+func TestIdempotentHandler(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	calls := 0
+	handler := IdempotentHandler(&MemoryResponseStore{},
+		HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			calls++
+			if calls == 1 {
+				return nil, errors.New("device offline")
+			}
+			return rb.BasicResponse(req), nil
+		}))
+
+	req := &Request{Directive: RequestDirective{
+		Header:  Header{Namespace: NamespacePowerController, Name: "TurnOn", MessageID: "directive-1"},
+		Payload: EmptyPayload,
+	}}
+
+	if _, err := handler.HandleRequest(context.Background(), req); err == nil {
+		t.Fatal("expected the handler error")
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := handler.HandleRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Event.Header.Name != "Response" {
+			t.Errorf("expected a response but got %s", resp.Event.Header.Name)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected failures to be retried and successes replayed but got %d calls", calls)
+	}
+}
@@ -0,0 +1,129 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PropertyMode is the Alexa.ModeController property holding the current mode value
+const PropertyMode = "mode"
+
+// ModeControllerConfiguration is the discovery configuration of a mode instance. Ordered
+// modes, e.g. fan speeds, can also be adjusted up and down by AdjustMode.
+type ModeControllerConfiguration struct {
+	Ordered        bool            `json:"ordered"`
+	SupportedModes []SupportedMode `json:"supportedModes"`
+}
+
+// SupportedMode is a mode value, e.g. Wash.Cycle.Delicates, with the names users say for it
+type SupportedMode struct {
+	Value         string              `json:"value"`
+	ModeResources CapabilityResources `json:"modeResources"`
+}
+
+// Supports reports if mode is one of the supported modes
+func (c ModeControllerConfiguration) Supports(mode string) bool {
+	return c.index(mode) >= 0
+}
+
+// Adjust returns the ordered mode delta steps from current, stopping at the first or last mode
+func (c ModeControllerConfiguration) Adjust(current string, delta int) (string, error) {
+	if !c.Ordered {
+		return "", fmt.Errorf("modes aren't ordered")
+	}
+	i := c.index(current)
+	if i < 0 {
+		return "", fmt.Errorf("unsupported mode %q", current)
+	}
+	i += delta
+	if i < 0 {
+		i = 0
+	}
+	if last := len(c.SupportedModes) - 1; i > last {
+		i = last
+	}
+	return c.SupportedModes[i].Value, nil
+}
+
+func (c ModeControllerConfiguration) index(mode string) int {
+	for i, supported := range c.SupportedModes {
+		if supported.Value == mode {
+			return i
+		}
+	}
+	return -1
+}
+
+type SetModePayload struct {
+	Mode string `json:"mode"`
+}
+
+type AdjustModePayload struct {
+	ModeDelta int `json:"modeDelta"`
+}
+
+// ModeControllerHandler routes handling of set & adjust mode directives for a single
+// instance. adjustMode may be nil for modes that aren't ordered. Use InstanceHandler to
+// route endpoints with several instances.
+func ModeControllerHandler(setMode, adjustMode Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "SetMode":
+			return setMode.HandleRequest(ctx, req)
+		case "AdjustMode":
+			if adjustMode != nil {
+				return adjustMode.HandleRequest(ctx, req)
+			}
+		}
+		return nil, UnexpectedDirective("ModeControllerHandler", req)
+	}
+}
+
+// ModeProperty creates a mode property of instance
+func ModeProperty(instance, mode string, timeOfSample time.Time) (ContextProperty, error) {
+	prop, err := NewProperty(NamespaceModeController, PropertyMode, mode, timeOfSample)
+	if err != nil {
+		return ContextProperty{}, err
+	}
+	prop.Instance = instance
+	return prop, nil
+}
+
+// OrderedModeSemantics maps raise and lower to adjusting an ordered mode by one step
+func OrderedModeSemantics() (*Semantics, error) {
+	raise, err := ActionsToDirective("AdjustMode", AdjustModePayload{ModeDelta: 1}, ActionRaise)
+	if err != nil {
+		return nil, err
+	}
+	lower, err := ActionsToDirective("AdjustMode", AdjustModePayload{ModeDelta: -1}, ActionLower)
+	if err != nil {
+		return nil, err
+	}
+	return &Semantics{ActionMappings: []ActionMapping{raise, lower}}, nil
+}
+
+// NewModeControllerCapability creates the discovery capability of a mode instance named
+// by resources. semantics may be nil.
+func NewModeControllerCapability(instance string, resources CapabilityResources,
+	config ModeControllerConfiguration, semantics *Semantics) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: InterfaceModeController,
+		Instance:  instance,
+		Version:   "3",
+		Properties: &DiscoverProperties{
+			Supported:           []DiscoverProperty{{Name: PropertyMode}},
+			ProactivelyReported: true,
+			Retrievable:         true,
+		},
+		CapabilityResources: &resources,
+		Configuration:       configJSON,
+		Semantics:           semantics,
+	}, nil
+}
@@ -0,0 +1,14 @@
+package alexa
+
+import "time"
+
+// NewMotionSensorCapability creates the discovery capability of a motion sensor. Its
+// detectionState should be proactively reported so routines trigger on motion.
+func NewMotionSensorCapability() DiscoverCapability {
+	return detectionSensorCapability(InterfaceMotionSensor)
+}
+
+// MotionSensorProperty creates a detectionState property that's DETECTED if motion was detected
+func MotionSensorProperty(motion bool, timeOfSample time.Time) (ContextProperty, error) {
+	return detectionStateProperty(NamespaceMotionSensor, motion, timeOfSample)
+}
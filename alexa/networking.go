@@ -0,0 +1,146 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PropertyNetworkAccess is the Alexa.Networking.AccessController property holding a
+// NetworkAccess enum
+const PropertyNetworkAccess = "networkAccess"
+
+// NetworkAccess enums
+const (
+	NetworkAccessAllowed = "ALLOWED"
+	NetworkAccessBlocked = "BLOCKED"
+)
+
+// NewHomeNetworkControllerCapability creates the discovery capability of the endpoint
+// representing a router or mesh network
+func NewHomeNetworkControllerCapability() DiscoverCapability {
+	return DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: InterfaceNetworkingHomeNetworkController,
+		Version:   "1.0",
+	}
+}
+
+// ConnectedDeviceConfiguration is the discovery configuration of a device connected to a
+// home network
+type ConnectedDeviceConfiguration struct {
+	StaticDeviceInformation StaticDeviceInformation `json:"staticDeviceInformation"`
+}
+
+// StaticDeviceInformation identifies a connected device. Only the MAC address is required.
+type StaticDeviceInformation struct {
+	DeviceName       string `json:"deviceName,omitempty"`
+	Hostname         string `json:"hostname,omitempty"`
+	MacAddress       string `json:"macAddress"`
+	DHCPDeviceVendor string `json:"dhcpDeviceVendor,omitempty"`
+}
+
+// NewConnectedDeviceCapability creates the discovery capability of a connected device
+func NewConnectedDeviceCapability(config ConnectedDeviceConfiguration) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:          "AlexaInterface",
+		Interface:     InterfaceNetworkingConnectedDevice,
+		Version:       "1.0",
+		Configuration: configJSON,
+	}, nil
+}
+
+// AccessControllerConfiguration is the discovery configuration of a connected device whose
+// network access can be controlled. SupportsScheduling is set if access can be blocked for
+// a scheduled window, see NetworkAccessSchedule.
+type AccessControllerConfiguration struct {
+	SupportsScheduling bool `json:"supportsScheduling"`
+}
+
+// NewAccessControllerCapability creates the discovery capability of a connected device
+// whose network access can be controlled
+func NewAccessControllerCapability(config AccessControllerConfiguration) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: InterfaceNetworkingAccessController,
+		Version:   "1.0",
+		Properties: &DiscoverProperties{
+			Supported:           []DiscoverProperty{{Name: PropertyNetworkAccess}},
+			ProactivelyReported: true,
+			Retrievable:         true,
+		},
+		Configuration: configJSON,
+	}, nil
+}
+
+// NetworkAccessSchedule limits a network access change to a window, e.g. to block a device
+// for an hour
+type NetworkAccessSchedule struct {
+	Start time.Time `json:"start"`
+	// Duration is an ISO 8601 duration, e.g. PT1H
+	Duration string `json:"duration"`
+}
+
+// Window returns the start and end of the schedule
+func (s NetworkAccessSchedule) Window() (time.Time, time.Time, error) {
+	return ThermostatSchedule(s).Window()
+}
+
+// Validate checks that the schedule has a start and positive duration
+func (s NetworkAccessSchedule) Validate() error {
+	return ThermostatSchedule(s).Validate()
+}
+
+// SetNetworkAccessPayload allows or blocks the network access of a connected device.
+// Schedule is only set for devices that support scheduling.
+type SetNetworkAccessPayload struct {
+	NetworkAccess string                 `json:"networkAccess"`
+	Schedule      *NetworkAccessSchedule `json:"schedule,omitempty"`
+}
+
+// Validate checks that the access is a NetworkAccess enum and any schedule is valid
+func (p SetNetworkAccessPayload) Validate() error {
+	if p.NetworkAccess != NetworkAccessAllowed && p.NetworkAccess != NetworkAccessBlocked {
+		return fmt.Errorf("unknown network access %q", p.NetworkAccess)
+	}
+	if p.Schedule != nil {
+		return p.Schedule.Validate()
+	}
+	return nil
+}
+
+// AccessControllerHandler routes SetNetworkAccess directives that allow access to
+// enableAccess and those that block it to disableAccess
+func AccessControllerHandler(enableAccess, disableAccess Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		if req.DirectiveName() != "SetNetworkAccess" {
+			return nil, UnexpectedDirective("AccessControllerHandler", req)
+		}
+		var payload SetNetworkAccessPayload
+		if err := req.DecodePayload(&payload); err != nil {
+			return nil, fmt.Errorf("failed to decode payload: %v", err)
+		}
+		switch payload.NetworkAccess {
+		case NetworkAccessAllowed:
+			return enableAccess.HandleRequest(ctx, req)
+		case NetworkAccessBlocked:
+			return disableAccess.HandleRequest(ctx, req)
+		default:
+			return nil, fmt.Errorf("unknown network access %q", payload.NetworkAccess)
+		}
+	}
+}
+
+// NetworkAccessProperty creates a networkAccess property
+func NetworkAccessProperty(access string, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceNetworkingAccessController, PropertyNetworkAccess, access, timeOfSample)
+}
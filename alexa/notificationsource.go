@@ -0,0 +1,14 @@
+package alexa
+
+// NewProactiveNotificationSourceCapability creates the discovery capability of an endpoint
+// that sends proactive events Alexa may announce as notifications, e.g. a doorbell or a
+// sensor detecting a leak. Build the events with ProactiveEvent.
+func NewProactiveNotificationSourceCapability() DiscoverCapability {
+	proactivelyReported := true
+	return DiscoverCapability{
+		Type:                "AlexaInterface",
+		Interface:           InterfaceProactiveNotificationSource,
+		Version:             "3",
+		ProactivelyReported: &proactivelyReported,
+	}
+}
@@ -0,0 +1,55 @@
+package alexa
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// payloadCache holds the payloads decoded by Request.DecodePayload keyed by type
+type payloadCache struct {
+	decoded map[reflect.Type]reflect.Value
+}
+
+// DecodePayload unmarshals the directive payload into v, which must be a non-nil pointer.
+// The result is cached per type so middleware and handlers that both inspect the payload
+// only unmarshal it once. Later calls receive a shallow copy of the cached value so
+// slices and maps in it should be treated as read only. Replace the payload with
+// SetPayload so values decoded from the old payload are discarded.
+// DecodePayload is not safe for concurrent use on the same Request, use Clone to give
+// each goroutine its own copy.
+func (r *Request) DecodePayload(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("DecodePayload requires a non-nil pointer, got %T", v)
+	}
+	target := rv.Elem()
+
+	cache := r.payloadCache
+	if cache == nil {
+		cache = &payloadCache{decoded: make(map[reflect.Type]reflect.Value)}
+		r.payloadCache = cache
+	}
+
+	if decoded, ok := cache.decoded[target.Type()]; ok {
+		target.Set(decoded)
+		return nil
+	}
+
+	if err := json.Unmarshal(r.Directive.Payload, v); err != nil {
+		return err
+	}
+
+	decoded := reflect.New(target.Type()).Elem()
+	decoded.Set(target)
+	cache.decoded[target.Type()] = decoded
+
+	return nil
+}
+
+// SetPayload replaces the directive payload and discards anything DecodePayload decoded
+// from the old payload
+func (r *Request) SetPayload(payload json.RawMessage) {
+	r.Directive.Payload = payload
+	r.payloadCache = nil
+}
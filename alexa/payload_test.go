@@ -0,0 +1,136 @@
+package alexa
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestDecodePayload(t *testing.T) {
+	req := &Request{
+		Directive: RequestDirective{
+			Payload: json.RawMessage(`{"percentage": 42}`),
+		},
+	}
+
+	var first SetPercentagePayload
+	if err := req.DecodePayload(&first); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if first.Percentage != 42 {
+		t.Errorf("expected 42, got %d", first.Percentage)
+	}
+
+	// a cached value is returned for the same type even though the payload
+	// no longer unmarshals
+	req.Directive.Payload[15] = 'x'
+	var second SetPercentagePayload
+	if err := req.DecodePayload(&second); err != nil {
+		t.Fatalf("expected cached decode: %v", err)
+	}
+	if second.Percentage != 42 {
+		t.Errorf("expected cached 42, got %d", second.Percentage)
+	}
+
+	req.SetPayload(json.RawMessage(`{"percentage": 7}`))
+	var replaced SetPercentagePayload
+	if err := req.DecodePayload(&replaced); err != nil {
+		t.Fatalf("failed to decode replaced payload: %v", err)
+	}
+	if replaced.Percentage != 7 {
+		t.Errorf("expected 7 after replacing payload, got %d", replaced.Percentage)
+	}
+
+	if err := json.Unmarshal([]byte(`{"directive":{"payload":{"percentage": 9}}}`), req); err != nil {
+		t.Fatalf("failed to unmarshal request: %v", err)
+	}
+	var redecoded SetPercentagePayload
+	if err := req.DecodePayload(&redecoded); err != nil {
+		t.Fatalf("failed to decode unmarshaled payload: %v", err)
+	}
+	if redecoded.Percentage != 9 {
+		t.Errorf("expected 9 after unmarshaling the request, got %d", redecoded.Percentage)
+	}
+
+	var notPointer SetPercentagePayload
+	if err := req.DecodePayload(notPointer); err == nil {
+		t.Error("expected error for non-pointer")
+	}
+}
+
+func TestRequestBearerToken(t *testing.T) {
+	tests := map[string]struct {
+		req      Request
+		expected string
+	}{
+		"endpoint": {
+			req: Request{Directive: RequestDirective{
+				Endpoint: RequestEndpoint{Scope: Scope{Type: "BearerToken", Token: "endpoint-token"}},
+				Payload:  EmptyPayload,
+			}},
+			expected: "endpoint-token",
+		},
+		"discover": {
+			req: Request{Directive: RequestDirective{
+				Payload: json.RawMessage(`{"scope":{"type":"BearerToken","token":"payload-token"}}`),
+			}},
+			expected: "payload-token",
+		},
+		"acceptGrant": {
+			req: Request{Directive: RequestDirective{
+				Payload: json.RawMessage(`{"grant":{"type":"OAuth2.AuthorizationCode","code":"code"},` +
+					`"grantee":{"type":"BearerToken","token":"grantee-token"}}`),
+			}},
+			expected: "grantee-token",
+		},
+		"none": {
+			req:      Request{Directive: RequestDirective{Payload: EmptyPayload}},
+			expected: "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if token := test.req.BearerToken(); token != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, token)
+			}
+		})
+	}
+}
+
+func TestDecodePayloadClones(t *testing.T) {
+	req := &Request{
+		Directive: RequestDirective{
+			Payload: json.RawMessage(`{"percentage": 42}`),
+		},
+	}
+
+	var first SetPercentagePayload
+	if err := req.DecodePayload(&first); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clone := req.Clone()
+			var p SetPercentagePayload
+			if err := clone.DecodePayload(&p); err != nil {
+				errs <- err
+				return
+			}
+			var b SetBrightnessPayload
+			if err := clone.DecodePayload(&b); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("failed to decode clone: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package alexa
+
+// Validate checks that the percentage is within 0 to 100
+func (p SetPercentagePayload) Validate() error {
+	return validateRange("percentage", float64(p.Percentage), 0, 100)
+}
+
+// Validate checks that the delta is within -100 to 100
+func (p AdjustPercentagePayload) Validate() error {
+	return validateRange("percentageDelta", float64(p.PercentageDelta), -100, 100)
+}
+
+// validateRange returns a ValueOutOfRangeError if value isn't within min and max
+func validateRange(field string, value, min, max float64) error {
+	if value < min || value > max {
+		return &ValueOutOfRangeError{Field: field, Value: value, Min: min, Max: max}
+	}
+	return nil
+}
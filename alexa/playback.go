@@ -0,0 +1,73 @@
+package alexa
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// PlaybackOperation enums. They're also the names of the Alexa.PlaybackController directives.
+const (
+	PlaybackOperationFastForward = "FastForward"
+	PlaybackOperationNext        = "Next"
+	PlaybackOperationPause       = "Pause"
+	PlaybackOperationPlay        = "Play"
+	PlaybackOperationPrevious    = "Previous"
+	PlaybackOperationRewind      = "Rewind"
+	PlaybackOperationStartOver   = "StartOver"
+	PlaybackOperationStop        = "Stop"
+)
+
+var playbackOperations = []string{PlaybackOperationFastForward, PlaybackOperationNext,
+	PlaybackOperationPause, PlaybackOperationPlay, PlaybackOperationPrevious,
+	PlaybackOperationRewind, PlaybackOperationStartOver, PlaybackOperationStop}
+
+// IsPlaybackOperation reports if operation is a PlaybackOperation enum
+func IsPlaybackOperation(operation string) bool {
+	return contains(playbackOperations, operation)
+}
+
+// PlaybackControllerHandler routes playback directives to the handler of the operation.
+// Only the operations with a non-nil handler are supported, see PlaybackOperations. It
+// panics if handlers has a key that isn't a PlaybackOperation enum.
+func PlaybackControllerHandler(handlers map[string]Handler) HandlerFunc {
+	supported := make(map[string]Handler, len(handlers))
+	for operation, handler := range handlers {
+		if !IsPlaybackOperation(operation) {
+			panic(fmt.Sprintf("alexa: PlaybackControllerHandler has unknown operation %q", operation))
+		}
+		if handler != nil {
+			supported[operation] = handler
+		}
+	}
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		if handler, ok := supported[req.DirectiveName()]; ok {
+			return handler.HandleRequest(ctx, req)
+		}
+		return nil, UnexpectedDirective("PlaybackControllerHandler", req)
+	}
+}
+
+// PlaybackOperations returns the operations of handlers with a non-nil handler in sorted
+// order, skipping keys that aren't PlaybackOperation enums
+func PlaybackOperations(handlers map[string]Handler) []string {
+	operations := make([]string, 0, len(handlers))
+	for operation, handler := range handlers {
+		if handler != nil && IsPlaybackOperation(operation) {
+			operations = append(operations, operation)
+		}
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+// NewPlaybackControllerCapability creates the discovery capability of an endpoint that
+// supports the operations
+func NewPlaybackControllerCapability(operations ...string) DiscoverCapability {
+	return DiscoverCapability{
+		Type:                "AlexaInterface",
+		Interface:           InterfacePlaybackController,
+		Version:             "3",
+		SupportedOperations: operations,
+	}
+}
@@ -0,0 +1,31 @@
+package alexa
+
+import (
+	"fmt"
+	"time"
+)
+
+// PropertyPlaybackState is the Alexa.PlaybackStateReporter property holding the
+// PlaybackStateValue of an endpoint
+const PropertyPlaybackState = "playbackState"
+
+// PlaybackState enums
+const (
+	PlaybackStatePaused  = "PAUSED"
+	PlaybackStatePlaying = "PLAYING"
+	PlaybackStateStopped = "STOPPED"
+)
+
+var playbackStates = []string{PlaybackStatePaused, PlaybackStatePlaying, PlaybackStateStopped}
+
+type PlaybackStateValue struct {
+	State string `json:"state"`
+}
+
+// PlaybackStateProperty creates a playbackState property. state must be a PlaybackState enum.
+func PlaybackStateProperty(state string, timeOfSample time.Time) (ContextProperty, error) {
+	if !contains(playbackStates, state) {
+		return ContextProperty{}, fmt.Errorf("unknown playback state %q", state)
+	}
+	return NewProperty(NamespacePlaybackStateReporter, PropertyPlaybackState, PlaybackStateValue{State: state}, timeOfSample)
+}
@@ -0,0 +1,99 @@
+package alexa
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// pooledResponse holds a response along with the structs and buffers it points to so
+// they can be reused together
+type pooledResponse struct {
+	resp     Response
+	endpoint ResponseEndpoint
+	context  ResponseContext
+	props    []ContextProperty
+	payload  []byte
+}
+
+var responsePool = sync.Pool{
+	New: func() interface{} { return &pooledResponse{} },
+}
+
+// maxPooledProperties limits the property slices kept in the pool
+const maxPooledProperties = 64
+
+func (p *pooledResponse) release() {
+	if cap(p.props) > maxPooledProperties || cap(p.payload) > 64<<10 {
+		return
+	}
+	// clear references so pooled responses don't keep property values alive
+	for i := range p.props {
+		p.props[i] = ContextProperty{}
+	}
+	p.props = p.props[:0]
+	p.payload = p.payload[:0]
+	p.resp = Response{}
+	p.endpoint = ResponseEndpoint{}
+	p.context = ResponseContext{}
+	responsePool.Put(p)
+}
+
+// WithChangeReport builds the same ChangeReport as ChangeReport but from pooled structs
+// and buffers, then passes it to fn. This avoids allocations for agents sending a high
+// volume of reports such as power meters. The response, its payload and properties are
+// reused once fn returns so fn must not modify or retain them. Encode or send the
+// response within fn instead.
+func (r *ResponseBuilder) WithChangeReport(scope Scope, endpointID, cause string,
+	changed []ContextProperty, unchanged []ContextProperty, fn func(resp *Response) error) error {
+	if len(changed) == 0 {
+		return errors.New("change report requires a changed property")
+	}
+	changed, err := r.validateProperties(changed)
+	if err != nil {
+		return err
+	}
+
+	p := responsePool.Get().(*pooledResponse)
+	defer p.release()
+
+	e := newEncoder()
+	err = e.changeReportPayload(cause, changed)
+	if err == nil {
+		p.payload = append(p.payload[:0], e.buf.Bytes()...)
+	}
+	e.release()
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	p.endpoint = ResponseEndpoint{
+		EndpointID: endpointID,
+		Scope:      scope,
+	}
+	p.resp = Response{
+		Event: Event{
+			Header: Header{
+				Namespace:      NamespaceAlexa,
+				Name:           "ChangeReport",
+				PayloadVersion: "3",
+				MessageID:      r.MessageID(),
+			},
+			Endpoint: &p.endpoint,
+			Payload:  p.payload,
+		},
+	}
+	if len(unchanged) > 0 {
+		p.props = append(p.props[:0], unchanged...)
+		r.normalizePropertiesInPlace(p.props)
+		for _, property := range p.props {
+			if err := ValidateProperty(property); err != nil {
+				return err
+			}
+		}
+		p.context.Properties = p.props
+		p.resp.Context = &p.context
+	}
+
+	return fn(&p.resp)
+}
@@ -0,0 +1,102 @@
+package alexa
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ValidateProperty checks that property has the fields the smart home api requires:
+// a namespace, name, valid json value, sample time and non-negative uncertainty.
+func ValidateProperty(property ContextProperty) error {
+	switch {
+	case property.Namespace == "":
+		return fmt.Errorf("property %s has no namespace", property.Name)
+	case property.Name == "":
+		return fmt.Errorf("%s property has no name", property.Namespace)
+	case len(property.Value) == 0 || !json.Valid(property.Value):
+		return fmt.Errorf("%s.%s value is not valid json", property.Namespace, property.Name)
+	case property.TimeOfSample.IsZero():
+		return fmt.Errorf("%s.%s has no timeOfSample", property.Namespace, property.Name)
+	case property.UncertaintyInMilliseconds < 0:
+		return fmt.Errorf("%s.%s has negative uncertaintyInMilliseconds", property.Namespace, property.Name)
+	}
+	return nil
+}
+
+// NormalizeProperty applies defaults to sloppy property data: a zero TimeOfSample is
+// set to now, the time is converted to UTC and a negative uncertainty is set to 0.
+func NormalizeProperty(property ContextProperty, now time.Time) ContextProperty {
+	if property.TimeOfSample.IsZero() {
+		property.TimeOfSample = now
+	}
+	property.TimeOfSample = property.TimeOfSample.UTC()
+	if property.UncertaintyInMilliseconds < 0 {
+		property.UncertaintyInMilliseconds = 0
+	}
+	return property
+}
+
+// needsNormalizing reports if NormalizeProperty would change the encoded property
+func needsNormalizing(property *ContextProperty) bool {
+	if property.TimeOfSample.IsZero() || property.UncertaintyInMilliseconds < 0 {
+		return true
+	}
+	// times in a zero offset zone already encode the same as UTC
+	_, offset := property.TimeOfSample.Zone()
+	return offset != 0
+}
+
+// normalizeProperties returns properties with NormalizeProperty applied. The slice is
+// only copied if a property needs changing so the caller's data isn't modified.
+func (r *ResponseBuilder) normalizeProperties(properties []ContextProperty) []ContextProperty {
+	for i := range properties {
+		if !needsNormalizing(&properties[i]) {
+			continue
+		}
+
+		now := time.Now()
+		normalized := make([]ContextProperty, len(properties))
+		copy(normalized, properties[:i])
+		for j := i; j < len(properties); j++ {
+			normalized[j] = NormalizeProperty(properties[j], now)
+		}
+		return normalized
+	}
+	return properties
+}
+
+// normalizePropertiesInPlace applies NormalizeProperty to properties owned by the builder
+func (r *ResponseBuilder) normalizePropertiesInPlace(properties []ContextProperty) {
+	for i := range properties {
+		if needsNormalizing(&properties[i]) {
+			properties[i] = NormalizeProperty(properties[i], time.Now())
+		}
+	}
+}
+
+// validateProperties normalizes and validates properties
+func (r *ResponseBuilder) validateProperties(properties []ContextProperty) ([]ContextProperty, error) {
+	properties = r.normalizeProperties(properties)
+	for _, property := range properties {
+		if err := ValidateProperty(property); err != nil {
+			return nil, err
+		}
+	}
+	return properties, nil
+}
+
+// NewProperty creates a property of an endpoint with value marshaled to json. A zero
+// timeOfSample is set to the current time when the property is sent.
+func NewProperty(namespace, name string, value interface{}, timeOfSample time.Time) (ContextProperty, error) {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return ContextProperty{}, fmt.Errorf("failed to marshal %s.%s: %v", namespace, name, err)
+	}
+	return ContextProperty{
+		Namespace:    namespace,
+		Name:         name,
+		Value:        valueJSON,
+		TimeOfSample: timeOfSample,
+	}, nil
+}
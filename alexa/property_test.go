@@ -0,0 +1,78 @@
+package alexa
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestResponseBuilderNormalizesProperties(t *testing.T) {
+	sampled := time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+
+	properties := []ContextProperty{
+		{
+			Namespace:                 NamespacePowerController,
+			Name:                      "powerState",
+			Value:                     json.RawMessage(`"ON"`),
+			UncertaintyInMilliseconds: -1,
+		},
+		{
+			Namespace:    NamespaceEndpointHealth,
+			Name:         "connectivity",
+			Value:        json.RawMessage(`{"value":"OK"}`),
+			TimeOfSample: sampled.In(time.FixedZone("PST", -8*60*60)),
+		},
+	}
+
+	before := time.Now()
+	resp := rb.BasicResponse(&Request{}, properties...)
+	after := time.Now()
+	for _, property := range resp.Context.Properties {
+		if property.TimeOfSample.Location() != time.UTC || property.UncertaintyInMilliseconds != 0 {
+			t.Errorf("property not normalized: %+v", property)
+		}
+	}
+	if sampled := resp.Context.Properties[0].TimeOfSample; sampled.Before(before) || sampled.After(after) {
+		t.Errorf("expected missing time of sample to default to now, got %v", sampled)
+	}
+	if !resp.Context.Properties[1].TimeOfSample.Equal(sampled) {
+		t.Errorf("expected time of sample to be kept, got %v", resp.Context.Properties[1].TimeOfSample)
+	}
+	if !properties[0].TimeOfSample.IsZero() {
+		t.Error("caller's properties were modified")
+	}
+
+	invalid := properties[0]
+	invalid.Value = json.RawMessage(`{`)
+	if _, err := rb.ChangeReport(Scope{}, "fan-1", CausePhysicalInteraction, []ContextProperty{invalid}); err == nil {
+		t.Error("expected invalid value to fail validation")
+	}
+	if _, err := rb.ChangeReport(Scope{}, "fan-1", CausePhysicalInteraction, nil, properties...); err == nil {
+		t.Error("expected change report without changed properties to fail")
+	}
+}
+
+func TestContactSensorProperty(t *testing.T) {
+	sampled := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		open     bool
+		expected string
+	}{
+		"open":   {open: true, expected: `"DETECTED"`},
+		"closed": {open: false, expected: `"NOT_DETECTED"`},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			property, err := ContactSensorProperty(test.open, sampled)
+			if err != nil {
+				t.Fatalf("failed to create property: %v", err)
+			}
+			if property.Namespace != NamespaceContactSensor || property.Name != PropertyDetectionState ||
+				string(property.Value) != test.expected {
+				t.Errorf("expected %s, got %+v", test.expected, property)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package alexa
+
+// raceEnabled is set when the race detector, which adds allocations, is on
+const raceEnabled = true
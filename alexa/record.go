@@ -0,0 +1,54 @@
+package alexa
+
+import (
+	"context"
+	"time"
+)
+
+// PropertyRecordingState is the Alexa.RecordController property. Unlike other properties
+// its name is capitalized.
+const PropertyRecordingState = "RecordingState"
+
+// RecordingState enums
+const (
+	RecordingStateNotRecording = "NOT_RECORDING"
+	RecordingStateRecording    = "RECORDING"
+)
+
+// RecordControllerHandler routes handling of start & stop recording directives
+func RecordControllerHandler(startRecording, stopRecording Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "StartRecording":
+			return startRecording.HandleRequest(ctx, req)
+		case "StopRecording":
+			return stopRecording.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("RecordControllerHandler", req)
+		}
+	}
+}
+
+// RecordingStateProperty creates a RecordingState property that's RECORDING if recording
+func RecordingStateProperty(recording bool, timeOfSample time.Time) (ContextProperty, error) {
+	state := RecordingStateNotRecording
+	if recording {
+		state = RecordingStateRecording
+	}
+	return NewProperty(NamespaceRecordController, PropertyRecordingState, state, timeOfSample)
+}
+
+// NewRecordControllerCapability creates the discovery capability of an endpoint that can
+// record live TV
+func NewRecordControllerCapability() DiscoverCapability {
+	return DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: InterfaceRecordController,
+		Version:   "3",
+		Properties: &DiscoverProperties{
+			Supported:           []DiscoverProperty{{Name: PropertyRecordingState}},
+			ProactivelyReported: true,
+			Retrievable:         true,
+		},
+	}
+}
@@ -0,0 +1,35 @@
+package alexa
+
+import (
+	"context"
+	"errors"
+)
+
+// SearchAndPlayPayload is the payload of SearchAndPlay and SearchAndDisplayResults
+// directives. Use Entities to pick out the entities of a type, e.g. the Actor.
+type SearchAndPlayPayload struct {
+	Entities   []SearchEntity    `json:"entities"`
+	TimeWindow *SearchTimeWindow `json:"timeWindow,omitempty"`
+}
+
+// Validate checks that an entity was requested
+func (p SearchAndPlayPayload) Validate() error {
+	if len(p.Entities) == 0 {
+		return errors.New("missing entities")
+	}
+	return nil
+}
+
+// RemoteVideoPlayerHandler routes handling of video search directives
+func RemoteVideoPlayerHandler(searchAndPlay, searchAndDisplayResults Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "SearchAndPlay":
+			return searchAndPlay.HandleRequest(ctx, req)
+		case "SearchAndDisplayResults":
+			return searchAndDisplayResults.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("RemoteVideoPlayerHandler", req)
+		}
+	}
+}
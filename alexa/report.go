@@ -0,0 +1,59 @@
+package alexa
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrorReporter is notified of failures so they can be surfaced somewhere more
+// visible than the process log. req may be nil if the failure isn't tied to a request.
+type ErrorReporter interface {
+	ReportError(ctx context.Context, req *Request, err error)
+}
+
+// ErrorReporterFunc implements ErrorReporter as a func
+type ErrorReporterFunc func(ctx context.Context, req *Request, err error)
+
+// ReportError calls the ErrorReporterFunc
+func (e ErrorReporterFunc) ReportError(ctx context.Context, req *Request, err error) {
+	e(ctx, req, err)
+}
+
+// PanicError is reported when a handler panics
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// RecoverPanic converts a recovered panic value into a PanicError. It returns nil
+// if val is nil. It should be called with the result of recover().
+func RecoverPanic(val interface{}) *PanicError {
+	if val == nil {
+		return nil
+	}
+	return &PanicError{Value: val, Stack: debug.Stack()}
+}
+
+// ErrorReportingHandler wraps handler and reports any error it returns to reporter.
+// A panic in handler is recovered, reported and returned as a PanicError.
+func ErrorReportingHandler(reporter ErrorReporter, handler Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (resp *Response, err error) {
+		defer func() {
+			if panicErr := RecoverPanic(recover()); panicErr != nil {
+				resp, err = nil, panicErr
+				reporter.ReportError(ctx, req, panicErr)
+			}
+		}()
+
+		resp, err = handler.HandleRequest(ctx, req)
+		if err != nil {
+			reporter.ReportError(ctx, req, err)
+		}
+		return resp, err
+	}
+}
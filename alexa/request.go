@@ -0,0 +1,159 @@
+package alexa
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// received is a copy of the directive as it was decoded. It's used to detect if a
+// request has been modified or copied since Raw no longer describes it then.
+type received struct {
+	request   *Request
+	directive RequestDirective
+}
+
+// UnmarshalJSON decodes the request and retains a copy of data, see Raw. Payloads
+// decoded from an earlier payload are discarded.
+func (r *Request) UnmarshalJSON(data []byte) error {
+	// plain doesn't have this method so it decodes with the default behavior
+	type plain Request
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	r.raw = append(json.RawMessage(nil), data...)
+	r.payloadCache = nil
+
+	directive := r.Directive
+	if directive.Endpoint.Cookie != nil {
+		directive.Endpoint.Cookie = make(map[string]string, len(r.Directive.Endpoint.Cookie))
+		for k, v := range r.Directive.Endpoint.Cookie {
+			directive.Endpoint.Cookie[k] = v
+		}
+	}
+	directive.Payload = append(json.RawMessage(nil), r.Directive.Payload...)
+	r.received = received{request: r, directive: directive}
+
+	return nil
+}
+
+// Raw returns the json the request was decoded from or nil if it was built in code.
+// It reflects what was actually received and isn't updated if the request is modified.
+func (r *Request) Raw() json.RawMessage {
+	return r.raw
+}
+
+// JSON returns Raw if the request was decoded from json and hasn't been modified or
+// copied since. Otherwise it marshals the request.
+func (r *Request) JSON() ([]byte, error) {
+	if r.raw != nil && r.unmodified() {
+		return r.raw, nil
+	}
+	return json.Marshal(r)
+}
+
+// unmodified reports if r is the request that was decoded and its directive still
+// matches what was received
+func (r *Request) unmodified() bool {
+	if r.received.request != r {
+		return false
+	}
+	current, original := r.Directive, r.received.directive
+	return current.Header == original.Header &&
+		current.Endpoint.Scope == original.Endpoint.Scope &&
+		current.Endpoint.EndpointID == original.Endpoint.EndpointID &&
+		reflect.DeepEqual(current.Endpoint.Cookie, original.Endpoint.Cookie) &&
+		bytes.Equal(current.Payload, original.Payload)
+}
+
+// Clone returns a shallow copy of the request that can be modified and decoded
+// independently of r. The directive's cookie is shared so replace it rather than
+// writing to it. The copy has no Raw json as it's expected to be modified.
+func (r *Request) Clone() *Request {
+	clone := *r
+	clone.payloadCache = nil
+	clone.raw = nil
+	clone.received = received{}
+	return &clone
+}
+
+// Namespace returns the directive's namespace, e.g. Alexa.PowerController
+func (r *Request) Namespace() string {
+	return r.Directive.Header.Namespace
+}
+
+// DirectiveName returns the directive's name, e.g. TurnOn
+func (r *Request) DirectiveName() string {
+	return r.Directive.Header.Name
+}
+
+// Instance returns the capability instance targeted by the directive, e.g. Fan.Oscillate.
+// It's empty for interfaces that don't support multiple instances.
+func (r *Request) Instance() string {
+	return r.Directive.Header.Instance
+}
+
+// MessageID returns the unique id of the directive
+func (r *Request) MessageID() string {
+	return r.Directive.Header.MessageID
+}
+
+// CorrelationToken returns the token that must be included in the response or
+// deferred event for the directive
+func (r *Request) CorrelationToken() string {
+	return r.Directive.Header.CorrelationToken
+}
+
+// EndpointID returns the id of the endpoint targeted by the directive. It's empty
+// for directives without an endpoint such as Discover.
+func (r *Request) EndpointID() string {
+	return r.Directive.Endpoint.EndpointID
+}
+
+// CookieValue returns the value stored under key in the endpoint's cookie
+func (r *Request) CookieValue(key string) string {
+	return r.Directive.Endpoint.Cookie[key]
+}
+
+// BearerToken returns the user's access token. It's read from the endpoint scope or,
+// for directives without an endpoint, the payload scope (Discover) or grantee
+// (AcceptGrant). An empty string is returned if there's no token.
+func (r *Request) BearerToken() string {
+	if token := r.Directive.Endpoint.Scope.Token; token != "" {
+		return token
+	}
+
+	var payload struct {
+		Scope   Scope              `json:"scope"`
+		Grantee AcceptGrantGrantee `json:"grantee"`
+	}
+	if err := r.DecodePayload(&payload); err != nil {
+		return ""
+	}
+	if payload.Scope.Token != "" {
+		return payload.Scope.Token
+	}
+	return payload.Grantee.Token
+}
+
+// Scope returns the endpoint scope or, for directives without an endpoint, the
+// payload scope. The zero Scope is returned if there's neither.
+func (r *Request) Scope() Scope {
+	if scope := r.Directive.Endpoint.Scope; scope.Type != "" {
+		return scope
+	}
+
+	var payload struct {
+		Scope Scope `json:"scope"`
+	}
+	if err := r.DecodePayload(&payload); err != nil {
+		return Scope{}
+	}
+	return payload.Scope
+}
+
+// Partition returns the partition the directive is scoped to if it has a
+// BearerTokenWithPartition scope
+func (r *Request) Partition() (PartitionScope, bool) {
+	return r.Scope().Partitioned()
+}
@@ -0,0 +1,85 @@
+package alexa
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRequestRaw(t *testing.T) {
+	reqJSON := []byte(`{"directive": {"header": {"namespace": "Alexa", "name": "ReportState", "messageId": "1", "payloadVersion": "3"}, "payload": {}, "extra": true}}`)
+
+	var req Request
+	if err := json.Unmarshal(reqJSON, &req); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if req.Namespace() != NamespaceAlexa || string(req.Raw()) != string(reqJSON) {
+		t.Fatalf("unexpected request: %+v raw: %s", req, req.Raw())
+	}
+
+	reqJSON[0] = ' '
+	if req.Raw()[0] != '{' {
+		t.Error("raw json should be a copy")
+	}
+
+	if reqJSON, err := req.JSON(); err != nil || string(reqJSON) != string(req.Raw()) {
+		t.Errorf("expected raw json for an unmodified request: %s %v", reqJSON, err)
+	}
+
+	copied := req
+	clone := req.Clone()
+	clone.Directive.Endpoint.EndpointID = "member-1"
+	for name, r := range map[string]*Request{"copy": &copied, "clone": clone} {
+		reqJSON, err := r.JSON()
+		if err != nil {
+			t.Fatalf("%s: failed to get json: %v", name, err)
+		}
+		if string(reqJSON) == string(req.Raw()) {
+			t.Errorf("%s: expected json to be marshaled rather than raw", name)
+		}
+	}
+	if clone.Raw() != nil {
+		t.Errorf("expected clone to have no raw json: %s", clone.Raw())
+	}
+
+	req.Directive.Header.Name = "TurnOff"
+	if reqJSON, err := req.JSON(); err != nil || !strings.Contains(string(reqJSON), "TurnOff") {
+		t.Errorf("expected json of the modified request: %s %v", reqJSON, err)
+	}
+
+	built := &Request{Directive: RequestDirective{Header: Header{Namespace: NamespaceAlexa}}}
+	if built.Raw() != nil {
+		t.Error("expected nil raw json for a request built in code")
+	}
+	if builtJSON, err := built.JSON(); err != nil || len(builtJSON) == 0 {
+		t.Errorf("expected marshaled json: %s %v", builtJSON, err)
+	}
+}
+
+func TestPartitionScope(t *testing.T) {
+	reqJSON := []byte(`{"directive": {"header": {"namespace": "Alexa.PowerController", "name": "TurnOn"}, "endpoint": {"scope": {"type": "BearerTokenWithPartition", "token": "access-token", "partition": "kitchen", "userId": "user-1"}, "endpointId": "light-1"}, "payload": {}}}`)
+
+	var req Request
+	if err := json.Unmarshal(reqJSON, &req); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	scope, ok := req.Directive.Endpoint.Scope.Partitioned()
+	if !ok {
+		t.Fatal("expected a partitioned scope")
+	}
+	expected := PartitionScope{Token: "access-token", Partition: "kitchen", UserID: "user-1"}
+	if scope != expected {
+		t.Errorf("expected %+v, got %+v", expected, scope)
+	}
+	if scope.Scope() != req.Directive.Endpoint.Scope {
+		t.Errorf("scope didn't round trip: %+v", scope.Scope())
+	}
+	if req.BearerToken() != "access-token" {
+		t.Errorf("unexpected bearer token: %s", req.BearerToken())
+	}
+
+	if _, ok := (Scope{Type: ScopeTypeBearerToken, Token: "access-token"}).Partitioned(); ok {
+		t.Error("expected a BearerToken scope not to be partitioned")
+	}
+}
@@ -2,7 +2,9 @@ package alexa
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/google/uuid"
 )
@@ -21,7 +23,7 @@ type ResponseBuilder struct {
 
 // NewResponseBuilder creates a new ResponseBuilder with a UUID MessageID generator.
 func NewResponseBuilder() *ResponseBuilder {
-	return &ResponseBuilder{UUIDMessageID}
+	return &ResponseBuilder{MessageID: UUIDMessageID}
 }
 
 // DeferredResponse creates a response that indicates that a response will be
@@ -53,18 +55,116 @@ func (r *ResponseBuilder) DiscoverResponse(endpoints ...DiscoverEndpoint) (*Resp
 	}
 
 	resp := Response{
+		Event: Event{
+			Header:  r.discoverHeader(),
+			Payload: payloadJSON,
+		},
+	}
+
+	return &resp, nil
+}
+
+func (r *ResponseBuilder) discoverHeader() Header {
+	return Header{
+		Namespace:      NamespaceDiscovery,
+		Name:           "Discover.Response",
+		PayloadVersion: "3",
+		MessageID:      r.MessageID(),
+	}
+}
+
+// EncodeDiscoverResponse streams the json of a DiscoverResponse for endpoints to w. Only
+// one endpoint is held in memory as json at a time which avoids large allocations when
+// discovering big device fleets. The output matches json.Marshal of DiscoverResponse.
+func (r *ResponseBuilder) EncodeDiscoverResponse(w io.Writer, endpoints ...DiscoverEndpoint) error {
+	e := newEncoder()
+	defer e.release()
+
+	header := r.discoverHeader()
+	e.buf.WriteString(`{"event":{"header":`)
+	e.header(&header)
+	e.buf.WriteString(`,"payload":{"endpoints":`)
+	if endpoints == nil {
+		e.buf.WriteString("null")
+	} else {
+		e.buf.WriteByte('[')
+		enc := json.NewEncoder(&e.buf)
+		for i := range endpoints {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			if err := enc.Encode(&endpoints[i]); err != nil {
+				return fmt.Errorf("failed to marshal endpoint %s: %v", endpoints[i].EndpointID, err)
+			}
+			// drop the newline Encode adds
+			e.buf.Truncate(e.buf.Len() - 1)
+
+			if e.buf.Len() >= streamFlushSize {
+				if _, err := e.buf.WriteTo(w); err != nil {
+					return err
+				}
+			}
+		}
+		e.buf.WriteByte(']')
+	}
+	e.buf.WriteString(`}}}`)
+
+	_, err := e.buf.WriteTo(w)
+	return err
+}
+
+// AddOrUpdateReport creates a proactive event notifying the smart home api of new or changed
+// endpoints. scope must hold the user's access token obtained via AcceptGrant.
+func (r *ResponseBuilder) AddOrUpdateReport(scope Scope, endpoints ...DiscoverEndpoint) (*Response, error) {
+	payload := AddOrUpdateReportPayload{
+		Endpoints: endpoints,
+		Scope:     scope,
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	return &Response{
 		Event: Event{
 			Header: Header{
-				Namespace:      "Alexa.Discovery",
-				Name:           "Discover.Response",
+				Namespace:      NamespaceDiscovery,
+				Name:           "AddOrUpdateReport",
 				PayloadVersion: "3",
 				MessageID:      r.MessageID(),
 			},
 			Payload: payloadJSON,
 		},
+	}, nil
+}
+
+// DeleteReport creates a proactive event notifying the smart home api that the endpoints
+// have been removed. scope must hold the user's access token obtained via AcceptGrant.
+func (r *ResponseBuilder) DeleteReport(scope Scope, endpointIDs ...string) (*Response, error) {
+	payload := DeleteReportPayload{
+		Scope: scope,
+	}
+	for _, endpointID := range endpointIDs {
+		payload.Endpoints = append(payload.Endpoints, DeleteReportEndpoint{endpointID})
 	}
 
-	return &resp, nil
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	return &Response{
+		Event: Event{
+			Header: Header{
+				Namespace:      NamespaceDiscovery,
+				Name:           "DeleteReport",
+				PayloadVersion: "3",
+				MessageID:      r.MessageID(),
+			},
+			Payload: payloadJSON,
+		},
+	}, nil
 }
 
 // BasicErrorResponse creates a response for simple errors
@@ -116,13 +216,14 @@ func (r *ResponseBuilder) CustomErrorResponse(req *Request, payload json.RawMess
 	}
 }
 
-// StateReportResponse builds a StateReport response with the provided properties
+// StateReportResponse builds a StateReport response with the provided properties.
+// Properties are normalized, see NormalizeProperty.
 func (r *ResponseBuilder) StateReportResponse(req *Request, properties ...ContextProperty) *Response {
 	return &Response{
 		Event: Event{
 			Header: Header{
 				Namespace:        NamespaceAlexa,
-				Name:             "StateReport",
+				Name:             EventStateReport,
 				PayloadVersion:   "3",
 				MessageID:        r.MessageID(),
 				CorrelationToken: req.Directive.Header.CorrelationToken,
@@ -134,12 +235,13 @@ func (r *ResponseBuilder) StateReportResponse(req *Request, properties ...Contex
 			Payload: EmptyPayload,
 		},
 		Context: &ResponseContext{
-			Properties: properties,
+			Properties: r.normalizeProperties(properties),
 		},
 	}
 }
 
-// BasicResponse returns a response event response
+// BasicResponse returns a response event response. Properties are normalized,
+// see NormalizeProperty.
 func (r *ResponseBuilder) BasicResponse(req *Request, properties ...ContextProperty) *Response {
 	return &Response{
 		Event: Event{
@@ -157,9 +259,59 @@ func (r *ResponseBuilder) BasicResponse(req *Request, properties ...ContextPrope
 			Payload: EmptyPayload,
 		},
 		Context: &ResponseContext{
-			Properties: properties,
+			Properties: r.normalizeProperties(properties),
+		},
+	}
+}
+
+// ChangeReport creates a proactive event reporting that properties of an endpoint changed.
+// changed holds the properties that changed due to cause while unchanged holds the current
+// value of other properties of the endpoint. scope must identify the user, see deferred.HTTPEventSender.
+// Properties are normalized and then validated, see NormalizeProperty and ValidateProperty.
+// It fails if changed is empty as Alexa rejects a change report without a changed property.
+func (r *ResponseBuilder) ChangeReport(scope Scope, endpointID, cause string,
+	changed []ContextProperty, unchanged ...ContextProperty) (*Response, error) {
+	if len(changed) == 0 {
+		return nil, errors.New("change report requires a changed property")
+	}
+	changed, err := r.validateProperties(changed)
+	if err != nil {
+		return nil, err
+	}
+	if unchanged, err = r.validateProperties(unchanged); err != nil {
+		return nil, err
+	}
+
+	// change reports can be sent at a high rate so the payload is encoded directly
+	// rather than via json.Marshal
+	e := newEncoder()
+	defer e.release()
+
+	if err := e.changeReportPayload(cause, changed); err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	payloadJSON := json.RawMessage(e.bytes())
+
+	resp := &Response{
+		Event: Event{
+			Header: Header{
+				Namespace:      NamespaceAlexa,
+				Name:           "ChangeReport",
+				PayloadVersion: "3",
+				MessageID:      r.MessageID(),
+			},
+			Endpoint: &ResponseEndpoint{
+				EndpointID: endpointID,
+				Scope:      scope,
+			},
+			Payload: payloadJSON,
 		},
 	}
+	if len(unchanged) > 0 {
+		resp.Context = &ResponseContext{Properties: unchanged}
+	}
+
+	return resp, nil
 }
 
 // AcceptGrantResponse returns a successful accept grant response
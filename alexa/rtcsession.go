@@ -0,0 +1,105 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// RTCSessionController event name enums
+const (
+	EventAnswerGeneratedForSession = "AnswerGeneratedForSession"
+	EventSessionConnected          = "SessionConnected"
+	EventSessionDisconnected       = "SessionDisconnected"
+)
+
+// SessionDescriptionFormatSDP is the only supported SessionDescription format
+const SessionDescriptionFormatSDP = "SDP"
+
+// RTCSessionConfiguration is the discovery configuration of an endpoint supporting WebRTC
+// sessions
+type RTCSessionConfiguration struct {
+	IsFullDuplexAudioEnabled bool `json:"isFullDuplexAudioEnabled"`
+}
+
+// NewRTCSessionControllerCapability creates the discovery capability of an endpoint
+// supporting WebRTC sessions
+func NewRTCSessionControllerCapability(config RTCSessionConfiguration) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:          "AlexaInterface",
+		Interface:     InterfaceRTCSessionController,
+		Version:       "3",
+		Configuration: configJSON,
+	}, nil
+}
+
+// SessionDescription is an SDP offer or answer
+type SessionDescription struct {
+	Format string `json:"format"`
+	Value  string `json:"value"`
+}
+
+// InitiateSessionWithOfferPayload is Alexa's SDP offer to start a session
+type InitiateSessionWithOfferPayload struct {
+	SessionID string             `json:"sessionId"`
+	Offer     SessionDescription `json:"offer"`
+}
+
+// Validate checks that the offer has a session and SDP value
+func (p InitiateSessionWithOfferPayload) Validate() error {
+	if p.SessionID == "" {
+		return errors.New("missing sessionId")
+	}
+	if p.Offer.Format != SessionDescriptionFormatSDP || p.Offer.Value == "" {
+		return errors.New("offer must be an SDP value")
+	}
+	return nil
+}
+
+// SessionPayload identifies the session of SessionConnected and SessionDisconnected
+// directives and their response events
+type SessionPayload struct {
+	SessionID string `json:"sessionId"`
+}
+
+// AnswerGeneratedForSessionPayload answers an InitiateSessionWithOffer directive
+type AnswerGeneratedForSessionPayload struct {
+	Answer SessionDescription `json:"answer"`
+}
+
+// RTCSessionControllerHandler routes handling of session signaling directives
+func RTCSessionControllerHandler(initiate, connected, disconnected Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "InitiateSessionWithOffer":
+			return initiate.HandleRequest(ctx, req)
+		case "SessionConnected":
+			return connected.HandleRequest(ctx, req)
+		case "SessionDisconnected":
+			return disconnected.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("RTCSessionControllerHandler", req)
+		}
+	}
+}
+
+// AnswerGeneratedForSessionResponse answers an InitiateSessionWithOffer directive with
+// the endpoint's SDP answer
+func (r *ResponseBuilder) AnswerGeneratedForSessionResponse(req *Request, sdp string) (*Response, error) {
+	return TypedResponse(r, req, NamespaceRTCSessionController, EventAnswerGeneratedForSession,
+		AnswerGeneratedForSessionPayload{Answer: SessionDescription{Format: SessionDescriptionFormatSDP, Value: sdp}})
+}
+
+// SessionConnectedResponse answers a SessionConnected directive
+func (r *ResponseBuilder) SessionConnectedResponse(req *Request, sessionID string) (*Response, error) {
+	return TypedResponse(r, req, NamespaceRTCSessionController, EventSessionConnected, SessionPayload{sessionID})
+}
+
+// SessionDisconnectedResponse answers a SessionDisconnected directive
+func (r *ResponseBuilder) SessionDisconnectedResponse(req *Request, sessionID string) (*Response, error) {
+	return TypedResponse(r, req, NamespaceRTCSessionController, EventSessionDisconnected, SessionPayload{sessionID})
+}
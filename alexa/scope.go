@@ -0,0 +1,33 @@
+package alexa
+
+// ScopeType enums
+const (
+	ScopeTypeBearerToken              = "BearerToken"
+	ScopeTypeBearerTokenWithPartition = "BearerTokenWithPartition"
+)
+
+// PartitionScope is a BearerTokenWithPartition scope. It's used by directive sources
+// that address a partition of a user's home, such as a room or a separate building.
+type PartitionScope struct {
+	Token     string
+	Partition string
+	UserID    string
+}
+
+// Partitioned returns the scope as a PartitionScope if it's a BearerTokenWithPartition scope
+func (s Scope) Partitioned() (PartitionScope, bool) {
+	if s.Type != ScopeTypeBearerTokenWithPartition {
+		return PartitionScope{}, false
+	}
+	return PartitionScope{Token: s.Token, Partition: s.Partition, UserID: s.UserID}, true
+}
+
+// Scope returns the scope as a BearerTokenWithPartition Scope
+func (p PartitionScope) Scope() Scope {
+	return Scope{
+		Type:      ScopeTypeBearerTokenWithPartition,
+		Token:     p.Token,
+		Partition: p.Partition,
+		UserID:    p.UserID,
+	}
+}
@@ -0,0 +1,191 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Alexa.SecurityPanelController properties
+const (
+	PropertyArmState            = "armState"
+	PropertyBurglaryAlarm       = "burglaryAlarm"
+	PropertyCarbonMonoxideAlarm = "carbonMonoxideAlarm"
+	PropertyFireAlarm           = "fireAlarm"
+	PropertyWaterAlarm          = "waterAlarm"
+)
+
+// ArmState enums
+const (
+	ArmStateArmedAway  = "ARMED_AWAY"
+	ArmStateArmedNight = "ARMED_NIGHT"
+	ArmStateArmedStay  = "ARMED_STAY"
+	ArmStateDisarmed   = "DISARMED"
+)
+
+var armedStates = []string{ArmStateArmedAway, ArmStateArmedNight, ArmStateArmedStay}
+
+// AlarmState enums
+const (
+	AlarmStateAlarm = "ALARM"
+	AlarmStateOK    = "OK"
+)
+
+// AuthorizationType enums
+const AuthorizationTypeFourDigitPIN = "FOUR_DIGIT_PIN"
+
+// Alexa.SecurityPanelController ErrorType enums
+const (
+	ErrorTypeAuthorizationRequired = "AUTHORIZATION_REQUIRED"
+	ErrorTypeBadPIN                = "BAD_PIN"
+	ErrorTypeBypassNeeded          = "BYPASS_NEEDED"
+	ErrorTypeNotReady              = "NOT_READY"
+	ErrorTypeUnauthorized          = "UNAUTHORIZED"
+	ErrorTypeUnclearedAlarm        = "UNCLEARED_ALARM"
+	ErrorTypeUnclearedTrouble      = "UNCLEARED_TROUBLE"
+)
+
+// SecurityPanelConfiguration is the discovery configuration of a security panel
+type SecurityPanelConfiguration struct {
+	SupportedArmStates          []SupportedArmState          `json:"supportedArmStates"`
+	SupportedAuthorizationTypes []SupportedAuthorizationType `json:"supportedAuthorizationTypes,omitempty"`
+}
+
+type SupportedArmState struct {
+	Value string `json:"value"`
+}
+
+type SupportedAuthorizationType struct {
+	Type string `json:"type"`
+}
+
+// NewSecurityPanelControllerCapability creates the discovery capability of a security
+// panel supporting config
+func NewSecurityPanelControllerCapability(config SecurityPanelConfiguration) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: InterfaceSecurityPanelController,
+		Version:   "3",
+		Properties: &DiscoverProperties{
+			Supported: []DiscoverProperty{
+				{Name: PropertyArmState},
+				{Name: PropertyBurglaryAlarm},
+				{Name: PropertyFireAlarm},
+				{Name: PropertyCarbonMonoxideAlarm},
+				{Name: PropertyWaterAlarm},
+			},
+			ProactivelyReported: true,
+			Retrievable:         true,
+		},
+		Configuration: configJSON,
+	}, nil
+}
+
+// ArmPayload requests an armed ArmState
+type ArmPayload struct {
+	ArmState string `json:"armState"`
+}
+
+// Validate checks that the arm state is one of the armed states
+func (p ArmPayload) Validate() error {
+	if !contains(armedStates, p.ArmState) {
+		return fmt.Errorf("unknown arm state %q", p.ArmState)
+	}
+	return nil
+}
+
+// Authorization is the code the user said to disarm the panel
+type Authorization struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type DisarmPayload struct {
+	Authorization *Authorization `json:"authorization,omitempty"`
+}
+
+// ArmResponsePayload answers an Arm directive. ExitDelayInSeconds is the time the user has
+// to leave before the panel arms, 0 to 255.
+type ArmResponsePayload struct {
+	ExitDelayInSeconds int `json:"exitDelayInSeconds,omitempty"`
+}
+
+// SecurityPanelControllerHandler routes handling of arm & disarm directives
+func SecurityPanelControllerHandler(arm, disarm Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "Arm":
+			return arm.HandleRequest(ctx, req)
+		case "Disarm":
+			return disarm.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("SecurityPanelControllerHandler", req)
+		}
+	}
+}
+
+// ArmResponse answers an Arm directive with the exit delay and properties, which should
+// include the armState
+func (r *ResponseBuilder) ArmResponse(req *Request, exitDelay time.Duration, properties ...ContextProperty) (*Response, error) {
+	payload := ArmResponsePayload{ExitDelayInSeconds: int(exitDelay / time.Second)}
+	if err := validateRange("exitDelayInSeconds", float64(payload.ExitDelayInSeconds), 0, 255); err != nil {
+		return nil, err
+	}
+	return TypedResponse(r, req, NamespaceSecurityPanelController, "Arm.Response", payload, properties...)
+}
+
+// SecurityPanelErrorResponse creates an Alexa.SecurityPanelController ErrorResponse, e.g.
+// for NOT_READY or UNAUTHORIZED
+func (r *ResponseBuilder) SecurityPanelErrorResponse(req *Request, errorType, msg string) (*Response, error) {
+	resp, err := r.BasicErrorResponse(req, errorType, msg)
+	if err != nil {
+		return nil, err
+	}
+	resp.Event.Header.Namespace = NamespaceSecurityPanelController
+	return resp, nil
+}
+
+// BypassNeededErrorResponse rejects an Arm directive until the endpoints, e.g. open
+// windows, are bypassed
+func (r *ResponseBuilder) BypassNeededErrorResponse(req *Request, msg string, endpointIDs ...string) (*Response, error) {
+	type endpoint struct {
+		EndpointID string `json:"endpointId"`
+	}
+	endpoints := make([]endpoint, 0, len(endpointIDs))
+	for _, id := range endpointIDs {
+		endpoints = append(endpoints, endpoint{id})
+	}
+	payloadJSON, err := json.Marshal(struct {
+		Type      string     `json:"type"`
+		Message   string     `json:"message"`
+		Endpoints []endpoint `json:"endpoints"`
+	}{ErrorTypeBypassNeeded, msg, endpoints})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	resp := r.CustomErrorResponse(req, payloadJSON)
+	resp.Event.Header.Namespace = NamespaceSecurityPanelController
+	return resp, nil
+}
+
+// ArmStateProperty creates an armState property
+func ArmStateProperty(state string, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceSecurityPanelController, PropertyArmState, state, timeOfSample)
+}
+
+// AlarmProperty creates a burglaryAlarm, fireAlarm, carbonMonoxideAlarm or waterAlarm
+// property that's ALARM if alarming, otherwise OK
+func AlarmProperty(name string, alarming bool, timeOfSample time.Time) (ContextProperty, error) {
+	value := struct {
+		Value string `json:"value"`
+	}{AlarmStateOK}
+	if alarming {
+		value.Value = AlarmStateAlarm
+	}
+	return NewProperty(NamespaceSecurityPanelController, name, value, timeOfSample)
+}
@@ -0,0 +1,90 @@
+package alexa
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Semantic action enums. Users can say them in place of the directives they're mapped to,
+// e.g. "open the blinds".
+const (
+	ActionClose = "Alexa.Actions.Close"
+	ActionLower = "Alexa.Actions.Lower"
+	ActionOpen  = "Alexa.Actions.Open"
+	ActionRaise = "Alexa.Actions.Raise"
+)
+
+// Semantic state enums
+const (
+	StateClosed = "Alexa.States.Closed"
+	StateOpen   = "Alexa.States.Open"
+)
+
+// Semantic mapping type enums
+const (
+	MappingActionsToDirective = "ActionsToDirective"
+	MappingStatesToRange      = "StatesToRange"
+	MappingStatesToValue      = "StatesToValue"
+)
+
+// Semantics maps utterances such as open and close to the directives and property values
+// of a Toggle, Mode or Range controller instance
+type Semantics struct {
+	ActionMappings []ActionMapping `json:"actionMappings,omitempty"`
+	StateMappings  []StateMapping  `json:"stateMappings,omitempty"`
+}
+
+type ActionMapping struct {
+	Type      string            `json:"@type"`
+	Actions   []string          `json:"actions"`
+	Directive SemanticDirective `json:"directive"`
+}
+
+type SemanticDirective struct {
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// StateMapping maps states to a single Value or, for range controllers, a Range of values
+type StateMapping struct {
+	Type   string          `json:"@type"`
+	States []string        `json:"states"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	Range  *SemanticRange  `json:"range,omitempty"`
+}
+
+type SemanticRange struct {
+	MinimumValue float64 `json:"minimumValue"`
+	MaximumValue float64 `json:"maximumValue"`
+}
+
+// ActionsToDirective maps actions to the directive name sent with payload
+func ActionsToDirective(name string, payload interface{}, actions ...string) (ActionMapping, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return ActionMapping{}, fmt.Errorf("failed to marshal %s payload: %v", name, err)
+	}
+	return ActionMapping{
+		Type:      MappingActionsToDirective,
+		Actions:   actions,
+		Directive: SemanticDirective{Name: name, Payload: payloadJSON},
+	}, nil
+}
+
+// StatesToValue maps states to the property value
+func StatesToValue(value interface{}, states ...string) (StateMapping, error) {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return StateMapping{}, fmt.Errorf("failed to marshal state value: %v", err)
+	}
+	return StateMapping{Type: MappingStatesToValue, States: states, Value: valueJSON}, nil
+}
+
+// StatesToRange maps states to property values from min to max
+func StatesToRange(min, max float64, states ...string) StateMapping {
+	return StateMapping{
+		Type:   MappingStatesToRange,
+		States: states,
+		Range:  &SemanticRange{MinimumValue: min, MaximumValue: max},
+	}
+}
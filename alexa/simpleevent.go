@@ -0,0 +1,84 @@
+package alexa
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// EventSimpleEvent is the proactive Alexa.SimpleEventSource event
+const EventSimpleEvent = "SimpleEvent"
+
+// SimpleEventNameButtonPress is the event of a stateless button
+const SimpleEventNameButtonPress = "buttonPress"
+
+// PressType enums
+const (
+	PressTypeDoublePress = "DOUBLE_PRESS"
+	PressTypeLongPress   = "LONG_PRESS"
+	PressTypeSinglePress = "SINGLE_PRESS"
+)
+
+// SimpleEventSourceConfiguration is the discovery configuration of an endpoint sending
+// simple events, e.g. a button that can be used to trigger routines
+type SimpleEventSourceConfiguration struct {
+	SupportedEvents []SupportedSimpleEvent `json:"supportedEvents"`
+}
+
+// SupportedSimpleEvent is an event the endpoint sends. PressTypes lists the PressType enums
+// of buttonPress events.
+type SupportedSimpleEvent struct {
+	Name       string   `json:"name"`
+	PressTypes []string `json:"pressTypes,omitempty"`
+}
+
+// NewSimpleEventSourceCapability creates the discovery capability of an endpoint sending
+// the events in config
+func NewSimpleEventSourceCapability(config SimpleEventSourceConfiguration) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	proactivelyReported := true
+	return DiscoverCapability{
+		Type:                "AlexaInterface",
+		Interface:           InterfaceSimpleEventSource,
+		Version:             "3",
+		ProactivelyReported: &proactivelyReported,
+		Configuration:       configJSON,
+	}, nil
+}
+
+// SimpleEventPayload is the payload of a SimpleEvent
+type SimpleEventPayload struct {
+	Event     SimpleEvent `json:"event"`
+	Cause     Cause       `json:"cause"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// SimpleEvent names what happened. PressType is only set for buttonPress events.
+type SimpleEvent struct {
+	EventName string `json:"eventName"`
+	PressType string `json:"pressType,omitempty"`
+}
+
+// SimpleEvent creates a proactive event that event happened at timestamp. scope must
+// identify the user, see deferred.HTTPEventSender.
+func (r *ResponseBuilder) SimpleEvent(scope Scope, endpointID string, event SimpleEvent, timestamp time.Time) (*Response, error) {
+	if event.EventName == "" {
+		return nil, errors.New("simple event requires an eventName")
+	}
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return ProactiveEvent(r, scope, NamespaceSimpleEventSource, EventSimpleEvent, endpointID, SimpleEventPayload{
+		Event:     event,
+		Cause:     Cause{Type: CausePhysicalInteraction},
+		Timestamp: timestamp.UTC(),
+	})
+}
+
+// ButtonPressEvent creates a proactive buttonPress event of pressType, see SimpleEvent
+func (r *ResponseBuilder) ButtonPressEvent(scope Scope, endpointID, pressType string, timestamp time.Time) (*Response, error) {
+	return r.SimpleEvent(scope, endpointID, SimpleEvent{EventName: SimpleEventNameButtonPress, PressType: pressType}, timestamp)
+}
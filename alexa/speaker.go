@@ -0,0 +1,63 @@
+package alexa
+
+import (
+	"context"
+	"time"
+)
+
+// Alexa.Speaker properties
+const (
+	PropertyVolume = "volume"
+	PropertyMuted  = "muted"
+)
+
+type SetVolumePayload struct {
+	Volume int `json:"volume"`
+}
+
+// Validate checks that the volume is within 0 to 100
+func (p SetVolumePayload) Validate() error {
+	return validateRange("volume", float64(p.Volume), 0, 100)
+}
+
+// AdjustVolumePayload changes the volume by Volume. VolumeDefault is set when the user
+// didn't say how much to change it by so the endpoint may use its own step instead.
+type AdjustVolumePayload struct {
+	Volume        int  `json:"volume"`
+	VolumeDefault bool `json:"volumeDefault"`
+}
+
+// Validate checks that the volume change is within -100 to 100
+func (p AdjustVolumePayload) Validate() error {
+	return validateRange("volume", float64(p.Volume), -100, 100)
+}
+
+type SetMutePayload struct {
+	Mute bool `json:"mute"`
+}
+
+// SpeakerHandler routes handling of volume & mute directives
+func SpeakerHandler(setVolume, adjustVolume, setMute Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "SetVolume":
+			return setVolume.HandleRequest(ctx, req)
+		case "AdjustVolume":
+			return adjustVolume.HandleRequest(ctx, req)
+		case "SetMute":
+			return setMute.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("SpeakerHandler", req)
+		}
+	}
+}
+
+// VolumeProperty creates a volume property
+func VolumeProperty(volume int, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceSpeaker, PropertyVolume, volume, timeOfSample)
+}
+
+// MutedProperty creates a muted property
+func MutedProperty(muted bool, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceSpeaker, PropertyMuted, muted, timeOfSample)
+}
@@ -0,0 +1,61 @@
+package alexa
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Logger records structured log entries. keyvals are alternating key/value pairs
+// describing the entry.
+type Logger interface {
+	Log(ctx context.Context, msg string, keyvals ...interface{})
+}
+
+// LoggerFunc implements Logger as a func
+type LoggerFunc func(ctx context.Context, msg string, keyvals ...interface{})
+
+// Log calls the LoggerFunc
+func (l LoggerFunc) Log(ctx context.Context, msg string, keyvals ...interface{}) {
+	l(ctx, msg, keyvals...)
+}
+
+// StdLogger writes log entries to the standard library logger in key=value form.
+type StdLogger struct{}
+
+// Log writes the entry with log.Println
+func (StdLogger) Log(ctx context.Context, msg string, keyvals ...interface{}) {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		var val interface{} = "MISSING"
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+		fmt.Fprintf(&b, " %v=%q", keyvals[i], fmt.Sprint(val))
+	}
+	log.Println(b.String())
+}
+
+// NopLogger discards all log entries
+type NopLogger struct{}
+
+// Log does nothing
+func (NopLogger) Log(ctx context.Context, msg string, keyvals ...interface{}) {}
+
+// Metrics records measurements. Tags are "key:value" strings in the statsd style.
+type Metrics interface {
+	Count(name string, value int64, tags ...string)
+	Timing(name string, d time.Duration, tags ...string)
+}
+
+// NopMetrics discards all measurements
+type NopMetrics struct{}
+
+// Count does nothing
+func (NopMetrics) Count(name string, value int64, tags ...string) {}
+
+// Timing does nothing
+func (NopMetrics) Timing(name string, d time.Duration, tags ...string) {}
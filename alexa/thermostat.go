@@ -0,0 +1,168 @@
+package alexa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Alexa.ThermostatController properties
+const (
+	PropertyTargetSetpoint = "targetSetpoint"
+	PropertyLowerSetpoint  = "lowerSetpoint"
+	PropertyUpperSetpoint  = "upperSetpoint"
+	PropertyThermostatMode = "thermostatMode"
+)
+
+// ThermostatMode enums
+const (
+	ThermostatModeAuto   = "AUTO"
+	ThermostatModeCool   = "COOL"
+	ThermostatModeCustom = "CUSTOM"
+	ThermostatModeEco    = "ECO"
+	ThermostatModeHeat   = "HEAT"
+	ThermostatModeOff    = "OFF"
+)
+
+var thermostatModes = []string{ThermostatModeAuto, ThermostatModeCool, ThermostatModeCustom,
+	ThermostatModeEco, ThermostatModeHeat, ThermostatModeOff}
+
+// TemperatureScaleKelvin is only used by setpoints, sensors report in celsius or fahrenheit
+const TemperatureScaleKelvin = "KELVIN"
+
+// In converts the temperature to scale
+func (t TemperatureValue) In(scale string) TemperatureValue {
+	if t.Scale == scale {
+		return t
+	}
+	celsius := t.Value
+	switch t.Scale {
+	case TemperatureScaleFahrenheit:
+		celsius = (t.Value - 32) * 5 / 9
+	case TemperatureScaleKelvin:
+		celsius = t.Value - 273.15
+	}
+	switch scale {
+	case TemperatureScaleFahrenheit:
+		return TemperatureValue{Value: celsius*9/5 + 32, Scale: scale}
+	case TemperatureScaleKelvin:
+		return TemperatureValue{Value: celsius + 273.15, Scale: scale}
+	default:
+		return TemperatureValue{Value: celsius, Scale: TemperatureScaleCelsius}
+	}
+}
+
+// DeltaIn converts a temperature difference, such as an AdjustTargetTemperature delta, to scale
+func (t TemperatureValue) DeltaIn(scale string) TemperatureValue {
+	fromFahrenheit := t.Scale == TemperatureScaleFahrenheit
+	toFahrenheit := scale == TemperatureScaleFahrenheit
+	switch {
+	case fromFahrenheit && !toFahrenheit:
+		return TemperatureValue{Value: t.Value * 5 / 9, Scale: scale}
+	case !fromFahrenheit && toFahrenheit:
+		return TemperatureValue{Value: t.Value * 9 / 5, Scale: scale}
+	default:
+		return TemperatureValue{Value: t.Value, Scale: scale}
+	}
+}
+
+// SetTargetTemperaturePayload requests a single TargetSetpoint, a LowerSetpoint and
+// UpperSetpoint pair for dual setpoint thermostats, or all three for triple setpoint
+// thermostats. A Schedule limits the setpoints to a window, see ThermostatSchedule.
+type SetTargetTemperaturePayload struct {
+	TargetSetpoint *TemperatureValue   `json:"targetSetpoint,omitempty"`
+	LowerSetpoint  *TemperatureValue   `json:"lowerSetpoint,omitempty"`
+	UpperSetpoint  *TemperatureValue   `json:"upperSetpoint,omitempty"`
+	Schedule       *ThermostatSchedule `json:"schedule,omitempty"`
+}
+
+// Validate checks that the setpoints form a single, dual or triple setpoint request with
+// the lower setpoint below the upper setpoint and any schedule is valid
+func (p SetTargetTemperaturePayload) Validate() error {
+	if p.Schedule != nil {
+		if err := p.Schedule.Validate(); err != nil {
+			return err
+		}
+	}
+	if (p.LowerSetpoint == nil) != (p.UpperSetpoint == nil) {
+		return errors.New("lowerSetpoint and upperSetpoint must be set together")
+	}
+	if p.LowerSetpoint == nil {
+		if p.TargetSetpoint == nil {
+			return errors.New("a setpoint must be set")
+		}
+		return nil
+	}
+	lower := p.LowerSetpoint.In(TemperatureScaleCelsius)
+	upper := p.UpperSetpoint.In(TemperatureScaleCelsius)
+	if lower.Value >= upper.Value {
+		return fmt.Errorf("lowerSetpoint %v must be below upperSetpoint %v", p.LowerSetpoint.Value, p.UpperSetpoint.Value)
+	}
+	return nil
+}
+
+type AdjustTargetTemperaturePayload struct {
+	TargetSetpointDelta TemperatureValue    `json:"targetSetpointDelta"`
+	Schedule            *ThermostatSchedule `json:"schedule,omitempty"`
+}
+
+// Validate checks any schedule
+func (p AdjustTargetTemperaturePayload) Validate() error {
+	if p.Schedule != nil {
+		return p.Schedule.Validate()
+	}
+	return nil
+}
+
+// ThermostatModeValue is the mode requested by SetThermostatMode. CustomName names the
+// manufacturer specific mode when Value is CUSTOM.
+type ThermostatModeValue struct {
+	Value      string `json:"value"`
+	CustomName string `json:"customName,omitempty"`
+}
+
+type SetThermostatModePayload struct {
+	ThermostatMode ThermostatModeValue `json:"thermostatMode"`
+}
+
+// Validate checks that the mode is a ThermostatMode enum with a custom name if it's CUSTOM
+func (p SetThermostatModePayload) Validate() error {
+	if !contains(thermostatModes, p.ThermostatMode.Value) {
+		return fmt.Errorf("unknown thermostat mode %q", p.ThermostatMode.Value)
+	}
+	if p.ThermostatMode.Value == ThermostatModeCustom && p.ThermostatMode.CustomName == "" {
+		return errors.New("CUSTOM thermostat mode requires a customName")
+	}
+	return nil
+}
+
+// ThermostatControllerHandler routes handling of thermostat directives. resumeSchedule
+// may be nil for thermostats without a schedule.
+func ThermostatControllerHandler(setTarget, adjustTarget, setMode, resumeSchedule Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "SetTargetTemperature":
+			return setTarget.HandleRequest(ctx, req)
+		case "AdjustTargetTemperature":
+			return adjustTarget.HandleRequest(ctx, req)
+		case "SetThermostatMode":
+			return setMode.HandleRequest(ctx, req)
+		case "ResumeSchedule":
+			if resumeSchedule != nil {
+				return resumeSchedule.HandleRequest(ctx, req)
+			}
+		}
+		return nil, UnexpectedDirective("ThermostatControllerHandler", req)
+	}
+}
+
+// SetpointProperty creates a targetSetpoint, lowerSetpoint or upperSetpoint property
+func SetpointProperty(name string, value TemperatureValue, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceThermostatController, name, value, timeOfSample)
+}
+
+// ThermostatModeProperty creates a thermostatMode property
+func ThermostatModeProperty(mode string, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceThermostatController, PropertyThermostatMode, mode, timeOfSample)
+}
@@ -0,0 +1,138 @@
+package alexa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alexa.ThermostatController ErrorType enums
+const (
+	ErrorTypeDualSetpointsUnsupported   = "DUAL_SETPOINTS_UNSUPPORTED"
+	ErrorTypeRequestedSetpointsTooClose = "REQUESTED_SETPOINTS_TOO_CLOSE"
+	// ErrorTypeScheduleRequestFailed reports that a scheduled setpoint couldn't be applied
+	ErrorTypeScheduleRequestFailed      = "SCHEDULE_REQUEST_FAILED"
+	ErrorTypeThermostatIsOff            = "THERMOSTAT_IS_OFF"
+	ErrorTypeTripleSetpointsUnsupported = "TRIPLE_SETPOINTS_UNSUPPORTED"
+	ErrorTypeUnsupportedThermostatMode  = "UNSUPPORTED_THERMOSTAT_MODE"
+	// ErrorTypeUnwillingToSetSchedule rejects a scheduled setpoint the thermostat won't hold
+	ErrorTypeUnwillingToSetSchedule = "UNWILLING_TO_SET_SCHEDULE"
+	ErrorTypeUnwillingToSetValue    = "UNWILLING_TO_SET_VALUE"
+)
+
+// ThermostatSchedule limits a requested setpoint to a window, e.g. to hold a temperature
+// for the next few hours before the thermostat resumes its own schedule
+type ThermostatSchedule struct {
+	Start time.Time `json:"start"`
+	// Duration is an ISO 8601 duration, e.g. PT4H
+	Duration string `json:"duration"`
+}
+
+// NewThermostatSchedule creates a schedule holding from start for d
+func NewThermostatSchedule(start time.Time, d time.Duration) ThermostatSchedule {
+	return ThermostatSchedule{Start: start.UTC(), Duration: formatISODuration(d)}
+}
+
+// Window returns the start and end of the schedule
+func (s ThermostatSchedule) Window() (time.Time, time.Time, error) {
+	d, err := parseISODuration(s.Duration)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return s.Start, s.Start.Add(d), nil
+}
+
+// Validate checks that the schedule has a start and positive duration
+func (s ThermostatSchedule) Validate() error {
+	if s.Start.IsZero() {
+		return errors.New("schedule start must be set")
+	}
+	d, err := parseISODuration(s.Duration)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("schedule duration %s must be positive", s.Duration)
+	}
+	return nil
+}
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration parses the day and time parts of an ISO 8601 duration
+func parseISODuration(s string) (time.Duration, error) {
+	match := isoDurationPattern.FindStringSubmatch(s)
+	if match == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", s)
+	}
+
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if match[i+1] == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(match[i+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q: %v", s, err)
+		}
+		d += time.Duration(value * float64(unit))
+	}
+	return d, nil
+}
+
+// formatISODuration formats d as an ISO 8601 duration in hours, minutes and seconds
+func formatISODuration(d time.Duration) string {
+	if d <= 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	b.WriteString("PT")
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+		d -= m * time.Minute
+	}
+	if d > 0 {
+		b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S")
+	}
+	return b.String()
+}
+
+// ThermostatErrorResponse creates an Alexa.ThermostatController ErrorResponse, e.g. for
+// THERMOSTAT_IS_OFF or UNWILLING_TO_SET_SCHEDULE
+func (r *ResponseBuilder) ThermostatErrorResponse(req *Request, errorType, msg string) (*Response, error) {
+	resp, err := r.BasicErrorResponse(req, errorType, msg)
+	if err != nil {
+		return nil, err
+	}
+	resp.Event.Header.Namespace = NamespaceThermostatController
+	return resp, nil
+}
+
+// ScheduleRequestFailedErrorResponse reports that the scheduled setpoint of a directive
+// couldn't be applied
+func (r *ResponseBuilder) ScheduleRequestFailedErrorResponse(req *Request, msg string) (*Response, error) {
+	return r.ThermostatErrorResponse(req, ErrorTypeScheduleRequestFailed, msg)
+}
+
+// SetpointsTooCloseErrorResponse rejects dual setpoints closer than minimumDelta
+func (r *ResponseBuilder) SetpointsTooCloseErrorResponse(req *Request, minimumDelta TemperatureValue, msg string) (*Response, error) {
+	payloadJSON, err := json.Marshal(struct {
+		Type                    string           `json:"type"`
+		Message                 string           `json:"message"`
+		MinimumTemperatureDelta TemperatureValue `json:"minimumTemperatureDelta"`
+	}{ErrorTypeRequestedSetpointsTooClose, msg, minimumDelta})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	resp := r.CustomErrorResponse(req, payloadJSON)
+	resp.Event.Header.Namespace = NamespaceThermostatController
+	return resp, nil
+}
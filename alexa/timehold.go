@@ -0,0 +1,58 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// PropertyHoldStartTime is the Alexa.TimeHoldController property holding when the current
+// hold started
+const PropertyHoldStartTime = "holdStartTime"
+
+// TimeHoldConfiguration is the discovery configuration of an appliance that can pause its
+// cycle. AllowRemoteResume is false if the user must resume it at the appliance.
+type TimeHoldConfiguration struct {
+	AllowRemoteResume bool `json:"allowRemoteResume"`
+}
+
+// TimeHoldControllerHandler routes handling of hold & resume directives. resume may be nil
+// if remote resume isn't allowed.
+func TimeHoldControllerHandler(hold, resume Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "Hold":
+			return hold.HandleRequest(ctx, req)
+		case "Resume":
+			if resume != nil {
+				return resume.HandleRequest(ctx, req)
+			}
+		}
+		return nil, UnexpectedDirective("TimeHoldControllerHandler", req)
+	}
+}
+
+// HoldStartTimeProperty creates a holdStartTime property
+func HoldStartTimeProperty(start, timeOfSample time.Time) (ContextProperty, error) {
+	return NewProperty(NamespaceTimeHoldController, PropertyHoldStartTime, start.UTC(), timeOfSample)
+}
+
+// NewTimeHoldControllerCapability creates the discovery capability of an appliance that
+// can pause its cycle
+func NewTimeHoldControllerCapability(config TimeHoldConfiguration) (DiscoverCapability, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return DiscoverCapability{}, err
+	}
+	return DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: InterfaceTimeHoldController,
+		Version:   "3",
+		Properties: &DiscoverProperties{
+			Supported:           []DiscoverProperty{{Name: PropertyHoldStartTime}},
+			ProactivelyReported: true,
+			Retrievable:         true,
+		},
+		Configuration: configJSON,
+	}, nil
+}
@@ -0,0 +1,62 @@
+package alexa
+
+import (
+	"context"
+	"time"
+)
+
+// PropertyToggleState is the Alexa.ToggleController property holding ON or OFF
+const PropertyToggleState = "toggleState"
+
+// ToggleState enums
+const (
+	ToggleStateOff = "OFF"
+	ToggleStateOn  = "ON"
+)
+
+// ToggleControllerHandler routes handling of turn on & off directives for a single
+// instance. Use InstanceHandler to route endpoints with several instances.
+func ToggleControllerHandler(turnOn, turnOff Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "TurnOn":
+			return turnOn.HandleRequest(ctx, req)
+		case "TurnOff":
+			return turnOff.HandleRequest(ctx, req)
+		default:
+			return nil, UnexpectedDirective("ToggleControllerHandler", req)
+		}
+	}
+}
+
+// ToggleStateProperty creates a toggleState property of instance
+func ToggleStateProperty(instance string, on bool, timeOfSample time.Time) (ContextProperty, error) {
+	state := ToggleStateOff
+	if on {
+		state = ToggleStateOn
+	}
+	prop, err := NewProperty(NamespaceToggleController, PropertyToggleState, state, timeOfSample)
+	if err != nil {
+		return ContextProperty{}, err
+	}
+	prop.Instance = instance
+	return prop, nil
+}
+
+// NewToggleControllerCapability creates the discovery capability of a toggle instance
+// named by resources. semantics may be nil.
+func NewToggleControllerCapability(instance string, resources CapabilityResources, semantics *Semantics) DiscoverCapability {
+	return DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: InterfaceToggleController,
+		Instance:  instance,
+		Version:   "3",
+		Properties: &DiscoverProperties{
+			Supported:           []DiscoverProperty{{Name: PropertyToggleState}},
+			ProactivelyReported: true,
+			Retrievable:         true,
+		},
+		CapabilityResources: &resources,
+		Semantics:           semantics,
+	}
+}
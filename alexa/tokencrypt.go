@@ -0,0 +1,130 @@
+package alexa
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// encryptedTokenPrefix marks an access token field holding an encrypted token
+const encryptedTokenPrefix = "aesgcm:"
+
+// EncryptedTokenStore encrypts tokens with AES-GCM before passing them to TokenStore.
+// The entire token is encrypted and stored in the AccessToken field of the token
+// given to TokenStore so any TokenReaderWriter can be used as the backing store.
+// Each ciphertext is tagged with the id of the key used so keys can be rotated by
+// adding a new key and updating CurrentKeyID.
+type EncryptedTokenStore struct {
+	TokenStore TokenReaderWriter
+	// Keys maps key ids to 16, 24 or 32 byte AES keys
+	Keys map[string][]byte
+	// CurrentKeyID is the id of the key used to encrypt new tokens
+	CurrentKeyID string
+	// AllowPlaintext permits reading tokens that were stored before encryption was enabled
+	AllowPlaintext bool
+}
+
+func (e *EncryptedTokenStore) Write(ctx context.Context, id string, token *oauth2.Token) error {
+	aead, err := e.aead(e.CurrentKeyID)
+	if err != nil {
+		return err
+	}
+
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %v", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	// the user id is bound as additional data so ciphertexts can't be swapped between users
+	sealed := aead.Seal(nonce, nonce, tokenJSON, []byte(id))
+	encrypted := &oauth2.Token{
+		AccessToken: encryptedTokenPrefix + e.CurrentKeyID + ":" + base64.StdEncoding.EncodeToString(sealed),
+	}
+
+	return e.TokenStore.Write(ctx, id, encrypted)
+}
+
+func (e *EncryptedTokenStore) Read(ctx context.Context, id string) (*oauth2.Token, error) {
+	stored, err := e.TokenStore.Read(ctx, id)
+	if err != nil || stored == nil {
+		return stored, err
+	}
+
+	if !strings.HasPrefix(stored.AccessToken, encryptedTokenPrefix) {
+		if e.AllowPlaintext {
+			return stored, nil
+		}
+		return nil, errors.New("stored token is not encrypted")
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(stored.AccessToken, encryptedTokenPrefix), ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("malformed encrypted token")
+	}
+
+	aead, err := e.aead(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted token: %v", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted token")
+	}
+
+	tokenJSON, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token: %v", err)
+	}
+
+	var token oauth2.Token
+	if err := json.Unmarshal(tokenJSON, &token); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token: %v", err)
+	}
+
+	return &token, nil
+}
+
+func (e *EncryptedTokenStore) Delete(ctx context.Context, id string) error {
+	deleter, ok := e.TokenStore.(TokenDeleter)
+	if !ok {
+		return fmt.Errorf("token store %T does not support delete", e.TokenStore)
+	}
+	return deleter.Delete(ctx, id)
+}
+
+func (e *EncryptedTokenStore) aead(keyID string) (cipher.AEAD, error) {
+	key, ok := e.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown token encryption key: %s", keyID)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token encryption key %s: %v", keyID, err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init gcm: %v", err)
+	}
+
+	return aead, nil
+}
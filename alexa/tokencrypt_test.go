@@ -0,0 +1,69 @@
+package alexa
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestEncryptedTokenStore(t *testing.T) {
+	backing := &memoryTokenStore{make(map[string]*oauth2.Token)}
+	store := &EncryptedTokenStore{
+		TokenStore:   backing,
+		Keys:         map[string][]byte{"k1": []byte("0123456789abcdef")},
+		CurrentKeyID: "k1",
+	}
+
+	ctx := context.Background()
+
+	if err := store.Write(ctx, "user-1", &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	stored := backing.tokens["user-1"]
+	if !strings.HasPrefix(stored.AccessToken, "aesgcm:k1:") || strings.Contains(stored.AccessToken, "refresh") {
+		t.Fatalf("token not encrypted: %s", stored.AccessToken)
+	}
+
+	// rotate to a new key, tokens written with the old key must remain readable
+	store.Keys["k2"] = []byte("fedcba9876543210fedcba9876543210")
+	store.CurrentKeyID = "k2"
+
+	token, err := store.Read(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" {
+		t.Fatalf("unexpected token: %+v", token)
+	}
+
+	// ciphertext is bound to the user id
+	backing.tokens["user-2"] = stored
+	if _, err := store.Read(ctx, "user-2"); err == nil {
+		t.Fatalf("expected error reading token copied to another user")
+	}
+
+	backing.tokens["user-3"] = &oauth2.Token{AccessToken: "plain"}
+	if _, err := store.Read(ctx, "user-3"); err == nil {
+		t.Fatalf("expected error reading plaintext token")
+	}
+	store.AllowPlaintext = true
+	if token, err := store.Read(ctx, "user-3"); err != nil || token.AccessToken != "plain" {
+		t.Fatalf("expected plaintext token: %v %v", token, err)
+	}
+}
+
+type memoryTokenStore struct {
+	tokens map[string]*oauth2.Token
+}
+
+func (m *memoryTokenStore) Write(ctx context.Context, id string, token *oauth2.Token) error {
+	m.tokens[id] = token
+	return nil
+}
+
+func (m *memoryTokenStore) Read(ctx context.Context, id string) (*oauth2.Token, error) {
+	return m.tokens[id], nil
+}
@@ -0,0 +1,101 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Typed adapts a handler that takes the directive payload decoded into I. The payload is
+// decoded with DecodePayload so other handlers in the chain share the decoded value.
+// Payloads with a Validate method, e.g. SetPercentagePayload, are validated and the
+// validation error returned without calling handler.
+//
+//	mux.Handle("SetPercentage", alexa.Typed(func(ctx context.Context, req *alexa.Request,
+//		payload alexa.SetPercentagePayload) (*alexa.Response, error) {
+//		...
+//	}))
+func Typed[I any](handler func(ctx context.Context, req *Request, payload I) (*Response, error)) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		var payload I
+		if err := req.DecodePayload(&payload); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal payload: %v", err)
+		}
+		if v, ok := any(payload).(payloadValidator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req, payload)
+	}
+}
+
+// payloadValidator is implemented by payloads that can check their values
+type payloadValidator interface {
+	Validate() error
+}
+
+// TypedResponse builds a response to req with the given event namespace and name and
+// payload marshaled from P. It's the response side equivalent of Typed for directives
+// whose response carries a payload, e.g. Alexa.CameraStreamController's Response.
+func TypedResponse[P any](r *ResponseBuilder, req *Request, namespace, name string, payload P,
+	properties ...ContextProperty) (*Response, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	properties, err = r.validateProperties(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &Response{
+		Event: Event{
+			Header: Header{
+				Namespace:        namespace,
+				Name:             name,
+				PayloadVersion:   "3",
+				MessageID:        r.MessageID(),
+				CorrelationToken: req.Directive.Header.CorrelationToken,
+			},
+			Endpoint: &ResponseEndpoint{
+				EndpointID: req.Directive.Endpoint.EndpointID,
+				Scope:      req.Directive.Endpoint.Scope,
+			},
+			Payload: payloadJSON,
+		},
+	}
+	if len(properties) > 0 {
+		resp.Context = &ResponseContext{Properties: properties}
+	}
+
+	return resp, nil
+}
+
+// ProactiveEvent builds a proactive event of endpointID with the given namespace and name
+// and payload marshaled from P. Unlike TypedResponse the event doesn't answer a directive
+// so scope must identify the user, see deferred.HTTPEventSender.
+func ProactiveEvent[P any](r *ResponseBuilder, scope Scope, namespace, name, endpointID string,
+	payload P) (*Response, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	return &Response{
+		Event: Event{
+			Header: Header{
+				Namespace:      namespace,
+				Name:           name,
+				PayloadVersion: "3",
+				MessageID:      r.MessageID(),
+			},
+			Endpoint: &ResponseEndpoint{
+				EndpointID: endpointID,
+				Scope:      scope,
+			},
+			Payload: payloadJSON,
+		},
+	}, nil
+}
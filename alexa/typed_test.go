@@ -0,0 +1,112 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestTyped(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+
+	type adjustResult struct {
+		Applied int `json:"applied"`
+	}
+
+	handler := Typed(func(ctx context.Context, req *Request, payload AdjustPercentagePayload) (*Response, error) {
+		return TypedResponse(rb, req, NamespacePercentageController, "Response",
+			adjustResult{Applied: payload.PercentageDelta})
+	})
+
+	req := &Request{
+		Directive: RequestDirective{
+			Header:   Header{Namespace: NamespacePercentageController, Name: "AdjustPercentage", CorrelationToken: "corr"},
+			Endpoint: RequestEndpoint{EndpointID: "fan-1"},
+			Payload:  json.RawMessage(`{"percentageDelta": -20}`),
+		},
+	}
+
+	resp, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+	if resp.Event.Header.CorrelationToken != "corr" || resp.Event.Endpoint.EndpointID != "fan-1" {
+		t.Errorf("response not addressed to request: %+v", resp.Event)
+	}
+	if string(resp.Event.Payload) != `{"applied":-20}` {
+		t.Errorf("unexpected payload: %s", resp.Event.Payload)
+	}
+
+	req.SetPayload(json.RawMessage(`{"percentageDelta": "lots"}`))
+	if _, err := handler(context.Background(), req); err == nil {
+		t.Error("expected error for invalid payload")
+	}
+}
+
+func TestTypedValueOutOfRange(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	handler := ValueOutOfRangeHandler(rb, Typed(func(ctx context.Context, req *Request, payload AdjustPercentagePayload) (*Response, error) {
+		return rb.BasicResponse(req), nil
+	}))
+
+	req := &Request{
+		Directive: RequestDirective{
+			Header:   Header{Namespace: NamespacePercentageController, Name: "AdjustPercentage"},
+			Endpoint: RequestEndpoint{EndpointID: "fan-1"},
+			Payload:  json.RawMessage(`{"percentageDelta": -150}`),
+		},
+	}
+
+	resp, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+	if resp.Event.Header.Name != "ErrorResponse" {
+		t.Fatalf("expected ErrorResponse, got %s", resp.Event.Header.Name)
+	}
+	var payload ValueOutOfRangeErrorPayload
+	if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+	if payload.Type != ErrorTypeValueOutOfRange || payload.ValidRange != (ValidRange{-100, 100}) {
+		t.Errorf("unexpected payload: %s", resp.Event.Payload)
+	}
+
+	req.SetPayload(json.RawMessage(`{"percentageDelta": -100}`))
+	resp, err = handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+	if resp.Event.Header.Name != "Response" {
+		t.Errorf("expected Response, got %s", resp.Event.Header.Name)
+	}
+}
+
+func TestSetPercentageOutOfRange(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	handler := ValueOutOfRangeHandler(rb, Typed(func(ctx context.Context, req *Request, payload SetPercentagePayload) (*Response, error) {
+		return rb.BasicResponse(req), nil
+	}))
+
+	for payload, expected := range map[string]string{
+		`{"percentage": 0}`:   "Response",
+		`{"percentage": 100}`: "Response",
+		`{"percentage": 300}`: "ErrorResponse",
+		`{"percentage": -5}`:  "ErrorResponse",
+	} {
+		req := &Request{
+			Directive: RequestDirective{
+				Header:   Header{Namespace: NamespacePercentageController, Name: "SetPercentage"},
+				Endpoint: RequestEndpoint{EndpointID: "fan-1"},
+				Payload:  json.RawMessage(payload),
+			},
+		}
+		resp, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s: failed to handle request: %v", payload, err)
+		}
+		if resp.Event.Header.Name != expected {
+			t.Errorf("%s: expected %s, got %s", payload, expected, resp.Event.Header.Name)
+		}
+	}
+}
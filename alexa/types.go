@@ -12,17 +12,24 @@ import (
 // Request represents an incoming request from the smart home service
 type Request struct {
 	Directive RequestDirective `json:"directive"`
+
+	raw          json.RawMessage
+	received     received
+	payloadCache *payloadCache
 }
 
 type RequestDirective struct {
 	Header   Header          `json:"header"`
 	Endpoint RequestEndpoint `json:"endpoint"`
-	Payload  json.RawMessage `json:"payload"`
+	// Payload of a decoded request should be replaced with Request.SetPayload
+	Payload json.RawMessage `json:"payload"`
 }
 
 type Header struct {
-	Namespace        string `json:"namespace"`
-	Name             string `json:"name"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Instance identifies the capability instance of multi-instance interfaces
+	Instance         string `json:"instance,omitempty"`
 	MessageID        string `json:"messageId"`
 	CorrelationToken string `json:"correlationToken,omitempty"`
 	PayloadVersion   string `json:"payloadVersion"`
@@ -34,9 +41,14 @@ type RequestEndpoint struct {
 	Cookie     map[string]string `json:"cookie,omitempty"`
 }
 
+// Scope identifies the user a directive or event is for. Type is one of the ScopeType
+// enums, see Partitioned for BearerTokenWithPartition scopes.
 type Scope struct {
 	Type  string `json:"type"`
 	Token string `json:"token"`
+	// Partition and UserID are only set for BearerTokenWithPartition scopes
+	Partition string `json:"partition,omitempty"`
+	UserID    string `json:"userId,omitempty"`
 }
 
 // Response represents a response to a request from the smart home service
@@ -51,17 +63,80 @@ type ResponseContext struct {
 
 // Namespace enums
 const (
-	NamespaceAlexa                = "Alexa"
-	NamespaceAuthorization        = "Alexa.Authorization"
-	NamespaceDiscovery            = "Alexa.Discovery"
-	NamespacePercentageController = "Alexa.PercentageController"
-	NamespacePowerController      = "Alexa.PowerController"
-	NamespaceSceneController      = "Alexa.SceneController"
-	NamespaceTemperatureSensor    = "Alexa.TemperatureSensor"
+	NamespaceAlexa                           = "Alexa"
+	NamespaceAuthorization                   = "Alexa.Authorization"
+	NamespaceBrightnessController            = "Alexa.BrightnessController"
+	NamespaceCameraStreamController          = "Alexa.CameraStreamController"
+	NamespaceChannelController               = "Alexa.ChannelController"
+	NamespaceColorController                 = "Alexa.ColorController"
+	NamespaceColorTemperatureController      = "Alexa.ColorTemperatureController"
+	NamespaceCommissionable                  = "Alexa.Commissionable"
+	NamespaceContactSensor                   = "Alexa.ContactSensor"
+	NamespaceDataController                  = "Alexa.DataController"
+	NamespaceDeviceUsageEstimation           = "Alexa.DeviceUsage.Estimation"
+	NamespaceDeviceUsageMeter                = "Alexa.DeviceUsage.Meter"
+	NamespaceDiscovery                       = "Alexa.Discovery"
+	NamespaceDoorbellEventSource             = "Alexa.DoorbellEventSource"
+	NamespaceEndpointHealth                  = "Alexa.EndpointHealth"
+	NamespaceEqualizerController             = "Alexa.EqualizerController"
+	NamespaceEventDetectionSensor            = "Alexa.EventDetectionSensor"
+	NamespaceInventoryLevelSensor            = "Alexa.InventoryLevelSensor"
+	NamespaceInventoryUsageSensor            = "Alexa.InventoryUsageSensor"
+	NamespaceKeypadController                = "Alexa.KeypadController"
+	NamespaceLockController                  = "Alexa.LockController"
+	NamespaceMediaMetadata                   = "Alexa.MediaMetadata"
+	NamespaceModeController                  = "Alexa.ModeController"
+	NamespaceMotionSensor                    = "Alexa.MotionSensor"
+	NamespaceNetworkingAccessController      = "Alexa.Networking.AccessController"
+	NamespaceNetworkingConnectedDevice       = "Alexa.Networking.ConnectedDevice"
+	NamespaceNetworkingHomeNetworkController = "Alexa.Networking.HomeNetworkController"
+	NamespacePercentageController            = "Alexa.PercentageController"
+	NamespacePlaybackController              = "Alexa.PlaybackController"
+	NamespacePlaybackStateReporter           = "Alexa.PlaybackStateReporter"
+	NamespacePowerController                 = "Alexa.PowerController"
+	NamespaceProactiveNotificationSource     = "Alexa.ProactiveNotificationSource"
+	NamespaceRTCSessionController            = "Alexa.RTCSessionController"
+	NamespaceRecordController                = "Alexa.RecordController"
+	NamespaceRemoteVideoPlayer               = "Alexa.RemoteVideoPlayer"
+	NamespaceSceneController                 = "Alexa.SceneController"
+	NamespaceSecurityPanelController         = "Alexa.SecurityPanelController"
+	NamespaceSimpleEventSource               = "Alexa.SimpleEventSource"
+	NamespaceSpeaker                         = "Alexa.Speaker"
+	NamespaceTemperatureSensor               = "Alexa.TemperatureSensor"
+	NamespaceThermostatController            = "Alexa.ThermostatController"
+	NamespaceTimeHoldController              = "Alexa.TimeHoldController"
+	NamespaceToggleController                = "Alexa.ToggleController"
+	NamespaceVideoRecorder                   = "Alexa.VideoRecorder"
+)
+
+// Directive name enums
+const (
+	// DirectiveReportState requests the current state of an endpoint's retrievable properties
+	DirectiveReportState = "ReportState"
+)
+
+// Event name enums
+const (
+	// EventStateReport answers a ReportState directive
+	EventStateReport = "StateReport"
+)
+
+// ErrorType enums
+const (
+	ErrorTypeAcceptGrantFailed              = "ACCEPT_GRANT_FAILED"
+	ErrorTypeEndpointUnreachable            = "ENDPOINT_UNREACHABLE"
+	ErrorTypeExpiredAuthorizationCredential = "EXPIRED_AUTHORIZATION_CREDENTIAL"
+	ErrorTypeInternalError                  = "INTERNAL_ERROR"
+	ErrorTypeInvalidAuthorizationCredential = "INVALID_AUTHORIZATION_CREDENTIAL"
+	ErrorTypeInvalidDirective               = "INVALID_DIRECTIVE"
+	ErrorTypeInvalidValue                   = "INVALID_VALUE"
+	ErrorTypeNoSuchEndpoint                 = "NO_SUCH_ENDPOINT"
+	ErrorTypeValueOutOfRange                = "VALUE_OUT_OF_RANGE"
 )
 
 type ContextProperty struct {
 	Namespace                 string          `json:"namespace"`
+	Instance                  string          `json:"instance,omitempty"`
 	Name                      string          `json:"name"`
 	Value                     json.RawMessage `json:"value"`
 	TimeOfSample              time.Time       `json:"timeOfSample"`
@@ -83,20 +158,61 @@ type ResponseEndpoint struct {
 // DisplayCategory enums
 const (
 	DisplayCategoryActivityTrigger   = "ACTIVITY_TRIGGER"
+	DisplayCategoryContactSensor     = "CONTACT_SENSOR"
 	DisplayCategoryDoor              = "DOOR"
 	DisplayCategoryExteriorBlind     = "EXTERIOR_BLIND"
 	DisplayCategoryInteriorBlind     = "INTERIOR_BLIND"
+	DisplayCategoryLight             = "LIGHT"
+	DisplayCategoryMotionSensor      = "MOTION_SENSOR"
+	DisplayCategorySmartLock         = "SMARTLOCK"
 	DisplayCategorySwitch            = "SWITCH"
 	DisplayCategoryTemperatureSensor = "TEMPERATURE_SENSOR"
+	DisplayCategoryThermostat        = "THERMOSTAT"
 	DisplayCategoryOther             = "OTHER"
 )
 
 // Interface enums
 const (
-	InterfacePercentageController = NamespacePercentageController
-	InterfacePowerController      = NamespacePowerController
-	InterfaceSceneController      = NamespaceSceneController
-	InterfaceTemperatureSensor    = NamespaceTemperatureSensor
+	InterfaceBrightnessController            = NamespaceBrightnessController
+	InterfaceCameraStreamController          = NamespaceCameraStreamController
+	InterfaceChannelController               = NamespaceChannelController
+	InterfaceColorController                 = NamespaceColorController
+	InterfaceColorTemperatureController      = NamespaceColorTemperatureController
+	InterfaceCommissionable                  = NamespaceCommissionable
+	InterfaceContactSensor                   = NamespaceContactSensor
+	InterfaceDataController                  = NamespaceDataController
+	InterfaceDeviceUsageEstimation           = NamespaceDeviceUsageEstimation
+	InterfaceDeviceUsageMeter                = NamespaceDeviceUsageMeter
+	InterfaceDoorbellEventSource             = NamespaceDoorbellEventSource
+	InterfaceEqualizerController             = NamespaceEqualizerController
+	InterfaceEventDetectionSensor            = NamespaceEventDetectionSensor
+	InterfaceInventoryLevelSensor            = NamespaceInventoryLevelSensor
+	InterfaceInventoryUsageSensor            = NamespaceInventoryUsageSensor
+	InterfaceKeypadController                = NamespaceKeypadController
+	InterfaceLockController                  = NamespaceLockController
+	InterfaceMediaMetadata                   = NamespaceMediaMetadata
+	InterfaceModeController                  = NamespaceModeController
+	InterfaceMotionSensor                    = NamespaceMotionSensor
+	InterfaceNetworkingAccessController      = NamespaceNetworkingAccessController
+	InterfaceNetworkingConnectedDevice       = NamespaceNetworkingConnectedDevice
+	InterfaceNetworkingHomeNetworkController = NamespaceNetworkingHomeNetworkController
+	InterfacePercentageController            = NamespacePercentageController
+	InterfacePlaybackController              = NamespacePlaybackController
+	InterfacePlaybackStateReporter           = NamespacePlaybackStateReporter
+	InterfacePowerController                 = NamespacePowerController
+	InterfaceProactiveNotificationSource     = NamespaceProactiveNotificationSource
+	InterfaceRTCSessionController            = NamespaceRTCSessionController
+	InterfaceRecordController                = NamespaceRecordController
+	InterfaceRemoteVideoPlayer               = NamespaceRemoteVideoPlayer
+	InterfaceSceneController                 = NamespaceSceneController
+	InterfaceSecurityPanelController         = NamespaceSecurityPanelController
+	InterfaceSimpleEventSource               = NamespaceSimpleEventSource
+	InterfaceSpeaker                         = NamespaceSpeaker
+	InterfaceTemperatureSensor               = NamespaceTemperatureSensor
+	InterfaceThermostatController            = NamespaceThermostatController
+	InterfaceTimeHoldController              = NamespaceTimeHoldController
+	InterfaceToggleController                = NamespaceToggleController
+	InterfaceVideoRecorder                   = NamespaceVideoRecorder
 )
 
 // EmptyPayload is a payload with no content
@@ -114,15 +230,57 @@ type DiscoverEndpoint struct {
 	DisplayCategories []string             `json:"displayCategories"`
 	Cookie            map[string]string    `json:"cookie,omitempty"`
 	Capabilities      []DiscoverCapability `json:"capabilities"`
+	// AdditionalAttributes and Connections optionally identify the physical device,
+	// e.g. to match a Matter bridged endpoint with the device Alexa commissions.
+	AdditionalAttributes *AdditionalAttributes `json:"additionalAttributes,omitempty"`
+	Connections          []Connection          `json:"connections,omitempty"`
 }
 
 type DiscoverCapability struct {
-	Type                 string              `json:"type"`
-	Interface            string              `json:"interface"`
-	Version              string              `json:"version"`
-	Properties           *DiscoverProperties `json:"properties,omitempty"`
-	SupportsDeactivation *bool               `json:"supportsDeactivation,omitempty"`
-	ProactivelyReported  *bool               `json:"proactivelyReported,omitempty"`
+	Type                 string               `json:"type"`
+	Interface            string               `json:"interface"`
+	Instance             string               `json:"instance,omitempty"`
+	Version              string               `json:"version"`
+	Properties           *DiscoverProperties  `json:"properties,omitempty"`
+	CapabilityResources  *CapabilityResources `json:"capabilityResources,omitempty"`
+	SupportsDeactivation *bool                `json:"supportsDeactivation,omitempty"`
+	ProactivelyReported  *bool                `json:"proactivelyReported,omitempty"`
+	// Configuration holds the interface specific configuration, e.g. a marshaled
+	// DataControllerConfiguration
+	Configuration json.RawMessage `json:"configuration,omitempty"`
+	// Configurations is the plural configuration of Alexa.EqualizerController, e.g. a
+	// marshaled EqualizerConfiguration
+	Configurations json.RawMessage `json:"configurations,omitempty"`
+	// CameraStreamConfigurations describes the streams of Alexa.CameraStreamController
+	CameraStreamConfigurations []CameraStreamConfiguration `json:"cameraStreamConfigurations,omitempty"`
+	// SupportedOperations lists the PlaybackOperation enums of Alexa.PlaybackController
+	SupportedOperations []string `json:"supportedOperations,omitempty"`
+	// Keys lists the Key enums of Alexa.KeypadController
+	Keys []string `json:"keys,omitempty"`
+	// Semantics maps utterances like open and close to Toggle, Mode and Range controller instances
+	Semantics *Semantics `json:"semantics,omitempty"`
+}
+
+// CapabilityResources provides the names users can refer to an instance of a capability by
+type CapabilityResources struct {
+	FriendlyNames []FriendlyName `json:"friendlyNames"`
+}
+
+// FriendlyName enums
+const (
+	FriendlyNameAsset = "asset"
+	FriendlyNameText  = "text"
+)
+
+type FriendlyName struct {
+	Type  string            `json:"@type"`
+	Value FriendlyNameValue `json:"value"`
+}
+
+type FriendlyNameValue struct {
+	Text    string `json:"text,omitempty"`
+	Locale  string `json:"locale,omitempty"`
+	AssetID string `json:"assetId,omitempty"`
 }
 
 type DiscoverProperties struct {
@@ -135,6 +293,42 @@ type DiscoverProperty struct {
 	Name string `json:"name"`
 }
 
+// Cause enums
+const (
+	CauseAppInteraction      = "APP_INTERACTION"
+	CausePeriodicPoll        = "PERIODIC_POLL"
+	CausePhysicalInteraction = "PHYSICAL_INTERACTION"
+	CauseRuleTrigger         = "RULE_TRIGGER"
+	CauseVoiceInteraction    = "VOICE_INTERACTION"
+)
+
+type ChangeReportPayload struct {
+	Change Change `json:"change"`
+}
+
+type Change struct {
+	Cause      Cause             `json:"cause"`
+	Properties []ContextProperty `json:"properties"`
+}
+
+type Cause struct {
+	Type string `json:"type"`
+}
+
+type AddOrUpdateReportPayload struct {
+	Endpoints []DiscoverEndpoint `json:"endpoints"`
+	Scope     Scope              `json:"scope"`
+}
+
+type DeleteReportPayload struct {
+	Endpoints []DeleteReportEndpoint `json:"endpoints"`
+	Scope     Scope                  `json:"scope"`
+}
+
+type DeleteReportEndpoint struct {
+	EndpointID string `json:"endpointId"`
+}
+
 type AcceptGrantPayload struct {
 	Grant   AcceptGrantGrant   `json:"grant"`
 	Grantee AcceptGrantGrantee `json:"grantee"`
@@ -150,8 +344,21 @@ type AcceptGrantGrantee struct {
 	Token string `json:"token"`
 }
 
+// Connectivity enums
+const (
+	ConnectivityOK          = "OK"
+	ConnectivityUnreachable = "UNREACHABLE"
+)
+
+type ConnectivityValue struct {
+	Value string `json:"value"`
+	// Reason optionally explains why an UNREACHABLE endpoint can't be reached
+	Reason string `json:"reason,omitempty"`
+}
+
 // TemperatureScale enums
 const (
+	TemperatureScaleCelsius    = "CELSIUS"
 	TemperatureScaleFahrenheit = "FAHRENHEIT"
 )
 
@@ -160,10 +367,37 @@ type TemperatureValue struct {
 	Scale string  `json:"scale"`
 }
 
+// ReportStatePayload is the payload of a ReportState directive. It's always empty, the
+// state of every retrievable property of the endpoint is expected in the StateReport.
+type ReportStatePayload struct{}
+
+// StateReportPayload is the payload of a StateReport. It's always empty, the
+// properties are reported in the response context.
+type StateReportPayload struct{}
+
 type SetPercentagePayload struct {
-	Percentage uint8 `json:"percentage"`
+	Percentage int `json:"percentage"`
 }
 
 type AdjustPercentagePayload struct {
-	PercentageDelta int8 `json:"percentageDelta"`
+	PercentageDelta int `json:"percentageDelta"`
+}
+
+// ValueOutOfRangeErrorPayload is the payload of a VALUE_OUT_OF_RANGE ErrorResponse
+type ValueOutOfRangeErrorPayload struct {
+	Type       string     `json:"type"`
+	Message    string     `json:"message"`
+	ValidRange ValidRange `json:"validRange"`
+}
+
+type ValidRange struct {
+	MinimumValue float64 `json:"minimumValue"`
+	MaximumValue float64 `json:"maximumValue"`
+}
+
+// SceneActivationPayload is the payload of the ActivationStarted and DeactivationStarted
+// events of Alexa.SceneController
+type SceneActivationPayload struct {
+	Cause     Cause     `json:"cause"`
+	Timestamp time.Time `json:"timestamp"`
 }
@@ -0,0 +1,115 @@
+package alexa
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncValidationHandler validates responses against the smart home schema in a
+// background goroutine so validation can stay enabled in production without adding
+// latency to the directive round trip. Responses are queued for validation and dropped
+// if the queue is full.
+type AsyncValidationHandler struct {
+	handler   Handler
+	onInvalid func(req *Request, respJSON []byte, err error)
+
+	queue   chan validationJob
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	dropped int64
+}
+
+type validationJob struct {
+	req      *Request
+	respJSON []byte
+}
+
+// NewAsyncValidationHandler wraps handler and starts the validation goroutine. onInvalid
+// is called from that goroutine with each response that fails validation. The error is
+// a *SchemaError unless the response couldn't be validated at all. Up to queueSize
+// responses wait for validation. Invalid responses are ignored if onInvalid is nil.
+func NewAsyncValidationHandler(handler Handler, queueSize int,
+	onInvalid func(req *Request, respJSON []byte, err error)) *AsyncValidationHandler {
+	if onInvalid == nil {
+		onInvalid = func(req *Request, respJSON []byte, err error) {}
+	}
+	a := &AsyncValidationHandler{
+		handler:   handler,
+		onInvalid: onInvalid,
+		queue:     make(chan validationJob, queueSize),
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// HandleRequest passes the request to the wrapped handler and queues the response
+// for validation. The response is marshaled before returning so it may be modified
+// or reused afterwards.
+func (a *AsyncValidationHandler) HandleRequest(ctx context.Context, req *Request) (*Response, error) {
+	resp, err := a.handler.HandleRequest(ctx, req)
+	if resp == nil {
+		return resp, err
+	}
+
+	respJSON, marshalErr := MarshalResponse(resp)
+	if marshalErr != nil {
+		a.onInvalid(req, nil, marshalErr)
+		return resp, err
+	}
+
+	select {
+	case <-a.stop:
+		atomic.AddInt64(&a.dropped, 1)
+	default:
+		select {
+		case a.queue <- validationJob{req, respJSON}:
+		default:
+			atomic.AddInt64(&a.dropped, 1)
+		}
+	}
+
+	return resp, err
+}
+
+// Dropped returns the number of responses skipped because the queue was full
+// or the handler was closed
+func (a *AsyncValidationHandler) Dropped() int64 {
+	return atomic.LoadInt64(&a.dropped)
+}
+
+// Close validates the responses already queued and stops the validation goroutine
+func (a *AsyncValidationHandler) Close() {
+	a.once.Do(func() {
+		close(a.stop)
+	})
+	<-a.stopped
+}
+
+func (a *AsyncValidationHandler) run() {
+	defer close(a.stopped)
+	for {
+		select {
+		case job := <-a.queue:
+			a.validate(job)
+		case <-a.stop:
+			for {
+				select {
+				case job := <-a.queue:
+					a.validate(job)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (a *AsyncValidationHandler) validate(job validationJob) {
+	if err := ValidateResponse(job.respJSON); err != nil {
+		a.onInvalid(job.req, job.respJSON, err)
+	}
+}
@@ -0,0 +1,86 @@
+package alexa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestAsyncValidationHandler(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	var req Request
+	if err := json.Unmarshal([]byte(sampleRequest), &req); err != nil {
+		t.Fatalf("failed to unmarshal request: %v", err)
+	}
+
+	valid := rb.StateReportResponse(&req, testProperties()[:1]...)
+	invalid := rb.StateReportResponse(&req, testProperties()...)
+
+	var mu sync.Mutex
+	var failures []error
+	handler := NewAsyncValidationHandler(
+		HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			if req.Directive.Header.Name == "Invalid" {
+				return invalid, nil
+			}
+			return valid, nil
+		}),
+		10,
+		func(req *Request, respJSON []byte, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failures = append(failures, err)
+		})
+
+	for _, name := range []string{"ReportState", "Invalid"} {
+		req := req
+		req.Directive.Header.Name = name
+		if _, err := handler.HandleRequest(context.Background(), &req); err != nil {
+			t.Fatalf("failed to handle request: %v", err)
+		}
+	}
+	handler.Close()
+
+	if _, err := handler.HandleRequest(context.Background(), &req); err != nil {
+		t.Fatalf("failed to handle request after close: %v", err)
+	}
+
+	if len(failures) != 1 {
+		t.Fatalf("expected 1 invalid response, got %d", len(failures))
+	}
+	var schemaErr *SchemaError
+	if !errors.As(failures[0], &schemaErr) {
+		t.Errorf("expected SchemaError, got %v", failures[0])
+	}
+	if handler.Dropped() != 1 {
+		t.Errorf("expected the request after close to be dropped, got %d", handler.Dropped())
+	}
+}
+
+func TestAsyncValidationHandlerNilOnInvalid(t *testing.T) {
+	rb := &ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	var req Request
+	if err := json.Unmarshal([]byte(sampleRequest), &req); err != nil {
+		t.Fatalf("failed to unmarshal request: %v", err)
+	}
+
+	invalid := rb.StateReportResponse(&req, testProperties()...)
+	handler := NewAsyncValidationHandler(
+		HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			return invalid, nil
+		}),
+		10,
+		nil)
+
+	resp, err := handler.HandleRequest(context.Background(), &req)
+	if err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+	handler.Close()
+
+	if resp != invalid {
+		t.Errorf("expected the handler's response to be returned")
+	}
+}
@@ -0,0 +1,85 @@
+package alexa
+
+import (
+	"context"
+	"errors"
+)
+
+// EventSearchAndRecordResponse answers a SearchAndRecord directive
+const EventSearchAndRecordResponse = "Alexa.SearchAndRecordResponse"
+
+// Quantifier enums
+const (
+	QuantifierAll = "ALL"
+	QuantifierNew = "NEW"
+)
+
+// RecordingStatus enums
+const (
+	RecordingStatusAlreadyScheduled = "ALREADY_SCHEDULED"
+	RecordingStatusNotScheduled     = "NOT_SCHEDULED"
+	RecordingStatusScheduled        = "SCHEDULED"
+)
+
+// SearchAndRecordPayload requests recording the video matching Entities, optionally
+// airing within TimeWindow. Quantifier is NEW when only new episodes should be recorded.
+type SearchAndRecordPayload struct {
+	Entities   []SearchEntity    `json:"entities"`
+	TimeWindow *SearchTimeWindow `json:"timeWindow,omitempty"`
+	Quantifier *Quantifier       `json:"quantifier,omitempty"`
+}
+
+type Quantifier struct {
+	Name string `json:"name"`
+}
+
+// Validate checks that an entity was requested
+func (p SearchAndRecordPayload) Validate() error {
+	if len(p.Entities) == 0 {
+		return errors.New("missing entities")
+	}
+	return nil
+}
+
+// SearchAndRecordResponsePayload answers a SearchAndRecord directive. StorageLevel is the
+// percentage of the recorder's storage in use.
+type SearchAndRecordResponsePayload struct {
+	RecordingStatus string `json:"recordingStatus"`
+	StorageLevel    *int   `json:"storageLevel,omitempty"`
+}
+
+// Validate checks that the storage level is within 0 to 100
+func (p SearchAndRecordResponsePayload) Validate() error {
+	if p.StorageLevel != nil {
+		return validateRange("storageLevel", float64(*p.StorageLevel), 0, 100)
+	}
+	return nil
+}
+
+// VideoRecorderHandler routes handling of recording directives. cancelRecording and
+// deleteRecording may be nil for recorders that don't support them.
+func VideoRecorderHandler(searchAndRecord, cancelRecording, deleteRecording Handler) HandlerFunc {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch req.DirectiveName() {
+		case "SearchAndRecord":
+			return searchAndRecord.HandleRequest(ctx, req)
+		case "CancelRecording":
+			if cancelRecording != nil {
+				return cancelRecording.HandleRequest(ctx, req)
+			}
+		case "DeleteRecording":
+			if deleteRecording != nil {
+				return deleteRecording.HandleRequest(ctx, req)
+			}
+		}
+		return nil, UnexpectedDirective("VideoRecorderHandler", req)
+	}
+}
+
+// SearchAndRecordResponse answers a SearchAndRecord directive
+func (r *ResponseBuilder) SearchAndRecordResponse(req *Request, payload SearchAndRecordResponsePayload) (*Response, error) {
+	if err := payload.Validate(); err != nil {
+		return nil, err
+	}
+	return TypedResponse(r, req, NamespaceVideoRecorder, EventSearchAndRecordResponse, payload)
+}
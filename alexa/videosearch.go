@@ -0,0 +1,53 @@
+package alexa
+
+// SearchEntity type enums
+const (
+	EntityTypeActor             = "Actor"
+	EntityTypeApp               = "App"
+	EntityTypeChannel           = "Channel"
+	EntityTypeCharacter         = "Character"
+	EntityTypeDirector          = "Director"
+	EntityTypeEpisode           = "Episode"
+	EntityTypeEvent             = "Event"
+	EntityTypeFranchise         = "Franchise"
+	EntityTypeGenre             = "Genre"
+	EntityTypeLeague            = "League"
+	EntityTypeMediaType         = "MediaType"
+	EntityTypePopularity        = "Popularity"
+	EntityTypeProductionCompany = "ProductionCompany"
+	EntityTypeRecency           = "Recency"
+	EntityTypeSeason            = "Season"
+	EntityTypeSport             = "Sport"
+	EntityTypeSportsTeam        = "SportsTeam"
+	EntityTypeVideo             = "Video"
+	EntityTypeVideoResolution   = "VideoResolution"
+)
+
+// SearchEntity is an entity of a video search, e.g. the title or an actor the user named.
+// It's shared by the Alexa.VideoRecorder and Alexa.RemoteVideoPlayer directives.
+type SearchEntity struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	// ExternalIDs identify the entity in catalogs, e.g. gracenote or imdb ids
+	ExternalIDs    map[string]string `json:"externalIds,omitempty"`
+	EntityMetadata map[string]string `json:"entityMetadata,omitempty"`
+	URI            string            `json:"uri,omitempty"`
+}
+
+// SearchTimeWindow limits a search to videos airing in a window. Times are RFC 3339
+// timestamps as sent by Alexa.
+type SearchTimeWindow struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// Entities returns the entities of type
+func Entities(entities []SearchEntity, entityType string) []SearchEntity {
+	var matched []SearchEntity
+	for _, entity := range entities {
+		if entity.Type == entityType {
+			matched = append(matched, entity)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,76 @@
+package dynamostore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// MessageIDStore uses a DynamoDB table to record handled directive message ids so
+// duplicates can be detected across processes. The table must have a string
+// partition key named MessageID. Enable DynamoDB TTL on the ExpiresAt attribute
+// to have old ids removed automatically.
+type MessageIDStore struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Table    string
+	// TTL defaults to alexa.DefaultMessageIDTTL
+	TTL time.Duration
+}
+
+// MarkSeen records messageID and reports whether it was already recorded and unexpired.
+func (m *MessageIDStore) MarkSeen(ctx context.Context, messageID string) (bool, error) {
+	now := time.Now()
+	req := dynamodb.PutItemInput{
+		TableName: &m.Table,
+		Item: map[string]*dynamodb.AttributeValue{
+			"MessageID": {S: aws.String(messageID)},
+			"ExpiresAt": {N: aws.String(strconv.FormatInt(now.Add(m.ttl()).Unix(), 10))},
+		},
+		// DynamoDB TTL deletion is lazy so expired items are treated as absent
+		ConditionExpression: aws.String("attribute_not_exists(MessageID) OR ExpiresAt < :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	}
+
+	if _, err := m.DynamoDB.PutItemWithContext(ctx, &req); err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+				return true, nil
+			}
+		}
+		return false, fmt.Errorf("failed to put message id: %v", err)
+	}
+
+	return false, nil
+}
+
+// Release deletes messageID from the table
+func (m *MessageIDStore) Release(ctx context.Context, messageID string) error {
+	req := dynamodb.DeleteItemInput{
+		TableName: &m.Table,
+		Key: map[string]*dynamodb.AttributeValue{
+			"MessageID": {S: aws.String(messageID)},
+		},
+	}
+
+	if _, err := m.DynamoDB.DeleteItemWithContext(ctx, &req); err != nil {
+		return fmt.Errorf("failed to delete message id: %v", err)
+	}
+
+	return nil
+}
+
+func (m *MessageIDStore) ttl() time.Duration {
+	if m.TTL <= 0 {
+		return alexa.DefaultMessageIDTTL
+	}
+	return m.TTL
+}
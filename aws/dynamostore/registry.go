@@ -0,0 +1,150 @@
+package dynamostore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// EndpointStore is a registry.Store backed by a DynamoDB table. The table must have a
+// string partition key named UserID and a string sort key named EndpointID. The
+// endpoint definition is stored as json in the Endpoint attribute.
+type EndpointStore struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Table    string
+}
+
+func (e *EndpointStore) List(ctx context.Context, userID string) ([]alexa.DiscoverEndpoint, error) {
+	req := dynamodb.QueryInput{
+		TableName:              &e.Table,
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userID": {S: aws.String(userID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	var endpoints []alexa.DiscoverEndpoint
+	var unmarshalErr error
+	err := e.DynamoDB.QueryPagesWithContext(ctx, &req, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			endpoint, err := unmarshalEndpoint(item)
+			if err != nil {
+				unmarshalErr = err
+				return false
+			}
+			endpoints = append(endpoints, *endpoint)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query endpoints: %v", err)
+	}
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	return endpoints, nil
+}
+
+func (e *EndpointStore) Get(ctx context.Context, userID, endpointID string) (*alexa.DiscoverEndpoint, error) {
+	req := dynamodb.GetItemInput{
+		TableName:      &e.Table,
+		Key:            endpointKey(userID, endpointID),
+		ConsistentRead: aws.Bool(true),
+	}
+
+	resp, err := e.DynamoDB.GetItemWithContext(ctx, &req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoint: %v", err)
+	}
+	if resp.Item == nil {
+		return nil, nil
+	}
+
+	return unmarshalEndpoint(resp.Item)
+}
+
+func (e *EndpointStore) Put(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) error {
+	endpointJSON, err := json.Marshal(endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal endpoint: %v", err)
+	}
+
+	item := endpointKey(userID, endpoint.EndpointID)
+	item["Endpoint"] = &dynamodb.AttributeValue{S: aws.String(string(endpointJSON))}
+
+	req := dynamodb.PutItemInput{
+		TableName: &e.Table,
+		Item:      item,
+	}
+
+	if _, err := e.DynamoDB.PutItemWithContext(ctx, &req); err != nil {
+		return fmt.Errorf("failed to put endpoint: %v", err)
+	}
+
+	return nil
+}
+
+func (e *EndpointStore) Delete(ctx context.Context, userID, endpointID string) error {
+	req := dynamodb.DeleteItemInput{
+		TableName: &e.Table,
+		Key:       endpointKey(userID, endpointID),
+	}
+
+	if _, err := e.DynamoDB.DeleteItemWithContext(ctx, &req); err != nil {
+		return fmt.Errorf("failed to delete endpoint: %v", err)
+	}
+
+	return nil
+}
+
+// Users returns the ids of the users with endpoints. It scans the whole table.
+func (e *EndpointStore) Users(ctx context.Context) ([]string, error) {
+	req := dynamodb.ScanInput{
+		TableName:            &e.Table,
+		ProjectionExpression: aws.String("UserID"),
+	}
+
+	seen := make(map[string]bool)
+	var users []string
+	err := e.DynamoDB.ScanPagesWithContext(ctx, &req, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			if attr, ok := item["UserID"]; ok && attr.S != nil && !seen[*attr.S] {
+				seen[*attr.S] = true
+				users = append(users, *attr.S)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan endpoints: %v", err)
+	}
+
+	return users, nil
+}
+
+func endpointKey(userID, endpointID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"UserID":     {S: aws.String(userID)},
+		"EndpointID": {S: aws.String(endpointID)},
+	}
+}
+
+func unmarshalEndpoint(item map[string]*dynamodb.AttributeValue) (*alexa.DiscoverEndpoint, error) {
+	attr, ok := item["Endpoint"]
+	if !ok || attr.S == nil {
+		return nil, fmt.Errorf("item missing Endpoint attribute")
+	}
+
+	var endpoint alexa.DiscoverEndpoint
+	if err := json.Unmarshal([]byte(*attr.S), &endpoint); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal endpoint: %v", err)
+	}
+	return &endpoint, nil
+}
@@ -0,0 +1,102 @@
+package dynamostore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// ResponseStore is an alexa.ResponseStore backed by a DynamoDB table. The table must have
+// a string partition key named MessageID. Enable DynamoDB TTL on the ExpiresAt attribute
+// to have old responses removed automatically.
+type ResponseStore struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Table    string
+	// TTL defaults to alexa.DefaultMessageIDTTL
+	TTL time.Duration
+}
+
+func (r *ResponseStore) Get(ctx context.Context, messageID string) (*alexa.Response, error) {
+	req := dynamodb.GetItemInput{
+		TableName: &r.Table,
+		Key: map[string]*dynamodb.AttributeValue{
+			"MessageID": {S: aws.String(messageID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	resp, err := r.DynamoDB.GetItemWithContext(ctx, &req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response: %v", err)
+	}
+	if resp.Item == nil {
+		return nil, nil
+	}
+
+	// DynamoDB TTL deletion is lazy so expiry is checked here as well
+	if expiresAt, ok := resp.Item["ExpiresAt"]; ok && expiresAt.N != nil {
+		expires, err := strconv.ParseInt(*expiresAt.N, 10, 64)
+		if err == nil && time.Now().Unix() >= expires {
+			return nil, nil
+		}
+	}
+
+	attr, ok := resp.Item["Response"]
+	if !ok || attr.S == nil {
+		return nil, fmt.Errorf("item missing Response attribute")
+	}
+
+	var recorded alexa.Response
+	if err := json.Unmarshal([]byte(*attr.S), &recorded); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	return &recorded, nil
+}
+
+func (r *ResponseStore) Put(ctx context.Context, messageID string, resp *alexa.Response) error {
+	respJSON, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %v", err)
+	}
+
+	now := time.Now()
+	req := dynamodb.PutItemInput{
+		TableName: &r.Table,
+		Item: map[string]*dynamodb.AttributeValue{
+			"MessageID": {S: aws.String(messageID)},
+			"Response":  {S: aws.String(string(respJSON))},
+			"ExpiresAt": {N: aws.String(strconv.FormatInt(now.Add(r.ttl()).Unix(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(MessageID) OR ExpiresAt < :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	}
+
+	if _, err := r.DynamoDB.PutItemWithContext(ctx, &req); err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+				// the first recorded response is kept
+				return nil
+			}
+		}
+		return fmt.Errorf("failed to put response: %v", err)
+	}
+
+	return nil
+}
+
+func (r *ResponseStore) ttl() time.Duration {
+	if r.TTL <= 0 {
+		return alexa.DefaultMessageIDTTL
+	}
+	return r.TTL
+}
@@ -0,0 +1,112 @@
+package dynamostore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/state"
+)
+
+// StateStore is a state.Store backed by a DynamoDB table. The table must have a string
+// partition key named EndpointID and a string sort key named PropertyKey. The property
+// is stored as json in the Property attribute.
+type StateStore struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Table    string
+}
+
+func (s *StateStore) Put(ctx context.Context, endpointID string, properties ...alexa.ContextProperty) error {
+	for _, prop := range properties {
+		propJSON, err := json.Marshal(prop)
+		if err != nil {
+			return fmt.Errorf("failed to marshal property: %v", err)
+		}
+
+		req := dynamodb.PutItemInput{
+			TableName: &s.Table,
+			Item: map[string]*dynamodb.AttributeValue{
+				"EndpointID":  {S: aws.String(endpointID)},
+				"PropertyKey": {S: aws.String(state.PropertyKey(prop))},
+				"Property":    {S: aws.String(string(propJSON))},
+			},
+		}
+
+		if _, err := s.DynamoDB.PutItemWithContext(ctx, &req); err != nil {
+			return fmt.Errorf("failed to put property: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *StateStore) Get(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+	items, err := s.query(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make([]alexa.ContextProperty, 0, len(items))
+	for _, item := range items {
+		attr, ok := item["Property"]
+		if !ok || attr.S == nil {
+			return nil, fmt.Errorf("item missing Property attribute")
+		}
+
+		var prop alexa.ContextProperty
+		if err := json.Unmarshal([]byte(*attr.S), &prop); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal property: %v", err)
+		}
+		props = append(props, prop)
+	}
+
+	return props, nil
+}
+
+func (s *StateStore) Delete(ctx context.Context, endpointID string) error {
+	items, err := s.query(ctx, endpointID)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		req := dynamodb.DeleteItemInput{
+			TableName: &s.Table,
+			Key: map[string]*dynamodb.AttributeValue{
+				"EndpointID":  item["EndpointID"],
+				"PropertyKey": item["PropertyKey"],
+			},
+		}
+		if _, err := s.DynamoDB.DeleteItemWithContext(ctx, &req); err != nil {
+			return fmt.Errorf("failed to delete property: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *StateStore) query(ctx context.Context, endpointID string) ([]map[string]*dynamodb.AttributeValue, error) {
+	req := dynamodb.QueryInput{
+		TableName:              &s.Table,
+		KeyConditionExpression: aws.String("EndpointID = :endpointID"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":endpointID": {S: aws.String(endpointID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	err := s.DynamoDB.QueryPagesWithContext(ctx, &req, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query properties: %v", err)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,109 @@
+package dynamostore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"golang.org/x/oauth2"
+)
+
+// TokenStorage stores a user's oauth tokens in a DynamoDB table. The table must have a
+// string partition key named UserID. The token is stored as json in the Token attribute.
+// Enable encryption at rest on the table and strictly limit access to it.
+type TokenStorage struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Table    string
+}
+
+func (t *TokenStorage) Write(ctx context.Context, id string, token *oauth2.Token) error {
+	content, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %v", err)
+	}
+
+	req := dynamodb.PutItemInput{
+		TableName: &t.Table,
+		Item: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(id)},
+			"Token":  {S: aws.String(string(content))},
+		},
+	}
+
+	if _, err := t.DynamoDB.PutItemWithContext(ctx, &req); err != nil {
+		return fmt.Errorf("failed to put token: %v", err)
+	}
+
+	return nil
+}
+
+func (t *TokenStorage) Read(ctx context.Context, id string) (*oauth2.Token, error) {
+	req := dynamodb.GetItemInput{
+		TableName: &t.Table,
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(id)},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	resp, err := t.DynamoDB.GetItemWithContext(ctx, &req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %v", err)
+	}
+	if resp.Item == nil {
+		return nil, nil
+	}
+
+	attr, ok := resp.Item["Token"]
+	if !ok || attr.S == nil {
+		return nil, fmt.Errorf("item missing Token attribute")
+	}
+
+	var token oauth2.Token
+	if err := json.Unmarshal([]byte(*attr.S), &token); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token: %v", err)
+	}
+
+	return &token, nil
+}
+
+func (t *TokenStorage) Delete(ctx context.Context, id string) error {
+	req := dynamodb.DeleteItemInput{
+		TableName: &t.Table,
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(id)},
+		},
+	}
+
+	if _, err := t.DynamoDB.DeleteItemWithContext(ctx, &req); err != nil {
+		return fmt.Errorf("failed to delete token: %v", err)
+	}
+
+	return nil
+}
+
+// List returns the ids of all users with stored tokens
+func (t *TokenStorage) List(ctx context.Context) ([]string, error) {
+	req := dynamodb.ScanInput{
+		TableName:            &t.Table,
+		ProjectionExpression: aws.String("UserID"),
+	}
+
+	var ids []string
+	err := t.DynamoDB.ScanPagesWithContext(ctx, &req, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			if attr, ok := item["UserID"]; ok && attr.S != nil {
+				ids = append(ids, *attr.S)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan tokens: %v", err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,34 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// CaptureSink is a capture.Sink storing captured directives under Prefix in an S3 bucket
+type CaptureSink struct {
+	S3     s3iface.S3API
+	Bucket string
+	Prefix string
+}
+
+func (c *CaptureSink) Write(ctx context.Context, key string, data []byte) error {
+	req := s3.PutObjectInput{
+		Bucket:      &c.Bucket,
+		Key:         aws.String(path.Join(c.Prefix, key)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+
+	if _, err := c.S3.PutObjectWithContext(ctx, &req); err != nil {
+		return fmt.Errorf("failed to upload capture to s3: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package s3store
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// ConfigSource is a registry.Source loading an endpoint config from an S3 object
+type ConfigSource struct {
+	S3     s3iface.S3API
+	Bucket string
+	Key    string
+}
+
+// Load downloads the object
+func (c *ConfigSource) Load(ctx context.Context) ([]byte, error) {
+	resp, err := c.S3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: &c.Bucket,
+		Key:    &c.Key,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get s3://%s/%s: %v", c.Bucket, c.Key, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read s3://%s/%s: %v", c.Bucket, c.Key, err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,149 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// EndpointStore is a registry.Store that keeps each user's endpoints in a single json
+// document named Prefix + user id. Writes read and replace the whole document so
+// concurrent writers for the same user may overwrite each other's changes.
+type EndpointStore struct {
+	S3     s3iface.S3API
+	Bucket string
+	Prefix string
+}
+
+func (e *EndpointStore) List(ctx context.Context, userID string) ([]alexa.DiscoverEndpoint, error) {
+	endpoints, err := e.read(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]alexa.DiscoverEndpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		list = append(list, endpoint)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].EndpointID < list[j].EndpointID
+	})
+	return list, nil
+}
+
+func (e *EndpointStore) Get(ctx context.Context, userID, endpointID string) (*alexa.DiscoverEndpoint, error) {
+	endpoints, err := e.read(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, ok := endpoints[endpointID]
+	if !ok {
+		return nil, nil
+	}
+	return &endpoint, nil
+}
+
+func (e *EndpointStore) Put(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) error {
+	endpoints, err := e.read(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	endpoints[endpoint.EndpointID] = endpoint
+	return e.write(ctx, userID, endpoints)
+}
+
+func (e *EndpointStore) Delete(ctx context.Context, userID, endpointID string) error {
+	endpoints, err := e.read(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if _, ok := endpoints[endpointID]; !ok {
+		return nil
+	}
+
+	delete(endpoints, endpointID)
+	return e.write(ctx, userID, endpoints)
+}
+
+// Users returns the ids of the users with an endpoint document under Prefix
+func (e *EndpointStore) Users(ctx context.Context) ([]string, error) {
+	req := s3.ListObjectsV2Input{
+		Bucket: &e.Bucket,
+		Prefix: aws.String(e.Prefix),
+	}
+
+	var users []string
+	err := e.S3.ListObjectsV2PagesWithContext(ctx, &req, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			users = append(users, strings.TrimPrefix(aws.StringValue(obj.Key), e.Prefix))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list s3 objects: %v", err)
+	}
+
+	return users, nil
+}
+
+func (e *EndpointStore) read(ctx context.Context, userID string) (map[string]alexa.DiscoverEndpoint, error) {
+	req := s3.GetObjectInput{
+		Bucket: &e.Bucket,
+		Key:    aws.String(e.Prefix + userID),
+	}
+
+	endpoints := make(map[string]alexa.DiscoverEndpoint)
+
+	resp, err := e.S3.GetObjectWithContext(ctx, &req)
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == s3.ErrCodeNoSuchKey {
+				return endpoints, nil
+			}
+		}
+		return nil, fmt.Errorf("failed to retrieve from s3: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read s3 data: %v", err)
+	}
+
+	if err := json.Unmarshal(body, &endpoints); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal endpoints: %v", err)
+	}
+
+	return endpoints, nil
+}
+
+func (e *EndpointStore) write(ctx context.Context, userID string, endpoints map[string]alexa.DiscoverEndpoint) error {
+	content, err := json.Marshal(endpoints)
+	if err != nil {
+		return fmt.Errorf("failed to marshal endpoints: %v", err)
+	}
+
+	req := s3.PutObjectInput{
+		Bucket:      &e.Bucket,
+		Key:         aws.String(e.Prefix + userID),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String("application/json"),
+	}
+
+	if _, err := e.S3.PutObjectWithContext(ctx, &req); err != nil {
+		return fmt.Errorf("failed to upload to s3: %v", err)
+	}
+
+	return nil
+}
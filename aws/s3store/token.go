@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -15,7 +16,7 @@ import (
 )
 
 // TokenStorage uses S3 as a simple backing store for a user's oauth tokens.
-// Tokens are stored as json documents named by the user's id.
+// Tokens are stored as json documents named Prefix + user id.
 // This isn't the most secure option although it can be improved by enabling
 // encryption and strictly limiting access to the S3 bucket.
 // Due to S3's eventually consistent nature a Read may not always reflect the
@@ -23,6 +24,7 @@ import (
 type TokenStorage struct {
 	S3     s3iface.S3API
 	Bucket string
+	Prefix string
 }
 
 func (s *TokenStorage) Write(ctx context.Context, id string, token *oauth2.Token) error {
@@ -33,7 +35,7 @@ func (s *TokenStorage) Write(ctx context.Context, id string, token *oauth2.Token
 
 	req := s3.PutObjectInput{
 		Bucket:      &s.Bucket,
-		Key:         &id,
+		Key:         aws.String(s.Prefix + id),
 		Body:        bytes.NewReader(content),
 		ContentType: aws.String("application/json"),
 	}
@@ -48,7 +50,7 @@ func (s *TokenStorage) Write(ctx context.Context, id string, token *oauth2.Token
 func (s *TokenStorage) Read(ctx context.Context, id string) (*oauth2.Token, error) {
 	req := s3.GetObjectInput{
 		Bucket: &s.Bucket,
-		Key:    &id,
+		Key:    aws.String(s.Prefix + id),
 	}
 
 	resp, err := s.S3.GetObjectWithContext(ctx, &req)
@@ -73,3 +75,37 @@ func (s *TokenStorage) Read(ctx context.Context, id string) (*oauth2.Token, erro
 
 	return &token, nil
 }
+
+func (s *TokenStorage) Delete(ctx context.Context, id string) error {
+	req := s3.DeleteObjectInput{
+		Bucket: &s.Bucket,
+		Key:    aws.String(s.Prefix + id),
+	}
+
+	if _, err := s.S3.DeleteObjectWithContext(ctx, &req); err != nil {
+		return fmt.Errorf("failed to delete from s3: %v", err)
+	}
+
+	return nil
+}
+
+// List returns the ids of all users with stored tokens under Prefix
+func (s *TokenStorage) List(ctx context.Context) ([]string, error) {
+	req := s3.ListObjectsV2Input{
+		Bucket: &s.Bucket,
+		Prefix: aws.String(s.Prefix),
+	}
+
+	var ids []string
+	err := s.S3.ListObjectsV2PagesWithContext(ctx, &req, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			ids = append(ids, strings.TrimPrefix(aws.StringValue(obj.Key), s.Prefix))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list s3 objects: %v", err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,59 @@
+package secretcreds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// CredentialsProvider loads the skill's client credentials from an AWS Secrets Manager
+// secret holding a json document with clientId and clientSecret fields. The secret
+// is cached for RefreshInterval so rotations are picked up without a restart.
+type CredentialsProvider struct {
+	SecretsManager  secretsmanageriface.SecretsManagerAPI
+	SecretID        string
+	RefreshInterval time.Duration
+
+	mu        sync.Mutex
+	creds     alexa.ClientCredentials
+	refreshAt time.Time
+}
+
+// Credentials returns the cached credentials, reloading the secret if the cache has expired
+func (c *CredentialsProvider) Credentials(ctx context.Context) (alexa.ClientCredentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Now().Before(c.refreshAt) {
+		return c.creds, nil
+	}
+
+	resp, err := c.SecretsManager.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: &c.SecretID,
+	})
+	if err != nil {
+		return alexa.ClientCredentials{}, fmt.Errorf("failed to get secret: %v", err)
+	}
+	if resp.SecretString == nil {
+		return alexa.ClientCredentials{}, errors.New("secret has no string value")
+	}
+
+	var creds alexa.ClientCredentials
+	if err := json.Unmarshal([]byte(*resp.SecretString), &creds); err != nil {
+		return alexa.ClientCredentials{}, fmt.Errorf("failed to unmarshal secret: %v", err)
+	}
+	if creds.ClientID == "" || creds.ClientSecret == "" {
+		return alexa.ClientCredentials{}, errors.New("secret missing clientId or clientSecret")
+	}
+
+	c.creds = creds
+	c.refreshAt = time.Now().Add(c.RefreshInterval)
+	return creds, nil
+}
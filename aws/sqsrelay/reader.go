@@ -3,6 +3,7 @@ package sqsrelay
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -24,6 +25,21 @@ type QueueProcessor struct {
 	QueueURL             string
 	Handler              *deferred.Handler
 	QueueWaitTimeSeconds int64
+	// OnReceive is optionally called after each successful receive from the queue,
+	// including ones that return no messages. It can be used to track queue connectivity.
+	OnReceive func()
+	// RequeuePolicy decides what happens to a message that fails to be handled. Dropped
+	// messages are deleted and others are left on the queue to be redelivered. When
+	// the policy is RetrySend the unsent events are kept in Pending and sent again on
+	// redelivery instead of handling the request again. If nil, Process returns the
+	// error, leaving the message to be handled again.
+	RequeuePolicy deferred.RequeuePolicy
+	// OnError is optionally called with handling errors resolved by the RequeuePolicy
+	OnError func(ctx context.Context, req *alexa.Request, err error)
+	// Pending holds unsent events until their message is redelivered. Its TTL should
+	// cover the queue's visibility timeout. The events are kept in process so RetrySend
+	// requires the QueueProcessor to be the queue's only consumer.
+	Pending deferred.PendingEvents
 }
 
 // Process reads and handles SQS queue messages until an error occurs
@@ -37,6 +53,9 @@ func (q *QueueProcessor) Process(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("failed to read from sqs: %v", err)
 		}
+		if q.OnReceive != nil {
+			q.OnReceive()
+		}
 
 		for _, msg := range resp.Messages {
 			var homeReq alexa.Request
@@ -44,8 +63,16 @@ func (q *QueueProcessor) Process(ctx context.Context) error {
 				return fmt.Errorf("failed to read message: %s: %v", *msg.Body, err)
 			}
 
-			if err := q.Handler.HandleRequest(ctx, &homeReq); err != nil {
-				return fmt.Errorf("failed to handle request: %v", err)
+			if err := q.handle(ctx, aws.StringValue(msg.MessageId), &homeReq); err != nil {
+				if q.RequeuePolicy == nil {
+					return fmt.Errorf("failed to handle request: %v", err)
+				}
+				if q.OnError != nil {
+					q.OnError(ctx, &homeReq, err)
+				}
+				if q.requeue(aws.StringValue(msg.MessageId), err) {
+					continue
+				}
 			}
 
 			deleteReq := sqs.DeleteMessageInput{
@@ -58,3 +85,31 @@ func (q *QueueProcessor) Process(ctx context.Context) error {
 		}
 	}
 }
+
+// handle sends any events left unsent by a previous delivery of the message or
+// otherwise handles the request
+func (q *QueueProcessor) handle(ctx context.Context, messageID string, req *alexa.Request) error {
+	if events, ok := q.Pending.Take(messageID); ok {
+		return q.Handler.Resend(ctx, req, events)
+	}
+	return q.Handler.HandleRequest(ctx, req)
+}
+
+// requeue applies the RequeuePolicy to err and reports if the message should be left
+// on the queue for redelivery
+func (q *QueueProcessor) requeue(messageID string, err error) bool {
+	switch q.RequeuePolicy(err) {
+	case deferred.RetrySend:
+		var unsent *deferred.UnsentEventsError
+		if !errors.As(err, &unsent) {
+			// nothing to resend so the request must be handled again
+			return true
+		}
+		q.Pending.Put(messageID, unsent.Events)
+		return true
+	case deferred.RetryRequest:
+		return true
+	default:
+		return false
+	}
+}
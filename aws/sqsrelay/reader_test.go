@@ -0,0 +1,168 @@
+package sqsrelay
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+const benchmarkDirective = `{"directive":{"header":{"namespace":"Alexa.PowerController","name":"TurnOn",` +
+	`"messageId":"1bd5d003-31b9-476f-ad03-71d471922820","correlationToken":"token","payloadVersion":"3"},` +
+	`"endpoint":{"scope":{"type":"BearerToken","token":"access-token"},"endpointId":"switch-1"},"payload":{}}}`
+
+var errDrained = errors.New("drained")
+
+// benchmarkReader returns batches of messages until n have been received
+type benchmarkReader struct {
+	remaining int
+	batch     []*sqs.Message
+}
+
+func (b *benchmarkReader) ReceiveMessageWithContext(aws.Context, *sqs.ReceiveMessageInput, ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	if b.remaining <= 0 {
+		return nil, errDrained
+	}
+	batch := b.batch
+	if b.remaining < len(batch) {
+		batch = batch[:b.remaining]
+	}
+	b.remaining -= len(batch)
+	return &sqs.ReceiveMessageOutput{Messages: batch}, nil
+}
+
+func (b *benchmarkReader) DeleteMessageWithContext(aws.Context, *sqs.DeleteMessageInput, ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+// BenchmarkQueueProcessor measures the per message overhead of reading, handling and
+// sending the response for relayed directives. Baselines are recorded in the README.
+func BenchmarkQueueProcessor(b *testing.B) {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-1" }}
+
+	batch := make([]*sqs.Message, 10)
+	for i := range batch {
+		batch[i] = &sqs.Message{
+			Body:          aws.String(benchmarkDirective),
+			ReceiptHandle: aws.String("receipt"),
+		}
+	}
+
+	reader := &benchmarkReader{batch: batch}
+	processor := &QueueProcessor{
+		SQS:      reader,
+		QueueURL: "https://sqs.us-west-2.amazonaws.com/123456789012/directives.fifo",
+		Handler: &deferred.Handler{
+			RequestHandler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+				return rb.BasicResponse(req), nil
+			}),
+			EventSender: deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+				_, err := alexa.MarshalResponse(resp)
+				return err
+			}),
+		},
+	}
+
+	reader.remaining = b.N
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	// Process only returns once the reader is drained
+	if err := processor.Process(context.Background()); !strings.Contains(err.Error(), errDrained.Error()) {
+		b.Fatal(err)
+	}
+}
+
+func TestQueueProcessorRequeue(t *testing.T) {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-1" }}
+
+	msg := &sqs.Message{
+		MessageId:     aws.String("sqs-1"),
+		Body:          aws.String(benchmarkDirective),
+		ReceiptHandle: aws.String("receipt"),
+	}
+	// the message is redelivered once as it isn't deleted after the first failure
+	reader := &benchmarkReader{remaining: 2, batch: []*sqs.Message{msg}}
+
+	var handled, sent, failed int
+	processor := &QueueProcessor{
+		SQS:      reader,
+		QueueURL: "https://sqs.us-west-2.amazonaws.com/123456789012/directives.fifo",
+		Handler: &deferred.Handler{
+			RequestHandler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+				handled++
+				return rb.BasicResponse(req), nil
+			}),
+			EventSender: deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+				sent++
+				if sent == 1 {
+					return deferred.NewSendError(errors.New("gateway unavailable"), true)
+				}
+				return nil
+			}),
+		},
+		RequeuePolicy: deferred.DefaultRequeuePolicy,
+		OnError: func(ctx context.Context, req *alexa.Request, err error) {
+			failed++
+		},
+	}
+
+	if err := processor.Process(context.Background()); !strings.Contains(err.Error(), errDrained.Error()) {
+		t.Fatal(err)
+	}
+	if handled != 1 || sent != 2 || failed != 1 {
+		t.Errorf("expected request handled once and sent twice, got handled=%d sent=%d failed=%d", handled, sent, failed)
+	}
+}
+
+func TestQueueProcessorResendPanic(t *testing.T) {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-1" }}
+
+	msg := &sqs.Message{
+		MessageId:     aws.String("sqs-1"),
+		Body:          aws.String(benchmarkDirective),
+		ReceiptHandle: aws.String("receipt"),
+	}
+	reader := &benchmarkReader{remaining: 2, batch: []*sqs.Message{msg}}
+
+	var sent int
+	var reported []error
+	processor := &QueueProcessor{
+		SQS:      reader,
+		QueueURL: "https://sqs.us-west-2.amazonaws.com/123456789012/directives.fifo",
+		Handler: &deferred.Handler{
+			RequestHandler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+				return rb.BasicResponse(req), nil
+			}),
+			EventSender: deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+				sent++
+				if sent == 1 {
+					return deferred.NewSendError(errors.New("gateway unavailable"), true)
+				}
+				panic("sender bug")
+			}),
+			ErrorReporter: alexa.ErrorReporterFunc(func(ctx context.Context, req *alexa.Request, err error) {
+				reported = append(reported, err)
+			}),
+		},
+		RequeuePolicy: deferred.DefaultRequeuePolicy,
+	}
+
+	if err := processor.Process(context.Background()); !strings.Contains(err.Error(), errDrained.Error()) {
+		t.Fatal(err)
+	}
+
+	var panicErr *alexa.PanicError
+	if len(reported) != 2 || !errors.As(reported[1], &panicErr) {
+		t.Errorf("expected the resend panic to be recovered and reported, got %v", reported)
+	}
+	if processor.Pending.Len() != 0 {
+		t.Errorf("expected no pending events, got %d", processor.Pending.Len())
+	}
+}
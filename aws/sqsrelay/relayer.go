@@ -2,7 +2,6 @@ package sqsrelay
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -22,9 +21,10 @@ type RelayHandler struct {
 	QueueURL string
 }
 
-// Relay handles the alexa request by marshalling to json and sending it as a SQS message
+// Relay handles the alexa request by sending its json as a SQS message. The json
+// received from Alexa is relayed as is unless the request was modified or copied.
 func (r *RelayHandler) Relay(ctx context.Context, req *alexa.Request) error {
-	payload, err := json.Marshal(req)
+	payload, err := req.JSON()
 	if err != nil {
 		return fmt.Errorf("sqsrelay: failed to marshal request: %v", err)
 	}
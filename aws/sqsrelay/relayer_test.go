@@ -0,0 +1,51 @@
+package sqsrelay
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+type recordingSender struct {
+	bodies []string
+}
+
+func (r *recordingSender) SendMessageWithContext(_ aws.Context, input *sqs.SendMessageInput, _ ...request.Option) (*sqs.SendMessageOutput, error) {
+	r.bodies = append(r.bodies, aws.StringValue(input.MessageBody))
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func TestRelayHandlerRelay(t *testing.T) {
+	var req alexa.Request
+	if err := json.Unmarshal([]byte(benchmarkDirective), &req); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	sender := &recordingSender{}
+	relay := &RelayHandler{SQS: sender, QueueURL: "queue"}
+
+	member := req.Clone()
+	member.Directive.Endpoint.EndpointID = "member-1"
+
+	for _, r := range []*alexa.Request{&req, member} {
+		if err := relay.Relay(context.Background(), r); err != nil {
+			t.Fatalf("failed to relay: %v", err)
+		}
+	}
+
+	if sender.bodies[0] != benchmarkDirective {
+		t.Errorf("expected the received json to be relayed as is: %s", sender.bodies[0])
+	}
+	var relayed alexa.Request
+	if err := json.Unmarshal([]byte(sender.bodies[1]), &relayed); err != nil {
+		t.Fatalf("failed to unmarshal relayed member: %v", err)
+	}
+	if relayed.EndpointID() != "member-1" {
+		t.Errorf("expected the member directive to be relayed, got endpoint %s", relayed.EndpointID())
+	}
+}
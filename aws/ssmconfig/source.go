@@ -0,0 +1,34 @@
+package ssmconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// ParameterSource is a registry.Source loading an endpoint config from an SSM
+// Parameter Store parameter. SecureString parameters are decrypted.
+type ParameterSource struct {
+	SSM  ssmiface.SSMAPI
+	Name string
+}
+
+// Load reads the parameter's value
+func (p *ParameterSource) Load(ctx context.Context) ([]byte, error) {
+	resp, err := p.SSM.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           &p.Name,
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parameter %s: %v", p.Name, err)
+	}
+	if resp.Parameter == nil || resp.Parameter.Value == nil {
+		return nil, errors.New("parameter has no value")
+	}
+
+	return []byte(*resp.Parameter.Value), nil
+}
@@ -0,0 +1,139 @@
+package servicebusrelay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+// ReceivedMessage is a message received from a Service Bus queue or subscription
+type ReceivedMessage struct {
+	Body      []byte
+	MessageID string
+	// LockToken identifies the receiver's lock on the message when settling it
+	LockToken string
+	// Source optionally holds the message as received by the adapter, e.g. the
+	// *azservicebus.ReceivedMessage needed to settle it
+	Source interface{}
+}
+
+// MessageReceiver is the subset of a Service Bus receiver used by Processor. See the
+// package docs for adapting an azservicebus.Receiver in peek lock mode.
+type MessageReceiver interface {
+	ReceiveMessages(ctx context.Context, maxMessages int) ([]*ReceivedMessage, error)
+	// CompleteMessage removes the message from the queue or subscription
+	CompleteMessage(ctx context.Context, msg *ReceivedMessage) error
+	// AbandonMessage releases the lock on the message so it's delivered again
+	AbandonMessage(ctx context.Context, msg *ReceivedMessage) error
+	// DeadLetterMessage moves the message to the dead-letter queue with reason
+	DeadLetterMessage(ctx context.Context, msg *ReceivedMessage, reason string) error
+}
+
+// Processor reads and handles Service Bus messages produced by RelayHandler from a
+// queue or a topic subscription
+type Processor struct {
+	Receiver MessageReceiver
+	Handler  *deferred.Handler
+	// MaxMessages is the most messages received at once. Defaults to 10.
+	MaxMessages int
+	// OnReceive is optionally called after each successful receive, including ones that
+	// return no messages. It can be used to track connectivity.
+	OnReceive func()
+	// RequeuePolicy decides what happens to a message that fails to be handled. Dropped
+	// messages are completed and others are abandoned to be redelivered. When the policy
+	// is RetrySend the unsent events are kept in Pending and sent again on redelivery
+	// instead of handling the request again. If nil, Process returns the error, leaving
+	// the message to be redelivered once its lock expires.
+	RequeuePolicy deferred.RequeuePolicy
+	// OnError is optionally called with handling errors resolved by the RequeuePolicy and
+	// with a nil request for messages dead-lettered because they aren't valid requests
+	OnError func(ctx context.Context, req *alexa.Request, err error)
+	// Pending holds unsent events until their message is redelivered. They're kept in
+	// process so RetrySend requires a single Processor per queue or subscription.
+	Pending deferred.PendingEvents
+}
+
+// Process reads and handles Service Bus messages until an error occurs
+func (p *Processor) Process(ctx context.Context) error {
+	for {
+		msgs, err := p.Receiver.ReceiveMessages(ctx, p.maxMessages())
+		if err != nil {
+			return fmt.Errorf("failed to read from service bus: %v", err)
+		}
+		if p.OnReceive != nil {
+			p.OnReceive()
+		}
+
+		for _, msg := range msgs {
+			var homeReq alexa.Request
+			if err := json.Unmarshal(msg.Body, &homeReq); err != nil {
+				// redelivering won't help so keep the message aside rather than failing
+				if err := p.Receiver.DeadLetterMessage(ctx, msg, "invalid request"); err != nil {
+					return fmt.Errorf("failed to dead-letter message: %v", err)
+				}
+				if p.OnError != nil {
+					p.OnError(ctx, nil, fmt.Errorf("failed to read message %s: %v", msg.MessageID, err))
+				}
+				continue
+			}
+
+			if err := p.handle(ctx, msg.MessageID, &homeReq); err != nil {
+				if p.RequeuePolicy == nil {
+					return fmt.Errorf("failed to handle request: %v", err)
+				}
+				if p.OnError != nil {
+					p.OnError(ctx, &homeReq, err)
+				}
+				if p.requeue(msg.MessageID, err) {
+					if err := p.Receiver.AbandonMessage(ctx, msg); err != nil {
+						return fmt.Errorf("failed to abandon message: %v", err)
+					}
+					continue
+				}
+			}
+
+			if err := p.Receiver.CompleteMessage(ctx, msg); err != nil {
+				return fmt.Errorf("failed to complete message: %v", err)
+			}
+		}
+	}
+}
+
+// handle sends any events left unsent by a previous delivery of the message or
+// otherwise handles the request
+func (p *Processor) handle(ctx context.Context, messageID string, req *alexa.Request) error {
+	if events, ok := p.Pending.Take(messageID); ok {
+		return p.Handler.Resend(ctx, req, events)
+	}
+	return p.Handler.HandleRequest(ctx, req)
+}
+
+// requeue applies the RequeuePolicy to err and reports if the message should be
+// delivered again
+func (p *Processor) requeue(messageID string, err error) bool {
+	switch p.RequeuePolicy(err) {
+	case deferred.RetrySend:
+		var unsent *deferred.UnsentEventsError
+		if !errors.As(err, &unsent) {
+			// nothing to resend so the request must be handled again
+			return true
+		}
+		p.Pending.Put(messageID, unsent.Events)
+		return true
+	case deferred.RetryRequest:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Processor) maxMessages() int {
+	if p.MaxMessages <= 0 {
+		return 10
+	}
+	return p.MaxMessages
+}
@@ -0,0 +1,140 @@
+package servicebusrelay
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+const testDirective = `{"directive":{"header":{"namespace":"Alexa.PowerController","name":"TurnOn",` +
+	`"messageId":"1bd5d003-31b9-476f-ad03-71d471922820","correlationToken":"token","payloadVersion":"3"},` +
+	`"endpoint":{"scope":{"type":"BearerToken","token":"access-token"},"endpointId":"switch-1"},"payload":{}}}`
+
+var errDrained = errors.New("drained")
+
+// testReceiver redelivers abandoned messages until remaining deliveries have been made
+type testReceiver struct {
+	remaining    int
+	queue        []*ReceivedMessage
+	completed    int
+	abandoned    int
+	deadLettered []string
+}
+
+func (r *testReceiver) ReceiveMessages(ctx context.Context, maxMessages int) ([]*ReceivedMessage, error) {
+	if r.remaining <= 0 || len(r.queue) == 0 {
+		return nil, errDrained
+	}
+	r.remaining--
+	msgs := r.queue
+	r.queue = nil
+	return msgs, nil
+}
+
+func (r *testReceiver) CompleteMessage(ctx context.Context, msg *ReceivedMessage) error {
+	r.completed++
+	return nil
+}
+
+func (r *testReceiver) AbandonMessage(ctx context.Context, msg *ReceivedMessage) error {
+	r.abandoned++
+	r.queue = append(r.queue, msg)
+	return nil
+}
+
+func (r *testReceiver) DeadLetterMessage(ctx context.Context, msg *ReceivedMessage, reason string) error {
+	r.deadLettered = append(r.deadLettered, msg.MessageID)
+	return nil
+}
+
+func TestProcessorRequeue(t *testing.T) {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-1" }}
+
+	receiver := &testReceiver{
+		remaining: 2,
+		queue:     []*ReceivedMessage{{Body: []byte(testDirective), MessageID: "sb-1", LockToken: "lock"}},
+	}
+
+	var handled, sent int
+	processor := &Processor{
+		Receiver: receiver,
+		Handler: &deferred.Handler{
+			RequestHandler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+				handled++
+				return rb.BasicResponse(req), nil
+			}),
+			EventSender: deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+				sent++
+				if sent == 1 {
+					return deferred.NewSendError(errors.New("gateway unavailable"), true)
+				}
+				return nil
+			}),
+		},
+		RequeuePolicy: deferred.DefaultRequeuePolicy,
+	}
+
+	if err := processor.Process(context.Background()); !strings.Contains(err.Error(), errDrained.Error()) {
+		t.Fatal(err)
+	}
+	if handled != 1 || sent != 2 {
+		t.Errorf("expected request handled once and sent twice, got handled=%d sent=%d", handled, sent)
+	}
+	if receiver.abandoned != 1 || receiver.completed != 1 {
+		t.Errorf("expected message abandoned then completed, got abandoned=%d completed=%d",
+			receiver.abandoned, receiver.completed)
+	}
+	if processor.Pending.Len() != 0 {
+		t.Errorf("expected pending events to be taken on redelivery, got %d", processor.Pending.Len())
+	}
+}
+
+func TestProcessorInvalidMessage(t *testing.T) {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-1" }}
+
+	receiver := &testReceiver{
+		remaining: 1,
+		queue: []*ReceivedMessage{
+			{Body: []byte("not json"), MessageID: "sb-1"},
+			{Body: []byte(testDirective), MessageID: "sb-2"},
+		},
+	}
+
+	var handled int
+	var reported []error
+	processor := &Processor{
+		Receiver: receiver,
+		Handler: &deferred.Handler{
+			RequestHandler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+				handled++
+				return rb.BasicResponse(req), nil
+			}),
+			EventSender: deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+				return nil
+			}),
+		},
+		OnError: func(ctx context.Context, req *alexa.Request, err error) {
+			if req != nil {
+				t.Errorf("expected no request, got %+v", req)
+			}
+			reported = append(reported, err)
+		},
+	}
+
+	if err := processor.Process(context.Background()); !strings.Contains(err.Error(), errDrained.Error()) {
+		t.Fatal(err)
+	}
+	if len(receiver.deadLettered) != 1 || receiver.deadLettered[0] != "sb-1" {
+		t.Errorf("expected invalid message to be dead-lettered, got %v", receiver.deadLettered)
+	}
+	if len(reported) != 1 || !strings.Contains(reported[0].Error(), "sb-1") {
+		t.Errorf("expected invalid message to be reported, got %v", reported)
+	}
+	if handled != 1 || receiver.completed != 1 {
+		t.Errorf("expected following message to be handled, got handled=%d completed=%d", handled, receiver.completed)
+	}
+}
@@ -0,0 +1,71 @@
+// Package servicebusrelay relays directives through Azure Service Bus queues and topics
+// for backends that run in Azure. It doesn't depend on the Azure SDK so the module stays
+// free of it. Instead MessageSender and MessageReceiver are small interfaces satisfied
+// by adapters over azservicebus written by the application, e.g.
+//
+//	type sender struct{ *azservicebus.Sender }
+//
+//	func (s sender) SendMessage(ctx context.Context, msg *servicebusrelay.Message) error {
+//		return s.Sender.SendMessage(ctx, &azservicebus.Message{
+//			Body:      msg.Body,
+//			MessageID: &msg.MessageID,
+//			SessionID: &msg.SessionID,
+//			Subject:   &msg.Subject,
+//		}, nil)
+//	}
+//
+// The receiver adapter maps azservicebus.ReceivedMessage to ReceivedMessage, keeping
+// the original message to complete, abandon or dead-letter it.
+package servicebusrelay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// Message is a message sent to a Service Bus queue or topic
+type Message struct {
+	Body []byte
+	// MessageID enables duplicate detection on queues and topics that have it enabled
+	MessageID string
+	// SessionID orders messages on session enabled queues and subscriptions
+	SessionID string
+	Subject   string
+}
+
+// MessageSender is the subset of a Service Bus sender used by RelayHandler. See the
+// package docs for adapting an azservicebus.Sender for a queue or topic.
+type MessageSender interface {
+	SendMessage(ctx context.Context, msg *Message) error
+}
+
+// RelayHandler publishes the request to a Service Bus queue or topic
+type RelayHandler struct {
+	Sender MessageSender
+	// SessionID optionally sets the session of each message so directives are handled
+	// in order by a session enabled subscriber
+	SessionID string
+}
+
+// Relay handles the alexa request by sending its json as a Service Bus message. The
+// json received from Alexa is relayed as is unless the request was modified or copied.
+func (r *RelayHandler) Relay(ctx context.Context, req *alexa.Request) error {
+	payload, err := req.JSON()
+	if err != nil {
+		return fmt.Errorf("servicebusrelay: failed to marshal request: %v", err)
+	}
+
+	msg := Message{
+		Body:      payload,
+		MessageID: req.Directive.Header.MessageID,
+		SessionID: r.SessionID,
+		Subject:   "alexa.HandleRequest",
+	}
+	if err := r.Sender.SendMessage(ctx, &msg); err != nil {
+		return fmt.Errorf("servicebusrelay: failed to send request to service bus: %v", err)
+	}
+
+	return nil
+}
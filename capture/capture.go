@@ -0,0 +1,120 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// Sink stores captured directives
+type Sink interface {
+	Write(ctx context.Context, key string, data []byte) error
+}
+
+// Redacted replaces tokens and grant codes in captured directives
+const Redacted = "REDACTED"
+
+// Handler tees each directive to sink before passing it to handler. Bearer tokens and
+// grant codes are redacted. Each directive is stored as its own json document so it
+// can be replayed as is, e.g. by posting it to cmd/devserver.
+// Failures to capture are logged and don't affect handling of the directive.
+func Handler(sink Sink, handler alexa.Handler) alexa.HandlerFunc {
+	return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		if err := capture(ctx, sink, req, time.Now()); err != nil {
+			log.Printf("capture: failed to capture directive %s: %v", req.Directive.Header.MessageID, err)
+		}
+		return handler.HandleRequest(ctx, req)
+	}
+}
+
+func capture(ctx context.Context, sink Sink, req *alexa.Request, now time.Time) error {
+	redacted, err := Redact(req)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(redacted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal directive: %v", err)
+	}
+
+	return sink.Write(ctx, Key(req, now), data)
+}
+
+// Key names a captured directive so captures sort by the time they were received
+func Key(req *alexa.Request, received time.Time) string {
+	header := req.Directive.Header
+	return fmt.Sprintf("%s/%s-%s.%s-%s.json",
+		received.UTC().Format("2006/01/02"),
+		received.UTC().Format("150405.000"),
+		header.Namespace, header.Name, header.MessageID)
+}
+
+// Redact returns a copy of req with bearer tokens and grant codes replaced by Redacted
+func Redact(req *alexa.Request) (*alexa.Request, error) {
+	redacted := req.Clone()
+	if redacted.Directive.Endpoint.Scope.Token != "" {
+		redacted.Directive.Endpoint.Scope.Token = Redacted
+	}
+
+	if len(req.Directive.Payload) > 0 {
+		var payload interface{}
+		if err := json.Unmarshal(req.Directive.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal payload: %v", err)
+		}
+		redactValue(payload)
+		payloadJSON, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal payload: %v", err)
+		}
+		redacted.SetPayload(payloadJSON)
+	}
+
+	return redacted, nil
+}
+
+// redactValue replaces any token, and the code of a grant, in a decoded json value
+func redactValue(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if _, ok := child.(string); ok && key == "token" {
+				v[key] = Redacted
+				continue
+			}
+			if grant, ok := child.(map[string]interface{}); ok && key == "grant" {
+				if _, ok := grant["code"]; ok {
+					grant["code"] = Redacted
+				}
+			}
+			redactValue(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			redactValue(child)
+		}
+	}
+}
+
+// DirSink writes captured directives to files under Dir
+type DirSink struct {
+	Dir string
+}
+
+func (d *DirSink) Write(ctx context.Context, key string, data []byte) error {
+	path := filepath.Join(d.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create capture dir: %v", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write capture: %v", err)
+	}
+	return nil
+}
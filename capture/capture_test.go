@@ -0,0 +1,57 @@
+package capture
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func TestRedact(t *testing.T) {
+	var req alexa.Request
+	err := json.Unmarshal([]byte(`{
+		"directive": {
+			"header": {"namespace": "Alexa.Authorization", "name": "AcceptGrant", "messageId": "1", "payloadVersion": "3"},
+			"payload": {
+				"grant": {"type": "OAuth2.AuthorizationCode", "code": "secret-code"},
+				"grantee": {"type": "BearerToken", "token": "secret-token"}
+			}
+		}
+	}`), &req)
+	if err != nil {
+		t.Fatalf("failed to unmarshal request: %v", err)
+	}
+	req.Directive.Endpoint.Scope = alexa.Scope{Type: "BearerToken", Token: "endpoint-token"}
+
+	redacted, err := Redact(&req)
+	if err != nil {
+		t.Fatalf("failed to redact: %v", err)
+	}
+
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	directiveJSON, err := redacted.JSON()
+	if err != nil {
+		t.Fatalf("failed to get json: %v", err)
+	}
+	for _, secret := range []string{"secret-code", "secret-token", "endpoint-token"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("expected %s to be redacted: %s", secret, data)
+		}
+		if strings.Contains(string(directiveJSON), secret) {
+			t.Errorf("expected %s to be redacted from JSON(): %s", secret, directiveJSON)
+		}
+	}
+	if redacted.Raw() != nil {
+		t.Errorf("expected redacted request to have no raw json: %s", redacted.Raw())
+	}
+	if !strings.Contains(string(data), "OAuth2.AuthorizationCode") {
+		t.Errorf("expected grant type to be kept: %s", data)
+	}
+	if req.Directive.Endpoint.Scope.Token != "endpoint-token" {
+		t.Errorf("expected original request to be unchanged")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/discovery"
+)
+
+// Compares two discovery responses, e.g. captured before and after a deploy, and lists
+// added, removed and changed endpoints. Each file may hold a Discover.Response event,
+// discovery payload or array of endpoints.
+//
+// Exits with status 1 if endpoints or capabilities were removed since Alexa deletes
+// any routines or groups using them.
+//
+// Usage:
+//
+//	alexa-diff before.json after.json
+func main() {
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "usage: alexa-diff before.json after.json\n")
+		os.Exit(2)
+	}
+
+	before, err := readEndpoints(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	after, err := readEndpoints(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	diff := discovery.Compare(before, after)
+	if diff.Empty() {
+		fmt.Println("no changes")
+		return
+	}
+
+	fmt.Print(diff)
+
+	if diff.Breaking() {
+		fmt.Fprintf(os.Stderr, "\nendpoints or capabilities were removed\n")
+		os.Exit(1)
+	}
+}
+
+func readEndpoints(path string) ([]alexa.DiscoverEndpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	endpoints, err := discovery.ParseEndpoints(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mctofu/alexa-smart-home/infra"
+)
+
+// Generates SAM templates for the AWS resources described by a skill config. A
+// template named template-<region>.json is written for each region. The templates
+// can also be used from CDK with CfnInclude.
+//
+// Example config:
+//
+//	{
+//	  "name": "myskill",
+//	  "skillId": "amzn1.ask.skill.xxxx",
+//	  "regions": ["us-east-1", "eu-west-1"],
+//	  "tokenStore": "dynamodb",
+//	  "queue": {"maxReceiveCount": 5}
+//	}
+func main() {
+	configPath := flag.String("config", "skill.json", "skill config file")
+	outDir := flag.String("out", ".", "directory to write templates to")
+	flag.Parse()
+
+	data, err := ioutil.ReadFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read config: %v\n", err)
+		os.Exit(2)
+	}
+
+	cfg, err := infra.LoadConfig(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	templates, err := infra.Templates(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		os.Exit(2)
+	}
+
+	regions := make([]string, 0, len(templates))
+	for region := range templates {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	for _, region := range regions {
+		templateJSON, err := json.MarshalIndent(templates[region], "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to marshal template: %v\n", err)
+			os.Exit(1)
+		}
+
+		path := filepath.Join(*outDir, fmt.Sprintf("template-%s.json", region))
+		if err := ioutil.WriteFile(path, append(templateJSON, '\n'), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write template: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(path)
+	}
+}
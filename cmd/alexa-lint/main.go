@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/mctofu/alexa-smart-home/discovery"
+)
+
+// Lints discovery json for spec violations and likely certification failures.
+// Reads a Discover.Response event, discovery payload or array of endpoints from the
+// named file or stdin. Exits with status 1 if any errors are found.
+//
+// Use discovery.LintHandler to lint a handler's response directly, e.g. from a test.
+//
+// Usage:
+//
+//	alexa-lint discovery.json
+//	alexa-lint -warnings=false < discovery.json
+func main() {
+	warnings := flag.Bool("warnings", true, "report certification warnings as well as errors")
+	flag.Parse()
+
+	data, err := readInput(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	problems, err := discovery.LintResponse(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	for _, problem := range problems {
+		if problem.Severity == discovery.SeverityWarning && !*warnings {
+			continue
+		}
+		fmt.Println(problem)
+	}
+
+	if discovery.HasErrors(problems) {
+		os.Exit(1)
+	}
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %v", err)
+		}
+		return data, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Generates a skill project wired to this package's apis: a lambda handling discovery
+// and account linking, a device package implementing the chosen controllers and,
+// if directives are relayed, an agent that handles them remotely.
+//
+// Usage:
+//
+//	alexa-new -module github.com/me/myskill -controllers power,percentage -relay sqs myskill
+func main() {
+	var cfg config
+	var controllers string
+	flag.StringVar(&cfg.Module, "module", "", "go module path of the new project")
+	flag.StringVar(&cfg.Name, "name", "My Device", "friendly name of the generated endpoint")
+	flag.StringVar(&controllers, "controllers", "power", "comma separated controllers to implement: power, percentage, temperature")
+	flag.StringVar(&cfg.Tokens, "tokens", tokensS3, "token store: s3 or none")
+	flag.StringVar(&cfg.Relay, "relay", relaySQS, "how directives reach the device: sqs, mqtt (AWS IoT Core) or none to handle them in the lambda")
+	force := flag.Bool("force", false, "overwrite existing files")
+	flag.Parse()
+
+	dir := flag.Arg(0)
+	if dir == "" {
+		fmt.Fprintf(os.Stderr, "usage: alexa-new [flags] directory\n\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if cfg.Module == "" {
+		cfg.Module = filepath.Base(dir)
+	}
+
+	if err := cfg.setControllers(controllers); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	files, err := generate(&cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if err := write(dir, files, *force); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Created %s. Next run:\n  cd %s && go mod tidy && go build ./...\n", dir, dir)
+}
+
+// tokens enums
+const (
+	tokensS3   = "s3"
+	tokensNone = "none"
+)
+
+// relay enums
+const (
+	relaySQS  = "sqs"
+	relayMQTT = "mqtt"
+	relayNone = "none"
+)
+
+type config struct {
+	Module string
+	Name   string
+	Tokens string
+	Relay  string
+
+	Power       bool
+	Percentage  bool
+	Temperature bool
+}
+
+func (c *config) setControllers(controllers string) error {
+	for _, controller := range strings.Split(controllers, ",") {
+		switch strings.TrimSpace(controller) {
+		case "power":
+			c.Power = true
+		case "percentage":
+			c.Percentage = true
+		case "temperature":
+			c.Temperature = true
+		default:
+			return fmt.Errorf("unknown controller: %s", controller)
+		}
+	}
+	return nil
+}
+
+func (c *config) validate() error {
+	switch c.Tokens {
+	case tokensS3, tokensNone:
+	default:
+		return fmt.Errorf("unknown token store: %s", c.Tokens)
+	}
+
+	switch c.Relay {
+	case relaySQS, relayMQTT:
+		if c.Tokens == tokensNone {
+			return errors.New("a token store is required to send deferred responses from the agent")
+		}
+	case relayNone:
+	default:
+		return fmt.Errorf("unknown relay: %s", c.Relay)
+	}
+
+	return nil
+}
+
+// Relayed reports whether directives are handled by an agent
+func (c *config) Relayed() bool {
+	return c.Relay != relayNone
+}
+
+// generate renders the project's files keyed by their path
+func generate(cfg *config) (map[string][]byte, error) {
+	files := map[string]string{
+		"go.mod":           goModTemplate,
+		"README.md":        readmeTemplate,
+		"device/device.go": deviceTemplate,
+		"lambda/main.go":   lambdaTemplate,
+	}
+	switch cfg.Relay {
+	case relaySQS:
+		files["agent/main.go"] = sqsAgentTemplate
+	case relayMQTT:
+		files["lambda/mqtt.go"] = mqttRelayTemplate
+		files["agent/main.go"] = mqttAgentTemplate
+	}
+
+	rendered := make(map[string][]byte)
+	for path, text := range files {
+		tmpl, err := template.New(path).Delims("{%", "%}").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %v", path, err)
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, cfg); err != nil {
+			return nil, fmt.Errorf("failed to render %s: %v", path, err)
+		}
+
+		content := buf.Bytes()
+		if strings.HasSuffix(path, ".go") {
+			content, err = format.Source(content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to format %s: %v\n%s", path, err, buf.Bytes())
+			}
+		}
+		rendered[path] = content
+	}
+
+	return rendered, nil
+}
+
+func write(dir string, files map[string][]byte, force bool) error {
+	if !force {
+		for path := range files {
+			if _, err := os.Stat(filepath.Join(dir, path)); err == nil {
+				return fmt.Errorf("%s already exists, use -force to overwrite", filepath.Join(dir, path))
+			}
+		}
+	}
+
+	for path, content := range files {
+		target := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %v", err)
+		}
+		if err := ioutil.WriteFile(target, content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", target, err)
+		}
+	}
+
+	return nil
+}
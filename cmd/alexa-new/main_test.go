@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, relay := range []string{relaySQS, relayMQTT, relayNone} {
+		for _, tokens := range []string{tokensS3, tokensNone} {
+			for _, controllers := range []string{"power", "percentage,temperature", "temperature"} {
+				cfg := config{Module: "example.com/skill", Name: "Test", Relay: relay, Tokens: tokens}
+				if err := cfg.setControllers(controllers); err != nil {
+					t.Fatalf("failed to set controllers: %v", err)
+				}
+				if err := cfg.validate(); err != nil {
+					if tokens != tokensNone {
+						t.Errorf("unexpected validation error: %v", err)
+					}
+					continue
+				}
+
+				files, err := generate(&cfg)
+				if err != nil {
+					t.Fatalf("%s/%s/%s: %v", relay, tokens, controllers, err)
+				}
+				if _, ok := files["agent/main.go"]; ok != cfg.Relayed() {
+					t.Errorf("%s: expected agent only when relayed", relay)
+				}
+				if !strings.Contains(string(files["lambda/main.go"]), `"example.com/skill/device"`) {
+					t.Errorf("expected lambda to import device package")
+				}
+			}
+		}
+	}
+}
+
+// TestGeneratedProjectBuilds builds and vets each generated project against this checkout.
+// The go.mod is extended with this module's requirements so only the generated project's
+// own dependencies need to be fetched.
+func TestGeneratedProjectBuilds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go build of generated projects in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatalf("failed to find module root: %v", err)
+	}
+	rootMod, err := ioutil.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		t.Fatalf("failed to read go.mod: %v", err)
+	}
+	rootSum, err := ioutil.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatalf("failed to read go.sum: %v", err)
+	}
+	requires := regexp.MustCompile(`(?s)\nrequire .*`).Find(rootMod)
+
+	for _, relay := range []string{relaySQS, relayMQTT, relayNone} {
+		for _, tokens := range []string{tokensS3, tokensNone} {
+			cfg := config{Module: "example.com/skill", Name: "Test", Relay: relay, Tokens: tokens}
+			if err := cfg.setControllers("power,percentage,temperature"); err != nil {
+				t.Fatalf("failed to set controllers: %v", err)
+			}
+			if cfg.validate() != nil {
+				continue
+			}
+
+			t.Run(relay+"/"+tokens, func(t *testing.T) {
+				files, err := generate(&cfg)
+				if err != nil {
+					t.Fatalf("failed to generate: %v", err)
+				}
+				files["go.mod"] = append(files["go.mod"], requires...)
+				files["go.mod"] = append(files["go.mod"],
+					"\nrequire github.com/mctofu/alexa-smart-home v0.0.0\n"+
+						"\nreplace github.com/mctofu/alexa-smart-home => "+root+"\n"...)
+				files["go.sum"] = rootSum
+
+				dir := t.TempDir()
+				if err := write(dir, files, false); err != nil {
+					t.Fatalf("failed to write project: %v", err)
+				}
+
+				for _, args := range [][]string{{"build", "./..."}, {"vet", "./..."}} {
+					cmd := exec.Command(goBin, args...)
+					cmd.Dir = dir
+					cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+					if out, err := cmd.CombinedOutput(); err != nil {
+						t.Errorf("go %s failed: %v\n%s", strings.Join(args, " "), err, out)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,626 @@
+package main
+
+// Templates use {% %} delimiters so they don't collide with go composite literals
+
+const goModTemplate = `module {%.Module%}
+
+go 1.18
+{%- if eq .Relay "mqtt"%}
+
+require github.com/eclipse/paho.mqtt.golang v1.3.5
+{%- end%}
+`
+
+const readmeTemplate = `# {%.Name%} skill
+
+Generated by alexa-new. Run ` + "`go mod tidy`" + ` to fetch dependencies.
+
+- ` + "`device`" + ` implements the endpoint's controllers. Replace the logging with calls to your device.
+- ` + "`lambda`" + ` is the smart home skill lambda. It handles discovery and account linking{%if .Relayed%}
+  and relays the remaining directives to the agent{%else%} as well as the device's directives{%end%}.
+{%- if .Relayed%}
+- ` + "`agent`" + ` runs near the device, handles relayed directives and sends the responses to Alexa.
+{%- end%}
+
+## Configuration
+
+Both programs read their configuration from the environment:
+
+| Variable | Description |
+| --- | --- |
+| AUTH_CLIENT_ID | Alexa skill messaging client id |
+| AUTH_CLIENT_SECRET | Alexa skill messaging client secret |
+{%- if eq .Tokens "s3"%}
+| S3_TOKEN_BUCKET | bucket holding user tokens. Enable encryption and restrict access to it. |
+{%- end%}
+{%- if eq .Relay "sqs"%}
+| SQS_QUEUE_URL | FIFO queue directives are relayed through |
+{%- end%}
+{%- if eq .Relay "mqtt"%}
+| IOT_ENDPOINT | AWS IoT Core data endpoint used by the lambda |
+| MQTT_TOPIC | topic directives are relayed through |
+| MQTT_BROKER | broker url for the agent, e.g. ssl://xxxxx-ats.iot.us-east-1.amazonaws.com:8883 |
+| MQTT_CERT, MQTT_KEY, MQTT_CA | agent's IoT thing certificate, private key and the Amazon root CA |
+{%- end%}
+
+Use cmd/devserver and cmd/alexactl from github.com/mctofu/alexa-smart-home to try
+the device handlers locally.
+`
+
+const deviceTemplate = `package device
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+{%- if or .Power .Percentage%}
+	"log"
+{%- end%}
+	"sync"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// EndpointID identifies the device to Alexa
+const EndpointID = "device-1"
+
+// Endpoints returns the endpoints discovered by the skill
+func Endpoints() []alexa.DiscoverEndpoint {
+	return []alexa.DiscoverEndpoint{
+		{
+			EndpointID:        EndpointID,
+			FriendlyName:      "{%.Name%}",
+			Description:       "{%.Name%}",
+			ManufacturerName:  "{%.Name%}",
+			DisplayCategories: []string{ {%if .Temperature%}alexa.DisplayCategoryTemperatureSensor{%else%}alexa.DisplayCategorySwitch{%end%} },
+			Capabilities: []alexa.DiscoverCapability{
+				{
+					Type:      "AlexaInterface",
+					Interface: alexa.NamespaceAlexa,
+					Version:   "3",
+				},
+{%- if .Power%}
+				{
+					Type:      "AlexaInterface",
+					Interface: alexa.InterfacePowerController,
+					Version:   "3",
+					Properties: &alexa.DiscoverProperties{
+						Supported:   []alexa.DiscoverProperty{ {Name: "powerState"} },
+						Retrievable: true,
+					},
+				},
+{%- end%}
+{%- if .Percentage%}
+				{
+					Type:      "AlexaInterface",
+					Interface: alexa.InterfacePercentageController,
+					Version:   "3",
+					Properties: &alexa.DiscoverProperties{
+						Supported:   []alexa.DiscoverProperty{ {Name: "percentage"} },
+						Retrievable: true,
+					},
+				},
+{%- end%}
+{%- if .Temperature%}
+				{
+					Type:      "AlexaInterface",
+					Interface: alexa.InterfaceTemperatureSensor,
+					Version:   "3",
+					Properties: &alexa.DiscoverProperties{
+						Supported:   []alexa.DiscoverProperty{ {Name: "temperature"} },
+						Retrievable: true,
+					},
+				},
+{%- end%}
+			},
+		},
+	}
+}
+
+// Device handles directives for the endpoint. Replace the logging and in memory state
+// with calls to your device.
+type Device struct {
+	respBuilder *alexa.ResponseBuilder
+
+	mu sync.Mutex
+{%- if .Power%}
+	on bool
+{%- end%}
+{%- if .Percentage%}
+	percentage int
+{%- end%}
+}
+
+// New creates a Device
+func New(respBuilder *alexa.ResponseBuilder) *Device {
+	return &Device{respBuilder: respBuilder}
+}
+
+// Namespaces lists the namespaces Handler supports
+func (d *Device) Namespaces() []string {
+	return []string{
+		alexa.NamespaceAlexa,
+{%- if .Power%}
+		alexa.NamespacePowerController,
+{%- end%}
+{%- if .Percentage%}
+		alexa.NamespacePercentageController,
+{%- end%}
+	}
+}
+
+// Handler handles the device's directives
+func (d *Device) Handler() alexa.Handler {
+	mux := alexa.NewNamespaceMux()
+	mux.HandleReportState(alexa.HandlerFunc(d.ReportState))
+{%- if .Power%}
+	mux.Handle(alexa.NamespacePowerController,
+		alexa.PowerControllerHandler(
+			alexa.HandlerFunc(d.TurnOn),
+			alexa.HandlerFunc(d.TurnOff)))
+{%- end%}
+{%- if .Percentage%}
+	mux.Handle(alexa.NamespacePercentageController,
+		alexa.PercentageControllerHandler(
+			alexa.HandlerFunc(d.SetPercentage),
+			alexa.HandlerFunc(d.AdjustPercentage)))
+{%- end%}
+	return alexa.InvalidDirectiveHandler(d.respBuilder, mux)
+}
+
+// ReportState responds with the current value of every property
+func (d *Device) ReportState(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	props, err := d.properties()
+	if err != nil {
+		return nil, err
+	}
+	return d.respBuilder.StateReportResponse(req, props...), nil
+}
+{%- if .Power%}
+
+func (d *Device) TurnOn(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	log.Println("Turn on!")
+	return d.setPower(req, true)
+}
+
+func (d *Device) TurnOff(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	log.Println("Turn off!")
+	return d.setPower(req, false)
+}
+
+func (d *Device) setPower(req *alexa.Request, on bool) (*alexa.Response, error) {
+	d.mu.Lock()
+	d.on = on
+	d.mu.Unlock()
+
+	return d.response(req)
+}
+{%- end%}
+{%- if .Percentage%}
+
+func (d *Device) SetPercentage(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	var payload alexa.SetPercentagePayload
+	if err := json.Unmarshal(req.Directive.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("device.SetPercentage: invalid payload: %v", err)
+	}
+	log.Printf("SetPercentage: %d\n", payload.Percentage)
+
+	d.mu.Lock()
+	d.percentage = int(payload.Percentage)
+	d.mu.Unlock()
+
+	return d.response(req)
+}
+
+func (d *Device) AdjustPercentage(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	var payload alexa.AdjustPercentagePayload
+	if err := json.Unmarshal(req.Directive.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("device.AdjustPercentage: invalid payload: %v", err)
+	}
+	log.Printf("AdjustPercentage: %d\n", payload.PercentageDelta)
+
+	d.mu.Lock()
+	d.percentage += int(payload.PercentageDelta)
+	if d.percentage < 0 {
+		d.percentage = 0
+	}
+	if d.percentage > 100 {
+		d.percentage = 100
+	}
+	d.mu.Unlock()
+
+	return d.response(req)
+}
+{%- end%}
+
+func (d *Device) response(req *alexa.Request) (*alexa.Response, error) {
+	props, err := d.properties()
+	if err != nil {
+		return nil, err
+	}
+	return d.respBuilder.BasicResponse(req, props...), nil
+}
+
+func (d *Device) properties() ([]alexa.ContextProperty, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	var props []alexa.ContextProperty
+{%- if .Power%}
+
+	powerState := "OFF"
+	if d.on {
+		powerState = "ON"
+	}
+	powerJSON, err := json.Marshal(powerState)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal power state: %v", err)
+	}
+	props = append(props, alexa.ContextProperty{
+		Namespace:                 alexa.NamespacePowerController,
+		Name:                      "powerState",
+		Value:                     powerJSON,
+		TimeOfSample:              now,
+		UncertaintyInMilliseconds: 500,
+	})
+{%- end%}
+{%- if .Percentage%}
+
+	percentageJSON, err := json.Marshal(d.percentage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal percentage: %v", err)
+	}
+	props = append(props, alexa.ContextProperty{
+		Namespace:                 alexa.NamespacePercentageController,
+		Name:                      "percentage",
+		Value:                     percentageJSON,
+		TimeOfSample:              now,
+		UncertaintyInMilliseconds: 500,
+	})
+{%- end%}
+{%- if .Temperature%}
+
+	tempJSON, err := json.Marshal(alexa.TemperatureValue{
+		Value: 72,
+		Scale: alexa.TemperatureScaleFahrenheit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal temperature: %v", err)
+	}
+	props = append(props, alexa.ContextProperty{
+		Namespace:                 alexa.NamespaceTemperatureSensor,
+		Name:                      "temperature",
+		Value:                     tempJSON,
+		TimeOfSample:              now,
+		UncertaintyInMilliseconds: 60000,
+	})
+{%- end%}
+
+	return props, nil
+}
+`
+
+const lambdaTemplate = `package main
+
+import (
+{%- if eq .Tokens "none"%}
+	"context"
+{%- end%}
+{%- if or (eq .Tokens "s3") .Relayed%}
+	"log"
+{%- end%}
+	"os"
+
+	awslambda "github.com/aws/aws-lambda-go/lambda"
+{%- if or (eq .Tokens "s3") .Relayed%}
+	"github.com/aws/aws-sdk-go/aws/session"
+{%- end%}
+{%- if eq .Relay "mqtt"%}
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iotdataplane"
+{%- end%}
+{%- if eq .Tokens "s3"%}
+	"github.com/aws/aws-sdk-go/service/s3"
+{%- end%}
+{%- if eq .Relay "sqs"%}
+	"github.com/aws/aws-sdk-go/service/sqs"
+{%- end%}
+	"github.com/mctofu/alexa-smart-home/alexa"
+{%- if eq .Tokens "s3"%}
+	"github.com/mctofu/alexa-smart-home/aws/s3store"
+{%- end%}
+{%- if eq .Relay "sqs"%}
+	"github.com/mctofu/alexa-smart-home/aws/sqsrelay"
+{%- end%}
+	"github.com/mctofu/alexa-smart-home/lambda"
+
+	"{%.Module%}/device"
+)
+
+// Smart home skill lambda. Discovery and account linking are handled here.
+{%- if .Relayed%}
+// The device's directives are relayed to the agent which sends a deferred response.
+{%- end%}
+func main() {
+	authClientID := os.Getenv("AUTH_CLIENT_ID")
+	authClientSecret := os.Getenv("AUTH_CLIENT_SECRET")
+{%- if eq .Tokens "s3"%}
+	s3TokenBucket := os.Getenv("S3_TOKEN_BUCKET")
+{%- end%}
+{%- if eq .Relay "sqs"%}
+	sqsQueueURL := os.Getenv("SQS_QUEUE_URL")
+{%- end%}
+{%- if eq .Relay "mqtt"%}
+	iotEndpoint := os.Getenv("IOT_ENDPOINT")
+	mqttTopic := os.Getenv("MQTT_TOPIC")
+{%- end%}
+{%- if or (eq .Tokens "s3") .Relayed%}
+
+	session, err := session.NewSession()
+	if err != nil {
+		log.Fatalf("failed to init aws session: %v", err)
+	}
+{%- end%}
+
+	respBuilder := alexa.NewResponseBuilder()
+	dev := device.New(respBuilder)
+
+	mux := alexa.NewNamespaceMux()
+	mux.HandleFunc(alexa.NamespaceDiscovery, alexa.StaticDiscoveryHandler(respBuilder, device.Endpoints()...))
+{%- if eq .Tokens "s3"%}
+
+	tokenStorage := &s3store.TokenStorage{
+		S3:     s3.New(session),
+		Bucket: s3TokenBucket,
+	}
+	mux.HandleFunc(alexa.NamespaceAuthorization,
+		alexa.AuthorizationHandler(
+			authClientID,
+			authClientSecret,
+			&alexa.ProfileUserIDReader{HTTPDoer: alexa.DefaultHTTPClient},
+			tokenStorage,
+			respBuilder))
+{%- else%}
+
+	// without a token store the grant is accepted but the skill can't send events
+	_, _ = authClientID, authClientSecret
+	mux.HandleFunc(alexa.NamespaceAuthorization, func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		return respBuilder.AcceptGrantResponse(), nil
+	})
+{%- end%}
+{%- if eq .Relay "sqs"%}
+
+	relayer := &sqsrelay.RelayHandler{
+		SQS:      sqs.New(session),
+		QueueURL: sqsQueueURL,
+	}
+{%- end%}
+{%- if eq .Relay "mqtt"%}
+
+	relayer := &mqttRelay{
+		iot:   iotdataplane.New(session, aws.NewConfig().WithEndpoint(iotEndpoint)),
+		topic: mqttTopic,
+	}
+{%- end%}
+
+	for _, namespace := range dev.Namespaces() {
+{%- if .Relayed%}
+		mux.HandleFunc(namespace, alexa.DeferredRelayHandler(relayer, respBuilder))
+{%- else%}
+		mux.Handle(namespace, dev.Handler())
+{%- end%}
+	}
+
+	awslambda.Start(lambda.DebugRequestHandler(mux))
+}
+`
+
+const mqttRelayTemplate = `package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iotdataplane"
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// mqttRelay publishes directives to an AWS IoT Core topic the agent subscribes to
+type mqttRelay struct {
+	iot   *iotdataplane.IoTDataPlane
+	topic string
+}
+
+func (m *mqttRelay) Relay(ctx context.Context, req *alexa.Request) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("mqttRelay: failed to marshal request: %v", err)
+	}
+
+	_, err = m.iot.PublishWithContext(ctx, &iotdataplane.PublishInput{
+		Topic:   aws.String(m.topic),
+		Qos:     aws.Int64(1),
+		Payload: payload,
+	})
+	if err != nil {
+		return fmt.Errorf("mqttRelay: failed to publish request: %v", err)
+	}
+
+	return nil
+}
+`
+
+// agentSetup is shared by the agent templates
+const agentSetup = `
+	session, err := session.NewSession()
+	if err != nil {
+		log.Fatalf("failed to init aws session: %v", err)
+	}
+
+	tokenStorage := &s3store.TokenStorage{
+		S3:     s3.New(session),
+		Bucket: s3TokenBucket,
+	}
+
+	userIDReader := alexa.NewCachingUserIDReader(
+		&alexa.ProfileUserIDReader{HTTPDoer: alexa.DefaultHTTPClient},
+		time.Hour)
+
+	respBuilder := alexa.NewResponseBuilder()
+	dev := device.New(respBuilder)
+
+	deferredHandler := &deferred.Handler{
+		EventSender: &deferred.HTTPEventSender{
+			TokenStore:   tokenStorage,
+			UserIDReader: userIDReader,
+			Credentials: alexa.ClientCredentials{
+				ClientID:     authClientID,
+				ClientSecret: authClientSecret,
+			},
+		},
+		RequestHandler: alexa.DebugHandler(dev.Handler()),
+	}
+`
+
+const sqsAgentTemplate = `package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/mctofu/alexa-smart-home/agent"
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/aws/s3store"
+	"github.com/mctofu/alexa-smart-home/aws/sqsrelay"
+	"github.com/mctofu/alexa-smart-home/deferred"
+
+	"{%.Module%}/device"
+)
+
+// Listens on the SQS queue for directives relayed by the lambda and sends the
+// device's responses to Alexa
+func main() {
+	sqsQueueURL := os.Getenv("SQS_QUEUE_URL")
+	s3TokenBucket := os.Getenv("S3_TOKEN_BUCKET")
+	authClientID := os.Getenv("AUTH_CLIENT_ID")
+	authClientSecret := os.Getenv("AUTH_CLIENT_SECRET")
+` + agentSetup + `
+	reader := &sqsrelay.QueueProcessor{
+		SQS:                  sqs.New(session),
+		QueueURL:             sqsQueueURL,
+		Handler:              deferredHandler,
+		QueueWaitTimeSeconds: 20,
+	}
+
+	a := &agent.Agent{MaxBackoff: time.Duration(reader.QueueWaitTimeSeconds) * time.Second}
+	a.Add("sqs", agent.Loop(reader.Process))
+
+	if err := a.RunUntilSignal(context.Background()); err != nil {
+		log.Fatalf("failed to stop agent: %v", err)
+	}
+}
+`
+
+const mqttAgentTemplate = `package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/mctofu/alexa-smart-home/agent"
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/aws/s3store"
+	"github.com/mctofu/alexa-smart-home/deferred"
+
+	"{%.Module%}/device"
+)
+
+// Subscribes to the AWS IoT Core topic the lambda relays directives to and sends the
+// device's responses to Alexa
+func main() {
+	mqttBroker := os.Getenv("MQTT_BROKER")
+	mqttTopic := os.Getenv("MQTT_TOPIC")
+	s3TokenBucket := os.Getenv("S3_TOKEN_BUCKET")
+	authClientID := os.Getenv("AUTH_CLIENT_ID")
+	authClientSecret := os.Getenv("AUTH_CLIENT_SECRET")
+` + agentSetup + `
+	tlsConfig, err := iotTLSConfig(os.Getenv("MQTT_CERT"), os.Getenv("MQTT_KEY"), os.Getenv("MQTT_CA"))
+	if err != nil {
+		log.Fatalf("failed to load iot certificates: %v", err)
+	}
+
+	a := &agent.Agent{}
+	a.Add("mqtt", agent.ComponentFunc(func(ctx context.Context) error {
+		opts := mqtt.NewClientOptions().
+			AddBroker(mqttBroker).
+			SetClientID("{%.Module%}-agent").
+			SetTLSConfig(tlsConfig).
+			SetOnConnectHandler(func(client mqtt.Client) {
+				token := client.Subscribe(mqttTopic, 1, func(client mqtt.Client, msg mqtt.Message) {
+					var req alexa.Request
+					if err := json.Unmarshal(msg.Payload(), &req); err != nil {
+						log.Printf("Failed to unmarshal request: %v", err)
+						return
+					}
+					if err := deferredHandler.HandleRequest(ctx, &req); err != nil {
+						log.Printf("Failed to handle request: %v", err)
+					}
+				})
+				if token.Wait() && token.Error() != nil {
+					log.Printf("Failed to subscribe: %v", token.Error())
+				}
+			})
+
+		client := mqtt.NewClient(opts)
+		if token := client.Connect(); token.Wait() && token.Error() != nil {
+			return fmt.Errorf("failed to connect to broker: %v", token.Error())
+		}
+
+		<-ctx.Done()
+		client.Disconnect(uint(time.Second / time.Millisecond))
+		return nil
+	}))
+
+	if err := a.RunUntilSignal(context.Background()); err != nil {
+		log.Fatalf("failed to stop agent: %v", err)
+	}
+}
+
+func iotTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+	}, nil
+}
+`
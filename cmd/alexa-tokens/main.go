@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/aws/dynamostore"
+	"github.com/mctofu/alexa-smart-home/aws/s3store"
+	"golang.org/x/oauth2"
+)
+
+// Inspects the oauth tokens stored for account linked users. Tokens are never printed
+// in full.
+//
+// Usage:
+//
+//	alexa-tokens -s3-bucket my-tokens list
+//	alexa-tokens -dynamodb-table my-tokens show <user id>
+//	alexa-tokens -dir ./tokens check
+//	alexa-tokens -s3-bucket my-tokens delete <user id>
+func main() {
+	bucket := flag.String("s3-bucket", "", "S3 bucket holding tokens")
+	prefix := flag.String("s3-prefix", "", "key prefix of tokens in the S3 bucket")
+	table := flag.String("dynamodb-table", "", "DynamoDB table holding tokens")
+	dir := flag.String("dir", "", "directory holding token files")
+	yes := flag.Bool("yes", false, "delete without confirmation")
+	flag.Usage = usage
+	flag.Parse()
+
+	store, err := newStore(*bucket, *prefix, *table, *dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "list":
+		err = list(ctx, store, os.Stdout)
+	case "show":
+		if len(args) != 2 {
+			usage()
+			os.Exit(2)
+		}
+		err = show(ctx, store, os.Stdout, args[1])
+	case "check":
+		var ok bool
+		ok, err = check(ctx, store, os.Stdout, args[1:])
+		if err == nil && !ok {
+			os.Exit(1)
+		}
+	case "delete":
+		if len(args) != 2 {
+			usage()
+			os.Exit(2)
+		}
+		if !*yes && !confirm(fmt.Sprintf("delete tokens for %s?", args[1])) {
+			return
+		}
+		err = store.Delete(ctx, args[1])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: alexa-tokens (-s3-bucket name | -dynamodb-table name | -dir path) command\n\n")
+	fmt.Fprintf(os.Stderr, "commands:\n")
+	fmt.Fprintf(os.Stderr, "  list           list users with stored tokens\n")
+	fmt.Fprintf(os.Stderr, "  show <id>      show a user's tokens, redacted\n")
+	fmt.Fprintf(os.Stderr, "  check [id...]  verify tokens are usable. Checks all users if no ids are given.\n")
+	fmt.Fprintf(os.Stderr, "  delete <id>    delete a user's tokens\n\n")
+	fmt.Fprintf(os.Stderr, "flags:\n")
+	flag.PrintDefaults()
+}
+
+type tokenStore interface {
+	alexa.TokenReader
+	alexa.TokenDeleter
+	alexa.TokenLister
+}
+
+func newStore(bucket, prefix, table, dir string) (tokenStore, error) {
+	set := 0
+	for _, v := range []string{bucket, table, dir} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, errors.New("exactly one of -s3-bucket, -dynamodb-table or -dir is required")
+	}
+
+	if dir != "" {
+		return &alexa.FileTokenStore{Dir: dir}, nil
+	}
+
+	session, err := session.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to init aws session: %v", err)
+	}
+	if bucket != "" {
+		return &s3store.TokenStorage{S3: s3.New(session), Bucket: bucket, Prefix: prefix}, nil
+	}
+	return &dynamostore.TokenStorage{DynamoDB: dynamodb.New(session), Table: table}, nil
+}
+
+func list(ctx context.Context, store tokenStore, w io.Writer) error {
+	ids, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Strings(ids)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "USER\tSTATUS\tEXPIRY\n")
+	for _, id := range ids {
+		token, err := store.Read(ctx, id)
+		if err != nil {
+			fmt.Fprintf(tw, "%s\terror: %v\t\n", id, err)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", id, status(token), expiry(token))
+	}
+	return tw.Flush()
+}
+
+func show(ctx context.Context, store tokenStore, w io.Writer, id string) error {
+	token, err := store.Read(ctx, id)
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return fmt.Errorf("no tokens stored for %s", id)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "user\t%s\n", id)
+	fmt.Fprintf(tw, "token type\t%s\n", token.TokenType)
+	fmt.Fprintf(tw, "access token\t%s\n", redact(token.AccessToken))
+	fmt.Fprintf(tw, "refresh token\t%s\n", redact(token.RefreshToken))
+	fmt.Fprintf(tw, "expiry\t%s\n", expiry(token))
+	fmt.Fprintf(tw, "status\t%s\n", status(token))
+	return tw.Flush()
+}
+
+// check reports the status of each user's tokens and returns false if any are unusable
+func check(ctx context.Context, store tokenStore, w io.Writer, ids []string) (bool, error) {
+	if len(ids) == 0 {
+		var err error
+		ids, err = store.List(ctx)
+		if err != nil {
+			return false, err
+		}
+		sort.Strings(ids)
+	}
+
+	ok := true
+	for _, id := range ids {
+		token, err := store.Read(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		if !usable(token) {
+			ok = false
+		}
+		fmt.Fprintf(w, "%s: %s\n", id, status(token))
+	}
+	return ok, nil
+}
+
+func status(token *oauth2.Token) string {
+	switch {
+	case token == nil:
+		return "missing"
+	case token.AccessToken == "":
+		return "invalid, no access token"
+	case token.Valid():
+		return "valid"
+	case token.RefreshToken != "":
+		return "expired, refreshable"
+	}
+	return "expired"
+}
+
+// usable reports whether an event can be sent with the token, refreshing it if needed
+func usable(token *oauth2.Token) bool {
+	return token != nil && token.AccessToken != "" && (token.Valid() || token.RefreshToken != "")
+}
+
+func expiry(token *oauth2.Token) string {
+	if token == nil || token.Expiry.IsZero() {
+		return "none"
+	}
+	return fmt.Sprintf("%s (%s)", token.Expiry.Format(time.RFC3339), durationFromNow(token.Expiry))
+}
+
+func durationFromNow(t time.Time) string {
+	d := time.Until(t).Round(time.Second)
+	if d < 0 {
+		return fmt.Sprintf("%s ago", -d)
+	}
+	return fmt.Sprintf("in %s", d)
+}
+
+// redact shows just enough of a token to tell tokens apart
+func redact(token string) string {
+	if token == "" {
+		return "(none)"
+	}
+	if len(token) <= 12 {
+		return fmt.Sprintf("**** (%d chars)", len(token))
+	}
+	return fmt.Sprintf("%s****%s (%d chars)", token[:4], token[len(token)-4:], len(token))
+}
+
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type fakeStore struct {
+	tokens  map[string]*oauth2.Token
+	readErr map[string]error
+	listErr error
+}
+
+func (s *fakeStore) Read(ctx context.Context, id string) (*oauth2.Token, error) {
+	if err := s.readErr[id]; err != nil {
+		return nil, err
+	}
+	return s.tokens[id], nil
+}
+
+func (s *fakeStore) Delete(ctx context.Context, id string) error {
+	delete(s.tokens, id)
+	return nil
+}
+
+func (s *fakeStore) List(ctx context.Context) ([]string, error) {
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+	var ids []string
+	for id := range s.tokens {
+		ids = append(ids, id)
+	}
+	for id := range s.readErr {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func testStore() *fakeStore {
+	return &fakeStore{
+		tokens: map[string]*oauth2.Token{
+			"user-valid": {
+				AccessToken:  "access-token-0123456789",
+				RefreshToken: "refresh-token-0123456789",
+				TokenType:    "Bearer",
+				Expiry:       time.Now().Add(time.Hour),
+			},
+			"user-refreshable": {
+				AccessToken:  "access-token-0123456789",
+				RefreshToken: "refresh-token-0123456789",
+				Expiry:       time.Now().Add(-time.Hour),
+			},
+			"user-expired": {
+				AccessToken: "access-token-0123456789",
+				Expiry:      time.Now().Add(-time.Hour),
+			},
+		},
+	}
+}
+
+func TestList(t *testing.T) {
+	store := testStore()
+	store.readErr = map[string]error{"user-broken": errors.New("read failed")}
+
+	var out bytes.Buffer
+	if err := list(context.Background(), store, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	expected := []struct {
+		prefix string
+		status string
+	}{
+		{"USER", "STATUS"},
+		{"user-broken", "error: read failed"},
+		{"user-expired", "expired"},
+		{"user-refreshable", "expired, refreshable"},
+		{"user-valid", "valid"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines but got:\n%s", len(expected), out.String())
+	}
+	for i, e := range expected {
+		if !strings.HasPrefix(lines[i], e.prefix) || !strings.Contains(lines[i], e.status) {
+			t.Errorf("expected line %d to be %s with %q but got %q", i, e.prefix, e.status, lines[i])
+		}
+	}
+	if strings.Contains(out.String(), "access-token") {
+		t.Errorf("expected tokens not to be printed:\n%s", out.String())
+	}
+
+	store.listErr = errors.New("list failed")
+	if err := list(context.Background(), store, &out); err == nil {
+		t.Error("expected list error")
+	}
+}
+
+func TestShow(t *testing.T) {
+	var out bytes.Buffer
+	if err := show(context.Background(), testStore(), &out, "user-valid"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"user-valid",
+		"Bearer",
+		"acce****6789 (23 chars)",
+		"refr****6789 (24 chars)",
+		"valid",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected output to contain %q:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "access-token-0123456789") {
+		t.Errorf("expected access token to be redacted:\n%s", out.String())
+	}
+
+	if err := show(context.Background(), testStore(), &out, "user-missing"); err == nil {
+		t.Error("expected error showing a missing user")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := map[string]struct {
+		ids     []string
+		readErr bool
+		ok      bool
+		output  string
+		err     bool
+	}{
+		"all users": {
+			ok:     false,
+			output: "user-expired: expired\nuser-refreshable: expired, refreshable\nuser-valid: valid\n",
+		},
+		"usable users": {
+			ids:    []string{"user-valid", "user-refreshable"},
+			ok:     true,
+			output: "user-valid: valid\nuser-refreshable: expired, refreshable\n",
+		},
+		"missing user": {
+			ids:    []string{"user-missing"},
+			ok:     false,
+			output: "user-missing: missing\n",
+		},
+		"read error": {
+			ids:     []string{"user-valid"},
+			readErr: true,
+			err:     true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := testStore()
+			if test.readErr {
+				store.readErr = map[string]error{"user-valid": errors.New("read failed")}
+			}
+
+			var out bytes.Buffer
+			ok, err := check(context.Background(), store, &out, test.ids)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != test.ok {
+				t.Errorf("expected ok %t but got %t", test.ok, ok)
+			}
+			if out.String() != test.output {
+				t.Errorf("expected output:\n%s\nbut got:\n%s", test.output, out.String())
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := map[string]struct {
+		token  *oauth2.Token
+		status string
+		usable bool
+	}{
+		"missing": {
+			status: "missing",
+		},
+		"no access token": {
+			token:  &oauth2.Token{RefreshToken: "refresh"},
+			status: "invalid, no access token",
+		},
+		"valid": {
+			token:  &oauth2.Token{AccessToken: "access", Expiry: time.Now().Add(time.Hour)},
+			status: "valid",
+			usable: true,
+		},
+		"no expiry": {
+			token:  &oauth2.Token{AccessToken: "access"},
+			status: "valid",
+			usable: true,
+		},
+		"refreshable": {
+			token:  &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)},
+			status: "expired, refreshable",
+			usable: true,
+		},
+		"expired": {
+			token:  &oauth2.Token{AccessToken: "access", Expiry: time.Now().Add(-time.Hour)},
+			status: "expired",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if s := status(test.token); s != test.status {
+				t.Errorf("expected status %q but got %q", test.status, s)
+			}
+			if u := usable(test.token); u != test.usable {
+				t.Errorf("expected usable %t but got %t", test.usable, u)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := map[string]struct {
+		token    string
+		redacted string
+	}{
+		"empty": {
+			token:    "",
+			redacted: "(none)",
+		},
+		"short": {
+			token:    "abcdefghijkl",
+			redacted: "**** (12 chars)",
+		},
+		"long": {
+			token:    "abcdefghijklm",
+			redacted: "abcd****jklm (13 chars)",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if r := redact(test.token); r != test.redacted {
+				t.Errorf("expected %q but got %q", test.redacted, r)
+			}
+		})
+	}
+}
+
+func TestNewStoreRequiresOneStore(t *testing.T) {
+	tests := map[string][]string{
+		"none":     {"", "", "", ""},
+		"multiple": {"bucket", "", "table", ""},
+	}
+
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newStore(args[0], args[1], args[2], args[3]); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
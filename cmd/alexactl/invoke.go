@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	lambdasvc "github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/devserver"
+)
+
+// invoker sends a directive to a skill and returns its response
+type invoker interface {
+	invoke(ctx context.Context, req *alexa.Request) (*alexa.Response, error)
+}
+
+// lambdaInvoker invokes a deployed skill lambda
+type lambdaInvoker struct {
+	lambda       lambdaiface.LambdaAPI
+	functionName string
+}
+
+func (l *lambdaInvoker) invoke(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal directive: %v", err)
+	}
+
+	out, err := l.lambda.InvokeWithContext(ctx, &lambdasvc.InvokeInput{
+		FunctionName: aws.String(l.functionName),
+		Payload:      reqJSON,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to invoke lambda: %v", err)
+	}
+	if out.FunctionError != nil {
+		return nil, fmt.Errorf("lambda %s error: %s", aws.StringValue(out.FunctionError), out.Payload)
+	}
+
+	var resp alexa.Response
+	if err := json.Unmarshal(out.Payload, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	return &resp, nil
+}
+
+// devServerInvoker sends directives to a local cmd/devserver
+type devServerInvoker struct {
+	httpDoer alexa.HTTPDoer
+	url      string
+}
+
+func (d *devServerInvoker) invoke(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal directive: %v", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(d.url, "/")+"/directive",
+		bytes.NewReader(reqJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %v", err)
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := d.httpDoer.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform request: %v", err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("devserver unexpected status code: %s\n%s", httpResp.Status, body)
+	}
+
+	var exchange devserver.Exchange
+	if err := json.Unmarshal(body, &exchange); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal exchange: %v", err)
+	}
+	if exchange.Error != "" {
+		return exchange.Response, errors.New(exchange.Error)
+	}
+
+	return exchange.Response, nil
+}
+
+// eventSource is implemented by invokers that can observe the proactive events a
+// skill sends
+type eventSource interface {
+	// events returns the events recorded after sequence number after
+	events(ctx context.Context, after int) ([]devserver.RecordedEvent, error)
+}
+
+func (d *devServerInvoker) events(ctx context.Context, after int) ([]devserver.RecordedEvent, error) {
+	httpReq, err := http.NewRequest(http.MethodGet,
+		fmt.Sprintf("%s/events?after=%d", strings.TrimSuffix(d.url, "/"), after), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %v", err)
+	}
+	httpReq = httpReq.WithContext(ctx)
+
+	httpResp, err := d.httpDoer.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform request: %v", err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("devserver unexpected status code: %s\n%s", httpResp.Status, body)
+	}
+
+	var events []devserver.RecordedEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal events: %v", err)
+	}
+
+	return events, nil
+}
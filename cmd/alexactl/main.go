@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	lambdasvc "github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/mctofu/alexa-smart-home/sample"
+)
+
+// Command line tool that sends templated directives to a deployed skill lambda or a
+// local cmd/devserver and renders the response for quick manual testing.
+//
+// Usage:
+//
+//	alexactl -function my-skill discover
+//	alexactl -url http://localhost:8080 turnon switch-1
+//	alexactl -function my-skill -value 30 setpercentage window-1
+//	alexactl -url http://localhost:8080 repl
+//
+// The repl interactively picks an endpoint, capability and directive. When connected
+// to a devserver, proactive events the skill posts to the devserver's event gateway
+// are shown after each directive.
+func main() {
+	function := flag.String("function", "", "name or arn of the skill lambda to invoke")
+	url := flag.String("url", "", "url of a local devserver to send directives to")
+	token := flag.String("token", "", "bearer token to send in the directive scope")
+	value := flag.String("value", "", "payload value for directives that take one. json or a bare string")
+	timeout := flag.Duration("timeout", 10*time.Second, "time to wait for a response")
+	eventWait := flag.Duration("events-wait", 2*time.Second, "time to watch for proactive events after each directive in the repl")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	inv, err := newInvoker(*function, *url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	if flag.Arg(0) == "repl" {
+		r := &repl{
+			inv:       inv,
+			token:     *token,
+			timeout:   *timeout,
+			eventWait: *eventWait,
+			in:        bufio.NewScanner(os.Stdin),
+			out:       os.Stdout,
+		}
+		if err := r.run(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	params := sample.Params{
+		EndpointID: flag.Arg(1),
+		Token:      *token,
+	}
+	if *value != "" {
+		params.Value = sample.Value(*value)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if err := run(ctx, os.Stdout, inv, strings.ToLower(flag.Arg(0)), params); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: alexactl (-function name | -url devserver) [flags] directive [endpointId]\n")
+	fmt.Fprintf(os.Stderr, "       alexactl (-function name | -url devserver) [flags] repl\n\n")
+	fmt.Fprintf(os.Stderr, "directives:\n")
+	for _, name := range sample.Names() {
+		description := sample.Describe(name)
+		if hint := sample.ValueHint(name); hint != "" {
+			description = fmt.Sprintf("%s (-value %s)", description, hint)
+		}
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", name, description)
+	}
+	fmt.Fprintf(os.Stderr, "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+func newInvoker(function, url string) (invoker, error) {
+	switch {
+	case function != "" && url != "":
+		return nil, errors.New("only one of -function or -url may be set")
+	case function != "":
+		session, err := session.NewSession()
+		if err != nil {
+			return nil, fmt.Errorf("failed to init aws session: %v", err)
+		}
+		return &lambdaInvoker{lambda: lambdasvc.New(session), functionName: function}, nil
+	case url != "":
+		return &devServerInvoker{httpDoer: http.DefaultClient, url: url}, nil
+	}
+	return nil, errors.New("one of -function or -url is required")
+}
+
+func run(ctx context.Context, w io.Writer, inv invoker, directive string, params sample.Params) error {
+	req, err := sample.Directive(directive, params)
+	if err != nil {
+		return err
+	}
+
+	resp, err := inv.invoke(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	return render(w, resp)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/devserver"
+	"github.com/mctofu/alexa-smart-home/sample"
+)
+
+type invokerFunc func(ctx context.Context, req *alexa.Request) (*alexa.Response, error)
+
+func (f invokerFunc) invoke(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	return f(ctx, req)
+}
+
+type httpDoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f httpDoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func fixedMessageID() string {
+	return "message-1"
+}
+
+func TestRun(t *testing.T) {
+	builder := &alexa.ResponseBuilder{MessageID: fixedMessageID}
+
+	tests := map[string]struct {
+		resp     func(req *alexa.Request) (*alexa.Response, error)
+		contains []string
+		err      string
+	}{
+		"error response": {
+			resp: func(req *alexa.Request) (*alexa.Response, error) {
+				return builder.BasicErrorResponse(req, alexa.ErrorTypeEndpointUnreachable, "offline")
+			},
+			contains: []string{
+				"Alexa.ErrorResponse",
+				"ENDPOINT_UNREACHABLE: offline",
+			},
+		},
+		"no response": {
+			resp: func(req *alexa.Request) (*alexa.Response, error) {
+				return nil, nil
+			},
+			err: "no response returned",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			inv := invokerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+				if req.Directive.Endpoint.EndpointID != "switch-1" {
+					t.Errorf("unexpected endpoint: %s", req.Directive.Endpoint.EndpointID)
+				}
+				return test.resp(req)
+			})
+
+			var out bytes.Buffer
+			err := run(context.Background(), &out, inv, "reportstate", sample.Params{EndpointID: "switch-1"})
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q but got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, expected := range test.contains {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("expected output to contain %q:\n%s", expected, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRenderNil(t *testing.T) {
+	var out bytes.Buffer
+	if err := render(&out, nil); err == nil {
+		t.Fatal("expected error rendering a nil response")
+	}
+	if out.Len() != 0 {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+}
+
+func TestDevServerInvokerHandlerError(t *testing.T) {
+	inv := &devServerInvoker{
+		httpDoer: httpDoerFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.String() != "http://localhost:8080/directive" {
+				t.Errorf("unexpected url: %s", req.URL)
+			}
+			body, err := json.Marshal(devserver.Exchange{Error: "handler failed"})
+			if err != nil {
+				t.Fatalf("failed to marshal exchange: %v", err)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(body)),
+			}, nil
+		}),
+		url: "http://localhost:8080/",
+	}
+
+	req, err := sample.Directive("discover", sample.Params{})
+	if err != nil {
+		t.Fatalf("failed to build directive: %v", err)
+	}
+
+	resp, err := inv.invoke(context.Background(), req)
+	if err == nil || err.Error() != "handler failed" {
+		t.Fatalf("expected handler error but got %v", err)
+	}
+	if resp != nil {
+		t.Errorf("expected no response but got %+v", resp)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// render prints a summary of resp followed by the full response and its schema validation
+func render(w io.Writer, resp *alexa.Response) error {
+	if resp == nil {
+		return errors.New("no response returned")
+	}
+
+	header := resp.Event.Header
+	fmt.Fprintf(w, "%s.%s\n\n", header.Namespace, header.Name)
+
+	switch {
+	case header.Namespace == alexa.NamespaceDiscovery:
+		if err := renderEndpoints(w, resp.Event.Payload); err != nil {
+			return err
+		}
+	case header.Name == "ErrorResponse":
+		var payload struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal error payload: %v", err)
+		}
+		fmt.Fprintf(w, "%s: %s\n\n", payload.Type, payload.Message)
+	case resp.Context != nil:
+		renderProperties(w, resp.Context.Properties)
+	}
+
+	respJSON, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %v", err)
+	}
+	fmt.Fprintf(w, "%s\n\n", respJSON)
+
+	err = alexa.ValidateResponse(respJSON)
+	var schemaErr *alexa.SchemaError
+	switch {
+	case errors.As(err, &schemaErr):
+		fmt.Fprintf(w, "Response is not valid:\n%s\n", schemaErr)
+	case err != nil:
+		return err
+	default:
+		fmt.Fprintf(w, "Schema validated!\n")
+	}
+
+	return nil
+}
+
+func renderEndpoints(w io.Writer, payload json.RawMessage) error {
+	var discover alexa.DiscoverPayload
+	if err := json.Unmarshal(payload, &discover); err != nil {
+		return fmt.Errorf("failed to unmarshal discover payload: %v", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ENDPOINT\tNAME\tCATEGORIES\tINTERFACES\n")
+	for _, endpoint := range discover.Endpoints {
+		var interfaces []string
+		for _, capability := range endpoint.Capabilities {
+			interfaces = append(interfaces, capability.Interface)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			endpoint.EndpointID,
+			endpoint.FriendlyName,
+			strings.Join(endpoint.DisplayCategories, ","),
+			strings.Join(interfaces, ","))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+
+	return nil
+}
+
+func renderProperties(w io.Writer, properties []alexa.ContextProperty) {
+	if len(properties) == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "PROPERTY\tVALUE\tSAMPLED\n")
+	for _, prop := range properties {
+		name := prop.Namespace + "." + prop.Name
+		if prop.Instance != "" {
+			name = prop.Namespace + "." + prop.Instance + "." + prop.Name
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, prop.Value, prop.TimeOfSample.Format("15:04:05"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
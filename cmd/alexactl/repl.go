@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/sample"
+)
+
+// errQuit is returned by prompts when the user asks to leave the repl
+var errQuit = errors.New("quit")
+
+// repl interactively picks an endpoint, capability and directive, prompts for the
+// directive's payload value and sends it. Proactive events sent by the skill are shown
+// if the invoker can observe them.
+type repl struct {
+	inv     invoker
+	token   string
+	timeout time.Duration
+	// eventWait is how long to watch for events after each directive
+	eventWait time.Duration
+
+	in  *bufio.Scanner
+	out io.Writer
+
+	endpoints []alexa.DiscoverEndpoint
+	lastEvent int
+}
+
+func (r *repl) run(ctx context.Context) error {
+	fmt.Fprintf(r.out, "enter a number to choose, empty to go back, q to quit\n")
+
+	if err := r.discover(ctx); err != nil {
+		return err
+	}
+	if err := r.syncEvents(ctx); err != nil {
+		return err
+	}
+
+	for {
+		err := r.step(ctx)
+		switch {
+		case errors.Is(err, errQuit):
+			return nil
+		case err != nil:
+			fmt.Fprintf(r.out, "error: %v\n", err)
+		}
+	}
+}
+
+func (r *repl) discover(ctx context.Context) error {
+	resp, err := r.send(ctx, "discover", sample.Params{Token: r.token})
+	if err != nil {
+		return fmt.Errorf("failed to discover endpoints: %v", err)
+	}
+
+	var payload alexa.DiscoverPayload
+	if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal discover payload: %v", err)
+	}
+	r.endpoints = payload.Endpoints
+
+	return nil
+}
+
+// step sends one directive
+func (r *repl) step(ctx context.Context) error {
+	options := []string{"(rediscover)"}
+	for _, endpoint := range r.endpoints {
+		options = append(options, fmt.Sprintf("%s  %s", endpoint.EndpointID, endpoint.FriendlyName))
+	}
+	choice, err := r.choose("endpoint", options)
+	if err != nil {
+		return err
+	}
+	if choice < 0 {
+		return nil
+	}
+	if choice == 0 {
+		return r.discover(ctx)
+	}
+	endpoint := r.endpoints[choice-1]
+
+	for {
+		interfaces := endpointInterfaces(endpoint)
+		choice, err := r.choose("capability", interfaces)
+		if err != nil {
+			return err
+		}
+		if choice < 0 {
+			return nil
+		}
+
+		directives := interfaceDirectives(interfaces[choice])
+		if len(directives) == 0 {
+			fmt.Fprintf(r.out, "no sample directives for %s\n", interfaces[choice])
+			continue
+		}
+		var options []string
+		for _, name := range directives {
+			options = append(options, fmt.Sprintf("%s  %s", name, sample.Describe(name)))
+		}
+		choice, err = r.choose("directive", options)
+		if err != nil {
+			return err
+		}
+		if choice < 0 {
+			continue
+		}
+
+		return r.sendDirective(ctx, directives[choice], endpoint.EndpointID)
+	}
+}
+
+func (r *repl) sendDirective(ctx context.Context, name, endpointID string) error {
+	params := sample.Params{
+		EndpointID: endpointID,
+		Token:      r.token,
+	}
+
+	if hint := sample.ValueHint(name); hint != "" {
+		value, err := r.prompt(fmt.Sprintf("%s (empty for default)", hint))
+		if err != nil {
+			return err
+		}
+		if value != "" {
+			params.Value = sample.Value(value)
+		}
+	}
+
+	resp, err := r.send(ctx, name, params)
+	if err != nil {
+		return err
+	}
+	if err := render(r.out, resp); err != nil {
+		return err
+	}
+
+	return r.watchEvents(ctx)
+}
+
+func (r *repl) send(ctx context.Context, name string, params sample.Params) (*alexa.Response, error) {
+	req, err := sample.Directive(name, params)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.inv.invoke(ctx, req)
+}
+
+// syncEvents skips any events recorded before the repl started
+func (r *repl) syncEvents(ctx context.Context) error {
+	source, ok := r.inv.(eventSource)
+	if !ok {
+		return nil
+	}
+
+	events, err := source.events(ctx, r.lastEvent)
+	if err != nil {
+		return fmt.Errorf("failed to read events: %v", err)
+	}
+	if len(events) > 0 {
+		r.lastEvent = events[len(events)-1].Seq
+	}
+
+	return nil
+}
+
+// watchEvents prints the events the skill sends within eventWait
+func (r *repl) watchEvents(ctx context.Context) error {
+	source, ok := r.inv.(eventSource)
+	if !ok || r.eventWait <= 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(r.eventWait)
+	for time.Now().Before(deadline) {
+		events, err := source.events(ctx, r.lastEvent)
+		if err != nil {
+			return fmt.Errorf("failed to read events: %v", err)
+		}
+		for _, recorded := range events {
+			fmt.Fprintf(r.out, "--- event %d\n", recorded.Seq)
+			if err := render(r.out, recorded.Event); err != nil {
+				return err
+			}
+			r.lastEvent = recorded.Seq
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+
+	return nil
+}
+
+// choose lists options and returns the index of the chosen one or -1 if the user
+// wants to go back
+func (r *repl) choose(label string, options []string) (int, error) {
+	for i, option := range options {
+		fmt.Fprintf(r.out, "%3d) %s\n", i, option)
+	}
+
+	for {
+		answer, err := r.prompt(label)
+		if err != nil {
+			return 0, err
+		}
+		if answer == "" {
+			return -1, nil
+		}
+		choice, err := strconv.Atoi(answer)
+		if err == nil && choice >= 0 && choice < len(options) {
+			return choice, nil
+		}
+		fmt.Fprintf(r.out, "choose 0 to %d\n", len(options)-1)
+	}
+}
+
+func (r *repl) prompt(label string) (string, error) {
+	fmt.Fprintf(r.out, "%s> ", label)
+	if !r.in.Scan() {
+		if err := r.in.Err(); err != nil {
+			return "", err
+		}
+		return "", errQuit
+	}
+
+	answer := strings.TrimSpace(r.in.Text())
+	if answer == "q" {
+		return "", errQuit
+	}
+	return answer, nil
+}
+
+// endpointInterfaces lists the interfaces of the endpoint's capabilities. The Alexa
+// interface is always included so the endpoint's state can be requested.
+func endpointInterfaces(endpoint alexa.DiscoverEndpoint) []string {
+	interfaces := []string{alexa.NamespaceAlexa}
+	for _, capability := range endpoint.Capabilities {
+		if capability.Interface != alexa.NamespaceAlexa {
+			interfaces = append(interfaces, capability.Interface)
+		}
+	}
+	return interfaces
+}
+
+// interfaceDirectives lists the sample directives for an interface
+func interfaceDirectives(iface string) []string {
+	var names []string
+	for _, name := range sample.Names() {
+		if sample.Namespace(name) == iface {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/devserver"
+	"github.com/mctofu/alexa-smart-home/simulator"
+)
+
+// Local development server that emulates the Alexa smart home service in front of
+// a demo handler with a mock temperature sensor and a mock fan switch. Copy this
+// and replace handler() with your own skill's handler. Pass -simulate to serve
+// simulated devices from the simulator package instead.
+//
+// Try:
+//
+//	curl -X POST localhost:8080/samples/discover
+//	curl -X POST 'localhost:8080/samples/turnon?endpointId=switch-1'
+//	curl -X POST -d @directive.json localhost:8080/directive
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	simulate := flag.Bool("simulate", false, "serve simulated devices")
+	flag.Parse()
+
+	server := &devserver.Server{Handler: handler()}
+	if *simulate {
+		sim := simulated()
+		go sim.Run(context.Background())
+		server.Handler = sim
+	}
+
+	log.Printf("devserver listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
+
+func handler() alexa.Handler {
+	respBuilder := alexa.NewResponseBuilder()
+	fan := &fan{respBuilder: respBuilder}
+
+	mux := alexa.NewNamespaceMux()
+	mux.HandleFunc(alexa.NamespaceDiscovery, alexa.StaticDiscoveryHandler(respBuilder, endpoints()...))
+	mux.HandleFunc(alexa.NamespacePowerController, alexa.PowerControllerHandler(
+		alexa.HandlerFunc(fan.TurnOn), alexa.HandlerFunc(fan.TurnOff)))
+	mux.HandleReportState(alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		switch req.Directive.Endpoint.EndpointID {
+		case "temp-sensor-1":
+			return temperature(respBuilder, req)
+		case "switch-1":
+			return fan.ReportState(ctx, req)
+		}
+		return respBuilder.BasicErrorResponse(req, alexa.ErrorTypeNoSuchEndpoint, "unknown endpoint")
+	}))
+
+	return mux
+}
+
+func simulated() *simulator.Simulator {
+	sim := &simulator.Simulator{
+		RespBuilder: alexa.NewResponseBuilder(),
+		Interval:    10 * time.Second,
+	}
+	sim.Add(
+		simulator.NewLight("light-1", "Lamp"),
+		simulator.NewThermostat("thermostat-1", "Thermostat"),
+		simulator.NewLock("lock-1", "Front Door"),
+		simulator.NewSensor("temp-sensor-1", "Home Temperature", 72, 0.5),
+	)
+	return sim
+}
+
+func endpoints() []alexa.DiscoverEndpoint {
+	return []alexa.DiscoverEndpoint{
+		{
+			EndpointID:        "temp-sensor-1",
+			FriendlyName:      "Home Temperature",
+			Description:       "Temp monitor",
+			ManufacturerName:  "McTofu",
+			DisplayCategories: []string{alexa.DisplayCategoryTemperatureSensor},
+			Capabilities: []alexa.DiscoverCapability{
+				{
+					Type:      "AlexaInterface",
+					Interface: alexa.InterfaceTemperatureSensor,
+					Version:   "3",
+					Properties: &alexa.DiscoverProperties{
+						Supported: []alexa.DiscoverProperty{
+							{
+								Name: "temperature",
+							},
+						},
+						ProactivelyReported: false,
+						Retrievable:         true,
+					},
+				},
+			},
+		},
+		{
+			EndpointID:        "switch-1",
+			FriendlyName:      "Fan",
+			Description:       "Power switch for fan",
+			ManufacturerName:  "McTofu",
+			DisplayCategories: []string{alexa.DisplayCategorySwitch},
+			Capabilities: []alexa.DiscoverCapability{
+				{
+					Type:      "AlexaInterface",
+					Interface: alexa.InterfacePowerController,
+					Version:   "3",
+					Properties: &alexa.DiscoverProperties{
+						Supported: []alexa.DiscoverProperty{
+							{
+								Name: "powerState",
+							},
+						},
+						ProactivelyReported: false,
+						Retrievable:         true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func temperature(respBuilder *alexa.ResponseBuilder, req *alexa.Request) (*alexa.Response, error) {
+	tempJSON, err := json.Marshal(alexa.TemperatureValue{
+		Value: 75,
+		Scale: alexa.TemperatureScaleFahrenheit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal temp: %v", err)
+	}
+
+	return respBuilder.StateReportResponse(req,
+		alexa.ContextProperty{
+			Namespace:                 alexa.NamespaceTemperatureSensor,
+			Name:                      "temperature",
+			Value:                     tempJSON,
+			TimeOfSample:              time.Now(),
+			UncertaintyInMilliseconds: 60000,
+		}), nil
+}
+
+type fan struct {
+	respBuilder *alexa.ResponseBuilder
+
+	mu sync.Mutex
+	on bool
+}
+
+func (f *fan) TurnOn(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	return f.set(req, true)
+}
+
+func (f *fan) TurnOff(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	return f.set(req, false)
+}
+
+func (f *fan) ReportState(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	prop, err := f.powerState()
+	if err != nil {
+		return nil, err
+	}
+	return f.respBuilder.StateReportResponse(req, prop), nil
+}
+
+func (f *fan) set(req *alexa.Request, on bool) (*alexa.Response, error) {
+	f.mu.Lock()
+	f.on = on
+	f.mu.Unlock()
+
+	prop, err := f.powerState()
+	if err != nil {
+		return nil, err
+	}
+	return f.respBuilder.BasicResponse(req, prop), nil
+}
+
+func (f *fan) powerState() (alexa.ContextProperty, error) {
+	f.mu.Lock()
+	state := "OFF"
+	if f.on {
+		state = "ON"
+	}
+	f.mu.Unlock()
+
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return alexa.ContextProperty{}, fmt.Errorf("failed to marshal power state: %v", err)
+	}
+
+	return alexa.ContextProperty{
+		Namespace:                 alexa.NamespacePowerController,
+		Name:                      "powerState",
+		Value:                     stateJSON,
+		TimeOfSample:              time.Now(),
+		UncertaintyInMilliseconds: 0,
+	}, nil
+}
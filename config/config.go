@@ -0,0 +1,298 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/aws/dynamostore"
+	"github.com/mctofu/alexa-smart-home/aws/s3store"
+	"github.com/mctofu/alexa-smart-home/aws/secretcreds"
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+// Token store selections
+const (
+	TokenStoreS3       = "s3"
+	TokenStoreDynamoDB = "dynamodb"
+	TokenStoreFile     = "file"
+)
+
+// Client credentials sources
+const (
+	CredentialsEnv            = "env"
+	CredentialsFile           = "file"
+	CredentialsSecretsManager = "secretsmanager"
+)
+
+// Event gateway regions
+const (
+	EventRegionNorthAmerica = "na"
+	EventRegionEurope       = "eu"
+	EventRegionFarEast      = "fe"
+)
+
+// Log levels
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelOff   = "off"
+)
+
+// Config holds the settings shared by skill lambdas and home agents. Each field can
+// be set in a json file, an environment variable or a command line flag named after
+// its json key, with later sources overriding earlier ones.
+type Config struct {
+	// QueueURL is the SQS queue directives are relayed through
+	QueueURL string `json:"queueUrl" env:"SQS_QUEUE_URL"`
+	// Region is the AWS region. Defaults to the region of the AWS session.
+	Region string `json:"region" env:"AWS_REGION"`
+	// EventRegion selects the event gateway matching the region of the users' accounts:
+	// na, eu or fe. Defaults to na.
+	EventRegion string `json:"eventRegion" env:"EVENT_REGION"`
+
+	// TokenStore selects where user tokens are kept: s3, dynamodb or file. Defaults to s3.
+	TokenStore  string `json:"tokenStore" env:"TOKEN_STORE"`
+	TokenBucket string `json:"tokenBucket" env:"S3_TOKEN_BUCKET"`
+	TokenPrefix string `json:"tokenPrefix" env:"S3_TOKEN_PREFIX"`
+	TokenTable  string `json:"tokenTable" env:"DYNAMODB_TOKEN_TABLE"`
+	TokenDir    string `json:"tokenDir" env:"TOKEN_DIR"`
+
+	// CredentialsSource selects where the client credentials are loaded from: env,
+	// file or secretsmanager. Defaults to env which uses ClientID and ClientSecret.
+	CredentialsSource string `json:"credentialsSource" env:"CREDENTIALS_SOURCE"`
+	ClientID          string `json:"clientId" env:"AUTH_CLIENT_ID"`
+	ClientSecret      string `json:"clientSecret" env:"AUTH_CLIENT_SECRET"`
+	CredentialsFile   string `json:"credentialsFile" env:"CREDENTIALS_FILE"`
+	CredentialsSecret string `json:"credentialsSecret" env:"CREDENTIALS_SECRET"`
+
+	// LogLevel is debug, info or off. Defaults to info.
+	LogLevel   string `json:"logLevel" env:"LOG_LEVEL"`
+	SentryDSN  string `json:"sentryDsn" env:"SENTRY_DSN"`
+	HealthAddr string `json:"healthAddr" env:"HEALTH_ADDR"`
+}
+
+// Default returns a Config with the defaults applied
+func Default() *Config {
+	return &Config{
+		EventRegion:       EventRegionNorthAmerica,
+		TokenStore:        TokenStoreS3,
+		CredentialsSource: CredentialsEnv,
+		LogLevel:          LogLevelInfo,
+	}
+}
+
+// Load builds a Config from the defaults, the json file named by the -config flag or
+// CONFIG_FILE environment variable, the environment and finally the flags in args.
+// required lists the json keys of fields that must be set, e.g. "queueUrl".
+func Load(args []string, required ...string) (*Config, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "json config file")
+	flagValues := make(map[string]*string)
+	c.fields(func(key, env string, field reflect.Value) {
+		flagValues[key] = fs.String(key, "", fmt.Sprintf("overrides %s", env))
+	})
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		content, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		if err := json.Unmarshal(content, c); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %v", err)
+		}
+	}
+
+	c.fields(func(key, env string, field reflect.Value) {
+		if val, ok := os.LookupEnv(env); ok {
+			field.SetString(val)
+		}
+	})
+
+	fs.Visit(func(f *flag.Flag) {
+		if val, ok := flagValues[f.Name]; ok {
+			c.field(f.Name).SetString(*val)
+		}
+	})
+
+	if err := c.Validate(required...); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Validate checks that the selections are known, that the settings they depend on
+// are set and that the fields named by required are set.
+func (c *Config) Validate(required ...string) error {
+	var problems []string
+	missing := func(key string) {
+		problems = append(problems, fmt.Sprintf("%s must be set", key))
+	}
+	oneOf := func(key, val string, options ...string) bool {
+		for _, option := range options {
+			if val == option {
+				return true
+			}
+		}
+		problems = append(problems, fmt.Sprintf("%s must be one of %s, got %q", key, strings.Join(options, ", "), val))
+		return false
+	}
+
+	for _, key := range required {
+		field := c.field(key)
+		if !field.IsValid() {
+			problems = append(problems, fmt.Sprintf("unknown setting %s", key))
+		} else if field.String() == "" {
+			missing(key)
+		}
+	}
+
+	if oneOf("tokenStore", c.TokenStore, TokenStoreS3, TokenStoreDynamoDB, TokenStoreFile) {
+		switch {
+		case c.TokenStore == TokenStoreS3 && c.TokenBucket == "":
+			missing("tokenBucket")
+		case c.TokenStore == TokenStoreDynamoDB && c.TokenTable == "":
+			missing("tokenTable")
+		case c.TokenStore == TokenStoreFile && c.TokenDir == "":
+			missing("tokenDir")
+		}
+	}
+
+	if oneOf("credentialsSource", c.CredentialsSource, CredentialsEnv, CredentialsFile, CredentialsSecretsManager) {
+		switch c.CredentialsSource {
+		case CredentialsEnv:
+			if c.ClientID == "" {
+				missing("clientId")
+			}
+			if c.ClientSecret == "" {
+				missing("clientSecret")
+			}
+		case CredentialsFile:
+			if c.CredentialsFile == "" {
+				missing("credentialsFile")
+			}
+		case CredentialsSecretsManager:
+			if c.CredentialsSecret == "" {
+				missing("credentialsSecret")
+			}
+		}
+	}
+
+	oneOf("eventRegion", c.EventRegion, EventRegionNorthAmerica, EventRegionEurope, EventRegionFarEast)
+	oneOf("logLevel", c.LogLevel, LogLevelDebug, LogLevelInfo, LogLevelOff)
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Session creates an AWS session in Region
+func (c *Config) Session() (*session.Session, error) {
+	opts := session.Options{SharedConfigState: session.SharedConfigEnable}
+	if c.Region != "" {
+		opts.Config.Region = &c.Region
+	}
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init aws session: %v", err)
+	}
+	return sess, nil
+}
+
+// NewTokenStore creates the selected token store. Tokens are logged when LogLevel is debug.
+func (c *Config) NewTokenStore(sess *session.Session) alexa.TokenReaderWriter {
+	var store alexa.TokenReaderWriter
+	switch c.TokenStore {
+	case TokenStoreDynamoDB:
+		store = &dynamostore.TokenStorage{DynamoDB: dynamodb.New(sess), Table: c.TokenTable}
+	case TokenStoreFile:
+		store = &alexa.FileTokenStore{Dir: c.TokenDir}
+	default:
+		store = &s3store.TokenStorage{S3: s3.New(sess), Bucket: c.TokenBucket, Prefix: c.TokenPrefix}
+	}
+
+	if c.Debug() {
+		return &alexa.DebugTokenStore{TokenStore: store}
+	}
+	return store
+}
+
+// NewCredentials creates the selected client credentials provider
+func (c *Config) NewCredentials(sess *session.Session) alexa.CredentialsProvider {
+	switch c.CredentialsSource {
+	case CredentialsFile:
+		return &alexa.FileCredentials{Path: c.CredentialsFile}
+	case CredentialsSecretsManager:
+		return &secretcreds.CredentialsProvider{
+			SecretsManager:  secretsmanager.New(sess),
+			SecretID:        c.CredentialsSecret,
+			RefreshInterval: time.Hour,
+		}
+	default:
+		return alexa.ClientCredentials{ClientID: c.ClientID, ClientSecret: c.ClientSecret}
+	}
+}
+
+// EventGatewayURL returns the event gateway for EventRegion
+func (c *Config) EventGatewayURL() string {
+	switch c.EventRegion {
+	case EventRegionEurope:
+		return deferred.EventGatewayEurope
+	case EventRegionFarEast:
+		return deferred.EventGatewayFarEast
+	default:
+		return deferred.EventGatewayNorthAmerica
+	}
+}
+
+// Debug reports if LogLevel is debug
+func (c *Config) Debug() bool {
+	return c.LogLevel == LogLevelDebug
+}
+
+// Logger returns a logger for LogLevel
+func (c *Config) Logger() alexa.Logger {
+	if c.LogLevel == LogLevelOff {
+		return alexa.NopLogger{}
+	}
+	return alexa.StdLogger{}
+}
+
+// fields calls fn with each configurable field of c
+func (c *Config) fields(fn func(key, env string, field reflect.Value)) {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fn(f.Tag.Get("json"), f.Tag.Get("env"), v.Field(i))
+	}
+}
+
+// field returns the field with the json key or the zero Value if there isn't one
+func (c *Config) field(key string) reflect.Value {
+	var found reflect.Value
+	c.fields(func(k, env string, field reflect.Value) {
+		if k == key {
+			found = field
+		}
+	})
+	return found
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"queueUrl": "file-queue", "tokenStore": "dynamodb", "tokenTable": "tokens", "logLevel": "debug"}`
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("SQS_QUEUE_URL", "env-queue")
+	t.Setenv("AUTH_CLIENT_ID", "env-id")
+	t.Setenv("AUTH_CLIENT_SECRET", "env-secret")
+	t.Setenv("LOG_LEVEL", "info")
+
+	c, err := Load([]string{"-logLevel", "off", "-eventRegion", "eu"}, "queueUrl")
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+
+	if c.QueueURL != "env-queue" || c.TokenStore != TokenStoreDynamoDB || c.TokenTable != "tokens" ||
+		c.ClientID != "env-id" || c.LogLevel != LogLevelOff || c.EventRegion != EventRegionEurope {
+		t.Errorf("unexpected config: %+v", c)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := Default()
+	c.TokenStore = "redis"
+	c.CredentialsSource = CredentialsSecretsManager
+
+	err := c.Validate("queueUrl", "bogus")
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, problem := range []string{"queueUrl must be set", "unknown setting bogus", "tokenStore must be one of",
+		"credentialsSecret must be set"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in %v", problem, err)
+		}
+	}
+}
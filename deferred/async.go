@@ -0,0 +1,70 @@
+package deferred
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// Async answers requests with a DeferredResponse and handles them in the background
+// with Handler, which sends the actual response to the smart home api. It suits
+// devices that take longer than Alexa waits, such as locks:
+//
+//	unlock := &deferred.Async{Handler: &deferred.Handler{RequestHandler: unlocker, EventSender: sender, ErrorReporter: reporter}, RespBuilder: rb}
+//	mux.Handle(alexa.NamespaceLockController, alexa.LockControllerHandler(locker, unlock))
+//
+// Failures are reported to the Handler's ErrorReporter, which is required as nothing
+// else sees them. Wait for background handling to finish before shutting down.
+//
+// The process must keep running after the DeferredResponse is returned so Async
+// doesn't suit AWS Lambda, which freezes it. Relay directives with
+// sqsrelay.RelayHandler and handle them with a sqsrelay.QueueProcessor instead.
+type Async struct {
+	Handler     *Handler
+	RespBuilder *alexa.ResponseBuilder
+	// Timeout limits how long background handling and sending may take. Defaults to 1m.
+	Timeout time.Duration
+
+	wg sync.WaitGroup
+}
+
+// HandleRequest starts handling req in the background and returns a DeferredResponse.
+// The background handling keeps the values of ctx but not its deadline or cancellation.
+// An error is returned without handling req if the Handler has no ErrorReporter.
+func (a *Async) HandleRequest(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	if a.Handler.ErrorReporter == nil {
+		return nil, errors.New("deferred.Async: Handler requires an ErrorReporter")
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		handleCtx, cancel := context.WithTimeout(alexa.Detach(ctx), a.timeout())
+		defer cancel()
+		// errors are reported by the Handler's ErrorReporter
+		_ = a.Handler.HandleRequest(handleCtx, req)
+	}()
+	return a.RespBuilder.DeferredResponse(req), nil
+}
+
+// Wait blocks until the requests being handled in the background are done
+func (a *Async) Wait() {
+	a.wg.Wait()
+}
+
+func (a *Async) timeout() time.Duration {
+	if a.Timeout <= 0 {
+		return time.Minute
+	}
+	return a.Timeout
+}
+
+// DeferredUnlockHandler routes lock directives to lock and answers unlock directives
+// with async, which unlocks in the background and sends the response. Call async.Wait
+// before shutting down so pending unlocks aren't lost.
+func DeferredUnlockHandler(async *Async, lock alexa.Handler) alexa.HandlerFunc {
+	return alexa.LockControllerHandler(lock, async)
+}
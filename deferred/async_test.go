@@ -0,0 +1,115 @@
+package deferred
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func lockRequest(name string) *alexa.Request {
+	return &alexa.Request{Directive: alexa.RequestDirective{
+		Header: alexa.Header{
+			Namespace:        alexa.NamespaceLockController,
+			Name:             name,
+			MessageID:        "msg-1",
+			CorrelationToken: "corr-1",
+		},
+		Endpoint: alexa.RequestEndpoint{EndpointID: "lock-1"},
+		Payload:  alexa.EmptyPayload,
+	}}
+}
+
+// recorder collects the events sent and errors reported by an Async
+type recorder struct {
+	mu     sync.Mutex
+	events []*alexa.Response
+	errs   []error
+}
+
+func (r *recorder) Send(ctx context.Context, resp *alexa.Response) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, resp)
+	return nil
+}
+
+func (r *recorder) ReportError(ctx context.Context, req *alexa.Request, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+func TestDeferredUnlockHandler(t *testing.T) {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-2" }}
+	rec := &recorder{}
+	unlocked := make(chan struct{})
+	async := &Async{
+		Handler: &Handler{
+			RequestHandler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+				<-unlocked
+				return rb.BasicResponse(req), nil
+			}),
+			EventSender:   rec,
+			ErrorReporter: rec,
+		},
+		RespBuilder: rb,
+	}
+	lock := alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		return rb.BasicResponse(req), nil
+	})
+	handler := DeferredUnlockHandler(async, lock)
+
+	resp, err := handler.HandleRequest(context.Background(), lockRequest("Lock"))
+	if err != nil || resp.Event.Header.Name != "Response" {
+		t.Fatalf("expected lock to be answered directly: %+v %v", resp, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resp, err = handler.HandleRequest(ctx, lockRequest("Unlock"))
+	if err != nil || resp.Event.Header.Name != "DeferredResponse" {
+		t.Fatalf("expected a DeferredResponse for unlock: %+v %v", resp, err)
+	}
+	// background handling isn't tied to the request's cancellation
+	cancel()
+	close(unlocked)
+	async.Wait()
+
+	if len(rec.events) != 1 || rec.events[0].Event.Header.CorrelationToken != "corr-1" {
+		t.Errorf("expected the unlock response to be sent: %+v", rec.events)
+	}
+	if len(rec.errs) != 0 {
+		t.Errorf("unexpected errors: %v", rec.errs)
+	}
+}
+
+func TestAsyncReportsErrors(t *testing.T) {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-2" }}
+	rec := &recorder{}
+	async := &Async{
+		Handler: &Handler{
+			RequestHandler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+				return nil, errors.New("jammed")
+			}),
+			EventSender:   rec,
+			ErrorReporter: rec,
+		},
+		RespBuilder: rb,
+	}
+
+	if _, err := async.HandleRequest(context.Background(), lockRequest("Unlock")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	async.Wait()
+
+	if len(rec.errs) != 1 || len(rec.events) != 0 {
+		t.Errorf("expected the failure to be reported and nothing sent: %v %+v", rec.errs, rec.events)
+	}
+
+	async.Handler.ErrorReporter = nil
+	if _, err := async.HandleRequest(context.Background(), lockRequest("Unlock")); err == nil {
+		t.Error("expected an error without an ErrorReporter")
+	}
+}
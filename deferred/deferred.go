@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mctofu/alexa-smart-home/alexa"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/amazon"
 )
 
 // EventSender publishes a response back to the smart home event api
@@ -26,102 +29,428 @@ func (e EventSenderFunc) Send(ctx context.Context, resp *alexa.Response) error {
 	return e(ctx, resp)
 }
 
+// EventsHandler handles a request and returns the events to send for it in order, e.g.
+// a Response followed by a ChangeReport or a WakeUp event followed by a Response.
+type EventsHandler interface {
+	HandleRequest(ctx context.Context, req *alexa.Request) ([]*alexa.Response, error)
+}
+
+// EventsHandlerFunc implements EventsHandler as a func
+type EventsHandlerFunc func(ctx context.Context, req *alexa.Request) ([]*alexa.Response, error)
+
+// HandleRequest calls the EventsHandlerFunc
+func (e EventsHandlerFunc) HandleRequest(ctx context.Context, req *alexa.Request) ([]*alexa.Response, error) {
+	return e(ctx, req)
+}
+
+// SingleEvent adapts handler to an EventsHandler sending its response, if any, as the only event
+func SingleEvent(handler alexa.Handler) EventsHandlerFunc {
+	return func(ctx context.Context, req *alexa.Request) ([]*alexa.Response, error) {
+		resp, err := handler.HandleRequest(ctx, req)
+		if err != nil || resp == nil {
+			return nil, err
+		}
+		return []*alexa.Response{resp}, nil
+	}
+}
+
 // Handler coordinates handling a request and sending the response back to the smart home event api
 type Handler struct {
 	RequestHandler alexa.Handler
-	EventSender    EventSender
+	// EventsHandler is used instead of RequestHandler if set to send several events for a request
+	EventsHandler EventsHandler
+	EventSender   EventSender
+	// ErrorReporter is optionally notified of handler errors, panics and send failures.
+	ErrorReporter alexa.ErrorReporter
+	// Budget optionally limits handling so the events can still be sent before ctx's deadline
+	Budget *alexa.Budget
 }
 
 // HandleRequest passes the request to the RequestHandler. If response is returned it
-// is published via the EventSender. An error of type SendError indicates the request
-// was successful but the response failed to be sent.
-func (h *Handler) HandleRequest(ctx context.Context, req *alexa.Request) error {
-	resp, err := h.RequestHandler.HandleRequest(ctx, req)
+// is published via the EventSender. With an EventsHandler each returned event is
+// published in order, stopping at the first failure. An UnsentEventsError indicates
+// the request was successful but an event failed to be sent. A panic in
+// the handler is recovered and returned as an alexa.PanicError.
+func (h *Handler) HandleRequest(ctx context.Context, req *alexa.Request) (err error) {
+	defer h.recover(ctx, req, &err)
+
+	handler := h.EventsHandler
+	if handler == nil {
+		handler = SingleEvent(h.RequestHandler)
+	}
+
+	events, err := h.handle(ctx, handler, req)
 	if err != nil {
-		return fmt.Errorf("failed to handle request: %v", err)
+		return fmt.Errorf("failed to handle request: %w", err)
 	}
-	if resp == nil {
-		return nil
+
+	return h.SendEvents(ctx, events)
+}
+
+func (h *Handler) handle(ctx context.Context, handler EventsHandler, req *alexa.Request) ([]*alexa.Response, error) {
+	if h.Budget == nil {
+		return handler.HandleRequest(ctx, req)
+	}
+	handleCtx, cancel, err := h.Budget.Context(ctx, alexa.StageHandle)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	return handler.HandleRequest(handleCtx, req)
+}
+
+// Resend publishes the events left unsent by an earlier HandleRequest of req, see
+// UnsentEventsError. Like HandleRequest a panic is recovered and failures are reported
+// to the ErrorReporter.
+func (h *Handler) Resend(ctx context.Context, req *alexa.Request, events []*alexa.Response) (err error) {
+	defer h.recover(ctx, req, &err)
+	return h.SendEvents(ctx, events)
+}
+
+// recover converts a panic into a PanicError and reports the error, if any. It must
+// be deferred.
+func (h *Handler) recover(ctx context.Context, req *alexa.Request, err *error) {
+	if panicErr := alexa.RecoverPanic(recover()); panicErr != nil {
+		*err = panicErr
+	}
+	if *err != nil && h.ErrorReporter != nil {
+		h.ErrorReporter.ReportError(ctx, req, *err)
+	}
+}
+
+// SendEvents publishes events in order via the EventSender. If an event fails to send
+// an UnsentEventsError wrapping the EventSender's error is returned.
+func (h *Handler) SendEvents(ctx context.Context, events []*alexa.Response) error {
+	for i, event := range events {
+		if event == nil {
+			continue
+		}
+		if err := h.EventSender.Send(ctx, event); err != nil {
+			return &UnsentEventsError{Err: err, Events: events[i:]}
+		}
 	}
 
-	return h.EventSender.Send(ctx, resp)
+	return nil
 }
 
+// Event gateway urls for each region. The gateway for the region the user's
+// account is in must be used.
+const (
+	EventGatewayNorthAmerica = "https://api.amazonalexa.com/v3/events"
+	EventGatewayEurope       = "https://api.eu.amazonalexa.com/v3/events"
+	EventGatewayFarEast      = "https://api.fe.amazonalexa.com/v3/events"
+)
+
 // HTTPEventSender sends responses to the smart home api with the credentials of the user.
 type HTTPEventSender struct {
 	TokenStore   alexa.TokenReaderWriter
 	UserIDReader alexa.UserIDReader
-	ClientID     string
-	ClientSecret string
+	Credentials  alexa.CredentialsProvider
+	// Endpoint is the oauth endpoint used to refresh tokens. Defaults to Login with Amazon.
+	Endpoint oauth2.Endpoint
+	// EventGatewayURL defaults to EventGatewayNorthAmerica
+	EventGatewayURL string
+	// Retries is the number of additional attempts made when the event gateway
+	// can't be reached or responds with a 429 or 5xx status.
+	Retries int
+	// RetryDelay is the wait before the first retry. It doubles on each subsequent retry.
+	RetryDelay time.Duration
+	// Metrics optionally records gateway latency, status codes, retries and token refreshes.
+	Metrics alexa.Metrics
+	// Logger optionally records the outcome of each send.
+	Logger alexa.Logger
+	// HTTPClient is used for token refreshes and requests to the event gateway. Its
+	// transport is reused across sends so connections stay alive between events.
+	// Defaults to alexa.DefaultHTTPClient.
+	HTTPClient *http.Client
+	// OnTokenRevoked is optionally called when the user's refresh token has been
+	// permanently rejected, e.g. because the skill was disabled.
+	OnTokenRevoked func(ctx context.Context, userID string)
 }
 
 // Send responses to the smart home api with the credentials of the user.
 func (h *HTTPEventSender) Send(ctx context.Context, resp *alexa.Response) error {
-	respJSON, err := json.Marshal(resp)
+	start := time.Now()
+	err := h.send(ctx, resp)
+	h.metrics().Timing("event_sender.send", time.Since(start), "success:"+strconv.FormatBool(err == nil))
 	if err != nil {
-		return &SendError{fmt.Sprintf("failed to marshal response: %v", err)}
+		h.logger().Log(ctx, "event send failed",
+			"event", resp.Event.Header.Name,
+			"messageId", resp.Event.Header.MessageID,
+			"error", err)
+	} else {
+		h.logger().Log(ctx, "event sent",
+			"event", resp.Event.Header.Name,
+			"messageId", resp.Event.Header.MessageID,
+			"duration", time.Since(start))
 	}
+	return err
+}
 
-	profile, err := h.UserIDReader.Read(ctx, resp.Event.Endpoint.Scope.Token)
+func (h *HTTPEventSender) send(ctx context.Context, resp *alexa.Response) (err error) {
+	profile, err := h.userID(ctx, resp)
 	if err != nil {
-		return &SendError{fmt.Sprintf("failed to retrieve user id: %v", err)}
+		return &SendError{msg: fmt.Sprintf("failed to retrieve user id: %v", err), err: err, retryable: true}
 	}
 
 	token, err := h.TokenStore.Read(ctx, profile)
 	if err != nil {
-		return &SendError{fmt.Sprintf("failed to retrieve access token: %v", err)}
+		return &SendError{msg: fmt.Sprintf("failed to retrieve access token: %v", err), err: err, retryable: true}
 	}
 	if token == nil {
-		return &SendError{fmt.Sprintf("missing access token")}
+		return &SendError{msg: fmt.Sprintf("missing access token")}
 	}
 
-	eventReq, err := http.NewRequest(http.MethodPost, "https://api.amazonalexa.com/v3/events", bytes.NewReader(respJSON))
+	creds, err := h.Credentials.Credentials(ctx)
 	if err != nil {
-		return &SendError{fmt.Sprintf("failed to build event request: %v", err)}
+		return &SendError{msg: fmt.Sprintf("failed to load client credentials: %v", err), err: err, retryable: true}
 	}
 
-	eventReq = eventReq.WithContext(ctx)
-	eventReq.Header.Set("Content-Type", "application/json")
-	eventReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
-
 	oauth2Config := oauth2.Config{
-		ClientID:     h.ClientID,
-		ClientSecret: h.ClientSecret,
-		Endpoint:     amazon.Endpoint,
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		Endpoint:     alexa.OAuthEndpointOrDefault(h.Endpoint),
+	}
+
+	// the oauth2 client wraps the shared client's transport so connections are reused
+	oauthCtx := alexa.WithOAuthHTTPClient(ctx, h.HTTPClient)
+	tokenSniffer := &tokenSniffer{TokenSource: oauth2Config.TokenSource(oauthCtx, token)}
+	httpClient := oauth2.NewClient(oauthCtx, tokenSniffer)
+	// a refreshed token is stored even if the event isn't sent as the refresh token may
+	// have been rotated
+	defer func() {
+		if storeErr := h.storeRefreshed(ctx, profile, token, tokenSniffer.LastToken); storeErr != nil {
+			if err == nil {
+				err = storeErr
+				return
+			}
+			h.logger().Log(ctx, "refreshed token not stored", "userId", profile, "error", storeErr)
+		}
+	}()
+
+	// refresh up front so a scope holding the stored token, see UserScope, is sent with
+	// the refreshed one
+	current, err := tokenSniffer.Token()
+	if err != nil {
+		revoked := isTokenRevoked(err)
+		if revoked && h.OnTokenRevoked != nil {
+			h.OnTokenRevoked(ctx, profile)
+		}
+		return &SendError{msg: fmt.Sprintf("failed to refresh access token: %v", err), err: err,
+			retryable: ctx.Err() == nil && !revoked}
+	}
+	resp, err = refreshScope(resp, token.AccessToken, current.AccessToken)
+	if err != nil {
+		return &SendError{msg: fmt.Sprintf("failed to refresh scope: %v", err)}
+	}
+
+	respJSON, err := alexa.MarshalResponse(resp)
+	if err != nil {
+		return &SendError{msg: fmt.Sprintf("failed to marshal response: %v", err)}
+	}
+
+	delay := h.RetryDelay
+	for attempt := 0; ; attempt++ {
+		retryable, err := h.post(ctx, httpClient, respJSON)
+		if err == nil {
+			break
+		}
+		if isTokenRevoked(err) && h.OnTokenRevoked != nil {
+			h.OnTokenRevoked(ctx, profile)
+		}
+		if !retryable || attempt >= h.Retries {
+			var sendErr *SendError
+			if errors.As(err, &sendErr) {
+				sendErr.retryable = retryable
+			}
+			return err
+		}
+
+		h.metrics().Count("event_sender.retry", 1)
+		h.logger().Log(ctx, "retrying event send",
+			"messageId", resp.Event.Header.MessageID,
+			"attempt", attempt+1,
+			"error", err)
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return &SendError{msg: fmt.Sprintf("gave up retrying event request: %v", ctx.Err()), retryable: true}
+		}
+	}
+
+	return nil
+}
+
+// storeRefreshed stores refreshed for userID if it differs from the stored token. It's
+// written even if ctx is done so the refresh isn't lost.
+func (h *HTTPEventSender) storeRefreshed(ctx context.Context, userID string, stored, refreshed *oauth2.Token) error {
+	if refreshed == nil || refreshed.AccessToken == stored.AccessToken {
+		return nil
 	}
+	h.metrics().Count("event_sender.token_refresh", 1)
+	h.logger().Log(ctx, "access token refreshed", "userId", userID)
+	if err := h.TokenStore.Write(alexa.Detach(ctx), userID, refreshed); err != nil {
+		return fmt.Errorf("failed to update token: %v", err)
+	}
+	return nil
+}
 
-	tokenSniffer := &tokenSniffer{TokenSource: oauth2Config.TokenSource(ctx, token)}
-	httpClient := oauth2.NewClient(ctx, tokenSniffer)
+// post performs a single request to the event gateway. The returned bool indicates
+// if a failure may succeed when retried.
+func (h *HTTPEventSender) post(ctx context.Context, httpClient *http.Client, respJSON []byte) (bool, error) {
+	gatewayURL := h.EventGatewayURL
+	if gatewayURL == "" {
+		gatewayURL = EventGatewayNorthAmerica
+	}
+
+	eventReq, err := http.NewRequest(http.MethodPost, gatewayURL, bytes.NewReader(respJSON))
+	if err != nil {
+		return false, &SendError{msg: fmt.Sprintf("failed to build event request: %v", err)}
+	}
 
+	eventReq = eventReq.WithContext(ctx)
+	eventReq.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
 	eventResp, err := httpClient.Do(eventReq)
 	if err != nil {
-		return &SendError{fmt.Sprintf("failed to perform event request: %v", err)}
+		h.metrics().Count("event_sender.status", 1, "status:error")
+		retryable := ctx.Err() == nil && !isTokenRevoked(err)
+		return retryable, &SendError{msg: fmt.Sprintf("failed to perform event request: %v", err), err: err}
 	}
 	defer eventResp.Body.Close()
 
+	h.metrics().Timing("event_sender.gateway_latency", time.Since(start))
+	h.metrics().Count("event_sender.status", 1, "status:"+strconv.Itoa(eventResp.StatusCode))
+
 	body, err := ioutil.ReadAll(eventResp.Body)
 	if err != nil {
-		return &SendError{fmt.Sprintf("failed to read event body: %v", err)}
+		return true, &SendError{msg: fmt.Sprintf("failed to read event body: %v", err)}
 	}
 
 	if eventResp.StatusCode != http.StatusOK && eventResp.StatusCode != http.StatusAccepted {
-		return &SendError{fmt.Sprintf("event response unexpected status code: %s\n%s", eventResp.Status, body)}
+		retryable := eventResp.StatusCode == http.StatusTooManyRequests || eventResp.StatusCode >= http.StatusInternalServerError
+		return retryable, &SendError{msg: fmt.Sprintf("event response unexpected status code: %s\n%s", eventResp.Status, body)}
 	}
 
-	if tokenSniffer.LastToken != nil && token.AccessToken != tokenSniffer.LastToken.AccessToken {
-		if err := h.TokenStore.Write(ctx, profile, tokenSniffer.LastToken); err != nil {
-			return fmt.Errorf("failed to update token: %v", err)
-		}
+	return false, nil
+}
+
+// userID identifies the user the event is sent on behalf of. A user id placed in ctx
+// with alexa.WithUserID is preferred, otherwise the event's scope token is resolved
+// with the UserIDReader.
+func (h *HTTPEventSender) userID(ctx context.Context, resp *alexa.Response) (string, error) {
+	if userID, ok := alexa.UserIDFromContext(ctx); ok {
+		return userID, nil
 	}
 
-	return nil
+	if resp.Event.Endpoint != nil && resp.Event.Endpoint.Scope.Token != "" {
+		return h.UserIDReader.Read(ctx, resp.Event.Endpoint.Scope.Token)
+	}
+
+	// events without an endpoint, such as Discovery reports, carry the scope in the payload
+	var payload struct {
+		Scope alexa.Scope `json:"scope"`
+	}
+	if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil || payload.Scope.Token == "" {
+		return "", errors.New("event has no scope token")
+	}
+	return h.UserIDReader.Read(ctx, payload.Scope.Token)
+}
+
+func (h *HTTPEventSender) metrics() alexa.Metrics {
+	if h.Metrics == nil {
+		return alexa.NopMetrics{}
+	}
+	return h.Metrics
+}
+
+func (h *HTTPEventSender) logger() alexa.Logger {
+	if h.Logger == nil {
+		return alexa.NopLogger{}
+	}
+	return h.Logger
+}
+
+// isTokenRevoked reports if err is the result of the authorization server rejecting
+// the refresh token
+func isTokenRevoked(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+	return strings.Contains(string(retrieveErr.Body), "invalid_grant")
 }
 
 // SendError is an error sending to the smart home event api
 type SendError struct {
-	msg string
+	msg       string
+	err       error
+	retryable bool
 }
 
 func (r *SendError) Error() string {
 	return r.msg
 }
+
+// Unwrap returns the underlying cause of the error, if known
+func (r *SendError) Unwrap() error {
+	return r.err
+}
+
+// Retryable reports if sending again later may succeed, e.g. after a network failure
+// or a 429 or 5xx response from the event gateway.
+func (r *SendError) Retryable() bool {
+	return r.retryable
+}
+
+// NewSendError creates a SendError for EventSender implementations
+func NewSendError(err error, retryable bool) *SendError {
+	return &SendError{msg: err.Error(), err: err, retryable: retryable}
+}
+
+// UnsentEventsError is returned by Handler when an event fails to send. It holds the
+// events that weren't sent, starting with the failed one, so they can be sent again
+// with Handler.SendEvents without handling the request again.
+type UnsentEventsError struct {
+	Err    error
+	Events []*alexa.Response
+}
+
+func (u *UnsentEventsError) Error() string {
+	return u.Err.Error()
+}
+
+// Unwrap returns the send error
+func (u *UnsentEventsError) Unwrap() error {
+	return u.Err
+}
+
+// Disposition is what to do with a request whose handling failed
+type Disposition int
+
+// Dispositions
+const (
+	// Drop discards the request
+	Drop Disposition = iota
+	// RetryRequest handles the request again, repeating any device action
+	RetryRequest
+	// RetrySend sends the unsent events again without handling the request
+	RetrySend
+)
+
+// RequeuePolicy decides the Disposition of a request that failed with err
+type RequeuePolicy func(err error) Disposition
+
+// DefaultRequeuePolicy retries sending events that failed with a retryable SendError
+// and drops everything else so device actions are never repeated.
+func DefaultRequeuePolicy(err error) Disposition {
+	var sendErr *SendError
+	var unsent *UnsentEventsError
+	if errors.As(err, &unsent) && errors.As(err, &sendErr) && sendErr.Retryable() {
+		return RetrySend
+	}
+	return Drop
+}
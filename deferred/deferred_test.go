@@ -0,0 +1,546 @@
+package deferred
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"golang.org/x/oauth2"
+)
+
+func namedEvent(name string) *alexa.Response {
+	return &alexa.Response{Event: alexa.Event{Header: alexa.Header{Name: name}}}
+}
+
+func eventNames(events []*alexa.Response) []string {
+	var names []string
+	for _, event := range events {
+		names = append(names, event.Event.Header.Name)
+	}
+	return names
+}
+
+// failingSender records the events sent and fails to send the event named failOn
+type failingSender struct {
+	failOn string
+	sent   []string
+}
+
+func (f *failingSender) Send(ctx context.Context, resp *alexa.Response) error {
+	if resp.Event.Header.Name == f.failOn {
+		return NewSendError(errors.New("gateway unavailable"), true)
+	}
+	f.sent = append(f.sent, resp.Event.Header.Name)
+	return nil
+}
+
+func TestHandlerEvents(t *testing.T) {
+	tests := map[string]struct {
+		events     []*alexa.Response
+		handlerErr error
+		failOn     string
+		sent       []string
+		unsent     []string
+		err        bool
+	}{
+		"events sent in order": {
+			events: []*alexa.Response{namedEvent("WakeUp"), namedEvent("Response"), namedEvent("ChangeReport")},
+			sent:   []string{"WakeUp", "Response", "ChangeReport"},
+		},
+		"nil events skipped": {
+			events: []*alexa.Response{nil, namedEvent("Response"), nil},
+			sent:   []string{"Response"},
+		},
+		"no events": {},
+		"stops at first failure": {
+			events: []*alexa.Response{namedEvent("WakeUp"), namedEvent("Response"), namedEvent("ChangeReport")},
+			failOn: "Response",
+			sent:   []string{"WakeUp"},
+			unsent: []string{"Response", "ChangeReport"},
+			err:    true,
+		},
+		"handler error": {
+			events:     []*alexa.Response{namedEvent("Response")},
+			handlerErr: errors.New("device offline"),
+			err:        true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sender := &failingSender{failOn: test.failOn}
+			rec := &recorder{}
+			h := &Handler{
+				RequestHandler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+					t.Error("unexpected call to RequestHandler")
+					return nil, nil
+				}),
+				EventsHandler: EventsHandlerFunc(func(ctx context.Context, req *alexa.Request) ([]*alexa.Response, error) {
+					return test.events, test.handlerErr
+				}),
+				EventSender:   sender,
+				ErrorReporter: rec,
+			}
+
+			err := h.HandleRequest(context.Background(), lockRequest("Lock"))
+			if !reflect.DeepEqual(sender.sent, test.sent) {
+				t.Errorf("expected sent events %v but got %v", test.sent, sender.sent)
+			}
+			if !test.err {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if len(rec.errs) != 1 || rec.errs[0] != err {
+				t.Errorf("expected error to be reported but got %v", rec.errs)
+			}
+
+			var unsent *UnsentEventsError
+			if test.handlerErr != nil {
+				if !errors.Is(err, test.handlerErr) || errors.As(err, &unsent) {
+					t.Errorf("expected handler error but got %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &unsent) {
+				t.Fatalf("expected UnsentEventsError but got %v", err)
+			}
+			if names := eventNames(unsent.Events); !reflect.DeepEqual(names, test.unsent) {
+				t.Errorf("expected unsent events %v but got %v", test.unsent, names)
+			}
+			if DefaultRequeuePolicy(err) != RetrySend {
+				t.Errorf("expected unsent events to be resent")
+			}
+
+			sender.failOn = ""
+			if err := h.Resend(context.Background(), lockRequest("Lock"), unsent.Events); err != nil {
+				t.Fatalf("unexpected resend error: %v", err)
+			}
+			expected := append(append([]string{}, test.sent...), test.unsent...)
+			if !reflect.DeepEqual(sender.sent, expected) {
+				t.Errorf("expected sent events %v after resend but got %v", expected, sender.sent)
+			}
+		})
+	}
+}
+
+func TestHandlerSingleEvent(t *testing.T) {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-2" }}
+
+	tests := map[string]struct {
+		handler alexa.HandlerFunc
+		sent    []string
+	}{
+		"response sent": {
+			handler: func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+				return rb.BasicResponse(req), nil
+			},
+			sent: []string{"Response"},
+		},
+		"no response": {
+			handler: func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+				return nil, nil
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sender := &failingSender{}
+			h := &Handler{RequestHandler: test.handler, EventSender: sender}
+			if err := h.HandleRequest(context.Background(), lockRequest("Lock")); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(sender.sent, test.sent) {
+				t.Errorf("expected sent events %v but got %v", test.sent, sender.sent)
+			}
+		})
+	}
+}
+
+func TestHandlerRecoversPanic(t *testing.T) {
+	rec := &recorder{}
+	h := &Handler{
+		EventsHandler: EventsHandlerFunc(func(ctx context.Context, req *alexa.Request) ([]*alexa.Response, error) {
+			panic("boom")
+		}),
+		EventSender:   &failingSender{},
+		ErrorReporter: rec,
+	}
+
+	err := h.HandleRequest(context.Background(), lockRequest("Lock"))
+	var panicErr *alexa.PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+		t.Fatalf("expected PanicError but got %v", err)
+	}
+	if len(rec.errs) != 1 {
+		t.Errorf("expected panic to be reported but got %v", rec.errs)
+	}
+	if DefaultRequeuePolicy(err) != Drop {
+		t.Error("expected panic to be dropped")
+	}
+}
+
+func TestDefaultRequeuePolicy(t *testing.T) {
+	tests := map[string]struct {
+		err         error
+		disposition Disposition
+	}{
+		"retryable unsent events": {
+			err:         &UnsentEventsError{Err: NewSendError(errors.New("timeout"), true)},
+			disposition: RetrySend,
+		},
+		"permanent unsent events": {
+			err:         &UnsentEventsError{Err: NewSendError(errors.New("bad request"), false)},
+			disposition: Drop,
+		},
+		"retryable send without unsent events": {
+			err:         NewSendError(errors.New("timeout"), true),
+			disposition: Drop,
+		},
+		"handler error": {
+			err:         errors.New("device offline"),
+			disposition: Drop,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if d := DefaultRequeuePolicy(test.err); d != test.disposition {
+				t.Errorf("expected disposition %d but got %d", test.disposition, d)
+			}
+		})
+	}
+}
+
+// telemetry records the metrics and log messages of an HTTPEventSender
+type telemetry struct {
+	mu      sync.Mutex
+	metrics []string
+	logs    []string
+}
+
+func (m *telemetry) Count(name string, value int64, tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.metrics = append(m.metrics, fmt.Sprintf("%s:%d%v", name, value, tags))
+}
+
+func (m *telemetry) Timing(name string, d time.Duration, tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.metrics = append(m.metrics, fmt.Sprintf("%s%v", name, tags))
+}
+
+func (m *telemetry) Log(ctx context.Context, msg string, keyvals ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs = append(m.logs, msg)
+}
+
+type memoryTokenStore struct {
+	tokens map[string]*oauth2.Token
+}
+
+func (m *memoryTokenStore) Write(ctx context.Context, id string, token *oauth2.Token) error {
+	m.tokens[id] = token
+	return nil
+}
+
+func (m *memoryTokenStore) Read(ctx context.Context, id string) (*oauth2.Token, error) {
+	return m.tokens[id], nil
+}
+
+func TestHTTPEventSender(t *testing.T) {
+	valid := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}
+	expired := &oauth2.Token{AccessToken: "old-access", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)}
+
+	tests := map[string]struct {
+		token        *oauth2.Token
+		statuses     []int
+		refreshError bool
+		retries      int
+		attempts     int
+		retryable    bool
+		err          bool
+		revoked      bool
+		refreshed    bool
+		metrics      []string
+		logs         []string
+	}{
+		"sent": {
+			token:    valid,
+			statuses: []int{http.StatusAccepted},
+			attempts: 1,
+			metrics: []string{
+				"event_sender.gateway_latency[]",
+				"event_sender.status:1[status:202]",
+				"event_sender.send[success:true]",
+			},
+			logs: []string{"event sent"},
+		},
+		"transient failures retried": {
+			token:    valid,
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusAccepted},
+			retries:  2,
+			attempts: 3,
+			metrics: []string{
+				"event_sender.gateway_latency[]",
+				"event_sender.status:1[status:503]",
+				"event_sender.retry:1[]",
+				"event_sender.gateway_latency[]",
+				"event_sender.status:1[status:429]",
+				"event_sender.retry:1[]",
+				"event_sender.gateway_latency[]",
+				"event_sender.status:1[status:202]",
+				"event_sender.send[success:true]",
+			},
+			logs: []string{"retrying event send", "retrying event send", "event sent"},
+		},
+		"retries exhausted": {
+			token:     valid,
+			statuses:  []int{http.StatusInternalServerError, http.StatusInternalServerError},
+			retries:   1,
+			attempts:  2,
+			err:       true,
+			retryable: true,
+			metrics: []string{
+				"event_sender.gateway_latency[]",
+				"event_sender.status:1[status:500]",
+				"event_sender.retry:1[]",
+				"event_sender.gateway_latency[]",
+				"event_sender.status:1[status:500]",
+				"event_sender.send[success:false]",
+			},
+			logs: []string{"retrying event send", "event send failed"},
+		},
+		"rejected event not retried": {
+			token:    valid,
+			statuses: []int{http.StatusBadRequest},
+			retries:  2,
+			attempts: 1,
+			err:      true,
+			metrics: []string{
+				"event_sender.gateway_latency[]",
+				"event_sender.status:1[status:400]",
+				"event_sender.send[success:false]",
+			},
+			logs: []string{"event send failed"},
+		},
+		"token refreshed": {
+			token:     expired,
+			statuses:  []int{http.StatusAccepted},
+			attempts:  1,
+			refreshed: true,
+			metrics: []string{
+				"event_sender.gateway_latency[]",
+				"event_sender.status:1[status:202]",
+				"event_sender.token_refresh:1[]",
+				"event_sender.send[success:true]",
+			},
+			logs: []string{"access token refreshed", "event sent"},
+		},
+		"token refreshed but event rejected": {
+			token:     expired,
+			statuses:  []int{http.StatusInternalServerError},
+			attempts:  1,
+			err:       true,
+			retryable: true,
+			refreshed: true,
+			metrics: []string{
+				"event_sender.gateway_latency[]",
+				"event_sender.status:1[status:500]",
+				"event_sender.token_refresh:1[]",
+				"event_sender.send[success:false]",
+			},
+			logs: []string{"access token refreshed", "event send failed"},
+		},
+		"token revoked": {
+			token:        expired,
+			refreshError: true,
+			retries:      2,
+			err:          true,
+			revoked:      true,
+			metrics: []string{
+				"event_sender.send[success:false]",
+			},
+			logs: []string{"event send failed"},
+		},
+		"missing token": {
+			err: true,
+			metrics: []string{
+				"event_sender.send[success:false]",
+			},
+			logs: []string{"event send failed"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var attempts int
+			gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				expected := "Bearer access"
+				if test.refreshed {
+					expected = "Bearer new-access"
+				}
+				if auth := r.Header.Get("Authorization"); auth != expected {
+					t.Errorf("expected authorization %q, got %q", expected, auth)
+				}
+				var event alexa.Response
+				if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+					t.Errorf("failed to decode event: %v", err)
+				} else if scope := "Bearer " + event.Event.Endpoint.Scope.Token; scope != expected {
+					t.Errorf("expected the scope to hold the access token %q, got %q", expected, scope)
+				}
+				status := test.statuses[attempts]
+				attempts++
+				w.WriteHeader(status)
+			}))
+			defer gateway.Close()
+
+			oauthServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if test.refreshError {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, `{"error":"invalid_grant"}`)
+					return
+				}
+				fmt.Fprint(w, `{"access_token":"new-access","refresh_token":"refresh","token_type":"bearer","expires_in":3600}`)
+			}))
+			defer oauthServer.Close()
+
+			store := &memoryTokenStore{tokens: map[string]*oauth2.Token{}}
+			if test.token != nil {
+				store.tokens["user-1"] = test.token
+			}
+			tel := &telemetry{}
+			var revoked []string
+			sender := &HTTPEventSender{
+				TokenStore:      store,
+				Credentials:     alexa.ClientCredentials{ClientID: "client", ClientSecret: "secret"},
+				Endpoint:        oauth2.Endpoint{TokenURL: oauthServer.URL, AuthStyle: oauth2.AuthStyleInParams},
+				EventGatewayURL: gateway.URL,
+				Retries:         test.retries,
+				RetryDelay:      time.Millisecond,
+				Metrics:         tel,
+				Logger:          tel,
+				OnTokenRevoked: func(ctx context.Context, userID string) {
+					revoked = append(revoked, userID)
+				},
+			}
+
+			rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-2" }}
+			ctx := alexa.WithUserID(context.Background(), "user-1")
+			event := rb.BasicResponse(lockRequest("Lock"))
+			if test.token != nil {
+				event.Event.Endpoint.Scope, _ = UserScope(ctx, store, "user-1")
+			}
+			err := sender.Send(ctx, event)
+
+			if attempts != test.attempts {
+				t.Errorf("expected %d gateway attempts, got %d", test.attempts, attempts)
+			}
+			if test.err {
+				var sendErr *SendError
+				if !errors.As(err, &sendErr) {
+					t.Fatalf("expected SendError, got %v", err)
+				}
+				if sendErr.Retryable() != test.retryable {
+					t.Errorf("expected retryable %t, got %t", test.retryable, sendErr.Retryable())
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if fmt.Sprint(tel.metrics) != fmt.Sprint(test.metrics) {
+				t.Errorf("expected metrics:\n%v\ngot:\n%v", test.metrics, tel.metrics)
+			}
+			if fmt.Sprint(tel.logs) != fmt.Sprint(test.logs) {
+				t.Errorf("expected logs %v, got %v", test.logs, tel.logs)
+			}
+			if test.revoked != (len(revoked) == 1 && revoked[0] == "user-1") {
+				t.Errorf("unexpected revoked users: %v", revoked)
+			}
+			if test.refreshed && store.tokens["user-1"].AccessToken != "new-access" {
+				t.Errorf("expected refreshed token to be stored, got %+v", store.tokens["user-1"])
+			}
+		})
+	}
+}
+
+func TestRefreshScope(t *testing.T) {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	stale := alexa.Scope{Type: alexa.ScopeTypeBearerToken, Token: "old-access"}
+
+	tests := map[string]struct {
+		event func() (*alexa.Response, error)
+		// scope is the expected scope json of the sent event
+		scope string
+	}{
+		"endpoint scope": {
+			event: func() (*alexa.Response, error) {
+				return rb.DoorbellPressEvent(stale, "doorbell-1", alexa.CausePhysicalInteraction, time.Now())
+			},
+			scope: `{"type":"BearerToken","token":"new-access"}`,
+		},
+		"payload scope": {
+			event: func() (*alexa.Response, error) {
+				return rb.DeleteReport(stale, "lamp-1")
+			},
+			scope: `{"type":"BearerToken","token":"new-access"}`,
+		},
+		"other token kept": {
+			event: func() (*alexa.Response, error) {
+				return rb.DeleteReport(alexa.Scope{Type: alexa.ScopeTypeBearerToken, Token: "other"}, "lamp-1")
+			},
+			scope: `{"type":"BearerToken","token":"other"}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			event, err := test.event()
+			if err != nil {
+				t.Fatalf("failed to build event: %v", err)
+			}
+			original, err := json.Marshal(event)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+
+			refreshed, err := refreshScope(event, "old-access", "new-access")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var payload struct {
+				Scope json.RawMessage `json:"scope"`
+			}
+			if err := json.Unmarshal(refreshed.Event.Payload, &payload); err != nil {
+				t.Fatalf("failed to unmarshal payload: %v", err)
+			}
+			scope := payload.Scope
+			if refreshed.Event.Endpoint != nil {
+				scope, _ = json.Marshal(refreshed.Event.Endpoint.Scope)
+			}
+			if string(scope) != test.scope {
+				t.Errorf("expected scope %s, got %s", test.scope, scope)
+			}
+
+			if after, _ := json.Marshal(event); string(after) != string(original) {
+				t.Errorf("expected the original event to be unchanged, got %s", after)
+			}
+		})
+	}
+}
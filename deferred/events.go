@@ -0,0 +1,41 @@
+package deferred
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// SendProactiveEvent checks that event has the envelope of a proactive event, an endpoint
+// with a user's scope and no correlation token, and sends it with sender. Events answering
+// a directive should be sent with Handler instead.
+func SendProactiveEvent(ctx context.Context, sender EventSender, event *alexa.Response) error {
+	switch {
+	case event.Event.Header.CorrelationToken != "":
+		return errors.New("proactive event has a correlation token")
+	case event.Event.Endpoint == nil || event.Event.Endpoint.EndpointID == "":
+		return errors.New("proactive event has no endpoint")
+	case event.Event.Endpoint.Scope.Token == "":
+		if _, ok := alexa.UserIDFromContext(ctx); !ok {
+			return errors.New("proactive event has no scope token or user id")
+		}
+	}
+	if err := sender.Send(ctx, event); err != nil {
+		return fmt.Errorf("failed to send %s: %w", event.Event.Header.Name, err)
+	}
+	return nil
+}
+
+// SendDoorbellPress builds a DoorbellPress event for the doorbell pressed at timestamp and
+// sends it with sender
+func SendDoorbellPress(ctx context.Context, sender EventSender, rb *alexa.ResponseBuilder,
+	scope alexa.Scope, endpointID string, timestamp time.Time) error {
+	event, err := rb.DoorbellPressEvent(scope, endpointID, alexa.CausePhysicalInteraction, timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to build doorbell press: %w", err)
+	}
+	return SendProactiveEvent(ctx, sender, event)
+}
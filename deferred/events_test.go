@@ -0,0 +1,102 @@
+package deferred
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func TestSendProactiveEvent(t *testing.T) {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	scope := alexa.Scope{Type: alexa.ScopeTypeBearerToken, Token: "token"}
+	errSend := errors.New("gateway unavailable")
+
+	tests := map[string]struct {
+		ctx     context.Context
+		event   func(event *alexa.Response)
+		sendErr error
+		sent    bool
+	}{
+		"sent": {
+			sent: true,
+		},
+		"sent by user id": {
+			ctx:   alexa.WithUserID(context.Background(), "user-1"),
+			event: func(event *alexa.Response) { event.Event.Endpoint.Scope = alexa.Scope{} },
+			sent:  true,
+		},
+		"correlation token": {
+			event: func(event *alexa.Response) { event.Event.Header.CorrelationToken = "corr" },
+		},
+		"no endpoint": {
+			event: func(event *alexa.Response) { event.Event.Endpoint = nil },
+		},
+		"no scope or user id": {
+			event: func(event *alexa.Response) { event.Event.Endpoint.Scope = alexa.Scope{} },
+		},
+		"send failed": {
+			sendErr: errSend,
+			sent:    true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			event, err := rb.DoorbellPressEvent(scope, "doorbell-1", alexa.CausePhysicalInteraction, time.Now())
+			if err != nil {
+				t.Fatalf("failed to build event: %v", err)
+			}
+			if test.event != nil {
+				test.event(event)
+			}
+			ctx := test.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			var sent bool
+			err = SendProactiveEvent(ctx, EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+				sent = true
+				return test.sendErr
+			}), event)
+
+			if sent != test.sent {
+				t.Errorf("expected sent %t, got %t", test.sent, sent)
+			}
+			switch {
+			case test.sendErr != nil:
+				if !errors.Is(err, test.sendErr) {
+					t.Errorf("expected the send error to be wrapped, got %v", err)
+				}
+			case test.sent && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case !test.sent && err == nil:
+				t.Error("expected the event to be rejected")
+			}
+		})
+	}
+}
+
+func TestSendDoorbellPress(t *testing.T) {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "msg-1" }}
+	scope := alexa.Scope{Type: alexa.ScopeTypeBearerToken, Token: "token"}
+
+	var sent *alexa.Response
+	sender := EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+		sent = resp
+		return nil
+	})
+	if err := SendDoorbellPress(context.Background(), sender, rb, scope, "doorbell-1", time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sent == nil || sent.Event.Header.Name != alexa.EventDoorbellPress || sent.Event.Endpoint.EndpointID != "doorbell-1" {
+		t.Errorf("expected a doorbell press to be sent, got %+v", sent)
+	}
+
+	if err := SendDoorbellPress(context.Background(), sender, rb, scope, "", time.Now()); err == nil {
+		t.Error("expected a doorbell press without an endpoint to be rejected")
+	}
+}
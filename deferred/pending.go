@@ -0,0 +1,108 @@
+package deferred
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// PendingEvents holds the unsent events of messages that are waiting to be redelivered
+// by a queue so they can be sent without handling the request again. Messages that
+// aren't redelivered within TTL, e.g. because they moved to a dead letter queue, are
+// forgotten.
+//
+// The events are only held in memory so they're lost on restart and can't be taken by
+// another process. It's only suitable for a single consumer of the queue; otherwise a
+// redelivery that misses the pending events handles the request again, repeating the
+// device action.
+type PendingEvents struct {
+	// TTL defaults to 15m
+	TTL time.Duration
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+
+	mu     sync.Mutex
+	events map[string]pendingEntry
+	expiry []pendingKey
+}
+
+type pendingEntry struct {
+	events  []*alexa.Response
+	expires time.Time
+}
+
+type pendingKey struct {
+	messageID string
+	expires   time.Time
+}
+
+// Put holds the events of the message until it's redelivered
+func (p *PendingEvents) Put(messageID string, events []*alexa.Response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	p.expire(now)
+	if p.events == nil {
+		p.events = make(map[string]pendingEntry)
+	}
+	expires := now.Add(p.ttl())
+	p.events[messageID] = pendingEntry{events, expires}
+	p.expiry = append(p.expiry, pendingKey{messageID, expires})
+}
+
+// Take removes and returns the events held for the message
+func (p *PendingEvents) Take(messageID string) ([]*alexa.Response, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.events[messageID]
+	if !ok {
+		return nil, false
+	}
+	delete(p.events, messageID)
+	if !p.now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.events, true
+}
+
+// Len returns the number of messages with events held
+func (p *PendingEvents) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire(p.now())
+	return len(p.events)
+}
+
+// expire drops the entries that expired before now. Entries are added with the same
+// ttl so they expire in the order they were added and only expired ones are visited.
+func (p *PendingEvents) expire(now time.Time) {
+	n := 0
+	for ; n < len(p.expiry) && !now.Before(p.expiry[n].expires); n++ {
+		expired := p.expiry[n]
+		// the message may have been put again since
+		if entry, ok := p.events[expired.messageID]; ok && entry.expires.Equal(expired.expires) {
+			delete(p.events, expired.messageID)
+		}
+	}
+	if n > 0 {
+		p.expiry = append(p.expiry[:0], p.expiry[n:]...)
+	}
+}
+
+func (p *PendingEvents) ttl() time.Duration {
+	if p.TTL <= 0 {
+		return 15 * time.Minute
+	}
+	return p.TTL
+}
+
+func (p *PendingEvents) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,40 @@
+package deferred
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func TestPendingEvents(t *testing.T) {
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	pending := &PendingEvents{TTL: time.Minute, Now: func() time.Time { return now }}
+	events := []*alexa.Response{{Event: alexa.Event{Header: alexa.Header{Name: "Response"}}}}
+
+	pending.Put("msg-1", events)
+	if taken, ok := pending.Take("msg-1"); !ok || len(taken) != 1 {
+		t.Fatalf("expected the events to be taken: %v %t", taken, ok)
+	}
+	if _, ok := pending.Take("msg-1"); ok {
+		t.Error("expected events to only be taken once")
+	}
+
+	// messages that aren't redelivered are forgotten
+	pending.Put("msg-2", events)
+	now = now.Add(30 * time.Second)
+	pending.Put("msg-3", events)
+	now = now.Add(45 * time.Second)
+	if pending.Len() != 1 {
+		t.Errorf("expected msg-2 to expire, got %d pending", pending.Len())
+	}
+	if _, ok := pending.Take("msg-2"); ok {
+		t.Error("expected msg-2 to have expired")
+	}
+	if _, ok := pending.Take("msg-3"); !ok {
+		t.Error("expected msg-3 to still be pending")
+	}
+	if len(pending.expiry) != 1 {
+		t.Errorf("expected expired keys to be dropped, got %v", pending.expiry)
+	}
+}
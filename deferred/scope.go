@@ -0,0 +1,71 @@
+package deferred
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// ErrNoToken indicates no token is stored for the user
+var ErrNoToken = errors.New("no token")
+
+// UserScope returns a BearerToken scope for proactive events sent on behalf of userID
+// with the user's stored access token. The token may have expired by the time the event
+// is sent so HTTPEventSender replaces it with the refreshed token. An error wrapping
+// ErrNoToken is returned if the user has no token.
+func UserScope(ctx context.Context, tokens alexa.TokenReader, userID string) (alexa.Scope, error) {
+	token, err := tokens.Read(ctx, userID)
+	if err != nil {
+		return alexa.Scope{}, fmt.Errorf("failed to read token: %v", err)
+	}
+	if token == nil {
+		return alexa.Scope{}, fmt.Errorf("%w for user %s", ErrNoToken, userID)
+	}
+	return alexa.Scope{Type: alexa.ScopeTypeBearerToken, Token: token.AccessToken}, nil
+}
+
+// refreshScope returns resp with the stale access token in its scope replaced by
+// refreshed. resp isn't modified.
+func refreshScope(resp *alexa.Response, stale, refreshed string) (*alexa.Response, error) {
+	if stale == refreshed {
+		return resp, nil
+	}
+
+	if endpoint := resp.Event.Endpoint; endpoint != nil {
+		if endpoint.Scope.Token != stale {
+			return resp, nil
+		}
+		refreshedEndpoint := *endpoint
+		refreshedEndpoint.Scope.Token = refreshed
+		refreshedResp := *resp
+		refreshedResp.Event.Endpoint = &refreshedEndpoint
+		return &refreshedResp, nil
+	}
+
+	// events without an endpoint, such as Discovery reports, carry the scope in the payload
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil || payload["scope"] == nil {
+		return resp, nil
+	}
+	var scope alexa.Scope
+	if err := json.Unmarshal(payload["scope"], &scope); err != nil || scope.Token != stale {
+		return resp, nil
+	}
+	scope.Token = refreshed
+
+	scopeJSON, err := json.Marshal(scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal scope: %v", err)
+	}
+	payload["scope"] = scopeJSON
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	refreshedResp := *resp
+	refreshedResp.Event.Payload = payloadJSON
+	return &refreshedResp, nil
+}
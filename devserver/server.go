@@ -0,0 +1,312 @@
+package devserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/sample"
+)
+
+// Server emulates the Alexa smart home service for local development. Directives
+// posted to it are forwarded to Handler and the response is validated against the
+// smart home schema. Each exchange is pretty printed to Out.
+//
+// Routes:
+//
+//	POST /directive       send the raw directive in the request body
+//	GET  /samples         list the sample directives
+//	POST /samples/{name}  send a sample directive. The endpointId, token and value
+//	                      query parameters fill in the sample.
+//	POST /v3/events       emulates the event gateway. Point an HTTPEventSender's
+//	                      EventGatewayURL here to record proactive events.
+//	GET  /events          list recorded events. The after query parameter skips
+//	                      events up to and including that sequence number.
+type Server struct {
+	Handler alexa.Handler
+	// Out receives the pretty printed exchanges. Defaults to os.Stdout.
+	Out io.Writer
+
+	mu sync.Mutex
+
+	eventsMu sync.Mutex
+	events   []RecordedEvent
+}
+
+// Exchange is returned to the client for each directive sent
+type Exchange struct {
+	Request  *alexa.Request  `json:"request"`
+	Response *alexa.Response `json:"response,omitempty"`
+	// Error is set if the handler returned an error
+	Error string `json:"error,omitempty"`
+	// SchemaProblems lists the ways the response doesn't conform to the schema
+	SchemaProblems []string `json:"schemaProblems,omitempty"`
+}
+
+// RecordedEvent is an event posted to the emulated event gateway
+type RecordedEvent struct {
+	Seq   int             `json:"seq"`
+	Event *alexa.Response `json:"event"`
+	// SchemaProblems lists the ways the event doesn't conform to the schema
+	SchemaProblems []string `json:"schemaProblems,omitempty"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/directive":
+		s.serveDirective(w, r)
+	case r.URL.Path == "/v3/events":
+		s.serveGateway(w, r)
+	case r.URL.Path == "/events":
+		s.serveEvents(w, r)
+	case r.URL.Path == "/samples":
+		s.serveSamples(w, r)
+	case strings.HasPrefix(r.URL.Path, "/samples/"):
+		s.serveSample(w, r, strings.TrimPrefix(r.URL.Path, "/samples/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveDirective(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var req alexa.Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, fmt.Sprintf("failed to unmarshal directive: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	s.send(w, r, &req)
+}
+
+func (s *Server) serveSamples(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	samples := make(map[string]string)
+	for _, name := range sample.Names() {
+		samples[name] = sample.Describe(name)
+	}
+
+	writeJSON(w, samples)
+}
+
+func (s *Server) serveSample(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	params := sample.Params{
+		EndpointID: query.Get("endpointId"),
+		Token:      query.Get("token"),
+	}
+	if value := query.Get("value"); value != "" {
+		params.Value = sample.Value(value)
+	}
+
+	req, err := sample.Directive(name, params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.send(w, r, req)
+}
+
+func (s *Server) send(w http.ResponseWriter, r *http.Request, req *alexa.Request) {
+	exchange := Exchange{Request: req}
+
+	resp, err := s.Handler.HandleRequest(r.Context(), req)
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+	exchange.Response = resp
+
+	if resp != nil {
+		respJSON, err := json.Marshal(resp)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal response: %v", err), http.StatusInternalServerError)
+			return
+		}
+		exchange.SchemaProblems, err = schemaProblems(respJSON)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s.print(&exchange)
+	writeJSON(w, exchange)
+}
+
+func (s *Server) serveGateway(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var event alexa.Response
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, fmt.Sprintf("failed to unmarshal event: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	problems, err := schemaProblems(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.eventsMu.Lock()
+	recorded := RecordedEvent{
+		Seq:            len(s.events) + 1,
+		Event:          &event,
+		SchemaProblems: problems,
+	}
+	s.events = append(s.events, recorded)
+	s.eventsMu.Unlock()
+
+	s.printEvent(&recorded)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	after := 0
+	if afterParam := r.URL.Query().Get("after"); afterParam != "" {
+		var err error
+		after, err = strconv.Atoi(afterParam)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid after: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if after < 0 {
+		after = 0
+	}
+
+	s.eventsMu.Lock()
+	events := []RecordedEvent{}
+	if after < len(s.events) {
+		events = append(events, s.events[after:]...)
+	}
+	s.eventsMu.Unlock()
+
+	writeJSON(w, events)
+}
+
+// schemaProblems returns the ways respJSON doesn't conform to the smart home schema
+func schemaProblems(respJSON []byte) ([]string, error) {
+	err := alexa.ValidateResponse(respJSON)
+	var schemaErr *alexa.SchemaError
+	if errors.As(err, &schemaErr) {
+		return schemaErr.Problems, nil
+	}
+	return nil, err
+}
+
+// print writes the exchange to Out. Exchanges are serialized so concurrent
+// requests don't interleave.
+func (s *Server) print(exchange *Exchange) {
+	var buf bytes.Buffer
+
+	header := exchange.Request.Directive.Header
+	fmt.Fprintf(&buf, "=== %s.%s %s\n", header.Namespace, header.Name, exchange.Request.Directive.Endpoint.EndpointID)
+	fmt.Fprintln(&buf, "--- request")
+	writeIndented(&buf, exchange.Request)
+	if exchange.Response != nil {
+		fmt.Fprintln(&buf, "--- response")
+		writeIndented(&buf, exchange.Response)
+	}
+	if exchange.Error != "" {
+		fmt.Fprintf(&buf, "--- error\n%s\n", exchange.Error)
+	}
+	if exchange.Response != nil {
+		writeProblems(&buf, exchange.SchemaProblems)
+	}
+
+	s.write(buf.Bytes())
+}
+
+func (s *Server) printEvent(recorded *RecordedEvent) {
+	var buf bytes.Buffer
+
+	header := recorded.Event.Event.Header
+	fmt.Fprintf(&buf, "=== event %d %s.%s\n", recorded.Seq, header.Namespace, header.Name)
+	writeIndented(&buf, recorded.Event)
+	writeProblems(&buf, recorded.SchemaProblems)
+
+	s.write(buf.Bytes())
+}
+
+func (s *Server) write(data []byte) {
+	out := s.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out.Write(data)
+}
+
+func writeProblems(w io.Writer, problems []string) {
+	if len(problems) == 0 {
+		fmt.Fprintln(w, "--- schema validated")
+		return
+	}
+	fmt.Fprintln(w, "--- not valid")
+	for _, problem := range problems {
+		fmt.Fprintf(w, "- %s\n", problem)
+	}
+}
+
+func writeIndented(w io.Writer, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(w, "failed to marshal: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "%s\n", data)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, fmt.Sprintf("failed to marshal: %v", err), http.StatusInternalServerError)
+	}
+}
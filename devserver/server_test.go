@@ -0,0 +1,218 @@
+package devserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/sample"
+)
+
+func testServer(out *bytes.Buffer) *Server {
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "5f8a426e-01e4-4cc9-8b79-65f8bd0fd8a4" }}
+	return &Server{
+		Handler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+			switch req.Directive.Endpoint.EndpointID {
+			case "broken":
+				return nil, errors.New("device offline")
+			case "invalid":
+				return &alexa.Response{Event: alexa.Event{
+					Header:  alexa.Header{Namespace: alexa.NamespaceAlexa, Name: "Response"},
+					Payload: alexa.EmptyPayload,
+				}}, nil
+			}
+			return rb.BasicResponse(req), nil
+		}),
+		Out: out,
+	}
+}
+
+func serve(s *Server, method, target string, body []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(method, target, bytes.NewReader(body)))
+	return w
+}
+
+func TestServerDirective(t *testing.T) {
+	tests := map[string]struct {
+		endpointID string
+		errMsg     string
+		problems   bool
+		printed    string
+	}{
+		"valid response": {
+			endpointID: "lamp",
+			printed:    "--- schema validated",
+		},
+		"handler error": {
+			endpointID: "broken",
+			errMsg:     "device offline",
+			printed:    "--- error\ndevice offline",
+		},
+		"invalid response": {
+			endpointID: "invalid",
+			problems:   true,
+			printed:    "--- not valid",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			s := testServer(&out)
+
+			req, err := sample.Directive("turnon", sample.Params{EndpointID: test.endpointID})
+			if err != nil {
+				t.Fatalf("failed to build directive: %v", err)
+			}
+			reqJSON, err := json.Marshal(req)
+			if err != nil {
+				t.Fatalf("failed to marshal directive: %v", err)
+			}
+
+			w := serve(s, http.MethodPost, "/directive", reqJSON)
+			if w.Code != http.StatusOK {
+				t.Fatalf("unexpected status %d: %s", w.Code, w.Body)
+			}
+
+			var exchange Exchange
+			if err := json.Unmarshal(w.Body.Bytes(), &exchange); err != nil {
+				t.Fatalf("failed to unmarshal exchange: %v", err)
+			}
+			if exchange.Request.Directive.Endpoint.EndpointID != test.endpointID {
+				t.Errorf("unexpected request: %+v", exchange.Request)
+			}
+			if exchange.Error != test.errMsg {
+				t.Errorf("expected error %q, got %q", test.errMsg, exchange.Error)
+			}
+			if (test.errMsg == "") != (exchange.Response != nil) {
+				t.Errorf("unexpected response: %+v", exchange.Response)
+			}
+			if (len(exchange.SchemaProblems) > 0) != test.problems {
+				t.Errorf("unexpected schema problems: %v", exchange.SchemaProblems)
+			}
+			if !strings.Contains(out.String(), "=== Alexa.PowerController.TurnOn "+test.endpointID) ||
+				!strings.Contains(out.String(), test.printed) {
+				t.Errorf("expected %q to be printed:\n%s", test.printed, out.String())
+			}
+		})
+	}
+}
+
+func TestServerSamples(t *testing.T) {
+	var out bytes.Buffer
+	s := testServer(&out)
+
+	w := serve(s, http.MethodGet, "/samples", nil)
+	var samples map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &samples); err != nil {
+		t.Fatalf("failed to unmarshal samples: %v", err)
+	}
+	if len(samples) != len(sample.Names()) || samples["turnon"] == "" {
+		t.Errorf("unexpected samples: %v", samples)
+	}
+
+	w = serve(s, http.MethodPost, "/samples/setpercentage?endpointId=fan&token=abc&value=40", nil)
+	var exchange Exchange
+	if err := json.Unmarshal(w.Body.Bytes(), &exchange); err != nil {
+		t.Fatalf("failed to unmarshal exchange: %v", err)
+	}
+	directive := exchange.Request.Directive
+	if directive.Header.Name != "SetPercentage" || directive.Endpoint.EndpointID != "fan" ||
+		directive.Endpoint.Scope.Token != "abc" || string(directive.Payload) != `{"percentage":40}` {
+		t.Errorf("unexpected directive: %+v %s", directive, directive.Payload)
+	}
+	if exchange.Response == nil || len(exchange.SchemaProblems) != 0 {
+		t.Errorf("expected a valid response: %+v", exchange)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	tests := map[string]struct {
+		method string
+		target string
+		body   string
+		status int
+	}{
+		"directive method":  {http.MethodGet, "/directive", "", http.StatusMethodNotAllowed},
+		"invalid directive": {http.MethodPost, "/directive", "{", http.StatusBadRequest},
+		"samples method":    {http.MethodPost, "/samples", "", http.StatusMethodNotAllowed},
+		"unknown sample":    {http.MethodPost, "/samples/unknown", "", http.StatusBadRequest},
+		"gateway method":    {http.MethodGet, "/v3/events", "", http.StatusMethodNotAllowed},
+		"invalid event":     {http.MethodPost, "/v3/events", "{", http.StatusBadRequest},
+		"invalid after":     {http.MethodGet, "/events?after=x", "", http.StatusBadRequest},
+		"unknown path":      {http.MethodGet, "/unknown", "", http.StatusNotFound},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := serve(testServer(&out), test.method, test.target, []byte(test.body))
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestServerEvents(t *testing.T) {
+	var out bytes.Buffer
+	s := testServer(&out)
+	rb := &alexa.ResponseBuilder{MessageID: func() string { return "5f8a426e-01e4-4cc9-8b79-65f8bd0fd8a4" }}
+
+	for _, endpointID := range []string{"lamp", "fan"} {
+		req, err := sample.Directive("turnon", sample.Params{EndpointID: endpointID})
+		if err != nil {
+			t.Fatalf("failed to build directive: %v", err)
+		}
+		eventJSON, err := json.Marshal(rb.BasicResponse(req))
+		if err != nil {
+			t.Fatalf("failed to marshal event: %v", err)
+		}
+		if w := serve(s, http.MethodPost, "/v3/events", eventJSON); w.Code != http.StatusAccepted {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body)
+		}
+	}
+
+	tests := map[string]struct {
+		after     string
+		endpoints []string
+	}{
+		"all":          {endpoints: []string{"lamp", "fan"}},
+		"after":        {after: "1", endpoints: []string{"fan"}},
+		"negative":     {after: "-1", endpoints: []string{"lamp", "fan"}},
+		"caught up":    {after: "2"},
+		"past the end": {after: "5"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := serve(s, http.MethodGet, "/events?after="+test.after, nil)
+			var events []RecordedEvent
+			if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil {
+				t.Fatalf("failed to unmarshal events: %v", err)
+			}
+			if events == nil {
+				t.Fatal("expected an empty list rather than null")
+			}
+			if len(events) != len(test.endpoints) {
+				t.Fatalf("expected %d events, got %d", len(test.endpoints), len(events))
+			}
+			for i, event := range events {
+				if event.Event.Event.Endpoint.EndpointID != test.endpoints[i] || len(event.SchemaProblems) != 0 {
+					t.Errorf("unexpected event: %+v", event)
+				}
+			}
+		})
+	}
+
+	if !strings.Contains(out.String(), "=== event 2 Alexa.Response") {
+		t.Errorf("expected recorded events to be printed:\n%s", out.String())
+	}
+}
@@ -0,0 +1,224 @@
+package discovery
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// Diff describes how discovered endpoints changed between two discovery responses
+type Diff struct {
+	Added   []alexa.DiscoverEndpoint
+	Removed []alexa.DiscoverEndpoint
+	Changed []EndpointChange
+}
+
+// EndpointChange lists the changes to an endpoint present in both responses
+type EndpointChange struct {
+	EndpointID string
+	Changes    []string
+	// Breaking is set if a capability was removed. Routines using it will stop working.
+	Breaking bool
+}
+
+// Empty reports whether the responses discovered the same endpoints
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Breaking reports whether endpoints or capabilities were removed. Alexa deletes
+// removed endpoints along with any routines and groups that use them.
+func (d *Diff) Breaking() bool {
+	if len(d.Removed) > 0 {
+		return true
+	}
+	for _, change := range d.Changed {
+		if change.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Diff) String() string {
+	var b strings.Builder
+	for _, endpoint := range d.Removed {
+		fmt.Fprintf(&b, "- %s (%s)\n", endpoint.EndpointID, endpoint.FriendlyName)
+	}
+	for _, endpoint := range d.Added {
+		fmt.Fprintf(&b, "+ %s (%s)\n", endpoint.EndpointID, endpoint.FriendlyName)
+	}
+	for _, change := range d.Changed {
+		fmt.Fprintf(&b, "~ %s\n", change.EndpointID)
+		for _, c := range change.Changes {
+			fmt.Fprintf(&b, "    %s\n", c)
+		}
+	}
+	return b.String()
+}
+
+// Compare diffs the endpoints discovered before and after a change
+func Compare(before, after []alexa.DiscoverEndpoint) *Diff {
+	beforeByID := endpointsByID(before)
+	afterByID := endpointsByID(after)
+
+	diff := &Diff{}
+	for _, id := range sortedKeys(beforeByID) {
+		if _, ok := afterByID[id]; !ok {
+			diff.Removed = append(diff.Removed, beforeByID[id])
+		}
+	}
+	for _, id := range sortedKeys(afterByID) {
+		afterEndpoint := afterByID[id]
+		beforeEndpoint, ok := beforeByID[id]
+		if !ok {
+			diff.Added = append(diff.Added, afterEndpoint)
+			continue
+		}
+		if change := compareEndpoint(beforeEndpoint, afterEndpoint); len(change.Changes) > 0 {
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+
+	return diff
+}
+
+func compareEndpoint(before, after alexa.DiscoverEndpoint) EndpointChange {
+	change := EndpointChange{EndpointID: after.EndpointID}
+	add := func(format string, args ...interface{}) {
+		change.Changes = append(change.Changes, fmt.Sprintf(format, args...))
+	}
+
+	for _, field := range []struct{ name, before, after string }{
+		{"friendlyName", before.FriendlyName, after.FriendlyName},
+		{"description", before.Description, after.Description},
+		{"manufacturerName", before.ManufacturerName, after.ManufacturerName},
+		{"displayCategories", strings.Join(before.DisplayCategories, ","), strings.Join(after.DisplayCategories, ",")},
+	} {
+		if field.before != field.after {
+			add("%s: %q -> %q", field.name, field.before, field.after)
+		}
+	}
+	if !reflect.DeepEqual(before.Cookie, after.Cookie) {
+		add("cookie changed")
+	}
+	if !reflect.DeepEqual(before.AdditionalAttributes, after.AdditionalAttributes) {
+		add("additionalAttributes changed")
+	}
+	if !reflect.DeepEqual(before.Connections, after.Connections) {
+		add("connections changed")
+	}
+
+	beforeCaps := capabilitiesByName(before.Capabilities)
+	afterCaps := capabilitiesByName(after.Capabilities)
+	for _, name := range sortedKeys(beforeCaps) {
+		if _, ok := afterCaps[name]; !ok {
+			add("- capability %s", name)
+			change.Breaking = true
+		}
+	}
+	for _, name := range sortedKeys(afterCaps) {
+		afterCap := afterCaps[name]
+		beforeCap, ok := beforeCaps[name]
+		if !ok {
+			add("+ capability %s", name)
+			continue
+		}
+		for _, c := range compareCapability(beforeCap, afterCap) {
+			add("~ capability %s: %s", name, c)
+		}
+	}
+
+	return change
+}
+
+func compareCapability(before, after alexa.DiscoverCapability) []string {
+	var changes []string
+	if before.Version != after.Version {
+		changes = append(changes, fmt.Sprintf("version %s -> %s", before.Version, after.Version))
+	}
+
+	beforeProps, afterProps := before.Properties, after.Properties
+	if beforeProps == nil {
+		beforeProps = &alexa.DiscoverProperties{}
+	}
+	if afterProps == nil {
+		afterProps = &alexa.DiscoverProperties{}
+	}
+	if beforeNames, afterNames := propertyNames(beforeProps), propertyNames(afterProps); beforeNames != afterNames {
+		changes = append(changes, fmt.Sprintf("supported properties [%s] -> [%s]", beforeNames, afterNames))
+	}
+	if beforeProps.Retrievable != afterProps.Retrievable {
+		changes = append(changes, fmt.Sprintf("retrievable %t -> %t", beforeProps.Retrievable, afterProps.Retrievable))
+	}
+	if beforeProps.ProactivelyReported != afterProps.ProactivelyReported {
+		changes = append(changes, fmt.Sprintf("proactivelyReported %t -> %t",
+			beforeProps.ProactivelyReported, afterProps.ProactivelyReported))
+	}
+	if !reflect.DeepEqual(before.CapabilityResources, after.CapabilityResources) {
+		changes = append(changes, "capabilityResources changed")
+	}
+	if !bytes.Equal(before.Configuration, after.Configuration) {
+		changes = append(changes, "configuration changed")
+	}
+	if !bytes.Equal(before.Configurations, after.Configurations) {
+		changes = append(changes, "configurations changed")
+	}
+	if !reflect.DeepEqual(before.CameraStreamConfigurations, after.CameraStreamConfigurations) {
+		changes = append(changes, "cameraStreamConfigurations changed")
+	}
+	if !reflect.DeepEqual(before.SupportedOperations, after.SupportedOperations) {
+		changes = append(changes, fmt.Sprintf("supportedOperations %v -> %v", before.SupportedOperations, after.SupportedOperations))
+	}
+	if !reflect.DeepEqual(before.Keys, after.Keys) {
+		changes = append(changes, fmt.Sprintf("keys %v -> %v", before.Keys, after.Keys))
+	}
+	if !reflect.DeepEqual(before.Semantics, after.Semantics) {
+		changes = append(changes, "semantics changed")
+	}
+
+	return changes
+}
+
+func endpointsByID(endpoints []alexa.DiscoverEndpoint) map[string]alexa.DiscoverEndpoint {
+	byID := make(map[string]alexa.DiscoverEndpoint, len(endpoints))
+	for _, endpoint := range endpoints {
+		byID[endpoint.EndpointID] = endpoint
+	}
+	return byID
+}
+
+func capabilitiesByName(capabilities []alexa.DiscoverCapability) map[string]alexa.DiscoverCapability {
+	byName := make(map[string]alexa.DiscoverCapability, len(capabilities))
+	for _, capability := range capabilities {
+		name := capability.Interface
+		if capability.Instance != "" {
+			name += "." + capability.Instance
+		}
+		byName[name] = capability
+	}
+	return byName
+}
+
+func propertyNames(props *alexa.DiscoverProperties) string {
+	names := make([]string, 0, len(props.Supported))
+	for _, prop := range props.Supported {
+		names = append(names, prop.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := reflect.ValueOf(m).MapKeys()
+	sorted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, key.String())
+	}
+	sort.Strings(sorted)
+	return sorted
+}
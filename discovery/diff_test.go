@@ -0,0 +1,44 @@
+package discovery
+
+import (
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func TestCompare(t *testing.T) {
+	power := alexa.DiscoverCapability{Type: "AlexaInterface", Interface: alexa.InterfacePowerController, Version: "3"}
+	percentage := alexa.DiscoverCapability{Type: "AlexaInterface", Interface: alexa.InterfacePercentageController, Version: "3"}
+
+	before := []alexa.DiscoverEndpoint{
+		{EndpointID: "fan", FriendlyName: "Fan", Capabilities: []alexa.DiscoverCapability{power, percentage}},
+		{EndpointID: "lamp", FriendlyName: "Lamp", Capabilities: []alexa.DiscoverCapability{power}},
+	}
+
+	if diff := Compare(before, before); !diff.Empty() {
+		t.Fatalf("expected no changes, got:\n%s", diff)
+	}
+
+	after := []alexa.DiscoverEndpoint{
+		{EndpointID: "fan", FriendlyName: "Ceiling Fan", Capabilities: []alexa.DiscoverCapability{power}},
+		{EndpointID: "heater", FriendlyName: "Heater", Capabilities: []alexa.DiscoverCapability{power}},
+	}
+
+	diff := Compare(before, after)
+	expected := `- lamp (Lamp)
++ heater (Heater)
+~ fan
+    friendlyName: "Fan" -> "Ceiling Fan"
+    - capability Alexa.PercentageController
+`
+	if diff.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, diff)
+	}
+	if !diff.Breaking() {
+		t.Errorf("expected removals to be breaking")
+	}
+
+	if diff := Compare(before[1:], before); diff.Breaking() {
+		t.Errorf("expected additions not to be breaking")
+	}
+}
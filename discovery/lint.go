@@ -0,0 +1,488 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/sample"
+)
+
+// Severity enums
+const (
+	// SeverityError problems will cause discovery to fail or the endpoint to be dropped
+	SeverityError = "ERROR"
+	// SeverityWarning problems are likely to fail skill certification
+	SeverityWarning = "WARNING"
+)
+
+// Problem is a single issue found in a discovery response
+type Problem struct {
+	Severity string
+	// EndpointID is empty for problems with the response as a whole
+	EndpointID string
+	Message    string
+}
+
+func (p Problem) String() string {
+	if p.EndpointID == "" {
+		return fmt.Sprintf("%s: %s", p.Severity, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Severity, p.EndpointID, p.Message)
+}
+
+// HasErrors reports whether any of problems are errors
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// limits from the discovery api reference
+const (
+	maxEndpoints      = 300
+	maxEndpointIDLen  = 256
+	maxNameLen        = 128
+	maxCookieBytes    = 5000
+	maxCapabilities   = 100
+	defaultAPIVersion = "3"
+)
+
+var endpointIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_\-=#;:?@&]+$`)
+
+// knownDisplayCategories are the display categories Alexa accepts
+var knownDisplayCategories = map[string]bool{
+	"ACTIVITY_TRIGGER": true, "AIR_CONDITIONER": true, "AIR_FRESHENER": true, "AIR_PURIFIER": true,
+	"AIR_QUALITY_MONITOR": true, "ALEXA_VOICE_ENABLED": true, "AUTO_ACCESSORY": true,
+	"BLUETOOTH_SPEAKER": true, "CAMERA": true, "CHRISTMAS_TREE": true, "COFFEE_MAKER": true,
+	"COMPUTER": true, "CONTACT_SENSOR": true, "DASHCAM": true, "DISHWASHER": true, "DOOR": true,
+	"DOORBELL": true, "DRYER": true, "EXTERIOR_BLIND": true, "FAN": true, "GAME_CONSOLE": true,
+	"GARAGE_DOOR": true, "HEADPHONES": true, "HUB": true, "INTERIOR_BLIND": true, "LAPTOP": true,
+	"LIGHT": true, "MICROWAVE": true, "MOBILE_PHONE": true, "MOTION_SENSOR": true,
+	"MUSIC_SYSTEM": true, "NETWORK_HARDWARE": true, "OTHER": true, "OVEN": true, "PHONE": true,
+	"PRINTER": true, "REMOTE": true, "ROUTER": true, "SCENE_TRIGGER": true, "SCREEN": true,
+	"SECURITY_PANEL": true, "SECURITY_SYSTEM": true, "SLOW_COOKER": true, "SMARTLOCK": true,
+	"SMARTPLUG": true, "SPEAKER": true, "STREAMING_DEVICE": true, "SWITCH": true, "TABLET": true,
+	"TEMPERATURE_SENSOR": true, "THERMOSTAT": true, "TV": true, "VACUUM_CLEANER": true,
+	"VEHICLE": true, "WASHER": true, "WATER_HEATER": true, "WEARABLE": true,
+}
+
+// interfaceVersions are the versions of interfaces that aren't versioned with the api
+var interfaceVersions = map[string]string{
+	alexa.InterfaceCommissionable:                  "1.0",
+	alexa.InterfaceDataController:                  "1.0",
+	alexa.InterfaceDeviceUsageEstimation:           "1.0",
+	alexa.InterfaceDeviceUsageMeter:                "1.0",
+	alexa.InterfaceNetworkingAccessController:      "1.0",
+	alexa.InterfaceNetworkingConnectedDevice:       "1.0",
+	alexa.InterfaceNetworkingHomeNetworkController: "1.0",
+}
+
+// instanceInterfaces require an instance and, other than Alexa.DataController,
+// capabilityResources naming it
+var instanceInterfaces = map[string]bool{
+	alexa.InterfaceDataController:       true,
+	alexa.InterfaceInventoryLevelSensor: true,
+	alexa.InterfaceInventoryUsageSensor: true,
+	alexa.InterfaceModeController:       true,
+	"Alexa.RangeController":             true,
+	alexa.InterfaceToggleController:     true,
+}
+
+// semanticInterfaces support semantics mapping utterances to their instances
+var semanticInterfaces = map[string]bool{
+	alexa.InterfaceModeController:   true,
+	"Alexa.RangeController":         true,
+	alexa.InterfaceToggleController: true,
+}
+
+// sceneCategories don't represent a device so they don't report health
+var sceneCategories = map[string]bool{
+	alexa.DisplayCategoryActivityTrigger: true,
+	"SCENE_TRIGGER":                      true,
+}
+
+// Lint checks endpoints for spec violations and common certification failures
+func Lint(endpoints ...alexa.DiscoverEndpoint) []Problem {
+	var problems []Problem
+
+	if len(endpoints) > maxEndpoints {
+		problems = append(problems, Problem{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("%d endpoints exceeds the limit of %d", len(endpoints), maxEndpoints),
+		})
+	}
+
+	seen := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		if seen[endpoint.EndpointID] {
+			problems = append(problems, Problem{
+				Severity:   SeverityError,
+				EndpointID: endpoint.EndpointID,
+				Message:    "duplicate endpointId",
+			})
+		}
+		seen[endpoint.EndpointID] = true
+
+		problems = append(problems, LintEndpoint(endpoint)...)
+	}
+
+	return problems
+}
+
+// LintEndpoint checks a single endpoint. Unlike Lint it can't detect duplicate ids.
+func LintEndpoint(endpoint alexa.DiscoverEndpoint) []Problem {
+	var problems []Problem
+	add := func(severity, format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			Severity:   severity,
+			EndpointID: endpoint.EndpointID,
+			Message:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	switch {
+	case endpoint.EndpointID == "":
+		add(SeverityError, "missing endpointId")
+	case len(endpoint.EndpointID) > maxEndpointIDLen:
+		add(SeverityError, "endpointId longer than %d characters", maxEndpointIDLen)
+	case !endpointIDPattern.MatchString(endpoint.EndpointID):
+		add(SeverityError, "endpointId contains characters other than letters, numbers or _-=#;:?@&")
+	}
+
+	for _, field := range []struct{ name, value string }{
+		{"friendlyName", endpoint.FriendlyName},
+		{"description", endpoint.Description},
+		{"manufacturerName", endpoint.ManufacturerName},
+	} {
+		switch {
+		case strings.TrimSpace(field.value) == "":
+			add(SeverityError, "missing %s", field.name)
+		case len(field.value) > maxNameLen:
+			add(SeverityError, "%s longer than %d characters", field.name, maxNameLen)
+		}
+	}
+
+	if len(endpoint.DisplayCategories) == 0 {
+		add(SeverityError, "missing displayCategories")
+	}
+	for _, category := range endpoint.DisplayCategories {
+		if !knownDisplayCategories[category] {
+			add(SeverityError, "unknown display category %s", category)
+		}
+	}
+
+	cookieBytes := 0
+	for k, v := range endpoint.Cookie {
+		cookieBytes += len(k) + len(v)
+	}
+	if cookieBytes > maxCookieBytes {
+		add(SeverityError, "cookie is %d bytes, over the limit of %d", cookieBytes, maxCookieBytes)
+	}
+
+	if len(endpoint.Capabilities) == 0 {
+		add(SeverityError, "missing capabilities")
+		return problems
+	}
+	if len(endpoint.Capabilities) > maxCapabilities {
+		add(SeverityError, "%d capabilities exceeds the limit of %d", len(endpoint.Capabilities), maxCapabilities)
+	}
+
+	var hasAlexa, hasHealth, isScene bool
+	for _, category := range endpoint.DisplayCategories {
+		isScene = isScene || sceneCategories[category]
+	}
+
+	capabilities := make(map[string]bool)
+	for _, capability := range endpoint.Capabilities {
+		name := capability.Interface
+		if capability.Instance != "" {
+			name += "." + capability.Instance
+		}
+		if capabilities[name] {
+			add(SeverityError, "duplicate capability %s", name)
+		}
+		capabilities[name] = true
+
+		switch capability.Interface {
+		case alexa.NamespaceAlexa:
+			hasAlexa = true
+		case alexa.NamespaceEndpointHealth:
+			hasHealth = true
+		}
+
+		for _, p := range lintCapability(capability) {
+			add(p.Severity, "%s: %s", name, p.Message)
+		}
+	}
+
+	var hasMatter bool
+	for _, connection := range endpoint.Connections {
+		hasMatter = hasMatter || connection.Type == alexa.ConnectionTypeMatter
+		if msg := lintConnection(connection); msg != "" {
+			add(SeverityError, "%s connection: %s", connection.Type, msg)
+		}
+	}
+	if capabilities[alexa.InterfaceCommissionable] && !hasMatter {
+		add(SeverityWarning, "%s without a MATTER connection can't be matched to a device", alexa.InterfaceCommissionable)
+	}
+
+	if !hasAlexa {
+		add(SeverityWarning, "missing the Alexa interface capability")
+	}
+	if !hasHealth && !isScene {
+		add(SeverityWarning, "missing Alexa.EndpointHealth so connectivity can't be reported")
+	}
+
+	return problems
+}
+
+// lintConnection returns a message if connection lacks the fields required by its type
+func lintConnection(connection alexa.Connection) string {
+	switch connection.Type {
+	case alexa.ConnectionTypeMatter:
+		if connection.MatterDiscriminator == "" || connection.MatterVendorID == "" || connection.MatterProductID == "" {
+			return "requires matterDiscriminator, matterVendorId and matterProductId"
+		}
+	case alexa.ConnectionTypeTCPIP, alexa.ConnectionTypeZigbee:
+		if connection.MacAddress == "" {
+			return "requires macAddress"
+		}
+	case alexa.ConnectionTypeZWave:
+		if connection.HomeID == "" || connection.NodeID == "" {
+			return "requires homeId and nodeId"
+		}
+	case alexa.ConnectionTypeUnknown:
+		if connection.Value == "" {
+			return "requires value"
+		}
+	default:
+		return "unknown type"
+	}
+	return ""
+}
+
+// interfaceVersion returns the expected version of the interface
+func interfaceVersion(iface string) string {
+	if version, ok := interfaceVersions[iface]; ok {
+		return version
+	}
+	return defaultAPIVersion
+}
+
+func lintCapability(capability alexa.DiscoverCapability) []Problem {
+	var problems []Problem
+	add := func(severity, format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if capability.Type != "AlexaInterface" {
+		add(SeverityError, "unexpected type %q, expected AlexaInterface", capability.Type)
+	}
+	if capability.Interface == "" {
+		add(SeverityError, "missing interface")
+	}
+	if capability.Version == "" {
+		add(SeverityError, "missing version")
+	} else if capability.Interface != alexa.NamespaceEndpointHealth && capability.Version != interfaceVersion(capability.Interface) {
+		add(SeverityWarning, "unexpected version %s", capability.Version)
+	}
+
+	if instanceInterfaces[capability.Interface] {
+		if capability.Instance == "" {
+			add(SeverityError, "missing instance")
+		}
+		// data controller instances are described by their configuration rather than names
+		if capability.Interface == alexa.InterfaceDataController {
+			if len(capability.Configuration) == 0 {
+				add(SeverityError, "missing configuration")
+			}
+		} else if capability.CapabilityResources == nil || len(capability.CapabilityResources.FriendlyNames) == 0 {
+			add(SeverityError, "missing capabilityResources")
+		}
+	}
+	if capability.Interface == alexa.InterfaceCameraStreamController {
+		if len(capability.CameraStreamConfigurations) == 0 {
+			add(SeverityError, "missing cameraStreamConfigurations")
+		}
+		for i, config := range capability.CameraStreamConfigurations {
+			if err := config.Validate(); err != nil {
+				add(SeverityError, "cameraStreamConfigurations %d: %v", i, err)
+			}
+		}
+	}
+	if capability.Interface == alexa.InterfaceModeController {
+		var config alexa.ModeControllerConfiguration
+		if err := json.Unmarshal(capability.Configuration, &config); err != nil || len(config.SupportedModes) == 0 {
+			add(SeverityError, "missing configuration supportedModes")
+		}
+	}
+	if capability.Interface == alexa.InterfacePlaybackController {
+		if len(capability.SupportedOperations) == 0 {
+			add(SeverityError, "missing supportedOperations")
+		}
+		for _, operation := range capability.SupportedOperations {
+			if !alexa.IsPlaybackOperation(operation) {
+				add(SeverityError, "unknown supportedOperations value %q", operation)
+			}
+		}
+	}
+	if capability.Interface == alexa.InterfaceKeypadController {
+		if len(capability.Keys) == 0 {
+			add(SeverityError, "missing keys")
+		}
+		for _, key := range capability.Keys {
+			if !alexa.IsKey(key) {
+				add(SeverityError, "unknown keys value %q", key)
+			}
+		}
+	}
+	if capability.Semantics != nil {
+		if !semanticInterfaces[capability.Interface] {
+			add(SeverityError, "semantics are only supported by toggle, mode and range controllers")
+		}
+		for _, mapping := range capability.Semantics.ActionMappings {
+			if mapping.Type != alexa.MappingActionsToDirective || len(mapping.Actions) == 0 || mapping.Directive.Name == "" {
+				add(SeverityError, "action mapping requires ActionsToDirective type, actions and a directive name")
+			}
+		}
+		for _, mapping := range capability.Semantics.StateMappings {
+			switch {
+			case len(mapping.States) == 0:
+				add(SeverityError, "state mapping requires states")
+			case mapping.Type == alexa.MappingStatesToValue && len(mapping.Value) == 0:
+				add(SeverityError, "StatesToValue mapping requires a value")
+			case mapping.Type == alexa.MappingStatesToRange && mapping.Range == nil:
+				add(SeverityError, "StatesToRange mapping requires a range")
+			case mapping.Type != alexa.MappingStatesToValue && mapping.Type != alexa.MappingStatesToRange:
+				add(SeverityError, "unknown state mapping type %q", mapping.Type)
+			}
+		}
+	}
+	if capability.CapabilityResources != nil {
+		for _, name := range capability.CapabilityResources.FriendlyNames {
+			switch name.Type {
+			case alexa.FriendlyNameText:
+				if name.Value.Text == "" || name.Value.Locale == "" {
+					add(SeverityError, "text friendly name requires text and locale")
+				}
+			case alexa.FriendlyNameAsset:
+				if name.Value.AssetID == "" {
+					add(SeverityError, "asset friendly name requires assetId")
+				}
+			default:
+				add(SeverityError, "unknown friendly name type %q", name.Type)
+			}
+		}
+	}
+
+	if props := capability.Properties; props != nil {
+		if len(props.Supported) == 0 {
+			add(SeverityError, "properties missing supported properties")
+		}
+		if !props.Retrievable && !props.ProactivelyReported {
+			add(SeverityWarning, "properties are neither retrievable nor proactively reported")
+		}
+	}
+
+	return problems
+}
+
+// LintResponse lints the json of a Discover.Response event. A bare discovery payload or
+// array of endpoints is also accepted. Responses are also validated against the smart
+// home schema.
+func LintResponse(data []byte) ([]Problem, error) {
+	endpoints, isEvent, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	if isEvent {
+		problems, err = validate(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(problems, Lint(endpoints...)...), nil
+}
+
+// ParseEndpoints returns the endpoints in the json of a Discover.Response event,
+// discovery payload or array of endpoints
+func ParseEndpoints(data []byte) ([]alexa.DiscoverEndpoint, error) {
+	endpoints, _, err := parse(data)
+	return endpoints, err
+}
+
+// parse returns the endpoints in data and whether it is a Discover.Response event
+func parse(data []byte) ([]alexa.DiscoverEndpoint, bool, error) {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		var endpoints []alexa.DiscoverEndpoint
+		if err := json.Unmarshal(data, &endpoints); err != nil {
+			return nil, false, fmt.Errorf("failed to unmarshal endpoints: %v", err)
+		}
+		return endpoints, false, nil
+	}
+
+	var doc struct {
+		Event     *alexa.Event             `json:"event"`
+		Endpoints []alexa.DiscoverEndpoint `json:"endpoints"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal discovery json: %v", err)
+	}
+	if doc.Event == nil {
+		return doc.Endpoints, false, nil
+	}
+
+	var payload alexa.DiscoverPayload
+	if err := json.Unmarshal(doc.Event.Payload, &payload); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal discover payload: %v", err)
+	}
+	return payload.Endpoints, true, nil
+}
+
+// LintHandler sends a Discover directive to handler and lints the response.
+// token is used as the directive's bearer token.
+func LintHandler(ctx context.Context, handler alexa.Handler, token string) ([]Problem, error) {
+	req, err := sample.Directive("discover", sample.Params{Token: token})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := handler.HandleRequest(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("discovery handler failed: %v", err)
+	}
+
+	respJSON, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response: %v", err)
+	}
+
+	return LintResponse(respJSON)
+}
+
+func validate(respJSON []byte) ([]Problem, error) {
+	err := alexa.ValidateResponse(respJSON)
+	var schemaErr *alexa.SchemaError
+	if errors.As(err, &schemaErr) {
+		var problems []Problem
+		for _, problem := range schemaErr.Problems {
+			problems = append(problems, Problem{Severity: SeverityError, Message: "schema: " + problem})
+		}
+		return problems, nil
+	}
+	return nil, err
+}
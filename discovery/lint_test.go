@@ -0,0 +1,95 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func TestLint(t *testing.T) {
+	valid := alexa.DiscoverEndpoint{
+		EndpointID:        "switch-1",
+		FriendlyName:      "Fan",
+		Description:       "Power switch for fan",
+		ManufacturerName:  "McTofu",
+		DisplayCategories: []string{alexa.DisplayCategorySwitch},
+		Capabilities: []alexa.DiscoverCapability{
+			{Type: "AlexaInterface", Interface: alexa.NamespaceAlexa, Version: "3"},
+			{Type: "AlexaInterface", Interface: alexa.NamespaceEndpointHealth, Version: "3",
+				Properties: &alexa.DiscoverProperties{
+					Supported:   []alexa.DiscoverProperty{{Name: "connectivity"}},
+					Retrievable: true,
+				}},
+			{Type: "AlexaInterface", Interface: alexa.InterfacePowerController, Version: "3",
+				Properties: &alexa.DiscoverProperties{
+					Supported:   []alexa.DiscoverProperty{{Name: "powerState"}},
+					Retrievable: true,
+				}},
+		},
+	}
+
+	if problems := Lint(valid); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+
+	invalid := valid
+	invalid.EndpointID = "switch 1"
+	invalid.DisplayCategories = []string{"BLENDER"}
+	invalid.Capabilities = []alexa.DiscoverCapability{
+		{Type: "AlexaInterface", Interface: "Alexa.ToggleController", Version: "3", Instance: "Fan.Oscillate"},
+	}
+
+	var got []string
+	for _, p := range Lint(invalid) {
+		got = append(got, p.String())
+	}
+	expected := []string{
+		"ERROR: switch 1: endpointId contains characters other than letters, numbers or _-=#;:?@&",
+		"ERROR: switch 1: unknown display category BLENDER",
+		"ERROR: switch 1: Alexa.ToggleController.Fan.Oscillate: missing capabilityResources",
+		"WARNING: switch 1: missing the Alexa interface capability",
+		"WARNING: switch 1: missing Alexa.EndpointHealth so connectivity can't be reported",
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+
+	commissionable, err := alexa.NewCommissionableCapability(alexa.CommissioningProtocolMatter)
+	if err != nil {
+		t.Fatalf("failed to create capability: %v", err)
+	}
+	matter := valid
+	matter.Capabilities = append(append([]alexa.DiscoverCapability(nil), valid.Capabilities...), commissionable)
+	matter.Connections = []alexa.Connection{alexa.NewMatterConnection("3840", "65521", "")}
+
+	got = nil
+	for _, p := range Lint(matter) {
+		got = append(got, p.String())
+	}
+	expected = []string{
+		"ERROR: switch-1: MATTER connection: requires matterDiscriminator, matterVendorId and matterProductId",
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+
+	playback := valid
+	playback.Capabilities = append(append([]alexa.DiscoverCapability(nil), valid.Capabilities...),
+		alexa.NewPlaybackControllerCapability(alexa.PlaybackOperationPlay, "Eject"))
+
+	got = nil
+	for _, p := range Lint(playback) {
+		got = append(got, p.String())
+	}
+	expected = []string{
+		`ERROR: switch-1: Alexa.PlaybackController: unknown supportedOperations value "Eject"`,
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, got)
+	}
+
+	if problems := Lint(valid, valid); !HasErrors(problems) {
+		t.Errorf("expected duplicate endpoint error")
+	}
+}
@@ -0,0 +1,329 @@
+package energy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// PowerProfile describes the power draw of an endpoint's device
+type PowerProfile struct {
+	// StandbyWatts is drawn while the device is off
+	StandbyWatts float64
+	// OnWatts is drawn while the device is on at full level
+	OnWatts float64
+	// MinimumWatts is drawn while the device is on at the lowest level. Defaults to StandbyWatts.
+	MinimumWatts float64
+}
+
+// watts returns the draw in the state, with level scaling between MinimumWatts and OnWatts
+func (p PowerProfile) watts(on bool, level float64) float64 {
+	if !on {
+		return p.StandbyWatts
+	}
+	minimum := p.MinimumWatts
+	if minimum == 0 {
+		minimum = p.StandbyWatts
+	}
+	return minimum + (p.OnWatts-minimum)*level/100
+}
+
+// Usage is the usage of an endpoint accumulated since the start of its reporting period
+type Usage struct {
+	// Since is the start of the reporting period
+	Since time.Time `json:"since"`
+	// Updated is when the usage was last accumulated
+	Updated time.Time `json:"updated"`
+	On      bool      `json:"on"`
+	// Level is the percentage the device is on at, e.g. a light's brightness
+	Level       float64       `json:"level"`
+	OnDuration  time.Duration `json:"onDuration"`
+	OffDuration time.Duration `json:"offDuration"`
+	WattHours   float64       `json:"wattHours"`
+}
+
+// accumulate adds the usage in the current state up to now
+func (u *Usage) accumulate(profile PowerProfile, now time.Time) {
+	elapsed := now.Sub(u.Updated)
+	if elapsed <= 0 {
+		return
+	}
+	if u.On {
+		u.OnDuration += elapsed
+	} else {
+		u.OffDuration += elapsed
+	}
+	u.WattHours += profile.watts(u.On, u.Level) * elapsed.Hours()
+	u.Updated = now
+}
+
+// MeasurementsReporter publishes MeasurementsReports for an endpoint
+type MeasurementsReporter interface {
+	ReportMeasurements(ctx context.Context, endpointID string, measurements ...alexa.Measurement) error
+}
+
+// Storage persists the accumulated usage so estimates survive agent restarts
+type Storage interface {
+	Load(ctx context.Context) (map[string]Usage, error)
+	Save(ctx context.Context, usage map[string]Usage) error
+}
+
+// Estimator estimates the energy used by endpoints that can't measure it from the time
+// they spend on and off and their PowerProfile. Each Interval it sends the estimated
+// usage of every endpoint as an Alexa.DeviceUsage.Meter MeasurementsReport, so the
+// endpoints should be discovered with MeterConfiguration. Alexa.DeviceUsage.Estimation
+// is for Alexa estimating the usage itself, see alexa.NewEstimationCapability.
+type Estimator struct {
+	// Profiles maps endpoint ids to the power profile of their device
+	Profiles map[string]PowerProfile
+	Reporter MeasurementsReporter
+	// Storage optionally persists usage between reports
+	Storage Storage
+	// Interval between reports in Run. Defaults to 1h.
+	Interval time.Duration
+	// Logger optionally records failed reports
+	Logger alexa.Logger
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+
+	mu     sync.Mutex
+	usage  map[string]*Usage
+	loaded bool
+	// reporting serializes Report so a period isn't reported twice
+	reporting sync.Mutex
+}
+
+// MeterConfiguration returns the Alexa.DeviceUsage.Meter configuration of the endpoints
+// the estimator reports on, see alexa.NewMeterCapability
+func (e *Estimator) MeterConfiguration() alexa.MeterConfiguration {
+	config := alexa.ElectricityMeterConfiguration(e.interval())
+	config.EnergySources.Electricity.MeasuringMethod = alexa.MeasuringMethodIndirect
+	return config
+}
+
+// Record notes that the endpoint is now on or off at level percent. Usage is
+// accumulated in the previous state up to now.
+func (e *Estimator) Record(ctx context.Context, endpointID string, on bool, level float64) error {
+	profile, ok := e.Profiles[endpointID]
+	if !ok {
+		return fmt.Errorf("no power profile for %s", endpointID)
+	}
+	if level < 0 || level > 100 {
+		return fmt.Errorf("level %v of %s must be between 0 and 100", level, endpointID)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.load(ctx); err != nil {
+		return err
+	}
+
+	now := e.now()
+	usage, ok := e.usage[endpointID]
+	if !ok {
+		usage = &Usage{Since: now, Updated: now}
+		e.usage[endpointID] = usage
+	}
+	usage.accumulate(profile, now)
+	usage.On = on
+	usage.Level = level
+
+	return e.save(ctx)
+}
+
+// Report sends the usage of each recorded endpoint since its last report and starts
+// a new reporting period for the endpoints reported successfully. Usage can be recorded
+// while the reports are sent.
+func (e *Estimator) Report(ctx context.Context) error {
+	e.reporting.Lock()
+	defer e.reporting.Unlock()
+
+	pending, err := e.pendingReports(ctx)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	reported := make(map[string]Usage, len(pending))
+	for _, endpointID := range sortedKeys(pending) {
+		usage := pending[endpointID]
+		measurement := alexa.Measurement{
+			Type:      alexa.MeasurementTypeElectricEnergy,
+			Unit:      alexa.MeasurementUnitKilowattHours,
+			Value:     usage.WattHours / 1000,
+			StartTime: usage.Since,
+			EndTime:   usage.Updated,
+		}
+		if err := e.Reporter.ReportMeasurements(ctx, endpointID, measurement); err != nil {
+			e.logger().Log(ctx, "energy report failed", "endpointId", endpointID, "error", err)
+			failed = append(failed, endpointID)
+			continue
+		}
+		reported[endpointID] = usage
+	}
+
+	if err := e.startPeriods(ctx, reported); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to report energy usage of %v", failed)
+	}
+	return nil
+}
+
+// pendingReports accumulates the usage of each endpoint up to now and returns the
+// usage of those with a period to report
+func (e *Estimator) pendingReports(ctx context.Context) (map[string]Usage, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.load(ctx); err != nil {
+		return nil, err
+	}
+
+	now := e.now()
+	pending := make(map[string]Usage)
+	for endpointID, usage := range e.usage {
+		profile, ok := e.Profiles[endpointID]
+		if !ok {
+			// the endpoint was removed so its usage can't be reported
+			delete(e.usage, endpointID)
+			continue
+		}
+		usage.accumulate(profile, now)
+		if usage.Updated.After(usage.Since) {
+			pending[endpointID] = *usage
+		}
+	}
+	return pending, nil
+}
+
+// startPeriods removes the reported usage from each endpoint's accumulated usage, keeping
+// anything recorded since, and persists the result
+func (e *Estimator) startPeriods(ctx context.Context, reported map[string]Usage) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for endpointID, sent := range reported {
+		usage, ok := e.usage[endpointID]
+		if !ok {
+			continue
+		}
+		usage.Since = sent.Updated
+		usage.OnDuration -= sent.OnDuration
+		usage.OffDuration -= sent.OffDuration
+		if usage.Updated.After(sent.Updated) {
+			usage.WattHours -= sent.WattHours
+		} else {
+			// avoid carrying float rounding into the next period
+			usage.WattHours = 0
+		}
+	}
+
+	return e.save(ctx)
+}
+
+func sortedKeys(usage map[string]Usage) []string {
+	keys := make([]string, 0, len(usage))
+	for key := range usage {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Usage returns the usage of the endpoint accumulated up to now
+func (e *Estimator) Usage(ctx context.Context, endpointID string) (Usage, bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.load(ctx); err != nil {
+		return Usage{}, false, err
+	}
+	usage, ok := e.usage[endpointID]
+	if !ok {
+		return Usage{}, false, nil
+	}
+	current := *usage
+	current.accumulate(e.Profiles[endpointID], e.now())
+	return current, true, nil
+}
+
+// Run reports usage every Interval until ctx is done. Run can be added to an
+// agent.Agent as a component.
+func (e *Estimator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.Report(ctx); err != nil {
+				e.logger().Log(ctx, "energy report failed", "error", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// load reads the persisted usage the first time it's needed. Callers must hold mu.
+func (e *Estimator) load(ctx context.Context) error {
+	if e.loaded {
+		return nil
+	}
+	e.usage = make(map[string]*Usage)
+	if e.Storage != nil {
+		stored, err := e.Storage.Load(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load energy usage: %v", err)
+		}
+		for endpointID, usage := range stored {
+			usage := usage
+			e.usage[endpointID] = &usage
+		}
+	}
+	e.loaded = true
+	return nil
+}
+
+// save persists the usage. Callers must hold mu.
+func (e *Estimator) save(ctx context.Context) error {
+	if e.Storage == nil {
+		return nil
+	}
+	usage := make(map[string]Usage, len(e.usage))
+	for endpointID, u := range e.usage {
+		usage[endpointID] = *u
+	}
+	if err := e.Storage.Save(ctx, usage); err != nil {
+		return fmt.Errorf("failed to save energy usage: %v", err)
+	}
+	return nil
+}
+
+func (e *Estimator) interval() time.Duration {
+	if e.Interval <= 0 {
+		return time.Hour
+	}
+	return e.Interval
+}
+
+func (e *Estimator) now() time.Time {
+	if e.Now != nil {
+		return e.Now()
+	}
+	return time.Now()
+}
+
+func (e *Estimator) logger() alexa.Logger {
+	if e.Logger == nil {
+		return alexa.NopLogger{}
+	}
+	return e.Logger
+}
@@ -0,0 +1,172 @@
+package energy
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+	"golang.org/x/oauth2"
+)
+
+type recordingReporter struct {
+	reports []string
+}
+
+func (r *recordingReporter) ReportMeasurements(ctx context.Context, endpointID string, measurements ...alexa.Measurement) error {
+	for _, m := range measurements {
+		r.reports = append(r.reports, fmt.Sprintf("%s %.3f %s %s-%s", endpointID, m.Value, m.Unit,
+			m.StartTime.Format("15:04"), m.EndTime.Format("15:04")))
+	}
+	return nil
+}
+
+func TestEstimator(t *testing.T) {
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	storage := &FileStorage{Path: filepath.Join(t.TempDir(), "usage.json")}
+	reporter := &recordingReporter{}
+	newEstimator := func() *Estimator {
+		return &Estimator{
+			Profiles: map[string]PowerProfile{"light-1": {StandbyWatts: 0.5, OnWatts: 60}},
+			Reporter: reporter,
+			Storage:  storage,
+			Now:      func() time.Time { return now },
+		}
+	}
+
+	ctx := context.Background()
+	estimator := newEstimator()
+	if err := estimator.Record(ctx, "light-1", true, 100); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	now = now.Add(time.Hour)
+	if err := estimator.Record(ctx, "light-1", true, 50); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	if err := estimator.Record(ctx, "unknown", true, 50); err == nil {
+		t.Error("expected error for endpoint without a profile")
+	}
+
+	// a restarted estimator continues from the persisted usage
+	now = now.Add(2 * time.Hour)
+	estimator = newEstimator()
+	usage, ok, err := estimator.Usage(ctx, "light-1")
+	if err != nil || !ok {
+		t.Fatalf("expected usage: %v", err)
+	}
+	if usage.OnDuration != 3*time.Hour {
+		t.Errorf("expected 3h on, got %v", usage.OnDuration)
+	}
+
+	if err := estimator.Report(ctx); err != nil {
+		t.Fatalf("failed to report: %v", err)
+	}
+	now = now.Add(time.Hour)
+	if err := estimator.Record(ctx, "light-1", false, 0); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if err := estimator.Report(ctx); err != nil {
+		t.Fatalf("failed to report: %v", err)
+	}
+
+	expected := []string{
+		"light-1 0.120 KILOWATT_HOURS 12:00-15:00",
+		"light-1 0.031 KILOWATT_HOURS 15:00-18:00",
+	}
+	if !reflect.DeepEqual(expected, reporter.reports) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, reporter.reports)
+	}
+}
+
+type reporterFunc func(ctx context.Context, endpointID string, measurements ...alexa.Measurement) error
+
+func (f reporterFunc) ReportMeasurements(ctx context.Context, endpointID string, measurements ...alexa.Measurement) error {
+	return f(ctx, endpointID, measurements...)
+}
+
+func TestEstimatorRecordWhileReporting(t *testing.T) {
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	var reported []float64
+	estimator := &Estimator{
+		Profiles: map[string]PowerProfile{"light-1": {StandbyWatts: 0, OnWatts: 100}},
+		Now:      func() time.Time { return now },
+	}
+	estimator.Reporter = reporterFunc(func(ctx context.Context, endpointID string, measurements ...alexa.Measurement) error {
+		reported = append(reported, measurements[0].Value)
+		// recording doesn't wait for the report to be sent
+		now = now.Add(time.Hour)
+		return estimator.Record(ctx, endpointID, false, 0)
+	})
+
+	ctx := context.Background()
+	if err := estimator.Record(ctx, "light-1", true, 100); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	now = now.Add(time.Hour)
+	if err := estimator.Report(ctx); err != nil {
+		t.Fatalf("failed to report: %v", err)
+	}
+
+	// the hour on recorded during the report starts the next period
+	usage, ok, err := estimator.Usage(ctx, "light-1")
+	if err != nil || !ok {
+		t.Fatalf("expected usage: %v", err)
+	}
+	if !usage.Since.Equal(time.Date(2021, 2, 1, 13, 0, 0, 0, time.UTC)) || usage.OnDuration != time.Hour || usage.WattHours != 100 {
+		t.Errorf("unexpected usage after report: %+v", usage)
+	}
+	if !reflect.DeepEqual([]float64{0.1}, reported) {
+		t.Errorf("unexpected reports: %v", reported)
+	}
+}
+
+func TestEstimatorMeterConfiguration(t *testing.T) {
+	config := (&Estimator{Interval: 15 * time.Minute}).MeterConfiguration()
+	electricity := config.EnergySources.Electricity
+	if electricity.MeasuringMethod != alexa.MeasuringMethodIndirect ||
+		electricity.Unit != alexa.MeasurementUnitKilowattHours ||
+		electricity.DefaultMeasurementResolution != "PT15M" {
+		t.Errorf("unexpected configuration: %+v", electricity)
+	}
+}
+
+type tokenReaderFunc func(ctx context.Context, id string) (*oauth2.Token, error)
+
+func (f tokenReaderFunc) Read(ctx context.Context, id string) (*oauth2.Token, error) {
+	return f(ctx, id)
+}
+
+func TestEventMeasurementsReporter(t *testing.T) {
+	var sent *alexa.Response
+	reporter := &EventMeasurementsReporter{
+		EndpointUser: func(ctx context.Context, endpointID string) (string, error) { return "user-1", nil },
+		Tokens: tokenReaderFunc(func(ctx context.Context, id string) (*oauth2.Token, error) {
+			return &oauth2.Token{AccessToken: "token-" + id}, nil
+		}),
+		EventSender: deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+			sent = resp
+			return nil
+		}),
+		RespBuilder: &alexa.ResponseBuilder{MessageID: func() string { return "msg-1" }},
+	}
+
+	start := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	err := reporter.ReportMeasurements(context.Background(), "light-1", alexa.Measurement{
+		Type:      alexa.MeasurementTypeElectricEnergy,
+		Unit:      alexa.MeasurementUnitKilowattHours,
+		Value:     0.1,
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("failed to report: %v", err)
+	}
+	if sent.Event.Header.Namespace != alexa.NamespaceDeviceUsageMeter || sent.Event.Endpoint.Scope.Token != "token-user-1" {
+		t.Errorf("unexpected report: %+v", sent.Event)
+	}
+}
@@ -0,0 +1,53 @@
+package energy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+// EventMeasurementsReporter sends MeasurementsReports to the smart home api on behalf of
+// the user owning the endpoint.
+type EventMeasurementsReporter struct {
+	// Namespace of the reports. Defaults to Alexa.DeviceUsage.Meter, see Estimator.
+	Namespace string
+	// EndpointUser returns the id of the user owning the endpoint
+	EndpointUser func(ctx context.Context, endpointID string) (string, error)
+	Tokens       alexa.TokenReader
+	EventSender  deferred.EventSender
+	RespBuilder  *alexa.ResponseBuilder
+}
+
+// ReportMeasurements builds and sends a MeasurementsReport
+func (e *EventMeasurementsReporter) ReportMeasurements(ctx context.Context, endpointID string,
+	measurements ...alexa.Measurement) error {
+	userID, err := e.EndpointUser(ctx, endpointID)
+	if err != nil {
+		return fmt.Errorf("failed to find user for %s: %v", endpointID, err)
+	}
+
+	scope, err := deferred.UserScope(ctx, e.Tokens, userID)
+	if err != nil {
+		return err
+	}
+
+	report, err := e.RespBuilder.MeasurementsReport(scope, e.namespace(), endpointID, measurements...)
+	if err != nil {
+		return fmt.Errorf("failed to build measurements report: %v", err)
+	}
+
+	if err := e.EventSender.Send(alexa.WithUserID(ctx, userID), report); err != nil {
+		return fmt.Errorf("failed to send measurements report: %v", err)
+	}
+
+	return nil
+}
+
+func (e *EventMeasurementsReporter) namespace() string {
+	if e.Namespace == "" {
+		return alexa.NamespaceDeviceUsageMeter
+	}
+	return e.Namespace
+}
@@ -0,0 +1,64 @@
+package energy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileStorage persists usage as a json file at Path. It's intended for agents running
+// on a single host.
+type FileStorage struct {
+	Path string
+}
+
+func (f *FileStorage) Load(ctx context.Context) (map[string]Usage, error) {
+	content, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read usage file: %v", err)
+	}
+
+	var usage map[string]Usage
+	if err := json.Unmarshal(content, &usage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal usage: %v", err)
+	}
+	return usage, nil
+}
+
+func (f *FileStorage) Save(ctx context.Context, usage map[string]Usage) error {
+	content, err := json.Marshal(usage)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %v", err)
+	}
+
+	dir := filepath.Dir(f.Path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create usage dir: %v", err)
+	}
+
+	// write to a temp file first so a crash never leaves a partial file
+	tmp, err := ioutil.TempFile(dir, ".usage-")
+	if err != nil {
+		return fmt.Errorf("failed to create usage file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write usage file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write usage file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return fmt.Errorf("failed to write usage file: %v", err)
+	}
+
+	return nil
+}
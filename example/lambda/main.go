@@ -5,17 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"time"
 
 	awslambda "github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/mctofu/alexa-smart-home/alexa"
-	"github.com/mctofu/alexa-smart-home/aws/s3store"
 	"github.com/mctofu/alexa-smart-home/aws/sqsrelay"
+	"github.com/mctofu/alexa-smart-home/config"
 	"github.com/mctofu/alexa-smart-home/lambda"
 )
 
@@ -24,14 +21,14 @@ import (
 // with a canned response. Power controller requests return a deferred response
 // and publish a SQS message to allow the sqsagent to handle it remotely.
 func main() {
-	sqsQueueURL := os.Getenv("SQS_QUEUE_URL")
-	s3TokenBucket := os.Getenv("S3_TOKEN_BUCKET")
-	authClientID := os.Getenv("AUTH_CLIENT_ID")
-	authClientSecret := os.Getenv("AUTH_CLIENT_SECRET")
+	cfg, err := config.Load(os.Args[1:], "queueUrl")
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
 
-	session, err := session.NewSession()
+	session, err := cfg.Session()
 	if err != nil {
-		log.Fatalf("failed to init aws session: %v", err)
+		log.Fatal(err)
 	}
 
 	respBuilder := alexa.NewResponseBuilder()
@@ -41,32 +38,25 @@ func main() {
 	sqs := sqs.New(session)
 	sqsRelay := &sqsrelay.RelayHandler{
 		SQS:      sqs,
-		QueueURL: sqsQueueURL,
+		QueueURL: cfg.QueueURL,
 	}
 
-	s3Client := s3.New(session)
-	tokenStorage := &alexa.DebugTokenStore{
-		TokenStore: &s3store.TokenStorage{
-			S3:     s3Client,
-			Bucket: s3TokenBucket,
-		},
-	}
-	userIDReader := &alexa.ProfileUserIDReader{HTTPDoer: http.DefaultClient}
+	tokenStorage := cfg.NewTokenStore(session)
+	userIDReader := &alexa.ProfileUserIDReader{HTTPDoer: alexa.DefaultHTTPClient}
 
 	mux := alexa.NewNamespaceMux()
 	mux.HandleFunc(alexa.NamespacePercentageController, alexa.DeferredRelayHandler(sqsRelay, respBuilder))
 	mux.HandleFunc(alexa.NamespacePowerController, alexa.DeferredRelayHandler(sqsRelay, respBuilder))
 	mux.HandleFunc(alexa.NamespaceDiscovery, alexa.StaticDiscoveryHandler(respBuilder, endpoints()...))
-	mux.HandleFunc(alexa.NamespaceAlexa, tempReader.GetTemperature)
-	mux.HandleFunc(alexa.NamespaceAuthorization,
-		alexa.AuthorizationHandler(
-			authClientID,
-			authClientSecret,
-			userIDReader,
-			tokenStorage,
-			respBuilder))
-
-	awslambda.Start(lambda.DebugLambdaRequestHandler(mux))
+	mux.HandleReportState(alexa.HandlerFunc(tempReader.GetTemperature))
+	mux.HandleFunc(alexa.NamespaceAuthorization, (&alexa.AcceptGrantHandler{
+		Credentials:  cfg.NewCredentials(session),
+		UserIDReader: userIDReader,
+		TokenWriter:  tokenStorage,
+		RespBuilder:  respBuilder,
+	}).HandleRequest)
+
+	awslambda.Start(lambda.DebugRequestHandler(mux))
 }
 
 func endpoints() []alexa.DiscoverEndpoint {
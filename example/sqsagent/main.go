@@ -5,45 +5,36 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
-	"os/signal"
-	"sync"
-	"syscall"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/mctofu/alexa-smart-home/agent"
 	"github.com/mctofu/alexa-smart-home/alexa"
-	"github.com/mctofu/alexa-smart-home/aws/s3store"
 	"github.com/mctofu/alexa-smart-home/aws/sqsrelay"
+	"github.com/mctofu/alexa-smart-home/config"
 	"github.com/mctofu/alexa-smart-home/deferred"
+	"github.com/mctofu/alexa-smart-home/sentry"
 )
 
 // Listens on a SQS queue to remotely handle deferred power controller events
 // sent from the skill lambda
 func main() {
-	sqsQueueURL := os.Getenv("SQS_QUEUE_URL")
-	s3TokenBucket := os.Getenv("S3_TOKEN_BUCKET")
-	authClientID := os.Getenv("AUTH_CLIENT_ID")
-	authClientSecret := os.Getenv("AUTH_CLIENT_SECRET")
-
-	session, err := session.NewSession()
+	cfg, err := config.Load(os.Args[1:], "queueUrl")
 	if err != nil {
-		log.Fatalf("failed to init aws session: %v", err)
+		log.Fatalf("failed to load config: %v", err)
 	}
 
-	s3Client := s3.New(session)
-
-	tokenStorage := &alexa.DebugTokenStore{
-		TokenStore: &s3store.TokenStorage{
-			S3:     s3Client,
-			Bucket: s3TokenBucket,
-		},
+	session, err := cfg.Session()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	userIDReader := &alexa.ProfileUserIDReader{HTTPDoer: http.DefaultClient}
+	tokenStorage := cfg.NewTokenStore(session)
+
+	userIDReader := alexa.NewCachingUserIDReader(
+		&alexa.ProfileUserIDReader{HTTPDoer: alexa.DefaultHTTPClient},
+		time.Hour)
 
 	respBuilder := alexa.NewResponseBuilder()
 
@@ -60,64 +51,56 @@ func main() {
 			alexa.HandlerFunc(fanSwitch.TurnOn),
 			alexa.HandlerFunc(fanSwitch.TurnOff)))
 
-	requestHandler := mux
+	requestHandler := alexa.InvalidDirectiveHandler(respBuilder, mux)
 
 	eventSender := &deferred.HTTPEventSender{
-		TokenStore:   tokenStorage,
-		UserIDReader: userIDReader,
-		ClientID:     authClientID,
-		ClientSecret: authClientSecret,
+		TokenStore:      tokenStorage,
+		UserIDReader:    userIDReader,
+		Credentials:     cfg.NewCredentials(session),
+		EventGatewayURL: cfg.EventGatewayURL(),
+		Logger:          cfg.Logger(),
 	}
 
+	var sendHeartbeat agent.Heartbeat
 	deferredHandler := &deferred.Handler{
-		EventSender:    eventSender,
+		EventSender:    agent.SendHeartbeat(eventSender, &sendHeartbeat),
 		RequestHandler: alexa.DebugHandler(requestHandler),
 	}
+	if cfg.SentryDSN != "" {
+		deferredHandler.ErrorReporter = &sentry.Reporter{
+			DSN:      cfg.SentryDSN,
+			HTTPDoer: alexa.DefaultHTTPClient,
+		}
+	}
 
 	sqsClient := sqs.New(session)
 
+	var queueHeartbeat agent.Heartbeat
 	reader := &sqsrelay.QueueProcessor{
 		SQS:                  sqsClient,
-		QueueURL:             sqsQueueURL,
+		QueueURL:             cfg.QueueURL,
 		Handler:              deferredHandler,
 		QueueWaitTimeSeconds: 20,
+		OnReceive:            queueHeartbeat.Beat,
+		RequeuePolicy:        deferred.DefaultRequeuePolicy,
+		OnError: func(ctx context.Context, req *alexa.Request, err error) {
+			log.Printf("Failed to handle %s: %v", req.MessageID(), err)
+		},
 	}
 
-	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	var wg sync.WaitGroup
-	wg.Add(1)
-
-	go func() {
-		defer wg.Done()
-		for {
-			if err := reader.Process(ctx); err != nil {
-				if ctx.Err() != nil {
-					log.Printf("Terminating: %v", err)
-					break
-				}
-				log.Printf("Failed to process queue: %v", err)
-				delay := time.After(time.Duration(reader.QueueWaitTimeSeconds) * time.Second)
-				select {
-				case <-delay:
-					continue
-				case <-ctx.Done():
-					continue
-				}
-			}
-		}
-	}()
-
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	select {
-	case <-c:
-		cancel()
+	a := &agent.Agent{
+		MaxBackoff: time.Duration(reader.QueueWaitTimeSeconds) * time.Second,
+		HealthAddr: cfg.HealthAddr,
+		Logger:     cfg.Logger(),
 	}
+	a.Add("sqs", agent.Loop(reader.Process))
+	a.AddHeartbeat("event_send", &sendHeartbeat)
+	a.AddHeartbeat("queue_receive", &queueHeartbeat)
+	a.AddCheck("queue", queueHeartbeat.Check(3*time.Duration(reader.QueueWaitTimeSeconds)*time.Second))
 
-	wg.Wait()
+	if err := a.RunUntilSignal(context.Background()); err != nil {
+		log.Fatalf("failed to stop agent: %v", err)
+	}
 }
 
 type fanSwitch struct {
@@ -182,7 +165,7 @@ func (w *windowControl) AdjustPercentage(ctx context.Context, req *alexa.Request
 	}), nil
 }
 
-func (w *windowControl) marshalValue(val uint8) json.RawMessage {
+func (w *windowControl) marshalValue(val int) json.RawMessage {
 	jsonVal, err := json.Marshal(val)
 	if err != nil {
 		panic(fmt.Sprintf("unexpected error: %v", err))
@@ -0,0 +1,304 @@
+package infra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+// Config declares the AWS resources a skill needs
+type Config struct {
+	// Name prefixes the names of the generated resources
+	Name string `json:"name"`
+	// SkillID is the skill allowed to invoke the lambda
+	SkillID string `json:"skillId"`
+	// Regions to deploy the skill lambda to. Each must be a region Alexa invokes smart
+	// home skills in. Defaults to us-east-1.
+	Regions []string `json:"regions"`
+	// TokenStore is s3 or dynamodb
+	TokenStore string `json:"tokenStore"`
+	// KMSKeyID encrypts the token store and queue with a customer managed key.
+	// AWS managed keys are used if it is empty.
+	KMSKeyID string `json:"kmsKeyId"`
+	// Queue relays directives to an agent. No queue is created if it is nil.
+	Queue *QueueConfig `json:"queue"`
+	// Handler and CodeURI locate the lambda's code. Default to "main" and "./lambda".
+	Handler string `json:"handler"`
+	CodeURI string `json:"codeUri"`
+}
+
+// QueueConfig declares the SQS FIFO queue directives are relayed through
+type QueueConfig struct {
+	// VisibilityTimeout in seconds. Defaults to 30.
+	VisibilityTimeout int `json:"visibilityTimeout"`
+	// MaxReceiveCount moves messages to a dead letter queue after this many receives.
+	// No dead letter queue is created if it is 0.
+	MaxReceiveCount int `json:"maxReceiveCount"`
+}
+
+// TokenStore enums
+const (
+	TokenStoreS3       = "s3"
+	TokenStoreDynamoDB = "dynamodb"
+)
+
+// regionGateways maps the regions Alexa invokes smart home lambdas in to the
+// event gateway for that region
+var regionGateways = map[string]string{
+	"us-east-1": deferred.EventGatewayNorthAmerica,
+	"eu-west-1": deferred.EventGatewayEurope,
+	"us-west-2": deferred.EventGatewayFarEast,
+}
+
+// LoadConfig parses a json Config
+func LoadConfig(data []byte) (*Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %v", err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	if c.SkillID == "" {
+		return errors.New("skillId is required")
+	}
+	switch c.TokenStore {
+	case TokenStoreS3, TokenStoreDynamoDB:
+	default:
+		return fmt.Errorf("unknown tokenStore: %q", c.TokenStore)
+	}
+	for _, region := range c.regions() {
+		if _, ok := regionGateways[region]; !ok {
+			return fmt.Errorf("alexa doesn't invoke smart home skills in %s", region)
+		}
+	}
+	return nil
+}
+
+func (c *Config) regions() []string {
+	if len(c.Regions) == 0 {
+		return []string{"us-east-1"}
+	}
+	return c.Regions
+}
+
+// Template is a CloudFormation template using the SAM transform
+type Template map[string]interface{}
+
+// Templates generates a template for each of the config's regions keyed by region
+func Templates(cfg *Config) (map[string]Template, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	templates := make(map[string]Template)
+	for _, region := range cfg.regions() {
+		templates[region] = regionTemplate(cfg, region)
+	}
+	return templates, nil
+}
+
+func regionTemplate(cfg *Config, region string) Template {
+	resources := make(map[string]interface{})
+	outputs := make(map[string]interface{})
+
+	env := map[string]interface{}{
+		"AUTH_CLIENT_ID":     ref("AuthClientID"),
+		"AUTH_CLIENT_SECRET": ref("AuthClientSecret"),
+		"EVENT_GATEWAY_URL":  regionGateways[region],
+	}
+	var policies []interface{}
+
+	switch cfg.TokenStore {
+	case TokenStoreS3:
+		resources["TokenBucket"] = tokenBucket(cfg)
+		env["S3_TOKEN_BUCKET"] = ref("TokenBucket")
+		policies = append(policies, map[string]interface{}{
+			"S3CrudPolicy": map[string]interface{}{"BucketName": ref("TokenBucket")},
+		})
+		outputs["TokenBucket"] = output(ref("TokenBucket"))
+	case TokenStoreDynamoDB:
+		resources["TokenTable"] = tokenTable(cfg)
+		env["DYNAMODB_TOKEN_TABLE"] = ref("TokenTable")
+		policies = append(policies, map[string]interface{}{
+			"DynamoDBCrudPolicy": map[string]interface{}{"TableName": ref("TokenTable")},
+		})
+		outputs["TokenTable"] = output(ref("TokenTable"))
+	}
+
+	if cfg.Queue != nil {
+		resources["DirectiveQueue"] = queue(cfg, resources)
+		env["SQS_QUEUE_URL"] = ref("DirectiveQueue")
+		policies = append(policies, map[string]interface{}{
+			"SQSSendMessagePolicy": map[string]interface{}{
+				"QueueName": getAtt("DirectiveQueue", "QueueName"),
+			},
+		})
+		outputs["DirectiveQueueURL"] = output(ref("DirectiveQueue"))
+	}
+
+	if cfg.KMSKeyID != "" {
+		policies = append(policies, map[string]interface{}{
+			"KMSDecryptPolicy": map[string]interface{}{"KeyId": cfg.KMSKeyID},
+		})
+	}
+
+	handler := cfg.Handler
+	if handler == "" {
+		handler = "main"
+	}
+	codeURI := cfg.CodeURI
+	if codeURI == "" {
+		codeURI = "./lambda"
+	}
+
+	resources["SkillFunction"] = resource("AWS::Serverless::Function", map[string]interface{}{
+		"FunctionName": fmt.Sprintf("%s-skill", cfg.Name),
+		"Runtime":      "go1.x",
+		"Handler":      handler,
+		"CodeUri":      codeURI,
+		"Timeout":      8,
+		"Environment":  map[string]interface{}{"Variables": env},
+		"Policies":     policies,
+	})
+	resources["SkillPermission"] = resource("AWS::Lambda::Permission", map[string]interface{}{
+		"Action":           "lambda:InvokeFunction",
+		"FunctionName":     getAtt("SkillFunction", "Arn"),
+		"Principal":        "alexa-connectedhome.amazon.com",
+		"EventSourceToken": cfg.SkillID,
+	})
+	outputs["SkillFunctionArn"] = output(getAtt("SkillFunction", "Arn"))
+
+	return Template{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Transform":                "AWS::Serverless-2016-10-31",
+		"Description":              fmt.Sprintf("%s smart home skill (%s)", cfg.Name, region),
+		"Parameters": map[string]interface{}{
+			"AuthClientID": map[string]interface{}{
+				"Type":        "String",
+				"Description": "Alexa skill messaging client id",
+			},
+			"AuthClientSecret": map[string]interface{}{
+				"Type":        "String",
+				"Description": "Alexa skill messaging client secret",
+				"NoEcho":      true,
+			},
+		},
+		"Resources": resources,
+		"Outputs":   outputs,
+	}
+}
+
+func tokenBucket(cfg *Config) map[string]interface{} {
+	encryption := map[string]interface{}{"SSEAlgorithm": "AES256"}
+	if cfg.KMSKeyID != "" {
+		encryption = map[string]interface{}{
+			"SSEAlgorithm":   "aws:kms",
+			"KMSMasterKeyID": cfg.KMSKeyID,
+		}
+	}
+
+	return resource("AWS::S3::Bucket", map[string]interface{}{
+		"BucketEncryption": map[string]interface{}{
+			"ServerSideEncryptionConfiguration": []interface{}{
+				map[string]interface{}{"ServerSideEncryptionByDefault": encryption},
+			},
+		},
+		"PublicAccessBlockConfiguration": map[string]interface{}{
+			"BlockPublicAcls":       true,
+			"BlockPublicPolicy":     true,
+			"IgnorePublicAcls":      true,
+			"RestrictPublicBuckets": true,
+		},
+	})
+}
+
+// tokenTable matches the schema expected by dynamostore
+func tokenTable(cfg *Config) map[string]interface{} {
+	sse := map[string]interface{}{"SSEEnabled": true}
+	if cfg.KMSKeyID != "" {
+		sse["SSEType"] = "KMS"
+		sse["KMSMasterKeyId"] = cfg.KMSKeyID
+	}
+
+	return resource("AWS::DynamoDB::Table", map[string]interface{}{
+		"TableName":   fmt.Sprintf("%s-tokens", cfg.Name),
+		"BillingMode": "PAY_PER_REQUEST",
+		"AttributeDefinitions": []interface{}{
+			map[string]interface{}{"AttributeName": "UserID", "AttributeType": "S"},
+		},
+		"KeySchema": []interface{}{
+			map[string]interface{}{"AttributeName": "UserID", "KeyType": "HASH"},
+		},
+		"SSESpecification": sse,
+		"PointInTimeRecoverySpecification": map[string]interface{}{
+			"PointInTimeRecoveryEnabled": true,
+		},
+	})
+}
+
+// queue returns the directive queue, adding a dead letter queue to resources if configured
+func queue(cfg *Config, resources map[string]interface{}) map[string]interface{} {
+	visibilityTimeout := cfg.Queue.VisibilityTimeout
+	if visibilityTimeout == 0 {
+		visibilityTimeout = 30
+	}
+
+	props := map[string]interface{}{
+		"QueueName":                 fmt.Sprintf("%s-directives.fifo", cfg.Name),
+		"FifoQueue":                 true,
+		"ContentBasedDeduplication": false,
+		"VisibilityTimeout":         visibilityTimeout,
+	}
+	encrypt(cfg, props)
+
+	if cfg.Queue.MaxReceiveCount > 0 {
+		dlqProps := map[string]interface{}{
+			"QueueName":              fmt.Sprintf("%s-directives-dlq.fifo", cfg.Name),
+			"FifoQueue":              true,
+			"MessageRetentionPeriod": 1209600,
+		}
+		encrypt(cfg, dlqProps)
+		resources["DirectiveDeadLetterQueue"] = resource("AWS::SQS::Queue", dlqProps)
+
+		props["RedrivePolicy"] = map[string]interface{}{
+			"deadLetterTargetArn": getAtt("DirectiveDeadLetterQueue", "Arn"),
+			"maxReceiveCount":     cfg.Queue.MaxReceiveCount,
+		}
+	}
+
+	return resource("AWS::SQS::Queue", props)
+}
+
+func encrypt(cfg *Config, queueProps map[string]interface{}) {
+	if cfg.KMSKeyID != "" {
+		queueProps["KmsMasterKeyId"] = cfg.KMSKeyID
+		return
+	}
+	queueProps["SqsManagedSseEnabled"] = true
+}
+
+func resource(resourceType string, props map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"Type":       resourceType,
+		"Properties": props,
+	}
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"Ref": name}
+}
+
+func getAtt(name, attr string) map[string]interface{} {
+	return map[string]interface{}{"Fn::GetAtt": []string{name, attr}}
+}
+
+func output(value interface{}) map[string]interface{} {
+	return map[string]interface{}{"Value": value}
+}
@@ -0,0 +1,64 @@
+package infra
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	cfg, err := LoadConfig([]byte(`{
+		"name": "myskill",
+		"skillId": "amzn1.ask.skill.1",
+		"regions": ["us-east-1", "eu-west-1"],
+		"tokenStore": "dynamodb",
+		"queue": {"maxReceiveCount": 5}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	templates, err := Templates(cfg)
+	if err != nil {
+		t.Fatalf("failed to generate templates: %v", err)
+	}
+	if len(templates) != 2 {
+		t.Fatalf("expected a template per region, got %d", len(templates))
+	}
+
+	templateJSON, err := json.Marshal(templates["eu-west-1"])
+	if err != nil {
+		t.Fatalf("failed to marshal template: %v", err)
+	}
+	var template struct {
+		Resources map[string]struct {
+			Type       string
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(templateJSON, &template); err != nil {
+		t.Fatalf("failed to unmarshal template: %v", err)
+	}
+
+	for name, resourceType := range map[string]string{
+		"TokenTable":               "AWS::DynamoDB::Table",
+		"DirectiveQueue":           "AWS::SQS::Queue",
+		"DirectiveDeadLetterQueue": "AWS::SQS::Queue",
+		"SkillFunction":            "AWS::Serverless::Function",
+		"SkillPermission":          "AWS::Lambda::Permission",
+	} {
+		if template.Resources[name].Type != resourceType {
+			t.Errorf("expected %s to be a %s, got %q", name, resourceType, template.Resources[name].Type)
+		}
+	}
+	if _, ok := template.Resources["TokenBucket"]; ok {
+		t.Errorf("unexpected token bucket")
+	}
+	if token := template.Resources["SkillPermission"].Properties["EventSourceToken"]; token != "amzn1.ask.skill.1" {
+		t.Errorf("unexpected event source token: %v", token)
+	}
+
+	cfg.Regions = []string{"ap-southeast-2"}
+	if _, err := Templates(cfg); err == nil {
+		t.Errorf("expected error for unsupported region")
+	}
+}
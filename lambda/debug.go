@@ -3,6 +3,7 @@ package lambda
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
@@ -22,3 +23,36 @@ func DebugLambdaRequestHandler(handler alexa.Handler) func(context.Context, json
 		return alexa.ResponseDebugHandler(handler).HandleRequest(ctx, &req)
 	}
 }
+
+// DebugRequestHandler is DebugLambdaRequestHandler for use like RequestHandler. The
+// json produced for logging and validating the response is returned to the lambda
+// runtime so the response is only marshaled once.
+func DebugRequestHandler(handler alexa.Handler) func(context.Context, json.RawMessage) (json.RawMessage, error) {
+	return func(ctx context.Context, reqJSON json.RawMessage) (json.RawMessage, error) {
+		log.Printf("Debug request:\n%s\n", string(reqJSON))
+
+		var req alexa.Request
+		if err := json.Unmarshal(reqJSON, &req); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal request: %v", err)
+		}
+
+		resp, err := handler.HandleRequest(ctx, &req)
+		if resp == nil {
+			log.Println("Response is null.")
+			if err != nil {
+				return nil, err
+			}
+			return json.RawMessage("null"), nil
+		}
+
+		respJSON := alexa.DebugResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+		if respJSON == nil {
+			return nil, errors.New("failed to marshal response")
+		}
+
+		return respJSON, nil
+	}
+}
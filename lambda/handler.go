@@ -0,0 +1,36 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// RequestHandler adapts handler for use with lambda.Start. The response is encoded
+// with alexa.MarshalResponse so the lambda runtime only needs to copy the bytes
+// rather than marshal the response again.
+func RequestHandler(handler alexa.Handler) func(context.Context, json.RawMessage) (json.RawMessage, error) {
+	return func(ctx context.Context, reqJSON json.RawMessage) (json.RawMessage, error) {
+		var req alexa.Request
+		if err := json.Unmarshal(reqJSON, &req); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal request: %v", err)
+		}
+
+		resp, err := handler.HandleRequest(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			return json.RawMessage("null"), nil
+		}
+
+		respJSON, err := alexa.MarshalResponse(resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal response: %v", err)
+		}
+
+		return respJSON, nil
+	}
+}
@@ -0,0 +1,179 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+// EndpointLister lists the ids of the endpoints discovered for a user
+type EndpointLister interface {
+	ListEndpointIDs(ctx context.Context, userID string) ([]string, error)
+}
+
+// StateClearer removes any cached state held for a user
+type StateClearer interface {
+	ClearUser(ctx context.Context, userID string) error
+}
+
+// TokenStore provides the token access required by Cleaner
+type TokenStore interface {
+	alexa.TokenReader
+	alexa.TokenDeleter
+}
+
+// Cleaner removes everything stored for a user once they disable the skill or their
+// tokens are permanently revoked. Endpoints, State, EventSender and Logger are optional.
+type Cleaner struct {
+	Tokens TokenStore
+	// Endpoints lists the user's endpoints so DeleteReports can be sent for them.
+	Endpoints EndpointLister
+	// State is cleared of the user's cached state.
+	State StateClearer
+	// EventSender sends DeleteReports for the user's endpoints if set.
+	EventSender deferred.EventSender
+	RespBuilder *alexa.ResponseBuilder
+	// Logger records DeleteReports that couldn't be sent
+	Logger alexa.Logger
+}
+
+// cleaningKey marks a context used by Cleanup so a revocation reported while sending the
+// DeleteReport doesn't start a nested cleanup
+type cleaningKey struct{}
+
+// Cleanup removes the tokens and state stored for userID. If an EventSender is configured
+// a DeleteReport is sent for the user's endpoints first, since the stored token is
+// required to send it. A DeleteReport that can't be sent, e.g. because the token was
+// revoked, is logged and doesn't prevent the rest of the cleanup.
+func (c *Cleaner) Cleanup(ctx context.Context, userID string) error {
+	if c.EventSender != nil && c.Endpoints != nil {
+		if err := c.sendDeleteReport(context.WithValue(ctx, cleaningKey{}, true), userID); err != nil {
+			c.logger().Log(ctx, "delete report failed", "userId", userID, "error", err)
+		}
+	}
+	return c.clear(ctx, userID)
+}
+
+// TokenRevoked cleans up a user whose refresh token was permanently rejected. It skips the
+// DeleteReport, which can't be sent without a valid token, and logs any failure. It suits
+// deferred.HTTPEventSender's OnTokenRevoked:
+//
+//	sender.OnTokenRevoked = cleaner.TokenRevoked
+func (c *Cleaner) TokenRevoked(ctx context.Context, userID string) {
+	if ctx.Value(cleaningKey{}) != nil {
+		// Cleanup is already in progress for the user
+		return
+	}
+	if err := c.clear(ctx, userID); err != nil {
+		c.logger().Log(ctx, "cleanup of revoked user failed", "userId", userID, "error", err)
+	}
+}
+
+// clear removes the state and tokens of userID, attempting both even if one fails
+func (c *Cleaner) clear(ctx context.Context, userID string) error {
+	var stateErr error
+	if c.State != nil {
+		if err := c.State.ClearUser(ctx, userID); err != nil {
+			stateErr = fmt.Errorf("failed to clear state: %v", err)
+		}
+	}
+
+	if err := c.Tokens.Delete(ctx, userID); err != nil {
+		if stateErr != nil {
+			return fmt.Errorf("%v; failed to delete token: %v", stateErr, err)
+		}
+		return fmt.Errorf("failed to delete token: %v", err)
+	}
+
+	return stateErr
+}
+
+func (c *Cleaner) logger() alexa.Logger {
+	if c.Logger == nil {
+		return alexa.NopLogger{}
+	}
+	return c.Logger
+}
+
+func (c *Cleaner) sendDeleteReport(ctx context.Context, userID string) error {
+	endpointIDs, err := c.Endpoints.ListEndpointIDs(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list endpoints: %v", err)
+	}
+	if len(endpointIDs) == 0 {
+		return nil
+	}
+
+	scope, err := deferred.UserScope(ctx, c.Tokens, userID)
+	if errors.Is(err, deferred.ErrNoToken) {
+		// without a token the report can't be sent and there's nothing to revoke
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := c.RespBuilder.DeleteReport(scope, endpointIDs...)
+	if err != nil {
+		return fmt.Errorf("failed to build delete report: %v", err)
+	}
+
+	if err := c.EventSender.Send(alexa.WithUserID(ctx, userID), report); err != nil {
+		return fmt.Errorf("failed to send delete report: %v", err)
+	}
+
+	return nil
+}
+
+// SkillEvent is a skill lifecycle event such as AlexaSkillEvent.SkillDisabled
+type SkillEvent struct {
+	Version string            `json:"version"`
+	Request SkillEventRequest `json:"request"`
+}
+
+type SkillEventRequest struct {
+	Type      string          `json:"type"`
+	RequestID string          `json:"requestId"`
+	Timestamp string          `json:"timestamp"`
+	Body      json.RawMessage `json:"body"`
+}
+
+type SkillDisabledBody struct {
+	UserID                           string `json:"userId"`
+	UserInformationPersistenceStatus string `json:"userInformationPersistenceStatus"`
+}
+
+// SkillEvent types
+const (
+	SkillEventSkillDisabled = "AlexaSkillEvent.SkillDisabled"
+)
+
+// HandleSkillEvent runs Cleanup for SkillDisabled events and ignores other events.
+// resolveUserID maps the skill event's user id to the id used by the token store.
+// It may be nil if the ids are the same.
+func (c *Cleaner) HandleSkillEvent(ctx context.Context, event *SkillEvent,
+	resolveUserID func(ctx context.Context, skillUserID string) (string, error)) error {
+	if event.Request.Type != SkillEventSkillDisabled {
+		return nil
+	}
+
+	var body SkillDisabledBody
+	if err := json.Unmarshal(event.Request.Body, &body); err != nil {
+		return fmt.Errorf("failed to unmarshal body: %v", err)
+	}
+
+	userID := body.UserID
+	if resolveUserID != nil {
+		var err error
+		userID, err = resolveUserID(ctx, body.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to resolve user id: %v", err)
+		}
+	}
+
+	return c.Cleanup(ctx, userID)
+}
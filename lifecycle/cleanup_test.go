@@ -0,0 +1,131 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+	"golang.org/x/oauth2"
+)
+
+type memoryTokens struct {
+	tokens map[string]*oauth2.Token
+}
+
+func (m *memoryTokens) Read(ctx context.Context, id string) (*oauth2.Token, error) {
+	return m.tokens[id], nil
+}
+
+func (m *memoryTokens) Delete(ctx context.Context, id string) error {
+	delete(m.tokens, id)
+	return nil
+}
+
+type endpointList []string
+
+func (e endpointList) ListEndpointIDs(ctx context.Context, userID string) ([]string, error) {
+	return e, nil
+}
+
+type stateClearer struct {
+	cleared []string
+}
+
+func (s *stateClearer) ClearUser(ctx context.Context, userID string) error {
+	s.cleared = append(s.cleared, userID)
+	return nil
+}
+
+func TestCleanupRevokedToken(t *testing.T) {
+	tokens := &memoryTokens{tokens: map[string]*oauth2.Token{"user-1": {AccessToken: "revoked"}}}
+	state := &stateClearer{}
+	var logged []string
+	cleaner := &Cleaner{
+		Tokens:      tokens,
+		Endpoints:   endpointList{"light-1"},
+		State:       state,
+		RespBuilder: alexa.NewResponseBuilder(),
+		Logger: alexa.LoggerFunc(func(ctx context.Context, msg string, keyvals ...interface{}) {
+			logged = append(logged, msg)
+		}),
+	}
+	// sending with a revoked token fails and reports the revocation back to the cleaner
+	// like deferred.HTTPEventSender's OnTokenRevoked
+	var sent int
+	cleaner.EventSender = deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+		sent++
+		userID, _ := alexa.UserIDFromContext(ctx)
+		cleaner.TokenRevoked(ctx, userID)
+		return errors.New("token revoked")
+	})
+
+	if err := cleaner.Cleanup(context.Background(), "user-1"); err != nil {
+		t.Fatalf("failed to clean up: %v", err)
+	}
+
+	if sent != 1 {
+		t.Errorf("expected one delete report attempt, got %d", sent)
+	}
+	if _, ok := tokens.tokens["user-1"]; ok {
+		t.Error("expected token to be deleted")
+	}
+	if !reflect.DeepEqual([]string{"user-1"}, state.cleared) {
+		t.Errorf("expected state to be cleared once, got %v", state.cleared)
+	}
+	if !reflect.DeepEqual([]string{"delete report failed"}, logged) {
+		t.Errorf("unexpected logs %v", logged)
+	}
+}
+
+func TestTokenRevoked(t *testing.T) {
+	tokens := &memoryTokens{tokens: map[string]*oauth2.Token{"user-1": {AccessToken: "revoked"}}}
+	state := &stateClearer{}
+	var sent int
+	cleaner := &Cleaner{
+		Tokens:    tokens,
+		Endpoints: endpointList{"light-1"},
+		State:     state,
+		EventSender: deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+			sent++
+			return nil
+		}),
+		RespBuilder: alexa.NewResponseBuilder(),
+	}
+
+	cleaner.TokenRevoked(context.Background(), "user-1")
+
+	if sent != 0 {
+		t.Errorf("expected no delete report for a revoked token, got %d", sent)
+	}
+	if _, ok := tokens.tokens["user-1"]; ok || len(state.cleared) != 1 {
+		t.Errorf("expected token and state to be removed")
+	}
+}
+
+func TestCleanupWithoutToken(t *testing.T) {
+	state := &stateClearer{}
+	var logged []string
+	cleaner := &Cleaner{
+		Tokens:    &memoryTokens{tokens: map[string]*oauth2.Token{}},
+		Endpoints: endpointList{"light-1"},
+		State:     state,
+		EventSender: deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+			t.Error("expected no delete report without a token")
+			return nil
+		}),
+		RespBuilder: alexa.NewResponseBuilder(),
+		Logger: alexa.LoggerFunc(func(ctx context.Context, msg string, keyvals ...interface{}) {
+			logged = append(logged, msg)
+		}),
+	}
+
+	if err := cleaner.Cleanup(context.Background(), "user-1"); err != nil {
+		t.Fatalf("failed to clean up: %v", err)
+	}
+	if len(logged) != 0 || len(state.cleared) != 1 {
+		t.Errorf("expected state to be cleared without logging, got %v %v", logged, state.cleared)
+	}
+}
@@ -0,0 +1,121 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/discovery"
+)
+
+// EndpointBuilder completes a stored endpoint before it's returned for discovery, for
+// example by adding capabilities based on the device's current configuration.
+type EndpointBuilder interface {
+	BuildEndpoint(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) (alexa.DiscoverEndpoint, error)
+}
+
+// EndpointBuilderFunc implements EndpointBuilder as a func
+type EndpointBuilderFunc func(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) (alexa.DiscoverEndpoint, error)
+
+// BuildEndpoint calls the EndpointBuilderFunc
+func (e EndpointBuilderFunc) BuildEndpoint(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) (alexa.DiscoverEndpoint, error) {
+	return e(ctx, userID, endpoint)
+}
+
+// assembled is the outcome of building and validating a single endpoint
+type assembled struct {
+	endpoint alexa.DiscoverEndpoint
+	problems []discovery.Problem
+	err      error
+}
+
+// assemble runs the Builder and validation over each endpoint concurrently, limited by
+// MaxConcurrency. Endpoints keep their order. Endpoints with lint errors are dropped.
+func (r *Registry) assemble(ctx context.Context, userID string, endpoints []alexa.DiscoverEndpoint) ([]alexa.DiscoverEndpoint, error) {
+	if r.Builder == nil && !r.Validate {
+		return endpoints, nil
+	}
+
+	results := make([]assembled, len(endpoints))
+	workers := r.maxConcurrency()
+	if workers > len(endpoints) {
+		workers = len(endpoints)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = r.assembleEndpoint(ctx, userID, endpoints[i])
+			}
+		}()
+	}
+	for i := range endpoints {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	built := make([]alexa.DiscoverEndpoint, 0, len(endpoints))
+	for _, result := range results {
+		if result.err != nil {
+			return nil, result.err
+		}
+		if discovery.HasErrors(result.problems) {
+			if r.OnInvalidEndpoint != nil {
+				r.OnInvalidEndpoint(ctx, result.endpoint, result.problems)
+			}
+			continue
+		}
+		built = append(built, result.endpoint)
+	}
+
+	return built, nil
+}
+
+func (r *Registry) assembleEndpoint(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) assembled {
+	if err := ctx.Err(); err != nil {
+		return assembled{err: err}
+	}
+
+	if r.Builder != nil {
+		built, err := r.Builder.BuildEndpoint(ctx, userID, endpoint)
+		if err != nil {
+			return assembled{err: fmt.Errorf("failed to build endpoint %s: %v", endpoint.EndpointID, err)}
+		}
+		endpoint = built
+	}
+
+	result := assembled{endpoint: endpoint}
+	if r.Validate {
+		result.problems = discovery.LintEndpoint(endpoint)
+	}
+	return result
+}
+
+// LocalizingBuilder localizes the friendly names of endpoints with catalog after
+// building them with builder, if set. See alexa.Catalog.Localize.
+func LocalizingBuilder(catalog *alexa.Catalog, builder EndpointBuilder) EndpointBuilderFunc {
+	return func(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) (alexa.DiscoverEndpoint, error) {
+		if builder != nil {
+			built, err := builder.BuildEndpoint(ctx, userID, endpoint)
+			if err != nil {
+				return alexa.DiscoverEndpoint{}, err
+			}
+			endpoint = built
+		}
+		return catalog.Localize(endpoint)
+	}
+}
+
+func (r *Registry) maxConcurrency() int {
+	if r.MaxConcurrency > 0 {
+		return r.MaxConcurrency
+	}
+	return runtime.GOMAXPROCS(0)
+}
@@ -0,0 +1,137 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// GroupMembersCookie is the endpoint cookie key listing the member endpoint ids of a group
+const GroupMembersCookie = "registry.groupMembers"
+
+// NewGroupEndpoint marks endpoint as a group of the member endpoints. The membership is
+// stored in the endpoint's cookie so it is returned with every directive for the group.
+func NewGroupEndpoint(endpoint alexa.DiscoverEndpoint, memberIDs ...string) alexa.DiscoverEndpoint {
+	cookie := make(map[string]string, len(endpoint.Cookie)+1)
+	for k, v := range endpoint.Cookie {
+		cookie[k] = v
+	}
+	cookie[GroupMembersCookie] = strings.Join(memberIDs, ",")
+	endpoint.Cookie = cookie
+	return endpoint
+}
+
+// GroupMembers returns the member endpoint ids from a group endpoint's cookie
+func GroupMembers(cookie map[string]string) []string {
+	members := cookie[GroupMembersCookie]
+	if members == "" {
+		return nil
+	}
+	return strings.Split(members, ",")
+}
+
+// GroupHandler fans directives for group endpoints out to each member endpoint
+// concurrently. Directives for other endpoints are passed straight to Handler.
+type GroupHandler struct {
+	Handler     alexa.Handler
+	RespBuilder *alexa.ResponseBuilder
+	// Store is optionally used to populate each member request with the member's cookie.
+	// The user id must be in the context to use it.
+	Store Store
+	// OnMemberResponse is optionally called with each member's successful response, for
+	// example to publish it as a ChangeReport for the member.
+	OnMemberResponse func(ctx context.Context, memberID string, resp *alexa.Response)
+	// MaxConcurrency limits the members handled at once. Zero means no limit.
+	MaxConcurrency int
+}
+
+type memberResult struct {
+	memberID string
+	resp     *alexa.Response
+	err      error
+}
+
+// HandleRequest handles the request for each member of a group. If every member succeeds a
+// Response for the group is returned with the context properties of the first member.
+// Otherwise an ENDPOINT_UNREACHABLE error response lists the failed members.
+func (g *GroupHandler) HandleRequest(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	members := GroupMembers(req.Directive.Endpoint.Cookie)
+	if len(members) == 0 {
+		return g.Handler.HandleRequest(ctx, req)
+	}
+
+	results := make([]memberResult, len(members))
+	var sem chan struct{}
+	if g.MaxConcurrency > 0 {
+		sem = make(chan struct{}, g.MaxConcurrency)
+	}
+
+	var wg sync.WaitGroup
+	for i, memberID := range members {
+		wg.Add(1)
+		go func(i int, memberID string) {
+			defer wg.Done()
+			if sem != nil {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+			}
+			resp, err := g.handleMember(ctx, req, memberID)
+			results[i] = memberResult{memberID, resp, err}
+		}(i, memberID)
+	}
+	wg.Wait()
+
+	var failed []string
+	var first *alexa.Response
+	for _, result := range results {
+		if result.err != nil || result.resp == nil || result.resp.Event.Header.Name == "ErrorResponse" {
+			failed = append(failed, result.memberID)
+			continue
+		}
+		if first == nil {
+			first = result.resp
+		}
+		if g.OnMemberResponse != nil {
+			g.OnMemberResponse(ctx, result.memberID, result.resp)
+		}
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return g.RespBuilder.BasicErrorResponse(req, alexa.ErrorTypeEndpointUnreachable,
+			fmt.Sprintf("failed to handle members: %s", strings.Join(failed, ", ")))
+	}
+
+	var props []alexa.ContextProperty
+	if first.Context != nil {
+		props = first.Context.Properties
+	}
+	return g.RespBuilder.BasicResponse(req, props...), nil
+}
+
+func (g *GroupHandler) handleMember(ctx context.Context, req *alexa.Request, memberID string) (*alexa.Response, error) {
+	memberReq := req.Clone()
+	memberReq.Directive.Endpoint.EndpointID = memberID
+	memberReq.Directive.Endpoint.Cookie = nil
+
+	if g.Store != nil {
+		userID, ok := alexa.UserIDFromContext(ctx)
+		if !ok {
+			return nil, fmt.Errorf("no user id in context")
+		}
+		member, err := g.Store.Get(ctx, userID, memberID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get member %s: %v", memberID, err)
+		}
+		if member == nil {
+			return nil, fmt.Errorf("unknown member %s", memberID)
+		}
+		memberReq.Directive.Endpoint.Cookie = member.Cookie
+	}
+
+	return g.Handler.HandleRequest(ctx, memberReq)
+}
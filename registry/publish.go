@@ -0,0 +1,77 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+// PublishingStore wraps a Store and notifies the smart home api of endpoint changes by
+// sending an AddOrUpdateReport when an endpoint is added or modified and a DeleteReport
+// when an existing endpoint is deleted. The user's stored token is used to send the
+// events. The Store is only changed once the event is sent so a failed call can be
+// retried.
+type PublishingStore struct {
+	Store
+	Tokens      alexa.TokenReader
+	EventSender deferred.EventSender
+	RespBuilder *alexa.ResponseBuilder
+}
+
+// Put sends an AddOrUpdateReport for the endpoint and stores it. Nothing is sent if the
+// stored endpoint is unchanged.
+func (p *PublishingStore) Put(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) error {
+	existing, err := p.Store.Get(ctx, userID, endpoint.EndpointID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && reflect.DeepEqual(*existing, endpoint) {
+		return nil
+	}
+
+	scope, err := deferred.UserScope(ctx, p.Tokens, userID)
+	if err != nil {
+		return err
+	}
+
+	report, err := p.RespBuilder.AddOrUpdateReport(scope, endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to build add or update report: %v", err)
+	}
+
+	if err := p.EventSender.Send(alexa.WithUserID(ctx, userID), report); err != nil {
+		return fmt.Errorf("failed to send add or update report: %v", err)
+	}
+
+	return p.Store.Put(ctx, userID, endpoint)
+}
+
+// Delete sends a DeleteReport for the endpoint if it exists and removes it
+func (p *PublishingStore) Delete(ctx context.Context, userID, endpointID string) error {
+	existing, err := p.Store.Get(ctx, userID, endpointID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return nil
+	}
+
+	scope, err := deferred.UserScope(ctx, p.Tokens, userID)
+	if err != nil {
+		return err
+	}
+
+	report, err := p.RespBuilder.DeleteReport(scope, endpointID)
+	if err != nil {
+		return fmt.Errorf("failed to build delete report: %v", err)
+	}
+
+	if err := p.EventSender.Send(alexa.WithUserID(ctx, userID), report); err != nil {
+		return fmt.Errorf("failed to send delete report: %v", err)
+	}
+
+	return p.Store.Delete(ctx, userID, endpointID)
+}
@@ -0,0 +1,159 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/discovery"
+)
+
+// Store persists the endpoints discovered for each user
+type Store interface {
+	// List returns all endpoints for the user
+	List(ctx context.Context, userID string) ([]alexa.DiscoverEndpoint, error)
+	// Get returns the endpoint or nil if it doesn't exist
+	Get(ctx context.Context, userID, endpointID string) (*alexa.DiscoverEndpoint, error)
+	// Put creates or replaces the endpoint
+	Put(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) error
+	// Delete removes the endpoint. Deleting a missing endpoint is not an error.
+	Delete(ctx context.Context, userID, endpointID string) error
+}
+
+// UserLister lists the ids of the users with endpoints in a Store
+type UserLister interface {
+	Users(ctx context.Context) ([]string, error)
+}
+
+// Registry provides endpoint lookups on top of a Store
+type Registry struct {
+	Store Store
+	// Builder optionally completes each endpoint during discovery
+	Builder EndpointBuilder
+	// Validate lints each endpoint during discovery and drops those with errors so
+	// one bad endpoint doesn't fail discovery for the user.
+	Validate bool
+	// OnInvalidEndpoint is optionally called with each endpoint dropped by Validate
+	OnInvalidEndpoint func(ctx context.Context, endpoint alexa.DiscoverEndpoint, problems []discovery.Problem)
+	// MaxConcurrency limits the endpoints built and validated at once. Defaults to GOMAXPROCS.
+	MaxConcurrency int
+}
+
+// ListEndpointIDs returns the ids of all endpoints for the user
+func (r *Registry) ListEndpointIDs(ctx context.Context, userID string) ([]string, error) {
+	endpoints, err := r.Store.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		ids = append(ids, endpoint.EndpointID)
+	}
+	return ids, nil
+}
+
+// OwnsEndpoint reports if the endpoint is registered for the user
+func (r *Registry) OwnsEndpoint(ctx context.Context, userID, endpointID string) (bool, error) {
+	endpoint, err := r.Store.Get(ctx, userID, endpointID)
+	if err != nil {
+		return false, err
+	}
+	return endpoint != nil, nil
+}
+
+// DiscoveryHandler handles discovery requests with the endpoints registered for the user.
+// The user id must be in the context, see alexa.TokenValidationHandler.
+func (r *Registry) DiscoveryHandler(builder *alexa.ResponseBuilder) alexa.HandlerFunc {
+	return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		userID, ok := alexa.UserIDFromContext(ctx)
+		if !ok {
+			return nil, fmt.Errorf("registry.DiscoveryHandler: no user id in context")
+		}
+
+		endpoints, err := r.Store.List(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("registry.DiscoveryHandler: failed to list endpoints: %v", err)
+		}
+
+		endpoints, err = r.assemble(ctx, userID, endpoints)
+		if err != nil {
+			return nil, fmt.Errorf("registry.DiscoveryHandler: %v", err)
+		}
+
+		return builder.DiscoverResponse(endpoints...)
+	}
+}
+
+// MemoryStore is a Store that holds endpoints in memory
+type MemoryStore struct {
+	mu        sync.RWMutex
+	endpoints map[string]map[string]alexa.DiscoverEndpoint
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{endpoints: make(map[string]map[string]alexa.DiscoverEndpoint)}
+}
+
+// List returns the user's endpoints ordered by id
+func (m *MemoryStore) List(ctx context.Context, userID string) ([]alexa.DiscoverEndpoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	endpoints := make([]alexa.DiscoverEndpoint, 0, len(m.endpoints[userID]))
+	for _, endpoint := range m.endpoints[userID] {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].EndpointID < endpoints[j].EndpointID
+	})
+	return endpoints, nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, userID, endpointID string) (*alexa.DiscoverEndpoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	endpoint, ok := m.endpoints[userID][endpointID]
+	if !ok {
+		return nil, nil
+	}
+	return &endpoint, nil
+}
+
+func (m *MemoryStore) Put(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.endpoints[userID] == nil {
+		m.endpoints[userID] = make(map[string]alexa.DiscoverEndpoint)
+	}
+	m.endpoints[userID][endpoint.EndpointID] = endpoint
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, userID, endpointID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.endpoints[userID], endpointID)
+	return nil
+}
+
+// Users returns the ids of the users with endpoints
+func (m *MemoryStore) Users(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := make([]string, 0, len(m.endpoints))
+	for userID, endpoints := range m.endpoints {
+		if len(endpoints) > 0 {
+			users = append(users, userID)
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
@@ -0,0 +1,688 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+	"github.com/mctofu/alexa-smart-home/discovery"
+	"golang.org/x/oauth2"
+)
+
+func TestDiscoveryHandler(t *testing.T) {
+	store := NewMemoryStore()
+	reg := &Registry{Store: store}
+	ctx := context.Background()
+
+	for _, endpoint := range []alexa.DiscoverEndpoint{
+		{EndpointID: "switch-2", FriendlyName: "Lamp"},
+		{EndpointID: "switch-1", FriendlyName: "Fan"},
+	} {
+		if err := store.Put(ctx, "user-1", endpoint); err != nil {
+			t.Fatalf("failed to put endpoint: %v", err)
+		}
+	}
+	if err := store.Put(ctx, "user-2", alexa.DiscoverEndpoint{EndpointID: "other"}); err != nil {
+		t.Fatalf("failed to put endpoint: %v", err)
+	}
+
+	handler := reg.DiscoveryHandler(&alexa.ResponseBuilder{MessageID: func() string { return "id" }})
+
+	if _, err := handler(ctx, &alexa.Request{}); err == nil {
+		t.Fatalf("expected error without user id")
+	}
+
+	resp, err := handler(alexa.WithUserID(ctx, "user-1"), &alexa.Request{})
+	if err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+
+	var payload alexa.DiscoverPayload
+	if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+	if len(payload.Endpoints) != 2 ||
+		payload.Endpoints[0].EndpointID != "switch-1" ||
+		payload.Endpoints[1].EndpointID != "switch-2" {
+		t.Fatalf("unexpected endpoints: %+v", payload.Endpoints)
+	}
+
+	owned, err := reg.OwnsEndpoint(ctx, "user-2", "switch-1")
+	if err != nil || owned {
+		t.Fatalf("user-2 should not own switch-1: %v %v", owned, err)
+	}
+}
+
+func TestDiscoveryHandlerAssemble(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := alexa.WithUserID(context.Background(), "user-1")
+
+	for i := 0; i < 50; i++ {
+		endpoint := alexa.DiscoverEndpoint{
+			EndpointID:        fmt.Sprintf("switch-%02d", i),
+			ManufacturerName:  "Acme",
+			FriendlyName:      fmt.Sprintf("Switch %d", i),
+			Description:       "Switch",
+			DisplayCategories: []string{alexa.DisplayCategorySwitch},
+		}
+		if err := store.Put(ctx, "user-1", endpoint); err != nil {
+			t.Fatalf("failed to put endpoint: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	var dropped []string
+	reg := &Registry{
+		Store: store,
+		Builder: EndpointBuilderFunc(func(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) (alexa.DiscoverEndpoint, error) {
+			if endpoint.EndpointID == "switch-13" {
+				// no capabilities is a lint error
+				return endpoint, nil
+			}
+			endpoint.Capabilities = []alexa.DiscoverCapability{
+				{Type: "AlexaInterface", Interface: alexa.InterfacePowerController, Version: "3"},
+				{Type: "AlexaInterface", Interface: "Alexa", Version: "3"},
+			}
+			return endpoint, nil
+		}),
+		Validate: true,
+		OnInvalidEndpoint: func(ctx context.Context, endpoint alexa.DiscoverEndpoint, problems []discovery.Problem) {
+			mu.Lock()
+			defer mu.Unlock()
+			dropped = append(dropped, endpoint.EndpointID)
+		},
+		MaxConcurrency: 4,
+	}
+
+	resp, err := reg.DiscoveryHandler(&alexa.ResponseBuilder{MessageID: func() string { return "id" }})(ctx, &alexa.Request{})
+	if err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+
+	var payload alexa.DiscoverPayload
+	if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+	if len(payload.Endpoints) != 49 {
+		t.Fatalf("expected 49 endpoints, got %d", len(payload.Endpoints))
+	}
+	for i := 1; i < len(payload.Endpoints); i++ {
+		if payload.Endpoints[i-1].EndpointID >= payload.Endpoints[i].EndpointID {
+			t.Fatalf("endpoints out of order at %d", i)
+		}
+	}
+	if len(dropped) != 1 || dropped[0] != "switch-13" {
+		t.Errorf("expected switch-13 to be dropped, got %v", dropped)
+	}
+}
+
+func TestDiscoveryHandlerDefaultConcurrency(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := alexa.WithUserID(context.Background(), "user-1")
+
+	for i := 0; i < 20; i++ {
+		if err := store.Put(ctx, "user-1", alexa.DiscoverEndpoint{EndpointID: fmt.Sprintf("switch-%02d", i)}); err != nil {
+			t.Fatalf("failed to put endpoint: %v", err)
+		}
+	}
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(2))
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	reg := &Registry{
+		Store: store,
+		Builder: EndpointBuilderFunc(func(ctx context.Context, userID string, endpoint alexa.DiscoverEndpoint) (alexa.DiscoverEndpoint, error) {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return endpoint, nil
+		}),
+	}
+
+	resp, err := reg.DiscoveryHandler(&alexa.ResponseBuilder{MessageID: func() string { return "id" }})(ctx, &alexa.Request{})
+	if err != nil {
+		t.Fatalf("failed to handle request: %v", err)
+	}
+
+	var payload alexa.DiscoverPayload
+	if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+	if len(payload.Endpoints) != 20 {
+		t.Fatalf("expected 20 endpoints, got %d", len(payload.Endpoints))
+	}
+	if maxRunning > 2 {
+		t.Errorf("expected at most GOMAXPROCS endpoints built at once, got %d", maxRunning)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	config := `{"user-1": [{"endpointId": "lamp", "friendlyName": "Lamp"}, {"endpointId": "fan", "friendlyName": "Fan"}]}`
+	var changes []string
+	w := &Watcher{
+		Source: SourceFunc(func(ctx context.Context) ([]byte, error) { return []byte(config), nil }),
+		Store:  store,
+		OnChange: func(ctx context.Context, userID string, diff *discovery.Diff) {
+			changes = append(changes, fmt.Sprintf("%s +%d -%d ~%d", userID, len(diff.Added), len(diff.Removed), len(diff.Changed)))
+		},
+	}
+
+	if err := w.Reload(ctx); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	if err := w.Reload(ctx); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+
+	config = `{"user-1": [{"endpointId": "lamp", "friendlyName": "Desk Lamp"}], "user-2": [{"endpointId": "fan"}]}`
+	if err := w.Reload(ctx); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+
+	config = `{"user-2": [{"endpointId": "fan"}, {"endpointId": "fan"}]}`
+	if err := w.Reload(ctx); err == nil {
+		t.Fatal("expected error for duplicate endpoint")
+	}
+
+	config = `{"user-2": [{"endpointId": "fan"}]}`
+	if err := w.Reload(ctx); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+
+	expected := []string{"user-1 +2 -0 ~0", "user-1 +0 -1 ~1", "user-2 +1 -0 ~0", "user-1 +0 -1 ~0"}
+	sort.Strings(changes[1:3])
+	if fmt.Sprint(changes) != fmt.Sprint(expected) {
+		t.Errorf("expected changes %v, got %v", expected, changes)
+	}
+
+	endpoints, err := store.List(ctx, "user-1")
+	if err != nil || len(endpoints) != 0 {
+		t.Errorf("expected user-1 endpoints to be removed: %+v %v", endpoints, err)
+	}
+}
+
+func TestSceneHandler(t *testing.T) {
+	respBuilder := &alexa.ResponseBuilder{MessageID: func() string { return "id" }}
+	var mu sync.Mutex
+	var handled []string
+	handler := alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		mu.Lock()
+		handled = append(handled, req.Directive.Endpoint.EndpointID+":"+req.DirectiveName())
+		mu.Unlock()
+
+		switch req.Directive.Endpoint.EndpointID {
+		case "slow":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case "broken":
+			return respBuilder.BasicErrorResponse(req, alexa.ErrorTypeEndpointUnreachable, "broken")
+		}
+		return respBuilder.BasicResponse(req), nil
+	})
+
+	scenes := &SceneHandler{
+		Scenes: map[string]Scene{
+			"movie": {
+				Activate: []SceneAction{
+					{EndpointID: "lamp", Namespace: alexa.NamespacePowerController, Name: "TurnOff"},
+					{EndpointID: "blinds", Namespace: alexa.NamespacePercentageController, Name: "SetPercentage",
+						Payload: json.RawMessage(`{"percentage":0}`)},
+				},
+			},
+			"failing": {
+				Activate: []SceneAction{
+					{EndpointID: "slow", Namespace: alexa.NamespacePowerController, Name: "TurnOn"},
+					{EndpointID: "broken", Namespace: alexa.NamespacePowerController, Name: "TurnOn"},
+					{EndpointID: "lamp", Namespace: alexa.NamespacePowerController, Name: "TurnOn"},
+				},
+			},
+		},
+		Handler:     handler,
+		RespBuilder: respBuilder,
+		Timeout:     10 * time.Millisecond,
+	}
+
+	sceneRequest := func(endpointID string) *alexa.Request {
+		req := &alexa.Request{}
+		req.Directive.Header.Namespace = alexa.NamespaceSceneController
+		req.Directive.Header.Name = "Activate"
+		req.Directive.Endpoint.EndpointID = endpointID
+		req.Directive.Payload = json.RawMessage(`{"cause":{"type":"RULE_TRIGGER"}}`)
+		return req
+	}
+
+	resp, err := scenes.HandleRequest(context.Background(), sceneRequest("movie"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Event.Header.Name != "ActivationStarted" {
+		t.Fatalf("expected ActivationStarted, got %s", resp.Event.Header.Name)
+	}
+	var payload alexa.SceneActivationPayload
+	if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+	if payload.Cause.Type != alexa.CauseRuleTrigger {
+		t.Errorf("expected cause %s, got %s", alexa.CauseRuleTrigger, payload.Cause.Type)
+	}
+	sort.Strings(handled)
+	if fmt.Sprint(handled) != "[blinds:SetPercentage lamp:TurnOff]" {
+		t.Errorf("unexpected actions: %v", handled)
+	}
+
+	resp, err = scenes.HandleRequest(context.Background(), sceneRequest("failing"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Event.Header.Name != "ErrorResponse" {
+		t.Fatalf("expected ErrorResponse, got %s", resp.Event.Header.Name)
+	}
+	if !strings.Contains(string(resp.Event.Payload), "broken, slow") {
+		t.Errorf("expected failed endpoints in %s", resp.Event.Payload)
+	}
+}
+
+func TestWatcherReloadStoredUsers(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	// left over from before a restart
+	for userID, endpoint := range map[string]alexa.DiscoverEndpoint{
+		"user-1":       {EndpointID: "lamp", FriendlyName: "Lamp", Cookie: map[string]string{"pin": "1"}},
+		"removed-user": {EndpointID: "fan", FriendlyName: "Fan"},
+	} {
+		if err := store.Put(ctx, userID, endpoint); err != nil {
+			t.Fatalf("failed to put endpoint: %v", err)
+		}
+	}
+
+	var changes []string
+	w := &Watcher{
+		Source: SourceFunc(func(ctx context.Context) ([]byte, error) {
+			return []byte(`{"user-1": [{"endpointId": "lamp", "friendlyName": "Lamp", "cookie": {"pin": "2"}}]}`), nil
+		}),
+		Store: store,
+		Users: store,
+		OnChange: func(ctx context.Context, userID string, diff *discovery.Diff) {
+			changes = append(changes, fmt.Sprintf("%s +%d -%d ~%d", userID, len(diff.Added), len(diff.Removed), len(diff.Changed)))
+		},
+	}
+
+	if err := w.Reload(ctx); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+
+	sort.Strings(changes)
+	expected := []string{"removed-user +0 -1 ~0", "user-1 +0 -0 ~1"}
+	if fmt.Sprint(changes) != fmt.Sprint(expected) {
+		t.Errorf("expected changes %v, got %v", expected, changes)
+	}
+
+	lamp, err := store.Get(ctx, "user-1", "lamp")
+	if err != nil || lamp == nil || lamp.Cookie["pin"] != "2" {
+		t.Errorf("expected the cookie change to be stored: %+v %v", lamp, err)
+	}
+	users, err := store.Users(ctx)
+	if err != nil || fmt.Sprint(users) != "[user-1]" {
+		t.Errorf("expected removed-user to be cleaned up: %v %v", users, err)
+	}
+}
+
+type tokenReaderFunc func(ctx context.Context, id string) (*oauth2.Token, error)
+
+func (f tokenReaderFunc) Read(ctx context.Context, id string) (*oauth2.Token, error) {
+	return f(ctx, id)
+}
+
+func TestPublishingStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	var sent []string
+	fail := false
+	publisher := &PublishingStore{
+		Store: store,
+		Tokens: tokenReaderFunc(func(ctx context.Context, id string) (*oauth2.Token, error) {
+			return &oauth2.Token{AccessToken: "token-" + id}, nil
+		}),
+		EventSender: deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+			if fail {
+				return errors.New("gateway unavailable")
+			}
+			if resp.Event.Payload == nil {
+				t.Errorf("expected a payload")
+			}
+			sent = append(sent, resp.Event.Header.Name)
+			return nil
+		}),
+		RespBuilder: &alexa.ResponseBuilder{MessageID: func() string { return "id" }},
+	}
+
+	lamp := alexa.DiscoverEndpoint{EndpointID: "switch-1", FriendlyName: "Lamp"}
+	renamed := alexa.DiscoverEndpoint{EndpointID: "switch-1", FriendlyName: "Desk Lamp"}
+
+	steps := []struct {
+		name     string
+		apply    func() error
+		fail     bool
+		sent     []string
+		expected *alexa.DiscoverEndpoint
+	}{
+		{
+			name:     "add",
+			apply:    func() error { return publisher.Put(ctx, "user-1", lamp) },
+			sent:     []string{"AddOrUpdateReport"},
+			expected: &lamp,
+		},
+		{
+			name:     "unchanged",
+			apply:    func() error { return publisher.Put(ctx, "user-1", lamp) },
+			expected: &lamp,
+		},
+		{
+			name:     "update fails",
+			apply:    func() error { return publisher.Put(ctx, "user-1", renamed) },
+			fail:     true,
+			expected: &lamp,
+		},
+		{
+			name:     "update",
+			apply:    func() error { return publisher.Put(ctx, "user-1", renamed) },
+			sent:     []string{"AddOrUpdateReport"},
+			expected: &renamed,
+		},
+		{
+			name:     "delete fails",
+			apply:    func() error { return publisher.Delete(ctx, "user-1", "switch-1") },
+			fail:     true,
+			expected: &renamed,
+		},
+		{
+			name:  "delete",
+			apply: func() error { return publisher.Delete(ctx, "user-1", "switch-1") },
+			sent:  []string{"DeleteReport"},
+		},
+		{
+			name:  "delete missing",
+			apply: func() error { return publisher.Delete(ctx, "user-1", "switch-1") },
+		},
+	}
+
+	for _, step := range steps {
+		sent = nil
+		fail = step.fail
+		err := step.apply()
+		if step.fail != (err != nil) {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+		if fmt.Sprint(sent) != fmt.Sprint(step.sent) {
+			t.Errorf("%s: expected %v to be sent but got %v", step.name, step.sent, sent)
+		}
+
+		stored, err := store.Get(ctx, "user-1", "switch-1")
+		if err != nil {
+			t.Fatalf("%s: failed to get endpoint: %v", step.name, err)
+		}
+		if (stored == nil) != (step.expected == nil) || stored != nil && stored.FriendlyName != step.expected.FriendlyName {
+			t.Errorf("%s: expected %+v to be stored but got %+v", step.name, step.expected, stored)
+		}
+	}
+}
+
+func TestGroupHandler(t *testing.T) {
+	respBuilder := &alexa.ResponseBuilder{MessageID: func() string { return "id" }}
+
+	store := NewMemoryStore()
+	for _, endpoint := range []alexa.DiscoverEndpoint{
+		{EndpointID: "lamp", Cookie: map[string]string{"address": "10.0.0.1"}},
+		{EndpointID: "fan", Cookie: map[string]string{"address": "10.0.0.2"}},
+	} {
+		if err := store.Put(context.Background(), "user-1", endpoint); err != nil {
+			t.Fatalf("failed to put endpoint: %v", err)
+		}
+	}
+
+	tests := map[string]struct {
+		cookie    map[string]string
+		store     Store
+		userID    string
+		responses map[string]string
+		handled   []string
+		responded []string
+		name      string
+		errMsg    string
+	}{
+		"not a group": {
+			cookie:  map[string]string{"address": "10.0.0.3"},
+			handled: []string{"group:10.0.0.3"},
+			name:    "Response",
+		},
+		"members succeed": {
+			cookie:    map[string]string{GroupMembersCookie: "lamp,fan"},
+			handled:   []string{"fan:", "lamp:"},
+			responded: []string{"fan", "lamp"},
+			name:      "Response",
+		},
+		"members fail": {
+			cookie:    map[string]string{GroupMembersCookie: "lamp,fan,heater,oven"},
+			responses: map[string]string{"fan": "error", "heater": "ErrorResponse", "oven": "nil"},
+			handled:   []string{"fan:", "heater:", "lamp:", "oven:"},
+			responded: []string{"lamp"},
+			name:      "ErrorResponse",
+			errMsg:    "failed to handle members: fan, heater, oven",
+		},
+		"member cookies from store": {
+			cookie:    map[string]string{GroupMembersCookie: "lamp,fan"},
+			store:     store,
+			userID:    "user-1",
+			handled:   []string{"fan:10.0.0.2", "lamp:10.0.0.1"},
+			responded: []string{"fan", "lamp"},
+			name:      "Response",
+		},
+		"unknown member in store": {
+			cookie:    map[string]string{GroupMembersCookie: "lamp,missing"},
+			store:     store,
+			userID:    "user-1",
+			handled:   []string{"lamp:10.0.0.1"},
+			responded: []string{"lamp"},
+			name:      "ErrorResponse",
+			errMsg:    "failed to handle members: missing",
+		},
+		"store without user id": {
+			cookie: map[string]string{GroupMembersCookie: "lamp,fan"},
+			store:  store,
+			name:   "ErrorResponse",
+			errMsg: "failed to handle members: fan, lamp",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			var handled, responded []string
+			group := &GroupHandler{
+				Handler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+					mu.Lock()
+					handled = append(handled, req.Directive.Endpoint.EndpointID+":"+req.Directive.Endpoint.Cookie["address"])
+					mu.Unlock()
+
+					switch test.responses[req.Directive.Endpoint.EndpointID] {
+					case "error":
+						return nil, errors.New("failed")
+					case "ErrorResponse":
+						return respBuilder.BasicErrorResponse(req, alexa.ErrorTypeEndpointUnreachable, "offline")
+					case "nil":
+						return nil, nil
+					}
+					return respBuilder.BasicResponse(req, alexa.ContextProperty{
+						Namespace: alexa.NamespacePowerController,
+						Name:      "powerState",
+						Value:     json.RawMessage(`"` + req.Directive.Endpoint.EndpointID + `"`),
+					}), nil
+				}),
+				RespBuilder: respBuilder,
+				Store:       test.store,
+				OnMemberResponse: func(ctx context.Context, memberID string, resp *alexa.Response) {
+					responded = append(responded, memberID)
+				},
+			}
+
+			ctx := context.Background()
+			if test.userID != "" {
+				ctx = alexa.WithUserID(ctx, test.userID)
+			}
+			req := &alexa.Request{}
+			req.Directive.Header.Namespace = alexa.NamespacePowerController
+			req.Directive.Header.Name = "TurnOn"
+			req.Directive.Endpoint.EndpointID = "group"
+			req.Directive.Endpoint.Cookie = test.cookie
+
+			resp, err := group.HandleRequest(ctx, req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Event.Header.Name != test.name {
+				t.Fatalf("expected %s, got %s", test.name, resp.Event.Header.Name)
+			}
+			if resp.Event.Endpoint.EndpointID != "group" {
+				t.Errorf("expected response for the group, got %s", resp.Event.Endpoint.EndpointID)
+			}
+			if test.errMsg != "" && !strings.Contains(string(resp.Event.Payload), test.errMsg) {
+				t.Errorf("expected %q in %s", test.errMsg, resp.Event.Payload)
+			}
+			if test.name == "Response" && len(test.responded) > 0 {
+				if len(resp.Context.Properties) != 1 || string(resp.Context.Properties[0].Value) != `"lamp"` {
+					t.Errorf("expected properties of the first member, got %+v", resp.Context)
+				}
+			}
+
+			sort.Strings(handled)
+			if fmt.Sprint(handled) != fmt.Sprint(test.handled) {
+				t.Errorf("expected handled %v, got %v", test.handled, handled)
+			}
+			sort.Strings(responded)
+			if fmt.Sprint(responded) != fmt.Sprint(test.responded) {
+				t.Errorf("expected member responses %v, got %v", test.responded, responded)
+			}
+			if test.cookie[GroupMembersCookie] != "" && req.Directive.Endpoint.EndpointID != "group" {
+				t.Errorf("expected group request to be unmodified, got %s", req.Directive.Endpoint.EndpointID)
+			}
+		})
+	}
+}
+
+func TestGroupHandlerMaxConcurrency(t *testing.T) {
+	respBuilder := &alexa.ResponseBuilder{MessageID: func() string { return "id" }}
+
+	var mu sync.Mutex
+	var active, peak int
+	group := &GroupHandler{
+		Handler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+			mu.Lock()
+			active++
+			if active > peak {
+				peak = active
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+			return respBuilder.BasicResponse(req), nil
+		}),
+		RespBuilder:    respBuilder,
+		MaxConcurrency: 2,
+	}
+
+	req := &alexa.Request{}
+	req.Directive.Endpoint.EndpointID = "group"
+	req.Directive.Endpoint.Cookie = map[string]string{GroupMembersCookie: "a,b,c,d,e,f"}
+
+	resp, err := group.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Event.Header.Name != "Response" {
+		t.Fatalf("expected Response, got %s", resp.Event.Header.Name)
+	}
+	if peak > 2 {
+		t.Errorf("expected at most 2 members handled at once, got %d", peak)
+	}
+}
+
+// TestGroupHandlerConcurrentDecode decodes the payload in every member handler after
+// the group request's payload was already decoded. Run with -race to detect members
+// sharing the group request's payload cache.
+func TestGroupHandlerConcurrentDecode(t *testing.T) {
+	respBuilder := &alexa.ResponseBuilder{MessageID: func() string { return "id" }}
+
+	type percentage struct {
+		Percentage int `json:"percentage"`
+	}
+	type generic map[string]interface{}
+
+	group := &GroupHandler{
+		Handler: alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+			var p percentage
+			if err := req.DecodePayload(&p); err != nil {
+				return nil, err
+			}
+			var g generic
+			if err := req.DecodePayload(&g); err != nil {
+				return nil, err
+			}
+			if p.Percentage != 40 || g["percentage"] != float64(40) {
+				return nil, fmt.Errorf("unexpected payload: %+v %+v", p, g)
+			}
+			return respBuilder.BasicResponse(req), nil
+		}),
+		RespBuilder: respBuilder,
+	}
+
+	var members []string
+	for i := 0; i < 4*runtime.GOMAXPROCS(0); i++ {
+		members = append(members, fmt.Sprintf("member-%d", i))
+	}
+
+	req := &alexa.Request{}
+	req.Directive.Header.Namespace = alexa.NamespacePercentageController
+	req.Directive.Header.Name = "SetPercentage"
+	req.Directive.Endpoint.EndpointID = "group"
+	req.Directive.Endpoint.Cookie = map[string]string{GroupMembersCookie: strings.Join(members, ",")}
+	req.Directive.Payload = json.RawMessage(`{"percentage":40}`)
+
+	var p percentage
+	if err := req.DecodePayload(&p); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+
+	resp, err := group.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Event.Header.Name != "Response" {
+		t.Fatalf("expected Response, got %s", resp.Event.Payload)
+	}
+}
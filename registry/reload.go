@@ -0,0 +1,254 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/discovery"
+)
+
+// Source loads a serialized EndpointConfig, e.g. from a file, S3 object or SSM parameter
+type Source interface {
+	Load(ctx context.Context) ([]byte, error)
+}
+
+// SourceFunc implements Source as a func
+type SourceFunc func(ctx context.Context) ([]byte, error)
+
+// Load calls the SourceFunc
+func (s SourceFunc) Load(ctx context.Context) ([]byte, error) {
+	return s(ctx)
+}
+
+// FileSource loads the config from a local file
+type FileSource struct {
+	Path string
+}
+
+// Load reads the file
+func (f *FileSource) Load(ctx context.Context) ([]byte, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", f.Path, err)
+	}
+	return data, nil
+}
+
+// EndpointConfig defines the endpoints of each user keyed by user id
+type EndpointConfig map[string][]alexa.DiscoverEndpoint
+
+// ParseEndpointConfig decodes a json EndpointConfig
+func ParseEndpointConfig(data []byte) (EndpointConfig, error) {
+	var config EndpointConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid endpoint config: %v", err)
+	}
+
+	for userID, endpoints := range config {
+		seen := make(map[string]bool, len(endpoints))
+		for _, endpoint := range endpoints {
+			if endpoint.EndpointID == "" {
+				return nil, fmt.Errorf("invalid endpoint config: endpoint without id for user %s", userID)
+			}
+			if seen[endpoint.EndpointID] {
+				return nil, fmt.Errorf("invalid endpoint config: duplicate endpoint %s for user %s",
+					endpoint.EndpointID, userID)
+			}
+			seen[endpoint.EndpointID] = true
+		}
+	}
+
+	return config, nil
+}
+
+// Watcher keeps a Store in sync with an EndpointConfig loaded from Source. When the
+// config changes new and changed endpoints are put and removed endpoints are deleted.
+// Use a PublishingStore to send the matching AddOrUpdateReport and DeleteReport
+// events. The Watcher assumes it owns the endpoints of every user in the config.
+type Watcher struct {
+	Source Source
+	Store  Store
+	// Users optionally lists the users with endpoints in Store. Without it only users
+	// dropped from the config while the Watcher is running have their endpoints removed.
+	Users UserLister
+	// Interval between checks for changes. Defaults to 1m.
+	Interval time.Duration
+	// OnChange is optionally called with the changes applied for a user
+	OnChange func(ctx context.Context, userID string, diff *discovery.Diff)
+	// Logger optionally records reload failures
+	Logger alexa.Logger
+
+	mu       sync.Mutex
+	last     []byte
+	loadedAt time.Time
+	users    map[string]bool
+}
+
+// Run reloads the config every Interval until ctx is done. A config that fails to
+// load or apply is logged and retried on the next interval, leaving the last good
+// config in place. Run can be added to an agent.Agent as a component.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval())
+	defer ticker.Stop()
+
+	for {
+		if err := w.Reload(ctx); err != nil {
+			w.logger().Log(ctx, "endpoint config reload failed", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Reload loads the config and applies it to the Store if it changed since the last
+// successful reload.
+func (w *Watcher) Reload(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, err := w.Source.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load endpoint config: %v", err)
+	}
+	w.loadedAt = time.Now()
+
+	if w.last != nil && bytes.Equal(data, w.last) {
+		return nil
+	}
+
+	config, err := ParseEndpointConfig(data)
+	if err != nil {
+		return err
+	}
+
+	// users dropped from the config lose all their endpoints
+	known := make([]string, 0, len(w.users))
+	for userID := range w.users {
+		known = append(known, userID)
+	}
+	if w.Users != nil {
+		stored, err := w.Users.Users(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list users: %v", err)
+		}
+		known = append(known, stored...)
+	}
+	for _, userID := range known {
+		if _, ok := config[userID]; !ok {
+			config[userID] = nil
+		}
+	}
+
+	for userID, endpoints := range config {
+		if err := w.apply(ctx, userID, endpoints); err != nil {
+			return err
+		}
+	}
+
+	w.users = make(map[string]bool, len(config))
+	for userID, endpoints := range config {
+		if endpoints != nil {
+			w.users[userID] = true
+		}
+	}
+	w.last = data
+
+	return nil
+}
+
+// RefreshHandler reloads the config before passing requests on to handler if it
+// hasn't been loaded within Interval. It suits lambdas where a background Run
+// isn't possible. Reload failures are logged and the last good config is used.
+func (w *Watcher) RefreshHandler(handler alexa.Handler) alexa.HandlerFunc {
+	return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		w.mu.Lock()
+		stale := time.Since(w.loadedAt) >= w.interval()
+		w.mu.Unlock()
+
+		if stale {
+			if err := w.Reload(ctx); err != nil {
+				w.logger().Log(ctx, "endpoint config reload failed", "error", err)
+			}
+		}
+
+		return handler.HandleRequest(ctx, req)
+	}
+}
+
+func (w *Watcher) apply(ctx context.Context, userID string, endpoints []alexa.DiscoverEndpoint) error {
+	current, err := w.Store.List(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list endpoints for %s: %v", userID, err)
+	}
+
+	diff := discovery.Compare(current, endpoints)
+
+	// Compare only describes the differences Alexa cares about so any other change,
+	// e.g. to a cookie, is picked up by comparing the whole endpoint
+	desired := make(map[string]alexa.DiscoverEndpoint, len(endpoints))
+	for _, endpoint := range endpoints {
+		desired[endpoint.EndpointID] = endpoint
+	}
+	described := make(map[string]bool, len(diff.Changed))
+	for _, change := range diff.Changed {
+		described[change.EndpointID] = true
+	}
+	for _, endpoint := range current {
+		want, ok := desired[endpoint.EndpointID]
+		if ok && !described[endpoint.EndpointID] && !reflect.DeepEqual(endpoint, want) {
+			diff.Changed = append(diff.Changed, discovery.EndpointChange{
+				EndpointID: endpoint.EndpointID,
+				Changes:    []string{"endpoint definition changed"},
+			})
+		}
+	}
+	if diff.Empty() {
+		return nil
+	}
+
+	for _, endpoint := range diff.Added {
+		if err := w.Store.Put(ctx, userID, endpoint); err != nil {
+			return fmt.Errorf("failed to add endpoint %s: %v", endpoint.EndpointID, err)
+		}
+	}
+	for _, change := range diff.Changed {
+		if err := w.Store.Put(ctx, userID, desired[change.EndpointID]); err != nil {
+			return fmt.Errorf("failed to update endpoint %s: %v", change.EndpointID, err)
+		}
+	}
+	for _, endpoint := range diff.Removed {
+		if err := w.Store.Delete(ctx, userID, endpoint.EndpointID); err != nil {
+			return fmt.Errorf("failed to delete endpoint %s: %v", endpoint.EndpointID, err)
+		}
+	}
+
+	if w.OnChange != nil {
+		w.OnChange(ctx, userID, diff)
+	}
+
+	return nil
+}
+
+func (w *Watcher) interval() time.Duration {
+	if w.Interval <= 0 {
+		return time.Minute
+	}
+	return w.Interval
+}
+
+func (w *Watcher) logger() alexa.Logger {
+	if w.Logger == nil {
+		return alexa.NopLogger{}
+	}
+	return w.Logger
+}
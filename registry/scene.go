@@ -0,0 +1,192 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// SceneAction is a directive sent to an endpoint when a scene is activated or deactivated
+type SceneAction struct {
+	EndpointID string          `json:"endpointId"`
+	Namespace  string          `json:"namespace"`
+	Name       string          `json:"name"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+// Scene lists the actions performed when a scene is activated and deactivated
+type Scene struct {
+	Activate   []SceneAction `json:"activate"`
+	Deactivate []SceneAction `json:"deactivate,omitempty"`
+}
+
+// SceneHandler handles Alexa.SceneController directives for scenes composed of other
+// endpoints by sending each of the scene's actions to Handler concurrently.
+type SceneHandler struct {
+	// Scenes holds the scenes keyed by the endpoint id of the scene
+	Scenes      map[string]Scene
+	Handler     alexa.Handler
+	RespBuilder *alexa.ResponseBuilder
+	// Store is optionally used to populate each action request with the endpoint's cookie.
+	// The user id must be in the context to use it.
+	Store Store
+	// Timeout limits how long the actions may take. Defaults to 5s which leaves time
+	// to respond within the smart home api's 8s limit.
+	Timeout time.Duration
+	// MaxConcurrency limits the actions handled at once. Zero means no limit.
+	MaxConcurrency int
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+}
+
+type actionResult struct {
+	endpointID string
+	err        error
+}
+
+// HandleRequest performs the actions of the scene. If every action succeeds within
+// Timeout an ActivationStarted or DeactivationStarted event is returned. Otherwise an
+// ENDPOINT_UNREACHABLE error response lists the endpoints whose actions failed.
+func (s *SceneHandler) HandleRequest(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	sceneID := req.Directive.Endpoint.EndpointID
+	scene, ok := s.Scenes[sceneID]
+	if !ok {
+		return s.RespBuilder.BasicErrorResponse(req, alexa.ErrorTypeNoSuchEndpoint,
+			fmt.Sprintf("unknown scene %s", sceneID))
+	}
+
+	var actions []SceneAction
+	var event string
+	switch req.DirectiveName() {
+	case "Activate":
+		actions, event = scene.Activate, "ActivationStarted"
+	case "Deactivate":
+		actions, event = scene.Deactivate, "DeactivationStarted"
+	default:
+		return nil, alexa.UnexpectedDirective("registry.SceneHandler", req)
+	}
+
+	actionCtx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+
+	results := make([]actionResult, len(actions))
+	var sem chan struct{}
+	if s.MaxConcurrency > 0 {
+		sem = make(chan struct{}, s.MaxConcurrency)
+	}
+
+	var wg sync.WaitGroup
+	for i, action := range actions {
+		wg.Add(1)
+		go func(i int, action SceneAction) {
+			defer wg.Done()
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-actionCtx.Done():
+					results[i] = actionResult{action.EndpointID, actionCtx.Err()}
+					return
+				}
+			}
+			results[i] = actionResult{action.EndpointID, s.handleAction(actionCtx, req, action)}
+		}(i, action)
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result.endpointID)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return s.RespBuilder.BasicErrorResponse(req, alexa.ErrorTypeEndpointUnreachable,
+			fmt.Sprintf("scene %s failed for: %s", sceneID, strings.Join(failed, ", ")))
+	}
+
+	var directive struct {
+		Cause alexa.Cause `json:"cause"`
+	}
+	if err := req.DecodePayload(&directive); err != nil || directive.Cause.Type == "" {
+		directive.Cause.Type = alexa.CauseVoiceInteraction
+	}
+
+	resp, err := alexa.TypedResponse(s.RespBuilder, req, alexa.NamespaceSceneController, event,
+		alexa.SceneActivationPayload{Cause: directive.Cause, Timestamp: s.now().UTC()})
+	if err != nil {
+		return nil, err
+	}
+	resp.Context = &alexa.ResponseContext{}
+
+	return resp, nil
+}
+
+// handleAction sends the action to Handler. Error responses are treated as failures.
+func (s *SceneHandler) handleAction(ctx context.Context, req *alexa.Request, action SceneAction) error {
+	payload := action.Payload
+	if len(payload) == 0 {
+		payload = alexa.EmptyPayload
+	}
+
+	actionReq := &alexa.Request{Directive: alexa.RequestDirective{
+		Header: alexa.Header{
+			Namespace:        action.Namespace,
+			Name:             action.Name,
+			MessageID:        req.Directive.Header.MessageID,
+			CorrelationToken: req.Directive.Header.CorrelationToken,
+			PayloadVersion:   req.Directive.Header.PayloadVersion,
+		},
+		Endpoint: alexa.RequestEndpoint{
+			Scope:      req.Directive.Endpoint.Scope,
+			EndpointID: action.EndpointID,
+		},
+		Payload: payload,
+	}}
+
+	if s.Store != nil {
+		userID, ok := alexa.UserIDFromContext(ctx)
+		if !ok {
+			return fmt.Errorf("no user id in context")
+		}
+		endpoint, err := s.Store.Get(ctx, userID, action.EndpointID)
+		if err != nil {
+			return fmt.Errorf("failed to get endpoint %s: %v", action.EndpointID, err)
+		}
+		if endpoint == nil {
+			return fmt.Errorf("unknown endpoint %s", action.EndpointID)
+		}
+		actionReq.Directive.Endpoint.Cookie = endpoint.Cookie
+	}
+
+	resp, err := s.Handler.HandleRequest(ctx, actionReq)
+	if err != nil {
+		return err
+	}
+	if resp == nil || resp.Event.Header.Name == "ErrorResponse" {
+		return fmt.Errorf("%s.%s failed for %s", action.Namespace, action.Name, action.EndpointID)
+	}
+	// the handler may have returned after the deadline passed
+	return ctx.Err()
+}
+
+func (s *SceneHandler) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return 5 * time.Second
+	}
+	return s.Timeout
+}
+
+func (s *SceneHandler) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
@@ -0,0 +1,230 @@
+package sample
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// Params fill in the variable parts of a sample directive
+type Params struct {
+	EndpointID string
+	// Token is the bearer token of the user. Defaults to "sample-token".
+	Token string
+	// Value is the directive's payload value, e.g. a percentage. Not all directives use it.
+	Value json.RawMessage
+	// MessageID defaults to a new uuid
+	MessageID string
+}
+
+type sampleDirective struct {
+	namespace   string
+	name        string
+	description string
+	// value describes the Params.Value used by the directive. Empty if unused.
+	value   string
+	payload func(p Params) (interface{}, error)
+}
+
+var directives = map[string]sampleDirective{
+	"discover": {
+		namespace:   alexa.NamespaceDiscovery,
+		name:        "Discover",
+		description: "request the user's endpoints",
+		payload: func(p Params) (interface{}, error) {
+			return struct {
+				Scope alexa.Scope `json:"scope"`
+			}{scopeOf(p)}, nil
+		},
+	},
+	"acceptgrant": {
+		namespace:   alexa.NamespaceAuthorization,
+		name:        "AcceptGrant",
+		description: "link an account",
+		value:       "grant code",
+		payload: func(p Params) (interface{}, error) {
+			code := "sample-code"
+			if len(p.Value) > 0 {
+				if err := json.Unmarshal(p.Value, &code); err != nil {
+					return nil, fmt.Errorf("value must be a string grant code: %v", err)
+				}
+			}
+			return alexa.AcceptGrantPayload{
+				Grant:   alexa.AcceptGrantGrant{Type: "OAuth2.AuthorizationCode", Code: code},
+				Grantee: alexa.AcceptGrantGrantee{Type: "BearerToken", Token: scopeOf(p).Token},
+			}, nil
+		},
+	},
+	"reportstate": {
+		namespace:   alexa.NamespaceAlexa,
+		name:        alexa.DirectiveReportState,
+		description: "request the state of an endpoint",
+		payload:     emptyPayload,
+	},
+	"turnon": {
+		namespace:   alexa.NamespacePowerController,
+		name:        "TurnOn",
+		description: "turn on an endpoint",
+		payload:     emptyPayload,
+	},
+	"turnoff": {
+		namespace:   alexa.NamespacePowerController,
+		name:        "TurnOff",
+		description: "turn off an endpoint",
+		payload:     emptyPayload,
+	},
+	"setpercentage": {
+		namespace:   alexa.NamespacePercentageController,
+		name:        "SetPercentage",
+		description: "set the percentage of an endpoint",
+		value:       "percentage, 0 to 100",
+		payload: func(p Params) (interface{}, error) {
+			var payload alexa.SetPercentagePayload
+			if err := json.Unmarshal(valueOr(p, "50"), &payload.Percentage); err != nil {
+				return nil, fmt.Errorf("value must be a percentage: %v", err)
+			}
+			return payload, nil
+		},
+	},
+	"adjustpercentage": {
+		namespace:   alexa.NamespacePercentageController,
+		name:        "AdjustPercentage",
+		description: "adjust the percentage of an endpoint",
+		value:       "percentage delta, -100 to 100",
+		payload: func(p Params) (interface{}, error) {
+			var payload alexa.AdjustPercentagePayload
+			if err := json.Unmarshal(valueOr(p, "10"), &payload.PercentageDelta); err != nil {
+				return nil, fmt.Errorf("value must be a percentage delta: %v", err)
+			}
+			return payload, nil
+		},
+	},
+	"activate": {
+		namespace:   alexa.NamespaceSceneController,
+		name:        "Activate",
+		description: "activate a scene",
+		payload: func(p Params) (interface{}, error) {
+			return causePayload(), nil
+		},
+	},
+	"deactivate": {
+		namespace:   alexa.NamespaceSceneController,
+		name:        "Deactivate",
+		description: "deactivate a scene",
+		payload: func(p Params) (interface{}, error) {
+			return causePayload(), nil
+		},
+	},
+}
+
+// Names returns the names of the available sample directives
+func Names() []string {
+	names := make([]string, 0, len(directives))
+	for name := range directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Describe returns a short description of the sample directive
+func Describe(name string) string {
+	return directives[name].description
+}
+
+// Namespace returns the namespace of the sample directive
+func Namespace(name string) string {
+	return directives[name].namespace
+}
+
+// ValueHint describes the value the sample directive takes from Params.Value.
+// It returns an empty string if the directive doesn't use a value.
+func ValueHint(name string) string {
+	return directives[name].value
+}
+
+// Directive builds the named sample directive
+func Directive(name string, p Params) (*alexa.Request, error) {
+	sample, ok := directives[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sample directive: %s", name)
+	}
+
+	payload, err := sample.payload(p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	messageID := p.MessageID
+	if messageID == "" {
+		messageID = alexa.UUIDMessageID()
+	}
+
+	req := &alexa.Request{
+		Directive: alexa.RequestDirective{
+			Header: alexa.Header{
+				Namespace:      sample.namespace,
+				Name:           sample.name,
+				MessageID:      messageID,
+				PayloadVersion: "3",
+			},
+			Payload: payloadJSON,
+		},
+	}
+
+	// discovery and authorization directives don't target an endpoint
+	if sample.namespace != alexa.NamespaceDiscovery && sample.namespace != alexa.NamespaceAuthorization {
+		if p.EndpointID == "" {
+			return nil, fmt.Errorf("%s requires an endpoint id", name)
+		}
+		req.Directive.Header.CorrelationToken = "sample-correlation-token"
+		req.Directive.Endpoint = alexa.RequestEndpoint{
+			Scope:      scopeOf(p),
+			EndpointID: p.EndpointID,
+			Cookie:     map[string]string{},
+		}
+	}
+
+	return req, nil
+}
+
+// Value converts a user supplied value to a Params.Value. Valid json is used as is and
+// anything else is treated as a bare string, e.g. COOL becomes "COOL".
+func Value(value string) json.RawMessage {
+	if json.Valid([]byte(value)) {
+		return json.RawMessage(value)
+	}
+	quoted, _ := json.Marshal(value)
+	return quoted
+}
+
+func scopeOf(p Params) alexa.Scope {
+	token := p.Token
+	if token == "" {
+		token = "sample-token"
+	}
+	return alexa.Scope{Type: "BearerToken", Token: token}
+}
+
+func valueOr(p Params, fallback string) json.RawMessage {
+	if len(p.Value) == 0 {
+		return json.RawMessage(fallback)
+	}
+	return p.Value
+}
+
+func emptyPayload(p Params) (interface{}, error) {
+	return alexa.EmptyPayload, nil
+}
+
+func causePayload() interface{} {
+	return struct {
+		Cause alexa.Cause `json:"cause"`
+	}{alexa.Cause{Type: alexa.CauseVoiceInteraction}}
+}
@@ -0,0 +1,22 @@
+package sample
+
+import "testing"
+
+func TestValue(t *testing.T) {
+	tests := map[string]string{
+		"30":           "30",
+		`"COOL"`:       `"COOL"`,
+		"COOL":         `"COOL"`,
+		`{"value":22}`: `{"value":22}`,
+		"living room":  `"living room"`,
+		`say "hi"`:     `"say \"hi\""`,
+	}
+
+	for value, expected := range tests {
+		t.Run(value, func(t *testing.T) {
+			if got := string(Value(value)); got != expected {
+				t.Errorf("expected %s but got %s", expected, got)
+			}
+		})
+	}
+}
@@ -1,3 +1,7 @@
+// Package schema bundles the Alexa smart home message schema. It predates some of the
+// interfaces supported by the alexa package, e.g. Alexa.DeviceUsage.Meter,
+// Alexa.DoorbellEventSource, Alexa.SimpleEventSource and the thermostat schedule error
+// types, so validated messages using them are reported as invalid.
 package schema
 
 // AlexaSmartHome is the schema for smart home requests/responses
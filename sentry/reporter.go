@@ -0,0 +1,139 @@
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// Reporter is an example alexa.ErrorReporter that posts errors to Sentry
+// (https://sentry.io) using its HTTP store api so no client library is required.
+type Reporter struct {
+	// DSN is the project's client key in the form https://<key>@<host>/<project id>
+	DSN         string
+	HTTPDoer    alexa.HTTPDoer
+	Environment string
+	Release     string
+}
+
+// ReportError sends err to Sentry. Failures to report are logged rather than returned.
+func (r *Reporter) ReportError(ctx context.Context, req *alexa.Request, err error) {
+	if sendErr := r.send(ctx, req, err); sendErr != nil {
+		log.Printf("sentry: failed to report error %v: %v", err, sendErr)
+	}
+}
+
+func (r *Reporter) send(ctx context.Context, req *alexa.Request, reportErr error) error {
+	storeURL, authHeader, err := r.parseDSN()
+	if err != nil {
+		return err
+	}
+
+	event := event{
+		EventID:     strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Timestamp:   time.Now().UTC().Format("2006-01-02T15:04:05"),
+		Level:       "error",
+		Platform:    "go",
+		Environment: r.Environment,
+		Release:     r.Release,
+		Exception: exceptions{
+			Values: []exception{{Type: fmt.Sprintf("%T", reportErr), Value: reportErr.Error()}},
+		},
+		Tags:  make(map[string]string),
+		Extra: make(map[string]interface{}),
+	}
+	if req != nil {
+		event.Tags["namespace"] = req.Directive.Header.Namespace
+		event.Tags["name"] = req.Directive.Header.Name
+		event.Tags["endpointId"] = req.Directive.Endpoint.EndpointID
+		event.Extra["messageId"] = req.Directive.Header.MessageID
+	}
+	var panicErr *alexa.PanicError
+	if errors.As(reportErr, &panicErr) {
+		event.Level = "fatal"
+		event.Extra["stack"] = string(panicErr.Stack)
+	}
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, storeURL, bytes.NewReader(eventJSON))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %v", err)
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Sentry-Auth", authHeader)
+
+	httpResp, err := r.HTTPDoer.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to perform request: %v", err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %s\n%s", httpResp.Status, body)
+	}
+
+	return nil
+}
+
+// parseDSN returns the store api url and auth header for the DSN
+func (r *Reporter) parseDSN() (string, string, error) {
+	dsn, err := url.Parse(r.DSN)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid dsn: %v", err)
+	}
+	if dsn.User == nil || dsn.User.Username() == "" {
+		return "", "", errors.New("invalid dsn: missing key")
+	}
+	projectID := strings.TrimPrefix(dsn.Path, "/")
+	if projectID == "" {
+		return "", "", errors.New("invalid dsn: missing project id")
+	}
+
+	storeURL := fmt.Sprintf("%s://%s/api/%s/store/", dsn.Scheme, dsn.Host, projectID)
+	authHeader := fmt.Sprintf("Sentry sentry_version=7, sentry_client=alexa-smart-home/1.0, sentry_key=%s",
+		dsn.User.Username())
+
+	return storeURL, authHeader, nil
+}
+
+type event struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Environment string                 `json:"environment,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	Exception   exceptions             `json:"exception"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+}
+
+type exceptions struct {
+	Values []exception `json:"values"`
+}
+
+type exception struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
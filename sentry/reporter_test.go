@@ -0,0 +1,113 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+type httpDoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f httpDoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestReporter(t *testing.T) {
+	req := &alexa.Request{Directive: alexa.RequestDirective{
+		Header:   alexa.Header{Namespace: alexa.NamespacePowerController, Name: "TurnOn", MessageID: "msg-1"},
+		Endpoint: alexa.RequestEndpoint{EndpointID: "lamp"},
+	}}
+
+	tests := map[string]struct {
+		req   *alexa.Request
+		err   error
+		level string
+		tags  map[string]string
+	}{
+		"handler error": {
+			req:   req,
+			err:   errors.New("device offline"),
+			level: "error",
+			tags:  map[string]string{"namespace": alexa.NamespacePowerController, "name": "TurnOn", "endpointId": "lamp"},
+		},
+		"panic": {
+			req:   req,
+			err:   alexa.RecoverPanic("boom"),
+			level: "fatal",
+			tags:  map[string]string{"namespace": alexa.NamespacePowerController, "name": "TurnOn", "endpointId": "lamp"},
+		},
+		"no request": {
+			err:   errors.New("send failed"),
+			level: "error",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var sent event
+			var posts int
+			reporter := &Reporter{
+				DSN:         "https://key@sentry.example.com/42",
+				Environment: "test",
+				HTTPDoer: httpDoerFunc(func(r *http.Request) (*http.Response, error) {
+					posts++
+					if r.URL.String() != "https://sentry.example.com/api/42/store/" {
+						t.Errorf("unexpected url: %s", r.URL)
+					}
+					if !strings.Contains(r.Header.Get("X-Sentry-Auth"), "sentry_key=key") {
+						t.Errorf("unexpected auth header: %s", r.Header.Get("X-Sentry-Auth"))
+					}
+					if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+						t.Errorf("failed to decode event: %v", err)
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+				}),
+			}
+
+			reporter.ReportError(context.Background(), test.req, test.err)
+
+			if posts != 1 {
+				t.Fatalf("expected one event to be posted, got %d", posts)
+			}
+			if sent.Level != test.level || sent.Environment != "test" || len(sent.EventID) != 32 {
+				t.Errorf("unexpected event: %+v", sent)
+			}
+			if len(sent.Exception.Values) != 1 || sent.Exception.Values[0].Value != test.err.Error() {
+				t.Errorf("unexpected exception: %+v", sent.Exception)
+			}
+			for k, v := range test.tags {
+				if sent.Tags[k] != v {
+					t.Errorf("expected tag %s=%s, got %q", k, v, sent.Tags[k])
+				}
+			}
+			if _, ok := sent.Extra["stack"]; ok != (test.level == "fatal") {
+				t.Errorf("expected a stack only for panics: %v", sent.Extra)
+			}
+		})
+	}
+}
+
+func TestReporterInvalidDSN(t *testing.T) {
+	for _, dsn := range []string{
+		"https://sentry.example.com/42",
+		"https://key@sentry.example.com/",
+		"://key@sentry.example.com/42",
+	} {
+		reporter := &Reporter{
+			DSN: dsn,
+			HTTPDoer: httpDoerFunc(func(r *http.Request) (*http.Response, error) {
+				t.Errorf("%s: unexpected request", dsn)
+				return nil, errors.New("unexpected request")
+			}),
+		}
+		if err := reporter.send(context.Background(), nil, errors.New("failed")); err == nil {
+			t.Errorf("%s: expected error", dsn)
+		}
+	}
+}
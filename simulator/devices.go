@@ -0,0 +1,443 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// device holds what all simulated devices share
+type device struct {
+	ID   string
+	Name string
+
+	mu  sync.Mutex
+	sim *Simulator
+}
+
+func (d *device) attach(sim *Simulator) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sim = sim
+}
+
+func (d *device) simulator() (*Simulator, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.sim == nil {
+		return nil, fmt.Errorf("device %s hasn't been added to a simulator", d.ID)
+	}
+	return d.sim, nil
+}
+
+// endpoint describes the device with the Alexa and EndpointHealth capabilities and caps
+func (d *device) endpoint(category string, caps ...alexa.DiscoverCapability) alexa.DiscoverEndpoint {
+	return alexa.DiscoverEndpoint{
+		EndpointID:        d.ID,
+		FriendlyName:      d.Name,
+		Description:       "Simulated " + d.Name,
+		ManufacturerName:  "Simulator",
+		DisplayCategories: []string{category},
+		Capabilities: append([]alexa.DiscoverCapability{
+			{Type: "AlexaInterface", Interface: alexa.NamespaceAlexa, Version: "3"},
+			capability(alexa.NamespaceEndpointHealth, "connectivity"),
+		}, caps...),
+	}
+}
+
+// properties builds the device's properties sampled now, including its connectivity
+func (d *device) properties(sim *Simulator, values ...propertyValue) ([]alexa.ContextProperty, error) {
+	values = append(values, propertyValue{alexa.NamespaceEndpointHealth, "connectivity",
+		alexa.ConnectivityValue{Value: alexa.ConnectivityOK}})
+
+	props := make([]alexa.ContextProperty, 0, len(values))
+	for _, v := range values {
+		prop, err := sim.property(v.namespace, v.name, v.value)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, prop)
+	}
+	return props, nil
+}
+
+// respond answers req with the device's current properties
+func (d *device) respond(ctx context.Context, dev Device, req *alexa.Request) (*alexa.Response, error) {
+	sim, err := d.simulator()
+	if err != nil {
+		return nil, err
+	}
+	props, err := dev.Properties(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	return sim.RespBuilder.BasicResponse(req, props...), nil
+}
+
+// changed reports a change of the named properties made outside of a directive
+func (d *device) changed(ctx context.Context, dev Device, names ...string) {
+	if sim, err := d.simulator(); err == nil {
+		sim.reportChange(ctx, dev, names...)
+	}
+}
+
+type propertyValue struct {
+	namespace string
+	name      string
+	value     interface{}
+}
+
+func capability(iface string, properties ...string) alexa.DiscoverCapability {
+	supported := make([]alexa.DiscoverProperty, 0, len(properties))
+	for _, name := range properties {
+		supported = append(supported, alexa.DiscoverProperty{Name: name})
+	}
+	return alexa.DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: iface,
+		Version:   "3",
+		Properties: &alexa.DiscoverProperties{
+			Supported:           supported,
+			ProactivelyReported: true,
+			Retrievable:         true,
+		},
+	}
+}
+
+func powerState(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// Light is a simulated light that can be turned on and off
+type Light struct {
+	device
+	on bool
+}
+
+// NewLight creates a light that's off
+func NewLight(id, name string) *Light {
+	return &Light{device: device{ID: id, Name: name}}
+}
+
+func (l *Light) Endpoint() alexa.DiscoverEndpoint {
+	return l.endpoint(alexa.DisplayCategoryLight, capability(alexa.InterfacePowerController, "powerState"))
+}
+
+func (l *Light) Properties(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+	sim, err := l.simulator()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	on := l.on
+	l.mu.Unlock()
+
+	return l.properties(sim, propertyValue{alexa.NamespacePowerController, "powerState", powerState(on)})
+}
+
+func (l *Light) HandleRequest(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	set := func(on bool) alexa.HandlerFunc {
+		return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+			l.mu.Lock()
+			l.on = on
+			l.mu.Unlock()
+			return l.respond(ctx, l, req)
+		}
+	}
+
+	switch req.Namespace() {
+	case alexa.NamespacePowerController:
+		return alexa.PowerControllerHandler(set(true), set(false))(ctx, req)
+	default:
+		return nil, alexa.UnexpectedDirective("simulator.Light", req)
+	}
+}
+
+// SetPower simulates the light being switched at the wall
+func (l *Light) SetPower(ctx context.Context, on bool) {
+	l.mu.Lock()
+	changed := l.on != on
+	l.on = on
+	l.mu.Unlock()
+
+	if changed {
+		l.changed(ctx, l, "powerState")
+	}
+}
+
+// Thermostat is a simulated single setpoint thermostat in fahrenheit. Each step moves the
+// temperature towards the setpoint by Rate degrees unless the mode is OFF.
+type Thermostat struct {
+	device
+	// Rate is how far the temperature moves in a step. Defaults to 0.5.
+	Rate float64
+
+	mode        string
+	setpoint    float64
+	temperature float64
+}
+
+// NewThermostat creates a thermostat heating to 70°F from a room at 65°F
+func NewThermostat(id, name string) *Thermostat {
+	return &Thermostat{
+		device:      device{ID: id, Name: name},
+		mode:        alexa.ThermostatModeHeat,
+		setpoint:    70,
+		temperature: 65,
+	}
+}
+
+func (t *Thermostat) Endpoint() alexa.DiscoverEndpoint {
+	return t.endpoint(alexa.DisplayCategoryThermostat,
+		capability(alexa.InterfaceThermostatController, "targetSetpoint", "thermostatMode"),
+		capability(alexa.InterfaceTemperatureSensor, "temperature"))
+}
+
+func (t *Thermostat) Properties(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+	sim, err := t.simulator()
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	mode, setpoint, temperature := t.mode, t.setpoint, t.temperature
+	t.mu.Unlock()
+
+	return t.properties(sim,
+		propertyValue{alexa.NamespaceThermostatController, alexa.PropertyTargetSetpoint, fahrenheit(setpoint)},
+		propertyValue{alexa.NamespaceThermostatController, alexa.PropertyThermostatMode, mode},
+		propertyValue{alexa.NamespaceTemperatureSensor, "temperature", fahrenheit(temperature)})
+}
+
+func fahrenheit(value float64) alexa.TemperatureValue {
+	return alexa.TemperatureValue{Value: float32(value), Scale: alexa.TemperatureScaleFahrenheit}
+}
+
+func (t *Thermostat) HandleRequest(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	if req.Namespace() != alexa.NamespaceThermostatController {
+		return nil, alexa.UnexpectedDirective("simulator.Thermostat", req)
+	}
+	return alexa.ThermostatControllerHandler(
+		alexa.Typed(t.setTargetTemperature),
+		alexa.Typed(t.adjustTargetTemperature),
+		alexa.Typed(t.setThermostatMode),
+		nil,
+	)(ctx, req)
+}
+
+func (t *Thermostat) setTargetTemperature(ctx context.Context, req *alexa.Request,
+	payload alexa.SetTargetTemperaturePayload) (*alexa.Response, error) {
+	if payload.TargetSetpoint == nil {
+		return nil, errors.New("simulated thermostat only supports a single setpoint")
+	}
+	t.mu.Lock()
+	t.setpoint = float64(payload.TargetSetpoint.In(alexa.TemperatureScaleFahrenheit).Value)
+	t.mu.Unlock()
+	return t.respond(ctx, t, req)
+}
+
+func (t *Thermostat) adjustTargetTemperature(ctx context.Context, req *alexa.Request,
+	payload alexa.AdjustTargetTemperaturePayload) (*alexa.Response, error) {
+	t.mu.Lock()
+	t.setpoint += float64(payload.TargetSetpointDelta.DeltaIn(alexa.TemperatureScaleFahrenheit).Value)
+	t.mu.Unlock()
+	return t.respond(ctx, t, req)
+}
+
+func (t *Thermostat) setThermostatMode(ctx context.Context, req *alexa.Request,
+	payload alexa.SetThermostatModePayload) (*alexa.Response, error) {
+	switch mode := payload.ThermostatMode.Value; mode {
+	case alexa.ThermostatModeHeat, alexa.ThermostatModeCool, alexa.ThermostatModeAuto, alexa.ThermostatModeOff:
+		t.mu.Lock()
+		t.mode = mode
+		t.mu.Unlock()
+	default:
+		return nil, fmt.Errorf("unsupported thermostat mode %q", mode)
+	}
+	return t.respond(ctx, t, req)
+}
+
+// Step moves the temperature towards the setpoint and reports the new temperature
+func (t *Thermostat) Step(ctx context.Context) error {
+	rate := t.Rate
+	if rate <= 0 {
+		rate = 0.5
+	}
+
+	t.mu.Lock()
+	before := t.temperature
+	if t.mode != alexa.ThermostatModeOff {
+		switch diff := t.setpoint - t.temperature; {
+		case diff > rate:
+			t.temperature += rate
+		case diff < -rate:
+			t.temperature -= rate
+		default:
+			t.temperature = t.setpoint
+		}
+	}
+	changed := t.temperature != before
+	t.mu.Unlock()
+
+	if changed {
+		t.changed(ctx, t, "temperature")
+	}
+	return nil
+}
+
+// Lock is a simulated smart lock
+type Lock struct {
+	device
+	state string
+}
+
+// NewLock creates a locked lock
+func NewLock(id, name string) *Lock {
+	return &Lock{device: device{ID: id, Name: name}, state: alexa.LockStateLocked}
+}
+
+func (l *Lock) Endpoint() alexa.DiscoverEndpoint {
+	return l.endpoint(alexa.DisplayCategorySmartLock, capability(alexa.InterfaceLockController, "lockState"))
+}
+
+func (l *Lock) Properties(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+	sim, err := l.simulator()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	lockState := l.state
+	l.mu.Unlock()
+
+	return l.properties(sim, propertyValue{alexa.NamespaceLockController, alexa.PropertyLockState, lockState})
+}
+
+func (l *Lock) HandleRequest(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	if req.Namespace() != alexa.NamespaceLockController {
+		return nil, alexa.UnexpectedDirective("simulator.Lock", req)
+	}
+
+	return alexa.LockControllerHandler(l.set(alexa.LockStateLocked), l.set(alexa.LockStateUnlocked))(ctx, req)
+}
+
+func (l *Lock) set(target string) alexa.HandlerFunc {
+	return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		return l.move(ctx, req, target)
+	}
+}
+
+func (l *Lock) move(ctx context.Context, req *alexa.Request, target string) (*alexa.Response, error) {
+	l.mu.Lock()
+	jammed := l.state == alexa.LockStateJammed
+	if !jammed {
+		l.state = target
+	}
+	l.mu.Unlock()
+
+	if jammed {
+		sim, err := l.simulator()
+		if err != nil {
+			return nil, err
+		}
+		return sim.RespBuilder.BasicErrorResponse(req, alexa.ErrorTypeEndpointUnreachable, "lock is jammed")
+	}
+	return l.respond(ctx, l, req)
+}
+
+// SetState simulates the lock being operated by hand or jamming
+func (l *Lock) SetState(ctx context.Context, lockState string) error {
+	switch lockState {
+	case alexa.LockStateLocked, alexa.LockStateUnlocked, alexa.LockStateJammed:
+	default:
+		return fmt.Errorf("unknown lock state %q", lockState)
+	}
+
+	l.mu.Lock()
+	changed := l.state != lockState
+	l.state = lockState
+	l.mu.Unlock()
+
+	if changed {
+		l.changed(ctx, l, "lockState")
+	}
+	return nil
+}
+
+// Sensor is a simulated temperature sensor in fahrenheit. Each step the temperature
+// drifts randomly by up to Drift degrees.
+type Sensor struct {
+	device
+	Drift float64
+	// Rand optionally supplies the drift for repeatable simulations
+	Rand *rand.Rand
+
+	temperature float64
+}
+
+// NewSensor creates a sensor reading temperature that drifts up to drift degrees a step
+func NewSensor(id, name string, temperature, drift float64) *Sensor {
+	return &Sensor{
+		device:      device{ID: id, Name: name},
+		Drift:       drift,
+		temperature: temperature,
+	}
+}
+
+func (s *Sensor) Endpoint() alexa.DiscoverEndpoint {
+	return s.endpoint(alexa.DisplayCategoryTemperatureSensor, capability(alexa.InterfaceTemperatureSensor, "temperature"))
+}
+
+func (s *Sensor) Properties(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+	sim, err := s.simulator()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	temperature := s.temperature
+	s.mu.Unlock()
+
+	return s.properties(sim, propertyValue{alexa.NamespaceTemperatureSensor, "temperature", fahrenheit(temperature)})
+}
+
+// HandleRequest rejects directives since a sensor only reports state
+func (s *Sensor) HandleRequest(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	return nil, alexa.UnexpectedDirective("simulator.Sensor", req)
+}
+
+// SetTemperature simulates a new reading
+func (s *Sensor) SetTemperature(ctx context.Context, temperature float64) {
+	s.mu.Lock()
+	changed := s.temperature != temperature
+	s.temperature = temperature
+	s.mu.Unlock()
+
+	if changed {
+		s.changed(ctx, s, "temperature")
+	}
+}
+
+// Step drifts the temperature
+func (s *Sensor) Step(ctx context.Context) error {
+	if s.Drift == 0 {
+		return nil
+	}
+	random := rand.Float64
+	if s.Rand != nil {
+		random = s.Rand.Float64
+	}
+
+	s.mu.Lock()
+	// round to a tenth of a degree like a real sensor
+	temperature := math.Round((s.temperature+(random()*2-1)*s.Drift)*10) / 10
+	s.mu.Unlock()
+
+	s.SetTemperature(ctx, temperature)
+	return nil
+}
@@ -0,0 +1,211 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/state"
+)
+
+// Device is a virtual endpoint maintained by a Simulator
+type Device interface {
+	alexa.Handler
+	// Endpoint describes the device for discovery
+	Endpoint() alexa.DiscoverEndpoint
+	// Properties returns the current state of the device. It implements state.PropertyProvider.
+	Properties(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error)
+
+	attach(sim *Simulator)
+}
+
+// stepper is implemented by devices whose state changes over time
+type stepper interface {
+	Step(ctx context.Context) error
+}
+
+// Simulator hosts virtual devices for demos, integration tests and the local dev server.
+// It answers discovery, ReportState and the devices' directives. Changes that aren't caused
+// by a directive, such as a thermostat reaching its setpoint, are sent to Reporter.
+type Simulator struct {
+	RespBuilder *alexa.ResponseBuilder
+	// Reporter optionally receives ChangeReports for changes made outside of directives
+	Reporter state.ChangeReporter
+	// Interval between simulation steps in Run. Defaults to 1m.
+	Interval time.Duration
+	// Logger optionally records failed steps and change reports
+	Logger alexa.Logger
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+
+	mu      sync.RWMutex
+	devices map[string]Device
+	order   []string
+}
+
+// Add registers devices with the simulator, replacing any with the same endpoint id
+func (s *Simulator) Add(devices ...Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.devices == nil {
+		s.devices = make(map[string]Device)
+	}
+	for _, device := range devices {
+		device.attach(s)
+		id := device.Endpoint().EndpointID
+		if _, ok := s.devices[id]; !ok {
+			s.order = append(s.order, id)
+		}
+		s.devices[id] = device
+	}
+}
+
+// Device returns the device with the endpoint id
+func (s *Simulator) Device(endpointID string) (Device, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	device, ok := s.devices[endpointID]
+	return device, ok
+}
+
+// Endpoints returns the discovery endpoints of the devices in the order they were added
+func (s *Simulator) Endpoints() []alexa.DiscoverEndpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	endpoints := make([]alexa.DiscoverEndpoint, 0, len(s.order))
+	for _, id := range s.order {
+		endpoints = append(endpoints, s.devices[id].Endpoint())
+	}
+	return endpoints
+}
+
+// HandleRequest answers discovery with the devices' endpoints and passes other directives
+// to the targeted device. ReportState is answered with the device's properties.
+func (s *Simulator) HandleRequest(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	if req.Namespace() == alexa.NamespaceDiscovery {
+		return s.RespBuilder.DiscoverResponse(s.Endpoints()...)
+	}
+
+	device, ok := s.Device(req.EndpointID())
+	if !ok {
+		return s.RespBuilder.BasicErrorResponse(req, alexa.ErrorTypeNoSuchEndpoint,
+			fmt.Sprintf("no simulated device %s", req.EndpointID()))
+	}
+
+	if alexa.IsReportState(req) {
+		props, err := device.Properties(ctx, req.EndpointID())
+		if err != nil {
+			return nil, err
+		}
+		return s.RespBuilder.StateReportResponse(req, props...), nil
+	}
+
+	return device.HandleRequest(ctx, req)
+}
+
+// Run steps the devices every Interval until ctx is done. Run can be added to an
+// agent.Agent as a component.
+func (s *Simulator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Step(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Step advances the simulation of each device that changes over time
+func (s *Simulator) Step(ctx context.Context) {
+	s.mu.RLock()
+	var steppers []stepper
+	for _, id := range s.order {
+		if st, ok := s.devices[id].(stepper); ok {
+			steppers = append(steppers, st)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, st := range steppers {
+		if err := st.Step(ctx); err != nil {
+			s.logger().Log(ctx, "simulation step failed", "error", err)
+		}
+	}
+}
+
+// reportChange sends a ChangeReport for the changed properties of the device with its
+// remaining properties as context
+func (s *Simulator) reportChange(ctx context.Context, device Device, changed ...string) {
+	if s.Reporter == nil {
+		return
+	}
+
+	endpointID := device.Endpoint().EndpointID
+	props, err := device.Properties(ctx, endpointID)
+	if err != nil {
+		s.logger().Log(ctx, "simulated change report failed", "endpointId", endpointID, "error", err)
+		return
+	}
+
+	changedNames := make(map[string]bool, len(changed))
+	for _, name := range changed {
+		changedNames[name] = true
+	}
+	var changedProps, unchanged []alexa.ContextProperty
+	for _, prop := range props {
+		if changedNames[prop.Name] {
+			changedProps = append(changedProps, prop)
+		} else {
+			unchanged = append(unchanged, prop)
+		}
+	}
+
+	if err := s.Reporter.ReportChange(ctx, endpointID, alexa.CausePhysicalInteraction, changedProps, unchanged...); err != nil {
+		s.logger().Log(ctx, "simulated change report failed", "endpointId", endpointID, "error", err)
+	}
+}
+
+func (s *Simulator) interval() time.Duration {
+	if s.Interval <= 0 {
+		return time.Minute
+	}
+	return s.Interval
+}
+
+func (s *Simulator) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *Simulator) logger() alexa.Logger {
+	if s.Logger == nil {
+		return alexa.NopLogger{}
+	}
+	return s.Logger
+}
+
+// property builds a property sampled now
+func (s *Simulator) property(namespace, name string, value interface{}) (alexa.ContextProperty, error) {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return alexa.ContextProperty{}, fmt.Errorf("failed to marshal %s: %v", name, err)
+	}
+	return alexa.ContextProperty{
+		Namespace:    namespace,
+		Name:         name,
+		Value:        valueJSON,
+		TimeOfSample: s.now(),
+	}, nil
+}
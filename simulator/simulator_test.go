@@ -0,0 +1,112 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+type recordingReporter struct {
+	reports []string
+}
+
+func (r *recordingReporter) ReportChange(ctx context.Context, endpointID, cause string,
+	changed []alexa.ContextProperty, unchanged ...alexa.ContextProperty) error {
+	for _, prop := range changed {
+		r.reports = append(r.reports, fmt.Sprintf("%s %s %s=%s", endpointID, cause, prop.Name, prop.Value))
+	}
+	return nil
+}
+
+func directive(namespace, name, endpointID, payload string) *alexa.Request {
+	return &alexa.Request{Directive: alexa.RequestDirective{
+		Header:   alexa.Header{Namespace: namespace, Name: name, MessageID: "msg-1", PayloadVersion: "3"},
+		Endpoint: alexa.RequestEndpoint{EndpointID: endpointID},
+		Payload:  json.RawMessage(payload),
+	}}
+}
+
+func property(t *testing.T, resp *alexa.Response, name string) string {
+	t.Helper()
+	if resp.Context == nil {
+		t.Fatalf("expected context in %s response", resp.Event.Header.Name)
+	}
+	for _, prop := range resp.Context.Properties {
+		if prop.Name == name {
+			return string(prop.Value)
+		}
+	}
+	t.Fatalf("expected %s property", name)
+	return ""
+}
+
+func TestSimulator(t *testing.T) {
+	reporter := &recordingReporter{}
+	sim := &Simulator{
+		RespBuilder: alexa.NewResponseBuilder(),
+		Reporter:    reporter,
+		Now:         func() time.Time { return time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC) },
+	}
+	light := NewLight("light-1", "Lamp")
+	thermostat := NewThermostat("thermostat-1", "Thermostat")
+	thermostat.Rate = 2
+	lock := NewLock("lock-1", "Front Door")
+	sim.Add(light, thermostat, lock)
+
+	ctx := context.Background()
+
+	resp, err := sim.HandleRequest(ctx, directive(alexa.NamespacePowerController, "TurnOn", "light-1", `{}`))
+	if err != nil {
+		t.Fatalf("failed to turn on: %v", err)
+	}
+	if powerState := property(t, resp, "powerState"); powerState != `"ON"` {
+		t.Errorf("expected light on, got %s", powerState)
+	}
+
+	resp, err = sim.HandleRequest(ctx, directive(alexa.NamespaceThermostatController, "SetTargetTemperature", "thermostat-1",
+		`{"targetSetpoint":{"value":20,"scale":"CELSIUS"}}`))
+	if err != nil {
+		t.Fatalf("failed to set temperature: %v", err)
+	}
+	if setpoint := property(t, resp, "targetSetpoint"); setpoint != `{"value":68,"scale":"FAHRENHEIT"}` {
+		t.Errorf("unexpected setpoint %s", setpoint)
+	}
+
+	if err := lock.SetState(ctx, alexa.LockStateJammed); err != nil {
+		t.Fatalf("failed to jam lock: %v", err)
+	}
+	resp, err = sim.HandleRequest(ctx, directive(alexa.NamespaceLockController, "Unlock", "lock-1", `{}`))
+	if err != nil {
+		t.Fatalf("failed to unlock: %v", err)
+	}
+	if resp.Event.Header.Name != "ErrorResponse" {
+		t.Errorf("expected jammed lock to fail, got %s", resp.Event.Header.Name)
+	}
+
+	sim.Step(ctx)
+	sim.Step(ctx)
+	sim.Step(ctx)
+	light.SetPower(ctx, true)
+
+	expected := []string{
+		`lock-1 PHYSICAL_INTERACTION lockState="JAMMED"`,
+		`thermostat-1 PHYSICAL_INTERACTION temperature={"value":67,"scale":"FAHRENHEIT"}`,
+		`thermostat-1 PHYSICAL_INTERACTION temperature={"value":68,"scale":"FAHRENHEIT"}`,
+	}
+	if !reflect.DeepEqual(expected, reporter.reports) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, reporter.reports)
+	}
+
+	resp, err = sim.HandleRequest(ctx, directive(alexa.NamespaceAlexa, "ReportState", "missing", `{}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Event.Header.Name != "ErrorResponse" {
+		t.Errorf("expected error for unknown device, got %s", resp.Event.Header.Name)
+	}
+}
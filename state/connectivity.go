@@ -0,0 +1,174 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// ConnectivityMonitor tracks heartbeats from endpoints to determine their EndpointHealth
+// connectivity. An endpoint is UNREACHABLE once Timeout has passed since its last
+// heartbeat. Transitions are published through Reporter if set.
+type ConnectivityMonitor struct {
+	Timeout  time.Duration
+	Reporter ChangeReporter
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+
+	mu       sync.Mutex
+	lastSeen map[string]time.Time
+	status   map[string]string
+}
+
+func (c *ConnectivityMonitor) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Heartbeat records that the endpoint is reachable. A ChangeReport is sent if it was
+// reported as unreachable. The endpoint is only considered reported as reachable once
+// the ChangeReport succeeds so a failed report is retried on the next heartbeat.
+func (c *ConnectivityMonitor) Heartbeat(ctx context.Context, endpointID string) error {
+	c.mu.Lock()
+	if c.lastSeen == nil {
+		c.lastSeen = make(map[string]time.Time)
+		c.status = make(map[string]string)
+	}
+	c.lastSeen[endpointID] = c.now()
+	previous, known := c.status[endpointID]
+	if !known {
+		c.status[endpointID] = alexa.ConnectivityOK
+	}
+	c.mu.Unlock()
+
+	if !known || previous == alexa.ConnectivityOK {
+		return nil
+	}
+
+	if err := c.report(ctx, endpointID, alexa.ConnectivityOK); err != nil {
+		return err
+	}
+	c.setStatus(endpointID, alexa.ConnectivityOK)
+	return nil
+}
+
+// Check marks endpoints that have missed their heartbeat as unreachable and sends
+// ChangeReports for them. An endpoint whose report fails is checked again next time
+// and the remaining endpoints are still reported.
+func (c *ConnectivityMonitor) Check(ctx context.Context) error {
+	now := c.now()
+
+	var lost []string
+	c.mu.Lock()
+	for endpointID, lastSeen := range c.lastSeen {
+		if c.status[endpointID] == alexa.ConnectivityOK && now.Sub(lastSeen) > c.Timeout {
+			lost = append(lost, endpointID)
+		}
+	}
+	c.mu.Unlock()
+
+	sort.Strings(lost)
+	var failures []string
+	for _, endpointID := range lost {
+		if err := c.report(ctx, endpointID, alexa.ConnectivityUnreachable); err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		c.setStatus(endpointID, alexa.ConnectivityUnreachable)
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func (c *ConnectivityMonitor) setStatus(endpointID, connectivity string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status[endpointID] = connectivity
+}
+
+// Run calls Check every interval until ctx is done. Errors are passed to onError if set.
+func (c *ConnectivityMonitor) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Check(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Connectivity returns the connectivity of the endpoint. Endpoints that have never sent a
+// heartbeat are UNREACHABLE.
+func (c *ConnectivityMonitor) Connectivity(endpointID string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lastSeen, ok := c.lastSeen[endpointID]
+	if !ok || c.now().Sub(lastSeen) > c.Timeout {
+		return alexa.ConnectivityUnreachable
+	}
+	return alexa.ConnectivityOK
+}
+
+// EndpointConnectivity is Connectivity in the form of an alexa.EndpointConnectivity
+func (c *ConnectivityMonitor) EndpointConnectivity(ctx context.Context, endpointID string) (string, error) {
+	return c.Connectivity(endpointID), nil
+}
+
+// Handler wraps handler and replaces the EndpointHealth connectivity property of StateReport
+// and Response events with the monitored connectivity of the endpoint.
+func (c *ConnectivityMonitor) Handler(handler alexa.Handler) alexa.HandlerFunc {
+	return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		resp, err := handler.HandleRequest(ctx, req)
+		if err != nil || resp == nil || resp.Event.Endpoint == nil {
+			return resp, err
+		}
+		if resp.Event.Header.Name != alexa.EventStateReport && resp.Event.Header.Name != "Response" {
+			return resp, nil
+		}
+
+		prop, err := connectivityProperty(c.Connectivity(resp.Event.Endpoint.EndpointID), c.Now)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Context == nil {
+			resp.Context = &alexa.ResponseContext{}
+		}
+		resp.Context.Properties = append(withoutConnectivity(resp.Context.Properties), prop)
+
+		return resp, nil
+	}
+}
+
+func (c *ConnectivityMonitor) report(ctx context.Context, endpointID, connectivity string) error {
+	if c.Reporter == nil {
+		return nil
+	}
+
+	prop, err := connectivityProperty(connectivity, c.Now)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Reporter.ReportChange(ctx, endpointID, alexa.CausePeriodicPoll, []alexa.ContextProperty{prop}); err != nil {
+		return fmt.Errorf("failed to report connectivity of %s: %v", endpointID, err)
+	}
+	return nil
+}
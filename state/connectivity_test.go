@@ -0,0 +1,74 @@
+package state
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func TestConnectivityMonitor(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	reporter := &recordingReporter{failing: map[string]bool{"fan": true}}
+	monitor := &ConnectivityMonitor{
+		Timeout:  time.Minute,
+		Reporter: reporter,
+		Now:      func() time.Time { return now },
+	}
+
+	for _, endpointID := range []string{"fan", "light"} {
+		if err := monitor.Heartbeat(ctx, endpointID); err != nil {
+			t.Fatalf("failed to record heartbeat: %v", err)
+		}
+	}
+	if len(reporter.reports) != 0 {
+		t.Fatalf("expected no reports for first heartbeats: %v", reporter.reports)
+	}
+
+	now = now.Add(2 * time.Minute)
+	err := monitor.Check(ctx)
+	if err == nil || !strings.Contains(err.Error(), "fan") {
+		t.Fatalf("expected fan's report to fail, got %v", err)
+	}
+	expected := []string{`light PERIODIC_POLL connectivity={"value":"UNREACHABLE"}`}
+	if !reflect.DeepEqual(reporter.reports, expected) {
+		t.Errorf("expected light to still be reported:\n%v\ngot:\n%v", expected, reporter.reports)
+	}
+
+	// the failed report is retried on the next check
+	reporter.failing = nil
+	reporter.reports = nil
+	if err := monitor.Check(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []string{`fan PERIODIC_POLL connectivity={"value":"UNREACHABLE"}`}
+	if !reflect.DeepEqual(reporter.reports, expected) {
+		t.Errorf("expected fan to be reported again:\n%v\ngot:\n%v", expected, reporter.reports)
+	}
+
+	// a failed recovery report is retried on the next heartbeat
+	reporter.failing = map[string]bool{"light": true}
+	reporter.reports = nil
+	if err := monitor.Heartbeat(ctx, "light"); err == nil {
+		t.Fatal("expected the recovery report to fail")
+	}
+	reporter.failing = nil
+	if err := monitor.Heartbeat(ctx, "light"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := monitor.Heartbeat(ctx, "light"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []string{`light PERIODIC_POLL connectivity={"value":"OK"}`}
+	if !reflect.DeepEqual(reporter.reports, expected) {
+		t.Errorf("expected a single recovery report:\n%v\ngot:\n%v", expected, reporter.reports)
+	}
+
+	if monitor.Connectivity("light") != alexa.ConnectivityOK || monitor.Connectivity("fan") != alexa.ConnectivityUnreachable {
+		t.Errorf("unexpected connectivity: light %s fan %s", monitor.Connectivity("light"), monitor.Connectivity("fan"))
+	}
+}
@@ -0,0 +1,114 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// PropertyChange is the content of a single ChangeReport: the properties that changed
+// due to Cause and the unchanged properties reported as context.
+type PropertyChange struct {
+	Cause     string
+	Changed   []alexa.ContextProperty
+	Unchanged []alexa.ContextProperty
+}
+
+// Differ compares successive property sets of an endpoint to build ChangeReports
+type Differ struct {
+	// Causes attributes changes of a property key (see PropertyKey) or namespace to a cause
+	Causes map[string]string
+	// DefaultCause is used for properties not in Causes. Defaults to PHYSICAL_INTERACTION.
+	DefaultCause string
+}
+
+func (d *Differ) cause(prop alexa.ContextProperty) string {
+	if cause, ok := d.Causes[PropertyKey(prop)]; ok {
+		return cause
+	}
+	if cause, ok := d.Causes[prop.Namespace]; ok {
+		return cause
+	}
+	if d.DefaultCause != "" {
+		return d.DefaultCause
+	}
+	return alexa.CausePhysicalInteraction
+}
+
+// Diff returns the changes between previous and current grouped by cause, since a
+// ChangeReport carries a single cause. A property is changed if its value differs from
+// previous or it's absent from previous. Properties absent from current are not reported.
+// Changes are ordered by cause.
+func (d *Differ) Diff(previous, current []alexa.ContextProperty) []PropertyChange {
+	previousByKey := make(map[string]alexa.ContextProperty, len(previous))
+	for _, prop := range previous {
+		previousByKey[PropertyKey(prop)] = prop
+	}
+
+	changedByCause := make(map[string][]alexa.ContextProperty)
+	for _, prop := range current {
+		prev, ok := previousByKey[PropertyKey(prop)]
+		if ok && valuesEqual(prev.Value, prop.Value) {
+			continue
+		}
+		cause := d.cause(prop)
+		changedByCause[cause] = append(changedByCause[cause], prop)
+	}
+
+	causes := make([]string, 0, len(changedByCause))
+	for cause := range changedByCause {
+		causes = append(causes, cause)
+	}
+	sort.Strings(causes)
+
+	changes := make([]PropertyChange, 0, len(causes))
+	for _, cause := range causes {
+		changed := changedByCause[cause]
+		changedKeys := make(map[string]bool, len(changed))
+		for _, prop := range changed {
+			changedKeys[PropertyKey(prop)] = true
+		}
+
+		var unchanged []alexa.ContextProperty
+		for _, prop := range current {
+			if !changedKeys[PropertyKey(prop)] {
+				unchanged = append(unchanged, prop)
+			}
+		}
+
+		changes = append(changes, PropertyChange{cause, changed, unchanged})
+	}
+
+	return changes
+}
+
+// Report sends a ChangeReport through reporter for each change between previous and current
+func (d *Differ) Report(ctx context.Context, reporter ChangeReporter, endpointID string,
+	previous, current []alexa.ContextProperty) error {
+	for _, change := range d.Diff(previous, current) {
+		if err := reporter.ReportChange(ctx, endpointID, change.Cause, change.Changed, change.Unchanged...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valuesEqual compares json values ignoring formatting differences
+func valuesEqual(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+
+	var aVal, bVal interface{}
+	if err := json.Unmarshal(a, &aVal); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(b, &bVal); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(aVal, bVal)
+}
@@ -0,0 +1,52 @@
+package state
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func TestDiff(t *testing.T) {
+	previous := []alexa.ContextProperty{
+		{Namespace: alexa.NamespacePowerController, Name: "powerState", Value: json.RawMessage(`"OFF"`)},
+		{Namespace: alexa.NamespaceTemperatureSensor, Name: "temperature", Value: json.RawMessage(`{"value":70,"scale":"FAHRENHEIT"}`)},
+		{Namespace: alexa.NamespacePercentageController, Name: "percentage", Value: json.RawMessage(`50`)},
+	}
+	current := []alexa.ContextProperty{
+		{Namespace: alexa.NamespacePowerController, Name: "powerState", Value: json.RawMessage(`"ON"`)},
+		{Namespace: alexa.NamespaceTemperatureSensor, Name: "temperature", Value: json.RawMessage(`{"scale": "FAHRENHEIT", "value": 72}`)},
+		{Namespace: alexa.NamespacePercentageController, Name: "percentage", Value: json.RawMessage(`50`)},
+	}
+
+	differ := &Differ{
+		Causes: map[string]string{alexa.NamespaceTemperatureSensor: alexa.CausePeriodicPoll},
+	}
+
+	changes := differ.Diff(previous, current)
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes but got %+v", changes)
+	}
+
+	if changes[0].Cause != alexa.CausePeriodicPoll ||
+		len(changes[0].Changed) != 1 || changes[0].Changed[0].Name != "temperature" ||
+		len(changes[0].Unchanged) != 2 {
+		t.Fatalf("unexpected poll change: %+v", changes[0])
+	}
+
+	if changes[1].Cause != alexa.CausePhysicalInteraction ||
+		len(changes[1].Changed) != 1 || changes[1].Changed[0].Name != "powerState" ||
+		len(changes[1].Unchanged) != 2 {
+		t.Fatalf("unexpected physical change: %+v", changes[1])
+	}
+
+	if changes := differ.Diff(current, current); len(changes) != 0 {
+		t.Fatalf("expected no changes but got %+v", changes)
+	}
+
+	reformatted := []alexa.ContextProperty{current[0], current[2],
+		{Namespace: alexa.NamespaceTemperatureSensor, Name: "temperature", Value: json.RawMessage(`{"value":72,"scale":"FAHRENHEIT"}`)}}
+	if changes := differ.Diff(current, reformatted); len(changes) != 0 {
+		t.Fatalf("expected formatting differences to be ignored but got %+v", changes)
+	}
+}
@@ -0,0 +1,132 @@
+package state
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+// StoreProvider supplies the properties recorded in store
+func StoreProvider(store Store) PropertyProvider {
+	return PropertyProviderFunc(store.Get)
+}
+
+// MergeProperties returns base with the properties of overrides replacing the base
+// properties they share a PropertyKey with. Properties only in overrides are appended.
+func MergeProperties(base, overrides []alexa.ContextProperty) []alexa.ContextProperty {
+	overridden := make(map[string]bool, len(overrides))
+	for _, prop := range overrides {
+		overridden[PropertyKey(prop)] = true
+	}
+
+	merged := make([]alexa.ContextProperty, 0, len(base)+len(overrides))
+	for _, prop := range base {
+		if !overridden[PropertyKey(prop)] {
+			merged = append(merged, prop)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+// ContextMerger fills the context of Response and StateReport events with a base set of
+// endpoint properties so handlers only need to return the properties they changed.
+// Properties returned by the handler take precedence over the base properties.
+type ContextMerger struct {
+	// Base optionally supplies the last known properties of the endpoint, e.g. a
+	// StoreProvider or Providers.
+	Base PropertyProvider
+	// Connectivity optionally supplies the EndpointHealth connectivity property
+	Connectivity *ConnectivityMonitor
+	// Logger optionally records failures to merge, see Handler
+	Logger alexa.Logger
+}
+
+// Merge adds the base properties to the context of resp. Events other than Response and
+// StateReport and events without an endpoint are left as is.
+func (m *ContextMerger) Merge(ctx context.Context, resp *alexa.Response) error {
+	if resp == nil || resp.Event.Endpoint == nil {
+		return nil
+	}
+	if resp.Event.Header.Name != alexa.EventStateReport && resp.Event.Header.Name != "Response" {
+		return nil
+	}
+	endpointID := resp.Event.Endpoint.EndpointID
+
+	var base []alexa.ContextProperty
+	if m.Base != nil {
+		props, err := m.Base.Properties(ctx, endpointID)
+		if err != nil {
+			return fmt.Errorf("failed to get base properties of %s: %v", endpointID, err)
+		}
+		base = props
+	}
+	if m.Connectivity != nil {
+		prop, err := connectivityProperty(m.Connectivity.Connectivity(endpointID), m.Connectivity.Now)
+		if err != nil {
+			return err
+		}
+		base = append(withoutConnectivity(base), prop)
+	}
+	if len(base) == 0 {
+		return nil
+	}
+
+	if resp.Context == nil {
+		resp.Context = &alexa.ResponseContext{}
+	}
+	resp.Context.Properties = MergeProperties(base, resp.Context.Properties)
+
+	return nil
+}
+
+// Handler wraps handler and merges the base properties into a copy of its responses so
+// responses the handler shares, e.g. cached for idempotency, aren't modified. If the base
+// properties can't be retrieved the failure is logged and the handler's response is
+// returned as is since the device action has already happened.
+func (m *ContextMerger) Handler(handler alexa.Handler) alexa.HandlerFunc {
+	return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		resp, err := handler.HandleRequest(ctx, req)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+		return m.merged(ctx, resp), nil
+	}
+}
+
+// EventSender wraps sender and merges the base properties into a copy of deferred
+// events before they are sent. Failures to merge are handled as in Handler.
+func (m *ContextMerger) EventSender(sender deferred.EventSender) deferred.EventSender {
+	return deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+		if resp == nil {
+			return sender.Send(ctx, resp)
+		}
+		return sender.Send(ctx, m.merged(ctx, resp))
+	})
+}
+
+// merged returns a copy of resp with the base properties merged in or resp if they
+// couldn't be merged
+func (m *ContextMerger) merged(ctx context.Context, resp *alexa.Response) *alexa.Response {
+	merged := *resp
+	if resp.Context != nil {
+		respContext := *resp.Context
+		merged.Context = &respContext
+	}
+
+	if err := m.Merge(ctx, &merged); err != nil {
+		m.logger().Log(ctx, "failed to merge context properties",
+			"messageId", resp.Event.Header.MessageID,
+			"error", err)
+		return resp
+	}
+	return &merged
+}
+
+func (m *ContextMerger) logger() alexa.Logger {
+	if m.Logger == nil {
+		return alexa.NopLogger{}
+	}
+	return m.Logger
+}
@@ -0,0 +1,137 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func TestContextMerger(t *testing.T) {
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "fan",
+		alexa.ContextProperty{
+			Namespace:    alexa.NamespacePowerController,
+			Name:         "powerState",
+			Value:        json.RawMessage(`"OFF"`),
+			TimeOfSample: now.Add(-time.Hour),
+		},
+		alexa.ContextProperty{
+			Namespace:    alexa.NamespacePercentageController,
+			Name:         "percentage",
+			Value:        json.RawMessage(`50`),
+			TimeOfSample: now.Add(-time.Hour),
+		},
+	); err != nil {
+		t.Fatalf("failed to put state: %v", err)
+	}
+
+	monitor := &ConnectivityMonitor{Timeout: time.Minute, Now: func() time.Time { return now }}
+	if err := monitor.Heartbeat(ctx, "fan"); err != nil {
+		t.Fatalf("failed to record heartbeat: %v", err)
+	}
+
+	respBuilder := &alexa.ResponseBuilder{MessageID: func() string { return "id" }}
+	merger := &ContextMerger{Base: StoreProvider(store), Connectivity: monitor}
+	handler := merger.Handler(alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		return respBuilder.BasicResponse(req, alexa.ContextProperty{
+			Namespace:    alexa.NamespacePowerController,
+			Name:         "powerState",
+			Value:        json.RawMessage(`"ON"`),
+			TimeOfSample: now,
+		}), nil
+	}))
+
+	req := stateRequest("fan")
+	req.Directive.Header.Namespace = alexa.NamespacePowerController
+	req.Directive.Header.Name = "TurnOn"
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	values := make(map[string]string)
+	for _, prop := range resp.Context.Properties {
+		values[PropertyKey(prop)] = string(prop.Value)
+	}
+	expected := map[string]string{
+		"Alexa.PowerController:powerState":      `"ON"`,
+		"Alexa.PercentageController:percentage": `50`,
+		"Alexa.EndpointHealth:connectivity":     `{"value":"OK"}`,
+	}
+	if len(values) != len(expected) {
+		t.Fatalf("expected %d properties, got %v", len(expected), values)
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("expected %s to be %s, got %s", key, value, values[key])
+		}
+	}
+}
+
+func TestContextMergerLeavesResponseUnchanged(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	respBuilder := &alexa.ResponseBuilder{MessageID: func() string { return "id" }}
+
+	req := stateRequest("fan")
+	req.Directive.Header.Namespace = alexa.NamespacePowerController
+	req.Directive.Header.Name = "TurnOn"
+	// a response shared between requests, e.g. by an idempotency cache
+	shared := respBuilder.BasicResponse(req, alexa.ContextProperty{
+		Namespace:    alexa.NamespacePowerController,
+		Name:         "powerState",
+		Value:        json.RawMessage(`"ON"`),
+		TimeOfSample: now,
+	})
+	handler := alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		return shared, nil
+	})
+
+	var logged []string
+	base := PropertyProviderFunc(func(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+		return []alexa.ContextProperty{{
+			Namespace:    alexa.NamespacePercentageController,
+			Name:         "percentage",
+			Value:        json.RawMessage(`50`),
+			TimeOfSample: now,
+		}}, nil
+	})
+	merger := &ContextMerger{
+		Base: base,
+		Logger: alexa.LoggerFunc(func(ctx context.Context, msg string, keyvals ...interface{}) {
+			logged = append(logged, msg)
+		}),
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := merger.Handler(handler)(ctx, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(resp.Context.Properties) != 2 {
+			t.Errorf("expected merged properties, got %+v", resp.Context.Properties)
+		}
+	}
+	if len(shared.Context.Properties) != 1 {
+		t.Errorf("expected the handler's response to be unchanged, got %+v", shared.Context.Properties)
+	}
+
+	merger.Base = PropertyProviderFunc(func(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+		return nil, errors.New("store unavailable")
+	})
+	resp, err := merger.Handler(handler)(ctx, req)
+	if err != nil || resp != shared {
+		t.Errorf("expected the handler's response when base properties fail: %+v %v", resp, err)
+	}
+	if len(logged) != 1 {
+		t.Errorf("expected the failure to be logged, got %v", logged)
+	}
+}
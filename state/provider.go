@@ -0,0 +1,140 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// PropertyProvider supplies the current properties of one capability of an endpoint
+type PropertyProvider interface {
+	Properties(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error)
+}
+
+// PropertyProviderFunc implements PropertyProvider as a func
+type PropertyProviderFunc func(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error)
+
+// Properties calls the PropertyProviderFunc
+func (p PropertyProviderFunc) Properties(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+	return p(ctx, endpointID)
+}
+
+type registeredProvider struct {
+	namespace string
+	instance  string
+	provider  PropertyProvider
+}
+
+// Providers composes the state of endpoints from providers registered for each capability.
+// This gives devices with many interfaces a single place to build StateReports and
+// ChangeReports from.
+type Providers struct {
+	mu        sync.RWMutex
+	endpoints map[string][]registeredProvider
+}
+
+// NewProviders creates an empty Providers
+func NewProviders() *Providers {
+	return &Providers{endpoints: make(map[string][]registeredProvider)}
+}
+
+// Register adds a provider for the capability of the endpoint identified by namespace and,
+// for multi-instance capabilities, instance. A provider registered for the same capability
+// replaces the previous one.
+func (p *Providers) Register(endpointID, namespace, instance string, provider PropertyProvider) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Properties iterates the slice without holding the lock so it's replaced rather
+	// than modified in place
+	registered := registeredProvider{namespace, instance, provider}
+	existing := p.endpoints[endpointID]
+	providers := make([]registeredProvider, len(existing), len(existing)+1)
+	copy(providers, existing)
+	for i, prev := range providers {
+		if prev.namespace == namespace && prev.instance == instance {
+			providers[i] = registered
+			p.endpoints[endpointID] = providers
+			return
+		}
+	}
+	p.endpoints[endpointID] = append(providers, registered)
+}
+
+// Endpoints returns the ids of endpoints with registered providers
+func (p *Providers) Endpoints() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ids := make([]string, 0, len(p.endpoints))
+	for id := range p.endpoints {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Properties returns the properties of the endpoint from each provider in registration order.
+// An error is returned if a provider supplies a property outside of its capability.
+func (p *Providers) Properties(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+	p.mu.RLock()
+	providers := p.endpoints[endpointID]
+	p.mu.RUnlock()
+
+	var props []alexa.ContextProperty
+	for _, registered := range providers {
+		provided, err := registered.provider.Properties(ctx, endpointID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s properties: %v", registered.namespace, err)
+		}
+		for _, prop := range provided {
+			if prop.Namespace != registered.namespace || prop.Instance != registered.instance {
+				return nil, fmt.Errorf("%s provider returned %s property", registered.namespace, PropertyKey(prop))
+			}
+		}
+		props = append(props, provided...)
+	}
+	return props, nil
+}
+
+// ReportStateHandler answers ReportState directives with the properties from the endpoint's providers
+func (p *Providers) ReportStateHandler(respBuilder *alexa.ResponseBuilder) alexa.HandlerFunc {
+	return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		endpointID := req.Directive.Endpoint.EndpointID
+		props, err := p.Properties(ctx, endpointID)
+		if err != nil {
+			return nil, fmt.Errorf("state.Providers: %s: %v", endpointID, err)
+		}
+		if len(props) == 0 {
+			return respBuilder.BasicErrorResponse(req, alexa.ErrorTypeNoSuchEndpoint,
+				fmt.Sprintf("no properties for %s", endpointID))
+		}
+
+		return respBuilder.StateReportResponse(req, props...), nil
+	}
+}
+
+// ReportChange sends a ChangeReport for the changed properties with the remaining
+// properties of the endpoint from its providers as context.
+func (p *Providers) ReportChange(ctx context.Context, reporter ChangeReporter, endpointID, cause string,
+	changed ...alexa.ContextProperty) error {
+	props, err := p.Properties(ctx, endpointID)
+	if err != nil {
+		return fmt.Errorf("state.Providers: %s: %v", endpointID, err)
+	}
+
+	changedKeys := make(map[string]bool, len(changed))
+	for _, prop := range changed {
+		changedKeys[PropertyKey(prop)] = true
+	}
+
+	var unchanged []alexa.ContextProperty
+	for _, prop := range props {
+		if !changedKeys[PropertyKey(prop)] {
+			unchanged = append(unchanged, prop)
+		}
+	}
+
+	return reporter.ReportChange(ctx, endpointID, cause, changed, unchanged...)
+}
@@ -0,0 +1,57 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func TestProvidersRegisterConcurrently(t *testing.T) {
+	powerProvider := func(value string) PropertyProvider {
+		return PropertyProviderFunc(func(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+			return []alexa.ContextProperty{{
+				Namespace: alexa.NamespacePowerController,
+				Name:      "powerState",
+				Value:     json.RawMessage(value),
+			}}, nil
+		})
+	}
+
+	providers := NewProviders()
+	providers.Register("fan", alexa.NamespacePowerController, "", powerProvider(`"OFF"`))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			providers.Register("fan", alexa.NamespacePowerController, "", powerProvider(`"ON"`))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			props, err := providers.Properties(context.Background(), "fan")
+			if err != nil {
+				t.Errorf("failed to get properties: %v", err)
+				return
+			}
+			if len(props) != 1 {
+				t.Errorf("expected 1 property, got %d", len(props))
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	props, err := providers.Properties(context.Background(), "fan")
+	if err != nil {
+		t.Fatalf("failed to get properties: %v", err)
+	}
+	if len(props) != 1 || string(props[0].Value) != `"ON"` {
+		t.Errorf("expected the replaced provider, got %+v", props)
+	}
+}
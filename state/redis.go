@@ -0,0 +1,68 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// RedisHashClient is the subset of redis hash commands used by RedisStore. It can be
+// implemented with a thin adapter over any redis client library.
+type RedisHashClient interface {
+	HSet(ctx context.Context, key string, values map[string]string) error
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	Del(ctx context.Context, key string) error
+}
+
+// RedisStore is a Store that keeps each endpoint's properties in a redis hash named
+// KeyPrefix + endpoint id. Hash fields are property keys holding json encoded properties.
+type RedisStore struct {
+	Redis     RedisHashClient
+	KeyPrefix string
+}
+
+func (r *RedisStore) Put(ctx context.Context, endpointID string, properties ...alexa.ContextProperty) error {
+	if len(properties) == 0 {
+		return nil
+	}
+
+	values := make(map[string]string, len(properties))
+	for _, prop := range properties {
+		propJSON, err := json.Marshal(prop)
+		if err != nil {
+			return fmt.Errorf("failed to marshal property: %v", err)
+		}
+		values[PropertyKey(prop)] = string(propJSON)
+	}
+
+	if err := r.Redis.HSet(ctx, r.KeyPrefix+endpointID, values); err != nil {
+		return fmt.Errorf("failed to set properties: %v", err)
+	}
+	return nil
+}
+
+func (r *RedisStore) Get(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+	values, err := r.Redis.HGetAll(ctx, r.KeyPrefix+endpointID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get properties: %v", err)
+	}
+
+	props := make(map[string]alexa.ContextProperty, len(values))
+	for key, value := range values {
+		var prop alexa.ContextProperty
+		if err := json.Unmarshal([]byte(value), &prop); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal property %s: %v", key, err)
+		}
+		props[key] = prop
+	}
+	return sortedProperties(props), nil
+}
+
+func (r *RedisStore) Delete(ctx context.Context, endpointID string) error {
+	if err := r.Redis.Del(ctx, r.KeyPrefix+endpointID); err != nil {
+		return fmt.Errorf("failed to delete properties: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package state
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+// ChangeReporter publishes ChangeReports for an endpoint
+type ChangeReporter interface {
+	ReportChange(ctx context.Context, endpointID, cause string,
+		changed []alexa.ContextProperty, unchanged ...alexa.ContextProperty) error
+}
+
+// EventChangeReporter sends ChangeReports to the smart home api on behalf of the user
+// owning the endpoint.
+type EventChangeReporter struct {
+	// EndpointUser returns the id of the user owning the endpoint
+	EndpointUser func(ctx context.Context, endpointID string) (string, error)
+	Tokens       alexa.TokenReader
+	EventSender  deferred.EventSender
+	RespBuilder  *alexa.ResponseBuilder
+}
+
+// ReportChange builds and sends a ChangeReport
+func (e *EventChangeReporter) ReportChange(ctx context.Context, endpointID, cause string,
+	changed []alexa.ContextProperty, unchanged ...alexa.ContextProperty) error {
+	userID, err := e.EndpointUser(ctx, endpointID)
+	if err != nil {
+		return fmt.Errorf("failed to find user for %s: %v", endpointID, err)
+	}
+
+	scope, err := deferred.UserScope(ctx, e.Tokens, userID)
+	if err != nil {
+		return err
+	}
+
+	report, err := e.RespBuilder.ChangeReport(scope, endpointID, cause, changed, unchanged...)
+	if err != nil {
+		return fmt.Errorf("failed to build change report: %v", err)
+	}
+
+	if err := e.EventSender.Send(alexa.WithUserID(ctx, userID), report); err != nil {
+		return fmt.Errorf("failed to send change report: %v", err)
+	}
+
+	return nil
+}
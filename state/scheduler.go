@@ -0,0 +1,355 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// Scheduler periodically samples the properties of endpoints that can't report changes
+// themselves, such as polled sensors, and sends a ChangeReport when a value changes
+// significantly. Endpoints are polled one at a time so a slow provider delays the others.
+type Scheduler struct {
+	Reporter ChangeReporter
+	// Store optionally records each sample and supplies the values the first sample of an
+	// endpoint is compared with. Without it the first sample is only recorded as a baseline.
+	Store Store
+	// Differ optionally attributes changes to causes. Defaults to PERIODIC_POLL for all changes.
+	Differ *Differ
+	// MinChange maps a property key (see PropertyKey) or namespace to the smallest change
+	// of a numeric value that's reported. Smaller changes accumulate until they're reported.
+	MinChange map[string]float64
+	// Thresholds maps a property key or namespace to levels that are always reported when
+	// a numeric value crosses them, regardless of MinChange.
+	Thresholds map[string][]float64
+	// Logger optionally records failed polls
+	Logger alexa.Logger
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+
+	mu        sync.Mutex
+	endpoints map[string]*scheduledEndpoint
+	wake      chan struct{}
+}
+
+// minScheduleInterval is the shortest interval an endpoint can be polled at
+const minScheduleInterval = time.Second
+
+type scheduledEndpoint struct {
+	provider PropertyProvider
+	interval time.Duration
+	next     time.Time
+
+	// poll serializes polls of the endpoint and guards reported
+	poll sync.Mutex
+	// reported holds the last reported value of each property keyed by PropertyKey
+	reported map[string]alexa.ContextProperty
+}
+
+// Schedule polls provider for the properties of the endpoint every interval, replacing
+// any existing schedule for the endpoint. Intervals shorter than a second are raised to
+// a second.
+func (s *Scheduler) Schedule(endpointID string, interval time.Duration, provider PropertyProvider) {
+	if interval < minScheduleInterval {
+		interval = minScheduleInterval
+	}
+
+	s.mu.Lock()
+	if s.endpoints == nil {
+		s.endpoints = make(map[string]*scheduledEndpoint)
+	}
+	s.endpoints[endpointID] = &scheduledEndpoint{
+		provider: provider,
+		interval: interval,
+		next:     s.now(),
+	}
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// Unschedule stops polling the endpoint
+func (s *Scheduler) Unschedule(endpointID string) {
+	s.mu.Lock()
+	delete(s.endpoints, endpointID)
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// Run polls each endpoint when it's due until ctx is done. Failed polls are logged and
+// retried at the next interval. Run can be added to an agent.Agent as a component.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.wake == nil {
+		s.wake = make(chan struct{}, 1)
+	}
+	wake := s.wake
+	s.mu.Unlock()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		for _, endpointID := range s.due() {
+			if err := s.Poll(ctx, endpointID); err != nil {
+				s.logger().Log(ctx, "scheduled poll failed", "endpointId", endpointID, "error", err)
+			}
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if wait, ok := s.untilNext(); ok {
+			timer.Reset(wait)
+		}
+
+		select {
+		case <-timer.C:
+		case <-wake:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Poll samples the endpoint's properties now and reports any significant changes. Polls
+// of the same endpoint wait for each other.
+func (s *Scheduler) Poll(ctx context.Context, endpointID string) error {
+	s.mu.Lock()
+	scheduled, ok := s.endpoints[endpointID]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("endpoint %s isn't scheduled", endpointID)
+	}
+
+	scheduled.poll.Lock()
+	defer scheduled.poll.Unlock()
+
+	current, err := scheduled.provider.Properties(ctx, endpointID)
+	if err != nil {
+		return fmt.Errorf("failed to get properties of %s: %v", endpointID, err)
+	}
+
+	reported := scheduled.reported
+	if reported == nil {
+		reported = make(map[string]alexa.ContextProperty)
+		if s.Store != nil {
+			stored, err := s.Store.Get(ctx, endpointID)
+			if err != nil {
+				return fmt.Errorf("failed to get stored properties of %s: %v", endpointID, err)
+			}
+			for _, prop := range stored {
+				reported[PropertyKey(prop)] = prop
+			}
+		}
+	}
+	baseline := len(reported) == 0
+
+	if s.Store != nil && len(current) > 0 {
+		if err := s.Store.Put(ctx, endpointID, current...); err != nil {
+			return fmt.Errorf("failed to store properties of %s: %v", endpointID, err)
+		}
+	}
+
+	var changed []alexa.ContextProperty
+	for _, prop := range current {
+		key := PropertyKey(prop)
+		prev, ok := reported[key]
+		if baseline {
+			reported[key] = prop
+			continue
+		}
+		if ok && !s.significant(prev, prop) {
+			continue
+		}
+		changed = append(changed, prop)
+	}
+
+	scheduled.reported = reported
+	if err := s.report(ctx, endpointID, current, changed); err != nil {
+		// the unreported changes are compared again on the next poll
+		return err
+	}
+
+	for _, prop := range changed {
+		reported[PropertyKey(prop)] = prop
+	}
+
+	return nil
+}
+
+// report sends a ChangeReport per cause with the remaining current properties as context
+func (s *Scheduler) report(ctx context.Context, endpointID string, current, changed []alexa.ContextProperty) error {
+	if len(changed) == 0 {
+		return nil
+	}
+
+	differ := s.Differ
+	if differ == nil {
+		differ = &Differ{DefaultCause: alexa.CausePeriodicPoll}
+	}
+
+	changedByCause := make(map[string][]alexa.ContextProperty)
+	for _, prop := range changed {
+		cause := differ.cause(prop)
+		changedByCause[cause] = append(changedByCause[cause], prop)
+	}
+
+	causes := make([]string, 0, len(changedByCause))
+	for cause := range changedByCause {
+		causes = append(causes, cause)
+	}
+	sort.Strings(causes)
+
+	for _, cause := range causes {
+		causeChanged := changedByCause[cause]
+		causeKeys := make(map[string]bool, len(causeChanged))
+		for _, prop := range causeChanged {
+			causeKeys[PropertyKey(prop)] = true
+		}
+
+		var unchanged []alexa.ContextProperty
+		for _, prop := range current {
+			if !causeKeys[PropertyKey(prop)] {
+				unchanged = append(unchanged, prop)
+			}
+		}
+
+		if err := s.Reporter.ReportChange(ctx, endpointID, cause, causeChanged, unchanged...); err != nil {
+			return fmt.Errorf("failed to report change of %s: %v", endpointID, err)
+		}
+	}
+
+	return nil
+}
+
+// significant reports if the change from prev to current should be reported
+func (s *Scheduler) significant(prev, current alexa.ContextProperty) bool {
+	if valuesEqual(prev.Value, current.Value) {
+		return false
+	}
+
+	prevVal, prevOK := numericValue(prev.Value)
+	currentVal, currentOK := numericValue(current.Value)
+	if !prevOK || !currentOK {
+		return true
+	}
+
+	for _, level := range s.lookupThresholds(current) {
+		if (prevVal < level) != (currentVal < level) {
+			return true
+		}
+	}
+
+	minChange, ok := s.lookupMinChange(current)
+	return !ok || math.Abs(currentVal-prevVal) >= minChange
+}
+
+func (s *Scheduler) lookupMinChange(prop alexa.ContextProperty) (float64, bool) {
+	if minChange, ok := s.MinChange[PropertyKey(prop)]; ok {
+		return minChange, true
+	}
+	minChange, ok := s.MinChange[prop.Namespace]
+	return minChange, ok
+}
+
+func (s *Scheduler) lookupThresholds(prop alexa.ContextProperty) []float64 {
+	if levels, ok := s.Thresholds[PropertyKey(prop)]; ok {
+		return levels
+	}
+	return s.Thresholds[prop.Namespace]
+}
+
+// numericValue reads a number or an object with a numeric value field, e.g. a
+// TemperatureValue, from a property value
+func numericValue(value json.RawMessage) (float64, bool) {
+	var number float64
+	if err := json.Unmarshal(value, &number); err == nil {
+		return number, true
+	}
+
+	var object struct {
+		Value *float64 `json:"value"`
+	}
+	if err := json.Unmarshal(value, &object); err == nil && object.Value != nil {
+		return *object.Value, true
+	}
+	return 0, false
+}
+
+// due returns the endpoints whose next poll is due and schedules their following poll
+func (s *Scheduler) due() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var due []string
+	for endpointID, scheduled := range s.endpoints {
+		if !scheduled.next.After(now) {
+			due = append(due, endpointID)
+			scheduled.next = now.Add(scheduled.interval)
+		}
+	}
+	sort.Strings(due)
+	return due
+}
+
+// untilNext returns the time until the next poll is due if any endpoints are scheduled
+func (s *Scheduler) untilNext() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	for _, scheduled := range s.endpoints {
+		if next.IsZero() || scheduled.next.Before(next) {
+			next = scheduled.next
+		}
+	}
+	if next.IsZero() {
+		return 0, false
+	}
+	wait := next.Sub(s.now())
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+// notify wakes Run to pick up schedule changes
+func (s *Scheduler) notify() {
+	s.mu.Lock()
+	wake := s.wake
+	s.mu.Unlock()
+
+	if wake == nil {
+		return
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *Scheduler) logger() alexa.Logger {
+	if s.Logger == nil {
+		return alexa.NopLogger{}
+	}
+	return s.Logger
+}
@@ -0,0 +1,121 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+type recordingReporter struct {
+	reports []string
+	// failing endpoints have their reports rejected
+	failing map[string]bool
+}
+
+func (r *recordingReporter) ReportChange(ctx context.Context, endpointID, cause string,
+	changed []alexa.ContextProperty, unchanged ...alexa.ContextProperty) error {
+	if r.failing[endpointID] {
+		return fmt.Errorf("%s rejected", endpointID)
+	}
+	for _, prop := range changed {
+		r.reports = append(r.reports, fmt.Sprintf("%s %s %s=%s", endpointID, cause, prop.Name, prop.Value))
+	}
+	return nil
+}
+
+func TestSchedulerPoll(t *testing.T) {
+	reporter := &recordingReporter{}
+	scheduler := &Scheduler{
+		Reporter:   reporter,
+		MinChange:  map[string]float64{alexa.NamespaceTemperatureSensor: 2},
+		Thresholds: map[string][]float64{alexa.NamespaceTemperatureSensor: {80}},
+	}
+
+	var temperature float64
+	scheduler.Schedule("sensor", 0, PropertyProviderFunc(func(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+		value, err := json.Marshal(alexa.TemperatureValue{Value: float32(temperature), Scale: alexa.TemperatureScaleFahrenheit})
+		if err != nil {
+			return nil, err
+		}
+		return []alexa.ContextProperty{{Namespace: alexa.NamespaceTemperatureSensor, Name: "temperature", Value: value}}, nil
+	}))
+
+	ctx := context.Background()
+	for _, temperature = range []float64{70, 71, 72, 72.5, 79, 80.5} {
+		if err := scheduler.Poll(ctx, "sensor"); err != nil {
+			t.Fatalf("failed to poll: %v", err)
+		}
+	}
+
+	expected := []string{
+		`sensor PERIODIC_POLL temperature={"value":72,"scale":"FAHRENHEIT"}`,
+		`sensor PERIODIC_POLL temperature={"value":79,"scale":"FAHRENHEIT"}`,
+		`sensor PERIODIC_POLL temperature={"value":80.5,"scale":"FAHRENHEIT"}`,
+	}
+	if !reflect.DeepEqual(expected, reporter.reports) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, reporter.reports)
+	}
+
+	if err := scheduler.Poll(ctx, "unknown"); err == nil {
+		t.Error("expected error for unscheduled endpoint")
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	reporter := &recordingReporter{}
+	scheduler := &Scheduler{Reporter: reporter}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- scheduler.Run(ctx) }()
+
+	polled := make(chan struct{}, 10)
+	var mu sync.Mutex
+	power := "OFF"
+	scheduler.Schedule("switch", -time.Second, PropertyProviderFunc(func(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		defer func() { polled <- struct{}{} }()
+		return []alexa.ContextProperty{{Namespace: alexa.NamespacePowerController, Name: "powerState", Value: json.RawMessage(`"` + power + `"`)}}, nil
+	}))
+
+	// scheduling wakes Run to poll the new endpoint right away
+	select {
+	case <-polled:
+	case <-time.After(time.Second / 2):
+		t.Fatal("expected Run to poll the scheduled endpoint")
+	}
+
+	// polls alongside Run don't race with it
+	mu.Lock()
+	power = "ON"
+	mu.Unlock()
+	if err := scheduler.Poll(ctx, "switch"); err != nil {
+		t.Fatalf("failed to poll: %v", err)
+	}
+	<-polled
+
+	scheduler.mu.Lock()
+	interval := scheduler.endpoints["switch"].interval
+	scheduler.mu.Unlock()
+	if interval != time.Second {
+		t.Errorf("expected the interval to be raised to a second but got %s", interval)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{`switch PERIODIC_POLL powerState="ON"`}
+	if !reflect.DeepEqual(expected, reporter.reports) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, reporter.reports)
+	}
+}
@@ -0,0 +1,128 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// StaleAction determines how stale properties are reported
+type StaleAction int
+
+const (
+	// StaleUnreachableError responds with an ENDPOINT_UNREACHABLE error if any property is stale
+	StaleUnreachableError StaleAction = iota
+	// StaleMarkUnreachable omits stale properties and reports the endpoint's
+	// EndpointHealth connectivity as UNREACHABLE
+	StaleMarkUnreachable
+)
+
+// Staleness describes how old the properties of an endpoint may be before they're stale
+type Staleness struct {
+	// TTLs maps a property key (see PropertyKey) or namespace to the maximum age of the property
+	TTLs map[string]time.Duration
+	// DefaultTTL applies to properties not in TTLs. Zero means they never become stale.
+	DefaultTTL time.Duration
+	Action     StaleAction
+}
+
+// ttl returns the maximum age of prop or zero if it doesn't expire
+func (s *Staleness) ttl(prop alexa.ContextProperty) time.Duration {
+	if ttl, ok := s.TTLs[PropertyKey(prop)]; ok {
+		return ttl
+	}
+	if ttl, ok := s.TTLs[prop.Namespace]; ok {
+		return ttl
+	}
+	return s.DefaultTTL
+}
+
+// StalenessPolicy configures staleness per endpoint
+type StalenessPolicy struct {
+	Default   Staleness
+	Endpoints map[string]Staleness
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+}
+
+func (s *StalenessPolicy) staleness(endpointID string) *Staleness {
+	if staleness, ok := s.Endpoints[endpointID]; ok {
+		return &staleness
+	}
+	return &s.Default
+}
+
+// split separates props into fresh and stale properties for the endpoint
+func (s *StalenessPolicy) split(endpointID string, props []alexa.ContextProperty) (fresh, stale []alexa.ContextProperty) {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	staleness := s.staleness(endpointID)
+	for _, prop := range props {
+		ttl := staleness.ttl(prop)
+		if ttl > 0 && now().Sub(prop.TimeOfSample) > ttl {
+			stale = append(stale, prop)
+		} else {
+			fresh = append(fresh, prop)
+		}
+	}
+	return fresh, stale
+}
+
+// StalenessReportStateHandler answers ReportState directives with the properties recorded in
+// store, applying policy to properties that haven't been updated recently.
+func StalenessReportStateHandler(store Store, policy *StalenessPolicy, respBuilder *alexa.ResponseBuilder) alexa.HandlerFunc {
+	return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		endpointID := req.Directive.Endpoint.EndpointID
+		props, err := store.Get(ctx, endpointID)
+		if err != nil {
+			return nil, fmt.Errorf("state.ReportStateHandler: failed to get state for %s: %v", endpointID, err)
+		}
+		if len(props) == 0 {
+			return respBuilder.BasicErrorResponse(req, alexa.ErrorTypeEndpointUnreachable,
+				fmt.Sprintf("no state recorded for %s", endpointID))
+		}
+		if policy == nil {
+			return respBuilder.StateReportResponse(req, props...), nil
+		}
+
+		fresh, stale := policy.split(endpointID, props)
+		if len(stale) == 0 {
+			return respBuilder.StateReportResponse(req, props...), nil
+		}
+
+		switch policy.staleness(endpointID).Action {
+		case StaleMarkUnreachable:
+			unreachable, err := connectivityProperty(alexa.ConnectivityUnreachable, policy.Now)
+			if err != nil {
+				return nil, err
+			}
+			return respBuilder.StateReportResponse(req, append(withoutConnectivity(fresh), unreachable)...), nil
+		default:
+			return respBuilder.BasicErrorResponse(req, alexa.ErrorTypeEndpointUnreachable,
+				fmt.Sprintf("state of %s is stale", endpointID))
+		}
+	}
+}
+
+func connectivityProperty(connectivity string, now func() time.Time) (alexa.ContextProperty, error) {
+	if now == nil {
+		now = time.Now
+	}
+	return alexa.EndpointHealthProperty(connectivity, "", now())
+}
+
+func withoutConnectivity(props []alexa.ContextProperty) []alexa.ContextProperty {
+	filtered := make([]alexa.ContextProperty, 0, len(props)+1)
+	for _, prop := range props {
+		if prop.Namespace == alexa.NamespaceEndpointHealth && prop.Name == alexa.PropertyConnectivity {
+			continue
+		}
+		filtered = append(filtered, prop)
+	}
+	return filtered
+}
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+func TestStalenessReportStateHandler(t *testing.T) {
+	now := time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	props := []alexa.ContextProperty{
+		{
+			Namespace:    alexa.NamespacePowerController,
+			Name:         "powerState",
+			Value:        json.RawMessage(`"ON"`),
+			TimeOfSample: now.Add(-time.Minute),
+		},
+		{
+			Namespace:    alexa.NamespaceTemperatureSensor,
+			Name:         "temperature",
+			Value:        json.RawMessage(`{"value":70,"scale":"FAHRENHEIT"}`),
+			TimeOfSample: now.Add(-time.Hour),
+		},
+	}
+	for _, endpointID := range []string{"error", "mark"} {
+		if err := store.Put(ctx, endpointID, props...); err != nil {
+			t.Fatalf("failed to put state: %v", err)
+		}
+	}
+
+	policy := &StalenessPolicy{
+		Default: Staleness{
+			TTLs:       map[string]time.Duration{alexa.NamespaceTemperatureSensor: 30 * time.Minute},
+			DefaultTTL: 5 * time.Minute,
+		},
+		Endpoints: map[string]Staleness{
+			"mark": {DefaultTTL: 5 * time.Minute, Action: StaleMarkUnreachable},
+		},
+		Now: func() time.Time { return now },
+	}
+	handler := StalenessReportStateHandler(store, policy, &alexa.ResponseBuilder{MessageID: func() string { return "id" }})
+
+	resp, err := handler(ctx, stateRequest("error"))
+	if err != nil {
+		t.Fatalf("failed to handle: %v", err)
+	}
+	if resp.Event.Header.Name != "ErrorResponse" {
+		t.Fatalf("expected error response but got %s", resp.Event.Header.Name)
+	}
+
+	resp, err = handler(ctx, stateRequest("mark"))
+	if err != nil {
+		t.Fatalf("failed to handle: %v", err)
+	}
+	if resp.Event.Header.Name != "StateReport" {
+		t.Fatalf("expected state report but got %s", resp.Event.Header.Name)
+	}
+	got := resp.Context.Properties
+	if len(got) != 2 || got[0].Name != "powerState" || got[1].Namespace != alexa.NamespaceEndpointHealth ||
+		string(got[1].Value) != `{"value":"UNREACHABLE"}` {
+		t.Fatalf("unexpected properties: %+v", got)
+	}
+
+	policy.Now = func() time.Time { return now.Add(-50 * time.Minute) }
+	resp, err = handler(ctx, stateRequest("error"))
+	if err != nil {
+		t.Fatalf("failed to handle: %v", err)
+	}
+	if resp.Event.Header.Name != "StateReport" || len(resp.Context.Properties) != 2 {
+		t.Fatalf("expected fresh state report but got %+v", resp)
+	}
+}
+
+func stateRequest(endpointID string) *alexa.Request {
+	req := &alexa.Request{}
+	req.Directive.Header.Namespace = alexa.NamespaceAlexa
+	req.Directive.Header.Name = "ReportState"
+	req.Directive.Endpoint.EndpointID = endpointID
+	return req
+}
@@ -0,0 +1,116 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// Store holds the most recently reported value of each endpoint property so state
+// requests can be answered without contacting the device. Endpoint ids are
+// expected to be unique across users.
+type Store interface {
+	// Put records the properties, replacing earlier values of the same properties
+	Put(ctx context.Context, endpointID string, properties ...alexa.ContextProperty) error
+	// Get returns all recorded properties of the endpoint
+	Get(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error)
+	// Delete removes all recorded properties of the endpoint
+	Delete(ctx context.Context, endpointID string) error
+}
+
+// PropertyKey identifies a property of an endpoint
+func PropertyKey(prop alexa.ContextProperty) string {
+	if prop.Instance != "" {
+		return prop.Namespace + ":" + prop.Instance + ":" + prop.Name
+	}
+	return prop.Namespace + ":" + prop.Name
+}
+
+// ReportStateHandler answers ReportState directives with the properties recorded in store.
+// An ENDPOINT_UNREACHABLE error response is returned if nothing is recorded for the endpoint.
+func ReportStateHandler(store Store, respBuilder *alexa.ResponseBuilder) alexa.HandlerFunc {
+	return StalenessReportStateHandler(store, nil, respBuilder)
+}
+
+// EndpointLister lists the ids of the endpoints discovered for a user
+type EndpointLister interface {
+	ListEndpointIDs(ctx context.Context, userID string) ([]string, error)
+}
+
+// UserStateClearer removes the state of all endpoints belonging to a user
+type UserStateClearer struct {
+	Store     Store
+	Endpoints EndpointLister
+}
+
+// ClearUser deletes the recorded state of each of the user's endpoints
+func (u *UserStateClearer) ClearUser(ctx context.Context, userID string) error {
+	endpointIDs, err := u.Endpoints.ListEndpointIDs(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list endpoints: %v", err)
+	}
+
+	for _, endpointID := range endpointIDs {
+		if err := u.Store.Delete(ctx, endpointID); err != nil {
+			return fmt.Errorf("failed to delete state for %s: %v", endpointID, err)
+		}
+	}
+	return nil
+}
+
+// MemoryStore is a Store that holds properties in memory
+type MemoryStore struct {
+	mu        sync.RWMutex
+	endpoints map[string]map[string]alexa.ContextProperty
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{endpoints: make(map[string]map[string]alexa.ContextProperty)}
+}
+
+func (m *MemoryStore) Put(ctx context.Context, endpointID string, properties ...alexa.ContextProperty) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.endpoints[endpointID] == nil {
+		m.endpoints[endpointID] = make(map[string]alexa.ContextProperty)
+	}
+	for _, prop := range properties {
+		m.endpoints[endpointID][PropertyKey(prop)] = prop
+	}
+	return nil
+}
+
+// Get returns the endpoint's properties ordered by key
+func (m *MemoryStore) Get(ctx context.Context, endpointID string) ([]alexa.ContextProperty, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return sortedProperties(m.endpoints[endpointID]), nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, endpointID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.endpoints, endpointID)
+	return nil
+}
+
+func sortedProperties(props map[string]alexa.ContextProperty) []alexa.ContextProperty {
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]alexa.ContextProperty, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, props[key])
+	}
+	return sorted
+}
@@ -0,0 +1,235 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// memoryRedis implements RedisHashClient with maps
+type memoryRedis struct {
+	mu     sync.Mutex
+	hashes map[string]map[string]string
+}
+
+func (m *memoryRedis) HSet(ctx context.Context, key string, values map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hashes[key] == nil {
+		m.hashes[key] = make(map[string]string)
+	}
+	for field, value := range values {
+		m.hashes[key][field] = value
+	}
+	return nil
+}
+
+func (m *memoryRedis) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	values := make(map[string]string, len(m.hashes[key]))
+	for field, value := range m.hashes[key] {
+		values[field] = value
+	}
+	return values, nil
+}
+
+func (m *memoryRedis) Del(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.hashes, key)
+	return nil
+}
+
+func property(namespace, instance, name, value string) alexa.ContextProperty {
+	return alexa.ContextProperty{
+		Namespace:    namespace,
+		Instance:     instance,
+		Name:         name,
+		Value:        json.RawMessage(value),
+		TimeOfSample: time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func propertyValues(props []alexa.ContextProperty) string {
+	var values []string
+	for _, prop := range props {
+		values = append(values, PropertyKey(prop)+"="+string(prop.Value))
+	}
+	return strings.Join(values, " ")
+}
+
+func TestStores(t *testing.T) {
+	redis := &memoryRedis{hashes: make(map[string]map[string]string)}
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"redis":  &RedisStore{Redis: redis, KeyPrefix: "state:"},
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			props, err := store.Get(ctx, "fan")
+			if err != nil || len(props) != 0 {
+				t.Fatalf("expected no properties, got %v %v", props, err)
+			}
+
+			if err := store.Put(ctx, "fan",
+				property(alexa.NamespacePowerController, "", "powerState", `"ON"`),
+				property(alexa.NamespaceToggleController, "Fan.Oscillate", "toggleState", `"ON"`),
+				property(alexa.NamespaceToggleController, "Fan.Light", "toggleState", `"OFF"`),
+			); err != nil {
+				t.Fatalf("failed to put: %v", err)
+			}
+			if err := store.Put(ctx, "fan", property(alexa.NamespacePowerController, "", "powerState", `"OFF"`)); err != nil {
+				t.Fatalf("failed to put: %v", err)
+			}
+			if err := store.Put(ctx, "lamp", property(alexa.NamespacePowerController, "", "powerState", `"ON"`)); err != nil {
+				t.Fatalf("failed to put: %v", err)
+			}
+
+			props, err = store.Get(ctx, "fan")
+			if err != nil {
+				t.Fatalf("failed to get: %v", err)
+			}
+			expected := `Alexa.PowerController:powerState="OFF" ` +
+				`Alexa.ToggleController:Fan.Light:toggleState="OFF" ` +
+				`Alexa.ToggleController:Fan.Oscillate:toggleState="ON"`
+			if values := propertyValues(props); values != expected {
+				t.Errorf("expected %s, got %s", expected, values)
+			}
+			if !props[0].TimeOfSample.Equal(time.Date(2021, 2, 1, 12, 0, 0, 0, time.UTC)) {
+				t.Errorf("expected time of sample to be kept, got %s", props[0].TimeOfSample)
+			}
+
+			if err := store.Delete(ctx, "fan"); err != nil {
+				t.Fatalf("failed to delete: %v", err)
+			}
+			if props, err := store.Get(ctx, "fan"); err != nil || len(props) != 0 {
+				t.Errorf("expected deleted properties, got %v %v", props, err)
+			}
+			if props, err := store.Get(ctx, "lamp"); err != nil || len(props) != 1 {
+				t.Errorf("expected other endpoints to be kept, got %v %v", props, err)
+			}
+		})
+	}
+
+	if _, ok := redis.hashes["state:lamp"]; !ok {
+		t.Errorf("expected redis keys to be prefixed: %v", redis.hashes)
+	}
+}
+
+func TestReportStateHandler(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	if err := store.Put(ctx, "lamp",
+		property(alexa.NamespacePowerController, "", "powerState", `"ON"`),
+		property(alexa.NamespaceBrightnessController, "", "brightness", `40`),
+	); err != nil {
+		t.Fatalf("failed to put state: %v", err)
+	}
+
+	handler := ReportStateHandler(store, &alexa.ResponseBuilder{MessageID: func() string { return "id" }})
+
+	resp, err := handler(ctx, stateRequest("lamp"))
+	if err != nil {
+		t.Fatalf("failed to handle: %v", err)
+	}
+	if resp.Event.Header.Name != "StateReport" || resp.Event.Endpoint.EndpointID != "lamp" {
+		t.Fatalf("expected state report for lamp but got %+v", resp.Event)
+	}
+	expected := `Alexa.BrightnessController:brightness=40 Alexa.PowerController:powerState="ON"`
+	if values := propertyValues(resp.Context.Properties); values != expected {
+		t.Errorf("expected %s, got %s", expected, values)
+	}
+
+	resp, err = handler(ctx, stateRequest("unknown"))
+	if err != nil {
+		t.Fatalf("failed to handle: %v", err)
+	}
+	if resp.Event.Header.Name != "ErrorResponse" ||
+		!strings.Contains(string(resp.Event.Payload), alexa.ErrorTypeEndpointUnreachable) {
+		t.Errorf("expected unreachable error but got %s %s", resp.Event.Header.Name, resp.Event.Payload)
+	}
+}
+
+type endpointListerFunc func(ctx context.Context, userID string) ([]string, error)
+
+func (f endpointListerFunc) ListEndpointIDs(ctx context.Context, userID string) ([]string, error) {
+	return f(ctx, userID)
+}
+
+func TestUserStateClearer(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	for _, endpointID := range []string{"lamp", "fan", "other"} {
+		if err := store.Put(ctx, endpointID, property(alexa.NamespacePowerController, "", "powerState", `"ON"`)); err != nil {
+			t.Fatalf("failed to put state: %v", err)
+		}
+	}
+
+	clearer := &UserStateClearer{
+		Store: store,
+		Endpoints: endpointListerFunc(func(ctx context.Context, userID string) ([]string, error) {
+			if userID != "user-1" {
+				return nil, fmt.Errorf("unknown user %s", userID)
+			}
+			return []string{"lamp", "fan", "missing"}, nil
+		}),
+	}
+
+	if err := clearer.ClearUser(ctx, "user-1"); err != nil {
+		t.Fatalf("failed to clear user: %v", err)
+	}
+	for endpointID, remaining := range map[string]int{"lamp": 0, "fan": 0, "other": 1} {
+		if props, _ := store.Get(ctx, endpointID); len(props) != remaining {
+			t.Errorf("expected %d properties for %s, got %v", remaining, endpointID, props)
+		}
+	}
+
+	if err := clearer.ClearUser(ctx, "user-2"); err == nil {
+		t.Error("expected error for unknown user")
+	}
+}
+
+var errUnavailable = errors.New("redis unavailable")
+
+type failingRedis struct{}
+
+func (failingRedis) HSet(ctx context.Context, key string, values map[string]string) error {
+	return errUnavailable
+}
+
+func (failingRedis) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return map[string]string{"Alexa.PowerController:powerState": "not json"}, nil
+}
+
+func (failingRedis) Del(ctx context.Context, key string) error {
+	return errUnavailable
+}
+
+func TestRedisStoreErrors(t *testing.T) {
+	store := &RedisStore{Redis: failingRedis{}}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "lamp"); err != nil {
+		t.Errorf("expected putting no properties to skip redis, got %v", err)
+	}
+	if err := store.Put(ctx, "lamp", property(alexa.NamespacePowerController, "", "powerState", `"ON"`)); err == nil {
+		t.Error("expected put error")
+	}
+	if _, err := store.Get(ctx, "lamp"); err == nil {
+		t.Error("expected error for an invalid property")
+	}
+	if err := store.Delete(ctx, "lamp"); err == nil {
+		t.Error("expected delete error")
+	}
+}
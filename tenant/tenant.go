@@ -0,0 +1,258 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+)
+
+// Tenant is one of several skills hosted on shared infrastructure
+type Tenant struct {
+	// ClientID of the skill's account linking client. Requests are routed to the
+	// tenant whose ClientID the bearer token was issued to.
+	ClientID string
+	// TokenStore holds the tenant's user tokens. With Credentials it's used to handle
+	// AcceptGrant directives for the tenant. Router.EventSender uses it to find the
+	// tenant of a user.
+	TokenStore  alexa.TokenReaderWriter
+	Credentials alexa.CredentialsProvider
+	// Discovery optionally handles the tenant's Alexa.Discovery directives
+	Discovery alexa.Handler
+	// Handler handles the tenant's remaining directives
+	Handler alexa.Handler
+	// EventSender optionally sends the tenant's events, see Router.EventSender
+	EventSender deferred.EventSender
+
+	grantHandler alexa.Handler
+}
+
+type contextKey int
+
+const tenantContextKey contextKey = iota
+
+// WithTenant returns a copy of ctx carrying the tenant of the request
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey, tenant)
+}
+
+// FromContext returns the tenant placed in ctx by WithTenant
+func FromContext(ctx context.Context) (*Tenant, bool) {
+	tenant, ok := ctx.Value(tenantContextKey).(*Tenant)
+	return tenant, ok
+}
+
+// ClientIDReader looks up the client id a bearer token was issued to.
+// It has the same shape as alexa.UserIDReader so alexa.NewCachingUserIDReader
+// can be used to cache lookups.
+type ClientIDReader interface {
+	Read(ctx context.Context, bearerToken string) (string, error)
+}
+
+// DefaultTokenInfoURL is the Login with Amazon token info api
+const DefaultTokenInfoURL = "https://api.amazon.com/auth/o2/tokeninfo"
+
+// TokenInfoClientIDReader reads the client id of a Login with Amazon access token from
+// the token info api. For JWT access tokens an alexa.JWTUserIDReader with Claim set to
+// "aud" or "client_id" can be used instead.
+type TokenInfoClientIDReader struct {
+	// HTTPDoer defaults to alexa.DefaultHTTPClient
+	HTTPDoer alexa.HTTPDoer
+	// TokenInfoURL overrides DefaultTokenInfoURL if set
+	TokenInfoURL string
+}
+
+func (t *TokenInfoClientIDReader) Read(ctx context.Context, bearerToken string) (string, error) {
+	tokenInfoURL := t.TokenInfoURL
+	if tokenInfoURL == "" {
+		tokenInfoURL = DefaultTokenInfoURL
+	}
+
+	infoURL := tokenInfoURL + "?" + url.Values{"access_token": {bearerToken}}.Encode()
+	infoReq, err := http.NewRequest(http.MethodGet, infoURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build token info request: %v", err)
+	}
+
+	infoReq = infoReq.WithContext(ctx)
+
+	infoResp, err := alexa.HTTPDoerOrDefault(t.HTTPDoer).Do(infoReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to perform token info request: %v", err)
+	}
+	defer infoResp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(infoResp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token info body: %v", err)
+	}
+
+	if infoResp.StatusCode == http.StatusBadRequest || infoResp.StatusCode == http.StatusUnauthorized {
+		return "", fmt.Errorf("%w: token info response status code: %s", alexa.ErrInvalidToken, infoResp.Status)
+	}
+	if infoResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token info response unexpected status code: %s", infoResp.Status)
+	}
+
+	var info struct {
+		Aud string `json:"aud"`
+	}
+	if err := json.Unmarshal(respBody, &info); err != nil {
+		return "", fmt.Errorf("failed to unmarshal token info: %v", err)
+	}
+	if info.Aud == "" {
+		return "", errors.New("token info has no aud")
+	}
+
+	return info.Aud, nil
+}
+
+// Router routes directives to the tenant the request's bearer token was issued to.
+// Discovery and AcceptGrant directives are handled with the tenant's discovery
+// handler, token store and credentials when set. The tenant is available to
+// downstream handlers via FromContext. Requests with invalid tokens or for unknown
+// tenants receive an INVALID_AUTHORIZATION_CREDENTIAL error response while other
+// failures to resolve the tenant receive an INTERNAL_ERROR.
+type Router struct {
+	ClientIDReader ClientIDReader
+	// UserIDReader is used when handling AcceptGrant directives
+	UserIDReader alexa.UserIDReader
+	RespBuilder  *alexa.ResponseBuilder
+
+	mu      sync.RWMutex
+	tenants map[string]*Tenant
+}
+
+// Add registers tenant, replacing any tenant with the same ClientID
+func (r *Router) Add(tenant *Tenant) {
+	if tenant.TokenStore != nil && tenant.Credentials != nil {
+		tenant.grantHandler = alexa.HandlerFunc((&alexa.AcceptGrantHandler{
+			Credentials:  tenant.Credentials,
+			UserIDReader: r.UserIDReader,
+			TokenWriter:  tenant.TokenStore,
+			RespBuilder:  r.RespBuilder,
+		}).HandleRequest)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tenants == nil {
+		r.tenants = make(map[string]*Tenant)
+	}
+	r.tenants[tenant.ClientID] = tenant
+}
+
+// Tenant returns the tenant registered for clientID
+func (r *Router) Tenant(clientID string) (*Tenant, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant, ok := r.tenants[clientID]
+	return tenant, ok
+}
+
+// HandleRequest resolves the tenant of the request and delegates to its handlers
+func (r *Router) HandleRequest(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+	token := req.BearerToken()
+	if token == "" {
+		return r.RespBuilder.BasicErrorResponse(req, alexa.ErrorTypeInvalidAuthorizationCredential,
+			"missing bearer token")
+	}
+
+	tenant, err := r.resolve(ctx, token)
+	if err != nil {
+		errorType := alexa.ErrorTypeInternalError
+		if errors.Is(err, alexa.ErrInvalidToken) {
+			errorType = alexa.ErrorTypeInvalidAuthorizationCredential
+		}
+		return r.RespBuilder.BasicErrorResponse(req, errorType, err.Error())
+	}
+	ctx = WithTenant(ctx, tenant)
+
+	switch {
+	case req.Namespace() == alexa.NamespaceDiscovery && tenant.Discovery != nil:
+		return tenant.Discovery.HandleRequest(ctx, req)
+	case req.Namespace() == alexa.NamespaceAuthorization && tenant.grantHandler != nil:
+		return tenant.grantHandler.HandleRequest(ctx, req)
+	case tenant.Handler != nil:
+		return tenant.Handler.HandleRequest(ctx, req)
+	default:
+		return nil, alexa.UnexpectedDirective("tenant.Router", req)
+	}
+}
+
+// EventSender returns a deferred.EventSender that sends each event with the
+// EventSender of the event's tenant. The tenant is taken from ctx when the event is
+// sent while handling a request. Otherwise the user id in ctx, see alexa.WithUserID,
+// is looked up in each tenant's TokenStore. The event's scope token isn't used as it
+// may have expired by the time the event is sent.
+func (r *Router) EventSender() deferred.EventSender {
+	return deferred.EventSenderFunc(func(ctx context.Context, resp *alexa.Response) error {
+		tenant, ok := FromContext(ctx)
+		if !ok {
+			var err error
+			if tenant, err = r.userTenant(ctx); err != nil {
+				return err
+			}
+		}
+		if tenant.EventSender == nil {
+			return fmt.Errorf("tenant %s has no event sender", tenant.ClientID)
+		}
+
+		return tenant.EventSender.Send(WithTenant(ctx, tenant), resp)
+	})
+}
+
+// userTenant finds the tenant holding a token for the user id in ctx
+func (r *Router) userTenant(ctx context.Context) (*Tenant, error) {
+	userID, ok := alexa.UserIDFromContext(ctx)
+	if !ok {
+		return nil, errors.New("no tenant or user id in context")
+	}
+
+	r.mu.RLock()
+	clientIDs := make([]string, 0, len(r.tenants))
+	for clientID := range r.tenants {
+		clientIDs = append(clientIDs, clientID)
+	}
+	r.mu.RUnlock()
+	sort.Strings(clientIDs)
+
+	for _, clientID := range clientIDs {
+		tenant, ok := r.Tenant(clientID)
+		if !ok || tenant.TokenStore == nil {
+			continue
+		}
+		token, err := tenant.TokenStore.Read(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token of %s for tenant %s: %v", userID, clientID, err)
+		}
+		if token != nil {
+			return tenant, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no tenant has a token for user %s", userID)
+}
+
+func (r *Router) resolve(ctx context.Context, token string) (*Tenant, error) {
+	clientID, err := r.ClientIDReader.Read(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve client id: %w", err)
+	}
+
+	tenant, ok := r.Tenant(clientID)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown client id: %s", alexa.ErrInvalidToken, clientID)
+	}
+	return tenant, nil
+}
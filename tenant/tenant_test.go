@@ -0,0 +1,147 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+	"github.com/mctofu/alexa-smart-home/deferred"
+	"golang.org/x/oauth2"
+)
+
+func TestRouter(t *testing.T) {
+	respBuilder := &alexa.ResponseBuilder{MessageID: func() string { return "id" }}
+	clientIDs := map[string]string{"token-a": "client-a", "token-b": "client-b", "token-c": "client-c"}
+
+	handledBy := func(name string) alexa.HandlerFunc {
+		return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+			tenant, ok := FromContext(ctx)
+			if !ok {
+				return nil, errors.New("no tenant in context")
+			}
+			return nil, errors.New(tenant.ClientID + ":" + name)
+		}
+	}
+
+	router := &Router{
+		ClientIDReader: clientIDReaderFunc(func(ctx context.Context, token string) (string, error) {
+			switch token {
+			case "expired":
+				return "", fmt.Errorf("%w: expired", alexa.ErrInvalidToken)
+			case "outage":
+				return "", errors.New("token info unavailable")
+			}
+			return clientIDs[token], nil
+		}),
+		RespBuilder: respBuilder,
+	}
+	router.Add(&Tenant{ClientID: "client-a", Handler: handledBy("handler"), Discovery: handledBy("discovery")})
+	router.Add(&Tenant{ClientID: "client-b", Handler: handledBy("handler")})
+
+	request := func(namespace, token string) *alexa.Request {
+		var req alexa.Request
+		req.Directive.Header.Namespace = namespace
+		req.Directive.Header.Name = "Test"
+		req.Directive.Endpoint.Scope.Token = token
+		return &req
+	}
+
+	for _, test := range []struct {
+		req      *alexa.Request
+		expected string
+	}{
+		{request(alexa.NamespacePowerController, "token-a"), "client-a:handler"},
+		{request(alexa.NamespaceDiscovery, "token-a"), "client-a:discovery"},
+		{request(alexa.NamespaceDiscovery, "token-b"), "client-b:handler"},
+	} {
+		_, err := router.HandleRequest(context.Background(), test.req)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("expected %s, got %v", test.expected, err)
+		}
+	}
+
+	for token, expected := range map[string]string{
+		"token-c": alexa.ErrorTypeInvalidAuthorizationCredential,
+		"expired": alexa.ErrorTypeInvalidAuthorizationCredential,
+		"outage":  alexa.ErrorTypeInternalError,
+	} {
+		resp, err := router.HandleRequest(context.Background(), request(alexa.NamespacePowerController, token))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		var payload struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(resp.Event.Payload, &payload); err != nil {
+			t.Fatalf("failed to unmarshal payload: %v", err)
+		}
+		if payload.Type != expected {
+			t.Errorf("%s: expected %s, got %+v", token, expected, payload)
+		}
+	}
+}
+
+func TestRouterEventSender(t *testing.T) {
+	sentBy := func(clientID string, sent *[]string) deferred.EventSenderFunc {
+		return func(ctx context.Context, resp *alexa.Response) error {
+			tenant, ok := FromContext(ctx)
+			if !ok || tenant.ClientID != clientID {
+				return errors.New("tenant not in context")
+			}
+			*sent = append(*sent, clientID)
+			return nil
+		}
+	}
+
+	var sent []string
+	router := &Router{ClientIDReader: clientIDReaderFunc(func(ctx context.Context, token string) (string, error) {
+		return "", errors.New("tokens must not be resolved for events")
+	})}
+	router.Add(&Tenant{ClientID: "client-a", TokenStore: tokenStore{"user-a": {AccessToken: "a"}}, EventSender: sentBy("client-a", &sent)})
+	router.Add(&Tenant{ClientID: "client-b", TokenStore: tokenStore{"user-b": {AccessToken: "b"}}, EventSender: sentBy("client-b", &sent)})
+	sender := router.EventSender()
+
+	event := &alexa.Response{Event: alexa.Event{Endpoint: &alexa.ResponseEndpoint{
+		Scope: alexa.Scope{Type: "BearerToken", Token: "expired-access-token"},
+	}}}
+
+	ctx := context.Background()
+	if err := sender.Send(alexa.WithUserID(ctx, "user-b"), event); err != nil {
+		t.Fatalf("failed to send for user: %v", err)
+	}
+	tenantA, _ := router.Tenant("client-a")
+	if err := sender.Send(WithTenant(ctx, tenantA), event); err != nil {
+		t.Fatalf("failed to send for tenant in context: %v", err)
+	}
+	if err := sender.Send(alexa.WithUserID(ctx, "user-c"), event); err == nil {
+		t.Error("expected an error for a user without a tenant")
+	}
+	if err := sender.Send(ctx, event); err == nil {
+		t.Error("expected an error without a user id")
+	}
+
+	if !reflect.DeepEqual(sent, []string{"client-b", "client-a"}) {
+		t.Errorf("unexpected sends: %v", sent)
+	}
+}
+
+// tokenStore is a read only alexa.TokenReaderWriter
+type tokenStore map[string]*oauth2.Token
+
+func (t tokenStore) Read(ctx context.Context, id string) (*oauth2.Token, error) {
+	return t[id], nil
+}
+
+func (t tokenStore) Write(ctx context.Context, id string, token *oauth2.Token) error {
+	return errors.New("read only")
+}
+
+type clientIDReaderFunc func(ctx context.Context, token string) (string, error)
+
+func (c clientIDReaderFunc) Read(ctx context.Context, token string) (string, error) {
+	return c(ctx, token)
+}
@@ -0,0 +1,360 @@
+package zigbee2mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+// DefaultBaseTopic is the Zigbee2MQTT default base topic
+const DefaultBaseTopic = "zigbee2mqtt"
+
+// FriendlyNameCookie is the endpoint cookie key holding the device's Zigbee2MQTT friendly name
+const FriendlyNameCookie = "zigbee2mqtt.friendlyName"
+
+// access flags of an exposed feature
+const (
+	accessState = 1
+	accessSet   = 2
+	accessGet   = 4
+)
+
+// Device is a device listed on the bridge/devices topic
+type Device struct {
+	IEEEAddress        string      `json:"ieee_address"`
+	FriendlyName       string      `json:"friendly_name"`
+	Type               string      `json:"type"`
+	InterviewCompleted bool        `json:"interview_completed"`
+	Disabled           bool        `json:"disabled"`
+	Definition         *Definition `json:"definition"`
+}
+
+// Definition describes a supported device model
+type Definition struct {
+	Model       string   `json:"model"`
+	Vendor      string   `json:"vendor"`
+	Description string   `json:"description"`
+	Exposes     []Expose `json:"exposes"`
+}
+
+// Expose is a capability of a device. Composite types such as light and switch
+// group their properties in Features.
+type Expose struct {
+	Type     string   `json:"type"`
+	Name     string   `json:"name"`
+	Property string   `json:"property"`
+	Access   int      `json:"access"`
+	Unit     string   `json:"unit"`
+	ValueMin *float64 `json:"value_min"`
+	ValueMax *float64 `json:"value_max"`
+	Features []Expose `json:"features"`
+}
+
+// ParseDevices parses the payload of the bridge/devices topic
+func ParseDevices(payload []byte) ([]Device, error) {
+	var devices []Device
+	if err := json.Unmarshal(payload, &devices); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal devices: %v", err)
+	}
+	return devices, nil
+}
+
+// features flattens the device's exposes so composite types don't need special handling.
+// The composite type is kept to pick the display category.
+func (d *Device) features() ([]Expose, []string) {
+	if d.Definition == nil {
+		return nil, nil
+	}
+
+	var features []Expose
+	var composites []string
+	for _, expose := range d.Definition.Exposes {
+		if len(expose.Features) > 0 {
+			composites = append(composites, expose.Type)
+			features = append(features, expose.Features...)
+			continue
+		}
+		features = append(features, expose)
+	}
+	return features, composites
+}
+
+// Endpoint converts the device to an endpoint. ok is false for the coordinator, disabled
+// devices and devices that expose nothing that maps to a smart home capability.
+func (d *Device) Endpoint() (endpoint alexa.DiscoverEndpoint, ok bool) {
+	if d.Type == "Coordinator" || d.Disabled || !d.InterviewCompleted || d.Definition == nil {
+		return endpoint, false
+	}
+
+	features, composites := d.features()
+
+	var capabilities []alexa.DiscoverCapability
+	category := alexa.DisplayCategoryOther
+	for _, feature := range features {
+		capability, featureCategory, ok := capabilityFor(feature)
+		if !ok {
+			continue
+		}
+		capabilities = append(capabilities, capability)
+		if category == alexa.DisplayCategoryOther {
+			category = featureCategory
+		}
+	}
+	if len(capabilities) == 0 {
+		return endpoint, false
+	}
+
+	for _, composite := range composites {
+		switch composite {
+		case "light":
+			category = alexa.DisplayCategoryLight
+		case "switch":
+			category = alexa.DisplayCategorySwitch
+		}
+	}
+
+	capabilities = append(capabilities, alexa.DiscoverCapability{
+		Type:      "AlexaInterface",
+		Interface: "Alexa",
+		Version:   "3",
+	})
+
+	return alexa.DiscoverEndpoint{
+		EndpointID:        d.IEEEAddress,
+		ManufacturerName:  d.Definition.Vendor,
+		FriendlyName:      d.FriendlyName,
+		Description:       fmt.Sprintf("%s %s", d.Definition.Vendor, d.Definition.Description),
+		DisplayCategories: []string{category},
+		Cookie:            map[string]string{FriendlyNameCookie: d.FriendlyName},
+		Capabilities:      capabilities,
+	}, true
+}
+
+// capabilityFor maps an exposed feature to the capability controlling it
+func capabilityFor(feature Expose) (alexa.DiscoverCapability, string, bool) {
+	properties := func(name string) *alexa.DiscoverProperties {
+		return &alexa.DiscoverProperties{
+			Supported:           []alexa.DiscoverProperty{{Name: name}},
+			ProactivelyReported: feature.Access&accessState != 0,
+			Retrievable:         feature.Access&(accessState|accessGet) != 0,
+		}
+	}
+
+	switch {
+	case feature.Property == "state" && feature.Type == "binary" && feature.Access&accessSet != 0:
+		return alexa.DiscoverCapability{
+			Type:       "AlexaInterface",
+			Interface:  alexa.InterfacePowerController,
+			Version:    "3",
+			Properties: properties("powerState"),
+		}, alexa.DisplayCategorySwitch, true
+	case feature.Property == "position" && feature.Type == "numeric" && feature.Access&accessSet != 0:
+		return alexa.DiscoverCapability{
+			Type:       "AlexaInterface",
+			Interface:  alexa.InterfacePercentageController,
+			Version:    "3",
+			Properties: properties("percentage"),
+		}, alexa.DisplayCategoryInteriorBlind, true
+	case feature.Property == "temperature" && feature.Type == "numeric":
+		return alexa.DiscoverCapability{
+			Type:       "AlexaInterface",
+			Interface:  alexa.InterfaceTemperatureSensor,
+			Version:    "3",
+			Properties: properties("temperature"),
+		}, alexa.DisplayCategoryTemperatureSensor, true
+	}
+
+	return alexa.DiscoverCapability{}, "", false
+}
+
+// Properties converts a device state message published on the device's topic to the
+// properties of its endpoint, for example to record in a state.Store.
+func Properties(payload []byte, timeOfSample time.Time) ([]alexa.ContextProperty, error) {
+	var message struct {
+		State       *string  `json:"state"`
+		Position    *float64 `json:"position"`
+		Temperature *float64 `json:"temperature"`
+	}
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %v", err)
+	}
+
+	var props []alexa.ContextProperty
+	add := func(namespace, name string, value interface{}) error {
+		valueJSON, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %v", name, err)
+		}
+		props = append(props, alexa.ContextProperty{
+			Namespace:    namespace,
+			Name:         name,
+			Value:        valueJSON,
+			TimeOfSample: timeOfSample,
+		})
+		return nil
+	}
+
+	if message.State != nil && (*message.State == "ON" || *message.State == "OFF") {
+		if err := add(alexa.NamespacePowerController, "powerState", *message.State); err != nil {
+			return nil, err
+		}
+	}
+	if message.Position != nil {
+		if err := add(alexa.NamespacePercentageController, "percentage", int(*message.Position)); err != nil {
+			return nil, err
+		}
+	}
+	if message.Temperature != nil {
+		value := alexa.TemperatureValue{Value: float32(*message.Temperature), Scale: alexa.TemperatureScaleCelsius}
+		if err := add(alexa.NamespaceTemperatureSensor, "temperature", value); err != nil {
+			return nil, err
+		}
+	}
+
+	return props, nil
+}
+
+// Publisher publishes a message to an MQTT topic
+type Publisher interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+}
+
+// Bridge tracks the devices of a Zigbee2MQTT bridge and controls them. It doesn't depend
+// on an MQTT client: pass messages received on DevicesTopic to UpdateDevices and provide
+// a Publisher to send commands.
+type Bridge struct {
+	// BaseTopic defaults to DefaultBaseTopic
+	BaseTopic   string
+	Publisher   Publisher
+	RespBuilder *alexa.ResponseBuilder
+
+	mu      sync.RWMutex
+	devices map[string]Device
+}
+
+// DevicesTopic is the topic the bridge publishes its device list on. Subscribe to it
+// and pass messages to UpdateDevices.
+func (b *Bridge) DevicesTopic() string {
+	return b.baseTopic() + "/bridge/devices"
+}
+
+// SetTopic is the topic commands for the device are published to
+func (b *Bridge) SetTopic(friendlyName string) string {
+	return b.baseTopic() + "/" + friendlyName + "/set"
+}
+
+func (b *Bridge) baseTopic() string {
+	if b.BaseTopic == "" {
+		return DefaultBaseTopic
+	}
+	return b.BaseTopic
+}
+
+// UpdateDevices replaces the known devices with those in a bridge/devices message
+func (b *Bridge) UpdateDevices(payload []byte) error {
+	devices, err := ParseDevices(payload)
+	if err != nil {
+		return err
+	}
+
+	byAddress := make(map[string]Device, len(devices))
+	for _, device := range devices {
+		byAddress[device.IEEEAddress] = device
+	}
+
+	b.mu.Lock()
+	b.devices = byAddress
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Endpoints returns an endpoint for each device with supported capabilities ordered by id
+func (b *Bridge) Endpoints() []alexa.DiscoverEndpoint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	endpoints := make([]alexa.DiscoverEndpoint, 0, len(b.devices))
+	for _, device := range b.devices {
+		if endpoint, ok := device.Endpoint(); ok {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].EndpointID < endpoints[j].EndpointID
+	})
+	return endpoints
+}
+
+// DiscoveryHandler responds to discovery requests with the bridge's current endpoints
+func (b *Bridge) DiscoveryHandler() alexa.HandlerFunc {
+	return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		return b.RespBuilder.DiscoverResponse(b.Endpoints()...)
+	}
+}
+
+// PowerControllerHandler publishes TurnOn and TurnOff directives as state commands
+func (b *Bridge) PowerControllerHandler() alexa.HandlerFunc {
+	command := func(state string) alexa.HandlerFunc {
+		return func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+			return b.command(ctx, req, map[string]interface{}{"state": state},
+				alexa.NamespacePowerController, "powerState", state)
+		}
+	}
+	return alexa.PowerControllerHandler(command("ON"), command("OFF"))
+}
+
+// PercentageControllerHandler publishes SetPercentage directives as position commands.
+// AdjustPercentage requires the current position so it's rejected as an invalid directive.
+func (b *Bridge) PercentageControllerHandler() alexa.HandlerFunc {
+	setPct := alexa.Typed(func(ctx context.Context, req *alexa.Request, payload alexa.SetPercentagePayload) (*alexa.Response, error) {
+		return b.command(ctx, req, map[string]interface{}{"position": payload.Percentage},
+			alexa.NamespacePercentageController, "percentage", payload.Percentage)
+	})
+	adjustPct := alexa.HandlerFunc(func(ctx context.Context, req *alexa.Request) (*alexa.Response, error) {
+		return nil, alexa.UnexpectedDirective("zigbee2mqtt.PercentageControllerHandler", req)
+	})
+	return alexa.PercentageControllerHandler(setPct, adjustPct)
+}
+
+// command publishes the command to the device targeted by req and responds with the
+// property value it sets
+func (b *Bridge) command(ctx context.Context, req *alexa.Request, command map[string]interface{},
+	namespace, name string, value interface{}) (*alexa.Response, error) {
+	friendlyName := req.CookieValue(FriendlyNameCookie)
+	if friendlyName == "" {
+		b.mu.RLock()
+		friendlyName = b.devices[req.EndpointID()].FriendlyName
+		b.mu.RUnlock()
+	}
+	if friendlyName == "" {
+		return b.RespBuilder.BasicErrorResponse(req, alexa.ErrorTypeNoSuchEndpoint,
+			fmt.Sprintf("unknown zigbee device %s", req.EndpointID()))
+	}
+
+	commandJSON, err := json.Marshal(command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal command: %v", err)
+	}
+	if err := b.Publisher.Publish(ctx, b.SetTopic(friendlyName), commandJSON); err != nil {
+		return b.RespBuilder.BasicErrorResponse(req, alexa.ErrorTypeEndpointUnreachable,
+			fmt.Sprintf("failed to publish command: %v", err))
+	}
+
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal value: %v", err)
+	}
+	return b.RespBuilder.BasicResponse(req, alexa.ContextProperty{
+		Namespace:                 namespace,
+		Name:                      name,
+		Value:                     valueJSON,
+		TimeOfSample:              time.Now(),
+		UncertaintyInMilliseconds: 500,
+	}), nil
+}
@@ -0,0 +1,118 @@
+package zigbee2mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mctofu/alexa-smart-home/alexa"
+)
+
+const devicesMessage = `[
+  {"ieee_address": "0x00124b0000000000", "friendly_name": "Coordinator", "type": "Coordinator",
+   "interview_completed": true},
+  {"ieee_address": "0x0017880100000001", "friendly_name": "living/lamp", "type": "Router",
+   "interview_completed": true,
+   "definition": {"model": "9290012573A", "vendor": "Philips", "description": "Hue white and color ambiance E26/E27",
+     "exposes": [
+       {"type": "light", "features": [
+         {"type": "binary", "name": "state", "property": "state", "access": 7, "value_on": "ON", "value_off": "OFF"},
+         {"type": "numeric", "name": "brightness", "property": "brightness", "access": 7, "value_min": 0, "value_max": 254}
+       ]},
+       {"type": "numeric", "name": "linkquality", "property": "linkquality", "access": 1}
+     ]}},
+  {"ieee_address": "0x00158d0000000002", "friendly_name": "bedroom/sensor", "type": "EndDevice",
+   "interview_completed": true,
+   "definition": {"model": "WSDCGQ11LM", "vendor": "Xiaomi", "description": "Temperature, humidity and pressure sensor",
+     "exposes": [
+       {"type": "numeric", "name": "temperature", "property": "temperature", "access": 1, "unit": "°C"},
+       {"type": "numeric", "name": "humidity", "property": "humidity", "access": 1, "unit": "%"}
+     ]}},
+  {"ieee_address": "0x00158d0000000003", "friendly_name": "remote", "type": "EndDevice",
+   "interview_completed": true,
+   "definition": {"model": "WXKG01LM", "vendor": "Xiaomi", "description": "Wireless mini switch",
+     "exposes": [{"type": "enum", "name": "action", "property": "action", "access": 1}]}}
+]`
+
+type recordingPublisher struct {
+	topic   string
+	payload string
+}
+
+func (r *recordingPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	r.topic = topic
+	r.payload = string(payload)
+	return nil
+}
+
+func TestBridge(t *testing.T) {
+	publisher := &recordingPublisher{}
+	bridge := &Bridge{
+		Publisher:   publisher,
+		RespBuilder: &alexa.ResponseBuilder{MessageID: func() string { return "msg-1" }},
+	}
+	if err := bridge.UpdateDevices([]byte(devicesMessage)); err != nil {
+		t.Fatalf("failed to update devices: %v", err)
+	}
+
+	endpoints := bridge.Endpoints()
+	if len(endpoints) != 2 {
+		t.Fatalf("expected 2 endpoints, got %+v", endpoints)
+	}
+
+	lamp := endpoints[1]
+	if lamp.EndpointID != "0x0017880100000001" || lamp.DisplayCategories[0] != alexa.DisplayCategoryLight {
+		t.Errorf("unexpected lamp endpoint: %+v", lamp)
+	}
+	if lamp.Capabilities[0].Interface != alexa.InterfacePowerController ||
+		!lamp.Capabilities[0].Properties.ProactivelyReported {
+		t.Errorf("expected proactively reported power controller: %+v", lamp.Capabilities[0])
+	}
+
+	sensor := endpoints[0]
+	if sensor.DisplayCategories[0] != alexa.DisplayCategoryTemperatureSensor ||
+		sensor.Capabilities[0].Interface != alexa.InterfaceTemperatureSensor {
+		t.Errorf("unexpected sensor endpoint: %+v", sensor)
+	}
+
+	req := &alexa.Request{
+		Directive: alexa.RequestDirective{
+			Header:   alexa.Header{Namespace: alexa.NamespacePowerController, Name: "TurnOn"},
+			Endpoint: alexa.RequestEndpoint{EndpointID: lamp.EndpointID, Cookie: lamp.Cookie},
+			Payload:  alexa.EmptyPayload,
+		},
+	}
+	resp, err := bridge.PowerControllerHandler()(context.Background(), req)
+	if err != nil {
+		t.Fatalf("failed to handle TurnOn: %v", err)
+	}
+	if resp.Event.Header.Name != "Response" {
+		t.Errorf("expected Response, got %s", resp.Event.Header.Name)
+	}
+	if publisher.topic != "zigbee2mqtt/living/lamp/set" || publisher.payload != `{"state":"ON"}` {
+		t.Errorf("unexpected command %s %s", publisher.topic, publisher.payload)
+	}
+}
+
+func TestProperties(t *testing.T) {
+	sampled := time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)
+	props, err := Properties([]byte(`{"state":"OFF","temperature":21.5,"linkquality":120}`), sampled)
+	if err != nil {
+		t.Fatalf("failed to convert state: %v", err)
+	}
+	if len(props) != 2 {
+		t.Fatalf("expected 2 properties, got %+v", props)
+	}
+	if string(props[0].Value) != `"OFF"` {
+		t.Errorf("unexpected powerState: %s", props[0].Value)
+	}
+
+	var temp alexa.TemperatureValue
+	if err := json.Unmarshal(props[1].Value, &temp); err != nil {
+		t.Fatalf("failed to unmarshal temperature: %v", err)
+	}
+	if temp.Value != 21.5 || temp.Scale != alexa.TemperatureScaleCelsius {
+		t.Errorf("unexpected temperature: %+v", temp)
+	}
+}